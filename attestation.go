@@ -0,0 +1,108 @@
+package regclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/blob"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// Attestation is an in-toto statement that buildkit attached to an image within the index.
+type Attestation struct {
+	Subject       types.Descriptor `json:"subject"`       // image the attestation describes, including the platform
+	Manifest      types.Descriptor `json:"manifest"`      // attestation manifest in the index
+	Descriptor    types.Descriptor `json:"descriptor"`    // in-toto statement blob
+	PredicateType string           `json:"predicateType"` // predicate type of the statement, e.g. https://slsa.dev/provenance/v0.2
+}
+
+// AttestationList returns the buildkit attestations in an index.
+// Buildkit adds an attestation manifest to the index for each platform, with layers containing the in-toto statements.
+// ImageWithPlatform limits the list to the attestations for a single platform.
+func (rc *RegClient) AttestationList(ctx context.Context, r ref.Ref, opts ...ImageOpts) ([]Attestation, error) {
+	var opt imageOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	mi, ok := m.(manifest.Indexer)
+	if !ok {
+		return nil, fmt.Errorf("attestations are only found in an index, %s is %s%.0w", r.CommonName(), m.GetDescriptor().MediaType, types.ErrUnsupportedMediaType)
+	}
+	dl, err := mi.GetManifestList()
+	if err != nil {
+		return nil, err
+	}
+	subjects := map[string]types.Descriptor{}
+	for _, d := range dl {
+		subjects[d.Digest.String()] = d
+	}
+	// resolve the platform to a single subject
+	subjectDig := ""
+	if opt.platform != "" {
+		p, err := platform.Parse(opt.platform)
+		if err != nil {
+			return nil, err
+		}
+		d, err := manifest.GetPlatformDesc(m, &p)
+		if err != nil {
+			return nil, err
+		}
+		subjectDig = d.Digest.String()
+	}
+	result := []Attestation{}
+	for _, d := range dl {
+		if d.Annotations[types.AnnotationDockerReferenceType] != types.DockerReferenceTypeAttestation {
+			continue
+		}
+		dig := d.Annotations[types.AnnotationDockerReferenceDigest]
+		if subjectDig != "" && dig != subjectDig {
+			continue
+		}
+		subject, ok := subjects[dig]
+		if !ok {
+			return nil, fmt.Errorf("attestation %s references %s which is not in the index%.0w", d.Digest.String(), dig, types.ErrNotFound)
+		}
+		mAtt, err := rc.ManifestGet(ctx, r, WithManifestDesc(d))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get attestation manifest %s: %w", d.Digest.String(), err)
+		}
+		mAttImg, ok := mAtt.(manifest.Imager)
+		if !ok {
+			return nil, fmt.Errorf("attestation %s is not an image manifest%.0w", d.Digest.String(), types.ErrUnsupportedMediaType)
+		}
+		layers, err := mAttImg.GetLayers()
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range layers {
+			if l.MediaType != types.MediaTypeInToto {
+				continue
+			}
+			result = append(result, Attestation{
+				Subject:       subject,
+				Manifest:      d,
+				Descriptor:    l,
+				PredicateType: l.Annotations[types.AnnotationInTotoPredicateType],
+			})
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no attestations found in %s%.0w", r.CommonName(), types.ErrNotFound)
+	}
+	return result, nil
+}
+
+// AttestationGet returns a reader for the in-toto statement of an attestation.
+func (rc *RegClient) AttestationGet(ctx context.Context, r ref.Ref, a Attestation) (blob.Reader, error) {
+	if a.Descriptor.MediaType != types.MediaTypeInToto {
+		return nil, fmt.Errorf("attestation media type %s is not an in-toto statement%.0w", a.Descriptor.MediaType, types.ErrUnsupportedMediaType)
+	}
+	return rc.BlobGet(ctx, r, a.Descriptor)
+}
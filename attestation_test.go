@@ -0,0 +1,153 @@
+package regclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+const attestationProvenance = "https://slsa.dev/provenance/v0.2"
+
+// attestationSetup pushes an index with an image and a buildkit style attestation manifest
+func attestationSetup(ctx context.Context, rc *RegClient, r ref.Ref, rImg ref.Ref) ([]byte, error) {
+	mImg, err := rc.ManifestHead(ctx, rImg, WithManifestRequireDigest())
+	if err != nil {
+		return nil, err
+	}
+	dImg := mImg.GetDescriptor()
+	err = rc.ImageCopy(ctx, rImg, r)
+	if err != nil {
+		return nil, err
+	}
+	dImg.Platform = &platform.Platform{OS: "linux", Architecture: "amd64"}
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"` + attestationProvenance + `","subject":[{"name":"test","digest":{"sha256":"` + dImg.Digest.Encoded() + `"}}],"predicate":{}}`)
+	dStatement, err := rc.BlobPut(ctx, r, types.Descriptor{}, bytes.NewReader(statement))
+	if err != nil {
+		return nil, err
+	}
+	dStatement.MediaType = types.MediaTypeInToto
+	dStatement.Annotations = map[string]string{types.AnnotationInTotoPredicateType: attestationProvenance}
+	conf := []byte(`{"architecture":"unknown","os":"unknown","rootfs":{"type":"layers","diff_ids":["` + digest.FromBytes(statement).String() + `"]}}`)
+	dConf, err := rc.BlobPut(ctx, r, types.Descriptor{}, bytes.NewReader(conf))
+	if err != nil {
+		return nil, err
+	}
+	dConf.MediaType = types.MediaTypeOCI1ImageConfig
+	mAtt, err := manifest.New(manifest.WithOrig(v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: types.MediaTypeOCI1Manifest,
+		Config:    dConf,
+		Layers:    []types.Descriptor{dStatement},
+	}))
+	if err != nil {
+		return nil, err
+	}
+	rAtt := r
+	rAtt.Tag = ""
+	rAtt.Digest = mAtt.GetDescriptor().Digest.String()
+	err = rc.ManifestPut(ctx, rAtt, mAtt, WithManifestChild())
+	if err != nil {
+		return nil, err
+	}
+	dAtt := mAtt.GetDescriptor()
+	dAtt.Platform = &platform.Platform{OS: "unknown", Architecture: "unknown"}
+	dAtt.Annotations = map[string]string{
+		types.AnnotationDockerReferenceType:   types.DockerReferenceTypeAttestation,
+		types.AnnotationDockerReferenceDigest: dImg.Digest.String(),
+	}
+	mIdx, err := manifest.New(manifest.WithOrig(v1.Index{
+		Versioned: v1.IndexSchemaVersion,
+		MediaType: types.MediaTypeOCI1ManifestList,
+		Manifests: []types.Descriptor{dImg, dAtt},
+	}))
+	if err != nil {
+		return nil, err
+	}
+	err = rc.ManifestPut(ctx, r, mIdx)
+	if err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+func TestAttestation(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	rc := New(WithFS(fsMem))
+	rImg, err := ref.New("ocidir://testrepo:a1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	r, err := ref.New("ocidir://testattest:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	statement, err := attestationSetup(ctx, rc, r, rImg)
+	if err != nil {
+		t.Fatalf("failed to setup attestation: %v", err)
+	}
+	t.Run("List", func(t *testing.T) {
+		al, err := rc.AttestationList(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to list attestations: %v", err)
+		}
+		if len(al) != 1 || al[0].PredicateType != attestationProvenance || al[0].Subject.Platform == nil || al[0].Subject.Platform.Architecture != "amd64" {
+			t.Errorf("unexpected attestations: %v", al)
+		}
+	})
+	t.Run("Get", func(t *testing.T) {
+		al, err := rc.AttestationList(ctx, r, ImageWithPlatform("linux/amd64"))
+		if err != nil || len(al) != 1 {
+			t.Fatalf("failed to list attestations: %v", err)
+		}
+		rdr, err := rc.AttestationGet(ctx, r, al[0])
+		if err != nil {
+			t.Fatalf("failed to get attestation: %v", err)
+		}
+		defer rdr.Close()
+		out, err := io.ReadAll(rdr)
+		if err != nil {
+			t.Fatalf("failed to read attestation: %v", err)
+		}
+		if !bytes.Equal(out, statement) {
+			t.Errorf("statement mismatch, expected %s, received %s", statement, out)
+		}
+	})
+	t.Run("Missing platform", func(t *testing.T) {
+		_, err := rc.AttestationList(ctx, r, ImageWithPlatform("linux/s390x"))
+		if err == nil {
+			t.Errorf("attestation found for missing platform")
+		}
+	})
+	t.Run("No attestations", func(t *testing.T) {
+		rNone, err := ref.New("ocidir://testrepo:v1")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		_, err = rc.AttestationList(ctx, rNone)
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected not found, received %v", err)
+		}
+	})
+	t.Run("Image", func(t *testing.T) {
+		_, err = rc.AttestationList(ctx, rImg)
+		if !errors.Is(err, types.ErrUnsupportedMediaType) {
+			t.Errorf("expected unsupported media type, received %v", err)
+		}
+	})
+}
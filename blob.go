@@ -3,10 +3,14 @@ package regclient
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/regclient/regclient/internal/throttle"
+	"github.com/regclient/regclient/pkg/trace"
 	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/blob"
@@ -14,10 +18,15 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const blobCBFreq = time.Millisecond * 100
+const (
+	blobCBFreq = time.Millisecond * 100
+	// blobCopyRestartLimit is the number of times a failed blob copy is restarted from the source
+	blobCopyRestartLimit = 3
+)
 
 type blobOpt struct {
-	callback func(kind types.CallbackKind, instance string, state types.CallbackState, cur, total int64)
+	callback      func(kind types.CallbackKind, instance string, state types.CallbackState, cur, total int64)
+	ignoreMissing bool
 }
 
 // BlobOpts define options for the Image* commands
@@ -30,10 +39,21 @@ func BlobWithCallback(callback func(kind types.CallbackKind, instance string, st
 	}
 }
 
+// BlobWithIgnoreMissing treats a missing blob as a successful BlobDelete
+func BlobWithIgnoreMissing() BlobOpts {
+	return func(opts *blobOpt) {
+		opts.ignoreMissing = true
+	}
+}
+
 // BlobCopy copies a blob between two locations
 // If the blob already exists in the target, the copy is skipped
 // A server side cross repository blob mount is attempted
-func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, d types.Descriptor, opts ...BlobOpts) error {
+// An upload that cannot resume from the source stream is restarted with a new request to the source
+func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, d types.Descriptor, opts ...BlobOpts) (err error) {
+	ctx, span := rc.traceStart(ctx, "BlobCopy", refTgt)
+	defer func() { trace.End(span, err) }()
+	span.SetAttributes(trace.Attr{Key: "regclient.digest", Value: d.Digest.String()}, trace.Attr{Key: "regclient.size", Value: d.Size})
 	var opt blobOpt
 	for _, optFn := range opts {
 		optFn(&opt)
@@ -66,6 +86,11 @@ func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Re
 		}).Debug("Blob copy skipped, already exists")
 		return nil
 	}
+	// validate the blob once, the mount and put below skip the hooks
+	err = rc.pushHookRun(ctx, refTgt, tDesc)
+	if err != nil {
+		return err
+	}
 	// acquire throttle for both src and tgt to avoid deadlocks
 	tList := []*throttle.Throttle{}
 	schemeSrcAPI, err := rc.schemeGet(refSrc.Scheme)
@@ -82,6 +107,9 @@ func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Re
 	if tTgt, ok := schemeTgtAPI.(scheme.Throttler); ok {
 		tList = append(tList, tTgt.Throttle(refTgt, true)...)
 	}
+	if rc.blobThrottle != nil {
+		tList = append(tList, rc.blobThrottle)
+	}
 	if len(tList) > 0 {
 		ctx, err = throttle.AcquireMulti(ctx, tList)
 		if err != nil {
@@ -92,7 +120,7 @@ func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Re
 
 	// try mounting blob from the source repo is the registry is the same
 	if ref.EqualRegistry(refSrc, refTgt) {
-		err := rc.BlobMount(ctx, refSrc, refTgt, d)
+		err := rc.blobMount(ctx, refSrc, refTgt, d)
 		if err == nil {
 			if opt.callback != nil {
 				opt.callback(types.CallbackBlob, d.Digest.String(), types.CallbackSkipped, 0, d.Size)
@@ -120,6 +148,8 @@ func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Re
 		}).Warn("Failed to retrieve blob")
 		return err
 	}
+	// blobIOMu protects blobIO when a restart replaces the reader while the callback reports progress
+	var blobIOMu sync.Mutex
 	if opt.callback != nil {
 		opt.callback(types.CallbackBlob, d.Digest.String(), types.CallbackStarted, 0, d.Size)
 		ticker := time.NewTicker(blobCBFreq)
@@ -137,7 +167,9 @@ func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Re
 				case <-done:
 					return
 				case <-ticker.C:
+					blobIOMu.Lock()
 					offset, err := blobIO.Seek(0, io.SeekCurrent)
+					blobIOMu.Unlock()
 					if err == nil && offset > 0 {
 						opt.callback(types.CallbackBlob, d.Digest.String(), types.CallbackActive, offset, d.Size)
 					}
@@ -145,8 +177,32 @@ func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Re
 			}
 		}()
 	}
-	defer blobIO.Close()
-	if _, err := rc.BlobPut(ctx, refTgt, blobIO.GetDescriptor(), blobIO); err != nil {
+	defer func() {
+		blobIOMu.Lock()
+		blobIO.Close()
+		blobIOMu.Unlock()
+	}()
+	_, err = rc.blobPut(ctx, refTgt, blobIO.GetDescriptor(), blobIO)
+	// the target could not resume from the source reader, restart the transfer with a new request to the source
+	for i := 0; err != nil && errors.Is(err, types.ErrRetryNeeded) && ctx.Err() == nil && i < blobCopyRestartLimit; i++ {
+		rc.log.WithFields(logrus.Fields{
+			"err":    err,
+			"src":    refSrc.Reference,
+			"tgt":    refTgt.Reference,
+			"digest": d.Digest,
+		}).Debug("Restarting blob copy")
+		var blobIONew blob.Reader
+		blobIONew, err = rc.BlobGet(ctx, refSrc, d)
+		if err != nil {
+			break
+		}
+		blobIOMu.Lock()
+		blobIO.Close()
+		blobIO = blobIONew
+		blobIOMu.Unlock()
+		_, err = rc.blobPut(ctx, refTgt, blobIO.GetDescriptor(), blobIO)
+	}
+	if err != nil {
 		rc.log.WithFields(logrus.Fields{
 			"err": err,
 			"src": refSrc.Reference,
@@ -160,25 +216,106 @@ func (rc *RegClient) BlobCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Re
 // BlobDelete removes a blob from the registry
 // This method should only be used to repair a damaged registry
 // Typically a server side garbage collection should be used to purge unused blobs
-func (rc *RegClient) BlobDelete(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+func (rc *RegClient) BlobDelete(ctx context.Context, r ref.Ref, d types.Descriptor, opts ...BlobOpts) (err error) {
+	ctx, span := rc.traceStart(ctx, "BlobDelete", r)
+	defer func() { trace.End(span, err) }()
+	span.SetAttributes(trace.Attr{Key: "regclient.digest", Value: d.Digest.String()})
+	var opt blobOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	schemeAPI, err := rc.schemeGet(r.Scheme)
+	if err != nil {
+		return err
+	}
+	err = schemeAPI.BlobDelete(ctx, r, d)
+	if opt.ignoreMissing {
+		err = ignoreMissing(err, func() error {
+			br, err := schemeAPI.BlobHead(ctx, r, d)
+			if err == nil {
+				_ = br.Close()
+			}
+			return err
+		})
+	}
+	return err
+}
+
+// BlobUploadCancel deletes an in-progress upload session, removing any partially uploaded content.
+// The location is the upload URL returned by the registry, or the upload session UUID.
+// Schemes without upload sessions return ErrUnsupportedAPI.
+func (rc *RegClient) BlobUploadCancel(ctx context.Context, r ref.Ref, location string) error {
 	schemeAPI, err := rc.schemeGet(r.Scheme)
 	if err != nil {
 		return err
 	}
-	return schemeAPI.BlobDelete(ctx, r, d)
+	uc, ok := schemeAPI.(scheme.BlobUploadCanceler)
+	if !ok {
+		return fmt.Errorf("upload cancel is not supported by %s%.0w", r.Scheme, types.ErrUnsupportedAPI)
+	}
+	return uc.BlobUploadCancel(ctx, r, location)
 }
 
 // BlobGet retrieves a blob, returning a reader
-func (rc *RegClient) BlobGet(ctx context.Context, r ref.Ref, d types.Descriptor) (blob.Reader, error) {
+// The trace span ends when the returned reader is closed or the content is fully read.
+func (rc *RegClient) BlobGet(ctx context.Context, r ref.Ref, d types.Descriptor) (br blob.Reader, err error) {
+	ctx, span := rc.traceStart(ctx, "BlobGet", r)
+	span.SetAttributes(trace.Attr{Key: "regclient.digest", Value: d.Digest.String()}, trace.Attr{Key: "regclient.size", Value: d.Size})
 	data, err := d.GetData()
 	if err == nil {
+		trace.End(span, nil)
 		return blob.NewReader(blob.WithDesc(d), blob.WithRef(r), blob.WithReader(bytes.NewReader(data))), nil
 	}
 	schemeAPI, err := rc.schemeGet(r.Scheme)
 	if err != nil {
+		trace.End(span, err)
 		return nil, err
 	}
-	return schemeAPI.BlobGet(ctx, r, d)
+	br, err = schemeAPI.BlobGet(ctx, r, d)
+	if err != nil || rc.tracer == nil {
+		trace.End(span, err)
+		return br, err
+	}
+	return &blobSpanReader{Reader: br, span: span}, nil
+}
+
+// blobSpanReader ends the trace span of a blob once the content is read, converted, or the reader is closed
+type blobSpanReader struct {
+	blob.Reader
+	span trace.Span
+	once sync.Once
+}
+
+func (b *blobSpanReader) end(err error) {
+	b.once.Do(func() { trace.End(b.span, err) })
+}
+
+func (b *blobSpanReader) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.end(nil)
+	} else if err != nil {
+		b.end(err)
+	}
+	return n, err
+}
+
+func (b *blobSpanReader) Close() error {
+	err := b.Reader.Close()
+	b.end(err)
+	return err
+}
+
+func (b *blobSpanReader) ToOCIConfig() (blob.OCIConfig, error) {
+	oc, err := b.Reader.ToOCIConfig()
+	b.end(err)
+	return oc, err
+}
+
+func (b *blobSpanReader) ToTarReader() (blob.TarReader, error) {
+	tr, err := b.Reader.ToTarReader()
+	b.end(err)
+	return tr, err
 }
 
 // BlobGetOCIConfig retrieves an OCI config from a blob, automatically extracting the JSON
@@ -191,7 +328,10 @@ func (rc *RegClient) BlobGetOCIConfig(ctx context.Context, ref ref.Ref, d types.
 }
 
 // BlobHead is used to verify if a blob exists and is accessible
-func (rc *RegClient) BlobHead(ctx context.Context, r ref.Ref, d types.Descriptor) (blob.Reader, error) {
+func (rc *RegClient) BlobHead(ctx context.Context, r ref.Ref, d types.Descriptor) (br blob.Reader, err error) {
+	ctx, span := rc.traceStart(ctx, "BlobHead", r)
+	defer func() { trace.End(span, err) }()
+	span.SetAttributes(trace.Attr{Key: "regclient.digest", Value: d.Digest.String()})
 	schemeAPI, err := rc.schemeGet(r.Scheme)
 	if err != nil {
 		return nil, err
@@ -201,6 +341,17 @@ func (rc *RegClient) BlobHead(ctx context.Context, r ref.Ref, d types.Descriptor
 
 // BlobMount attempts to perform a server side copy/mount of the blob between repositories
 func (rc *RegClient) BlobMount(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, d types.Descriptor) error {
+	err := rc.pushHookRun(ctx, refTgt, d)
+	if err != nil {
+		return err
+	}
+	return rc.blobMount(ctx, refSrc, refTgt, d)
+}
+
+func (rc *RegClient) blobMount(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, d types.Descriptor) (err error) {
+	ctx, span := rc.traceStart(ctx, "BlobMount", refTgt)
+	defer func() { trace.End(span, err) }()
+	span.SetAttributes(trace.Attr{Key: "regclient.digest", Value: d.Digest.String()})
 	schemeAPI, err := rc.schemeGet(refSrc.Scheme)
 	if err != nil {
 		return err
@@ -213,6 +364,19 @@ func (rc *RegClient) BlobMount(ctx context.Context, refSrc ref.Ref, refTgt ref.R
 // It will then try doing a full put of the blob without chunking (most widely supported).
 // If the full put fails, it will fall back to a chunked upload (useful for flaky networks).
 func (rc *RegClient) BlobPut(ctx context.Context, ref ref.Ref, d types.Descriptor, rdr io.Reader) (types.Descriptor, error) {
+	err := rc.pushHookRun(ctx, ref, d)
+	if err != nil {
+		return types.Descriptor{}, err
+	}
+	return rc.blobPut(ctx, ref, d, rdr)
+}
+
+func (rc *RegClient) blobPut(ctx context.Context, ref ref.Ref, d types.Descriptor, rdr io.Reader) (dOut types.Descriptor, err error) {
+	ctx, span := rc.traceStart(ctx, "BlobPut", ref)
+	defer func() {
+		span.SetAttributes(trace.Attr{Key: "regclient.digest", Value: dOut.Digest.String()}, trace.Attr{Key: "regclient.size", Value: dOut.Size})
+		trace.End(span, err)
+	}()
 	schemeAPI, err := rc.schemeGet(ref.Scheme)
 	if err != nil {
 		return types.Descriptor{}, err
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -1099,6 +1102,19 @@ func TestBlobCopy(t *testing.T) {
 			},
 		},
 	}
+	// failed mounts and uploads cancel the upload session
+	for i, u := range []uuid.UUID{uuid1, uuid2, uuid3} {
+		rrs = append(rrs, reqresp.ReqResp{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   fmt.Sprintf("DELETE for repo b - d%d", i+1),
+				Method: "DELETE",
+				Path:   "/v2" + blobRepoB + "/blobs/uploads/" + u.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusNoContent,
+			},
+		})
+	}
 	rrs = append(rrs, reqresp.BaseEntries...)
 	// create a server
 	ts := httptest.NewServer(reqresp.NewHandler(t, rrs))
@@ -1181,3 +1197,147 @@ func TestBlobCopy(t *testing.T) {
 	})
 
 }
+
+// BenchmarkBlobCopy copies a generated blob between two OCI Layouts on disk.
+// Set REGCLIENT_BENCH_BLOB_SIZE to the size in bytes to test larger blobs, e.g. 10737418240 for 10GB.
+// The reported sys-MB is the memory obtained from the OS, and should not grow with the blob size.
+func BenchmarkBlobCopy(b *testing.B) {
+	ctx := context.Background()
+	size := int64(64 * 1024 * 1024)
+	if s := os.Getenv("REGCLIENT_BENCH_BLOB_SIZE"); s != "" {
+		_, err := fmt.Sscanf(s, "%d", &size)
+		if err != nil {
+			b.Fatalf("failed to parse REGCLIENT_BENCH_BLOB_SIZE: %v", err)
+		}
+	}
+	dir := b.TempDir()
+	rSrc, err := ref.New("ocidir://" + dir + "/src")
+	if err != nil {
+		b.Fatalf("failed to parse ref: %v", err)
+	}
+	rc := New()
+	// generate the source blob as a stream to avoid holding it in memory
+	d, err := rc.BlobPut(ctx, rSrc, types.Descriptor{}, io.LimitReader(rand.New(rand.NewSource(1)), size))
+	if err != nil {
+		b.Fatalf("failed to put source blob: %v", err)
+	}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rTgt, err := ref.New(fmt.Sprintf("ocidir://%s/tgt-%d", dir, i))
+		if err != nil {
+			b.Fatalf("failed to parse ref: %v", err)
+		}
+		err = rc.BlobCopy(ctx, rSrc, rTgt, d)
+		if err != nil {
+			b.Fatalf("failed to copy blob: %v", err)
+		}
+	}
+	b.StopTimer()
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	b.ReportMetric(float64(ms.Sys)/1024/1024, "sys-MB")
+}
+
+func TestBlobCopyRestart(t *testing.T) {
+	ctx := context.Background()
+	blobRepo := "/proj/repo"
+	blobChunk := 512
+	d1, blob1 := reqresp.NewRandomBlob(blobChunk*4, time.Now().UTC().Unix())
+	uploadPath := "/v2" + blobRepo + "/blobs/uploads/session"
+	var mu sync.Mutex
+	gets := 0
+	tsSrc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v2"+blobRepo+"/blobs/"+d1.String() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		gets++
+		mu.Unlock()
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(blob1)))
+		w.Header().Set("Docker-Content-Digest", d1.String())
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(blob1)
+	}))
+	defer tsSrc.Close()
+	// the target drops content from the end of the second chunk once, requiring content the stream no longer has
+	received := []byte{}
+	dropped := false
+	tsTgt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v2"+blobRepo+"/blobs/uploads/":
+			received = []byte{}
+			w.Header().Set("Location", uploadPath)
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPatch && r.URL.Path == uploadPath:
+			body, _ := io.ReadAll(r.Body)
+			var start, end int
+			_, err := fmt.Sscanf(r.Header.Get("Content-Range"), "%d-%d", &start, &end)
+			if err == nil && start == blobChunk*2 && !dropped {
+				dropped = true
+				received = received[:len(received)-100]
+			}
+			if err != nil || start != len(received) {
+				w.Header().Set("Location", uploadPath)
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			received = append(received, body...)
+			w.Header().Set("Location", uploadPath)
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && r.URL.Path == uploadPath:
+			if r.URL.Query().Get("digest") != d1.String() || !bytes.Equal(received, blob1) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", d1.String())
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete && r.URL.Path == uploadPath:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer tsTgt.Close()
+	srcURL, _ := url.Parse(tsSrc.URL)
+	tgtURL, _ := url.Parse(tsTgt.URL)
+	rc := New(
+		WithConfigHost(
+			config.Host{
+				Name:     srcURL.Host,
+				Hostname: srcURL.Host,
+				TLS:      config.TLSDisabled,
+			},
+			config.Host{
+				Name:      tgtURL.Host,
+				Hostname:  tgtURL.Host,
+				TLS:       config.TLSDisabled,
+				BlobChunk: int64(blobChunk),
+				BlobMax:   int64(blobChunk),
+			},
+		),
+		WithLog(&logrus.Logger{Out: io.Discard}),
+		WithRetryDelay(time.Millisecond, time.Millisecond),
+	)
+	rSrc, err := ref.New(srcURL.Host + blobRepo)
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+	rTgt, err := ref.New(tgtURL.Host + blobRepo)
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+	err = rc.BlobCopy(ctx, rSrc, rTgt, types.Descriptor{Digest: d1, Size: int64(len(blob1))})
+	if err != nil {
+		t.Fatalf("failed to copy blob: %v", err)
+	}
+	if !dropped || gets != 2 {
+		t.Errorf("copy was not restarted from the source, dropped %t, gets %d", dropped, gets)
+	}
+}
@@ -0,0 +1,302 @@
+package regclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/schema"
+	"github.com/sirupsen/logrus"
+)
+
+type imageCheckOpt struct {
+	blobs        bool
+	referrers    bool
+	referrerOpts []scheme.ReferrerOpts
+	schema       bool
+}
+
+// ImageCheckOpts define options for ImageCheck
+type ImageCheckOpts func(*imageCheckOpt)
+
+// ImageCheckWithBlobs downloads every blob to verify the digest and size.
+// Without this option, blobs are only checked with a HEAD request.
+func ImageCheckWithBlobs() ImageCheckOpts {
+	return func(opts *imageCheckOpt) {
+		opts.blobs = true
+	}
+}
+
+// ImageCheckWithReferrers includes the referrers to each manifest in the check.
+func ImageCheckWithReferrers(rOpts ...scheme.ReferrerOpts) ImageCheckOpts {
+	return func(opts *imageCheckOpt) {
+		opts.referrers = true
+		opts.referrerOpts = append(opts.referrerOpts, rOpts...)
+	}
+}
+
+// ImageCheckWithSchema validates each manifest and image config against the OCI image-spec schemas.
+// Content that does not match the schema is included in the Invalid list of the report.
+func ImageCheckWithSchema() ImageCheckOpts {
+	return func(opts *imageCheckOpt) {
+		opts.schema = true
+	}
+}
+
+// ImageCheckEntry describes missing or corrupt content found by ImageCheck
+type ImageCheckEntry struct {
+	Digest    digest.Digest `json:"digest"`
+	MediaType string        `json:"mediaType,omitempty"`
+	Size      int64         `json:"size"`
+	Parent    digest.Digest `json:"parent,omitempty"` // manifest that references the content
+	Err       string        `json:"error"`
+	// Violations lists the fields that do not match the schema for Invalid entries
+	Violations []schema.Violation `json:"violations,omitempty"`
+}
+
+// ImageCheckReport contains the results of ImageCheck
+type ImageCheckReport struct {
+	Manifests int               `json:"manifests"` // number of manifests checked
+	Blobs     int               `json:"blobs"`     // number of blobs checked
+	Missing   []ImageCheckEntry `json:"missing"`
+	Corrupt   []ImageCheckEntry `json:"corrupt"`
+	Invalid   []ImageCheckEntry `json:"invalid,omitempty"` // content that does not match the schema, see ImageCheckWithSchema
+}
+
+// OK returns true when no missing, corrupt, or invalid content was found
+func (report ImageCheckReport) OK() bool {
+	return len(report.Missing) == 0 && len(report.Corrupt) == 0 && len(report.Invalid) == 0
+}
+
+// ImageCheck verifies every manifest and blob referenced by an image or index exists with the expected digest and size.
+// External layers, with URLs in the descriptor, are not checked.
+// Missing and corrupt content is included in the report.
+// An error is only returned when the check could not be completed.
+func (rc *RegClient) ImageCheck(ctx context.Context, r ref.Ref, opts ...ImageCheckOpts) (ImageCheckReport, error) {
+	var opt imageCheckOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	report := ImageCheckReport{
+		Missing: []ImageCheckEntry{},
+		Corrupt: []ImageCheckEntry{},
+		Invalid: []ImageCheckEntry{},
+	}
+	m, err := rc.ManifestHead(ctx, r, WithManifestRequireDigest())
+	if err != nil {
+		return report, err
+	}
+	seen := map[digest.Digest]bool{}
+	err = rc.imageCheckManifest(ctx, r, m.GetDescriptor(), "", seen, opt, &report)
+	return report, err
+}
+
+func (rc *RegClient) imageCheckManifest(ctx context.Context, r ref.Ref, d types.Descriptor, parent digest.Digest, seen map[digest.Digest]bool, opt imageCheckOpt, report *ImageCheckReport) error {
+	if seen[d.Digest] {
+		return nil
+	}
+	seen[d.Digest] = true
+	report.Manifests++
+	rDig := r
+	rDig.Tag = ""
+	rDig.Digest = d.Digest.String()
+	m, err := rc.ManifestGet(ctx, rDig)
+	if err != nil {
+		if imageCheckNotFound(err) {
+			report.add(&report.Missing, d, parent, err)
+			return nil
+		}
+		return fmt.Errorf("failed to get manifest %s: %w", rDig.CommonName(), err)
+	}
+	// the digest of signed schema1 manifests is not computed from the raw body
+	if d.MediaType != types.MediaTypeDocker1ManifestSigned {
+		raw, err := m.RawBody()
+		if err != nil {
+			return fmt.Errorf("failed to read manifest %s: %w", rDig.CommonName(), err)
+		}
+		err = imageCheckContent(d, raw)
+		if err != nil {
+			report.add(&report.Corrupt, d, parent, err)
+			return nil
+		}
+	}
+	if opt.schema {
+		raw, err := m.RawBody()
+		if err != nil {
+			return fmt.Errorf("failed to read manifest %s: %w", rDig.CommonName(), err)
+		}
+		report.addSchema(m.GetDescriptor().MediaType, raw, d, parent)
+	}
+
+	if mi, ok := m.(manifest.Indexer); ok {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return fmt.Errorf("failed to get manifest list %s: %w", rDig.CommonName(), err)
+		}
+		for _, dChild := range dl {
+			err = rc.imageCheckManifest(ctx, r, dChild, d.Digest, seen, opt, report)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if mi, ok := m.(manifest.Imager); ok {
+		// schema1 manifests do not have a config
+		if cd, err := mi.GetConfig(); err == nil {
+			if opt.schema && !seen[cd.Digest] && schema.Supported(cd.MediaType) {
+				err = rc.imageCheckConfigSchema(ctx, r, cd, d.Digest, report)
+				if err != nil {
+					return err
+				}
+			}
+			err = rc.imageCheckBlob(ctx, r, cd, d.Digest, seen, opt, report)
+			if err != nil {
+				return err
+			}
+		}
+		layers, err := mi.GetLayers()
+		if err != nil {
+			return fmt.Errorf("failed to get layers %s: %w", rDig.CommonName(), err)
+		}
+		for _, layer := range layers {
+			if len(layer.URLs) > 0 {
+				// external layers are pulled from the URLs by the runtime and may not exist in the repository
+				continue
+			}
+			err = rc.imageCheckBlob(ctx, r, layer, d.Digest, seen, opt, report)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if opt.referrers {
+		rl, err := rc.ReferrerList(ctx, rDig, opt.referrerOpts...)
+		if err != nil {
+			return fmt.Errorf("failed to list referrers %s: %w", rDig.CommonName(), err)
+		}
+		for _, dReferrer := range rl.Descriptors {
+			err = rc.imageCheckManifest(ctx, r, dReferrer, d.Digest, seen, opt, report)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (rc *RegClient) imageCheckBlob(ctx context.Context, r ref.Ref, d types.Descriptor, parent digest.Digest, seen map[digest.Digest]bool, opt imageCheckOpt, report *ImageCheckReport) error {
+	if seen[d.Digest] {
+		return nil
+	}
+	seen[d.Digest] = true
+	report.Blobs++
+	rc.log.WithFields(logrus.Fields{
+		"ref":    r.CommonName(),
+		"digest": d.Digest.String(),
+	}).Debug("Checking blob")
+	if !opt.blobs {
+		// request the blob without the expected size to have the size returned by the server
+		b, err := rc.BlobHead(ctx, r, types.Descriptor{Digest: d.Digest})
+		if err != nil {
+			if imageCheckNotFound(err) {
+				report.add(&report.Missing, d, parent, err)
+				return nil
+			}
+			return fmt.Errorf("failed to check blob %s: %w", d.Digest.String(), err)
+		}
+		_ = b.Close()
+		if size := b.GetDescriptor().Size; size > 0 && size != d.Size {
+			report.add(&report.Corrupt, d, parent, fmt.Errorf("size mismatch, expected %d, received %d%.0w", d.Size, size, types.ErrMismatch))
+		}
+		return nil
+	}
+	b, err := rc.BlobGet(ctx, r, d)
+	if err != nil {
+		if imageCheckNotFound(err) {
+			report.add(&report.Missing, d, parent, err)
+			return nil
+		}
+		return fmt.Errorf("failed to get blob %s: %w", d.Digest.String(), err)
+	}
+	defer b.Close()
+	// the blob reader verifies the size and digest after reading the content
+	_, err = io.Copy(io.Discard, b)
+	if err != nil {
+		if errors.Is(err, types.ErrDigestMismatch) || errors.Is(err, types.ErrShortRead) || errors.Is(err, types.ErrSizeLimitExceeded) {
+			report.add(&report.Corrupt, d, parent, err)
+			return nil
+		}
+		return fmt.Errorf("failed to read blob %s: %w", d.Digest.String(), err)
+	}
+	return nil
+}
+
+// imageCheckConfigSchema validates the image config, a missing config is reported by imageCheckBlob
+func (rc *RegClient) imageCheckConfigSchema(ctx context.Context, r ref.Ref, d types.Descriptor, parent digest.Digest, report *ImageCheckReport) error {
+	b, err := rc.BlobGet(ctx, r, d)
+	if err != nil {
+		if imageCheckNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get config %s: %w", d.Digest.String(), err)
+	}
+	defer b.Close()
+	raw, err := b.RawBody()
+	if err != nil {
+		if errors.Is(err, types.ErrDigestMismatch) || errors.Is(err, types.ErrShortRead) || errors.Is(err, types.ErrSizeLimitExceeded) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config %s: %w", d.Digest.String(), err)
+	}
+	report.addSchema(d.MediaType, raw, d, parent)
+	return nil
+}
+
+// addSchema validates the content and adds an Invalid entry with the violations
+func (report *ImageCheckReport) addSchema(mediaType string, raw []byte, d types.Descriptor, parent digest.Digest) {
+	err := schema.Validate(mediaType, raw)
+	if err == nil || errors.Is(err, types.ErrUnsupportedMediaType) {
+		return
+	}
+	report.add(&report.Invalid, d, parent, err)
+	var errSchema *schema.Error
+	if errors.As(err, &errSchema) {
+		report.Invalid[len(report.Invalid)-1].Violations = errSchema.Violations
+	}
+}
+
+func (report *ImageCheckReport) add(list *[]ImageCheckEntry, d types.Descriptor, parent digest.Digest, err error) {
+	*list = append(*list, ImageCheckEntry{
+		Digest:    d.Digest,
+		MediaType: d.MediaType,
+		Size:      d.Size,
+		Parent:    parent,
+		Err:       err.Error(),
+	})
+}
+
+// imageCheckContent verifies the raw content matches the digest and size of the descriptor
+func imageCheckContent(d types.Descriptor, raw []byte) error {
+	if d.Size > 0 && int64(len(raw)) != d.Size {
+		return fmt.Errorf("size mismatch, expected %d, received %d%.0w", d.Size, len(raw), types.ErrMismatch)
+	}
+	if !d.Digest.Algorithm().Available() {
+		return fmt.Errorf("digest algorithm is not available: %s%.0w", d.Digest.String(), types.ErrUnsupported)
+	}
+	if dig := d.Digest.Algorithm().FromBytes(raw); dig != d.Digest {
+		return fmt.Errorf("digest mismatch, expected %s, calculated %s%.0w", d.Digest.String(), dig.String(), types.ErrDigestMismatch)
+	}
+	return nil
+}
+
+func imageCheckNotFound(err error) bool {
+	return errors.Is(err, types.ErrNotFound) || errors.Is(err, fs.ErrNotExist)
+}
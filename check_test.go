@@ -0,0 +1,219 @@
+package regclient
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestImageCheck(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:v2")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	// find a config and layer in the amd64 image
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	dAMD64, err := manifest.GetPlatformDesc(m, &platform.Platform{OS: "linux", Architecture: "amd64"})
+	if err != nil {
+		t.Errorf("failed to get amd64 descriptor: %v", err)
+		return
+	}
+	rAMD64 := r
+	rAMD64.Tag = ""
+	rAMD64.Digest = dAMD64.Digest.String()
+	mAMD64, err := rc.ManifestGet(ctx, rAMD64)
+	if err != nil {
+		t.Errorf("failed to get amd64 manifest: %v", err)
+		return
+	}
+	dConf, err := mAMD64.(manifest.Imager).GetConfig()
+	if err != nil {
+		t.Errorf("failed to get config: %v", err)
+		return
+	}
+	layers, err := mAMD64.(manifest.Imager).GetLayers()
+	if err != nil || len(layers) == 0 {
+		t.Errorf("failed to get layers: %v", err)
+		return
+	}
+	dLayer := layers[0]
+
+	t.Run("Valid", func(t *testing.T) {
+		report, err := rc.ImageCheck(ctx, r, ImageCheckWithBlobs(), ImageCheckWithReferrers())
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		if !report.OK() {
+			t.Errorf("unexpected problems: %v", report)
+		}
+		if report.Blobs == 0 {
+			t.Errorf("no blobs checked")
+		}
+		reportNoRef, err := rc.ImageCheck(ctx, r)
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		if reportNoRef.Manifests >= report.Manifests {
+			t.Errorf("referrers were not checked, manifest count %d, without referrers %d", report.Manifests, reportNoRef.Manifests)
+		}
+	})
+	t.Run("Schema", func(t *testing.T) {
+		report, err := rc.ImageCheck(ctx, r, ImageCheckWithSchema())
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		if !report.OK() {
+			t.Errorf("unexpected problems: %v", report)
+		}
+		// push an image with a config missing the rootfs and a layer with an invalid media type
+		rInvalid := r
+		rInvalid.Tag = "invalid"
+		confRaw := []byte(`{"architecture":"amd64","os":"linux","created":"yesterday"}`)
+		dConfInvalid, err := rc.BlobPut(ctx, rInvalid, types.Descriptor{}, bytes.NewReader(confRaw))
+		if err != nil {
+			t.Errorf("failed to put config: %v", err)
+			return
+		}
+		dConfInvalid.MediaType = types.MediaTypeOCI1ImageConfig
+		dLayerInvalid := dLayer
+		dLayerInvalid.MediaType = "not a media type"
+		mInvalid, err := manifest.New(manifest.WithOrig(v1.Manifest{
+			Versioned: v1.ManifestSchemaVersion,
+			MediaType: types.MediaTypeOCI1Manifest,
+			Config:    dConfInvalid,
+			Layers:    []types.Descriptor{dLayerInvalid},
+		}))
+		if err != nil {
+			t.Errorf("failed to create manifest: %v", err)
+			return
+		}
+		err = rc.ManifestPut(ctx, rInvalid, mInvalid)
+		if err != nil {
+			t.Errorf("failed to put manifest: %v", err)
+			return
+		}
+		report, err = rc.ImageCheck(ctx, rInvalid)
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		if len(report.Invalid) > 0 {
+			t.Errorf("schema checked without the option: %v", report)
+		}
+		report, err = rc.ImageCheck(ctx, rInvalid, ImageCheckWithSchema())
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		if report.OK() || len(report.Invalid) != 2 {
+			t.Errorf("invalid content not reported: %v", report)
+			return
+		}
+		fields := map[string]bool{}
+		for _, e := range report.Invalid {
+			for _, v := range e.Violations {
+				fields[v.Field] = true
+			}
+		}
+		for _, field := range []string{"layers[0].mediaType", "created", "rootfs"} {
+			if !fields[field] {
+				t.Errorf("violation for %s not reported: %v", field, report.Invalid)
+			}
+		}
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		fh, err := fsMem.Create("testrepo/blobs/" + dConf.Digest.Algorithm().String() + "/" + dConf.Digest.Encoded())
+		if err != nil {
+			t.Errorf("failed to open config: %v", err)
+			return
+		}
+		_, err = fh.Write([]byte("corrupt config"))
+		_ = fh.Close()
+		if err != nil {
+			t.Errorf("failed to write config: %v", err)
+			return
+		}
+		report, err := rc.ImageCheck(ctx, r)
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		if len(report.Corrupt) != 1 || report.Corrupt[0].Digest != dConf.Digest || report.Corrupt[0].Parent != dAMD64.Digest {
+			t.Errorf("size mismatch not detected: %v", report)
+		}
+		// a blob with the same size is only detected when blobs are downloaded
+		data := make([]byte, dConf.Size)
+		fh, err = fsMem.Create("testrepo/blobs/" + dConf.Digest.Algorithm().String() + "/" + dConf.Digest.Encoded())
+		if err != nil {
+			t.Errorf("failed to open config: %v", err)
+			return
+		}
+		_, err = fh.Write(data)
+		_ = fh.Close()
+		if err != nil {
+			t.Errorf("failed to write config: %v", err)
+			return
+		}
+		report, err = rc.ImageCheck(ctx, r)
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		if !report.OK() {
+			t.Errorf("unexpected problems with a HEAD check: %v", report)
+		}
+		report, err = rc.ImageCheck(ctx, r, ImageCheckWithBlobs())
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		if len(report.Corrupt) != 1 || report.Corrupt[0].Digest != dConf.Digest {
+			t.Errorf("digest mismatch not detected: %v", report)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		err := fsMem.Remove("testrepo/blobs/" + dLayer.Digest.Algorithm().String() + "/" + dLayer.Digest.Encoded())
+		if err != nil {
+			t.Errorf("failed to remove layer: %v", err)
+			return
+		}
+		report, err := rc.ImageCheck(ctx, r)
+		if err != nil {
+			t.Errorf("failed to check image: %v", err)
+			return
+		}
+		found := false
+		for _, e := range report.Missing {
+			if e.Digest == dLayer.Digest {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing layer not reported: %v", report)
+		}
+	})
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/regclient/regclient/config"
-	"github.com/regclient/regclient/pkg/template"
+	"github.com/robfig/cron/v3"
+	"github.com/yuin/gopher-lua/parse"
 	"gopkg.in/yaml.v2"
 )
 
@@ -26,7 +29,9 @@ type ConfigDefaults struct {
 	Parallel int           `yaml:"parallel" json:"parallel"`
 	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
 	// general options
+	ArtifactLimit  int64  `yaml:"artifactLimit" json:"artifactLimit"`
 	BlobLimit      int64  `yaml:"blobLimit" json:"blobLimit"`
+	CatalogCache   string `yaml:"catalogCache" json:"catalogCache"`
 	SkipDockerConf bool   `yaml:"skipDockerConfig" json:"skipDockerConfig"`
 	UserAgent      string `yaml:"userAgent" json:"userAgent"`
 }
@@ -88,34 +93,50 @@ func ConfigLoadFile(filename string) (*Config, error) {
 	return nil, err
 }
 
-// expand templates in various parts of the config
-func configExpandTemplates(c *Config) error {
-	for i := range c.Creds {
-		val, err := template.String(c.Creds[i].User, nil)
-		if err != nil {
-			return err
+// ConfigWrite outputs the processed config
+func ConfigWrite(c *Config, w io.Writer) error {
+	return yaml.NewEncoder(w).Encode(c)
+}
+
+// ConfigValidate checks the loaded config for errors that would otherwise be seen on the first run of each script.
+// All errors found are returned together.
+func ConfigValidate(c *Config) error {
+	errs := []error{}
+	for i, host := range c.Creds {
+		if err := host.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("creds[%d]: %w%.0w", i, err, ErrInvalidInput))
 		}
-		c.Creds[i].User = val
-		val, err = template.String(c.Creds[i].Pass, nil)
-		if err != nil {
-			return err
+	}
+	names := map[string]bool{}
+	for i, s := range c.Scripts {
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("scripts[%d]", i)
+			errs = append(errs, fmt.Errorf("%s: name is required%.0w", name, ErrMissingInput))
+		} else if names[name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate script name%.0w", name, ErrInvalidInput))
 		}
-		c.Creds[i].Pass = val
-		val, err = template.String(c.Creds[i].RegCert, nil)
-		if err != nil {
-			return err
+		names[name] = true
+		if s.Schedule != "" {
+			if _, err := cron.ParseStandard(s.Schedule); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid schedule %q: %w", name, s.Schedule, err))
+			}
 		}
-		c.Creds[i].RegCert = val
-		val, err = template.String(c.Creds[i].ClientCert, nil)
-		if err != nil {
-			return err
+		// syntax errors are found without running the script
+		if _, err := parse.Parse(strings.NewReader(s.Script), name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid script: %w", name, err))
 		}
-		c.Creds[i].ClientCert = val
-		val, err = template.String(c.Creds[i].ClientKey, nil)
+	}
+	return errors.Join(errs...)
+}
+
+// expand templates in various parts of the config
+func configExpandTemplates(c *Config) error {
+	for i := range c.Creds {
+		err := c.Creds[i].ExpandTemplates()
 		if err != nil {
 			return err
 		}
-		c.Creds[i].ClientKey = val
 	}
 	return nil
 }
@@ -6,26 +6,65 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/regclient/regclient/internal/godbg"
 	"github.com/sirupsen/logrus"
 )
 
+// exitCodeSignal is added to the signal number for the exit code after a shutdown from a signal, matching the shell convention
+const exitCodeSignal = 128
+
+var (
+	// shutdown is closed when a signal is received, no new tasks are started after this
+	shutdown = make(chan struct{})
+	// shutdownSig is the signal that started the shutdown
+	shutdownSig os.Signal
+)
+
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
-	sig := make(chan os.Signal, 1)
+	sig := make(chan os.Signal, 2)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-sig
-		log.WithFields(logrus.Fields{}).Debug("Interrupt received, stopping")
-		// clean shutdown
+		shutdownSig = <-sig
+		log.WithFields(logrus.Fields{
+			"signal":  shutdownSig.String(),
+			"timeout": rootOpts.shutdownTimeout.String(),
+		}).Info("Signal received, finishing running tasks")
+		close(shutdown)
+		// running tasks are canceled after the timeout or a second signal
+		select {
+		case <-sig:
+			log.WithFields(logrus.Fields{}).Warn("Second signal received, canceling running tasks")
+		case <-time.After(rootOpts.shutdownTimeout):
+			log.WithFields(logrus.Fields{}).Info("Shutdown timeout reached, canceling running tasks")
+		}
 		cancel()
 	}()
 	godbg.SignalTrace()
 
+	code := 0
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(1)
+		code = 1
+	}
+	select {
+	case <-shutdown:
+		if s, ok := shutdownSig.(syscall.Signal); ok {
+			code = exitCodeSignal + int(s)
+		}
+	default:
+	}
+	os.Exit(code)
+}
+
+// shuttingDown returns true after a signal is received
+func shuttingDown() bool {
+	select {
+	case <-shutdown:
+		return true
+	default:
+		return false
 	}
-	os.Exit(0)
 }
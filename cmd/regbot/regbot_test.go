@@ -3,11 +3,16 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/cmd/regbot/sandbox"
 	"github.com/regclient/regclient/internal/rwfs"
 	"github.com/regclient/regclient/internal/throttle"
 	"github.com/regclient/regclient/types/ref"
@@ -31,6 +36,7 @@ func TestRegbot(t *testing.T) {
   defaults:
     parallel: 1
     timeout: 60s
+    artifactLimit: 1024
   `
 	confRdr := bytes.NewReader([]byte(confBytes))
 	conf, err = ConfigLoadReader(confRdr)
@@ -138,6 +144,54 @@ func TestRegbot(t *testing.T) {
 			},
 			expErr: nil,
 		},
+		{
+			name: "Referrers",
+			script: ConfigScript{
+				Name: "Referrers",
+				Script: `
+				image.copy("ocidir://testrepo:v1", "ocidir://testart:v1")
+				d = artifact.put("ocidir://testart:v1", {
+					artifactType = "application/example.sbom",
+					annotations = {["org.example.created"] = "2024-01-01T00:00:00Z"},
+					subject = "ocidir://testart:v1",
+					files = {{mediaType = "application/json", title = "sbom.json", content = "{}"}},
+				})
+				list = referrer.ls("ocidir://testart:v1", {artifactType = "application/example.sbom"})
+				if #list ~= 1 or list[1].digest ~= d then
+					error("unexpected referrers")
+				end
+				if list[1].annotations["org.example.created"] ~= "2024-01-01T00:00:00Z" then
+					error("missing referrer annotation")
+				end
+				if #referrer.ls("ocidir://testart:v1", {artifactType = "application/example.signature"}) ~= 0 then
+					error("unexpected signature")
+				end
+				a = artifact.get("ocidir://testart@" .. d)
+				if a.artifactType ~= "application/example.sbom" or a.subject == "" then
+					error("unexpected artifact")
+				end
+				if #a.files ~= 1 or a.files[1].title ~= "sbom.json" or a.files[1].content ~= "{}" then
+					error("unexpected artifact files")
+				end
+				m = manifest.head("ocidir://testart@" .. d)
+				if m:annotations()["org.example.created"] ~= "2024-01-01T00:00:00Z" then
+					error("missing manifest annotation")
+				end
+				if pcall(artifact.get, "ocidir://testart:v1") then
+					error("artifact.get loaded an image")
+				end
+				artifact.put("ocidir://testart:large", {
+					artifactType = "application/example.sbom",
+					files = {{title = "large.json", content = string.rep("x", 2048)}},
+				})
+				if pcall(artifact.get, "ocidir://testart:large") then
+					error("artifact.get exceeded the limit")
+				end
+				`,
+			},
+			exists: []string{"ocidir://testart:v1"},
+			expErr: nil,
+		},
 		{
 			name: "Timeout",
 			script: ConfigScript{
@@ -205,3 +259,113 @@ func TestRegbot(t *testing.T) {
 		})
 	}
 }
+
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	throttleC = throttle.New(1)
+	rc = regclient.New(regclient.WithFS(fsMem))
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	rootOpts.dryRun = true
+	rootOpts.report = reportFile
+	defer func() {
+		rootOpts.dryRun = false
+		rootOpts.report = ""
+	}()
+	script := ConfigScript{
+		Name: "Report",
+		Script: `
+		image.copy("ocidir://testrepo:v1", "ocidir://testreport:v1")
+		tag.delete("ocidir://testrepo:v2")
+		`,
+	}
+	err = script.process(ctx)
+	if err != nil {
+		t.Errorf("failed to process: %v", err)
+		return
+	}
+	b, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Errorf("failed to read report: %v", err)
+		return
+	}
+	rr := RunReport{}
+	err = json.Unmarshal(b, &rr)
+	if err != nil {
+		t.Errorf("failed to parse report: %v", err)
+		return
+	}
+	if rr.Script != "Report" || !rr.DryRun || rr.End.Before(rr.Start) {
+		t.Errorf("unexpected report: %v", rr)
+	}
+	if len(rr.Actions) != 2 {
+		t.Errorf("unexpected actions: %v", rr.Actions)
+		return
+	}
+	if rr.Actions[0].Action != sandbox.ActionImageCopy || rr.Actions[0].Target != "ocidir://testreport:v1" || !rr.Actions[0].DryRun {
+		t.Errorf("unexpected copy action: %v", rr.Actions[0])
+	}
+	if rr.Actions[1].Action != sandbox.ActionTagDelete || rr.Actions[1].Target != "ocidir://testrepo:v2" {
+		t.Errorf("unexpected delete action: %v", rr.Actions[1])
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	// CAUTION: the below yaml is space indented and will not parse with tabs
+	cValid := `
+    version: 1
+    scripts:
+      - name: cleanup
+        schedule: "15 3 * * *"
+        script: |
+          for _, t in ipairs(tag.ls("registry:5000/repo")) do
+            log(t)
+          end
+`
+	c, err := ConfigLoadReader(strings.NewReader(cValid))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	err = ConfigValidate(c)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cInvalid := `
+    version: 1
+    creds:
+      - registry: registry:5000
+        clientCert: cert.pem
+    scripts:
+      - name: cleanup
+        schedule: "every hour"
+        script: |
+          for _, t in ipairs(tag.ls("registry:5000/repo")) do
+            log(t)
+      - name: cleanup
+        script: log("dup")
+`
+	c, err = ConfigLoadReader(strings.NewReader(cInvalid))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	err = ConfigValidate(c)
+	if err == nil {
+		t.Fatalf("validate did not fail")
+	}
+	for _, exp := range []string{
+		"creds[0]: registry:5000: clientCert and clientKey must be set together",
+		"cleanup: invalid schedule",
+		"cleanup: invalid script",
+		"cleanup: duplicate script name",
+	} {
+		if !strings.Contains(err.Error(), exp) {
+			t.Errorf("error missing %q: %v", exp, err)
+		}
+	}
+}
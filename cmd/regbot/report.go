@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/regclient/regclient/cmd/regbot/sandbox"
+)
+
+// RunReport lists the actions of a single script run, written when --report is set
+type RunReport struct {
+	Script  string           `json:"script"`
+	Start   time.Time        `json:"start"`
+	End     time.Time        `json:"end"`
+	DryRun  bool             `json:"dryRun"`
+	Actions []sandbox.Action `json:"actions"`
+	Error   string           `json:"error,omitempty"`
+}
+
+var reportMu sync.Mutex
+
+// reportWrite appends the report as a single line of json, "-" outputs to stdout
+func reportWrite(filename string, rr RunReport) error {
+	b, err := json.Marshal(rr)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	if filename == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	fh, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fh.Write(b)
+	if errC := fh.Close(); err == nil {
+		err = errC
+	}
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/cmd/regbot/sandbox"
@@ -25,11 +26,13 @@ More details at https://github.com/regclient/regclient`
 )
 
 var rootOpts struct {
-	confFile  string
-	dryRun    bool
-	verbosity string
-	logopts   []string
-	format    string // for Go template formatting of various commands
+	confFile        string
+	dryRun          bool
+	report          string
+	verbosity       string
+	logopts         []string
+	format          string // for Go template formatting of various commands
+	shutdownTimeout time.Duration
 }
 
 var (
@@ -62,6 +65,16 @@ returns after the last script completes.`,
 	RunE: runOnce,
 }
 
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the config",
+	Long: `Validate the config without running any scripts.
+Credentials, schedules, and the script syntax are checked, and the config with
+defaults applied is output.`,
+	Args: cobra.RangeArgs(0, 0),
+	RunE: runValidate,
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show the version",
@@ -79,16 +92,20 @@ func init() {
 	}
 	rootCmd.PersistentFlags().StringVarP(&rootOpts.confFile, "config", "c", "", "Config file")
 	rootCmd.PersistentFlags().BoolVarP(&rootOpts.dryRun, "dry-run", "", false, "Dry Run, skip all external actions")
+	rootCmd.PersistentFlags().StringVarP(&rootOpts.report, "report", "", "", "Append a json report of the actions from each script run to a file, \"-\" for stdout")
 	rootCmd.PersistentFlags().StringVarP(&rootOpts.verbosity, "verbosity", "v", logrus.InfoLevel.String(), "Log level (debug, info, warn, error, fatal, panic)")
 	rootCmd.PersistentFlags().StringArrayVar(&rootOpts.logopts, "logopt", []string{}, "Log options")
+	rootCmd.PersistentFlags().DurationVar(&rootOpts.shutdownTimeout, "shutdown-timeout", 10*time.Second, "Time for running scripts to finish after a signal before they are canceled")
 	versionCmd.Flags().StringVarP(&rootOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
 
 	rootCmd.MarkPersistentFlagFilename("config")
 	serverCmd.MarkPersistentFlagRequired("config")
 	onceCmd.MarkPersistentFlagRequired("config")
+	validateCmd.MarkPersistentFlagRequired("config")
 
 	rootCmd.AddCommand(serverCmd)
 	rootCmd.AddCommand(onceCmd)
+	rootCmd.AddCommand(validateCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	rootCmd.PersistentPreRunE = rootPreRun
@@ -114,6 +131,19 @@ func runVersion(cmd *cobra.Command, args []string) error {
 	return template.Writer(os.Stdout, rootOpts.format, info)
 }
 
+// runValidate checks the config for errors before it is used by the other commands
+func runValidate(cmd *cobra.Command, args []string) error {
+	err := readConf()
+	if err != nil {
+		return err
+	}
+	err = ConfigValidate(conf)
+	if err != nil {
+		return err
+	}
+	return ConfigWrite(conf, cmd.OutOrStdout())
+}
+
 // runOnce processes the file in one pass, ignoring cron
 func runOnce(cmd *cobra.Command, args []string) error {
 	err := loadConf()
@@ -125,6 +155,12 @@ func runOnce(cmd *cobra.Command, args []string) error {
 	var mainErr error
 	for _, s := range conf.Scripts {
 		s := s
+		if shuttingDown() {
+			if mainErr == nil {
+				mainErr = ErrCanceled
+			}
+			break
+		}
 		if conf.Defaults.Parallel > 0 {
 			wg.Add(1)
 			go func() {
@@ -192,9 +228,9 @@ func runServer(cmd *cobra.Command, args []string) error {
 	}
 	c.Start()
 	// wait on interrupt signal
-	done := ctx.Done()
-	if done != nil {
-		<-done
+	select {
+	case <-ctx.Done():
+	case <-shutdown:
 	}
 	log.WithFields(logrus.Fields{}).Info("Stopping server")
 	// clean shutdown
@@ -204,7 +240,8 @@ func runServer(cmd *cobra.Command, args []string) error {
 	return mainErr
 }
 
-func loadConf() error {
+// readConf parses the config file without any other setup
+func readConf() error {
 	var err error
 	if rootOpts.confFile == "-" {
 		conf, err = ConfigLoadReader(os.Stdin)
@@ -224,6 +261,14 @@ func loadConf() error {
 	} else {
 		return ErrMissingInput
 	}
+	return nil
+}
+
+func loadConf() error {
+	err := readConf()
+	if err != nil {
+		return err
+	}
 	// use a throttle to control parallelism
 	concurrent := conf.Defaults.Parallel
 	if concurrent <= 0 {
@@ -240,6 +285,9 @@ func loadConf() error {
 	if conf.Defaults.BlobLimit != 0 {
 		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithBlobLimit(conf.Defaults.BlobLimit)))
 	}
+	if conf.Defaults.CatalogCache != "" {
+		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithCatalogCache(conf.Defaults.CatalogCache)))
+	}
 	if !conf.Defaults.SkipDockerConf {
 		rcOpts = append(rcOpts, regclient.WithDockerCreds(), regclient.WithDockerCerts())
 	}
@@ -270,7 +318,11 @@ func loadConf() error {
 }
 
 // process a sync step
-func (s ConfigScript) process(ctx context.Context) error {
+func (s ConfigScript) process(ctx context.Context) (errRet error) {
+	// do not start new scripts after a signal
+	if shuttingDown() {
+		return ErrCanceled
+	}
 	log.WithFields(logrus.Fields{
 		"script": s.Name,
 	}).Debug("Starting script")
@@ -286,9 +338,42 @@ func (s ConfigScript) process(ctx context.Context) error {
 		sandbox.WithLog(log),
 		sandbox.WithThrottle(throttleC),
 	}
+	if conf.Defaults.ArtifactLimit > 0 {
+		sbOpts = append(sbOpts, sandbox.WithArtifactLimit(conf.Defaults.ArtifactLimit))
+	}
 	if rootOpts.dryRun {
 		sbOpts = append(sbOpts, sandbox.WithDryRun())
 	}
+	if rootOpts.report != "" {
+		rr := RunReport{
+			Script:  s.Name,
+			Start:   time.Now().UTC(),
+			DryRun:  rootOpts.dryRun,
+			Actions: []sandbox.Action{},
+		}
+		var mu sync.Mutex
+		sbOpts = append(sbOpts, sandbox.WithActionHook(func(a sandbox.Action) {
+			mu.Lock()
+			rr.Actions = append(rr.Actions, a)
+			mu.Unlock()
+		}))
+		defer func() {
+			mu.Lock()
+			defer mu.Unlock()
+			rr.End = time.Now().UTC()
+			if errRet != nil {
+				rr.Error = errRet.Error()
+			}
+			err := reportWrite(rootOpts.report, rr)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"script": s.Name,
+					"report": rootOpts.report,
+					"error":  err,
+				}).Warn("Failed to write report")
+			}
+		}()
+	}
 	sb := sandbox.New(s.Name, sbOpts...)
 	defer sb.Close()
 	err := sb.RunScript(s.Script)
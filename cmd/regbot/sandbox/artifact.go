@@ -0,0 +1,259 @@
+package sandbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/cmd/regbot/internal/go2lua"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	// artifactMTFile is the media type of files pushed without a media type
+	artifactMTFile = "application/octet-stream"
+	// defaultArtifactLimit is the default maximum size of the files loaded by artifact.get
+	defaultArtifactLimit = 1024 * 1024 * 16
+)
+
+func setupArtifact(s *Sandbox) {
+	s.setupMod(
+		luaArtifactName,
+		map[string]lua.LGFunction{
+			"get": s.artifactGet,
+			"put": s.artifactPut,
+		},
+		map[string]map[string]lua.LGFunction{
+			"__index": {},
+		},
+	)
+}
+
+// sbArtifact is the artifact content exchanged with Lua
+type sbArtifact struct {
+	Digest       string            `json:"digest"`
+	ArtifactType string            `json:"artifactType"`
+	Annotations  map[string]string `json:"annotations"`
+	Subject      string            `json:"subject"`
+	Files        []sbArtifactFile  `json:"files"`
+}
+
+type sbArtifactFile struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Title       string            `json:"title"`
+	Annotations map[string]string `json:"annotations"`
+	Content     string            `json:"content"`
+}
+
+func (s *Sandbox) artifactGet(ls *lua.LState) int {
+	err := s.ctx.Err()
+	if err != nil {
+		ls.RaiseError("Context error: %v", err)
+	}
+	r := s.checkReference(ls, 1)
+	if s.throttleC != nil {
+		s.throttleC.Acquire(s.ctx)
+		defer s.throttleC.Release(s.ctx)
+	}
+	s.log.WithFields(logrus.Fields{
+		"script": s.name,
+		"image":  r.r.CommonName(),
+	}).Debug("Retrieve artifact")
+	m, err := s.rc.ManifestGet(s.ctx, r.r)
+	if err != nil {
+		ls.RaiseError("Failed retrieving \"%s\" manifest: %v", r.r.CommonName(), err)
+	}
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		ls.RaiseError("Artifact methods are not available for manifest \"%s\"", r.r.CommonName())
+	}
+	// images are rejected, their layers are not files that can be loaded into memory
+	switch manifest.GetArtifactType(m) {
+	case "", types.MediaTypeOCI1ImageConfig, types.MediaTypeDocker2ImageConfig:
+		ls.RaiseError("Manifest \"%s\" is not an artifact", r.r.CommonName())
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		ls.RaiseError("Failed retrieving \"%s\" layers: %v", r.r.CommonName(), err)
+	}
+	// check the size of all files before pulling any content
+	var total int64
+	for _, l := range layers {
+		if l.MediaType != types.MediaTypeOCI1Empty {
+			total += l.Size
+		}
+		if l.Size < 0 || total > s.artLimit {
+			ls.RaiseError("Artifact \"%s\" exceeds the size limit of %d bytes", r.r.CommonName(), s.artLimit)
+		}
+	}
+	art := sbArtifact{
+		Digest:       m.GetDescriptor().Digest.String(),
+		ArtifactType: manifest.GetArtifactType(m),
+		Files:        []sbArtifactFile{},
+	}
+	if ma, ok := m.(manifest.Annotator); ok {
+		art.Annotations, _ = ma.GetAnnotations()
+	}
+	if ms, ok := m.(manifest.Subjecter); ok {
+		if subject, err := ms.GetSubject(); err == nil && subject != nil {
+			rs := r.r
+			rs.Tag = ""
+			rs.Digest = subject.Digest.String()
+			art.Subject = rs.CommonName()
+		}
+	}
+	for _, l := range layers {
+		// the empty layer of an artifact without files is skipped
+		if l.MediaType == types.MediaTypeOCI1Empty {
+			continue
+		}
+		b, err := s.rc.BlobGet(s.ctx, r.r, l)
+		if err != nil {
+			ls.RaiseError("Failed retrieving \"%s\" blob \"%s\": %v", r.r.CommonName(), l.Digest.String(), err)
+		}
+		// never read more than the descriptor size that was checked against the limit
+		content, err := io.ReadAll(io.LimitReader(b, l.Size+1))
+		b.Close()
+		if err == nil && int64(len(content)) > l.Size {
+			err = fmt.Errorf("blob exceeds the descriptor size of %d bytes%.0w", l.Size, types.ErrSizeLimitExceeded)
+		}
+		if err != nil {
+			ls.RaiseError("Failed reading \"%s\" blob \"%s\": %v", r.r.CommonName(), l.Digest.String(), err)
+		}
+		art.Files = append(art.Files, sbArtifactFile{
+			MediaType:   l.MediaType,
+			Digest:      l.Digest.String(),
+			Size:        l.Size,
+			Title:       l.Annotations[types.AnnotationTitle],
+			Annotations: l.Annotations,
+			Content:     string(content),
+		})
+	}
+	ls.Push(go2lua.Export(ls, art))
+	return 1
+}
+
+func (s *Sandbox) artifactPut(ls *lua.LState) int {
+	err := s.ctx.Err()
+	if err != nil {
+		ls.RaiseError("Context error: %v", err)
+	}
+	r := s.checkReference(ls, 1)
+	art := sbArtifact{}
+	err = go2lua.Import(ls, ls.CheckTable(2), &art, nil)
+	if err != nil {
+		ls.ArgError(2, fmt.Sprintf("Failed to parse artifact: %v", err))
+	}
+	if art.ArtifactType == "" {
+		ls.ArgError(2, "artifactType is required")
+	}
+	tgt := r.r
+	om := v1.Manifest{
+		Versioned:    v1.ManifestSchemaVersion,
+		MediaType:    types.MediaTypeOCI1Manifest,
+		ArtifactType: art.ArtifactType,
+		Config:       types.EmptyDescriptor(),
+		Layers:       []types.Descriptor{},
+		Annotations:  art.Annotations,
+	}
+	// an artifact with a subject is pushed by digest to the subject repository
+	if art.Subject != "" {
+		rs, err := ref.New(art.Subject)
+		if err != nil {
+			ls.ArgError(2, "subject parsing failed: "+err.Error())
+		}
+		if !ref.EqualRepository(rs, tgt) {
+			ls.ArgError(2, "subject must be in the same repository as the artifact")
+		}
+		mh, err := s.rc.ManifestHead(s.ctx, rs, regclient.WithManifestRequireDigest())
+		if err != nil {
+			ls.RaiseError("Failed retrieving subject \"%s\": %v", rs.CommonName(), err)
+		}
+		d := mh.GetDescriptor()
+		om.Subject = &types.Descriptor{MediaType: d.MediaType, Digest: d.Digest, Size: d.Size}
+	}
+	for _, f := range art.Files {
+		mt := f.MediaType
+		if mt == "" {
+			mt = artifactMTFile
+		}
+		annotations := map[string]string{}
+		for k, v := range f.Annotations {
+			annotations[k] = v
+		}
+		if f.Title != "" {
+			annotations[types.AnnotationTitle] = f.Title
+		}
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		om.Layers = append(om.Layers, types.Descriptor{
+			MediaType:   mt,
+			Digest:      digest.FromString(f.Content),
+			Size:        int64(len(f.Content)),
+			Annotations: annotations,
+		})
+	}
+	if len(om.Layers) == 0 {
+		om.Layers = append(om.Layers, types.EmptyDescriptor())
+	}
+	m, err := manifest.New(manifest.WithOrig(om))
+	if err != nil {
+		ls.RaiseError("Failed to create artifact: %v", err)
+	}
+	if om.Subject != nil {
+		tgt.Tag = ""
+		tgt.Digest = m.GetDescriptor().Digest.String()
+	}
+	s.log.WithFields(logrus.Fields{
+		"script":       s.name,
+		"image":        tgt.CommonName(),
+		"artifactType": art.ArtifactType,
+		"dry-run":      s.dryRun,
+	}).Info("Put artifact")
+	if s.dryRun {
+		s.action(Action{Action: ActionArtifactPut, Target: tgt.CommonName()}, nil)
+		ls.Push(lua.LString(m.GetDescriptor().Digest.String()))
+		return 1
+	}
+	err = s.artifactPush(tgt, om, art.Files, m)
+	s.action(Action{Action: ActionArtifactPut, Target: tgt.CommonName()}, err)
+	if err != nil {
+		ls.RaiseError("Failed to put artifact \"%s\": %v", tgt.CommonName(), err)
+	}
+	err = s.rc.Close(s.ctx, tgt)
+	if err != nil {
+		ls.RaiseError("Failed closing reference \"%s\": %v", tgt.CommonName(), err)
+	}
+	ls.Push(lua.LString(m.GetDescriptor().Digest.String()))
+	return 1
+}
+
+// artifactPush pushes the config, files, and manifest of an artifact
+func (s *Sandbox) artifactPush(r ref.Ref, om v1.Manifest, files []sbArtifactFile, m manifest.Manifest) error {
+	if s.throttleC != nil {
+		s.throttleC.Acquire(s.ctx)
+		defer s.throttleC.Release(s.ctx)
+	}
+	_, err := s.rc.BlobPut(s.ctx, r, om.Config, bytes.NewReader(types.EmptyData))
+	if err != nil {
+		return err
+	}
+	for i, f := range files {
+		_, err = s.rc.BlobPut(s.ctx, r, om.Layers[i], strings.NewReader(f.Content))
+		if err != nil {
+			return err
+		}
+	}
+	return s.rc.ManifestPut(s.ctx, r, m)
+}
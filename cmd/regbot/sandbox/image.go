@@ -212,9 +212,11 @@ func (s *Sandbox) imageCopy(ls *lua.LState) int {
 		"dry-run":         s.dryRun,
 	}).Info("Copy image")
 	if s.dryRun {
+		s.action(Action{Action: ActionImageCopy, Source: src.r.CommonName(), Target: tgt.r.CommonName()}, nil)
 		return 0
 	}
 	err = s.rc.ImageCopy(s.ctx, src.r, tgt.r, opts...)
+	s.action(Action{Action: ActionImageCopy, Source: src.r.CommonName(), Target: tgt.r.CommonName()}, err)
 	if err != nil {
 		ls.RaiseError("Failed copying \"%s\" to \"%s\": %v", src.r.CommonName(), tgt.r.CommonName(), err)
 	}
@@ -29,6 +29,7 @@ func setupManifest(s *Sandbox) {
 		},
 		map[string]map[string]lua.LGFunction{
 			"__index": {
+				"annotations":   s.manifestAnnotations,
 				"config":        s.configGet,
 				"delete":        s.manifestDelete,
 				"export":        s.manifestExport,
@@ -95,6 +96,33 @@ func (s *Sandbox) checkManifest(ls *lua.LState, i int, list bool, head bool) *sb
 	return m
 }
 
+// manifestAnnotations returns the annotations of a manifest or index
+func (s *Sandbox) manifestAnnotations(ls *lua.LState) int {
+	m := s.checkManifest(ls, 1, true, false)
+	if !m.m.IsSet() {
+		// pull the full manifest after a head request
+		r := m.r
+		r.Digest = m.m.GetDescriptor().Digest.String()
+		rcM, err := s.rc.ManifestGet(s.ctx, r)
+		if err != nil {
+			ls.RaiseError("Failed retrieving \"%s\" manifest: %v", m.r.CommonName(), err)
+		}
+		m = &sbManifest{m: rcM, r: m.r}
+	}
+	lAnnot := ls.NewTable()
+	if ma, ok := m.m.(manifest.Annotator); ok {
+		annotations, err := ma.GetAnnotations()
+		if err != nil {
+			ls.RaiseError("Failed retrieving \"%s\" annotations: %v", m.r.CommonName(), err)
+		}
+		for k, v := range annotations {
+			lAnnot.RawSetString(k, lua.LString(v))
+		}
+	}
+	ls.Push(lAnnot)
+	return 1
+}
+
 func (s *Sandbox) manifestDelete(ls *lua.LState) int {
 	err := s.ctx.Err()
 	if err != nil {
@@ -112,9 +140,11 @@ func (s *Sandbox) manifestDelete(ls *lua.LState) int {
 		"dry-run": s.dryRun,
 	}).Info("Delete manifest")
 	if s.dryRun {
+		s.action(Action{Action: ActionManifestDelete, Target: r.CommonName()}, nil)
 		return 0
 	}
 	err = s.rc.ManifestDelete(s.ctx, r)
+	s.action(Action{Action: ActionManifestDelete, Target: r.CommonName()}, err)
 	if err != nil {
 		ls.RaiseError("Failed deleting \"%s\": %v", r.CommonName(), err)
 	}
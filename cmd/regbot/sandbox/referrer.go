@@ -0,0 +1,69 @@
+package sandbox
+
+import (
+	"fmt"
+
+	"github.com/regclient/regclient/cmd/regbot/internal/go2lua"
+	"github.com/regclient/regclient/scheme"
+	"github.com/sirupsen/logrus"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func setupReferrer(s *Sandbox) {
+	s.setupMod(
+		luaReferrerName,
+		map[string]lua.LGFunction{
+			"ls": s.referrerLs,
+		},
+		map[string]map[string]lua.LGFunction{
+			"__index": {},
+		},
+	)
+}
+
+type referrerLsOpts struct {
+	ArtifactType string            `json:"artifactType"`
+	Annotations  map[string]string `json:"annotations"`
+	Platform     string            `json:"platform"`
+}
+
+func (s *Sandbox) referrerLs(ls *lua.LState) int {
+	err := s.ctx.Err()
+	if err != nil {
+		ls.RaiseError("Context error: %v", err)
+	}
+	r := s.checkReference(ls, 1)
+	opts := referrerLsOpts{}
+	optsArgs := []scheme.ReferrerOpts{}
+	if ls.GetTop() > 1 {
+		tab := ls.CheckTable(2)
+		err := go2lua.Import(ls, tab, &opts, nil)
+		if err != nil {
+			ls.ArgError(2, fmt.Sprintf("Failed to parse options: %v", err))
+		}
+		if opts.ArtifactType != "" {
+			optsArgs = append(optsArgs, scheme.WithReferrerAT(opts.ArtifactType))
+		}
+		if len(opts.Annotations) > 0 {
+			optsArgs = append(optsArgs, scheme.WithReferrerAnnotations(opts.Annotations))
+		}
+		if opts.Platform != "" {
+			optsArgs = append(optsArgs, scheme.WithReferrerPlatform(opts.Platform))
+		}
+	}
+	s.log.WithFields(logrus.Fields{
+		"script": s.name,
+		"image":  r.r.CommonName(),
+		"opts":   opts,
+	}).Debug("Listing referrers")
+	rl, err := s.rc.ReferrerList(s.ctx, r.r, optsArgs...)
+	if err != nil {
+		ls.RaiseError("Failed retrieving referrers for \"%s\": %v", r.r.CommonName(), err)
+	}
+	lDescs := ls.NewTable()
+	for _, d := range rl.Descriptors {
+		lDescs.Append(go2lua.Export(ls, d))
+	}
+	ls.Push(lDescs)
+	return 1
+}
@@ -22,8 +22,9 @@ func setupRepo(s *Sandbox) {
 }
 
 type repoLsOpts struct {
-	Limit int    `json:"limit"`
-	Last  string `json:"last"`
+	Limit  int    `json:"limit"`
+	Last   string `json:"last"`
+	Prefix string `json:"prefix"`
 }
 
 func (s *Sandbox) repoLs(ls *lua.LState) int {
@@ -47,6 +48,9 @@ func (s *Sandbox) repoLs(ls *lua.LState) int {
 		if opts.Last != "" {
 			optsArgs = append(optsArgs, scheme.WithRepoLast(opts.Last))
 		}
+		if opts.Prefix != "" {
+			optsArgs = append(optsArgs, scheme.WithRepoPrefix(opts.Prefix))
+		}
 	}
 	s.log.WithFields(logrus.Fields{
 		"script": s.name,
@@ -3,6 +3,7 @@ package sandbox
 
 import (
 	"context"
+	"time"
 
 	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/cmd/regbot/internal/go2lua"
@@ -19,6 +20,8 @@ const (
 	luaImageName       = "image"
 	luaImageConfigName = "imageconfig"
 	luaBlobName        = "blob"
+	luaReferrerName    = "referrer"
+	luaArtifactName    = "artifact"
 )
 
 // Sandbox defines a lua sandbox
@@ -30,8 +33,31 @@ type Sandbox struct {
 	rc        *regclient.RegClient
 	throttleC *throttle.Throttle
 	dryRun    bool
+	actionFn  func(Action)
+	artLimit  int64
 }
 
+// Action describes an external change made by a script, or skipped with a dry run
+type Action struct {
+	Action string    `json:"action"`
+	Source string    `json:"source,omitempty"`
+	Target string    `json:"target"`
+	DryRun bool      `json:"dryRun"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+const (
+	// ActionArtifactPut is reported when an artifact is pushed
+	ActionArtifactPut = "artifactPut"
+	// ActionImageCopy is reported when an image is copied
+	ActionImageCopy = "imageCopy"
+	// ActionManifestDelete is reported when a manifest is deleted
+	ActionManifestDelete = "manifestDelete"
+	// ActionTagDelete is reported when a tag is deleted
+	ActionTagDelete = "tagDelete"
+)
+
 // LuaMod defines a mod to add to Lua's sandbox
 type LuaMod func(*Sandbox)
 
@@ -42,6 +68,8 @@ var luaMods = []LuaMod{
 	setupImage,
 	setupManifest,
 	setupBlob,
+	setupReferrer,
+	setupArtifact,
 }
 
 // Opt function to process options on sandbox
@@ -70,6 +98,9 @@ func New(name string, opts ...Opt) *Sandbox {
 	if s.rc == nil {
 		s.rc = regclient.New()
 	}
+	if s.artLimit <= 0 {
+		s.artLimit = defaultArtifactLimit
+	}
 
 	// setup modules for the sandbox
 	for _, mod := range luaMods {
@@ -83,6 +114,13 @@ func New(name string, opts ...Opt) *Sandbox {
 	return s
 }
 
+// WithArtifactLimit sets the maximum size of the files loaded by artifact.get
+func WithArtifactLimit(limit int64) Opt {
+	return func(s *Sandbox) {
+		s.artLimit = limit
+	}
+}
+
 // WithContext defines the context for a sandbox
 func WithContext(ctx context.Context) Opt {
 	return func(s *Sandbox) {
@@ -97,6 +135,13 @@ func WithDryRun() Opt {
 	}
 }
 
+// WithActionHook is called for each external change made by a script, including changes skipped with a dry run
+func WithActionHook(fn func(Action)) Opt {
+	return func(s *Sandbox) {
+		s.actionFn = fn
+	}
+}
+
 // WithLog specifies a logrus logger
 func WithLog(log *logrus.Logger) Opt {
 	return func(s *Sandbox) {
@@ -148,6 +193,19 @@ func (s *Sandbox) Close() {
 	s.ls.Close()
 }
 
+// action reports an external change to the action hook
+func (s *Sandbox) action(a Action, err error) {
+	if s.actionFn == nil {
+		return
+	}
+	a.DryRun = s.dryRun
+	a.Time = time.Now().UTC()
+	if err != nil {
+		a.Error = err.Error()
+	}
+	s.actionFn(a)
+}
+
 func (s *Sandbox) sandboxLog(ls *lua.LState) int {
 	msg := ls.CheckString(1)
 	s.log.WithFields(logrus.Fields{
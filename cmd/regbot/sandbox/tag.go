@@ -32,9 +32,11 @@ func (s *Sandbox) tagDelete(ls *lua.LState) int {
 		"dry-run": s.dryRun,
 	}).Info("Delete tag")
 	if s.dryRun {
+		s.action(Action{Action: ActionTagDelete, Target: r.r.CommonName()}, nil)
 		return 0
 	}
 	err = s.rc.TagDelete(s.ctx, r.r)
+	s.action(Action{Action: ActionTagDelete, Target: r.r.CommonName()}, err)
 	if err != nil {
 		ls.RaiseError("Failed deleting \"%s\": %v", r.r.CommonName(), err)
 	}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"bytes"
-	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -184,7 +184,10 @@ func init() {
 
 func runArtifactGet(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 
 	// validate inputs
 	if artifactOpts.refers != "" {
@@ -432,7 +435,10 @@ func runArtifactList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("--latest cannot be used with --sort-annotation")
 	}
 
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, rSubject)
 
 	referrerOpts := []scheme.ReferrerOpts{}
@@ -609,7 +615,10 @@ func runArtifactPut(cmd *cobra.Command, args []string) error {
 	}
 
 	// setup regclient
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	var subjectDesc *types.Descriptor
@@ -829,12 +838,15 @@ func runArtifactTree(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
-	referrerOpts := []scheme.ReferrerOpts{}
+	treeOpts := []regclient.ManifestTreeOpts{}
 	if artifactOpts.filterAT != "" {
-		referrerOpts = append(referrerOpts, scheme.WithReferrerAT(artifactOpts.filterAT))
+		treeOpts = append(treeOpts, regclient.ManifestTreeWithReferrerOpts(scheme.WithReferrerAT(artifactOpts.filterAT)))
 	}
 	if artifactOpts.filterAnnot != nil {
 		af := map[string]string{}
@@ -846,24 +858,19 @@ func runArtifactTree(cmd *cobra.Command, args []string) error {
 				af[kv] = ""
 			}
 		}
-		referrerOpts = append(referrerOpts, scheme.WithReferrerAnnotations(af))
+		treeOpts = append(treeOpts, regclient.ManifestTreeWithReferrerOpts(scheme.WithReferrerAnnotations(af)))
 	}
-
-	// include digest tags if requested
-	tags := []string{}
 	if artifactOpts.digestTags {
-		tl, err := rc.TagList(ctx, r)
-		if err != nil {
-			return fmt.Errorf("failed to list tags: %w", err)
-		}
-		tags = tl.Tags
+		treeOpts = append(treeOpts, regclient.ManifestTreeWithDigestTags())
 	}
 
-	seen := []string{}
-	tr, err := treeAddResult(ctx, rc, r, seen, referrerOpts, tags)
+	tn, err := rc.ManifestTree(ctx, r, treeOpts...)
 	var twErr error
-	if tr != nil {
-		twErr = template.Writer(cmd.OutOrStdout(), artifactOpts.formatTree, tr)
+	if tn != nil {
+		twErr = template.Writer(cmd.OutOrStdout(), artifactOpts.formatTree, tn)
+	}
+	if errors.Is(err, types.ErrLoopDetected) {
+		err = fmt.Errorf("%w: %w", ErrLoopEncountered, err)
 	}
 	if err != nil {
 		return err
@@ -871,111 +878,6 @@ func runArtifactTree(cmd *cobra.Command, args []string) error {
 	return twErr
 }
 
-func treeAddResult(ctx context.Context, rc *regclient.RegClient, r ref.Ref, seen []string, rOpts []scheme.ReferrerOpts, tags []string) (*treeResult, error) {
-	tr := treeResult{
-		Ref: r,
-	}
-
-	// get manifest
-	m, err := rc.ManifestGet(ctx, r)
-	if err != nil {
-		return nil, err
-	}
-	tr.Manifest = m
-	if r.Digest == "" {
-		r.Digest = m.GetDescriptor().Digest.String()
-	}
-
-	// track already seen manifests
-	dig := m.GetDescriptor().Digest.String()
-	if sliceHasStr(seen, dig) {
-		return &tr, fmt.Errorf("%w, already processed %s", ErrLoopEncountered, dig)
-	}
-	seen = append(seen, dig)
-
-	// get child nodes
-	if m.IsList() {
-		tr.Child = []*treeResult{}
-		mi, ok := m.(manifest.Indexer)
-		if !ok {
-			return &tr, fmt.Errorf("failed to convert a manifest list to indexer for %s", r.CommonName())
-		}
-		dl, err := mi.GetManifestList()
-		if err != nil {
-			return &tr, fmt.Errorf("failed to get platforms for %s: %w", r.CommonName(), err)
-		}
-		for _, d := range dl {
-			rChild := r
-			rChild.Tag = ""
-			rChild.Digest = d.Digest.String()
-			tChild, err := treeAddResult(ctx, rc, rChild, seen, rOpts, tags)
-			if tChild != nil {
-				tChild.ArtifactType = d.ArtifactType
-				if d.Platform != nil {
-					pCopy := *d.Platform
-					tChild.Platform = &pCopy
-				}
-				tr.Child = append(tr.Child, tChild)
-			}
-			if err != nil {
-				return &tr, err
-			}
-		}
-	}
-
-	// get referrers
-	rl, err := rc.ReferrerList(ctx, r, rOpts...)
-	if err != nil {
-		return &tr, fmt.Errorf("failed to check referrers for %s: %w", r.CommonName(), err)
-	}
-	if len(rl.Descriptors) > 0 {
-		tr.Referrer = []*treeResult{}
-		for _, d := range rl.Descriptors {
-			rReferrer := r
-			rReferrer.Tag = ""
-			rReferrer.Digest = d.Digest.String()
-			tReferrer, err := treeAddResult(ctx, rc, rReferrer, seen, rOpts, tags)
-			if tReferrer != nil {
-				tReferrer.ArtifactType = d.ArtifactType
-				if d.Platform != nil {
-					pCopy := *d.Platform
-					tReferrer.Platform = &pCopy
-				}
-				tr.Referrer = append(tr.Referrer, tReferrer)
-			}
-			if err != nil {
-				return &tr, err
-			}
-		}
-	}
-
-	// include digest tags if requested
-	if artifactOpts.digestTags {
-		prefix, err := referrer.FallbackTag(r)
-		if err != nil {
-			return &tr, fmt.Errorf("failed to compute fallback tag: %v", err)
-		}
-		for _, t := range tags {
-			if strings.HasPrefix(t, prefix.Tag) && !sliceHasStr(rl.Tags, t) {
-				rTag := r
-				rTag.Tag = t
-				rTag.Digest = ""
-				tReferrer, err := treeAddResult(ctx, rc, rTag, seen, rOpts, tags)
-				if tReferrer != nil {
-					tReferrer.Ref.Tag = t
-					tReferrer.Ref.Digest = ""
-					tr.Referrer = append(tr.Referrer, tReferrer)
-				}
-				if err != nil {
-					return &tr, err
-				}
-			}
-		}
-	}
-
-	return &tr, nil
-}
-
 func sliceHasStr(list []string, search string) bool {
 	for _, el := range list {
 		if el == search {
@@ -984,91 +886,3 @@ func sliceHasStr(list []string, search string) bool {
 	}
 	return false
 }
-
-type treeResult struct {
-	Ref          ref.Ref            `json:"reference"`
-	Manifest     manifest.Manifest  `json:"manifest"`
-	Platform     *platform.Platform `json:"platform,omitempty"`
-	ArtifactType string             `json:"artifactType,omitempty"`
-	Child        []*treeResult      `json:"child,omitempty"`
-	Referrer     []*treeResult      `json:"referrer,omitempty"`
-}
-
-func (tr *treeResult) MarshalPretty() ([]byte, error) {
-	mp, err := tr.marshalPretty("")
-	if err != nil {
-		return nil, err
-	}
-	return []byte(fmt.Sprintf("Ref: %s\nDigest: %s", tr.Ref.CommonName(), mp)), nil
-}
-
-func (tr *treeResult) marshalPretty(indent string) ([]byte, error) {
-	result := bytes.NewBufferString("")
-	_, err := result.WriteString(tr.Manifest.GetDescriptor().Digest.String())
-	if err != nil {
-		return nil, err
-	}
-	if tr.Platform != nil {
-		_, err = result.WriteString(" [" + tr.Platform.String() + "]")
-		if err != nil {
-			return nil, err
-		}
-	}
-	if tr.ArtifactType != "" {
-		_, err = result.WriteString(": " + tr.ArtifactType)
-		if err != nil {
-			return nil, err
-		}
-	}
-	if tr.ArtifactType == "" && strings.HasPrefix(tr.Ref.Tag, "sha256-") {
-		_, err = result.WriteString(": " + tr.Ref.Tag)
-		if err != nil {
-			return nil, err
-		}
-	}
-	_, err = result.WriteString("\n")
-	if err != nil {
-		return nil, err
-	}
-	if len(tr.Child) > 0 {
-		_, err = result.WriteString(indent + "Children:\n")
-		if err != nil {
-			return nil, err
-		}
-		for _, trChild := range tr.Child {
-			_, err = result.WriteString(indent + "  - ")
-			if err != nil {
-				return nil, err
-			}
-			childBytes, err := trChild.marshalPretty(indent + "    ")
-			if err != nil {
-				return nil, err
-			}
-			_, err = result.Write(childBytes)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-	if len(tr.Referrer) > 0 {
-		_, err = result.WriteString(indent + "Referrers:\n")
-		if err != nil {
-			return nil, err
-		}
-		for _, trReferrer := range tr.Referrer {
-			_, err = result.WriteString(indent + "  - ")
-			if err != nil {
-				return nil, err
-			}
-			referrerBytes, err := trReferrer.marshalPretty(indent + "    ")
-			if err != nil {
-				return nil, err
-			}
-			_, err = result.Write(referrerBytes)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-	return result.Bytes(), nil
-}
@@ -15,9 +15,11 @@ import (
 	_ "crypto/sha512"
 
 	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/internal/diff"
 	"github.com/regclient/regclient/pkg/template"
 	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/blob"
 	"github.com/regclient/regclient/types/ref"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -28,6 +30,18 @@ var blobCmd = &cobra.Command{
 	Aliases: []string{"layer"},
 	Short:   "manage image blobs/layers",
 }
+var blobDeleteCmd = &cobra.Command{
+	Use:     "delete <repository> <digest>",
+	Aliases: []string{"del", "rm", "remove"},
+	Short:   "delete a blob",
+	Long: `Delete a blob from a repository. This should only be used to repair a
+damaged repository, registries typically remove unused blobs with garbage
+collection. Use "--upload" to cancel an in-progress upload session, where the
+argument is the upload location or session UUID instead of a digest.`,
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{}, // do not auto complete repository or digest
+	RunE:      runBlobDelete,
+}
 var blobDiffConfigCmd = &cobra.Command{
 	Use:       "diff-config <repository> <digest> <repository> <digest>",
 	Short:     "diff two image configs",
@@ -64,6 +78,15 @@ var blobGetFileCmd = &cobra.Command{
 	ValidArgs: []string{}, // do not auto complete repository, digest, or filenames
 	RunE:      runBlobGetFile,
 }
+var blobListFilesCmd = &cobra.Command{
+	Use:       "list-files <repository> <digest>",
+	Aliases:   []string{"ls"},
+	Short:     "list files in a layer",
+	Long:      `This lists each entry in a tar layer, including whiteout files.`,
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{}, // do not auto complete repository or digest
+	RunE:      runBlobListFiles,
+}
 var blobHeadCmd = &cobra.Command{
 	Use:       "head <repository> <digest>",
 	Aliases:   []string{"digest"},
@@ -78,7 +101,9 @@ var blobPutCmd = &cobra.Command{
 	Aliases: []string{"push"},
 	Short:   "upload a blob/layer",
 	Long: `Upload a blob to a repository. Stdin must be the blob contents. The output
-is the digest of the blob.`,
+is the digest of the blob. The length of stdin does not need to be known, content
+is sent with a chunked upload when the digest or size is not available, e.g.
+"tar -cz dir | regctl blob put registry.example.org/repo".`,
 	Args:      cobra.ExactArgs(1),
 	ValidArgs: []string{}, // do not auto complete repository
 	RunE:      runBlobPut,
@@ -101,13 +126,19 @@ var blobOpts struct {
 	diffIgnoreTime bool
 	formatGet      string
 	formatFile     string
+	ignoreMissing  bool
 	formatHead     string
+	formatList     string
 	formatPut      string
 	mt             string
 	digest         string
+	upload         bool
 }
 
 func init() {
+	blobDeleteCmd.Flags().BoolVarP(&blobOpts.ignoreMissing, "ignore-missing", "", false, "Ignore errors if the blob is already deleted")
+	blobDeleteCmd.Flags().BoolVarP(&blobOpts.upload, "upload", "", false, "Cancel an upload session")
+
 	blobDiffConfigCmd.Flags().IntVarP(&blobOpts.diffCtx, "context", "", 3, "Lines of context")
 	blobDiffConfigCmd.Flags().BoolVarP(&blobOpts.diffFullCtx, "context-full", "", false, "Show all lines of context")
 
@@ -127,6 +158,9 @@ func init() {
 
 	blobGetFileCmd.Flags().StringVarP(&blobOpts.formatFile, "format", "", "", "Format output with go template syntax")
 
+	blobListFilesCmd.Flags().StringVarP(&blobOpts.formatList, "format", "", "{{range .}}{{println .Name}}{{end}}", "Format output with go template syntax")
+	blobListFilesCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
 	blobHeadCmd.Flags().StringVarP(&blobOpts.formatHead, "format", "", "", "Format output with go template syntax")
 	blobHeadCmd.RegisterFlagCompletionFunc("format", completeArgNone)
 
@@ -141,16 +175,54 @@ func init() {
 	blobPutCmd.RegisterFlagCompletionFunc("digest", completeArgNone)
 	blobPutCmd.Flags().MarkHidden("content-type")
 
+	blobCmd.AddCommand(blobDeleteCmd)
 	blobCmd.AddCommand(blobDiffConfigCmd)
 	blobCmd.AddCommand(blobDiffLayerCmd)
 	blobCmd.AddCommand(blobGetCmd)
 	blobCmd.AddCommand(blobGetFileCmd)
 	blobCmd.AddCommand(blobHeadCmd)
+	blobCmd.AddCommand(blobListFilesCmd)
 	blobCmd.AddCommand(blobPutCmd)
 	blobCmd.AddCommand(blobCopyCmd)
 	rootCmd.AddCommand(blobCmd)
 }
 
+func runBlobDelete(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, r)
+
+	if blobOpts.upload {
+		log.WithFields(logrus.Fields{
+			"host":       r.Registry,
+			"repository": r.Repository,
+			"location":   args[1],
+		}).Debug("Blob upload cancel")
+		return rc.BlobUploadCancel(ctx, r, args[1])
+	}
+	d, err := digest.Parse(args[1])
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"host":       r.Registry,
+		"repository": r.Repository,
+		"digest":     args[1],
+	}).Debug("Blob delete")
+	bOpts := []regclient.BlobOpts{}
+	if blobOpts.ignoreMissing {
+		bOpts = append(bOpts, regclient.BlobWithIgnoreMissing())
+	}
+	return rc.BlobDelete(ctx, r, types.Descriptor{Digest: d}, bOpts...)
+}
+
 func runBlobDiffConfig(cmd *cobra.Command, args []string) error {
 	diffOpts := []diff.Opt{}
 	if blobOpts.diffCtx > 0 {
@@ -168,7 +240,10 @@ func runBlobDiffConfig(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 
 	// open both configs, and output each as formatted json
 	d1, err := digest.Parse(args[1])
@@ -222,7 +297,10 @@ func runBlobDiffLayer(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 
 	// open both blobs, and generate reports of each content
 	d1, err := digest.Parse(args[1])
@@ -293,7 +371,10 @@ func runBlobGet(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 	if blobOpts.mt != "" {
 		log.WithFields(logrus.Fields{
@@ -339,7 +420,10 @@ func runBlobGetFile(cmd *cobra.Command, args []string) error {
 	}
 	filename := args[2]
 	filename = strings.TrimPrefix(filename, "/")
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	log.WithFields(logrus.Fields{
@@ -392,6 +476,48 @@ func runBlobGetFile(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runBlobListFiles(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	d, err := digest.Parse(args[1])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, r)
+
+	log.WithFields(logrus.Fields{
+		"host":       r.Registry,
+		"repository": r.Repository,
+		"digest":     args[1],
+	}).Debug("List files")
+	b, err := rc.BlobGet(ctx, r, types.Descriptor{Digest: d})
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	tr, err := b.ToTarReader()
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+	tl, ok := tr.(blob.TarFileLister)
+	if !ok {
+		return fmt.Errorf("tar reader does not support listing files%.0w", types.ErrUnsupported)
+	}
+	headers, err := tl.ListFiles()
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), blobOpts.formatList, headers)
+}
+
 func runBlobHead(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	r, err := ref.New(args[0])
@@ -402,7 +528,10 @@ func runBlobHead(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	log.WithFields(logrus.Fields{
@@ -429,7 +558,10 @@ func runBlobPut(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 
 	if blobOpts.mt != "" {
 		log.WithFields(logrus.Fields{
@@ -472,7 +604,10 @@ func runBlobCopy(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, rSrc)
 
 	log.WithFields(logrus.Fields{
@@ -2,7 +2,10 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"testing"
+
+	"github.com/regclient/regclient/types"
 )
 
 // TODO: implement tests
@@ -57,6 +60,15 @@ func TestBlob(t *testing.T) {
 		if out != "A" {
 			t.Errorf("unexpected blob get-file output, expected A, received %s", out)
 		}
+		// list the files in the blob
+		out, err = cobraTest(t, "blob", "list-files", repo, digBaseA)
+		blobOpts = saveBlobOpts
+		if err != nil {
+			t.Errorf("failed to blob list-files: %v", err)
+		}
+		if out != "base.txt" {
+			t.Errorf("unexpected blob list-files output, expected base.txt, received %s", out)
+		}
 	})
 
 	t.Run("Put", func(t *testing.T) {
@@ -102,6 +114,31 @@ func TestBlob(t *testing.T) {
 		}
 	})
 
+	t.Run("Delete", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := cobraTest(t, "blob", "copy", repo, "ocidir://"+dir, digBaseA)
+		blobOpts = saveBlobOpts
+		if err != nil {
+			t.Errorf("failed to blob copy: %v", err)
+		}
+		_, err = cobraTest(t, "blob", "delete", "ocidir://"+dir, digBaseA)
+		blobOpts = saveBlobOpts
+		if err != nil {
+			t.Errorf("failed to blob delete: %v", err)
+		}
+		_, err = cobraTest(t, "blob", "head", "ocidir://"+dir, digBaseA)
+		blobOpts = saveBlobOpts
+		if err == nil {
+			t.Errorf("blob head succeeded after delete")
+		}
+		// ocidir does not have upload sessions
+		_, err = cobraTest(t, "blob", "delete", "--upload", "ocidir://"+dir, "session")
+		blobOpts = saveBlobOpts
+		if err == nil || !errors.Is(err, types.ErrUnsupportedAPI) {
+			t.Errorf("upload cancel did not fail: %v", err)
+		}
+	})
+
 	t.Run("Diff", func(t *testing.T) {
 		// diff the layers between two images
 		out, err := cobraTest(t, "blob", "diff-layer", repo, digBaseA, repo, digBaseB)
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/ref"
@@ -115,30 +119,161 @@ func completeArgList(funcList []completeFunc) completeFunc {
 	}
 }
 
+// completeArgTag expands the registry, then the repository, then the tag of an image reference.
+// Registries come from the config, repositories and tags are queried and cached, see completionLookup.
 func completeArgTag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	result := []string{}
-	// TODO: is it possible to expand registry, then repo, then tag?
+	if strings.Contains(toComplete, "://") {
+		return completeTags(toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	slash := strings.LastIndex(toComplete, "/")
+	if slash < 0 {
+		// a registry or an image on Docker Hub
+		conf, _ := ConfigLoadDefault()
+		if conf != nil {
+			for name := range conf.Hosts {
+				if name != "" && strings.HasPrefix(name, toComplete) {
+					result = append(result, name+"/")
+				}
+			}
+		}
+		sort.Strings(result)
+		if len(result) == 0 || strings.Contains(toComplete, ":") {
+			result = append(result, completeTags(toComplete)...)
+		}
+		return result, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+	if strings.ContainsAny(toComplete[slash:], ":@") {
+		return completeTags(toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	// list repositories from the registry, falling back to the tags when the catalog is not available
+	host, _, _ := strings.Cut(toComplete, "/")
+	repos, err := completionLookup("repo "+host, func(ctx context.Context) ([]string, error) {
+		rc, err := newRegClient()
+		if err != nil {
+			return nil, err
+		}
+		rl, err := rc.RepoList(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		return rl.GetRepos()
+	})
+	if err != nil {
+		return completeTags(toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	for _, repo := range repos {
+		name := host + "/" + repo
+		if strings.HasPrefix(name, toComplete) {
+			result = append(result, name+":")
+		}
+	}
+	return result, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeTags returns the image references for each tag matching the input
+func completeTags(toComplete string) []string {
+	result := []string{}
 	input := strings.TrimRight(toComplete, ":")
 	r, err := ref.New(input)
 	if err != nil || r.Digest != "" {
-		return result, cobra.ShellCompDirectiveNoFileComp
-	}
-	rc := newRegClient()
-	tl, err := rc.TagList(context.Background(), r)
-	if err != nil {
-		return result, cobra.ShellCompDirectiveNoFileComp
+		return result
 	}
-	tags, err := tl.GetTags()
+	rRepo := r
+	rRepo.Tag = ""
+	tags, err := completionLookup("tag "+rRepo.CommonName(), func(ctx context.Context) ([]string, error) {
+		rc, err := newRegClient()
+		if err != nil {
+			return nil, err
+		}
+		tl, err := rc.TagList(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		return tl.GetTags()
+	})
 	if err != nil {
-		return result, cobra.ShellCompDirectiveNoFileComp
+		return result
 	}
 	for _, tag := range tags {
-		resultRef, _ := ref.New(input)
+		resultRef := r
 		resultRef.Tag = tag
 		resultCN := resultRef.CommonName()
 		if strings.HasPrefix(resultCN, toComplete) {
 			result = append(result, resultCN)
 		}
 	}
-	return result, cobra.ShellCompDirectiveNoFileComp
+	return result
+}
+
+const (
+	// completionCacheEnv overrides the file used to cache completion results
+	completionCacheEnv = "REGCTL_COMPLETION_CACHE"
+	// completionCacheTTL is how long repository and tag lists are reused
+	completionCacheTTL = 5 * time.Minute
+	// completionTimeout limits each query so a slow registry does not block the shell
+	completionTimeout = 5 * time.Second
+)
+
+type completionCache struct {
+	Entries map[string]completionCacheEntry `json:"entries"`
+}
+
+type completionCacheEntry struct {
+	Expires time.Time `json:"expires"`
+	Values  []string  `json:"values"`
+}
+
+func completionCacheFile() string {
+	if filename := os.Getenv(completionCacheEnv); filename != "" {
+		return filename
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "regctl", "completion.json")
+}
+
+// completionLookup returns the cached values for a key, or runs the query with a timeout and caches the result
+func completionLookup(key string, query func(ctx context.Context) ([]string, error)) ([]string, error) {
+	filename := completionCacheFile()
+	cache := completionCache{}
+	if filename != "" {
+		//#nosec G304 filename is from the user
+		if b, err := os.ReadFile(filename); err == nil {
+			_ = json.Unmarshal(b, &cache)
+		}
+	}
+	now := time.Now()
+	if entry, ok := cache.Entries[key]; ok && now.Before(entry.Expires) {
+		return entry.Values, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	values, err := query(ctx)
+	if err != nil || filename == "" {
+		return values, err
+	}
+	// drop expired entries when saving the new result
+	entries := map[string]completionCacheEntry{}
+	for k, entry := range cache.Entries {
+		if now.Before(entry.Expires) {
+			entries[k] = entry
+		}
+	}
+	entries[key] = completionCacheEntry{Expires: now.Add(completionCacheTTL), Values: values}
+	cache.Entries = entries
+	b, err := json.Marshal(cache)
+	if err != nil {
+		return values, nil
+	}
+	// failing to write the cache does not prevent completion
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err == nil {
+		tmp := filename + ".tmp"
+		if err := os.WriteFile(tmp, b, 0600); err == nil {
+			_ = os.Rename(tmp, filename)
+		}
+	}
+	return values, nil
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/regclient/regclient/internal/reqresp"
+)
+
+func TestCompleteArgTag(t *testing.T) {
+	rrs := []reqresp.ReqResp{
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "catalog",
+				Method: "GET",
+				Path:   "/v2/_catalog",
+			},
+			RespEntry: reqresp.RespEntry{
+				Status:  http.StatusOK,
+				Headers: http.Header{"Content-Type": {"application/json"}},
+				Body:    []byte(`{"repositories":["app/one","app/two","other"]}`),
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "tags app/one",
+				Method: "GET",
+				Path:   "/v2/app/one/tags/list",
+			},
+			RespEntry: reqresp.RespEntry{
+				Status:  http.StatusOK,
+				Headers: http.Header{"Content-Type": {"application/json"}},
+				Body:    []byte(`{"name":"app/one","tags":["v1","v2","latest"]}`),
+			},
+		},
+	}
+	rrs = append(rrs, reqresp.BaseEntries...)
+	ts := httptest.NewServer(reqresp.NewHandler(t, rrs))
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	tmpDir := t.TempDir()
+	confFile := filepath.Join(tmpDir, "config.json")
+	err := os.WriteFile(confFile, []byte(`{"hosts":{"`+tsHost+`":{"tls":"disabled","reqPerSec":100}}}`), 0600)
+	if err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv(ConfigEnv, confFile)
+	t.Setenv(completionCacheEnv, filepath.Join(tmpDir, "cache", "completion.json"))
+
+	tt := []struct {
+		name   string
+		input  string
+		expect []string
+	}{
+		{
+			name:   "registry",
+			input:  tsHost[:3],
+			expect: []string{tsHost + "/"},
+		},
+		{
+			name:   "repository",
+			input:  tsHost + "/app/",
+			expect: []string{tsHost + "/app/one:", tsHost + "/app/two:"},
+		},
+		{
+			name:   "tag",
+			input:  tsHost + "/app/one:v",
+			expect: []string{tsHost + "/app/one:v1", tsHost + "/app/one:v2"},
+		},
+		{
+			name:   "digest",
+			input:  tsHost + "/app/one@sha256:",
+			expect: []string{},
+		},
+	}
+	check := func(t *testing.T) {
+		for _, tc := range tt {
+			t.Run(tc.name, func(t *testing.T) {
+				result, _ := completeArgTag(nil, []string{}, tc.input)
+				if strings.Join(result, ",") != strings.Join(tc.expect, ",") {
+					t.Errorf("unexpected result, expected %v, received %v", tc.expect, result)
+				}
+			})
+		}
+	}
+	t.Run("Query", check)
+	// repositories and tags are returned from the cache after the registry is stopped
+	ts.Close()
+	t.Run("Cache", check)
+}
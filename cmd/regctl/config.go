@@ -29,12 +29,14 @@ type Config struct {
 	Version       int                     `json:"version,omitempty"` // version the file in case the config file syntax changes in the future
 	Hosts         map[string]*config.Host `json:"hosts"`
 	BlobLimit     int64                   `json:"blobLimit,omitempty"`
+	BlobSpool     int64                   `json:"blobSpool,omitempty"`
 	IncDockerCert *bool                   `json:"incDockerCert,omitempty"`
 	IncDockerCred *bool                   `json:"incDockerCred,omitempty"`
 }
 
 var configOpts struct {
 	blobLimit  int64
+	blobSpool  int64
 	dockerCert bool
 	dockerCred bool
 	format     string
@@ -62,6 +64,7 @@ func init() {
 	configGetCmd.Flags().StringVar(&configOpts.format, "format", "{{ printPretty . }}", "format the output with Go template syntax")
 
 	configSetCmd.Flags().Int64Var(&configOpts.blobLimit, "blob-limit", 0, "limit for blob chunks, this is stored in memory")
+	configSetCmd.Flags().Int64Var(&configOpts.blobSpool, "blob-spool", 0, "max size of streamed blobs written to a temp file before pushing, 0 to disable")
 	configSetCmd.Flags().BoolVar(&configOpts.dockerCert, "docker-cert", false, "load certificates from docker")
 	configSetCmd.Flags().BoolVar(&configOpts.dockerCred, "docker-cred", false, "load credentials from docker")
 
@@ -92,6 +95,9 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 	if flagChanged(cmd, "blob-limit") {
 		c.BlobLimit = configOpts.blobLimit
 	}
+	if flagChanged(cmd, "blob-spool") {
+		c.BlobSpool = configOpts.blobSpool
+	}
 	if flagChanged(cmd, "docker-cert") {
 		if !configOpts.dockerCert {
 			c.IncDockerCert = &configOpts.dockerCert
@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -84,3 +85,17 @@ func TestConfig(t *testing.T) {
 	}
 
 }
+
+func TestConfigTemplateError(t *testing.T) {
+	tempDir := t.TempDir()
+	confFile := filepath.Join(tempDir, "config.json")
+	err := os.WriteFile(confFile, []byte(`{"hosts":{"registry.example.org":{"pass":"{{ env \"MISSING\" "}}}`), 0600)
+	if err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv(ConfigEnv, confFile)
+	_, err = cobraTest(t, "tag", "ls", "registry.example.org/repo")
+	if err == nil || !strings.Contains(err.Error(), "failed to expand templates") {
+		t.Errorf("expected a template error, received: %v", err)
+	}
+}
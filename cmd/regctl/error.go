@@ -3,6 +3,8 @@ package main
 import "errors"
 
 var (
+	// ErrConformanceFailed is returned when a registry conformance check fails
+	ErrConformanceFailed = errors.New("conformance check failed")
 	// ErrCredsNotFound returned when creds needed and cannot be found
 	ErrCredsNotFound = errors.New("auth creds not found")
 	// ErrInvalidInput indicates a required field is invalid
@@ -16,6 +18,8 @@ var (
 	// ErrNotImplemented returned when method has not been implemented yet
 	// TODO: Delete when all methods are implemented
 	ErrNotImplemented = errors.New("not implemented")
+	// ErrRegistryCopyFailed is returned when any repository in a registry copy fails
+	ErrRegistryCopyFailed = errors.New("registry copy failed")
 	// ErrUnsupportedConfigVersion happens when config file version is greater than this command supports
 	ErrUnsupportedConfigVersion = errors.New("unsupported config version")
 )
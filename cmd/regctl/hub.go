@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/regclient/regclient/pkg/template"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var hubCmd = &cobra.Command{
+	Use:   "hub <cmd>",
+	Short: "query Docker Hub",
+}
+var hubInfoCmd = &cobra.Command{
+	Use:   "info <repository>",
+	Short: "show Docker Hub repository metadata",
+	Long: `Shows the star count, pull count, last updated time, and description of a
+Docker Hub repository using the Hub API.
+The login for docker.io is used when the Hub API requires authentication.`,
+	Example: `
+# show the metadata for the alpine repository
+regctl hub info alpine
+
+# show the pull count
+regctl hub info --format '{{.PullCount}}' regclient/regctl`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgTag,
+	RunE:              runHubInfo,
+}
+
+var hubOpts struct {
+	format string
+}
+
+func init() {
+	hubInfoCmd.Flags().StringVarP(&hubOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
+	hubInfoCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
+	hubCmd.AddCommand(hubInfoCmd)
+	rootCmd.AddCommand(hubCmd)
+}
+
+func runHubInfo(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(logrus.Fields{
+		"repo": r.Repository,
+	}).Debug("Hub info")
+
+	hi, err := rc.HubInfo(ctx, r)
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), hubOpts.format, hi)
+}
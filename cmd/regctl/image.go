@@ -2,6 +2,8 @@ package main
 
 import (
 	"archive/tar"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -18,6 +20,7 @@ import (
 	"github.com/regclient/regclient/internal/ascii"
 	"github.com/regclient/regclient/internal/units"
 	"github.com/regclient/regclient/mod"
+	"github.com/regclient/regclient/pkg/scan"
 	"github.com/regclient/regclient/pkg/template"
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/manifest"
@@ -31,6 +34,41 @@ var imageCmd = &cobra.Command{
 	Use:   "image <cmd>",
 	Short: "manage images",
 }
+var imageAttestationCmd = &cobra.Command{
+	Use:   "attestation <cmd>",
+	Short: "buildkit attestations",
+	Long: `Manage the attestations buildkit attaches to an image.
+Buildkit adds an attestation manifest to the index for each platform,
+containing in-toto statements like SLSA provenance and SBOMs.`,
+}
+var imageAttestationGetCmd = &cobra.Command{
+	Use:   "get <image_ref>",
+	Short: "get an attestation",
+	Long: `Output the in-toto statements attached to an image by buildkit.
+The platform defaults to the local platform.
+Use --predicate-type to select a single statement, e.g. "https://slsa.dev/provenance/v0.2".`,
+	Example: `
+# get the provenance for the local platform
+regctl image attestation get registry.example.org/repo:v1 \
+  --predicate-type https://slsa.dev/provenance/v0.2
+
+# get the SBOM for linux/arm64
+regctl image attestation get registry.example.org/repo:v1 \
+  --platform linux/arm64 --predicate-type https://spdx.dev/Document`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgTag,
+	RunE:              runImageAttestationGet,
+}
+var imageAttestationListCmd = &cobra.Command{
+	Use:     "list <image_ref>",
+	Aliases: []string{"ls"},
+	Short:   "list attestations",
+	Long: `List the in-toto statements attached to an image by buildkit.
+By default, the attestations for every platform are listed.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgTag,
+	RunE:              runImageAttestationList,
+}
 var imageCheckBaseCmd = &cobra.Command{
 	Use:     "check-base <image_ref>",
 	Aliases: []string{},
@@ -46,6 +84,18 @@ Use "-v info" to see more details.`,
 	ValidArgsFunction: completeArgTag,
 	RunE:              runImageCheckBase,
 }
+var imageCheckCmd = &cobra.Command{
+	Use:   "check <image_ref>",
+	Short: "verify the content of an image",
+	Long: `Verify every manifest and blob referenced by an image or index.
+Each manifest is pulled and verified against the digest and size of its
+descriptor, and each blob is checked with a HEAD request. Use --blobs to
+download and verify the digest of every blob. Missing and corrupt content
+is reported, and the command exits with a non-zero status when any is found.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgTag,
+	RunE:              runImageCheck,
+}
 var imageCopyCmd = &cobra.Command{
 	Use:     "copy <src_image_ref> <dst_image_ref>",
 	Aliases: []string{"cp"},
@@ -82,8 +132,12 @@ var imageExportCmd = &cobra.Command{
 	Use:   "export <image_ref> [filename]",
 	Short: "export image",
 	Long: `Exports an image into a tar file that can be later loaded into a docker
-engine with "docker load". The tar file is output to stdout by default.
+engine with "docker load". The tar file is output to stdout by default, or when
+the filename is "-".
+Additional images may be included with --add, each is tagged with its reference.
 Compression is typically not useful since layers are already compressed.
+Blobs for each platform are pulled concurrently into temporary files, use
+--parallel 1 to stream each blob directly into the tar.
 Example usage: regctl image export registry:5000/yourimg:v1 >yourimg-v1.tar`,
 	Args:              cobra.RangeArgs(1, 2),
 	ValidArgsFunction: completeArgTag,
@@ -98,12 +152,31 @@ var imageGetFileCmd = &cobra.Command{
 	ValidArgsFunction: completeArgList([]completeFunc{completeArgTag, completeArgNone, completeArgNone}),
 	RunE:              runImageGetFile,
 }
+var imageHistoryCmd = &cobra.Command{
+	Use:   "history <image_ref>",
+	Short: "show the history of an image",
+	Long: `Shows the history from the image config with the size of each layer, similar
+to docker history, but only pulls the manifest and config. Entries that did not
+create a layer have a size of 0.`,
+	Example: `
+# show the history of an image
+regctl image history alpine
+
+# show the full command for each step
+regctl image history --format '{{range .History}}{{println .CreatedBy}}{{end}}' alpine`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgTag,
+	RunE:              runImageHistory,
+}
 var imageImportCmd = &cobra.Command{
 	Use:   "import <image_ref> <filename>",
 	Short: "import image",
 	Long: `Imports an image from a tar file. This must be either a docker formatted tar
 from "docker save" or an OCI Layout compatible tar. The output from
-"regctl image export" can be used. Stdin is not permitted for the tar file.`,
+"regctl image export" can be used. Use "-" as the filename to read the tar from
+stdin, which is spooled to a temporary file since the tar is read more than once.
+Blobs are spooled to temporary files and pushed concurrently, use --parallel 1
+to push each blob directly from the tar.`,
 	Args:              cobra.ExactArgs(2),
 	ValidArgsFunction: completeArgList([]completeFunc{completeArgTag, completeArgDefault}),
 	RunE:              runImageImport,
@@ -143,6 +216,17 @@ For time options, the value is a comma separated list of key/value pairs:
 	ValidArgsFunction: completeArgTag,
 	RunE:              runImageMod,
 }
+var imagePinCmd = &cobra.Command{
+	Use:   "pin [image_ref...]",
+	Short: "resolve image tags to digests",
+	Long: `Resolves each image reference to a digest and outputs the pinned reference.
+With --file, image references are read from a Kubernetes manifest, compose
+file, or Dockerfile ("image:" and "FROM" lines). Adding --write updates the
+file in place. References that already include a digest are unchanged.`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completeArgTag,
+	RunE:              runImagePin,
+}
 var imageRateLimitCmd = &cobra.Command{
 	Use:   "ratelimit <image_ref>",
 	Short: "show the current rate limit",
@@ -153,41 +237,104 @@ The other values may be 0 if not provided by the registry.`,
 	ValidArgsFunction: completeArgTag,
 	RunE:              runImageRateLimit,
 }
+var imageRetagCmd = &cobra.Command{
+	Use:   "retag <image_ref> <new_tag>",
+	Short: "add a tag to an existing image",
+	Long: `Pushes the existing manifest to a new tag without copying any layers.
+The new tag may be a tag in the same repository, or a full reference to
+another repository on the same registry, where layers are mounted from the
+source repository.`,
+	Example: `
+# add the latest tag to an image
+regctl image retag registry.example.com/repo:v1.2.3 latest
+
+# tag an image in another repository on the same registry
+regctl image retag registry.example.com/repo:v1.2.3 registry.example.com/release:v1.2.3`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeArgTag,
+	RunE:              runImageRetag,
+}
+var imageSizeCmd = &cobra.Command{
+	Use:   "size <image_ref>",
+	Short: "show the size of an image",
+	Long: `Shows the size of each platform in an image and the total size.
+The size of a platform includes the manifest, config, and compressed layers
+pulled by a runtime. The total counts each manifest and blob once, so layers
+shared between platforms are not added multiple times.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgTag,
+	RunE:              runImageSize,
+}
 
 var imageOpts struct {
 	checkBaseRef    string
 	checkBaseDigest string
+	checkBlobs      bool
+	checkSchema     bool
 	checkSkipConfig bool
 	create          string
+	downgrade       bool
+	dryRun          bool
+	exportAdd       []string
 	exportCompress  bool
 	exportRef       string
 	fastCheck       bool
 	forceRecursive  bool
 	format          string
+	formatAttest    string
+	formatCheck     string
 	formatFile      string
+	formatPin       string
 	importName      string
 	includeExternal bool
 	digestTags      bool
 	list            bool
 	modOpts         []mod.Opts
+	parallel        int
+	pinFile         string
+	pinWrite        bool
 	platform        string
 	platforms       []string
+	predicateType   string
 	referrers       bool
 	replace         bool
 	requireList     bool
+	scan            string
+	scanServer      string
+	scanSeverity    string
+	stateFile       string
+	toOCI           bool
 }
 
 func init() {
 	imageOpts.modOpts = []mod.Opts{}
 
+	imageAttestationGetCmd.Flags().StringVarP(&imageOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
+	imageAttestationGetCmd.Flags().StringVarP(&imageOpts.predicateType, "predicate-type", "", "", "Predicate type of the statement")
+	imageAttestationGetCmd.RegisterFlagCompletionFunc("platform", completeArgPlatform)
+
+	imageAttestationListCmd.Flags().StringVarP(&imageOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
+	imageAttestationListCmd.Flags().StringVarP(&imageOpts.predicateType, "predicate-type", "", "", "Predicate type of the statement")
+	imageAttestationListCmd.Flags().StringVarP(&imageOpts.formatAttest, "format", "", "{{range .}}{{printf \"%-16s %-40s %s\\n\" (printf \"%s\" .Subject.Platform) .PredicateType .Descriptor.Digest}}{{end}}", "Format output with go template syntax")
+	imageAttestationListCmd.RegisterFlagCompletionFunc("platform", completeArgPlatform)
+	imageAttestationListCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
 	imageCheckBaseCmd.Flags().StringVarP(&imageOpts.checkBaseRef, "base", "", "", "Base image reference (including tag)")
 	imageCheckBaseCmd.Flags().StringVarP(&imageOpts.checkBaseDigest, "digest", "", "", "Base image digest (checks if digest matches base)")
 	imageCheckBaseCmd.Flags().BoolVarP(&imageOpts.checkSkipConfig, "no-config", "", false, "Skip check of config history")
 	imageCheckBaseCmd.Flags().StringVarP(&imageOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
 
+	imageCheckCmd.Flags().BoolVarP(&imageOpts.checkBlobs, "blobs", "", false, "Download and verify the digest of every blob")
+	imageCheckCmd.Flags().StringVarP(&imageOpts.formatCheck, "format", "", "{{range .Missing}}{{printf \"missing %s: %s\\n\" .Digest .Err}}{{end}}{{range .Corrupt}}{{printf \"corrupt %s: %s\\n\" .Digest .Err}}{{end}}{{range .Invalid}}{{$d := .Digest}}{{range .Violations}}{{printf \"invalid %s: %s\\n\" $d .}}{{end}}{{end}}", "Format output with go template syntax")
+	imageCheckCmd.Flags().BoolVarP(&imageOpts.referrers, "referrers", "", false, "Include referrers")
+	imageCheckCmd.Flags().BoolVarP(&imageOpts.checkSchema, "schema", "", false, "Validate manifests and configs against the OCI image-spec schemas")
+	imageCheckCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
+	imageCopyCmd.Flags().BoolVarP(&imageOpts.downgrade, "downgrade", "", false, "Convert OCI manifests to docker media types when the target registry rejects them, this changes the digest")
+	imageCopyCmd.Flags().BoolVarP(&imageOpts.dryRun, "dry-run", "", false, "Compare digests without copying, output the manifests and blobs that would be copied")
 	imageCopyCmd.Flags().BoolVarP(&imageOpts.fastCheck, "fast", "", false, "Fast check, skip referrers and digest tag checks when image exists, overrides force-recursive")
 	imageCopyCmd.Flags().BoolVarP(&imageOpts.forceRecursive, "force-recursive", "", false, "Force recursive copy of image, repairs missing nested blobs and manifests")
-	imageCopyCmd.Flags().StringVarP(&imageOpts.format, "format", "", "", "Format output with go template syntax")
+	imageCopyCmd.Flags().StringVarP(&imageOpts.format, "format", "", "", "Format output with go template syntax (use \"{{printPretty .Result}}\" for a summary of the copy)")
 	imageCopyCmd.Flags().BoolVarP(&imageOpts.includeExternal, "include-external", "", false, "Include external layers")
 	imageCopyCmd.Flags().StringVarP(&imageOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
 	imageCopyCmd.Flags().StringArrayVarP(&imageOpts.platforms, "platforms", "", []string{}, "Copy only specific platforms, registry validation must be disabled")
@@ -195,8 +342,14 @@ func init() {
 	imageCopyCmd.Flags().MarkHidden("platforms")
 	imageCopyCmd.Flags().BoolVarP(&imageOpts.digestTags, "digest-tags", "", false, "Include digest tags (\"sha256-<digest>.*\") when copying manifests")
 	imageCopyCmd.Flags().BoolVarP(&imageOpts.referrers, "referrers", "", false, "Include referrers")
+	imageCopyCmd.Flags().StringVarP(&imageOpts.scan, "scan", "", "", "Scan the source image before the copy with grype or trivy")
+	imageCopyCmd.Flags().StringVarP(&imageOpts.scanServer, "scan-server", "", "", "Trivy server url used by the scan")
+	imageCopyCmd.Flags().StringVarP(&imageOpts.scanSeverity, "scan-severity", "", "", "Refuse the copy when the scan finds vulnerabilities at or above the severity (negligible, low, medium, high, critical)")
+	imageCopyCmd.Flags().StringVarP(&imageOpts.stateFile, "state-file", "", "", "Track copied blobs in a file, rerunning the copy skips completed blobs")
+	imageCopyCmd.Flags().BoolVarP(&imageOpts.toOCI, "to-oci", "", false, "Convert docker schema1 images to OCI, this changes the digest")
 
 	imageDeleteCmd.Flags().BoolVarP(&manifestOpts.forceTagDeref, "force-tag-dereference", "", false, "Dereference the a tag to a digest, this is unsafe")
+	imageDeleteCmd.Flags().BoolVarP(&manifestOpts.ignoreMissing, "ignore-missing", "", false, "Ignore errors if the image is already deleted")
 
 	imageDigestCmd.Flags().BoolVarP(&manifestOpts.list, "list", "", true, "Do not resolve platform from manifest list (enabled by default)")
 	imageDigestCmd.Flags().StringVarP(&manifestOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
@@ -207,11 +360,21 @@ func init() {
 	imageGetFileCmd.Flags().StringVarP(&imageOpts.formatFile, "format", "", "", "Format output with go template syntax")
 	imageGetFileCmd.Flags().StringVarP(&imageOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
 
+	imageExportCmd.Flags().StringArrayVar(&imageOpts.exportAdd, "add", []string{}, "Additional image to include in the export")
 	imageExportCmd.Flags().BoolVar(&imageOpts.exportCompress, "compress", false, "Compress output with gzip")
+	imageExportCmd.Flags().BoolVarP(&imageOpts.includeExternal, "include-external", "", false, "Include external layers")
 	imageExportCmd.Flags().StringVar(&imageOpts.exportRef, "name", "", "Name of image to embed for docker load")
+	imageExportCmd.Flags().IntVar(&imageOpts.parallel, "parallel", imageParallelDefault, "Number of blobs to pull concurrently, 1 disables temp files")
 	imageExportCmd.Flags().StringVarP(&imageOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
 
+	imageImportCmd.Flags().BoolVarP(&imageOpts.includeExternal, "include-external", "", false, "Include external layers")
 	imageImportCmd.Flags().StringVar(&imageOpts.importName, "name", "", "Name of image or tag to import when multiple images are packaged in the tar")
+	imageImportCmd.Flags().IntVar(&imageOpts.parallel, "parallel", imageParallelDefault, "Number of blobs to push concurrently, 1 disables temp files")
+
+	imageHistoryCmd.Flags().StringVarP(&imageOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
+	imageHistoryCmd.Flags().StringVarP(&imageOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
+	imageHistoryCmd.RegisterFlagCompletionFunc("platform", completeArgPlatform)
+	imageHistoryCmd.RegisterFlagCompletionFunc("format", completeArgNone)
 
 	imageInspectCmd.Flags().StringVarP(&imageOpts.platform, "platform", "p", "", "Specify platform (e.g. linux/amd64 or local)")
 	imageInspectCmd.Flags().StringVarP(&imageOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
@@ -243,6 +406,20 @@ func init() {
 			return nil
 		},
 	}, "annotation", "", `set an annotation (name=value, omit value to delete, prefix with platform list [p1,p2] or [*] for all images)`)
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "stringArray",
+		f: func(val string) error {
+			vs := strings.SplitN(val, "=", 2)
+			if len(vs) == 2 {
+				imageOpts.modOpts = append(imageOpts.modOpts, mod.WithAnnotationDescriptor(vs[0], vs[1]))
+			} else if len(vs) == 1 {
+				imageOpts.modOpts = append(imageOpts.modOpts, mod.WithAnnotationDescriptor(vs[0], ""))
+			} else {
+				return fmt.Errorf("invalid annotation")
+			}
+			return nil
+		},
+	}, "annotation-descriptor", "", `set an annotation on manifest list descriptors (name=value, omit value to delete, prefix with platform list [p1,p2] to limit the descriptors)`)
 	imageModCmd.Flags().VarP(&modFlagFunc{
 		t: "stringArray",
 		f: func(val string) error {
@@ -276,6 +453,17 @@ func init() {
 			return nil
 		},
 	}, "annotation-base", "", `set base image annotations (image/name:tag,sha256:digest)`)
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "stringArray",
+		f: func(val string) error {
+			re, err := regexp.Compile(val)
+			if err != nil {
+				return fmt.Errorf("regexp is invalid: %w", err)
+			}
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithAnnotationRmRegex(re))
+			return nil
+		},
+	}, "annotation-rm-regex", "", `delete annotations with a matching name from all manifests and descriptors`)
 	imageModCmd.Flags().VarP(&modFlagFunc{
 		t: "string",
 		f: func(val string) error {
@@ -304,6 +492,17 @@ func init() {
 			return nil
 		},
 	}, "buildarg-rm-regex", "", `delete a build arg with a regex value`)
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "string",
+		f: func(val string) error {
+			args, err := imageParseArgs(val)
+			if err != nil {
+				return fmt.Errorf("failed to parse cmd: %w", err)
+			}
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithCmd(args))
+			return nil
+		},
+	}, "cmd", "", `set the default command (JSON array or a single argument, empty to delete)`)
 	imageModCmd.Flags().VarP(&modFlagFunc{
 		t: "string",
 		f: func(val string) error {
@@ -351,6 +550,32 @@ func init() {
 			return nil
 		},
 	}, "data-max", "", `sets or removes descriptor data field (size in bytes)`)
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "string",
+		f: func(val string) error {
+			args, err := imageParseArgs(val)
+			if err != nil {
+				return fmt.Errorf("failed to parse entrypoint: %w", err)
+			}
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithEntrypoint(args))
+			return nil
+		},
+	}, "entrypoint", "", `set the entrypoint (JSON array or a single argument, empty to delete)`)
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "stringArray",
+		f: func(val string) error {
+			vs := strings.SplitN(val, "=", 2)
+			if vs[0] == "" {
+				return fmt.Errorf("environment variable name is required%.0w", ErrInvalidInput)
+			}
+			if len(vs) == 2 {
+				imageOpts.modOpts = append(imageOpts.modOpts, mod.WithEnv(vs[0], vs[1]))
+			} else {
+				imageOpts.modOpts = append(imageOpts.modOpts, mod.WithEnvRm(vs[0]))
+			}
+			return nil
+		},
+	}, "env", "", `set an environment variable (name=value, omit "=value" to delete)`)
 	imageModCmd.Flags().VarP(&modFlagFunc{
 		t: "stringArray",
 		f: func(val string) error {
@@ -449,6 +674,17 @@ func init() {
 		},
 	}, "label-to-annotation", "", `set annotations from labels`)
 	flagLabelAnnot.NoOptDefVal = "true"
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "stringArray",
+		f: func(val string) error {
+			re, err := regexp.Compile(val)
+			if err != nil {
+				return fmt.Errorf("regexp is invalid: %w", err)
+			}
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithLabelRmRegex(re))
+			return nil
+		},
+	}, "label-rm-regex", "", `delete labels with a matching name from all image configs`)
 	imageModCmd.Flags().VarP(&modFlagFunc{
 		t: "string",
 		f: func(val string) error {
@@ -479,6 +715,64 @@ func init() {
 			return nil
 		},
 	}, "layer-strip-file", "", `delete a file or directory from all layers`)
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "string",
+		f: func(val string) error {
+			source, target := val, ""
+			if strings.Contains(val, "=") {
+				source = ""
+				for _, ss := range strings.Split(val, ",") {
+					kv := strings.SplitN(ss, "=", 2)
+					if len(kv) != 2 {
+						return fmt.Errorf("parameter without a value: %s", ss)
+					}
+					switch kv[0] {
+					case "source", "src":
+						source = kv[1]
+					case "target", "dest":
+						target = kv[1]
+					default:
+						return fmt.Errorf("unknown layer-add parameter: %s", kv[0])
+					}
+				}
+			}
+			if source == "" {
+				return fmt.Errorf("layer-add requires a source")
+			}
+			fi, err := os.Stat(source)
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				imageOpts.modOpts = append(imageOpts.modOpts, mod.WithLayerAddDir(source, target))
+				return nil
+			}
+			if target != "" {
+				return fmt.Errorf("layer-add target is only supported with a directory source")
+			}
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithLayerAddTar(source))
+			return nil
+		},
+	}, "layer-add", "", `add a layer from a directory or tar file (source=dir,target=/path)`)
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "string",
+		f: func(val string) error {
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithLayerWhiteout(val))
+			return nil
+		},
+	}, "layer-whiteout", "", `delete a file or directory with a whiteout in a new layer`)
+	flagLayerSquash := imageModCmd.Flags().VarPF(&modFlagFunc{
+		t: "int",
+		f: func(val string) error {
+			i, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("count invalid: %w", err)
+			}
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithLayerSquash(i))
+			return nil
+		},
+	}, "layer-squash", "", `squash the top layers into one (count of layers, 0 squashes all layers)`)
+	flagLayerSquash.NoOptDefVal = "0"
 	imageModCmd.Flags().VarP(&modFlagFunc{
 		t: "string",
 		f: func(val string) error {
@@ -515,6 +809,28 @@ func init() {
 		},
 	}, "layer-time-max", "", `max timestamp for a layer`)
 	imageModCmd.Flags().MarkHidden("layer-time-max") // TODO: deprecate in favor of layer-time
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "stringArray",
+		f: func(val string) error {
+			platforms, err := imageParsePlatforms(val)
+			if err != nil {
+				return err
+			}
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithPlatformDrop(platforms))
+			return nil
+		},
+	}, "platform-drop", "", `delete platforms from a manifest list (comma separated, e.g. linux/s390x,linux/ppc64le)`)
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "stringArray",
+		f: func(val string) error {
+			platforms, err := imageParsePlatforms(val)
+			if err != nil {
+				return err
+			}
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithPlatformKeep(platforms))
+			return nil
+		},
+	}, "platform-keep", "", `delete every other platform from a manifest list (comma separated, e.g. linux/amd64,linux/arm64)`)
 	flagRebase := imageModCmd.Flags().VarPF(&modFlagFunc{
 		t: "bool",
 		f: func(val string) error {
@@ -529,7 +845,7 @@ func init() {
 			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithRebase())
 			return nil
 		},
-	}, "rebase", "", `rebase an image using OCI annotations`)
+	}, "rebase", "", `rebase an image using OCI annotations or buildpacks metadata`)
 	flagRebase.NoOptDefVal = "true"
 	imageModCmd.Flags().VarP(&modFlagFunc{
 		t: "string",
@@ -648,6 +964,13 @@ func init() {
 		},
 	}, "to-oci-referrers", "", `convert to OCI referrers`)
 	flagOCIReferrers.NoOptDefVal = "true"
+	imageModCmd.Flags().VarP(&modFlagFunc{
+		t: "string",
+		f: func(val string) error {
+			imageOpts.modOpts = append(imageOpts.modOpts, mod.WithUser(val))
+			return nil
+		},
+	}, "user", "", `set the user (name or uid, with optional :group, empty to delete)`)
 	imageModCmd.Flags().VarP(&modFlagFunc{
 		t: "stringArray",
 		f: func(val string) error {
@@ -663,23 +986,76 @@ func init() {
 		},
 	}, "volume-rm", "", `delete a volume definition`)
 
+	imagePinCmd.Flags().StringVarP(&imageOpts.pinFile, "file", "f", "", "File containing image references to pin")
+	imagePinCmd.Flags().StringVarP(&imageOpts.formatPin, "format", "", "{{range .}}{{println .Pinned}}{{end}}", "Format output with go template syntax")
+	imagePinCmd.Flags().BoolVarP(&imageOpts.pinWrite, "write", "w", false, "Update the file in place")
+	imagePinCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
 	imageRateLimitCmd.Flags().StringVarP(&imageOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
 	imageRateLimitCmd.RegisterFlagCompletionFunc("format", completeArgNone)
 
+	imageSizeCmd.Flags().StringVarP(&imageOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
+	imageSizeCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
+	imageAttestationCmd.AddCommand(imageAttestationGetCmd)
+	imageAttestationCmd.AddCommand(imageAttestationListCmd)
+	imageCmd.AddCommand(imageAttestationCmd)
+	imageCmd.AddCommand(imageCheckCmd)
 	imageCmd.AddCommand(imageCheckBaseCmd)
 	imageCmd.AddCommand(imageCopyCmd)
 	imageCmd.AddCommand(imageDeleteCmd)
 	imageCmd.AddCommand(imageDigestCmd)
 	imageCmd.AddCommand(imageExportCmd)
 	imageCmd.AddCommand(imageGetFileCmd)
+	imageCmd.AddCommand(imageHistoryCmd)
 	imageCmd.AddCommand(imageImportCmd)
 	imageCmd.AddCommand(imageInspectCmd)
 	imageCmd.AddCommand(imageManifestCmd)
 	imageCmd.AddCommand(imageModCmd)
+	imageCmd.AddCommand(imagePinCmd)
 	imageCmd.AddCommand(imageRateLimitCmd)
+	imageCmd.AddCommand(imageRetagCmd)
+	imageCmd.AddCommand(imageSizeCmd)
 	rootCmd.AddCommand(imageCmd)
 }
 
+// imageParsePlatforms parses a comma separated list of platforms
+func imageParsePlatforms(s string) ([]platform.Platform, error) {
+	platforms := []platform.Platform{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		p, err := platform.Parse(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse platform %s: %w", entry, err)
+		}
+		platforms = append(platforms, p)
+	}
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("at least one platform is required%.0w", ErrInvalidInput)
+	}
+	return platforms, nil
+}
+
+// imageParseArgs parses a JSON array of arguments, or a single argument, an empty string returns no arguments
+func imageParseArgs(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		return []string{s}, nil
+	}
+	args := []string{}
+	err := json.Unmarshal([]byte(s), &args)
+	if err != nil {
+		return nil, fmt.Errorf("arguments must be a JSON array of strings%.0w", ErrInvalidInput)
+	}
+	return args, nil
+}
+
 func imageParseOptTime(s string) (mod.OptTime, map[string]string, error) {
 	ot := mod.OptTime{}
 	otherFields := map[string]string{}
@@ -722,13 +1098,149 @@ func imageParseOptTime(s string) (mod.OptTime, map[string]string, error) {
 	return ot, otherFields, nil
 }
 
+func runImageAttestationGet(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, r)
+
+	p := imageOpts.platform
+	if p == "" {
+		p = "local"
+	}
+	log.WithFields(logrus.Fields{
+		"ref":           r.CommonName(),
+		"platform":      p,
+		"predicateType": imageOpts.predicateType,
+	}).Debug("Attestation get")
+	al, err := imageAttestations(ctx, rc, r, p)
+	if err != nil {
+		return err
+	}
+	for _, a := range al {
+		rdr, err := rc.AttestationGet(ctx, r, a)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(cmd.OutOrStdout(), rdr)
+		rdr.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runImageAttestationList(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, r)
+
+	log.WithFields(logrus.Fields{
+		"ref":           r.CommonName(),
+		"platform":      imageOpts.platform,
+		"predicateType": imageOpts.predicateType,
+	}).Debug("Attestation list")
+	al, err := imageAttestations(ctx, rc, r, imageOpts.platform)
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), imageOpts.formatAttest, al)
+}
+
+// imageAttestations lists the attestations for the platform, filtered by the predicate type
+func imageAttestations(ctx context.Context, rc *regclient.RegClient, r ref.Ref, p string) ([]regclient.Attestation, error) {
+	opts := []regclient.ImageOpts{}
+	if p != "" {
+		opts = append(opts, regclient.ImageWithPlatform(p))
+	}
+	al, err := rc.AttestationList(ctx, r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if imageOpts.predicateType == "" {
+		return al, nil
+	}
+	filtered := []regclient.Attestation{}
+	for _, a := range al {
+		if a.PredicateType == imageOpts.predicateType {
+			filtered = append(filtered, a)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no attestations found with predicate type %s%.0w", imageOpts.predicateType, types.ErrNotFound)
+	}
+	return filtered, nil
+}
+
+func runImageCheck(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, r)
+
+	opts := []regclient.ImageCheckOpts{}
+	if imageOpts.checkBlobs {
+		opts = append(opts, regclient.ImageCheckWithBlobs())
+	}
+	if imageOpts.referrers {
+		opts = append(opts, regclient.ImageCheckWithReferrers())
+	}
+	if imageOpts.checkSchema {
+		opts = append(opts, regclient.ImageCheckWithSchema())
+	}
+	log.WithFields(logrus.Fields{
+		"ref":    r.CommonName(),
+		"blobs":  imageOpts.checkBlobs,
+		"schema": imageOpts.checkSchema,
+	}).Debug("Image check")
+	report, err := rc.ImageCheck(ctx, r, opts...)
+	if err != nil {
+		return err
+	}
+	err = template.Writer(cmd.OutOrStdout(), imageOpts.formatCheck, report)
+	if err != nil {
+		return err
+	}
+	if !report.OK() {
+		return fmt.Errorf("image check found %d missing, %d corrupt, and %d invalid entries%.0w", len(report.Missing), len(report.Corrupt), len(report.Invalid), types.ErrMismatch)
+	}
+	log.WithFields(logrus.Fields{
+		"manifests": report.Manifests,
+		"blobs":     report.Blobs,
+	}).Info("image check passed")
+	return nil
+}
+
 func runImageCheckBase(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	r, err := ref.New(args[0])
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	opts := []regclient.ImageOpts{}
@@ -770,7 +1282,10 @@ func runImageCopy(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, rSrc)
 	defer rc.Close(ctx, rTgt)
 	if imageOpts.platform != "" {
@@ -797,6 +1312,9 @@ func runImageCopy(cmd *cobra.Command, args []string) error {
 		"digest-tags": imageOpts.digestTags,
 	}).Debug("Image copy")
 	opts := []regclient.ImageOpts{}
+	if imageOpts.downgrade {
+		opts = append(opts, regclient.ImageWithDowngrade())
+	}
 	if imageOpts.fastCheck {
 		opts = append(opts, regclient.ImageWithFastCheck())
 	}
@@ -815,10 +1333,39 @@ func runImageCopy(cmd *cobra.Command, args []string) error {
 	if len(imageOpts.platforms) > 0 {
 		opts = append(opts, regclient.ImageWithPlatforms(imageOpts.platforms))
 	}
+	if imageOpts.scan != "" {
+		scanner, err := scan.ByName(imageOpts.scan, imageOpts.scanServer)
+		if err != nil {
+			return err
+		}
+		threshold := regclient.ScanSeverityUnknown
+		if imageOpts.scanSeverity != "" {
+			threshold, err = regclient.ParseScanSeverity(imageOpts.scanSeverity)
+			if err != nil {
+				return err
+			}
+		}
+		opts = append(opts, regclient.ImageWithScanner(scanner, threshold))
+	} else if imageOpts.scanServer != "" || imageOpts.scanSeverity != "" {
+		return fmt.Errorf("--scan is required with --scan-server and --scan-severity%.0w", ErrMissingInput)
+	}
+	if imageOpts.stateFile != "" {
+		opts = append(opts, regclient.ImageWithCopyState(imageOpts.stateFile))
+	}
+	if imageOpts.toOCI {
+		opts = append(opts, regclient.ImageWithSchema1ToOCI())
+	}
+	var plan *regclient.ImageCopyPlan
+	if imageOpts.dryRun {
+		plan = &regclient.ImageCopyPlan{}
+		opts = append(opts, regclient.ImageWithCopyPlan(plan))
+	}
+	result := regclient.ImageCopyResult{}
+	opts = append(opts, regclient.ImageWithCopyResult(&result))
 	// check for a tty and attach progress reporter
 	done := make(chan bool)
 	var progress *imageProgress
-	if !flagChanged(cmd, "verbosity") && ascii.IsWriterTerminal(cmd.ErrOrStderr()) {
+	if !imageOpts.dryRun && !flagChanged(cmd, "verbosity") && ascii.IsWriterTerminal(cmd.ErrOrStderr()) {
 		progress = &imageProgress{
 			start:   time.Now(),
 			entries: map[string]*imageProgressEntry{},
@@ -848,10 +1395,34 @@ func runImageCopy(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if plan != nil {
+		if !flagChanged(cmd, "format") {
+			imageOpts.format = "{{range .Manifests}}{{printf \"manifest %s %d\\n\" .Digest .Size}}{{end}}{{range .Blobs}}{{printf \"blob %s %d\\n\" .Digest .Size}}{{end}}{{printf \"total %d bytes\\n\" .Bytes}}"
+		}
+		return template.Writer(cmd.OutOrStdout(), imageOpts.format, plan)
+	}
+	log.WithFields(logrus.Fields{
+		"source":           rSrc.CommonName(),
+		"target":           rTgt.CommonName(),
+		"digest":           result.Digest.String(),
+		"manifestsCopied":  result.ManifestsCopied,
+		"manifestsSkipped": result.ManifestsSkipped,
+		"blobsCopied":      result.BlobsCopied,
+		"blobsSkipped":     result.BlobsSkipped,
+		"bytesCopied":      result.BytesCopied,
+		"duration":         result.Duration.String(),
+		"rate":             units.HumanSize(result.Rate()) + "/s",
+	}).Info("Image copy complete")
 	if !flagChanged(cmd, "format") {
 		imageOpts.format = "{{ .CommonName }}\n"
 	}
-	return template.Writer(cmd.OutOrStdout(), imageOpts.format, rTgt)
+	return template.Writer(cmd.OutOrStdout(), imageOpts.format, imageCopyOutput{Ref: rTgt, Result: result})
+}
+
+// imageCopyOutput is passed to the format of the image copy command, including the fields of the target ref
+type imageCopyOutput struct {
+	ref.Ref
+	Result regclient.ImageCopyResult `json:"result"`
 }
 
 type imageProgress struct {
@@ -991,8 +1562,19 @@ func runImageExport(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	refs := []ref.Ref{r}
+	for _, add := range imageOpts.exportAdd {
+		rAdd, err := ref.New(add)
+		if err != nil {
+			return fmt.Errorf("cannot parse %s: %w", add, err)
+		}
+		refs = append(refs, rAdd)
+	}
+	if len(refs) > 1 && imageOpts.exportRef != "" {
+		return fmt.Errorf("--name cannot be used with --add")
+	}
 	var w io.Writer
-	if len(args) == 2 {
+	if len(args) == 2 && args[1] != "-" {
 		w, err = os.Create(args[1])
 		if err != nil {
 			return err
@@ -1000,29 +1582,40 @@ func runImageExport(cmd *cobra.Command, args []string) error {
 	} else {
 		w = cmd.OutOrStdout()
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 	opts := []regclient.ImageOpts{}
+	if imageOpts.includeExternal {
+		opts = append(opts, regclient.ImageWithIncludeExternal())
+	}
 	if imageOpts.platform != "" {
 		p, err := platform.Parse(imageOpts.platform)
 		if err != nil {
 			return err
 		}
-		m, err := rc.ManifestGet(ctx, r)
-		if err != nil {
-			return err
-		}
-		if m.IsList() {
-			d, err := manifest.GetPlatformDesc(m, &p)
+		for i := range refs {
+			m, err := rc.ManifestGet(ctx, refs[i])
 			if err != nil {
 				return err
 			}
-			r.Digest = d.Digest.String()
+			if m.IsList() {
+				d, err := manifest.GetPlatformDesc(m, &p)
+				if err != nil {
+					return err
+				}
+				refs[i].Digest = d.Digest.String()
+			}
 		}
 	}
 	if imageOpts.exportCompress {
 		opts = append(opts, regclient.ImageWithExportCompress())
 	}
+	if imageOpts.parallel > 0 {
+		opts = append(opts, regclient.ImageWithParallel(imageOpts.parallel))
+	}
 	if imageOpts.exportRef != "" {
 		eRef, err := ref.New(imageOpts.exportRef)
 		if err != nil {
@@ -1030,10 +1623,17 @@ func runImageExport(cmd *cobra.Command, args []string) error {
 		}
 		opts = append(opts, regclient.ImageWithExportRef(eRef))
 	}
+	if len(refs) > 1 {
+		log.WithFields(logrus.Fields{
+			"refs": imageOpts.exportAdd,
+			"ref":  r.CommonName(),
+		}).Debug("Image export multiple")
+		return rc.ImageExportMulti(ctx, refs, w, opts...)
+	}
 	log.WithFields(logrus.Fields{
-		"ref": r.CommonName(),
+		"ref": refs[0].CommonName(),
 	}).Debug("Image export")
-	return rc.ImageExport(ctx, r, w, opts...)
+	return rc.ImageExport(ctx, refs[0], w, opts...)
 }
 
 func runImageGetFile(cmd *cobra.Command, args []string) error {
@@ -1044,7 +1644,10 @@ func runImageGetFile(cmd *cobra.Command, args []string) error {
 	}
 	filename := args[1]
 	filename = strings.TrimPrefix(filename, "/")
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	log.WithFields(logrus.Fields{
@@ -1052,131 +1655,127 @@ func runImageGetFile(cmd *cobra.Command, args []string) error {
 		"filename": filename,
 	}).Debug("Get file")
 
-	// make it recursive for index of index scenarios
-	m, err := rc.ManifestGet(ctx, r)
+	opts := []regclient.ImageOpts{}
+	if imageOpts.platform != "" {
+		opts = append(opts, regclient.ImageWithPlatform(imageOpts.platform))
+	}
+	th, rdr, err := rc.ImageGetFile(ctx, r, filename, opts...)
 	if err != nil {
 		return err
 	}
-	if m.IsList() {
-		if imageOpts.platform == "" {
-			imageOpts.platform = "local"
-		}
-		plat, err := platform.Parse(imageOpts.platform)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"platform": imageOpts.platform,
-				"err":      err,
-			}).Warn("Could not parse platform")
+	defer rdr.Close()
+	// file found, output
+	if imageOpts.formatFile != "" {
+		data := struct {
+			Header *tar.Header
+			Reader io.Reader
+		}{
+			Header: th,
+			Reader: rdr,
 		}
-		desc, err := manifest.GetPlatformDesc(m, &plat)
+		return template.Writer(cmd.OutOrStdout(), imageOpts.formatFile, data)
+	}
+	var w io.Writer
+	if len(args) < 3 {
+		w = cmd.OutOrStdout()
+	} else {
+		fh, err := os.Create(args[2])
 		if err != nil {
-			pl, _ := manifest.GetPlatformList(m)
-			var ps []string
-			for _, p := range pl {
-				ps = append(ps, p.String())
-			}
-			log.WithFields(logrus.Fields{
-				"platform":  plat,
-				"err":       err,
-				"platforms": strings.Join(ps, ", "),
-			}).Warn("Platform could not be found in manifest list")
 			return err
 		}
-		m, err = rc.ManifestGet(ctx, r, regclient.WithManifestDesc(*desc))
-		if err != nil {
-			return fmt.Errorf("failed to pull platform specific digest: %w", err)
-		}
+		defer fh.Close()
+		w = fh
 	}
-	// go through layers in reverse
-	mi, ok := m.(manifest.Imager)
-	if !ok {
-		return fmt.Errorf("reference is not a known image media type")
+	_, err = io.Copy(w, rdr)
+	if err != nil {
+		return err
 	}
-	layers, err := mi.GetLayers()
+	return rdr.Close()
+}
+
+func runImageImport(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
 	if err != nil {
 		return err
 	}
-	for i := len(layers) - 1; i >= 0; i-- {
-		blob, err := rc.BlobGet(ctx, r, layers[i])
-		if err != nil {
-			return fmt.Errorf("failed pulling layer %d: %w", i, err)
-		}
-		btr, err := blob.ToTarReader()
+	opts := []regclient.ImageOpts{}
+	if imageOpts.includeExternal {
+		opts = append(opts, regclient.ImageWithIncludeExternal())
+	}
+	if imageOpts.importName != "" {
+		opts = append(opts, regclient.ImageWithImportName(imageOpts.importName))
+	}
+	if imageOpts.parallel > 0 {
+		opts = append(opts, regclient.ImageWithParallel(imageOpts.parallel))
+	}
+	var rs io.ReadSeeker
+	if args[1] == "-" {
+		// the import seeks within the tar, so stdin is written to a temp file
+		fh, err := os.CreateTemp("", "regctl-import-*.tar")
 		if err != nil {
-			return fmt.Errorf("could not convert layer %d to tar reader: %w", i, err)
+			return err
 		}
-		th, rdr, err := btr.ReadFile(filename)
+		defer os.Remove(fh.Name())
+		defer fh.Close()
+		_, err = io.Copy(fh, cmd.InOrStdin())
 		if err != nil {
-			if errors.Is(err, types.ErrFileNotFound) {
-				if err := btr.Close(); err != nil {
-					return err
-				}
-				if err := blob.Close(); err != nil {
-					return err
-				}
-				continue
-			}
-			return fmt.Errorf("failed pulling from layer %d: %w", i, err)
-		}
-		// file found, output
-		if imageOpts.formatFile != "" {
-			data := struct {
-				Header *tar.Header
-				Reader io.Reader
-			}{
-				Header: th,
-				Reader: rdr,
-			}
-			return template.Writer(cmd.OutOrStdout(), imageOpts.formatFile, data)
-		}
-		var w io.Writer
-		if len(args) < 3 {
-			w = cmd.OutOrStdout()
-		} else {
-			w, err = os.Create(args[2])
-			if err != nil {
-				return err
-			}
+			return fmt.Errorf("failed to read stdin: %w", err)
 		}
-		_, err = io.Copy(w, rdr)
+		_, err = fh.Seek(0, io.SeekStart)
 		if err != nil {
 			return err
 		}
-		if err := btr.Close(); err != nil {
-			return err
-		}
-		if err := blob.Close(); err != nil {
+		rs = fh
+	} else {
+		fh, err := os.Open(args[1])
+		if err != nil {
 			return err
 		}
-		return nil
+		defer fh.Close()
+		rs = fh
 	}
-	// all layers exhausted, not found or deleted
-	return types.ErrNotFound
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, r)
+	log.WithFields(logrus.Fields{
+		"ref":  r.CommonName(),
+		"file": args[1],
+	}).Debug("Image import")
+
+	return rc.ImageImport(ctx, r, rs, opts...)
 }
 
-func runImageImport(cmd *cobra.Command, args []string) error {
+func runImageHistory(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	r, err := ref.New(args[0])
 	if err != nil {
 		return err
 	}
-	opts := []regclient.ImageOpts{}
-	if imageOpts.importName != "" {
-		opts = append(opts, regclient.ImageWithImportName(imageOpts.importName))
-	}
-	rs, err := os.Open(args[1])
+	rc, err := newRegClient()
 	if err != nil {
 		return err
 	}
-	defer rs.Close()
-	rc := newRegClient()
 	defer rc.Close(ctx, r)
+
 	log.WithFields(logrus.Fields{
-		"ref":  r.CommonName(),
-		"file": args[1],
-	}).Debug("Image import")
+		"host":     r.Registry,
+		"repo":     r.Repository,
+		"tag":      r.Tag,
+		"platform": imageOpts.platform,
+	}).Debug("Image history")
 
-	return rc.ImageImport(ctx, r, rs, opts...)
+	opts := []regclient.ImageOpts{}
+	if imageOpts.platform != "" {
+		opts = append(opts, regclient.ImageWithPlatform(imageOpts.platform))
+	}
+	report, err := rc.ImageHistory(ctx, r, opts...)
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), imageOpts.format, report)
 }
 
 func runImageInspect(cmd *cobra.Command, args []string) error {
@@ -1185,7 +1784,10 @@ func runImageInspect(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	log.WithFields(logrus.Fields{
@@ -1253,7 +1855,10 @@ func runImageMod(cmd *cobra.Command, args []string) error {
 		rTgt.Tag = ""
 	}
 	imageOpts.modOpts = append(imageOpts.modOpts, mod.WithRefTgt(rTgt))
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 
 	log.WithFields(logrus.Fields{
 		"ref": rSrc.CommonName(),
@@ -1269,13 +1874,134 @@ func runImageMod(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// pinEntry is an image reference found by the pin command
+type pinEntry struct {
+	Source string  // reference as written in the input
+	Pinned string  // source with the digest appended
+	Ref    ref.Ref `json:"-"`
+}
+
+var (
+	pinReImage = regexp.MustCompile(`^(\s*-?\s*image:\s*["']?)([^\s"'#]+)(.*)$`)
+	pinReFrom  = regexp.MustCompile(`(?i)^(\s*FROM\s+(?:--\S+\s+)*)(\S+)(.*)$`)
+	pinReStage = regexp.MustCompile(`(?i)\s+AS\s+(\S+)`)
+)
+
+// pinFindRefs returns the image references found in a file, indexed by line number.
+// Each entry contains the text before the reference, the reference, and the text after.
+// Dockerfile stage names, scratch, and references with build args are skipped.
+func pinFindRefs(lines []string) map[int][]string {
+	found := map[int][]string{}
+	stages := map[string]bool{"scratch": true}
+	for i, line := range lines {
+		match := pinReImage.FindStringSubmatch(line)
+		if match == nil {
+			match = pinReFrom.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			stage := pinReStage.FindStringSubmatch(match[3])
+			if stages[strings.ToLower(match[2])] {
+				match = nil
+			}
+			if stage != nil {
+				stages[strings.ToLower(stage[1])] = true
+			}
+		}
+		if match == nil || strings.Contains(match[2], "$") {
+			continue
+		}
+		found[i] = match[1:]
+	}
+	return found
+}
+
+func runImagePin(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	if imageOpts.pinWrite && imageOpts.pinFile == "" {
+		return fmt.Errorf("--write requires --file")
+	}
+	sources := append([]string{}, args...)
+	var lines []string
+	var lineRefs map[int][]string
+	if imageOpts.pinFile != "" {
+		b, err := os.ReadFile(imageOpts.pinFile)
+		if err != nil {
+			return err
+		}
+		lines = strings.Split(string(b), "\n")
+		lineRefs = pinFindRefs(lines)
+		lineNums := make([]int, 0, len(lineRefs))
+		for i := range lineRefs {
+			lineNums = append(lineNums, i)
+		}
+		sort.Ints(lineNums)
+		for _, i := range lineNums {
+			sources = append(sources, lineRefs[i][1])
+		}
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("no image references provided")
+	}
+	entries := make([]pinEntry, len(sources))
+	refs := make([]ref.Ref, len(sources))
+	for i, src := range sources {
+		r, err := ref.New(src)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", src, err)
+		}
+		entries[i].Source = src
+		refs[i] = r
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	for _, r := range refs {
+		defer rc.Close(ctx, r)
+	}
+	log.WithFields(logrus.Fields{
+		"count": len(refs),
+	}).Debug("Pin images")
+	pinned, err := rc.Pin(ctx, refs)
+	if err != nil {
+		return err
+	}
+	pinMap := map[string]string{}
+	for i := range entries {
+		entries[i].Ref = pinned[i]
+		entries[i].Pinned = entries[i].Source
+		if !strings.Contains(entries[i].Source, "@") {
+			entries[i].Pinned = entries[i].Source + "@" + pinned[i].Digest
+		}
+		pinMap[entries[i].Source] = entries[i].Pinned
+	}
+	if imageOpts.pinWrite {
+		for i, match := range lineRefs {
+			lines[i] = match[0] + pinMap[match[1]] + match[2]
+		}
+		fi, err := os.Stat(imageOpts.pinFile)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(imageOpts.pinFile, []byte(strings.Join(lines, "\n")), fi.Mode())
+		if err != nil {
+			return err
+		}
+	}
+	return template.Writer(cmd.OutOrStdout(), imageOpts.formatPin, entries)
+}
+
 func runImageRateLimit(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	r, err := ref.New(args[0])
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 
 	log.WithFields(logrus.Fields{
 		"host": r.Registry,
@@ -1292,6 +2018,51 @@ func runImageRateLimit(cmd *cobra.Command, args []string) error {
 	return template.Writer(cmd.OutOrStdout(), imageOpts.format, manifest.GetRateLimit(m))
 }
 
+func runImageRetag(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, r)
+
+	log.WithFields(logrus.Fields{
+		"ref":    r.CommonName(),
+		"newTag": args[1],
+	}).Debug("Image retag")
+
+	return rc.TagCopy(ctx, r, args[1])
+}
+
+func runImageSize(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, r)
+
+	log.WithFields(logrus.Fields{
+		"host": r.Registry,
+		"repo": r.Repository,
+		"tag":  r.Tag,
+	}).Debug("Image size")
+
+	report, err := rc.ImageSize(ctx, r)
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), imageOpts.format, report)
+}
+
 type modFlagFunc struct {
 	f func(string) error
 	t string
@@ -1,10 +1,170 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"testing"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
 )
 
+func TestImageCheck(t *testing.T) {
+	tmpDir := t.TempDir()
+	tgtRef := "ocidir://" + tmpDir + "/repo:v1"
+	saveOpts := imageOpts
+	_, err := cobraTest(t, "image", "copy", "ocidir://../../testdata/testrepo:v1", tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to copy image: %v", err)
+		return
+	}
+
+	out, err := cobraTest(t, "image", "check", "--blobs", tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to check image: %v", err)
+		return
+	}
+	if out != "" {
+		t.Errorf("unexpected output: %v", out)
+	}
+
+	// remove any blob to break the image
+	blobDir := tmpDir + "/repo/blobs/sha256"
+	entries, err := os.ReadDir(blobDir)
+	if err != nil || len(entries) == 0 {
+		t.Errorf("failed to read blobs: %v", err)
+		return
+	}
+	err = os.Remove(blobDir + "/" + entries[0].Name())
+	if err != nil {
+		t.Errorf("failed to remove blob: %v", err)
+		return
+	}
+	out, err = cobraTest(t, "image", "check", tgtRef)
+	imageOpts = saveOpts
+	if err == nil {
+		t.Errorf("check did not fail")
+	}
+	if !strings.Contains(out, "missing sha256:"+entries[0].Name()) {
+		t.Errorf("missing blob not reported: %s", out)
+	}
+}
+
+func TestImageCopyDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcRef := "ocidir://../../testdata/testrepo:v1"
+	tgtRef := "ocidir://" + tmpDir + "/repo:v1"
+	saveOpts := imageOpts
+	out, err := cobraTest(t, "image", "copy", "--dry-run", srcRef, tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run dry-run: %v", err)
+		return
+	}
+	if !strings.Contains(out, "manifest sha256:") || !strings.Contains(out, "blob sha256:") || !strings.Contains(out, "total ") {
+		t.Errorf("unexpected output: %s", out)
+	}
+	if _, err := os.Stat(tmpDir + "/repo"); err == nil {
+		t.Errorf("dry-run created the target")
+	}
+	_, err = cobraTest(t, "image", "copy", srcRef, tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to copy image: %v", err)
+		return
+	}
+	out, err = cobraTest(t, "image", "copy", "--dry-run", "--format", "{{len .Manifests}} {{len .Blobs}} {{.Bytes}}", srcRef, tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run dry-run: %v", err)
+		return
+	}
+	if out != "0 0 0" {
+		t.Errorf("unexpected output after copy: %s", out)
+	}
+}
+
+func TestImageCopyResult(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcRef := "ocidir://../../testdata/testrepo:v1"
+	tgtRef := "ocidir://" + tmpDir + "/repo:v1"
+	saveOpts := imageOpts
+	out, err := cobraTest(t, "image", "copy", "--format", "{{.CommonName}} {{gt .Result.BlobsCopied 0}} {{gt .Result.BytesCopied 0}}", srcRef, tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to copy image: %v", err)
+		return
+	}
+	if out != tgtRef+" true true" {
+		t.Errorf("unexpected output: %s", out)
+	}
+	out, err = cobraTest(t, "image", "copy", "--format", "{{.Result.Unchanged}} {{.Result.BlobsCopied}}", srcRef, tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to copy image: %v", err)
+		return
+	}
+	if out != "true 0" {
+		t.Errorf("unexpected output after copy: %s", out)
+	}
+}
+
+func TestImageCopyScan(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcRef := "ocidir://../../testdata/testrepo:v1"
+	tgtRef := "ocidir://" + tmpDir + "/repo:v1"
+	saveOpts := imageOpts
+	tt := []struct {
+		name   string
+		args   []string
+		expErr error
+	}{
+		{
+			name:   "severity without scan",
+			args:   []string{"--scan-severity", "high"},
+			expErr: ErrMissingInput,
+		},
+		{
+			name:   "unknown scanner",
+			args:   []string{"--scan", "clair"},
+			expErr: types.ErrUnsupported,
+		},
+		{
+			name:   "invalid severity",
+			args:   []string{"--scan", "grype", "--scan-severity", "severe"},
+			expErr: types.ErrParsingFailed,
+		},
+		{
+			name:   "unsupported source",
+			args:   []string{"--scan", "grype", "--scan-severity", "high"},
+			expErr: types.ErrUnsupported,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append(append([]string{"image", "copy"}, tc.args...), srcRef, tgtRef)
+			_, err := cobraTest(t, args...)
+			imageOpts = saveOpts
+			if !errors.Is(err, tc.expErr) {
+				t.Errorf("unexpected error, expected %v, received %v", tc.expErr, err)
+			}
+		})
+	}
+	if _, err := os.Stat(tmpDir + "/repo"); err == nil {
+		t.Errorf("failed scan created the target")
+	}
+}
+
 func TestImageExportImport(t *testing.T) {
 	tmpDir := t.TempDir()
 	srcRef := "ocidir://../../testdata/testrepo:v2"
@@ -42,6 +202,59 @@ func TestImageExportImport(t *testing.T) {
 	if out != "" {
 		t.Errorf("unexpected output: %v", out)
 	}
+	out, err = cobraTest(t, "image", "export", "--platform", "linux/amd64", "--add", "ocidir://../../testdata/testrepo:v3", srcRef, exportFile)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run image export: %v", err)
+		return
+	}
+	if out != "" {
+		t.Errorf("unexpected output: %v", out)
+	}
+
+	out, err = cobraTest(t, "image", "import", "--name", "ocidir://../../testdata/testrepo:v3", importRefA, exportFile)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run image import: %v", err)
+		return
+	}
+	if out != "" {
+		t.Errorf("unexpected output: %v", out)
+	}
+
+	// pipe an export on stdout to an import on stdin
+	out, err = cobraTest(t, "image", "export", srcRef, "-")
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run image export: %v", err)
+		return
+	}
+	origIn := rootCmd.InOrStdin()
+	rootCmd.SetIn(bytes.NewBufferString(out))
+	defer rootCmd.SetIn(origIn)
+	importRefB := fmt.Sprintf("ocidir://%s/stdin:v2", tmpDir)
+	out, err = cobraTest(t, "image", "import", importRefB, "-")
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run image import from stdin: %v", err)
+		return
+	}
+	if out != "" {
+		t.Errorf("unexpected output: %v", out)
+	}
+	digSrc, err := cobraTest(t, "image", "digest", srcRef)
+	if err != nil {
+		t.Errorf("failed to get digest: %v", err)
+		return
+	}
+	digImport, err := cobraTest(t, "image", "digest", importRefB)
+	if err != nil {
+		t.Errorf("failed to get digest: %v", err)
+		return
+	}
+	if digSrc != digImport {
+		t.Errorf("digest mismatch after import from stdin, expected %s, received %s", digSrc, digImport)
+	}
 }
 
 func TestImageMod(t *testing.T) {
@@ -60,4 +273,268 @@ func TestImageMod(t *testing.T) {
 	if out == "" {
 		t.Errorf("missing output")
 	}
+
+	confRef := fmt.Sprintf("ocidir://%s/repo:conf", tmpDir)
+	_, err = cobraTest(t, "image", "mod", srcRef, "--create", confRef,
+		"--env", "HTTP_PROXY=http://proxy.example.org:3128", "--env", "PATH", "--entrypoint", `["/app", "serve"]`, "--cmd", "", "--user", "1000")
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run image mod: %v", err)
+		return
+	}
+	saveManifestOpts := manifestOpts
+	out, err = cobraTest(t, "image", "inspect", confRef, "--platform", "linux/amd64", "--format", "{{json .Config}}")
+	imageOpts = saveOpts
+	manifestOpts = saveManifestOpts
+	if err != nil {
+		t.Errorf("failed to get config: %v", err)
+		return
+	}
+	expect := `{"User":"1000","Env":["HTTP_PROXY=http://proxy.example.org:3128"],"Entrypoint":["/app","serve"]`
+	if !strings.HasPrefix(out, expect) {
+		t.Errorf("unexpected config, expected prefix %s, received %s", expect, out)
+	}
+	pruneRef := fmt.Sprintf("ocidir://%s/repo:prune", tmpDir)
+	_, err = cobraTest(t, "image", "mod", "ocidir://../../testdata/testrepo:v1", "--create", pruneRef, "--platform-keep", "linux/arm64")
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run image mod: %v", err)
+		return
+	}
+	out, err = cobraTest(t, "manifest", "get", pruneRef, "--format", "{{range .GetManifestList}}{{.Platform}} {{end}}")
+	manifestOpts = saveManifestOpts
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	if out != "linux/arm64 unknown/unknown" {
+		t.Errorf("unexpected platforms after prune: %s", out)
+	}
+	_, err = cobraTest(t, "image", "mod", srcRef, "--create", confRef, "--entrypoint", `["/app"`)
+	imageOpts = saveOpts
+	if err == nil || !strings.Contains(err.Error(), "JSON array") {
+		t.Errorf("unexpected error for an invalid entrypoint: %v", err)
+	}
+}
+
+func TestImagePin(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcRef := "ocidir://../../testdata/testrepo:v1"
+	saveOpts := imageOpts
+	saveManifestOpts := manifestOpts
+	dig, err := cobraTest(t, "image", "digest", srcRef)
+	manifestOpts = saveManifestOpts
+	if err != nil {
+		t.Errorf("failed to get digest: %v", err)
+		return
+	}
+
+	out, err := cobraTest(t, "image", "pin", srcRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run image pin: %v", err)
+		return
+	}
+	if out != srcRef+"@"+dig {
+		t.Errorf("unexpected output, expected %s@%s, received %s", srcRef, dig, out)
+	}
+
+	pinFile := tmpDir + "/Dockerfile"
+	content := "FROM " + srcRef + " as build\nFROM build\nFROM scratch\nCOPY --from=build /app /app\n"
+	err = os.WriteFile(pinFile, []byte(content), 0644)
+	if err != nil {
+		t.Errorf("failed to write file: %v", err)
+		return
+	}
+	_, err = cobraTest(t, "image", "pin", "--file", pinFile, "--write")
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to run image pin: %v", err)
+		return
+	}
+	b, err := os.ReadFile(pinFile)
+	if err != nil {
+		t.Errorf("failed to read file: %v", err)
+		return
+	}
+	expect := strings.Replace(content, srcRef, srcRef+"@"+dig, 1)
+	if string(b) != expect {
+		t.Errorf("unexpected file content, expected %s, received %s", expect, string(b))
+	}
+}
+
+func TestImageSize(t *testing.T) {
+	srcRef := "ocidir://../../testdata/testrepo:v1"
+	saveOpts := imageOpts
+	out, err := cobraTest(t, "image", "size", srcRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to get image size: %v", err)
+		return
+	}
+	if !strings.Contains(out, "linux/amd64") || !strings.Contains(out, "Total") {
+		t.Errorf("unexpected output: %s", out)
+	}
+	out, err = cobraTest(t, "image", "size", "--format", "{{.Total}}", srcRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to get image size: %v", err)
+		return
+	}
+	if out == "" || strings.Trim(out, "0123456789") != "" {
+		t.Errorf("unexpected total: %s", out)
+	}
+}
+
+func TestImageRetag(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcRef := "ocidir://" + tmpDir + "/repo:v1"
+	saveOpts := imageOpts
+	_, err := cobraTest(t, "image", "copy", "ocidir://../../testdata/testrepo:v1", srcRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to copy image: %v", err)
+		return
+	}
+	_, err = cobraTest(t, "image", "retag", srcRef, "latest")
+	if err != nil {
+		t.Errorf("failed to retag image: %v", err)
+		return
+	}
+	digSrc, err := cobraTest(t, "image", "digest", srcRef)
+	if err != nil {
+		t.Errorf("failed to get digest: %v", err)
+		return
+	}
+	digTgt, err := cobraTest(t, "image", "digest", "ocidir://"+tmpDir+"/repo:latest")
+	if err != nil {
+		t.Errorf("failed to get digest of new tag: %v", err)
+		return
+	}
+	if digSrc != digTgt {
+		t.Errorf("digest mismatch, expected %s, received %s", digSrc, digTgt)
+	}
+	_, err = cobraTest(t, "image", "retag", srcRef, "ocidir://"+tmpDir+"/other:v1")
+	if err == nil {
+		t.Errorf("retag to another ocidir did not fail")
+	}
+}
+
+func TestImageAttestation(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	rc := regclient.New()
+	rImg, err := ref.New("ocidir://../../testdata/testrepo:a1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	mImg, err := rc.ManifestHead(ctx, rImg, regclient.WithManifestRequireDigest())
+	if err != nil {
+		t.Fatalf("failed to head image: %v", err)
+	}
+	tgtRef := "ocidir://" + tmpDir + "/repo:v1"
+	r, err := ref.New(tgtRef)
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	err = rc.ImageCopy(ctx, rImg, r)
+	if err != nil {
+		t.Fatalf("failed to copy image: %v", err)
+	}
+	// push a buildkit style attestation for the image
+	predicateType := "https://slsa.dev/provenance/v0.2"
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"` + predicateType + `","predicate":{}}`)
+	dStatement, err := rc.BlobPut(ctx, r, types.Descriptor{}, bytes.NewReader(statement))
+	if err != nil {
+		t.Fatalf("failed to put statement: %v", err)
+	}
+	dStatement.MediaType = types.MediaTypeInToto
+	dStatement.Annotations = map[string]string{types.AnnotationInTotoPredicateType: predicateType}
+	dConf, err := rc.BlobPut(ctx, r, types.Descriptor{}, bytes.NewReader([]byte(`{}`)))
+	if err != nil {
+		t.Fatalf("failed to put config: %v", err)
+	}
+	dConf.MediaType = types.MediaTypeOCI1ImageConfig
+	mAtt, err := manifest.New(manifest.WithOrig(v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: types.MediaTypeOCI1Manifest,
+		Config:    dConf,
+		Layers:    []types.Descriptor{dStatement},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+	rAtt := r
+	rAtt.Tag = ""
+	rAtt.Digest = mAtt.GetDescriptor().Digest.String()
+	err = rc.ManifestPut(ctx, rAtt, mAtt, regclient.WithManifestChild())
+	if err != nil {
+		t.Fatalf("failed to put attestation: %v", err)
+	}
+	dImg := mImg.GetDescriptor()
+	dImg.Platform = &platform.Platform{OS: "linux", Architecture: "amd64"}
+	dAtt := mAtt.GetDescriptor()
+	dAtt.Platform = &platform.Platform{OS: "unknown", Architecture: "unknown"}
+	dAtt.Annotations = map[string]string{
+		types.AnnotationDockerReferenceType:   types.DockerReferenceTypeAttestation,
+		types.AnnotationDockerReferenceDigest: dImg.Digest.String(),
+	}
+	mIdx, err := manifest.New(manifest.WithOrig(v1.Index{
+		Versioned: v1.IndexSchemaVersion,
+		MediaType: types.MediaTypeOCI1ManifestList,
+		Manifests: []types.Descriptor{dImg, dAtt},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	err = rc.ManifestPut(ctx, r, mIdx)
+	if err != nil {
+		t.Fatalf("failed to put index: %v", err)
+	}
+
+	saveOpts := imageOpts
+	out, err := cobraTest(t, "image", "attestation", "list", tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Fatalf("failed to list attestations: %v", err)
+	}
+	if !strings.Contains(out, "linux/amd64") || !strings.Contains(out, predicateType) || !strings.Contains(out, dStatement.Digest.String()) {
+		t.Errorf("unexpected list output: %s", out)
+	}
+	out, err = cobraTest(t, "image", "attestation", "get", "--platform", "linux/amd64", "--predicate-type", predicateType, tgtRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Fatalf("failed to get attestation: %v", err)
+	}
+	if out != string(statement) {
+		t.Errorf("unexpected statement, expected %s, received %s", statement, out)
+	}
+	_, err = cobraTest(t, "image", "attestation", "get", "--platform", "linux/amd64", "--predicate-type", "https://spdx.dev/Document", tgtRef)
+	imageOpts = saveOpts
+	if !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected not found for a missing predicate type, received %v", err)
+	}
+}
+
+func TestImageHistory(t *testing.T) {
+	srcRef := "ocidir://../../testdata/testrepo:v1"
+	saveOpts := imageOpts
+	out, err := cobraTest(t, "image", "history", "--platform", "linux/amd64", srcRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to get image history: %v", err)
+		return
+	}
+	if !strings.Contains(out, "Created By") {
+		t.Errorf("unexpected output: %s", out)
+	}
+	out, err = cobraTest(t, "image", "history", "--platform", "linux/amd64", "--format", "{{len .History}}", srcRef)
+	imageOpts = saveOpts
+	if err != nil {
+		t.Errorf("failed to get image history: %v", err)
+		return
+	}
+	if out == "" || out == "0" || strings.Trim(out, "0123456789") != "" {
+		t.Errorf("unexpected history length: %s", out)
+	}
 }
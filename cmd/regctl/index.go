@@ -9,9 +9,7 @@ import (
 	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/pkg/template"
 	"github.com/regclient/regclient/types"
-	"github.com/regclient/regclient/types/docker/schema2"
 	"github.com/regclient/regclient/types/manifest"
-	v1 "github.com/regclient/regclient/types/oci/v1"
 	"github.com/regclient/regclient/types/platform"
 	"github.com/regclient/regclient/types/ref"
 	"github.com/spf13/cobra"
@@ -118,42 +116,20 @@ func runIndexAdd(cmd *cobra.Command, args []string) error {
 	}
 
 	// setup regclient
-	rc := newRegClient()
-	defer rc.Close(ctx, r)
-
-	// pull existing index
-	m, err := rc.ManifestGet(ctx, r)
-	if err != nil {
-		return err
-	}
-	mi, ok := m.(manifest.Indexer)
-	if !ok {
-		return fmt.Errorf("current manifest is not an index/manifest list, \"%s\": %w", m.GetDescriptor().MediaType, types.ErrUnsupportedMediaType)
-	}
-	curDesc, err := mi.GetManifestList()
+	rc, err := newRegClient()
 	if err != nil {
 		return err
 	}
+	defer rc.Close(ctx, r)
 
-	// generate a list of descriptors from CLI args
-	descList, err := indexBuildDescList(ctx, rc, r)
+	// generate a list of children from CLI args
+	children, iOpts, err := indexBuildChildren(ctx, rc, r)
 	if err != nil {
 		return err
 	}
 
-	// append list
-	curDesc = append(curDesc, descList...)
-	curDesc = indexDescListRmDup(curDesc)
-	err = mi.SetManifestList(curDesc)
-	if err != nil {
-		return err
-	}
-
-	// push the index
-	if r.Tag == "" && r.Digest != "" {
-		r.Digest = m.GetDescriptor().Digest.String()
-	}
-	err = rc.ManifestPut(ctx, r, m)
+	// add the children and push the index
+	m, err := rc.IndexAdd(ctx, r, children, iOpts...)
 	if err != nil {
 		return err
 	}
@@ -185,7 +161,10 @@ func runIndexCreate(cmd *cobra.Command, args []string) error {
 	}
 
 	// setup regclient
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	// parse annotations
@@ -199,14 +178,19 @@ func runIndexCreate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// generate a list of descriptors from CLI args
-	descList, err := indexBuildDescList(ctx, rc, r)
+	// generate a list of children from CLI args
+	children, iOpts, err := indexBuildChildren(ctx, rc, r)
 	if err != nil {
 		return err
 	}
-	descList = indexDescListRmDup(descList)
+	iOpts = append(iOpts,
+		regclient.IndexWithMediaType(indexOpts.mediaType),
+		regclient.IndexWithAnnotations(annotations),
+	)
+	if indexOpts.mediaType == types.MediaTypeOCI1ManifestList {
+		iOpts = append(iOpts, regclient.IndexWithArtifactType(indexOpts.artifactType))
+	}
 
-	var subj *types.Descriptor
 	if indexOpts.subject != "" && indexOpts.mediaType == types.MediaTypeOCI1ManifestList {
 		rSubj := r
 		rSubj.Tag = ""
@@ -223,45 +207,15 @@ func runIndexCreate(cmd *cobra.Command, args []string) error {
 		}
 		desc := mSubj.GetDescriptor()
 		desc.Annotations = nil
-		subj = &desc
-	}
-
-	// build the index
-	mOpts := []manifest.Opts{}
-	switch indexOpts.mediaType {
-	case types.MediaTypeOCI1ManifestList:
-		m := v1.Index{
-			Versioned:    v1.IndexSchemaVersion,
-			MediaType:    types.MediaTypeOCI1ManifestList,
-			ArtifactType: indexOpts.artifactType,
-			Manifests:    descList,
-			Subject:      subj,
-		}
-		if len(annotations) > 0 {
-			m.Annotations = annotations
-		}
-		mOpts = append(mOpts, manifest.WithOrig(m))
-	case types.MediaTypeDocker2ManifestList:
-		m := schema2.ManifestList{
-			Versioned: schema2.ManifestListSchemaVersion,
-			Manifests: descList,
-		}
-		if len(annotations) > 0 {
-			m.Annotations = annotations
-		}
-		mOpts = append(mOpts, manifest.WithOrig(m))
-	}
-	mm, err := manifest.New(mOpts...)
-	if err != nil {
-		return err
+		iOpts = append(iOpts, regclient.IndexWithSubject(desc))
 	}
 
-	// push the index
+	// build and push the index
 	if indexOpts.byDigest {
 		r.Tag = ""
-		r.Digest = mm.GetDescriptor().Digest.String()
+		r.Digest = ""
 	}
-	err = rc.ManifestPut(ctx, r, mm)
+	mm, err := rc.IndexCreate(ctx, r, children, iOpts...)
 	if err != nil {
 		return err
 	}
@@ -287,67 +241,33 @@ func runIndexDelete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// setup regclient
-	rc := newRegClient()
-	defer rc.Close(ctx, r)
-
-	// pull existing index
-	m, err := rc.ManifestGet(ctx, r)
-	if err != nil {
-		return err
-	}
-	mi, ok := m.(manifest.Indexer)
-	if !ok {
-		return fmt.Errorf("current manifest is not an index/manifest list, \"%s\": %w", m.GetDescriptor().MediaType, types.ErrUnsupportedMediaType)
-	}
-	curDesc, err := mi.GetManifestList()
-	if err != nil {
-		return err
-	}
-
-	// for each CLI arg, find and delete matching entries
-	for _, dig := range indexOpts.digests {
-		i := len(curDesc) - 1
-		for i >= 0 {
-			if curDesc[i].Digest.String() == dig {
-				if i < len(curDesc)-1 {
-					curDesc = append(curDesc[:i], curDesc[i+1:]...)
-				} else {
-					curDesc = curDesc[:i]
-				}
-			}
-			i--
+	// parse the entries to delete
+	digests := []digest.Digest{}
+	for _, dStr := range indexOpts.digests {
+		dig, err := digest.Parse(dStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse digest %s: %w", dStr, err)
 		}
+		digests = append(digests, dig)
 	}
-	for _, platStr := range indexOpts.platforms {
-		plat, err := platform.Parse(platStr)
+	platforms := []platform.Platform{}
+	for _, pStr := range indexOpts.platforms {
+		p, err := platform.Parse(pStr)
 		if err != nil {
 			return err
 		}
-		i := len(curDesc) - 1
-		for i >= 0 {
-			if curDesc[i].Platform != nil && platform.Match(plat, *curDesc[i].Platform) {
-				if i < len(curDesc)-1 {
-					curDesc = append(curDesc[:i], curDesc[i+1:]...)
-				} else {
-					curDesc = curDesc[:i]
-				}
-			}
-			i--
-		}
+		platforms = append(platforms, p)
 	}
 
-	// update manifest
-	err = mi.SetManifestList(curDesc)
+	// setup regclient
+	rc, err := newRegClient()
 	if err != nil {
 		return err
 	}
+	defer rc.Close(ctx, r)
 
-	// push the index
-	if r.Tag == "" && r.Digest != "" {
-		r.Digest = m.GetDescriptor().Digest.String()
-	}
-	err = rc.ManifestPut(ctx, r, m)
+	// remove the entries and push the index
+	m, err := rc.IndexRemove(ctx, r, digests, platforms)
 	if err != nil {
 		return err
 	}
@@ -364,7 +284,9 @@ func runIndexDelete(cmd *cobra.Command, args []string) error {
 	return template.Writer(cmd.OutOrStdout(), indexOpts.format, result)
 }
 
-func indexBuildDescList(ctx context.Context, rc *regclient.RegClient, r ref.Ref) ([]types.Descriptor, error) {
+// indexBuildChildren copies each ref from the CLI args into the target repository,
+// returning the list of children and options to add them to an index.
+func indexBuildChildren(ctx context.Context, rc *regclient.RegClient, r ref.Ref) ([]ref.Ref, []regclient.IndexOpts, error) {
 	imgCopyOpts := []regclient.ImageOpts{
 		regclient.ImageWithChild(),
 	}
@@ -375,36 +297,45 @@ func indexBuildDescList(ctx context.Context, rc *regclient.RegClient, r ref.Ref)
 		imgCopyOpts = append(imgCopyOpts, regclient.ImageWithReferrers())
 	}
 
-	descAnnotations := map[string]string{}
-	for _, a := range indexOpts.descAnnotations {
-		aSplit := strings.SplitN(a, "=", 2)
-		if len(aSplit) == 1 {
-			descAnnotations[aSplit[0]] = ""
-		} else {
-			descAnnotations[aSplit[0]] = aSplit[1]
+	iOpts := []regclient.IndexOpts{}
+	if len(indexOpts.descAnnotations) > 0 {
+		descAnnotations := map[string]string{}
+		for _, a := range indexOpts.descAnnotations {
+			aSplit := strings.SplitN(a, "=", 2)
+			if len(aSplit) == 1 {
+				descAnnotations[aSplit[0]] = ""
+			} else {
+				descAnnotations[aSplit[0]] = aSplit[1]
+			}
 		}
+		iOpts = append(iOpts, regclient.IndexWithDescAnnotations(descAnnotations))
+	}
+	if indexOpts.descPlatform != "" {
+		p, err := platform.Parse(indexOpts.descPlatform)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse platform %s: %w", indexOpts.descPlatform, err)
+		}
+		iOpts = append(iOpts, regclient.IndexWithDescPlatform(p))
 	}
 	platforms := []platform.Platform{}
 	for _, pStr := range indexOpts.platforms {
 		p, err := platform.Parse(pStr)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse platform %s: %w", pStr, err)
+			return nil, nil, fmt.Errorf("failed to parse platform %s: %w", pStr, err)
 		}
 		platforms = append(platforms, p)
 	}
 
 	// copy each ref by digest to the destination repository
-	if indexOpts.digests == nil {
-		indexOpts.digests = []string{}
-	}
+	digests := append([]string{}, indexOpts.digests...)
 	for _, rStr := range indexOpts.refs {
 		srcRef, err := ref.New(rStr)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		mCopy, err := rc.ManifestHead(ctx, srcRef, regclient.WithManifestRequireDigest())
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if !mCopy.IsList() || len(platforms) == 0 {
 			// single manifest
@@ -414,22 +345,22 @@ func indexBuildDescList(ctx context.Context, rc *regclient.RegClient, r ref.Ref)
 			tgtRef.Digest = desc.Digest.String()
 			err = rc.ImageCopy(ctx, srcRef, tgtRef, imgCopyOpts...)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			indexOpts.digests = append(indexOpts.digests, desc.Digest.String())
+			digests = append(digests, desc.Digest.String())
 		} else {
 			// platform specific descriptors are being extracted from a manifest list
 			mCopy, err = rc.ManifestGet(ctx, srcRef)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			mi, ok := mCopy.(manifest.Indexer)
 			if !ok {
-				return nil, fmt.Errorf("manifest list is not an Indexer")
+				return nil, nil, fmt.Errorf("manifest list is not an Indexer")
 			}
 			dl, err := mi.GetManifestList()
 			if err != nil {
-				return nil, fmt.Errorf("failed to get descriptor list: %w", err)
+				return nil, nil, fmt.Errorf("failed to get descriptor list: %w", err)
 			}
 			for _, d := range dl {
 				if d.Platform != nil && indexPlatformInList(*d.Platform, platforms) {
@@ -441,94 +372,23 @@ func indexBuildDescList(ctx context.Context, rc *regclient.RegClient, r ref.Ref)
 					tgtRef.Digest = d.Digest.String()
 					err = rc.ImageCopy(ctx, dRef, tgtRef, imgCopyOpts...)
 					if err != nil {
-						return nil, err
+						return nil, nil, err
 					}
-					indexOpts.digests = append(indexOpts.digests, d.Digest.String())
+					digests = append(digests, d.Digest.String())
 				}
 			}
 		}
 	}
 
-	// parse each digest, pull manifest, get config, append to list of descriptors
-	descList := []types.Descriptor{}
-	for _, dig := range indexOpts.digests {
+	// each digest is a child in the target repository
+	children := []ref.Ref{}
+	for _, dig := range digests {
 		rDig := r
 		rDig.Tag = ""
 		rDig.Digest = dig
-
-		mDig, err := rc.ManifestHead(ctx, rDig, regclient.WithManifestRequireDigest())
-		if err != nil {
-			return nil, err
-		}
-		desc := mDig.GetDescriptor()
-		plat := &platform.Platform{}
-		if indexOpts.descPlatform != "" {
-			*plat, err = platform.Parse(indexOpts.descPlatform)
-		} else {
-			plat, err = indexGetPlatform(ctx, rc, rDig, mDig)
-		}
-		if err == nil {
-			desc.Platform = plat
-		}
-		if len(descAnnotations) > 0 {
-			desc.Annotations = map[string]string{}
-		} else {
-			desc.Annotations = nil
-		}
-		for k, v := range descAnnotations {
-			desc.Annotations[k] = v
-		}
-		descList = append(descList, desc)
-	}
-	return descList, nil
-}
-
-func indexGetPlatform(ctx context.Context, rc *regclient.RegClient, r ref.Ref, m manifest.Manifest) (*platform.Platform, error) {
-	if mi, ok := m.(manifest.Imager); ok {
-		if !m.IsSet() {
-			// fetch the manifest if it wasn't already pulled
-			m, err := rc.ManifestGet(ctx, r)
-			if err != nil {
-				return nil, err
-			}
-			if mi, ok = m.(manifest.Imager); !ok {
-				return nil, nil
-			}
-		}
-		cd, err := mi.GetConfig()
-		if err != nil {
-			return nil, err
-		}
-		blobConfig, err := rc.BlobGetOCIConfig(ctx, r, cd)
-		if err != nil {
-			return nil, err
-		}
-		ociConfig := blobConfig.GetConfig()
-		if ociConfig.OS == "" {
-			return nil, nil
-		}
-		return &ociConfig.Platform, nil
-	}
-	return nil, nil
-}
-
-func indexDescListRmDup(dl []types.Descriptor) []types.Descriptor {
-	i := 0
-	for i < len(dl)-1 {
-		j := len(dl) - 1
-		for j > i {
-			if dl[i].Equal(dl[j]) {
-				if j < len(dl)-1 {
-					dl = append(dl[:j], dl[j+1:]...)
-				} else {
-					dl = dl[:j]
-				}
-			}
-			j--
-		}
-		i++
+		children = append(children, rDig)
 	}
-	return dl
+	return children, iOpts, nil
 }
 
 func indexPlatformInList(p platform.Platform, pl []platform.Platform) bool {
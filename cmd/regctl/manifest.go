@@ -3,9 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
 	"github.com/regclient/regclient"
@@ -67,10 +67,14 @@ var manifestHeadCmd = &cobra.Command{
 }
 
 var manifestPutCmd = &cobra.Command{
-	Use:               "put <image_ref>",
-	Aliases:           []string{"push"},
-	Short:             "push manifest or manifest list",
-	Long:              `Pushes a manifest or manifest list to a repository.`,
+	Use:     "put <image_ref>",
+	Aliases: []string{"push"},
+	Short:   "push manifest or manifest list",
+	Long: `Pushes a manifest or manifest list read from stdin to a repository.
+The manifest must be valid JSON with a supported media type, and every manifest
+and blob it references must already exist in the repository. The missing
+content is listed when this check fails, disable it with --check-children=false.
+The digest of the pushed manifest is output after confirming it with the registry.`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: completeArgTag,
 	RunE:              runManifestPut,
@@ -78,11 +82,14 @@ var manifestPutCmd = &cobra.Command{
 
 var manifestOpts struct {
 	byDigest      bool
+	checkChildren bool
+	childSource   string
 	contentType   string
 	diffCtx       int
 	diffFullCtx   bool
 	forceTagDeref bool
 	formatGet     string
+	ignoreMissing bool
 	formatHead    string
 	formatPut     string
 	list          bool
@@ -94,6 +101,7 @@ var manifestOpts struct {
 
 func init() {
 	manifestDeleteCmd.Flags().BoolVarP(&manifestOpts.forceTagDeref, "force-tag-dereference", "", false, "Dereference the a tag to a digest, this is unsafe")
+	manifestDeleteCmd.Flags().BoolVarP(&manifestOpts.ignoreMissing, "ignore-missing", "", false, "Ignore errors if the manifest is already deleted")
 	manifestDeleteCmd.Flags().BoolVarP(&manifestOpts.referrers, "referrers", "", false, "Check for referrers, recommended when deleting artifacts")
 
 	manifestDiffCmd.Flags().IntVarP(&manifestOpts.diffCtx, "context", "", 3, "Lines of context")
@@ -116,9 +124,12 @@ func init() {
 	manifestGetCmd.Flags().MarkHidden("list")
 
 	manifestPutCmd.Flags().BoolVarP(&manifestOpts.byDigest, "by-digest", "", false, "Push manifest by digest instead of tag")
+	manifestPutCmd.Flags().BoolVarP(&manifestOpts.checkChildren, "check-children", "", true, "Verify referenced manifests and blobs exist before pushing")
+	manifestPutCmd.Flags().StringVarP(&manifestOpts.childSource, "child-source", "", "", "Copy missing manifests and blobs from a source repository")
 	manifestPutCmd.Flags().StringVarP(&manifestOpts.contentType, "content-type", "t", "", "Specify content-type (e.g. application/vnd.docker.distribution.manifest.v2+json)")
+	manifestPutCmd.RegisterFlagCompletionFunc("child-source", completeArgTag)
 	manifestPutCmd.RegisterFlagCompletionFunc("content-type", completeArgMediaTypeManifest)
-	manifestPutCmd.Flags().StringVarP(&manifestOpts.formatPut, "format", "", "", "Format output with go template syntax")
+	manifestPutCmd.Flags().StringVarP(&manifestOpts.formatPut, "format", "", "{{ printf \"%s\\n\" .Manifest.GetDescriptor.Digest }}", "Format output with go template syntax")
 
 	manifestCmd.AddCommand(manifestDeleteCmd)
 	manifestCmd.AddCommand(manifestDiffCmd)
@@ -210,12 +221,20 @@ func runManifestDelete(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	if r.Digest == "" && manifestOpts.forceTagDeref {
 		m, err := rc.ManifestHead(ctx, r, regclient.WithManifestRequireDigest())
-		if err != nil {
+		if err != nil && manifestOpts.ignoreMissing && errors.Is(err, types.ErrNotFound) {
+			log.WithFields(logrus.Fields{
+				"tag": r.Tag,
+			}).Debug("Manifest already deleted")
+			return nil
+		} else if err != nil {
 			return err
 		}
 		r.Digest = manifest.GetDigest(m).String()
@@ -231,6 +250,9 @@ func runManifestDelete(cmd *cobra.Command, args []string) error {
 		"digest": r.Digest,
 	}).Debug("Manifest delete")
 	mOpts := []regclient.ManifestOpts{}
+	if manifestOpts.ignoreMissing {
+		mOpts = append(mOpts, regclient.WithManifestIgnoreMissing())
+	}
 	if manifestOpts.referrers {
 		mOpts = append(mOpts, regclient.WithManifestCheckReferrers())
 	}
@@ -260,7 +282,10 @@ func runManifestDiff(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 
 	log.WithFields(logrus.Fields{
 		"ref1": r1.CommonName(),
@@ -305,7 +330,10 @@ func runManifestHead(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 
 	log.WithFields(logrus.Fields{
 		"host": r.Registry,
@@ -367,7 +395,10 @@ func runManifestGet(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
 	m, err := getManifest(ctx, rc, r)
@@ -392,13 +423,28 @@ func runManifestPut(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 
-	raw, err := io.ReadAll(os.Stdin)
+	raw, err := io.ReadAll(cmd.InOrStdin())
 	if err != nil {
 		return err
 	}
+	if !json.Valid(raw) {
+		return fmt.Errorf("manifest is not valid json%.0w", ErrInvalidInput)
+	}
+	if manifestOpts.contentType != "" {
+		mt := struct {
+			MediaType string `json:"mediaType,omitempty"`
+		}{}
+		_ = json.Unmarshal(raw, &mt)
+		if mt.MediaType != "" && mt.MediaType != manifestOpts.contentType {
+			return fmt.Errorf("content type %s does not match the manifest media type %s%.0w", manifestOpts.contentType, mt.MediaType, ErrInvalidInput)
+		}
+	}
 	opts := []manifest.Opts{
 		manifest.WithRef(r),
 		manifest.WithRaw(raw),
@@ -417,18 +463,34 @@ func runManifestPut(cmd *cobra.Command, args []string) error {
 		r.Digest = rcM.GetDescriptor().Digest.String()
 	}
 
-	err = rc.ManifestPut(ctx, r, rcM)
+	putOpts := []regclient.ManifestOpts{}
+	if manifestOpts.childSource != "" {
+		rSrc, err := ref.New(manifestOpts.childSource)
+		if err != nil {
+			return err
+		}
+		defer rc.Close(ctx, rSrc)
+		putOpts = append(putOpts, regclient.WithManifestChildSource(rSrc))
+	} else if manifestOpts.checkChildren {
+		putOpts = append(putOpts, regclient.WithManifestCheckChildren())
+	}
+	err = rc.ManifestPut(ctx, r, rcM, putOpts...)
 	if err != nil {
 		return err
 	}
+	// confirm the registry stored the manifest with the same digest
+	mh, err := rc.ManifestHead(ctx, r, regclient.WithManifestRequireDigest())
+	if err != nil {
+		return fmt.Errorf("failed to confirm manifest digest: %w", err)
+	}
+	if rcM.GetDescriptor().Digest != "" && mh.GetDescriptor().Digest != rcM.GetDescriptor().Digest {
+		return fmt.Errorf("registry digest %s does not match the pushed manifest %s%.0w", mh.GetDescriptor().Digest.String(), rcM.GetDescriptor().Digest.String(), types.ErrDigestMismatch)
+	}
 
 	result := struct {
 		Manifest manifest.Manifest
 	}{
 		Manifest: rcM,
 	}
-	if manifestOpts.byDigest && manifestOpts.formatPut == "" {
-		manifestOpts.formatPut = "{{ printf \"%s\\n\" .Manifest.GetDescriptor.Digest }}"
-	}
 	return template.Writer(cmd.OutOrStdout(), manifestOpts.formatPut, result)
 }
@@ -3,9 +3,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient/types"
 )
 
@@ -74,3 +76,103 @@ func TestManifestHead(t *testing.T) {
 	}
 
 }
+
+func TestManifestGetRaw(t *testing.T) {
+	saveManifestOpts := manifestOpts
+	srcRef := "ocidir://../../testdata/testrepo:v1"
+	dig, err := cobraTest(t, "manifest", "head", srcRef)
+	manifestOpts = saveManifestOpts
+	if err != nil {
+		t.Errorf("failed to head manifest: %v", err)
+		return
+	}
+	d, err := digest.Parse(dig)
+	if err != nil {
+		t.Errorf("failed to parse digest %s: %v", dig, err)
+		return
+	}
+	expect, err := os.ReadFile("../../testdata/testrepo/blobs/" + d.Algorithm().String() + "/" + d.Encoded())
+	if err != nil {
+		t.Errorf("failed to read manifest: %v", err)
+		return
+	}
+	out, err := cobraTest(t, "manifest", "get", "--format", "raw-body", srcRef)
+	manifestOpts = saveManifestOpts
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	if out != strings.TrimSpace(string(expect)) {
+		t.Errorf("raw body was modified, expected %s, received %s", string(expect), out)
+	}
+}
+
+func TestManifestPut(t *testing.T) {
+	saveManifestOpts := manifestOpts
+	tmpDir := t.TempDir()
+	srcRef := "ocidir://../../testdata/testrepo:v1"
+	tgtRef := "ocidir://" + tmpDir + "/repo"
+	_, err := cobraTest(t, "image", "copy", srcRef, tgtRef+":v1")
+	if err != nil {
+		t.Fatalf("failed to copy image: %v", err)
+	}
+	raw, err := cobraTest(t, "manifest", "get", "--format", "raw-body", srcRef)
+	manifestOpts = saveManifestOpts
+	if err != nil {
+		t.Fatalf("failed to get manifest: %v", err)
+	}
+	d := digest.FromString(raw)
+	origIn := rootCmd.InOrStdin()
+	defer rootCmd.SetIn(origIn)
+	tt := []struct {
+		name      string
+		args      []string
+		in        string
+		expectErr error
+		expectOut string
+	}{
+		{
+			name:      "Put",
+			args:      []string{"manifest", "put", tgtRef + ":v2"},
+			in:        raw,
+			expectOut: d.String(),
+		},
+		{
+			name:      "Invalid json",
+			args:      []string{"manifest", "put", tgtRef + ":v3"},
+			in:        raw[:len(raw)/2],
+			expectErr: ErrInvalidInput,
+		},
+		{
+			name:      "Content type mismatch",
+			args:      []string{"manifest", "put", "--content-type", types.MediaTypeDocker2Manifest, tgtRef + ":v3"},
+			in:        raw,
+			expectErr: ErrInvalidInput,
+		},
+		{
+			name:      "Missing children",
+			args:      []string{"manifest", "put", "ocidir://" + tmpDir + "/empty:v1"},
+			in:        raw,
+			expectErr: types.ErrNotFound,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rootCmd.SetIn(strings.NewReader(tc.in))
+			out, err := cobraTest(t, tc.args...)
+			manifestOpts = saveManifestOpts
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("unexpected error, expected %v, received %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out != tc.expectOut {
+				t.Errorf("unexpected output, expected %s, received %s", tc.expectOut, out)
+			}
+		})
+	}
+}
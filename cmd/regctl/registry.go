@@ -2,15 +2,25 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/conformance"
+	"github.com/regclient/regclient/internal/rematch"
+	"github.com/regclient/regclient/internal/timejson"
+	"github.com/regclient/regclient/pkg/template"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types/ref"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -29,6 +39,62 @@ in the output (e.g. passwords, tokens, and TLS keys).`,
 	ValidArgsFunction: registryArgListReg,
 	RunE:              runRegistryConfig,
 }
+var registryConformanceCmd = &cobra.Command{
+	Use:   "conformance <repository>",
+	Short: "check the features supported by a registry",
+	Long: `Push, pull, list, and delete generated content in a repository to report
+which distribution-spec features the registry supports. Content is pushed to a
+unique tag and deleted when the checks finish, unless --no-delete is set.
+The command fails when any check fails.`,
+	Example: `
+# check a local registry
+regctl registry conformance localhost:5000/conformance`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgNone,
+	RunE:              runRegistryConformance,
+}
+var registryCopyCmd = &cobra.Command{
+	Use:     "copy <source_registry> <target>",
+	Aliases: []string{"cp", "migrate"},
+	Short:   "copy every repository in a registry",
+	Long: `Copy each repository listed in the source registry catalog to the target.
+The target is a registry, a registry with a path prefix, or an OCI Layout
+directory (ocidir://dir) where each repository is a subdirectory.
+Use --include and --exclude to filter the repositories, and --map to rename a
+repository prefix (old=new) in the target. With --checkpoint, each completed
+repository is recorded in the file, and repeating the command skips those
+repositories to resume an interrupted copy. A failed repository does not stop
+the copy, but the command fails after writing the report.
+The source registry must support the catalog API.`,
+	Example: `
+# copy every repository to a new registry
+regctl registry copy old-registry.example.com new-registry.example.com
+
+# move the team repositories under a new prefix, resuming from a checkpoint
+regctl registry copy old-registry.example.com new-registry.example.com \
+  --include 'team/.*' --map team=org/team --checkpoint migrate.json`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: registryArgListReg,
+	RunE:              runRegistryCopy,
+}
+var registryExtCmd = &cobra.Command{
+	Use:     "ext <registry|repository>",
+	Aliases: []string{"extensions"},
+	Short:   "list extensions supported by a registry",
+	Long: `Lists the distribution-spec extensions advertised by the registry with the
+extensions discovery API (_oci/ext/discover). Include a repository to list the
+extensions available for that repository. An empty list is output when the
+registry does not support extension discovery.`,
+	Example: `
+# list the extensions of a registry
+regctl registry ext registry.example.com
+
+# list the extensions of a repository
+regctl registry ext registry.example.com/repo`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: registryArgListReg,
+	RunE:              runRegistryExt,
+}
 var registryLoginCmd = &cobra.Command{
 	Use:   "login <registry>",
 	Short: "login to a registry",
@@ -67,14 +133,51 @@ var registryOpts struct {
 	priority             uint
 	repoAuth             bool
 	blobChunk, blobMax   int64
+	blobRate             int64
 	reqPerSec            float64
 	reqConcurrent        int64
+	maxIdleConns         int
+	idleTimeout          time.Duration
+	keepAlive            time.Duration
+	disableHTTP2         bool
+	digestOnly           bool
+	proxy                string
+	resolve              []string
+	manifestAccept       []string
+	api                  string
 	apiOpts              []string
+	format               string // conformance opts
+	noDelete             bool
+	layerSize            int64
+	checkpoint           string // copy opts
+	digestTags           bool
+	exclude, include     []string
+	formatCopy           string
+	maps                 []string
+	referrers            bool
 	scheme               string   // TODO: remove
 	dns                  []string // TODO: remove
 }
 
 func init() {
+	registryConformanceCmd.Flags().StringVarP(&registryOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
+	registryConformanceCmd.Flags().BoolVarP(&registryOpts.noDelete, "no-delete", "", false, "Skip the delete checks, leaving the pushed content in the repository")
+	registryConformanceCmd.Flags().Int64VarP(&registryOpts.layerSize, "layer-size", "", 1024, "Size of the generated layer")
+	registryConformanceCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+	registryConformanceCmd.RegisterFlagCompletionFunc("layer-size", completeArgNone)
+
+	registryCopyCmd.Flags().StringVarP(&registryOpts.checkpoint, "checkpoint", "", "", "File to track completed repositories and resume the copy")
+	registryCopyCmd.Flags().BoolVarP(&registryOpts.digestTags, "digest-tags", "", false, "Include digest tags (\"sha256-<digest>.*\") when copying manifests")
+	registryCopyCmd.Flags().StringArrayVarP(&registryOpts.exclude, "exclude", "", []string{}, "Regexp of repositories to exclude")
+	registryCopyCmd.Flags().StringVarP(&registryOpts.formatCopy, "format", "", "{{printPretty .}}", "Format output with go template syntax")
+	registryCopyCmd.Flags().StringArrayVarP(&registryOpts.include, "include", "", []string{}, "Regexp of repositories to include")
+	registryCopyCmd.Flags().StringArrayVarP(&registryOpts.maps, "map", "", []string{}, "Rename a repository prefix in the target (old=new), the first match is used")
+	registryCopyCmd.Flags().BoolVarP(&registryOpts.referrers, "referrers", "", false, "Include referrers")
+	registryCopyCmd.RegisterFlagCompletionFunc("exclude", completeArgNone)
+	registryCopyCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+	registryCopyCmd.RegisterFlagCompletionFunc("include", completeArgNone)
+	registryCopyCmd.RegisterFlagCompletionFunc("map", completeArgNone)
+
 	registryLoginCmd.Flags().StringVarP(&registryOpts.user, "user", "u", "", "Username")
 	registryLoginCmd.Flags().StringVarP(&registryOpts.pass, "pass", "p", "", "Password")
 	registryLoginCmd.Flags().BoolVarP(&registryOpts.passStdin, "pass-stdin", "", false, "Read password from stdin")
@@ -93,8 +196,18 @@ func init() {
 	registrySetCmd.Flags().BoolVarP(&registryOpts.repoAuth, "repo-auth", "", false, "Separate auth requests per repository instead of per registry")
 	registrySetCmd.Flags().Int64VarP(&registryOpts.blobChunk, "blob-chunk", "", 0, "Blob chunk size")
 	registrySetCmd.Flags().Int64VarP(&registryOpts.blobMax, "blob-max", "", 0, "Blob size before switching to chunked push, -1 to disable")
+	registrySetCmd.Flags().Int64VarP(&registryOpts.blobRate, "blob-rate", "", 0, "Bandwidth limit for blob transfers in bytes per second")
 	registrySetCmd.Flags().Float64VarP(&registryOpts.reqPerSec, "req-per-sec", "", 0, "Requests per second")
 	registrySetCmd.Flags().Int64VarP(&registryOpts.reqConcurrent, "req-concurrent", "", 0, "Concurrent requests")
+	registrySetCmd.Flags().IntVarP(&registryOpts.maxIdleConns, "max-idle-conns", "", 0, "Maximum idle connections to keep open")
+	registrySetCmd.Flags().DurationVarP(&registryOpts.idleTimeout, "idle-timeout", "", 0, "Time before closing an idle connection")
+	registrySetCmd.Flags().DurationVarP(&registryOpts.keepAlive, "keep-alive", "", 0, "TCP keepalive interval, negative to disable")
+	registrySetCmd.Flags().BoolVarP(&registryOpts.digestOnly, "digest-only", "", false, "Only pull manifests by digest and skip credentials, for public mirrors")
+	registrySetCmd.Flags().BoolVarP(&registryOpts.disableHTTP2, "disable-http2", "", false, "Force HTTP/1.1 connections")
+	registrySetCmd.Flags().StringVarP(&registryOpts.proxy, "proxy", "", "", "Proxy url (http, https, or socks5), \"direct\" to ignore environment proxy settings")
+	registrySetCmd.Flags().StringArrayVarP(&registryOpts.resolve, "resolve", "", nil, "Address (ip or ip:port) to connect to in place of the hostname")
+	registrySetCmd.Flags().StringArrayVarP(&registryOpts.manifestAccept, "manifest-accept", "", nil, "Media type to accept when pulling manifests, replaces the default list")
+	registrySetCmd.Flags().StringVarP(&registryOpts.api, "api", "", "", "API profile for registry quirks (hub, ecr-public, ghcr, artifactory, harbor, quay)")
 	registrySetCmd.Flags().StringArrayVarP(&registryOpts.apiOpts, "api-opts", "", nil, "List of options (key=value))")
 	registrySetCmd.RegisterFlagCompletionFunc("cacert", completeArgNone)
 	registrySetCmd.RegisterFlagCompletionFunc("tls", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -104,6 +217,16 @@ func init() {
 			"disabled",
 		}, cobra.ShellCompDirectiveNoFileComp
 	})
+	registrySetCmd.RegisterFlagCompletionFunc("api", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{
+			config.APIHub,
+			config.APIECRPublic,
+			config.APIGHCR,
+			config.APIArtifactory,
+			config.APIHarbor,
+			config.APIQuay,
+		}, cobra.ShellCompDirectiveNoFileComp
+	})
 	registrySetCmd.RegisterFlagCompletionFunc("hostname", completeArgNone)
 	registrySetCmd.RegisterFlagCompletionFunc("path-prefix", completeArgNone)
 	registrySetCmd.RegisterFlagCompletionFunc("mirror", completeArgNone)
@@ -111,6 +234,9 @@ func init() {
 	registrySetCmd.RegisterFlagCompletionFunc("blob-chunk", completeArgNone)
 	registrySetCmd.RegisterFlagCompletionFunc("blob-max", completeArgNone)
 
+	registryExtCmd.Flags().StringVarP(&registryOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
+	registryExtCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
 	// TODO: eventually remove
 	registrySetCmd.Flags().StringVarP(&registryOpts.scheme, "scheme", "", "", "[Deprecated] Scheme (http, https)")
 	registrySetCmd.Flags().StringArrayVarP(&registryOpts.dns, "dns", "", nil, "[Deprecated] DNS hostname or ip with port")
@@ -118,6 +244,9 @@ func init() {
 	registrySetCmd.Flags().MarkHidden("dns")
 
 	registryCmd.AddCommand(registryConfigCmd)
+	registryCmd.AddCommand(registryConformanceCmd)
+	registryCmd.AddCommand(registryCopyCmd)
+	registryCmd.AddCommand(registryExtCmd)
 	registryCmd.AddCommand(registryLoginCmd)
 	registryCmd.AddCommand(registryLogoutCmd)
 	registryCmd.AddCommand(registrySetCmd)
@@ -151,7 +280,8 @@ func runRegistryConfig(cmd *cobra.Command, args []string) error {
 	}
 	var hj []byte
 	if len(args) > 0 {
-		h, ok := c.Hosts[args[0]]
+		// lookup by the host name, the argument may include a scheme or path
+		h, ok := c.Hosts[config.HostNewName(args[0]).Name]
 		if !ok {
 			log.WithFields(logrus.Fields{
 				"registry": args[0],
@@ -173,6 +303,273 @@ func runRegistryConfig(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runRegistryConformance(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"repository": r.CommonName(),
+		"noDelete":   registryOpts.noDelete,
+	}).Debug("Running conformance checks")
+	opts := []conformance.Opts{conformance.WithLayerSize(registryOpts.layerSize)}
+	if registryOpts.noDelete {
+		opts = append(opts, conformance.WithoutDelete())
+	}
+	report := conformance.Run(ctx, rc, r, opts...)
+	err = template.Writer(cmd.OutOrStdout(), registryOpts.format, report)
+	if err != nil {
+		return err
+	}
+	if report.Failed() {
+		return ErrConformanceFailed
+	}
+	return nil
+}
+
+// registryCopyReport is the output of a registry copy
+type registryCopyReport struct {
+	Source  string                     `json:"source"`
+	Target  string                     `json:"target"`
+	Copied  []regclient.RepoCopyReport `json:"copied"`
+	Skipped []string                   `json:"skipped"`
+	Failed  []registryCopyFailure      `json:"failed"`
+}
+
+// registryCopyFailure is a repository that could not be copied
+type registryCopyFailure struct {
+	Repository string `json:"repository"`
+	Error      string `json:"error"`
+}
+
+// registryCopyCheckpoint records the completed repositories to resume a registry copy
+type registryCopyCheckpoint struct {
+	Source    string   `json:"source"`
+	Target    string   `json:"target"`
+	Completed []string `json:"completed"`
+}
+
+// registryCopyMap renames a repository prefix
+type registryCopyMap struct {
+	from, to string
+}
+
+func runRegistryCopy(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	src := strings.TrimSuffix(args[0], "/")
+	tgt := strings.TrimSuffix(args[1], "/")
+	// parse the source as a repository to validate the registry name
+	rSrc, err := ref.New(src + "/repo")
+	if err != nil {
+		return err
+	}
+	if rSrc.Scheme != "reg" || rSrc.Repository != "repo" {
+		return fmt.Errorf("source must be a registry name: %s%.0w", args[0], ErrInvalidInput)
+	}
+	reInclude, err := repoRegexp(registryOpts.include)
+	if err != nil {
+		return err
+	}
+	reExclude, err := repoRegexp(registryOpts.exclude)
+	if err != nil {
+		return err
+	}
+	maps := []registryCopyMap{}
+	for _, m := range registryOpts.maps {
+		from, to, ok := strings.Cut(m, "=")
+		to = strings.Trim(to, "/")
+		if !ok || to == "" {
+			return fmt.Errorf("map must be in the format old=new: %s%.0w", m, ErrInvalidInput)
+		}
+		maps = append(maps, registryCopyMap{from: strings.Trim(from, "/"), to: to})
+	}
+	rcOpts := []regclient.RepoCopyOpts{}
+	if registryOpts.digestTags {
+		rcOpts = append(rcOpts, regclient.RepoCopyWithImageOpts(regclient.ImageWithDigestTags()))
+	}
+	if registryOpts.referrers {
+		rcOpts = append(rcOpts, regclient.RepoCopyWithImageOpts(regclient.ImageWithReferrers()))
+	}
+
+	cp := registryCopyCheckpoint{Source: src, Target: tgt, Completed: []string{}}
+	if registryOpts.checkpoint != "" {
+		b, err := os.ReadFile(registryOpts.checkpoint)
+		if err == nil {
+			err = json.Unmarshal(b, &cp)
+			if err != nil {
+				return fmt.Errorf("failed to parse checkpoint %s: %w", registryOpts.checkpoint, err)
+			}
+			if cp.Source != src || cp.Target != tgt {
+				return fmt.Errorf("checkpoint %s is for a copy from %s to %s%.0w", registryOpts.checkpoint, cp.Source, cp.Target, ErrInvalidInput)
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read checkpoint %s: %w", registryOpts.checkpoint, err)
+		}
+	}
+	completed := map[string]bool{}
+	for _, repo := range cp.Completed {
+		completed[repo] = true
+	}
+
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	repos, err := registryCopyList(ctx, rc, rSrc.Registry)
+	if err != nil {
+		return err
+	}
+	report := registryCopyReport{
+		Source:  src,
+		Target:  tgt,
+		Copied:  []regclient.RepoCopyReport{},
+		Skipped: []string{},
+		Failed:  []registryCopyFailure{},
+	}
+	for _, repo := range repos {
+		if !rematch.Match(repo, reInclude, reExclude) {
+			continue
+		}
+		if completed[repo] {
+			report.Skipped = append(report.Skipped, repo)
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tgtRepo := repo
+		for _, m := range maps {
+			if m.from == "" || repo == m.from || strings.HasPrefix(repo, m.from+"/") {
+				tgtRepo = strings.Trim(m.to+"/"+strings.TrimPrefix(strings.TrimPrefix(repo, m.from), "/"), "/")
+				break
+			}
+		}
+		log.WithFields(logrus.Fields{
+			"source": src + "/" + repo,
+			"target": tgt + "/" + tgtRepo,
+		}).Info("Copying repository")
+		rcr, err := registryCopyRepo(ctx, rc, src+"/"+repo, tgt+"/"+tgtRepo, rcOpts)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"repository": repo,
+				"err":        err,
+			}).Warn("Failed to copy repository")
+			report.Failed = append(report.Failed, registryCopyFailure{Repository: repo, Error: err.Error()})
+			continue
+		}
+		report.Copied = append(report.Copied, rcr)
+		if registryOpts.checkpoint != "" {
+			cp.Completed = append(cp.Completed, repo)
+			err = registryCopyCheckpointSave(registryOpts.checkpoint, cp)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = template.Writer(cmd.OutOrStdout(), registryOpts.formatCopy, report)
+	if err != nil {
+		return err
+	}
+	if len(report.Failed) > 0 {
+		return ErrRegistryCopyFailed
+	}
+	return nil
+}
+
+// registryCopyList returns every repository in the registry catalog, following each page
+func registryCopyList(ctx context.Context, rc *regclient.RegClient, registry string) ([]string, error) {
+	repos := []string{}
+	last := ""
+	for {
+		opts := []scheme.RepoOpts{}
+		if last != "" {
+			opts = append(opts, scheme.WithRepoLast(last))
+		}
+		rl, err := rc.RepoList(ctx, registry, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories in %s: %w", registry, err)
+		}
+		page, err := rl.GetRepos()
+		if err != nil {
+			return nil, err
+		}
+		// stop when the registry returns an empty page or repeats the last page
+		if len(page) == 0 || page[len(page)-1] == last {
+			return repos, nil
+		}
+		for _, repo := range page {
+			if repo > last || last == "" {
+				repos = append(repos, repo)
+			}
+		}
+		last = page[len(page)-1]
+	}
+}
+
+func registryCopyRepo(ctx context.Context, rc *regclient.RegClient, src, tgt string, rcOpts []regclient.RepoCopyOpts) (regclient.RepoCopyReport, error) {
+	rSrc, err := ref.New(src)
+	if err != nil {
+		return regclient.RepoCopyReport{}, err
+	}
+	rTgt, err := ref.New(tgt)
+	if err != nil {
+		return regclient.RepoCopyReport{}, err
+	}
+	defer rc.Close(ctx, rTgt)
+	return rc.RepoCopy(ctx, rSrc, rTgt, rcOpts...)
+}
+
+// registryCopyCheckpointSave replaces the checkpoint file so an interrupted write does not lose the progress
+func registryCopyCheckpointSave(filename string, cp registryCopyCheckpoint) error {
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", filename, err)
+	}
+	err = os.Rename(tmp, filename)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", filename, err)
+	}
+	return nil
+}
+
+func runRegistryExt(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	var r ref.Ref
+	if strings.Contains(args[0], "/") {
+		var err error
+		r, err = ref.New(args[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		r = ref.Ref{Scheme: "reg", Registry: config.HostNewName(args[0]).Name}
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"registry":   r.Registry,
+		"repository": r.Repository,
+	}).Debug("Extension discovery")
+	el, err := rc.ExtensionList(ctx, r)
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), registryOpts.format, el)
+}
+
 func runRegistryLogin(cmd *cobra.Command, args []string) error {
 	c, err := ConfigLoadDefault()
 	if err != nil {
@@ -353,12 +750,42 @@ func runRegistrySet(cmd *cobra.Command, args []string) error {
 	if flagChanged(cmd, "blob-max") {
 		h.BlobMax = registryOpts.blobMax
 	}
+	if flagChanged(cmd, "blob-rate") {
+		h.BlobRate = registryOpts.blobRate
+	}
 	if flagChanged(cmd, "req-per-sec") {
 		h.ReqPerSec = registryOpts.reqPerSec
 	}
 	if flagChanged(cmd, "req-concurrent") {
 		h.ReqConcurrent = registryOpts.reqConcurrent
 	}
+	if flagChanged(cmd, "max-idle-conns") {
+		h.MaxIdleConns = registryOpts.maxIdleConns
+	}
+	if flagChanged(cmd, "idle-timeout") {
+		h.IdleTimeout = timejson.Duration(registryOpts.idleTimeout)
+	}
+	if flagChanged(cmd, "keep-alive") {
+		h.KeepAlive = timejson.Duration(registryOpts.keepAlive)
+	}
+	if flagChanged(cmd, "digest-only") {
+		h.DigestOnly = registryOpts.digestOnly
+	}
+	if flagChanged(cmd, "disable-http2") {
+		h.DisableHTTP2 = registryOpts.disableHTTP2
+	}
+	if flagChanged(cmd, "proxy") {
+		h.Proxy = registryOpts.proxy
+	}
+	if flagChanged(cmd, "resolve") {
+		h.Resolve = registryOpts.resolve
+	}
+	if flagChanged(cmd, "manifest-accept") {
+		h.ManifestAccept = registryOpts.manifestAccept
+	}
+	if flagChanged(cmd, "api") {
+		h.API = registryOpts.api
+	}
 	if flagChanged(cmd, "api-opts") {
 		if h.APIOpts == nil {
 			h.APIOpts = map[string]string{}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/regclient/regclient/internal/reqresp"
+)
+
+func TestRegistryConformance(t *testing.T) {
+	tmpDir := t.TempDir()
+	out, err := cobraTest(t, "registry", "conformance", "--format", "{{range .Results}}{{println .Name .Status}}{{end}}", "ocidir://"+tmpDir+"/conformance")
+	if err != nil {
+		t.Errorf("failed to run conformance: %v, output: %s", err, out)
+		return
+	}
+	if out == "" || strings.Contains(out, "fail") {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestRegistryCopy(t *testing.T) {
+	rrs := []reqresp.ReqResp{
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "catalog last page",
+				Method: "GET",
+				Path:   "/v2/_catalog",
+				Query:  map[string][]string{"last": {"other"}},
+			},
+			RespEntry: reqresp.RespEntry{
+				Status:  http.StatusOK,
+				Headers: http.Header{"Content-Type": {"application/json"}},
+				Body:    []byte(`{"repositories":[]}`),
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "catalog",
+				Method: "GET",
+				Path:   "/v2/_catalog",
+			},
+			RespEntry: reqresp.RespEntry{
+				Status:  http.StatusOK,
+				Headers: http.Header{"Content-Type": {"application/json"}},
+				Body:    []byte(`{"repositories":["app/one","app/two","other"]}`),
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "tags app/one",
+				Method: "GET",
+				Path:   "/v2/app/one/tags/list",
+			},
+			RespEntry: reqresp.RespEntry{
+				Status:  http.StatusOK,
+				Headers: http.Header{"Content-Type": {"application/json"}},
+				Body:    []byte(`{"name":"app/one","tags":[]}`),
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "tags app/two",
+				Method: "GET",
+				Path:   "/v2/app/two/tags/list",
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusForbidden,
+			},
+		},
+	}
+	rrs = append(rrs, reqresp.BaseEntries...)
+	ts := httptest.NewServer(reqresp.NewHandler(t, rrs))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	tmpDir := t.TempDir()
+	confFile := filepath.Join(tmpDir, "config.json")
+	err := os.WriteFile(confFile, []byte(`{"hosts":{"`+tsHost+`":{"tls":"disabled","reqPerSec":100}}}`), 0600)
+	if err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv(ConfigEnv, confFile)
+	checkpoint := filepath.Join(tmpDir, "checkpoint.json")
+	tgt := "ocidir://" + tmpDir + "/backup"
+	format := `{{range .Copied}}{{println "copied" .Target}}{{end}}{{range .Skipped}}{{println "skipped" .}}{{end}}{{range .Failed}}{{println "failed" .Repository}}{{end}}`
+	saveRegistryOpts := registryOpts
+	defer func() {
+		registryOpts = saveRegistryOpts
+	}()
+
+	t.Run("Copy", func(t *testing.T) {
+		out, err := cobraTest(t, "registry", "copy", tsHost, tgt, "--exclude", "other", "--map", "app=mirror/app", "--checkpoint", checkpoint, "--format", format)
+		if !errors.Is(err, ErrRegistryCopyFailed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		expect := "copied " + tgt + "/mirror/app/one\nfailed app/two"
+		if out != expect {
+			t.Errorf("unexpected output, expected %s, received %s", expect, out)
+		}
+		b, err := os.ReadFile(checkpoint)
+		if err != nil {
+			t.Fatalf("failed to read checkpoint: %v", err)
+		}
+		cp := registryCopyCheckpoint{}
+		err = json.Unmarshal(b, &cp)
+		if err != nil {
+			t.Fatalf("failed to parse checkpoint: %v", err)
+		}
+		if len(cp.Completed) != 1 || cp.Completed[0] != "app/one" {
+			t.Errorf("unexpected checkpoint: %v", cp)
+		}
+	})
+	t.Run("Resume", func(t *testing.T) {
+		out, err := cobraTest(t, "registry", "copy", tsHost, tgt, "--include", "app/.*", "--map", "app=mirror/app", "--checkpoint", checkpoint, "--format", format)
+		if !errors.Is(err, ErrRegistryCopyFailed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		expect := "skipped app/one\nfailed app/two"
+		if out != expect {
+			t.Errorf("unexpected output, expected %s, received %s", expect, out)
+		}
+	})
+	t.Run("Checkpoint mismatch", func(t *testing.T) {
+		_, err := cobraTest(t, "registry", "copy", tsHost, "ocidir://"+tmpDir+"/other", "--checkpoint", checkpoint)
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Invalid map", func(t *testing.T) {
+		for _, m := range []string{"app", "app=", "app=/"} {
+			_, err := cobraTest(t, "registry", "copy", tsHost, tgt, "--map", m)
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("unexpected error for %s: %v", m, err)
+			}
+		}
+	})
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/pkg/template"
 	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +19,62 @@ var repoCmd = &cobra.Command{
 	Use:   "repo <cmd>",
 	Short: "manage repositories",
 }
+var repoBackupCmd = &cobra.Command{
+	Use:   "backup <repository> <dir>",
+	Short: "incrementally backup a repository to an OCI Layout",
+	Long: `Copy each tag in a repository to an OCI Layout directory.
+Repeating the backup to the same directory only pulls blobs missing from the
+directory, and updates the tags in the index.json. Images that are unchanged
+are skipped. Use --prune to remove tags from the directory that no longer
+exist in the repository, along with any blobs they referenced.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeArgNone,
+	RunE:              runRepoBackup,
+}
+var repoCopyCmd = &cobra.Command{
+	Use:     "copy <source_repository> <target_repository>",
+	Aliases: []string{"cp", "sync"},
+	Short:   "copy every tag in a repository",
+	Long: `Copy each tag in a repository to another repository.
+Images already in the target are skipped, so repeating the copy only pushes
+changes. Use --include and --exclude to filter the tags, and --prune to
+delete tags from the target that no longer exist in the source.`,
+	Example: `
+# copy all tags between registries
+regctl repo copy registry-a.example.org/repo registry-b.example.org/repo
+
+# copy release tags and remove any deleted releases
+regctl repo copy --include 'v.*' --prune \
+  registry-a.example.org/repo registry-b.example.org/repo`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeArgNone,
+	RunE:              runRepoCopy,
+}
+var repoGCCmd = &cobra.Command{
+	Use:     "gc <repository>",
+	Aliases: []string{"garbage-collect"},
+	Short:   "remove unreferenced blobs from an OCI Layout",
+	Long: `Remove blobs from an OCI Layout that are not referenced by the index.json.
+This is used to reclaim space after tags are deleted or overwritten.
+The digest of each removed blob is output.
+Use --dry-run to list the blobs without deleting them.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgNone,
+	RunE:              runRepoGC,
+}
+var repoVerifyCmd = &cobra.Command{
+	Use:   "verify <repository>",
+	Short: "verify the blobs and index of an OCI Layout",
+	Long: `Recompute the digest of every blob in an OCI Layout and check the index.json
+and manifests for missing content. This detects corruption of layouts stored on
+unreliable media. Use --repair to rename blobs stored under the wrong digest,
+move other corrupt blobs to the quarantine directory of the layout, and remove
+index entries for missing manifests.
+An error is returned if any problems remain.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgNone,
+	RunE:              runRepoVerify,
+}
 var repoLsCmd = &cobra.Command{
 	Use:     "ls <registry>",
 	Aliases: []string{"list"},
@@ -23,25 +85,152 @@ Note: Docker Hub does not support this API request.`,
 	ValidArgsFunction: registryArgListReg,
 	RunE:              runRepoLs,
 }
+var repoSearchCmd = &cobra.Command{
+	Use:   "search <term>",
+	Short: "search for repositories",
+	Long: `Search for repositories matching a term, showing the description and star count.
+The term is searched on Docker Hub unless it is prefixed with a registry,
+e.g. "registry.example.org/term". Other registries must be configured with
+the harbor or quay API profile.`,
+	Example: `
+# search Docker Hub
+regctl repo search alpine
+
+# search a Harbor registry
+regctl registry set --api harbor harbor.example.org
+regctl repo search harbor.example.org/nginx`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArgNone,
+	RunE:              runRepoSearch,
+}
 
 var repoOpts struct {
-	last   string
-	limit  int
-	format string
+	last         string
+	limit        int
+	prefix       string
+	format       string
+	formatBackup string
+	formatCopy   string
+	digestTags   bool
+	dryRun       bool
+	formatGC     string
+	formatVerify string
+	repair       bool
+	exclude      []string
+	include      []string
+	prune        bool
+	referrers    bool
 }
 
 func init() {
+	repoBackupCmd.Flags().BoolVarP(&repoOpts.digestTags, "digest-tags", "", false, "Include digest tags (\"sha256-<digest>.*\") when copying manifests")
+	repoBackupCmd.Flags().StringArrayVarP(&repoOpts.exclude, "exclude", "", []string{}, "Regexp of tags to exclude")
+	repoBackupCmd.Flags().StringVarP(&repoOpts.formatBackup, "format", "", "{{range .Copied}}{{printf \"copied %s\\n\" .}}{{end}}{{range .Pruned}}{{printf \"pruned %s\\n\" .}}{{end}}", "Format output with go template syntax")
+	repoBackupCmd.Flags().StringArrayVarP(&repoOpts.include, "include", "", []string{}, "Regexp of tags to include")
+	repoBackupCmd.Flags().BoolVarP(&repoOpts.prune, "prune", "", false, "Delete tags from the backup that are not in the repository")
+	repoBackupCmd.Flags().BoolVarP(&repoOpts.referrers, "referrers", "", false, "Include referrers")
+	repoBackupCmd.RegisterFlagCompletionFunc("exclude", completeArgNone)
+	repoBackupCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+	repoBackupCmd.RegisterFlagCompletionFunc("include", completeArgNone)
+
+	repoCopyCmd.Flags().BoolVarP(&repoOpts.digestTags, "digest-tags", "", false, "Include digest tags (\"sha256-<digest>.*\") when copying manifests")
+	repoCopyCmd.Flags().StringArrayVarP(&repoOpts.exclude, "exclude", "", []string{}, "Regexp of tags to exclude")
+	repoCopyCmd.Flags().StringVarP(&repoOpts.formatCopy, "format", "", "{{range .Copied}}{{printf \"copied %s\\n\" .}}{{end}}{{range .Pruned}}{{printf \"pruned %s\\n\" .}}{{end}}", "Format output with go template syntax")
+	repoCopyCmd.Flags().StringArrayVarP(&repoOpts.include, "include", "", []string{}, "Regexp of tags to include")
+	repoCopyCmd.Flags().BoolVarP(&repoOpts.prune, "prune", "", false, "Delete tags from the target that are not in the source")
+	repoCopyCmd.Flags().BoolVarP(&repoOpts.referrers, "referrers", "", false, "Include referrers")
+	repoCopyCmd.RegisterFlagCompletionFunc("exclude", completeArgNone)
+	repoCopyCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+	repoCopyCmd.RegisterFlagCompletionFunc("include", completeArgNone)
+
+	repoGCCmd.Flags().BoolVarP(&repoOpts.dryRun, "dry-run", "", false, "List unreferenced blobs without deleting them")
+	repoGCCmd.Flags().StringVarP(&repoOpts.formatGC, "format", "", "{{range .}}{{println .}}{{end}}", "Format output with go template syntax")
+	repoGCCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
 	repoLsCmd.Flags().StringVarP(&repoOpts.last, "last", "", "", "Specify the last repo from a previous request for pagination")
 	repoLsCmd.Flags().IntVarP(&repoOpts.limit, "limit", "", 0, "Specify the number of repos to retrieve")
+	repoLsCmd.Flags().StringVarP(&repoOpts.prefix, "prefix", "", "", "Only list repos starting with a prefix")
 	repoLsCmd.Flags().StringVarP(&repoOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
 	repoLsCmd.RegisterFlagCompletionFunc("last", completeArgNone)
 	repoLsCmd.RegisterFlagCompletionFunc("limit", completeArgNone)
+	repoLsCmd.RegisterFlagCompletionFunc("prefix", completeArgNone)
 	repoLsCmd.RegisterFlagCompletionFunc("format", completeArgNone)
 
+	repoSearchCmd.Flags().IntVarP(&repoOpts.limit, "limit", "", 0, "Specify the maximum number of results")
+	repoSearchCmd.Flags().StringVarP(&repoOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
+	repoSearchCmd.RegisterFlagCompletionFunc("limit", completeArgNone)
+	repoSearchCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
+	repoVerifyCmd.Flags().BoolVarP(&repoOpts.repair, "repair", "", false, "Rename or quarantine corrupt blobs and remove index entries for missing manifests")
+	repoVerifyCmd.Flags().StringVarP(&repoOpts.formatVerify, "format", "", "{{printPretty .}}", "Format output with go template syntax")
+	repoVerifyCmd.RegisterFlagCompletionFunc("format", completeArgNone)
+
+	repoCmd.AddCommand(repoBackupCmd)
+	repoCmd.AddCommand(repoCopyCmd)
+	repoCmd.AddCommand(repoGCCmd)
 	repoCmd.AddCommand(repoLsCmd)
+	repoCmd.AddCommand(repoSearchCmd)
+	repoCmd.AddCommand(repoVerifyCmd)
 	rootCmd.AddCommand(repoCmd)
 }
 
+func runRepoGC(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"repository": r.CommonName(),
+		"dryRun":     repoOpts.dryRun,
+	}).Debug("Garbage collect")
+	opts := []scheme.GCOpts{}
+	if repoOpts.dryRun {
+		opts = append(opts, scheme.WithGCDryRun())
+	}
+	removed, err := rc.RepoGarbageCollect(ctx, r, opts...)
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), repoOpts.formatGC, removed)
+}
+
+func runRepoVerify(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	r, err := ref.New(args[0])
+	if err != nil {
+		return err
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"repository": r.CommonName(),
+		"repair":     repoOpts.repair,
+	}).Debug("Verify")
+	opts := []scheme.VerifyOpts{}
+	if repoOpts.repair {
+		opts = append(opts, scheme.WithVerifyRepair())
+	}
+	report, err := rc.RepoVerify(ctx, r, opts...)
+	if err != nil {
+		return err
+	}
+	err = template.Writer(cmd.OutOrStdout(), repoOpts.formatVerify, report)
+	if err != nil {
+		return err
+	}
+	if len(report.Missing) > 0 || (!repoOpts.repair && (len(report.Corrupt) > 0 || len(report.IndexFixed) > 0)) {
+		return fmt.Errorf("problems found verifying %s%.0w", r.CommonName(), types.ErrMismatch)
+	}
+	return nil
+}
+
 func runRepoLs(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	host := args[0]
@@ -53,11 +242,15 @@ func runRepoLs(cmd *cobra.Command, args []string) error {
 		}).Error("Hostname invalid")
 		return ErrInvalidInput
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	log.WithFields(logrus.Fields{
-		"host":  host,
-		"last":  repoOpts.last,
-		"limit": repoOpts.limit,
+		"host":   host,
+		"last":   repoOpts.last,
+		"limit":  repoOpts.limit,
+		"prefix": repoOpts.prefix,
 	}).Debug("Listing repositories")
 	opts := []scheme.RepoOpts{}
 	if repoOpts.last != "" {
@@ -66,6 +259,9 @@ func runRepoLs(cmd *cobra.Command, args []string) error {
 	if repoOpts.limit != 0 {
 		opts = append(opts, scheme.WithRepoLimit(repoOpts.limit))
 	}
+	if repoOpts.prefix != "" {
+		opts = append(opts, scheme.WithRepoPrefix(repoOpts.prefix))
+	}
 	rl, err := rc.RepoList(ctx, host, opts...)
 	if err != nil {
 		return err
@@ -80,3 +276,105 @@ func runRepoLs(cmd *cobra.Command, args []string) error {
 	}
 	return template.Writer(cmd.OutOrStdout(), repoOpts.format, rl)
 }
+
+func runRepoBackup(cmd *cobra.Command, args []string) error {
+	return repoCopy(cmd, args[0], "ocidir://"+args[1], repoOpts.formatBackup)
+}
+
+func runRepoCopy(cmd *cobra.Command, args []string) error {
+	return repoCopy(cmd, args[0], args[1], repoOpts.formatCopy)
+}
+
+func repoCopy(cmd *cobra.Command, src, tgt, format string) error {
+	ctx := cmd.Context()
+	rSrc, err := ref.New(src)
+	if err != nil {
+		return err
+	}
+	rTgt, err := ref.New(tgt)
+	if err != nil {
+		return err
+	}
+	rcOpts := []regclient.RepoCopyOpts{}
+	reInclude, err := repoRegexp(repoOpts.include)
+	if err != nil {
+		return err
+	}
+	for _, re := range reInclude {
+		rcOpts = append(rcOpts, regclient.RepoCopyWithInclude(re))
+	}
+	reExclude, err := repoRegexp(repoOpts.exclude)
+	if err != nil {
+		return err
+	}
+	for _, re := range reExclude {
+		rcOpts = append(rcOpts, regclient.RepoCopyWithExclude(re))
+	}
+	if repoOpts.prune {
+		rcOpts = append(rcOpts, regclient.RepoCopyWithPrune())
+	}
+	if repoOpts.digestTags {
+		rcOpts = append(rcOpts, regclient.RepoCopyWithImageOpts(regclient.ImageWithDigestTags()))
+	}
+	if repoOpts.referrers {
+		rcOpts = append(rcOpts, regclient.RepoCopyWithImageOpts(regclient.ImageWithReferrers()))
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	defer rc.Close(ctx, rSrc)
+	defer rc.Close(ctx, rTgt)
+
+	log.WithFields(logrus.Fields{
+		"source": rSrc.CommonName(),
+		"target": rTgt.CommonName(),
+	}).Debug("Repo copy")
+	report, err := rc.RepoCopy(ctx, rSrc, rTgt, rcOpts...)
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), format, report)
+}
+
+func repoRegexp(exprs []string) ([]*regexp.Regexp, error) {
+	reList := []*regexp.Regexp{}
+	for _, expr := range exprs {
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse regexp \"%s\": %w", expr, err)
+		}
+		reList = append(reList, re)
+	}
+	return reList, nil
+}
+
+func runRepoSearch(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	host, term := config.DockerRegistry, args[0]
+	// a leading registry is detected the same as an image reference
+	if first, rest, ok := strings.Cut(term, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		host, term = first, rest
+	}
+	if term == "" {
+		return fmt.Errorf("search term is required%.0w", ErrInvalidInput)
+	}
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"host":  host,
+		"term":  term,
+		"limit": repoOpts.limit,
+	}).Debug("Searching repositories")
+	opts := []scheme.RepoOpts{}
+	if repoOpts.limit != 0 {
+		opts = append(opts, scheme.WithRepoLimit(repoOpts.limit))
+	}
+	sl, err := rc.RepoSearch(ctx, host, term, opts...)
+	if err != nil {
+		return err
+	}
+	return template.Writer(cmd.OutOrStdout(), repoOpts.format, sl)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/regclient/regclient/types"
+)
+
+func TestRepoBackup(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcRepo := "ocidir://../../testdata/testrepo"
+	bkDir := tmpDir + "/backup"
+	saveRepoOpts := repoOpts
+
+	// initial backup copies each tag
+	out, err := cobraTest(t, "repo", "backup", "--include", "v.*", srcRepo, bkDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run backup: %v", err)
+		return
+	}
+	if out != "copied v1\ncopied v2\ncopied v3" {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	// repeated backup skips unchanged images
+	out, err = cobraTest(t, "repo", "backup", "--include", "v.*", "--format", "{{len .Copied}} {{len .Unchanged}}", srcRepo, bkDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run backup: %v", err)
+		return
+	}
+	if out != "0 3" {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	// prune removes tags excluded from the source
+	out, err = cobraTest(t, "repo", "backup", "--include", "v.*", "--exclude", "v3", "--prune", srcRepo, bkDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run backup: %v", err)
+		return
+	}
+	if out != "" {
+		t.Errorf("unexpected output, tag outside of the filter was pruned: %s", out)
+	}
+	out, err = cobraTest(t, "repo", "backup", "--include", "v[12]", "--prune", "ocidir://"+bkDir, tmpDir+"/backup2")
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run backup: %v", err)
+		return
+	}
+	if out != "copied v1\ncopied v2" {
+		t.Errorf("unexpected output: %s", out)
+	}
+	_, err = cobraTest(t, "tag", "delete", "ocidir://"+bkDir+":v2")
+	if err != nil {
+		t.Errorf("failed to delete tag: %v", err)
+		return
+	}
+	out, err = cobraTest(t, "repo", "backup", "--include", "v[12]", "--prune", "ocidir://"+bkDir, tmpDir+"/backup2")
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run backup: %v", err)
+		return
+	}
+	if out != "pruned v2" {
+		t.Errorf("unexpected output: %s", out)
+	}
+	out, err = cobraTest(t, "tag", "ls", "ocidir://"+tmpDir+"/backup2")
+	if err != nil {
+		t.Errorf("failed to list tags: %v", err)
+		return
+	}
+	if out != "v1" {
+		t.Errorf("unexpected tags after prune: %s", out)
+	}
+}
+
+func TestRepoCopy(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcRepo := "ocidir://../../testdata/testrepo"
+	tgtRepo := "ocidir://" + tmpDir + "/copy"
+	saveRepoOpts := repoOpts
+
+	out, err := cobraTest(t, "repo", "copy", "--include", "v.*", srcRepo, tgtRepo)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run copy: %v", err)
+		return
+	}
+	if out != "copied v1\ncopied v2\ncopied v3" {
+		t.Errorf("unexpected output: %s", out)
+	}
+	out, err = cobraTest(t, "repo", "copy", "--include", "v.*", "--format", "{{.Target}} {{len .Copied}} {{len .Unchanged}}", srcRepo, tgtRepo)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run copy: %v", err)
+		return
+	}
+	if out != tgtRepo+" 0 3" {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestRepoGC(t *testing.T) {
+	tmpDir := t.TempDir()
+	saveRepoOpts := repoOpts
+	saveBlobOpts := blobOpts
+	_, err := cobraTest(t, "repo", "backup", "--include", "v1", "ocidir://../../testdata/testrepo", tmpDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run backup: %v", err)
+		return
+	}
+	// push a blob that is not referenced by any manifest
+	origIn := rootCmd.InOrStdin()
+	rootCmd.SetIn(bytes.NewBufferString("unreferenced blob"))
+	defer rootCmd.SetIn(origIn)
+	dig, err := cobraTest(t, "blob", "put", "ocidir://"+tmpDir)
+	blobOpts = saveBlobOpts
+	if err != nil {
+		t.Errorf("failed to put blob: %v", err)
+		return
+	}
+
+	out, err := cobraTest(t, "repo", "gc", "--dry-run", "ocidir://"+tmpDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run gc: %v", err)
+		return
+	}
+	if out != dig {
+		t.Errorf("unexpected dry run output, expected %s, received %s", dig, out)
+	}
+	out, err = cobraTest(t, "repo", "gc", "ocidir://"+tmpDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run gc: %v", err)
+		return
+	}
+	if out != dig {
+		t.Errorf("unexpected output, expected %s, received %s", dig, out)
+	}
+	_, err = cobraTest(t, "blob", "head", "ocidir://"+tmpDir, dig)
+	blobOpts = saveBlobOpts
+	if err == nil {
+		t.Errorf("blob was not removed")
+	}
+	out, err = cobraTest(t, "repo", "gc", "ocidir://"+tmpDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Errorf("failed to run gc: %v", err)
+		return
+	}
+	if out != "" {
+		t.Errorf("repeated gc removed blobs: %s", out)
+	}
+}
+
+func TestRepoVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+	saveRepoOpts := repoOpts
+	_, err := cobraTest(t, "repo", "backup", "--include", "v1", "ocidir://../../testdata/testrepo", tmpDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Fatalf("failed to run backup: %v", err)
+	}
+	out, err := cobraTest(t, "repo", "verify", "--format", "{{.Blobs}}", "ocidir://"+tmpDir)
+	repoOpts = saveRepoOpts
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if out == "" || out == "0" {
+		t.Errorf("unexpected blob count: %s", out)
+	}
+	// corrupt a blob
+	blobs, err := os.ReadDir(filepath.Join(tmpDir, "blobs", "sha256"))
+	if err != nil || len(blobs) == 0 {
+		t.Fatalf("failed to list blobs: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(tmpDir, "blobs", "sha256", blobs[0].Name()), []byte("corrupt"), 0644)
+	if err != nil {
+		t.Fatalf("failed to corrupt blob: %v", err)
+	}
+	_, err = cobraTest(t, "repo", "verify", "ocidir://"+tmpDir)
+	repoOpts = saveRepoOpts
+	if err == nil {
+		t.Errorf("verify did not fail with a corrupt blob")
+	}
+	out, err = cobraTest(t, "repo", "verify", "--repair", "--format", "{{len .Quarantined}}", "ocidir://"+tmpDir)
+	repoOpts = saveRepoOpts
+	if out != "1" {
+		t.Errorf("unexpected quarantine count: %s, err %v", out, err)
+	}
+}
+
+func TestRepoSearch(t *testing.T) {
+	_, err := cobraTest(t, "repo", "search", "registry.example.org/")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected invalid input for a missing term, received %v", err)
+	}
+	_, err = cobraTest(t, "repo", "search", "registry.example.org/alpine")
+	if !errors.Is(err, types.ErrUnsupportedAPI) {
+		t.Errorf("expected unsupported API, received %v", err)
+	}
+}
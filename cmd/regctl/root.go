@@ -1,12 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
 	"os"
 	"time"
 
 	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/version"
+	"github.com/regclient/regclient/pkg/cassette"
 	"github.com/regclient/regclient/pkg/template"
 	"github.com/regclient/regclient/scheme/reg"
 	"github.com/sirupsen/logrus"
@@ -14,8 +17,10 @@ import (
 )
 
 const (
-	progressFreq = time.Millisecond * 250
-	usageDesc    = `Utility for accessing docker registries
+	// imageParallelDefault is the number of blobs transferred concurrently by image export and import
+	imageParallelDefault = 3
+	progressFreq         = time.Millisecond * 250
+	usageDesc            = `Utility for accessing docker registries
 More details at https://github.com/regclient/regclient`
 	// UserAgent sets the header on http requests
 	UserAgent = "regclient/regctl"
@@ -23,6 +28,8 @@ More details at https://github.com/regclient/regclient`
 
 var (
 	log *logrus.Logger
+	// rootCassette records or replays registry requests, see the --record and --replay flags
+	rootCassette *cassette.Cassette
 )
 
 var rootCmd = &cobra.Command{
@@ -46,6 +53,8 @@ var rootOpts struct {
 	logopts   []string
 	format    string // for Go template formatting of various commands
 	userAgent string
+	record    string
+	replay    string
 }
 
 func init() {
@@ -59,11 +68,14 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&rootOpts.verbosity, "verbosity", "v", logrus.WarnLevel.String(), "Log level (debug, info, warn, error, fatal, panic)")
 	rootCmd.PersistentFlags().StringArrayVar(&rootOpts.logopts, "logopt", []string{}, "Log options")
 	rootCmd.PersistentFlags().StringVarP(&rootOpts.userAgent, "user-agent", "", "", "Override user agent")
+	rootCmd.PersistentFlags().StringVarP(&rootOpts.record, "record", "", "", "Record sanitized registry requests and responses to a file for debugging")
+	rootCmd.PersistentFlags().StringVarP(&rootOpts.replay, "replay", "", "", "Replay registry responses from a recorded file without network access")
 
 	rootCmd.RegisterFlagCompletionFunc("verbosity", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return []string{"debug", "info", "warn", "error", "fatal", "panic"}, cobra.ShellCompDirectiveNoFileComp
 	})
 	rootCmd.RegisterFlagCompletionFunc("logopt", completeArgNone)
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
 
 	versionCmd.Flags().StringVarP(&rootOpts.format, "format", "", "{{printPretty .}}", "Format output with go template syntax")
 	versionCmd.RegisterFlagCompletionFunc("format", completeArgNone)
@@ -83,6 +95,15 @@ func rootPreRun(cmd *cobra.Command, args []string) error {
 			log.Formatter = new(logrus.JSONFormatter)
 		}
 	}
+	rootCassette = nil
+	if rootOpts.record != "" {
+		rootCassette = cassette.New(rootOpts.record)
+	} else if rootOpts.replay != "" {
+		rootCassette, err = cassette.Load(rootOpts.replay)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -91,7 +112,7 @@ func runVersion(cmd *cobra.Command, args []string) error {
 	return template.Writer(cmd.OutOrStdout(), rootOpts.format, info)
 }
 
-func newRegClient() *regclient.RegClient {
+func newRegClient() (*regclient.RegClient, error) {
 	conf, err := ConfigLoadDefault()
 	if err != nil {
 		log.WithFields(logrus.Fields{
@@ -113,9 +134,19 @@ func newRegClient() *regclient.RegClient {
 			rcOpts = append(rcOpts, regclient.WithUserAgent(UserAgent+" ("+info.VCSRef+")"))
 		}
 	}
+	if rootCassette != nil && rootOpts.record != "" {
+		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithTransportWrap(rootCassette.Recorder)))
+	} else if rootCassette != nil {
+		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithTransportWrap(func(http.RoundTripper) http.RoundTripper {
+			return rootCassette.Replay()
+		})))
+	}
 	if conf.BlobLimit != 0 {
 		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithBlobLimit(conf.BlobLimit)))
 	}
+	if conf.BlobSpool > 0 {
+		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithBlobSpool("", conf.BlobSpool)))
+	}
 	if conf.IncDockerCred == nil || *conf.IncDockerCred {
 		rcOpts = append(rcOpts, regclient.WithDockerCreds())
 	}
@@ -125,14 +156,20 @@ func newRegClient() *regclient.RegClient {
 
 	rcHosts := []config.Host{}
 	for name, host := range conf.Hosts {
-		host.Name = name
-		rcHosts = append(rcHosts, *host)
+		// templates are expanded on a copy, leaving the original value to be saved in the config
+		h := *host
+		h.Name = name
+		err := h.ExpandTemplates()
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand templates in host config %s: %w", name, err)
+		}
+		rcHosts = append(rcHosts, h)
 	}
 	if len(rcHosts) > 0 {
 		rcOpts = append(rcOpts, regclient.WithConfigHost(rcHosts...))
 	}
 
-	return regclient.New(rcOpts...)
+	return regclient.New(rcOpts...), nil
 }
 
 func flagChanged(cmd *cobra.Command, name string) bool {
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "run a read-only pull-through registry mirror",
+	Long: `Serves the distribution API locally as a read-only pull-through mirror.
+Repositories are pulled from the first upstream by default, e.g. "library/alpine".
+Other upstreams are selected by including the registry in the repository name,
+e.g. "ghcr.io/regclient/regctl". An upstream may also be an OCI Layout
+("ocidir://path"). Pulled manifests and blobs are stored in the --cache OCI
+Layout and reused for later requests by digest.`,
+	Args: cobra.ExactArgs(0),
+	RunE: runServer,
+}
+
+var serverOpts struct {
+	cache     string
+	listen    string
+	upstreams []string
+}
+
+func init() {
+	serverCmd.Flags().StringVarP(&serverOpts.cache, "cache", "", "", "Directory to cache pulled manifests and blobs")
+	serverCmd.Flags().StringVarP(&serverOpts.listen, "listen", "", "127.0.0.1:5000", "Address to listen on")
+	serverCmd.Flags().StringArrayVarP(&serverOpts.upstreams, "upstream", "", []string{"docker.io"}, "Upstream registry, the first is the default")
+	serverCmd.RegisterFlagCompletionFunc("listen", completeArgNone)
+	serverCmd.RegisterFlagCompletionFunc("upstream", completeArgNone)
+
+	rootCmd.AddCommand(serverCmd)
+}
+
+func runServer(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
+	s, err := newServer(rc, serverOpts.upstreams, serverOpts.cache)
+	if err != nil {
+		return err
+	}
+	hs := &http.Server{
+		Addr:              serverOpts.listen,
+		Handler:           s,
+		ReadHeaderTimeout: time.Second * 30,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+		_ = hs.Shutdown(shutdownCtx)
+	}()
+	log.WithFields(logrus.Fields{
+		"listen":    serverOpts.listen,
+		"upstreams": serverOpts.upstreams,
+		"cache":     serverOpts.cache,
+	}).Info("Starting server")
+	err = hs.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// server is a read-only http handler for the distribution API
+type server struct {
+	rc        *regclient.RegClient
+	upstreams []string
+	cache     ref.Ref
+	useCache  bool
+}
+
+func newServer(rc *regclient.RegClient, upstreams []string, cache string) (*server, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("at least one upstream is required")
+	}
+	s := &server{
+		rc:        rc,
+		upstreams: upstreams,
+	}
+	if cache != "" {
+		r, err := ref.New("ocidir://" + cache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cache %s: %w", cache, err)
+		}
+		s.cache = r
+		s.useCache = true
+	}
+	return s, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		serverError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "registry is read-only")
+		return
+	}
+	p := req.URL.Path
+	if p == "/v2" || p == "/v2/" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+		return
+	}
+	if !strings.HasPrefix(p, "/v2/") {
+		serverError(w, http.StatusNotFound, "NOT_FOUND", "unknown path")
+		return
+	}
+	p = strings.TrimPrefix(p, "/v2/")
+	switch {
+	case strings.HasSuffix(p, "/tags/list"):
+		s.serveTags(w, req, strings.TrimSuffix(p, "/tags/list"))
+	case strings.Contains(p, "/manifests/"):
+		i := strings.LastIndex(p, "/manifests/")
+		s.serveManifest(w, req, p[:i], p[i+len("/manifests/"):])
+	case strings.Contains(p, "/blobs/"):
+		i := strings.LastIndex(p, "/blobs/")
+		s.serveBlob(w, req, p[:i], p[i+len("/blobs/"):])
+	default:
+		serverError(w, http.StatusNotFound, "NOT_FOUND", "unknown path")
+	}
+}
+
+// serverRepoRE matches a repository name from the distribution spec, preventing a path traversal with an ocidir upstream
+var serverRepoRE = regexp.MustCompile(`^[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*)*$`)
+
+// upstreamRef converts a repository name and tag or digest into a reference to an upstream.
+func (s *server) upstreamRef(name, reference string) (ref.Ref, error) {
+	upstream := s.upstreams[0]
+	if i := strings.Index(name, "/"); i > 0 && strings.ContainsAny(name[:i], ".:") {
+		upstream = ""
+		for _, u := range s.upstreams {
+			if u == name[:i] {
+				upstream = u
+				name = name[i+1:]
+				break
+			}
+		}
+		if upstream == "" {
+			return ref.Ref{}, fmt.Errorf("registry %s is not an upstream%.0w", name[:i], types.ErrNotFound)
+		}
+	}
+	if !serverRepoRE.MatchString(name) {
+		return ref.Ref{}, fmt.Errorf("invalid repository name %s%.0w", name, types.ErrInvalidReference)
+	}
+	sep := ":"
+	if strings.Contains(reference, ":") {
+		sep = "@"
+	}
+	if reference == "" {
+		sep = ""
+	}
+	return ref.New(upstream + "/" + name + sep + reference)
+}
+
+func (s *server) serveManifest(w http.ResponseWriter, req *http.Request, name, reference string) {
+	ctx := req.Context()
+	r, err := s.upstreamRef(name, reference)
+	if err != nil {
+		serverRefError(w, err)
+		return
+	}
+	var m manifest.Manifest
+	dig := digest.Digest(r.Digest)
+	if dig == "" {
+		// tags are always resolved against the upstream
+		mh, err := s.rc.ManifestHead(ctx, r, regclient.WithManifestRequireDigest())
+		if err != nil {
+			serverUpstreamError(w, "MANIFEST_UNKNOWN", err)
+			return
+		}
+		dig = mh.GetDescriptor().Digest
+	}
+	rCache := s.cache
+	rCache.Digest = dig.String()
+	if s.useCache {
+		m, err = s.rc.ManifestGet(ctx, rCache)
+		if err != nil {
+			m = nil
+		}
+	}
+	if m == nil {
+		rDig := r
+		rDig.Digest = dig.String()
+		m, err = s.rc.ManifestGet(ctx, rDig)
+		if err != nil {
+			serverUpstreamError(w, "MANIFEST_UNKNOWN", err)
+			return
+		}
+		if s.useCache {
+			err = s.rc.ManifestPut(ctx, rCache, m, regclient.WithManifestChild())
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"ref": rDig.CommonName(),
+					"err": err,
+				}).Warn("Failed to cache manifest")
+			}
+		}
+	}
+	raw, err := m.RawBody()
+	if err != nil {
+		serverError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", m.GetDescriptor().MediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(raw)))
+	w.Header().Set("Docker-Content-Digest", dig.String())
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(raw)
+	}
+}
+
+func (s *server) serveBlob(w http.ResponseWriter, req *http.Request, name, reference string) {
+	ctx := req.Context()
+	dig, err := digest.Parse(reference)
+	if err != nil {
+		serverError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+		return
+	}
+	r, err := s.upstreamRef(name, "")
+	if err != nil {
+		serverRefError(w, err)
+		return
+	}
+	d := types.Descriptor{Digest: dig}
+	src := r
+	cacheMiss := false
+	if s.useCache {
+		if b, err := s.rc.BlobHead(ctx, s.cache, d); err == nil {
+			_ = b.Close()
+			src = s.cache
+		} else {
+			cacheMiss = true
+		}
+	}
+	if req.Method == http.MethodHead {
+		b, err := s.rc.BlobHead(ctx, src, d)
+		if err != nil {
+			serverUpstreamError(w, "BLOB_UNKNOWN", err)
+			return
+		}
+		_ = b.Close()
+		serverBlobHeaders(w, b.GetDescriptor(), dig)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	b, err := s.rc.BlobGet(ctx, src, d)
+	if err != nil {
+		serverUpstreamError(w, "BLOB_UNKNOWN", err)
+		return
+	}
+	defer b.Close()
+	serverBlobHeaders(w, b.GetDescriptor(), dig)
+	w.WriteHeader(http.StatusOK)
+	var rdr io.Reader = b
+	var pw *io.PipeWriter
+	var putErr chan error
+	if cacheMiss {
+		// the blob is cached while it is streamed to the client
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		putErr = make(chan error, 1)
+		dPut := types.Descriptor{Digest: dig, Size: b.GetDescriptor().Size}
+		go func() {
+			_, err := s.rc.BlobPut(ctx, s.cache, dPut, pr)
+			_ = pr.CloseWithError(err)
+			putErr <- err
+		}()
+		rdr = io.TeeReader(b, &serverCacheWriter{w: pw})
+	}
+	_, err = io.Copy(w, rdr)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"ref":    src.CommonName(),
+			"digest": dig.String(),
+			"err":    err,
+		}).Warn("Failed to send blob")
+	}
+	if cacheMiss {
+		if err != nil {
+			_ = pw.CloseWithError(err)
+		} else {
+			_ = pw.Close()
+		}
+		if err := <-putErr; err != nil {
+			log.WithFields(logrus.Fields{
+				"ref":    r.CommonName(),
+				"digest": dig.String(),
+				"err":    err,
+			}).Warn("Failed to cache blob")
+		}
+	}
+}
+
+// serverCacheWriter stops writing to the cache after the first error without failing the response to the client
+type serverCacheWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *serverCacheWriter) Write(p []byte) (int, error) {
+	if cw.err == nil {
+		_, cw.err = cw.w.Write(p)
+	}
+	return len(p), nil
+}
+
+func (s *server) serveTags(w http.ResponseWriter, req *http.Request, name string) {
+	r, err := s.upstreamRef(name, "")
+	if err != nil {
+		serverRefError(w, err)
+		return
+	}
+	tl, err := s.rc.TagList(req.Context(), r)
+	if err != nil {
+		serverUpstreamError(w, "NAME_UNKNOWN", err)
+		return
+	}
+	tags, err := tl.GetTags()
+	if err != nil {
+		serverError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	body, err := json.Marshal(struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}{
+		Name: name,
+		Tags: tags,
+	})
+	if err != nil {
+		serverError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(body)
+	}
+}
+
+func serverBlobHeaders(w http.ResponseWriter, d types.Descriptor, dig digest.Digest) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if d.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(d.Size, 10))
+	}
+	w.Header().Set("Docker-Content-Digest", dig.String())
+}
+
+// serverRefError returns an invalid or unknown name error for a failed upstreamRef
+func serverRefError(w http.ResponseWriter, err error) {
+	if errors.Is(err, types.ErrInvalidReference) {
+		serverError(w, http.StatusBadRequest, "NAME_INVALID", err.Error())
+		return
+	}
+	serverError(w, http.StatusNotFound, "NAME_UNKNOWN", err.Error())
+}
+
+// serverUpstreamError returns a not found or bad gateway error depending on the upstream response
+func serverUpstreamError(w http.ResponseWriter, code string, err error) {
+	if errors.Is(err, types.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		serverError(w, http.StatusNotFound, code, err.Error())
+		return
+	}
+	serverError(w, http.StatusBadGateway, "UNKNOWN", err.Error())
+}
+
+func serverError(w http.ResponseWriter, status int, code, message string) {
+	body, _ := json.Marshal(struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{
+		Errors: []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}{{Code: code, Message: message}},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func TestServer(t *testing.T) {
+	cacheDir := t.TempDir()
+	rc, err := newRegClient()
+	if err != nil {
+		t.Fatalf("failed to create regclient: %v", err)
+	}
+	s, err := newServer(rc, []string{"ocidir://../../testdata"}, cacheDir)
+	if err != nil {
+		t.Errorf("failed to create server: %v", err)
+		return
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	dig, err := cobraTest(t, "manifest", "get", "ocidir://../../testdata/testrepo:v1", "--platform", "linux/amd64", "--format", "{{(index .Layers 0).Digest}}")
+	manifestOpts.platform = ""
+	if err != nil {
+		t.Errorf("failed to get layer digest: %v", err)
+		return
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		expectCode int
+		expectBody string
+		expectFile string
+	}{
+		{
+			name:       "ping",
+			method:     http.MethodGet,
+			path:       "/v2/",
+			expectCode: http.StatusOK,
+			expectBody: "{}",
+		},
+		{
+			name:       "manifest",
+			method:     http.MethodGet,
+			path:       "/v2/testrepo/manifests/v1",
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "manifest head",
+			method:     http.MethodHead,
+			path:       "/v2/testrepo/manifests/v1",
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "manifest missing",
+			method:     http.MethodGet,
+			path:       "/v2/testrepo/manifests/missing",
+			expectCode: http.StatusNotFound,
+		},
+		{
+			name:       "blob",
+			method:     http.MethodGet,
+			path:       "/v2/testrepo/blobs/" + dig,
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "blob stored",
+			method:     http.MethodHead,
+			path:       "/v2/testrepo/blobs/" + dig,
+			expectCode: http.StatusOK,
+			expectFile: "blobs/sha256/" + strings.TrimPrefix(dig, "sha256:"),
+		},
+		{
+			name:       "blob cached",
+			method:     http.MethodGet,
+			path:       "/v2/testrepo/blobs/" + dig,
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "tag list",
+			method:     http.MethodGet,
+			path:       "/v2/testrepo/tags/list",
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "unknown upstream",
+			method:     http.MethodGet,
+			path:       "/v2/registry.example.com/repo/manifests/latest",
+			expectCode: http.StatusNotFound,
+		},
+		{
+			name:       "path traversal",
+			method:     http.MethodGet,
+			path:       "/v2/other/../testrepo/manifests/v1",
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "read-only",
+			method:     http.MethodPut,
+			path:       "/v2/testrepo/manifests/v1",
+			expectCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Errorf("failed to create request: %v", err)
+				return
+			}
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Errorf("failed to send request: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Errorf("failed to read body: %v", err)
+				return
+			}
+			if resp.StatusCode != tt.expectCode {
+				t.Errorf("unexpected status, expected %d, received %d: %s", tt.expectCode, resp.StatusCode, string(body))
+			}
+			if tt.expectBody != "" && string(body) != tt.expectBody {
+				t.Errorf("unexpected body, expected %s, received %s", tt.expectBody, string(body))
+			}
+			if tt.expectFile != "" {
+				if _, err := os.Stat(filepath.Join(cacheDir, tt.expectFile)); err != nil {
+					t.Errorf("file missing from the cache: %v", err)
+				}
+			}
+			if tt.expectCode == http.StatusOK && tt.method == http.MethodGet && resp.Header.Get("Docker-Content-Digest") != "" {
+				if digest.FromBytes(body).String() != resp.Header.Get("Docker-Content-Digest") {
+					t.Errorf("digest mismatch, header %s, body %s", resp.Header.Get("Docker-Content-Digest"), digest.FromBytes(body).String())
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 
+	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/pkg/template"
 	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types/ref"
@@ -43,14 +44,17 @@ For an OCI Layout, the index is available as Index (--format "{{.Index}}").
 }
 
 var tagOpts struct {
-	limit   int
-	last    string
-	include []string
-	exclude []string
-	format  string
+	limit         int
+	last          string
+	include       []string
+	exclude       []string
+	format        string
+	ignoreMissing bool
 }
 
 func init() {
+	tagDeleteCmd.Flags().BoolVarP(&tagOpts.ignoreMissing, "ignore-missing", "", false, "Ignore errors if the tag is already deleted")
+
 	tagLsCmd.Flags().StringVarP(&tagOpts.last, "last", "", "", "Specify the last tag from a previous request for pagination (depends on registry support)")
 	tagLsCmd.Flags().IntVarP(&tagOpts.limit, "limit", "", 0, "Specify the number of tags to retrieve (depends on registry support)")
 	tagLsCmd.Flags().StringArrayVar(&tagOpts.include, "include", []string{}, "Regexp of tags to include (expression is bound to beginning and ending of tag)")
@@ -72,14 +76,21 @@ func runTagDelete(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 	log.WithFields(logrus.Fields{
 		"host":       r.Registry,
 		"repository": r.Repository,
 		"tag":        r.Tag,
 	}).Debug("Delete tag")
-	err = rc.TagDelete(ctx, r)
+	tdOpts := []regclient.TagDeleteOpts{}
+	if tagOpts.ignoreMissing {
+		tdOpts = append(tdOpts, regclient.TagDeleteWithIgnoreMissing())
+	}
+	err = rc.TagDelete(ctx, r, tdOpts...)
 	if err != nil {
 		return err
 	}
@@ -108,7 +119,10 @@ func runTagLs(cmd *cobra.Command, args []string) error {
 		}
 		reExclude = append(reExclude, re)
 	}
-	rc := newRegClient()
+	rc, err := newRegClient()
+	if err != nil {
+		return err
+	}
 	defer rc.Close(ctx, r)
 	log.WithFields(logrus.Fields{
 		"host":       r.Registry,
@@ -126,27 +140,48 @@ func runTagLs(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	if len(reInclude) > 0 || len(reExclude) > 0 {
-		filtered := []string{}
-		var included, excluded bool
-		for _, tag := range tl.Tags {
-			included = len(reInclude) == 0
-			excluded = false
-			for _, re := range reInclude {
-				if re.MatchString(tag) {
-					included = true
-					break
-				}
-			}
-			if included {
-				for _, re := range reExclude {
+		filter := func(tags []string) []string {
+			filtered := []string{}
+			var included, excluded bool
+			for _, tag := range tags {
+				included = len(reInclude) == 0
+				excluded = false
+				for _, re := range reInclude {
 					if re.MatchString(tag) {
-						excluded = true
+						included = true
+						break
+					}
+				}
+				if included {
+					for _, re := range reExclude {
+						if re.MatchString(tag) {
+							excluded = true
+						}
 					}
 				}
+				if included && !excluded {
+					filtered = append(filtered, tag)
+				}
+			}
+			return filtered
+		}
+		filtered := filter(tl.Tags)
+		// keep paging when the filters remove tags from a limited listing
+		page := tl.Tags
+		for tagOpts.limit > 0 && len(filtered) < tagOpts.limit && len(page) > 0 {
+			last := page[len(page)-1]
+			tlNext, err := rc.TagList(ctx, r, scheme.WithTagLimit(tagOpts.limit), scheme.WithTagLast(last))
+			if err != nil {
+				return err
 			}
-			if included && !excluded {
-				filtered = append(filtered, tag)
+			page = tlNext.Tags
+			if len(page) == 0 || page[len(page)-1] <= last {
+				break
 			}
+			filtered = append(filtered, filter(page)...)
+		}
+		if tagOpts.limit > 0 && len(filtered) > tagOpts.limit {
+			filtered = filtered[:tagOpts.limit]
 		}
 		tl.Tags = filtered
 	}
@@ -52,6 +52,16 @@ func TestTagList(t *testing.T) {
 			expectOut:   "v1\nv2\nv3",
 			outContains: true,
 		},
+		{
+			name:      "List tags limit 2",
+			args:      []string{"tag", "ls", "--limit", "2", "ocidir://../../testdata/testrepo"},
+			expectOut: "a-docker\na1",
+		},
+		{
+			name:      "List tags paginated",
+			args:      []string{"tag", "ls", "--last", "sha256-f", "--limit", "5", "ocidir://../../testdata/testrepo"},
+			expectOut: "v1\nv2\nv3",
+		},
 		{
 			name:        "List tags formatted",
 			args:        []string{"tag", "ls", "--format", "raw", "ocidir://../../testdata/testrepo"},
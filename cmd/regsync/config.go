@@ -2,18 +2,33 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"regexp"
 	"time"
 
+	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/pkg/scan"
 	"github.com/regclient/regclient/pkg/template"
 	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v2"
 )
 
 // delay checking for at least 5 minutes when rate limit is exceeded
 var rateLimitRetryMin = time.Minute * 5
+
+// rateLimitRetryMax limits the number of times a copy is paused and retried after exceeding the rate limit
+var rateLimitRetryMax = 5
+
+// backoffMaxDefault limits the delay between runs of a failing sync step when backoffMax is not set
+var backoffMaxDefault = time.Hour
+
 var defaultMediaTypes = []string{
 	types.MediaTypeDocker2Manifest,
 	types.MediaTypeDocker2ManifestList,
@@ -31,24 +46,36 @@ type Config struct {
 
 // ConfigDefaults is uses for general options and defaults for ConfigSync entries
 type ConfigDefaults struct {
-	Backup          string                 `yaml:"backup" json:"backup"`
-	Interval        time.Duration          `yaml:"interval" json:"interval"`
-	Schedule        string                 `yaml:"schedule" json:"schedule"`
-	RateLimit       ConfigRateLimit        `yaml:"ratelimit" json:"ratelimit"`
-	Parallel        int                    `yaml:"parallel" json:"parallel"`
-	DigestTags      *bool                  `yaml:"digestTags" json:"digestTags"`
-	Referrers       *bool                  `yaml:"referrers" json:"referrers"`
-	ReferrerFilters []ConfigReferrerFilter `yaml:"referrerFilters" json:"referrerFilters"`
-	FastCheck       *bool                  `yaml:"fastCheck" json:"fastCheck"`
-	ForceRecursive  *bool                  `yaml:"forceRecursive" json:"forceRecursive"`
-	IncludeExternal *bool                  `yaml:"includeExternal" json:"includeExternal"`
-	MediaTypes      []string               `yaml:"mediaTypes" json:"mediaTypes"`
-	Hooks           ConfigHooks            `yaml:"hooks" json:"hooks"`
+	Backup           string                 `yaml:"backup" json:"backup"`
+	Interval         time.Duration          `yaml:"interval" json:"interval"`
+	Schedule         string                 `yaml:"schedule" json:"schedule"`
+	Jitter           time.Duration          `yaml:"jitter" json:"jitter"`
+	RateLimit        ConfigRateLimit        `yaml:"ratelimit" json:"ratelimit"`
+	Backoff          time.Duration          `yaml:"backoff" json:"backoff"`
+	BackoffMax       time.Duration          `yaml:"backoffMax" json:"backoffMax"`
+	FailureThreshold int                    `yaml:"failureThreshold" json:"failureThreshold"`
+	Parallel         int                    `yaml:"parallel" json:"parallel"`
+	BlobParallel     int                    `yaml:"blobParallel" json:"blobParallel"`
+	BlobRate         int64                  `yaml:"blobRate" json:"blobRate"`
+	DigestTags       *bool                  `yaml:"digestTags" json:"digestTags"`
+	Referrers        *bool                  `yaml:"referrers" json:"referrers"`
+	ReferrerFilters  []ConfigReferrerFilter `yaml:"referrerFilters" json:"referrerFilters"`
+	FastCheck        *bool                  `yaml:"fastCheck" json:"fastCheck"`
+	ForceRecursive   *bool                  `yaml:"forceRecursive" json:"forceRecursive"`
+	Immutable        *ConfigImmutable       `yaml:"immutable" json:"immutable"`
+	IncludeExternal  *bool                  `yaml:"includeExternal" json:"includeExternal"`
+	IgnoreMissing    *bool                  `yaml:"ignoreMissing" json:"ignoreMissing"`
+	Scan             *ConfigScan            `yaml:"scan" json:"scan"`
+	MediaTypes       []string               `yaml:"mediaTypes" json:"mediaTypes"`
+	Hooks            ConfigHooks            `yaml:"hooks" json:"hooks"`
+	Webhooks         []ConfigWebhook        `yaml:"webhooks" json:"webhooks"`
 	// general options
 	BlobLimit      int64         `yaml:"blobLimit" json:"blobLimit"`
+	BlobSpool      int64         `yaml:"blobSpool" json:"blobSpool"`
 	CacheCount     int           `yaml:"cacheCount" json:"cacheCount"`
 	CacheTime      time.Duration `yaml:"cacheTime" json:"cacheTime"`
 	SkipDockerConf bool          `yaml:"skipDockerConfig" json:"skipDockerConfig"`
+	StateDir       string        `yaml:"stateDir" json:"stateDir"`
 	UserAgent      string        `yaml:"userAgent" json:"userAgent"`
 }
 
@@ -60,24 +87,37 @@ type ConfigRateLimit struct {
 
 // ConfigSync defines a source/target repository to sync
 type ConfigSync struct {
-	Source          string                 `yaml:"source" json:"source"`
-	Target          string                 `yaml:"target" json:"target"`
-	Type            string                 `yaml:"type" json:"type"`
-	Tags            ConfigTags             `yaml:"tags" json:"tags"`
-	DigestTags      *bool                  `yaml:"digestTags" json:"digestTags"`
-	Referrers       *bool                  `yaml:"referrers" json:"referrers"`
-	ReferrerFilters []ConfigReferrerFilter `yaml:"referrerFilters" json:"referrerFilters"`
-	Platform        string                 `yaml:"platform" json:"platform"`
-	Platforms       []string               `yaml:"platforms" json:"platforms"`
-	FastCheck       *bool                  `yaml:"fastCheck" json:"fastCheck"`
-	ForceRecursive  *bool                  `yaml:"forceRecursive" json:"forceRecursive"`
-	IncludeExternal *bool                  `yaml:"includeExternal" json:"includeExternal"`
-	Backup          string                 `yaml:"backup" json:"backup"`
-	Interval        time.Duration          `yaml:"interval" json:"interval"`
-	Schedule        string                 `yaml:"schedule" json:"schedule"`
-	RateLimit       ConfigRateLimit        `yaml:"ratelimit" json:"ratelimit"`
-	MediaTypes      []string               `yaml:"mediaTypes" json:"mediaTypes"`
-	Hooks           ConfigHooks            `yaml:"hooks" json:"hooks"`
+	Source           string                 `yaml:"source" json:"source"`
+	Target           string                 `yaml:"target" json:"target"`
+	Type             string                 `yaml:"type" json:"type"`
+	Tags             ConfigTags             `yaml:"tags" json:"tags"`
+	DigestTags       *bool                  `yaml:"digestTags" json:"digestTags"`
+	Referrers        *bool                  `yaml:"referrers" json:"referrers"`
+	ReferrerFilters  []ConfigReferrerFilter `yaml:"referrerFilters" json:"referrerFilters"`
+	Platform         string                 `yaml:"platform" json:"platform"`
+	Platforms        []string               `yaml:"platforms" json:"platforms"`
+	FastCheck        *bool                  `yaml:"fastCheck" json:"fastCheck"`
+	ForceRecursive   *bool                  `yaml:"forceRecursive" json:"forceRecursive"`
+	Immutable        *ConfigImmutable       `yaml:"immutable" json:"immutable"`
+	IncludeExternal  *bool                  `yaml:"includeExternal" json:"includeExternal"`
+	IgnoreMissing    *bool                  `yaml:"ignoreMissing" json:"ignoreMissing"`
+	Scan             *ConfigScan            `yaml:"scan" json:"scan"`
+	Backup           string                 `yaml:"backup" json:"backup"`
+	Interval         time.Duration          `yaml:"interval" json:"interval"`
+	Schedule         string                 `yaml:"schedule" json:"schedule"`
+	Jitter           time.Duration          `yaml:"jitter" json:"jitter"`
+	RateLimit        ConfigRateLimit        `yaml:"ratelimit" json:"ratelimit"`
+	Backoff          time.Duration          `yaml:"backoff" json:"backoff"`
+	BackoffMax       time.Duration          `yaml:"backoffMax" json:"backoffMax"`
+	FailureThreshold int                    `yaml:"failureThreshold" json:"failureThreshold"`
+	Parallel         int                    `yaml:"parallel" json:"parallel"`
+	BlobRate         int64                  `yaml:"blobRate" json:"blobRate"`
+	MediaTypes       []string               `yaml:"mediaTypes" json:"mediaTypes"`
+	Hooks            ConfigHooks            `yaml:"hooks" json:"hooks"`
+	Webhooks         []ConfigWebhook        `yaml:"webhooks" json:"webhooks"`
+	batch            *regclient.ImageCopyBatch
+	planOnly         bool
+	planned          *syncPlan
 }
 
 // ConfigTags is an allow and deny list of tag regex strings
@@ -86,6 +126,20 @@ type ConfigTags struct {
 	Deny  []string `yaml:"deny" json:"deny"`
 }
 
+// ConfigImmutable protects target tags from being overwritten with a different image
+type ConfigImmutable struct {
+	Annotation string   `yaml:"annotation" json:"annotation"`
+	Tags       []string `yaml:"tags" json:"tags"`
+}
+
+// ConfigScan runs a vulnerability scanner on the source image, refusing the copy when findings reach the severity
+type ConfigScan struct {
+	Type     string   `yaml:"type" json:"type"`
+	Server   string   `yaml:"server" json:"server"`
+	Severity string   `yaml:"severity" json:"severity"`
+	Args     []string `yaml:"args" json:"args"`
+}
+
 type ConfigReferrerFilter struct {
 	ArtifactType string            `yaml:"artifactType" json:"artifactType"`
 	Annotations  map[string]string `yaml:"annotations" json:"annotations"`
@@ -104,6 +158,16 @@ type ConfigHook struct {
 	Params []string `yaml:"params" json:"params"`
 }
 
+// ConfigWebhook sends an http request when a sync event occurs
+type ConfigWebhook struct {
+	URL     string            `yaml:"url" json:"url"`
+	Method  string            `yaml:"method" json:"method"`
+	Headers map[string]string `yaml:"headers" json:"headers"`
+	Events  []string          `yaml:"events" json:"events"`
+	Body    string            `yaml:"body" json:"body"`
+	Timeout time.Duration     `yaml:"timeout" json:"timeout"`
+}
+
 // ConfigNew creates an empty configuration
 func ConfigNew() *Config {
 	c := Config{
@@ -161,42 +225,98 @@ func ConfigWrite(c *Config, w io.Writer) error {
 	return yaml.NewEncoder(w).Encode(c)
 }
 
+// ConfigValidate checks the loaded config for errors that would otherwise be seen on the first run of each step.
+// All errors found are returned together.
+func ConfigValidate(c *Config) error {
+	errs := []error{}
+	for i, host := range c.Creds {
+		if err := host.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("creds[%d]: %w%.0w", i, err, ErrInvalidInput))
+		}
+	}
+	for i, s := range c.Sync {
+		name := fmt.Sprintf("sync[%d]", i)
+		switch s.Type {
+		case "registry":
+			if s.Source == "" || s.Target == "" {
+				errs = append(errs, fmt.Errorf("%s: source and target are required%.0w", name, ErrMissingInput))
+			}
+		case "repository", "image":
+			for _, r := range []string{s.Source, s.Target} {
+				if _, err := ref.New(r); err != nil {
+					errs = append(errs, fmt.Errorf("%s: invalid reference %q: %w", name, r, err))
+				}
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: type %q must be one of: registry, repository, or image%.0w", name, s.Type, ErrInvalidInput))
+		}
+		for _, filter := range append(append([]string{}, s.Tags.Allow...), s.Tags.Deny...) {
+			if _, err := regexp.Compile("^" + filter + "$"); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid tag filter %q: %w", name, filter, err))
+			}
+		}
+		if s.Immutable != nil {
+			for _, filter := range s.Immutable.Tags {
+				if _, err := regexp.Compile("^" + filter + "$"); err != nil {
+					errs = append(errs, fmt.Errorf("%s: invalid immutable tag %q: %w", name, filter, err))
+				}
+			}
+		}
+		if s.Scan != nil {
+			if _, err := scan.ByName(s.Scan.Type, s.Scan.Server); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid scan: %w", name, err))
+			}
+			if s.Scan.Severity != "" {
+				if _, err := regclient.ParseScanSeverity(s.Scan.Severity); err != nil {
+					errs = append(errs, fmt.Errorf("%s: invalid scan severity: %w", name, err))
+				}
+			}
+		}
+		if s.Schedule != "" {
+			if _, err := cron.ParseStandard(s.Schedule); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid schedule %q: %w", name, s.Schedule, err))
+			}
+		}
+		if s.Jitter < 0 {
+			errs = append(errs, fmt.Errorf("%s: jitter %s must not be negative%.0w", name, s.Jitter, ErrInvalidInput))
+		}
+		if s.Backoff < 0 || s.BackoffMax < 0 {
+			errs = append(errs, fmt.Errorf("%s: backoff must not be negative%.0w", name, ErrInvalidInput))
+		}
+		platforms := s.Platforms
+		if s.Platform != "" {
+			platforms = append([]string{s.Platform}, platforms...)
+		}
+		for _, p := range platforms {
+			if _, err := platform.Parse(p); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid platform %q: %w", name, p, err))
+			}
+		}
+		for _, wh := range s.Webhooks {
+			u, err := url.Parse(wh.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				errs = append(errs, fmt.Errorf("%s: invalid webhook url %q%.0w", name, wh.URL, ErrInvalidInput))
+			}
+			for _, event := range wh.Events {
+				if event != webhookCopied && event != webhookFailed && event != webhookDeleted && event != webhookUnhealthy {
+					errs = append(errs, fmt.Errorf("%s: unknown webhook event %q%.0w", name, event, ErrInvalidInput))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // expand templates in various parts of the config
 func configExpandTemplates(c *Config) error {
 	dataSync := struct {
 		Sync ConfigSync
 	}{}
 	for i := range c.Creds {
-		val, err := template.String(c.Creds[i].Name, nil)
+		err := c.Creds[i].ExpandTemplates()
 		if err != nil {
 			return err
 		}
-		c.Creds[i].Name = val
-		val, err = template.String(c.Creds[i].User, nil)
-		if err != nil {
-			return err
-		}
-		c.Creds[i].User = val
-		val, err = template.String(c.Creds[i].Pass, nil)
-		if err != nil {
-			return err
-		}
-		c.Creds[i].Pass = val
-		val, err = template.String(c.Creds[i].RegCert, nil)
-		if err != nil {
-			return err
-		}
-		c.Creds[i].RegCert = val
-		val, err = template.String(c.Creds[i].ClientCert, nil)
-		if err != nil {
-			return err
-		}
-		c.Creds[i].ClientCert = val
-		val, err = template.String(c.Creds[i].ClientKey, nil)
-		if err != nil {
-			return err
-		}
-		c.Creds[i].ClientKey = val
 	}
 	for i := range c.Sync {
 		dataSync.Sync = c.Sync[i]
@@ -226,6 +346,18 @@ func syncSetDefaults(s *ConfigSync, d ConfigDefaults) {
 	if s.Interval == 0 && s.Schedule == "" && d.Interval != 0 {
 		s.Interval = d.Interval
 	}
+	if s.Jitter == 0 {
+		s.Jitter = d.Jitter
+	}
+	if s.Backoff == 0 {
+		s.Backoff = d.Backoff
+	}
+	if s.BackoffMax == 0 {
+		s.BackoffMax = d.BackoffMax
+	}
+	if s.FailureThreshold == 0 {
+		s.FailureThreshold = d.FailureThreshold
+	}
 	if s.RateLimit.Min == 0 && d.RateLimit.Min != 0 {
 		s.RateLimit.Min = d.RateLimit.Min
 	}
@@ -260,10 +392,23 @@ func syncSetDefaults(s *ConfigSync, d ConfigDefaults) {
 		b := (d.ForceRecursive != nil && *d.ForceRecursive)
 		s.ForceRecursive = &b
 	}
+	if s.Immutable == nil {
+		s.Immutable = d.Immutable
+	}
+	if s.Scan == nil {
+		s.Scan = d.Scan
+	}
+	if s.BlobRate == 0 {
+		s.BlobRate = d.BlobRate
+	}
 	if s.IncludeExternal == nil {
 		b := (d.IncludeExternal != nil && *d.IncludeExternal)
 		s.IncludeExternal = &b
 	}
+	if s.IgnoreMissing == nil {
+		b := (d.IgnoreMissing != nil && *d.IgnoreMissing)
+		s.IgnoreMissing = &b
+	}
 	if s.Hooks.Pre == nil && d.Hooks.Pre != nil {
 		s.Hooks.Pre = d.Hooks.Pre
 	}
@@ -273,4 +418,7 @@ func syncSetDefaults(s *ConfigSync, d ConfigDefaults) {
 	if s.Hooks.Unchanged == nil && d.Hooks.Unchanged != nil {
 		s.Hooks.Unchanged = d.Hooks.Unchanged
 	}
+	if s.Webhooks == nil {
+		s.Webhooks = d.Webhooks
+	}
 }
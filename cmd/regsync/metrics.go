@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/regclient/regclient/types"
+	"github.com/sirupsen/logrus"
+)
+
+// syncMetrics tracks the status of each sync step for the metrics endpoint
+type syncMetrics struct {
+	mu      sync.Mutex
+	entries map[string]*syncMetricsEntry
+}
+
+type syncMetricsEntry struct {
+	source      string
+	target      string
+	lastRun     time.Time
+	lastSuccess time.Time
+	copied      int64
+	failed      int64
+	bytes       int64
+	failures    int       // consecutive failed runs
+	retryAfter  time.Time // scheduled runs are skipped until this time after a failure
+	unhealthy   bool
+}
+
+var metrics = newSyncMetrics()
+
+func newSyncMetrics() *syncMetrics {
+	return &syncMetrics{
+		entries: map[string]*syncMetricsEntry{},
+	}
+}
+
+// entry returns the metrics for a sync step, the lock must be held
+func (m *syncMetrics) entry(s ConfigSync) *syncMetricsEntry {
+	key := s.Source + " " + s.Target
+	e, ok := m.entries[key]
+	if !ok {
+		e = &syncMetricsEntry{source: s.Source, target: s.Target}
+		m.entries[key] = e
+	}
+	return e
+}
+
+// runDone records the completion of a sync step.
+// True is returned when the step has just reached the failure threshold.
+func (m *syncMetrics) runDone(s ConfigSync, err error) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.entry(s)
+	e.lastRun = time.Now()
+	if err == nil {
+		e.lastSuccess = e.lastRun
+		e.failures = 0
+		e.retryAfter = time.Time{}
+		e.unhealthy = false
+		return false
+	}
+	e.failures++
+	// double the delay for each consecutive failure, up to the max
+	if s.Backoff > 0 {
+		backoffMax := s.BackoffMax
+		if backoffMax <= 0 {
+			backoffMax = backoffMaxDefault
+		}
+		delay := s.Backoff
+		for i := 1; i < e.failures && delay < backoffMax; i++ {
+			delay *= 2
+		}
+		if delay > backoffMax {
+			delay = backoffMax
+		}
+		e.retryAfter = e.lastRun.Add(delay)
+	}
+	if s.FailureThreshold > 0 && e.failures >= s.FailureThreshold && !e.unhealthy {
+		e.unhealthy = true
+		return true
+	}
+	return false
+}
+
+// backoff returns the time until a failing sync step may run again, or a zero time when the step may run now
+func (m *syncMetrics) backoff(s ConfigSync) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.entry(s)
+	if e.retryAfter.IsZero() || time.Now().After(e.retryAfter) {
+		return time.Time{}
+	}
+	return e.retryAfter
+}
+
+// imageCopied records a successful image copy
+func (m *syncMetrics) imageCopied(s ConfigSync) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entry(s).copied++
+}
+
+// imageFailed records a failed image sync
+func (m *syncMetrics) imageFailed(s ConfigSync) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entry(s).failed++
+}
+
+// blobCallback returns an ImageCopy callback that counts the bytes of each copied blob
+func (m *syncMetrics) blobCallback(s ConfigSync) func(kind types.CallbackKind, instance string, state types.CallbackState, cur, total int64) {
+	return func(kind types.CallbackKind, instance string, state types.CallbackState, cur, total int64) {
+		if kind != types.CallbackBlob || state != types.CallbackFinished {
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.entry(s).bytes += total
+	}
+}
+
+// write outputs the metrics in the Prometheus text format
+func (m *syncMetrics) write(w io.Writer) error {
+	m.mu.Lock()
+	entries := make([]syncMetricsEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, *e)
+	}
+	m.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].source != entries[j].source {
+			return entries[i].source < entries[j].source
+		}
+		return entries[i].target < entries[j].target
+	})
+	list := []struct {
+		name, kind, help string
+		value            func(e syncMetricsEntry) string
+	}{
+		{"regsync_last_run_timestamp_seconds", "gauge", "Time the sync step last finished.", func(e syncMetricsEntry) string { return metricsTime(e.lastRun) }},
+		{"regsync_last_success_timestamp_seconds", "gauge", "Time the sync step last finished without an error.", func(e syncMetricsEntry) string { return metricsTime(e.lastSuccess) }},
+		{"regsync_images_copied_total", "counter", "Number of images copied to the target.", func(e syncMetricsEntry) string { return fmt.Sprintf("%d", e.copied) }},
+		{"regsync_bytes_transferred_total", "counter", "Number of blob bytes copied to the target.", func(e syncMetricsEntry) string { return fmt.Sprintf("%d", e.bytes) }},
+		{"regsync_failures_total", "counter", "Number of images that failed to sync.", func(e syncMetricsEntry) string { return fmt.Sprintf("%d", e.failed) }},
+		{"regsync_consecutive_failures", "gauge", "Number of consecutive runs of the sync step that failed.", func(e syncMetricsEntry) string { return fmt.Sprintf("%d", e.failures) }},
+		{"regsync_unhealthy", "gauge", "Set to 1 when the sync step has reached the failure threshold.", func(e syncMetricsEntry) string { return metricsBool(e.unhealthy) }},
+	}
+	for _, metric := range list {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			_, err = fmt.Fprintf(w, "%s{source=\"%s\",target=\"%s\"} %s\n", metric.name, metricsLabel(e.source), metricsLabel(e.target), metric.value(e))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *syncMetrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	err := m.write(w)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("Failed to write metrics")
+	}
+}
+
+// healthz returns a 503 listing each sync step that has reached the failure threshold
+func (m *syncMetrics) healthz(w http.ResponseWriter, req *http.Request) {
+	m.mu.Lock()
+	unhealthy := []string{}
+	for _, e := range m.entries {
+		if e.unhealthy {
+			unhealthy = append(unhealthy, e.source+" -> "+e.target)
+		}
+	}
+	m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain")
+	if len(unhealthy) > 0 {
+		sort.Strings(unhealthy)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("unhealthy: " + strings.Join(unhealthy, ", ") + "\n"))
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// metricsServe runs the metrics and health endpoints until the context is done
+func metricsServe(ctx context.Context, listen string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc("/healthz", metrics.healthz)
+	hs := &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 30,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		_ = hs.Shutdown(shutdownCtx)
+	}()
+	log.WithFields(logrus.Fields{
+		"listen": listen,
+	}).Info("Starting metrics server")
+	err := hs.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func metricsTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return fmt.Sprintf("%.3f", float64(t.UnixMilli())/1000)
+}
+
+func metricsBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+var metricsLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func metricsLabel(s string) string {
+	return metricsLabelReplacer.Replace(s)
+}
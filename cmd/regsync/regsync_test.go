@@ -3,15 +3,25 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient"
 	"github.com/regclient/regclient/internal/rwfs"
 	"github.com/regclient/regclient/internal/throttle"
 	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/scheme/ocidir"
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/manifest"
 	"github.com/regclient/regclient/types/platform"
@@ -177,6 +187,25 @@ func TestProcess(t *testing.T) {
 			},
 			expErr: nil,
 		},
+		{
+			name: "RepoCopyParallel",
+			sync: ConfigSync{
+				Source:   "ocidir://testrepo",
+				Target:   "ocidir://test-parallel",
+				Type:     "repository",
+				Parallel: 3,
+			},
+			action: actionCopy,
+			exists: []string{"ocidir://test-parallel:v1", "ocidir://test-parallel:v2", "ocidir://test-parallel:v3"},
+			desired: []string{
+				"test-parallel/index.json",
+				"test-parallel/oci-layout",
+				"test-parallel/blobs/sha256/" + d1.Hex(), // v1
+				"test-parallel/blobs/sha256/" + d2.Hex(), // v2
+				"test-parallel/blobs/sha256/" + d3.Hex(), // v3
+			},
+			expErr: nil,
+		},
 		{
 			name: "Overwrite",
 			sync: ConfigSync{
@@ -782,6 +811,621 @@ func TestProcessRef(t *testing.T) {
 	}
 }
 
+func TestProcessImmutable(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "../../testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc = regclient.New(regclient.WithFS(fsMem))
+	throttleC = throttle.New(1)
+	cs := ConfigSync{
+		Source: "ocidir://testrepo",
+		Target: "ocidir://testimmutable",
+		Type:   "repository",
+	}
+	syncSetDefaults(&cs, ConfigDefaults{
+		Immutable: &ConfigImmutable{Tags: []string{"rel.*"}},
+	})
+	if cs.Immutable == nil {
+		t.Fatalf("immutable default not applied")
+	}
+	rV1, _ := ref.New("ocidir://testrepo:v1")
+	rV2, _ := ref.New("ocidir://testrepo:v2")
+	rTgt, _ := ref.New("ocidir://testimmutable:release")
+	err = cs.processRef(ctx, rV1, rTgt, actionCopy)
+	if err != nil {
+		t.Errorf("failed to copy new tag: %v", err)
+	}
+	err = cs.processRef(ctx, rV2, rTgt, actionCopy)
+	if !errors.Is(err, types.ErrTagImmutable) {
+		t.Errorf("unexpected error overwriting immutable tag: %v", err)
+	}
+	cliOpts.force = true
+	defer func() {
+		cliOpts.force = false
+	}()
+	err = cs.processRef(ctx, rV2, rTgt, actionCopy)
+	if err != nil {
+		t.Errorf("failed to force overwrite: %v", err)
+	}
+}
+
+func TestProcessIgnoreMissing(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "../../testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc = regclient.New(regclient.WithFS(fsMem))
+	throttleC = throttle.New(1)
+	confOrig := conf
+	conf = &Config{}
+	defer func() {
+		conf = confOrig
+	}()
+	boolTrue := true
+	cs := ConfigSync{
+		Source: "ocidir://testrepo",
+		Target: "ocidir://testmissing",
+		Type:   "image",
+	}
+	syncSetDefaults(&cs, ConfigDefaults{})
+	err = cs.processImage(ctx, "ocidir://testrepo:missing", "ocidir://testmissing:missing", actionCopy)
+	if err == nil {
+		t.Errorf("copy of a missing source did not fail")
+	}
+	syncSetDefaults(&cs, ConfigDefaults{})
+	cs.IgnoreMissing = &boolTrue
+	err = cs.processImage(ctx, "ocidir://testrepo:missing", "ocidir://testmissing:missing", actionCopy)
+	if err != nil {
+		t.Errorf("missing source image was not ignored: %v", err)
+	}
+	err = cs.processRepo(ctx, "ocidir://missingrepo", "ocidir://testmissing", actionCopy)
+	if err != nil {
+		t.Errorf("missing source repository was not ignored: %v", err)
+	}
+	err = cs.processImage(ctx, "ocidir://testrepo:v1", "ocidir://testmissing:v1", actionCopy)
+	if err != nil {
+		t.Errorf("failed to copy existing image: %v", err)
+	}
+}
+
+func TestProcessRepoPlan(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "../../testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	rc = regclient.New(regclient.WithFS(fsMem))
+	throttleC = throttle.New(1)
+	confOrig := conf
+	conf = &Config{}
+	defer func() {
+		conf = confOrig
+	}()
+	cs := ConfigSync{
+		Source: "ocidir://testrepo",
+		Target: "ocidir://testplan",
+		Type:   "repository",
+	}
+	syncSetDefaults(&cs, ConfigDefaults{})
+	cs.batch = &regclient.ImageCopyBatch{}
+	cs.processRepoPlan(ctx, cs.Source, cs.Target, []string{"v1", "v2", "v3"}, actionCopy, throttle.New(2))
+	// the tags share layers, each missing blob is only planned once
+	if len(cs.batch.Blobs) == 0 {
+		t.Fatalf("no blobs were planned")
+	}
+	unique := map[digest.Digest]bool{}
+	for _, d := range cs.batch.Blobs {
+		if unique[d.Digest] {
+			t.Errorf("duplicate blob in plan: %s", d.Digest)
+		}
+		unique[d.Digest] = true
+	}
+	// nothing is copied by the plan
+	rTgt, err := ref.New("ocidir://testplan:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	_, err = rc.ManifestHead(ctx, rTgt)
+	if err == nil {
+		t.Errorf("target was copied when planning")
+	}
+	err = cs.processRepo(ctx, cs.Source, cs.Target, actionCopy)
+	if err != nil {
+		t.Fatalf("failed to sync repository: %v", err)
+	}
+	_, err = rc.ManifestHead(ctx, rTgt)
+	if err != nil {
+		t.Errorf("target is missing after the sync: %v", err)
+	}
+}
+
+// countScheme counts the manifest requests to the source
+type countScheme struct {
+	scheme.API
+	mu    sync.Mutex
+	gets  map[string]int
+	heads int
+}
+
+func (cs *countScheme) ManifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	cs.mu.Lock()
+	cs.gets[r.CommonName()]++
+	cs.mu.Unlock()
+	return cs.API.ManifestGet(ctx, r)
+}
+
+func (cs *countScheme) ManifestHead(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	cs.mu.Lock()
+	cs.heads++
+	cs.mu.Unlock()
+	return cs.API.ManifestHead(ctx, r)
+}
+
+func (cs *countScheme) Throttle(r ref.Ref, put bool) []*throttle.Throttle {
+	if t, ok := cs.API.(scheme.Throttler); ok {
+		return t.Throttle(r, put)
+	}
+	return nil
+}
+
+func TestProcessRepoPlanReuse(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "../../testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	cnt := &countScheme{API: ocidir.New(ocidir.WithFS(fsMem)), gets: map[string]int{}}
+	// regsync parses the config with ref.New
+	ref.RegisterPathScheme("count")
+	rc = regclient.New(regclient.WithFS(fsMem), regclient.WithScheme("count", cnt))
+	throttleC = throttle.New(1)
+	confOrig := conf
+	conf = &Config{}
+	defer func() {
+		conf = confOrig
+	}()
+	cs := ConfigSync{
+		Source: "count://testrepo",
+		Target: "ocidir://testplanreuse",
+		Type:   "repository",
+		Tags:   ConfigTags{Allow: []string{"v1", "v2", "v3"}},
+	}
+	syncSetDefaults(&cs, ConfigDefaults{})
+	err = cs.processRepo(ctx, cs.Source, cs.Target, actionCopy)
+	if err != nil {
+		t.Fatalf("failed to sync repository: %v", err)
+	}
+	// the copy reuses the comparison and manifests from the plan
+	if cnt.heads != 3 {
+		t.Errorf("unexpected number of source head requests, expected 3, received %d", cnt.heads)
+	}
+	for r, count := range cnt.gets {
+		if count > 1 {
+			t.Errorf("source manifest %s was requested %d times", r, count)
+		}
+	}
+	for _, tag := range []string{"v1", "v2", "v3"} {
+		rTgt, err := ref.New(cs.Target + ":" + tag)
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		_, err = rc.ManifestHead(ctx, rTgt)
+		if err != nil {
+			t.Errorf("target %s is missing after the sync: %v", tag, err)
+		}
+	}
+}
+
+// rateLimitScheme returns a rate limit error on every manifest get
+type rateLimitScheme struct {
+	scheme.API
+	mu   sync.Mutex
+	gets int
+}
+
+func (rls *rateLimitScheme) ManifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	rls.mu.Lock()
+	rls.gets++
+	rls.mu.Unlock()
+	return nil, fmt.Errorf("test limit%.0w", types.ErrHTTPRateLimit)
+}
+
+func TestProcessRateLimit(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "../../testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rls := &rateLimitScheme{API: ocidir.New(ocidir.WithFS(fsMem))}
+	rc = regclient.New(regclient.WithFS(fsMem), regclient.WithScheme("ratelimit", rls))
+	throttleC = throttle.New(1)
+	confOrig := conf
+	conf = &Config{}
+	retryMaxOrig := rateLimitRetryMax
+	rateLimitRetryMax = 2
+	defer func() {
+		conf = confOrig
+		rateLimitRetryMax = retryMaxOrig
+	}()
+	cs := ConfigSync{
+		Source: "ratelimit://testrepo",
+		Target: "ocidir://testratelimit",
+		Type:   "image",
+	}
+	syncSetDefaults(&cs, ConfigDefaults{})
+	cs.RateLimit = ConfigRateLimit{Min: 1, Retry: time.Millisecond}
+	rSrc, err := rc.RefNew("ratelimit://testrepo:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	rTgt, err := ref.New("ocidir://testratelimit:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	err = cs.processRef(ctx, rSrc, rTgt, actionCopy)
+	if !errors.Is(err, types.ErrHTTPRateLimit) {
+		t.Errorf("unexpected error, expected rate limit, received %v", err)
+	}
+	if rls.gets != rateLimitRetryMax+1 {
+		t.Errorf("unexpected number of copies, expected %d, received %d", rateLimitRetryMax+1, rls.gets)
+	}
+}
+
+func TestProcessShutdown(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	fsOS := rwfs.OSNew("")
+	err := rwfs.CopyRecursive(fsOS, "../../testdata", fsOS, tempDir)
+	if err != nil {
+		t.Fatalf("failed to setup testdata copy: %v", err)
+	}
+	stateDir := filepath.Join(tempDir, "state")
+	err = os.MkdirAll(stateDir, 0700)
+	if err != nil {
+		t.Fatalf("failed to create state dir: %v", err)
+	}
+	rc = regclient.New()
+	throttleC = throttle.New(1)
+	confOrig := conf
+	conf = &Config{Defaults: ConfigDefaults{StateDir: stateDir}}
+	defer func() {
+		conf = confOrig
+	}()
+	cs := ConfigSync{
+		Source: "ocidir://" + tempDir + "/testrepo",
+		Target: "ocidir://" + tempDir + "/testshutdown",
+		Type:   "repository",
+	}
+	syncSetDefaults(&cs, conf.Defaults)
+	src, _ := ref.New(cs.Source + ":v1")
+	tgt, _ := ref.New(cs.Target + ":v1")
+	// after a signal, no new copies are started
+	shutdownOrig := shutdown
+	shutdown = make(chan struct{})
+	close(shutdown)
+	err = cs.processRef(ctx, src, tgt, actionCopy)
+	shutdown = shutdownOrig
+	if !errors.Is(err, ErrCanceled) {
+		t.Errorf("unexpected error after shutdown: %v", err)
+	}
+	if _, err := rc.ManifestHead(ctx, tgt); err == nil {
+		t.Errorf("target was copied after shutdown")
+	}
+	// the state file is removed after a successful copy
+	err = cs.processRef(ctx, src, tgt, actionCopy)
+	if err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	entries, err := os.ReadDir(stateDir)
+	if err != nil {
+		t.Fatalf("failed to read state dir: %v", err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("state dir not cleaned up: %v", entries)
+	}
+}
+
+func TestJitterWait(t *testing.T) {
+	ctx := context.Background()
+	s := ConfigSync{Jitter: time.Millisecond}
+	if !s.jitterWait(ctx) {
+		t.Errorf("jitter wait interrupted")
+	}
+	// a shutdown interrupts the wait
+	s.Jitter = time.Hour
+	shutdownOrig := shutdown
+	shutdown = make(chan struct{})
+	close(shutdown)
+	defer func() {
+		shutdown = shutdownOrig
+	}()
+	start := time.Now()
+	if s.jitterWait(ctx) {
+		t.Errorf("jitter wait not interrupted by shutdown")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("jitter wait did not return after shutdown")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "../../testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc = regclient.New(regclient.WithFS(fsMem))
+	throttleC = throttle.New(1)
+	// collect events sent to the webhook
+	var mu sync.Mutex
+	events := []WebhookEvent{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event WebhookEvent
+		err := json.NewDecoder(req.Body).Decode(&event)
+		if err != nil {
+			t.Errorf("failed to decode event: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Header.Get("X-Test") != "test" {
+			t.Errorf("missing header on webhook")
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	// resetEvents clears and returns the events received since the last call
+	resetEvents := func() []WebhookEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		ret := events
+		events = []WebhookEvent{}
+		return ret
+	}
+	webhookDeletedSent.mu.Lock()
+	webhookDeletedSent.tags = map[string]string{}
+	webhookDeletedSent.mu.Unlock()
+	cs := ConfigSync{
+		Source: "ocidir://testrepo",
+		Target: "ocidir://testwebhook",
+		Type:   "repository",
+		Tags: ConfigTags{
+			Allow: []string{"v1", "gone"},
+		},
+		Webhooks: []ConfigWebhook{
+			{
+				URL:     ts.URL,
+				Headers: map[string]string{"X-Test": "test"},
+			},
+		},
+	}
+	syncSetDefaults(&cs, ConfigDefaults{})
+	// create a tag on the target that does not exist on the source
+	rSrc, err := ref.New("ocidir://testrepo:v2")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	rGone, err := ref.New("ocidir://testwebhook:gone")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	err = rc.ImageCopy(ctx, rSrc, rGone)
+	if err != nil {
+		t.Errorf("failed to copy image: %v", err)
+		return
+	}
+	rV1, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	mV1, err := rc.ManifestHead(ctx, rV1)
+	if err != nil {
+		t.Errorf("failed to head v1: %v", err)
+		return
+	}
+
+	t.Run("Copy and Delete", func(t *testing.T) {
+		resetEvents()
+		err := cs.process(ctx, actionCopy)
+		events := resetEvents()
+		if err != nil {
+			t.Errorf("failed to process: %v", err)
+			return
+		}
+		if len(events) != 2 {
+			t.Errorf("unexpected events: %v", events)
+			return
+		}
+		if events[0].Event != webhookDeleted || events[0].Target != rGone.CommonName() {
+			t.Errorf("unexpected deleted event: %v", events[0])
+		}
+		if events[1].Event != webhookCopied || events[1].Digest != mV1.GetDescriptor().Digest.String() {
+			t.Errorf("unexpected copied event: %v", events[1])
+		}
+	})
+	t.Run("Deleted once", func(t *testing.T) {
+		resetEvents()
+		err := cs.process(ctx, actionCopy)
+		events := resetEvents()
+		if err != nil {
+			t.Errorf("failed to process: %v", err)
+			return
+		}
+		if len(events) != 0 {
+			t.Errorf("unexpected events: %v", events)
+		}
+	})
+	t.Run("Unchanged", func(t *testing.T) {
+		resetEvents()
+		cs.Webhooks[0].Events = []string{webhookCopied}
+		err := cs.process(ctx, actionCopy)
+		cs.Webhooks[0].Events = nil
+		events := resetEvents()
+		if err != nil {
+			t.Errorf("failed to process: %v", err)
+			return
+		}
+		if len(events) != 0 {
+			t.Errorf("unexpected events: %v", events)
+		}
+	})
+	t.Run("Failed", func(t *testing.T) {
+		resetEvents()
+		err := cs.processImage(ctx, "ocidir://testrepo:missing", "ocidir://testwebhook:missing", actionCopy)
+		events := resetEvents()
+		if err == nil {
+			t.Errorf("process of missing image did not fail")
+		}
+		if len(events) != 1 || events[0].Event != webhookFailed || events[0].Error == "" {
+			t.Errorf("unexpected events: %v", events)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	m := newSyncMetrics()
+	cs := ConfigSync{Source: "registry.example.org/src", Target: "registry.example.org/\"tgt\""}
+	m.imageCopied(cs)
+	m.imageCopied(cs)
+	m.imageFailed(cs)
+	cb := m.blobCallback(cs)
+	cb(types.CallbackBlob, "sha256:1234", types.CallbackStarted, 0, 100)
+	cb(types.CallbackBlob, "sha256:1234", types.CallbackFinished, 100, 100)
+	cb(types.CallbackManifest, "sha256:5678", types.CallbackFinished, 50, 50)
+	m.runDone(cs, fmt.Errorf("failed"))
+	resp := httptest.NewRecorder()
+	m.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if resp.Code != http.StatusOK {
+		t.Errorf("unexpected status: %d", resp.Code)
+	}
+	body := resp.Body.String()
+	labels := `{source="registry.example.org/src",target="registry.example.org/\"tgt\""}`
+	for _, line := range []string{
+		"# TYPE regsync_images_copied_total counter",
+		"regsync_images_copied_total" + labels + " 2",
+		"regsync_failures_total" + labels + " 1",
+		"regsync_bytes_transferred_total" + labels + " 100",
+		"regsync_last_success_timestamp_seconds" + labels + " 0",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing metric %s in output:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "regsync_last_run_timestamp_seconds"+labels+" 0\n") {
+		t.Errorf("last run time not set:\n%s", body)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	m := newSyncMetrics()
+	cs := ConfigSync{
+		Source:           "registry.example.org/src",
+		Target:           "registry.example.org/tgt",
+		Backoff:          time.Minute,
+		BackoffMax:       3 * time.Minute,
+		FailureThreshold: 3,
+	}
+	errFail := fmt.Errorf("failed")
+	if !m.backoff(cs).IsZero() {
+		t.Errorf("backoff before any failure")
+	}
+	for i, exp := range []struct {
+		delay     time.Duration
+		unhealthy bool
+	}{
+		{delay: time.Minute},
+		{delay: 2 * time.Minute},
+		{delay: 3 * time.Minute, unhealthy: true},
+		{delay: 3 * time.Minute},
+	} {
+		unhealthy := m.runDone(cs, errFail)
+		if unhealthy != exp.unhealthy {
+			t.Errorf("failure %d: unexpected unhealthy result %t", i+1, unhealthy)
+		}
+		retry := m.backoff(cs)
+		delay := time.Until(retry)
+		if delay > exp.delay || delay < exp.delay-time.Second {
+			t.Errorf("failure %d: unexpected delay %s, expected %s", i+1, delay, exp.delay)
+		}
+	}
+	resp := httptest.NewRecorder()
+	m.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	labels := `{source="registry.example.org/src",target="registry.example.org/tgt"}`
+	for _, line := range []string{
+		"regsync_consecutive_failures" + labels + " 4",
+		"regsync_unhealthy" + labels + " 1",
+	} {
+		if !strings.Contains(resp.Body.String(), line+"\n") {
+			t.Errorf("missing metric %s in output:\n%s", line, resp.Body.String())
+		}
+	}
+	// a success resets the backoff
+	if m.runDone(cs, nil) {
+		t.Errorf("unhealthy after success")
+	}
+	if !m.backoff(cs).IsZero() {
+		t.Errorf("backoff after success")
+	}
+	resp = httptest.NewRecorder()
+	m.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(resp.Body.String(), "regsync_unhealthy"+labels+" 0\n") {
+		t.Errorf("unhealthy not reset:\n%s", resp.Body.String())
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	m := newSyncMetrics()
+	cs := ConfigSync{
+		Source:           "registry.example.org/src",
+		Target:           "registry.example.org/tgt",
+		FailureThreshold: 2,
+	}
+	errFail := fmt.Errorf("failed")
+	for i, exp := range []struct {
+		err  error
+		code int
+		body string
+	}{
+		{err: errFail, code: http.StatusOK, body: "ok\n"},
+		{err: errFail, code: http.StatusServiceUnavailable, body: "unhealthy: registry.example.org/src -> registry.example.org/tgt\n"},
+		{err: nil, code: http.StatusOK, body: "ok\n"},
+	} {
+		m.runDone(cs, exp.err)
+		resp := httptest.NewRecorder()
+		m.healthz(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if resp.Code != exp.code {
+			t.Errorf("run %d: unexpected status %d, expected %d", i+1, resp.Code, exp.code)
+		}
+		if resp.Body.String() != exp.body {
+			t.Errorf("run %d: unexpected body %q, expected %q", i+1, resp.Body.String(), exp.body)
+		}
+	}
+}
+
 func TestConfigRead(t *testing.T) {
 	// CAUTION: the below yaml is space indented and will not parse with tabs
 	cRead := bytes.NewReader([]byte(`
@@ -799,6 +1443,8 @@ func TestConfigRead(t *testing.T) {
       backup: "bkup-{{.Ref.Tag}}"
       cacheCount: 500
       cacheTime: "5m"
+      blobRate: 1048576
+      jitter: 5m
     x-sync-hub: &sync-hub
       target: registry:5000/hub/{{ .Sync.Source }}
     x-sync-gcr: &sync-gcr
@@ -810,6 +1456,8 @@ func TestConfigRead(t *testing.T) {
       - <<: *sync-hub
         source: alpine
         type: repository
+        blobRate: 65536
+        jitter: 30s
         tags:
           allow:
           - 3
@@ -835,5 +1483,148 @@ func TestConfigRead(t *testing.T) {
 	if c.Sync[2].Target != "registry:5000/gcr/example/repo" {
 		t.Errorf("template sync-gcr mismatch, expected: %s, received: %s", "registry:5000/gcr/example/repo", c.Sync[2].Target)
 	}
+	if c.Sync[1].BlobRate != 65536 {
+		t.Errorf("blobRate override mismatch, expected: %d, received: %d", 65536, c.Sync[1].BlobRate)
+	}
+	if c.Sync[2].BlobRate != 1048576 {
+		t.Errorf("blobRate default mismatch, expected: %d, received: %d", 1048576, c.Sync[2].BlobRate)
+	}
+	if c.Sync[1].Jitter != 30*time.Second || c.Sync[2].Jitter != 5*time.Minute {
+		t.Errorf("jitter mismatch, expected 30s and 5m, received: %s and %s", c.Sync[1].Jitter, c.Sync[2].Jitter)
+	}
 	// TODO: test remainder of templates and parsing
 }
+
+func TestConfigSecrets(t *testing.T) {
+	passFile := filepath.Join(t.TempDir(), "pass")
+	err := os.WriteFile(passFile, []byte("secret\n"), 0600)
+	if err != nil {
+		t.Errorf("failed to write pass file: %v", err)
+		return
+	}
+	t.Setenv("REGSYNC_TEST_USER", "testuser")
+	t.Setenv("REGSYNC_TEST_HOST", "registry.example.org")
+	cRead := strings.NewReader(`
+version: 1
+creds:
+  - registry: '{{ env "REGSYNC_TEST_HOST" }}'
+    user: '{{ env "REGSYNC_TEST_USER" }}'
+    pass: '{{ file "` + passFile + `" }}'
+sync: []
+`)
+	c, err := ConfigLoadReader(cRead)
+	if err != nil {
+		t.Errorf("failed to load reader: %v", err)
+		return
+	}
+	if len(c.Creds) != 1 {
+		t.Errorf("unexpected creds: %v", c.Creds)
+		return
+	}
+	if c.Creds[0].Name != "registry.example.org" || c.Creds[0].User != "testuser" || c.Creds[0].Pass != "secret" {
+		t.Errorf("templates not expanded, registry %s, user %s, pass %s", c.Creds[0].Name, c.Creds[0].User, c.Creds[0].Pass)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tt := []struct {
+		name   string
+		conf   string
+		expErr []string
+	}{
+		{
+			name: "valid",
+			conf: `
+    version: 1
+    creds:
+      - registry: registry:5000
+        user: user
+        pass: secret
+    sync:
+      - source: busybox
+        target: registry:5000/library/busybox
+        type: repository
+        schedule: "15 3 * * *"
+        platforms: ["linux/amd64", "linux/arm64"]
+        tags:
+          allow: ["1\\.[0-9]+"]
+        scan:
+          type: trivy
+          server: http://trivy:4954
+          severity: high
+        webhooks:
+          - url: https://example.com/hook
+            events: [copied]
+      - source: registry:5000
+        target: registry:5001
+        type: registry
+        schedule: "@every 1h"
+`,
+		},
+		{
+			name: "invalid",
+			conf: `
+    version: 1
+    creds:
+      - registry: registry:5000
+        user: user
+      - registry: registry:5001
+        credHelper: docker-credential-missing-helper
+    sync:
+      - source: busybox
+        target: registry:5000/Invalid
+        type: repository
+        schedule: "61 * * * *"
+        platform: "linux/amd 64"
+        tags:
+          allow: ["1.[0-9"]
+      - source: alpine
+        target: registry:5000/alpine
+        type: repo
+        scan:
+          type: grype
+          server: http://trivy:4954
+          severity: severe
+        webhooks:
+          - url: example.com/hook
+            events: [pushed]
+`,
+			expErr: []string{
+				"creds[0]: registry:5000: user user is set without a password",
+				"creds[1]: registry:5001: credential helper not found",
+				"sync[0]: invalid reference \"registry:5000/Invalid\"",
+				"sync[0]: invalid tag filter \"1.[0-9\"",
+				"sync[0]: invalid schedule",
+				"sync[0]: invalid platform",
+				"sync[1]: type \"repo\" must be one of",
+				"sync[1]: invalid scan: server is not supported with grype",
+				"sync[1]: invalid scan severity",
+				"sync[1]: invalid webhook url",
+				"sync[1]: unknown webhook event \"pushed\"",
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ConfigLoadReader(strings.NewReader(tc.conf))
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			err = ConfigValidate(c)
+			if len(tc.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validate did not fail")
+			}
+			for _, exp := range tc.expErr {
+				if !strings.Contains(err.Error(), exp) {
+					t.Errorf("error missing %q: %v", exp, err)
+				}
+			}
+		})
+	}
+}
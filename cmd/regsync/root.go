@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
@@ -19,6 +22,8 @@ import (
 	"github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/throttle"
 	"github.com/regclient/regclient/internal/version"
+	"github.com/regclient/regclient/pkg/bwlimit"
+	"github.com/regclient/regclient/pkg/scan"
 	"github.com/regclient/regclient/pkg/template"
 	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/scheme/reg"
@@ -47,11 +52,14 @@ const (
 )
 
 var cliOpts struct {
-	confFile  string
-	verbosity string
-	logopts   []string
-	format    string // for Go template formatting of various commands
-	missing   bool
+	confFile        string
+	verbosity       string
+	logopts         []string
+	format          string // for Go template formatting of various commands
+	force           bool
+	listen          string
+	missing         bool
+	shutdownTimeout time.Duration
 }
 
 var (
@@ -103,6 +111,16 @@ var configCmd = &cobra.Command{
 	RunE:  runConfig,
 }
 
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the config",
+	Long: `Validate the config without running any sync steps.
+References, credentials, tag filters, platforms, schedules, and webhooks are
+checked, and the config with defaults applied is output.`,
+	Args: cobra.RangeArgs(0, 0),
+	RunE: runValidate,
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show the version",
@@ -121,19 +139,24 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&cliOpts.confFile, "config", "c", "", "Config file")
 	rootCmd.PersistentFlags().StringVarP(&cliOpts.verbosity, "verbosity", "v", logrus.InfoLevel.String(), "Log level (debug, info, warn, error, fatal, panic)")
 	rootCmd.PersistentFlags().StringArrayVar(&cliOpts.logopts, "logopt", []string{}, "Log options")
+	rootCmd.PersistentFlags().BoolVar(&cliOpts.force, "force", false, "Overwrite tags protected by the immutable setting")
+	rootCmd.PersistentFlags().DurationVar(&cliOpts.shutdownTimeout, "shutdown-timeout", 10*time.Second, "Time for running tasks to finish after a signal before they are canceled")
 	versionCmd.Flags().StringVar(&cliOpts.format, "format", "{{printPretty .}}", "Format output with go template syntax")
 	onceCmd.Flags().BoolVar(&cliOpts.missing, "missing", false, "Only copy tags that are missing on target")
+	serverCmd.Flags().StringVar(&cliOpts.listen, "listen", "", "Address to serve /metrics and /healthz, e.g. \":8080\"")
 
 	rootCmd.MarkPersistentFlagFilename("config")
 	serverCmd.MarkPersistentFlagRequired("config")
 	checkCmd.MarkPersistentFlagRequired("config")
 	onceCmd.MarkPersistentFlagRequired("config")
 	configCmd.MarkPersistentFlagRequired("config")
+	validateCmd.MarkPersistentFlagRequired("config")
 
 	rootCmd.AddCommand(serverCmd)
 	rootCmd.AddCommand(checkCmd)
 	rootCmd.AddCommand(onceCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(validateCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	rootCmd.PersistentPreRunE = rootPreRun
@@ -169,6 +192,19 @@ func runConfig(cmd *cobra.Command, args []string) error {
 	return ConfigWrite(conf, cmd.OutOrStdout())
 }
 
+// runValidate checks the config for errors before it is used by the other commands
+func runValidate(cmd *cobra.Command, args []string) error {
+	err := readConf()
+	if err != nil {
+		return err
+	}
+	err = ConfigValidate(conf)
+	if err != nil {
+		return err
+	}
+	return ConfigWrite(conf, cmd.OutOrStdout())
+}
+
 // runOnce processes the file in one pass, ignoring cron
 func runOnce(cmd *cobra.Command, args []string) error {
 	err := loadConf()
@@ -184,6 +220,12 @@ func runOnce(cmd *cobra.Command, args []string) error {
 	var mainErr error
 	for _, s := range conf.Sync {
 		s := s
+		if shuttingDown() {
+			if mainErr == nil {
+				mainErr = ErrCanceled
+			}
+			break
+		}
 		if conf.Defaults.Parallel > 0 {
 			wg.Add(1)
 			go func() {
@@ -218,6 +260,17 @@ func runServer(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	var wg sync.WaitGroup
 	var mainErr error
+	if cliOpts.listen != "" {
+		go func() {
+			err := metricsServe(ctx, cliOpts.listen)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"listen": cliOpts.listen,
+					"error":  err,
+				}).Error("Metrics server failed")
+			}
+		}()
+	}
 	c := cron.New(cron.WithChain(
 		cron.SkipIfStillRunning(cron.DefaultLogger),
 	))
@@ -235,13 +288,24 @@ func runServer(cmd *cobra.Command, args []string) error {
 				"sched":  sched,
 			}).Debug("Scheduled task")
 			c.AddFunc(sched, func() {
+				wg.Add(1)
+				defer wg.Done()
+				if retry := metrics.backoff(s); !retry.IsZero() {
+					log.WithFields(logrus.Fields{
+						"source": s.Source,
+						"target": s.Target,
+						"retry":  retry.Format(time.RFC3339),
+					}).Info("Skipping run after previous failures")
+					return
+				}
+				if !s.jitterWait(ctx) {
+					return
+				}
 				log.WithFields(logrus.Fields{
 					"source": s.Source,
 					"target": s.Target,
 					"type":   s.Type,
 				}).Debug("Running task")
-				wg.Add(1)
-				defer wg.Done()
 				err := s.process(ctx, actionCopy)
 				if mainErr == nil {
 					mainErr = err
@@ -280,9 +344,9 @@ func runServer(cmd *cobra.Command, args []string) error {
 	wg.Wait()
 	c.Start()
 	// wait on interrupt signal
-	done := ctx.Done()
-	if done != nil {
-		<-done
+	select {
+	case <-ctx.Done():
+	case <-shutdown:
 	}
 	log.WithFields(logrus.Fields{}).Info("Stopping server")
 	// clean shutdown
@@ -311,7 +375,8 @@ func runCheck(cmd *cobra.Command, args []string) error {
 	return mainErr
 }
 
-func loadConf() error {
+// readConf parses the config file without any other setup
+func readConf() error {
 	var err error
 	if cliOpts.confFile == "-" {
 		conf, err = ConfigLoadReader(os.Stdin)
@@ -331,6 +396,14 @@ func loadConf() error {
 	} else {
 		return ErrMissingInput
 	}
+	return nil
+}
+
+func loadConf() error {
+	err := readConf()
+	if err != nil {
+		return err
+	}
 	// use a throttle to control parallelism
 	concurrent := conf.Defaults.Parallel
 	if concurrent <= 0 {
@@ -340,6 +413,12 @@ func loadConf() error {
 		"concurrent": concurrent,
 	}).Debug("Configuring parallel settings")
 	throttleC = throttle.New(concurrent)
+	if conf.Defaults.StateDir != "" {
+		err = os.MkdirAll(conf.Defaults.StateDir, 0700)
+		if err != nil {
+			return fmt.Errorf("failed to create state dir %s: %w", conf.Defaults.StateDir, err)
+		}
+	}
 	// set the regclient, loading docker creds unless disabled, and inject logins from config file
 	rcOpts := []regclient.Opt{
 		regclient.WithLog(log),
@@ -347,6 +426,12 @@ func loadConf() error {
 	if conf.Defaults.BlobLimit != 0 {
 		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithBlobLimit(conf.Defaults.BlobLimit)))
 	}
+	if conf.Defaults.BlobParallel > 0 {
+		rcOpts = append(rcOpts, regclient.WithBlobConcurrent(conf.Defaults.BlobParallel))
+	}
+	if conf.Defaults.BlobSpool > 0 {
+		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithBlobSpool("", conf.Defaults.BlobSpool)))
+	}
 	if conf.Defaults.CacheCount > 0 && conf.Defaults.CacheTime > 0 {
 		rcOpts = append(rcOpts, regclient.WithRegOpts(reg.WithCache(conf.Defaults.CacheTime, conf.Defaults.CacheCount)))
 	}
@@ -379,8 +464,52 @@ func loadConf() error {
 	return nil
 }
 
+// jitterWait delays a scheduled run by a random duration up to the jitter setting.
+// False is returned if the wait is interrupted by a cancel or shutdown.
+func (s ConfigSync) jitterWait(ctx context.Context) bool {
+	if s.Jitter <= 0 {
+		return true
+	}
+	delay := time.Duration(rand.Int63n(int64(s.Jitter)))
+	log.WithFields(logrus.Fields{
+		"source": s.Source,
+		"target": s.Target,
+		"delay":  delay.String(),
+	}).Debug("Delaying task for jitter")
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-shutdown:
+		return false
+	}
+}
+
 // process a sync step
-func (s ConfigSync) process(ctx context.Context, action actionType) error {
+func (s ConfigSync) process(ctx context.Context, action actionType) (err error) {
+	if action != actionCheck {
+		defer func() {
+			if metrics.runDone(s, err) {
+				log.WithFields(logrus.Fields{
+					"source":   s.Source,
+					"target":   s.Target,
+					"failures": s.FailureThreshold,
+					"error":    err,
+				}).Error("Sync step is unhealthy after repeated failures")
+				s.webhookSend(ctx, WebhookEvent{
+					Event:  webhookUnhealthy,
+					Source: s.Source,
+					Target: s.Target,
+					Error:  err.Error(),
+				})
+			}
+		}()
+	}
+	// the blob rate is shared by every image copied in this sync entry
+	ctx = bwlimit.NewContext(ctx, bwlimit.New(s.BlobRate))
 	switch s.Type {
 	case "registry":
 		if err := s.processRegistry(ctx, s.Source, s.Target, action); err != nil {
@@ -451,6 +580,13 @@ func (s ConfigSync) processRepo(ctx context.Context, src, tgt string, action act
 		return err
 	}
 	sTags, err := rc.TagList(ctx, sRepoRef)
+	if err != nil && s.IgnoreMissing != nil && *s.IgnoreMissing && (errors.Is(err, types.ErrNotFound) || errors.Is(err, fs.ErrNotExist)) {
+		log.WithFields(logrus.Fields{
+			"source": sRepoRef.CommonName(),
+			"error":  err,
+		}).Warn("Source repository is missing, skipping")
+		return nil
+	}
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"source": sRepoRef.CommonName(),
@@ -485,6 +621,10 @@ func (s ConfigSync) processRepo(ctx context.Context, src, tgt string, action act
 		}).Warn("No matching tags found")
 		return nil
 	}
+	// notify when tags on the target were deleted from the source
+	if action != actionCheck && s.webhookWants(webhookDeleted) {
+		s.processRepoDeleted(ctx, src, tgt, sTagList)
+	}
 	// if only copying missing entries, delete tags that already exist on target
 	if action == actionMissing {
 		tRepoRef, err := ref.New(tgt)
@@ -535,15 +675,179 @@ func (s ConfigSync) processRepo(ctx context.Context, src, tgt string, action act
 			}
 		}
 	}
+	// blobs shared between the tags are checked and copied once
+	s.batch = &regclient.ImageCopyBatch{}
+	s.planned = &syncPlan{}
+	parallel := s.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	throttleS := throttle.New(parallel)
+	if action != actionCheck {
+		s.processRepoPlan(ctx, src, tgt, sTagList, action, throttleS)
+	}
+	// process tags concurrently up to the per-sync limit, copies are also limited by the global parallel setting
 	var retErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
 	for _, tag := range sTagList {
-		if err := s.processImage(ctx, fmt.Sprintf("%s:%s", src, tag), fmt.Sprintf("%s:%s", tgt, tag), action); err != nil {
-			retErr = err
+		if err := throttleS.Acquire(ctx); err != nil {
+			mu.Lock()
+			retErr = ErrCanceled
+			mu.Unlock()
+			break
 		}
+		tag := tag
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer throttleS.Release(ctx)
+			if err := s.processImage(ctx, fmt.Sprintf("%s:%s", src, tag), fmt.Sprintf("%s:%s", tgt, tag), action); err != nil {
+				mu.Lock()
+				retErr = err
+				mu.Unlock()
+			}
+		}()
 	}
+	wg.Wait()
 	return retErr
 }
 
+// processRepoPlan compares each tag with the target before any content is copied.
+// The union of blobs missing from the target is added to the batch, so each unique blob is transferred once,
+// and blobs already on the target are not checked again by the copy.
+// The comparison and source manifests of each tag are reused by the copy, and failures are left for the copy to report.
+func (s ConfigSync) processRepoPlan(ctx context.Context, src, tgt string, sTagList []string, action actionType, throttleS *throttle.Throttle) {
+	s.planOnly = true
+	var wg sync.WaitGroup
+	for _, tag := range sTagList {
+		if err := throttleS.Acquire(ctx); err != nil {
+			break
+		}
+		tag := tag
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer throttleS.Release(ctx)
+			sRef, err := ref.New(fmt.Sprintf("%s:%s", src, tag))
+			if err != nil {
+				return
+			}
+			tRef, err := ref.New(fmt.Sprintf("%s:%s", tgt, tag))
+			if err != nil {
+				return
+			}
+			_ = s.processRef(ctx, sRef, tRef, action)
+		}()
+	}
+	wg.Wait()
+	log.WithFields(logrus.Fields{
+		"source": src,
+		"target": tgt,
+		"blobs":  len(s.batch.Blobs),
+		"bytes":  s.batch.Bytes,
+	}).Debug("Planned repository sync")
+}
+
+// syncPlan holds the comparison of each tag made when planning a repository, reused by the copy
+type syncPlan struct {
+	mu   sync.Mutex
+	refs map[string]syncPlanRef
+}
+
+// syncPlanRef is the result of comparing a source and target, copy is false when the target is current or skipped
+type syncPlanRef struct {
+	src        ref.Ref
+	mSrc       manifest.Manifest
+	mTgt       manifest.Manifest
+	tgtExists  bool
+	tgtMatches bool
+	copy       bool
+	err        error
+}
+
+func (sp *syncPlan) get(tgt ref.Ref) (syncPlanRef, bool) {
+	if sp == nil {
+		return syncPlanRef{}, false
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	spr, ok := sp.refs[tgt.CommonName()]
+	return spr, ok
+}
+
+func (sp *syncPlan) set(tgt ref.Ref, spr syncPlanRef) {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.refs == nil {
+		sp.refs = map[string]syncPlanRef{}
+	}
+	sp.refs[tgt.CommonName()] = spr
+}
+
+// processRepoDeleted sends a webhook for each filtered tag on the target that is missing from the source.
+// The event is only sent once for each target tag and digest, a tag that returns to the source is tracked again.
+// The tags on the target are not modified.
+func (s ConfigSync) processRepoDeleted(ctx context.Context, src, tgt string, sTagList []string) {
+	tRepoRef, err := ref.New(tgt)
+	if err != nil {
+		return
+	}
+	tTags, err := rc.TagList(ctx, tRepoRef)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"target": tRepoRef.CommonName(),
+			"error":  err,
+		}).Debug("Failed getting target tags")
+		return
+	}
+	tTagsList, err := tTags.GetTags()
+	if err != nil {
+		return
+	}
+	tTagList, err := s.filterTags(tTagsList)
+	if err != nil {
+		return
+	}
+	srcTags := map[string]bool{}
+	for _, tag := range sTagList {
+		srcTags[tag] = true
+	}
+	for _, tag := range tTagList {
+		tRef := tRepoRef
+		tRef.Tag = tag
+		if srcTags[tag] {
+			webhookDeletedSent.mu.Lock()
+			delete(webhookDeletedSent.tags, tRef.CommonName())
+			webhookDeletedSent.mu.Unlock()
+			continue
+		}
+		event := WebhookEvent{
+			Event:  webhookDeleted,
+			Source: fmt.Sprintf("%s:%s", src, tag),
+			Target: tRef.CommonName(),
+		}
+		if m, err := rc.ManifestHead(ctx, tRef, regclient.WithManifestRequireDigest()); err == nil {
+			event.PrevDigest = manifest.GetDigest(m).String()
+		}
+		webhookDeletedSent.mu.Lock()
+		prev, sent := webhookDeletedSent.tags[tRef.CommonName()]
+		webhookDeletedSent.tags[tRef.CommonName()] = event.PrevDigest
+		webhookDeletedSent.mu.Unlock()
+		if sent && prev == event.PrevDigest {
+			continue
+		}
+		log.WithFields(logrus.Fields{
+			"source": event.Source,
+			"target": event.Target,
+		}).Info("Tag deleted from source")
+		s.webhookSend(ctx, event)
+	}
+}
+
 func (s ConfigSync) processImage(ctx context.Context, src, tgt string, action actionType) error {
 	sRef, err := ref.New(src)
 	if err != nil {
@@ -562,12 +866,29 @@ func (s ConfigSync) processImage(ctx context.Context, src, tgt string, action ac
 		return err
 	}
 	err = s.processRef(ctx, sRef, tRef, action)
+	if err != nil && s.IgnoreMissing != nil && *s.IgnoreMissing && sourceMissing(ctx, sRef) {
+		log.WithFields(logrus.Fields{
+			"target": tRef.CommonName(),
+			"source": sRef.CommonName(),
+			"error":  err,
+		}).Warn("Source image is missing, skipping")
+		err = nil
+	}
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"target": tRef.CommonName(),
 			"source": sRef.CommonName(),
 			"error":  err,
 		}).Error("Failed to sync")
+		if action != actionCheck {
+			metrics.imageFailed(s)
+			s.webhookSend(ctx, WebhookEvent{
+				Event:  webhookFailed,
+				Source: sRef.CommonName(),
+				Target: tRef.CommonName(),
+				Error:  err.Error(),
+			})
+		}
 	}
 	if err := rc.Close(ctx, tRef); err != nil {
 		log.WithFields(logrus.Fields{
@@ -578,8 +899,82 @@ func (s ConfigSync) processImage(ctx context.Context, src, tgt string, action ac
 	return err
 }
 
-// process a sync step
-func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action actionType) error {
+// processRefPlan adds the blobs an image copy would transfer to the batch without copying any content
+func (s ConfigSync) processRefPlan(ctx context.Context, src, tgt ref.Ref) error {
+	opts, err := s.imageOpts()
+	if err != nil {
+		return err
+	}
+	plan := regclient.ImageCopyPlan{}
+	opts = append(opts, regclient.ImageWithCopyPlan(&plan), regclient.ImageWithCopyBatch(s.batch))
+	if err := throttleC.Acquire(ctx); err != nil {
+		return err
+	}
+	defer throttleC.Release(ctx)
+	return rc.ImageCopy(ctx, src, tgt, opts...)
+}
+
+// imageOpts returns the options that define the content copied by the sync step
+func (s ConfigSync) imageOpts() ([]regclient.ImageOpts, error) {
+	opts := []regclient.ImageOpts{}
+	if s.DigestTags != nil && *s.DigestTags {
+		opts = append(opts, regclient.ImageWithDigestTags())
+	}
+	if s.Referrers != nil && *s.Referrers {
+		if s.ReferrerFilters == nil || len(s.ReferrerFilters) == 0 {
+			opts = append(opts, regclient.ImageWithReferrers())
+		} else {
+			for _, filter := range s.ReferrerFilters {
+				rOpts := []scheme.ReferrerOpts{}
+				if filter.ArtifactType != "" {
+					rOpts = append(rOpts, scheme.WithReferrerAT(filter.ArtifactType))
+				}
+				if filter.Annotations != nil {
+					rOpts = append(rOpts, scheme.WithReferrerAnnotations(filter.Annotations))
+				}
+				opts = append(opts, regclient.ImageWithReferrers(rOpts...))
+			}
+		}
+	}
+	if s.FastCheck != nil && *s.FastCheck {
+		opts = append(opts, regclient.ImageWithFastCheck())
+	}
+	if s.ForceRecursive != nil && *s.ForceRecursive {
+		opts = append(opts, regclient.ImageWithForceRecursive())
+	}
+	if s.Immutable != nil && !cliOpts.force {
+		reTags := []*regexp.Regexp{}
+		for _, expr := range s.Immutable.Tags {
+			re, err := regexp.Compile("^" + expr + "$")
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"tag": expr,
+					"err": err,
+				}).Error("Failed to parse immutable tag regexp")
+				return nil, err
+			}
+			reTags = append(reTags, re)
+		}
+		opts = append(opts, regclient.ImageWithImmutable(s.Immutable.Annotation, reTags...))
+	}
+	if s.IncludeExternal != nil && *s.IncludeExternal {
+		opts = append(opts, regclient.ImageWithIncludeExternal())
+	}
+	if len(s.Platforms) > 0 {
+		opts = append(opts, regclient.ImageWithPlatforms(s.Platforms))
+	}
+	return opts, nil
+}
+
+// sourceMissing returns true when the source manifest is not found.
+// Other errors, e.g. a 401 or 403, are not treated as missing.
+func sourceMissing(ctx context.Context, r ref.Ref) bool {
+	_, err := rc.ManifestHead(ctx, r)
+	return errors.Is(err, types.ErrNotFound) || errors.Is(err, fs.ErrNotExist)
+}
+
+// processRefCheck compares the source and target of a sync step, returning whether a copy is needed
+func (s ConfigSync) processRefCheck(ctx context.Context, src, tgt ref.Ref, action actionType) syncPlanRef {
 	mSrc, err := rc.ManifestHead(ctx, src, regclient.WithManifestRequireDigest())
 	if err != nil && errors.Is(err, types.ErrUnsupportedAPI) {
 		mSrc, err = rc.ManifestGet(ctx, src)
@@ -589,7 +984,7 @@ func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action act
 			"source": src.CommonName(),
 			"error":  err,
 		}).Error("Failed to lookup source manifest")
-		return err
+		return syncPlanRef{err: err}
 	}
 	fastCheck := (s.FastCheck != nil && *s.FastCheck)
 	forceRecursive := (s.ForceRecursive != nil && *s.ForceRecursive)
@@ -606,14 +1001,14 @@ func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action act
 			"source": src.CommonName(),
 			"target": tgt.CommonName(),
 		}).Debug("Image matches")
-		return nil
+		return syncPlanRef{}
 	}
 	if tgtExists && action == actionMissing {
 		log.WithFields(logrus.Fields{
 			"source": src.CommonName(),
 			"target": tgt.CommonName(),
 		}).Debug("target exists")
-		return nil
+		return syncPlanRef{}
 	}
 
 	// skip when source manifest is an unsupported type
@@ -631,14 +1026,14 @@ func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action act
 			"mediaType": manifest.GetMediaType(mSrc),
 			"allowed":   s.MediaTypes,
 		}).Info("Skipping unsupported media type")
-		return nil
+		return syncPlanRef{}
 	}
 
 	// if platform is defined and source is a list, resolve the source platform
 	if mSrc.IsList() && s.Platform != "" {
 		platDigest, err := getPlatformDigest(ctx, src, s.Platform, mSrc)
 		if err != nil {
-			return err
+			return syncPlanRef{err: err}
 		}
 		src.Digest = platDigest.String()
 		if tgtExists && platDigest.String() == manifest.GetDigest(mTgt).String() {
@@ -650,9 +1045,49 @@ func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action act
 				"platform": s.Platform,
 				"target":   tgt.CommonName(),
 			}).Debug("Image matches for platform")
-			return nil
+			return syncPlanRef{}
+		}
+	}
+	return syncPlanRef{
+		src:        src,
+		mSrc:       mSrc,
+		mTgt:       mTgt,
+		tgtExists:  tgtExists,
+		tgtMatches: tgtMatches,
+		copy:       true,
+	}
+}
+
+// process a sync step
+func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action actionType) error {
+	// reuse the comparison made when planning the repository
+	check, ok := s.planned.get(tgt)
+	if !ok {
+		check = s.processRefCheck(ctx, src, tgt, action)
+		if s.planOnly {
+			if check.copy && check.src.Digest == "" {
+				// pin the source so the copy uses the manifests read when planning
+				check.src.Digest = manifest.GetDigest(check.mSrc).String()
+			}
+			s.planned.set(tgt, check)
+			if check.err != nil || !check.copy {
+				return check.err
+			}
+			return s.processRefPlan(ctx, check.src, tgt)
 		}
 	}
+	if check.err != nil || !check.copy {
+		return check.err
+	}
+	src = check.src
+	mSrc := check.mSrc
+	mTgt := check.mTgt
+	tgtExists := check.tgtExists
+	tgtMatches := check.tgtMatches
+	forceRecursive := (s.ForceRecursive != nil && *s.ForceRecursive)
+	referrers := (s.Referrers != nil && *s.Referrers)
+	digestTags := (s.DigestTags != nil && *s.DigestTags)
+	var err error
 	if tgtMatches {
 		log.WithFields(logrus.Fields{
 			"source":     src.CommonName(),
@@ -704,7 +1139,6 @@ func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action act
 			throttleC.Acquire(ctx)
 			mSrc, err = rc.ManifestHead(ctx, src)
 			if err != nil {
-				throttleC.Release(ctx)
 				log.WithFields(logrus.Fields{
 					"source": src.CommonName(),
 					"error":  err,
@@ -720,12 +1154,19 @@ func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action act
 			"step-min":      s.RateLimit.Min,
 		}).Debug("Rate limit passed")
 	}
-	defer throttleC.Release(ctx)
+	throttleHeld := true
+	defer func() {
+		if throttleHeld {
+			throttleC.Release(ctx)
+		}
+	}()
 
-	// verify context has not been canceled while waiting for throttle
+	// verify context has not been canceled while waiting for throttle, and no new copies start after a signal
 	select {
 	case <-ctx.Done():
 		return ErrCanceled
+	case <-shutdown:
+		return ErrCanceled
 	default:
 	}
 
@@ -782,46 +1223,85 @@ func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action act
 		}
 	}
 
-	opts := []regclient.ImageOpts{}
-	if s.DigestTags != nil && *s.DigestTags {
-		opts = append(opts, regclient.ImageWithDigestTags())
+	opts, err := s.imageOpts()
+	if err != nil {
+		return err
 	}
-	if s.Referrers != nil && *s.Referrers {
-		if s.ReferrerFilters == nil || len(s.ReferrerFilters) == 0 {
-			opts = append(opts, regclient.ImageWithReferrers())
-		} else {
-			for _, filter := range s.ReferrerFilters {
-				rOpts := []scheme.ReferrerOpts{}
-				if filter.ArtifactType != "" {
-					rOpts = append(rOpts, scheme.WithReferrerAT(filter.ArtifactType))
-				}
-				if filter.Annotations != nil {
-					rOpts = append(rOpts, scheme.WithReferrerAnnotations(filter.Annotations))
-				}
-				opts = append(opts, regclient.ImageWithReferrers(rOpts...))
+	if s.Scan != nil {
+		scanner, err := scan.ByName(s.Scan.Type, s.Scan.Server, scan.WithArgs(s.Scan.Args...))
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"scan":  s.Scan.Type,
+				"error": err,
+			}).Error("Failed to setup scanner")
+			return err
+		}
+		threshold := regclient.ScanSeverityUnknown
+		if s.Scan.Severity != "" {
+			threshold, err = regclient.ParseScanSeverity(s.Scan.Severity)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"severity": s.Scan.Severity,
+					"error":    err,
+				}).Error("Failed to parse scan severity")
+				return err
 			}
 		}
+		opts = append(opts, regclient.ImageWithScanner(scanner, threshold))
 	}
-	if s.FastCheck != nil && *s.FastCheck {
-		opts = append(opts, regclient.ImageWithFastCheck())
-	}
-	if s.ForceRecursive != nil && *s.ForceRecursive {
-		opts = append(opts, regclient.ImageWithForceRecursive())
-	}
-	if s.IncludeExternal != nil && *s.IncludeExternal {
-		opts = append(opts, regclient.ImageWithIncludeExternal())
+
+	if s.batch != nil {
+		opts = append(opts, regclient.ImageWithCopyBatch(s.batch))
 	}
-	if len(s.Platforms) > 0 {
-		opts = append(opts, regclient.ImageWithPlatforms(s.Platforms))
+	// checkpoint the copied blobs so an interrupted copy resumes without checking the target
+	stateFile := ""
+	if conf.Defaults.StateDir != "" {
+		stateFile = filepath.Join(conf.Defaults.StateDir, digest.FromString(tgt.CommonName()).Encoded()[:16]+".json")
+		opts = append(opts, regclient.ImageWithCopyState(stateFile))
 	}
 
+	result := regclient.ImageCopyResult{}
+	opts = append(opts, regclient.ImageWithCopyResult(&result), regclient.ImageWithCallback(metrics.blobCallback(s)))
+
 	// Copy the image
 	log.WithFields(logrus.Fields{
 		"source": src.CommonName(),
 		"target": tgt.CommonName(),
 	}).Debug("Image sync running")
 	err = rc.ImageCopy(ctx, src, tgt, opts...)
+	// pause and reschedule the copy when the rate limit is reached mid-sync, up to rateLimitRetryMax times
+	for retry := 1; err != nil && errors.Is(err, types.ErrHTTPRateLimit) && s.RateLimit.Min > 0 && retry <= rateLimitRetryMax; retry++ {
+		log.WithFields(logrus.Fields{
+			"source": src.CommonName(),
+			"target": tgt.CommonName(),
+			"sleep":  s.RateLimit.Retry,
+			"retry":  retry,
+		}).Warn("Rate limit exceeded, delaying copy")
+		throttleC.Release(ctx)
+		throttleHeld = false
+		select {
+		case <-ctx.Done():
+			return ErrCanceled
+		case <-shutdown:
+			return ErrCanceled
+		case <-time.After(s.RateLimit.Retry):
+		}
+		if throttleC.Acquire(ctx) != nil {
+			return ErrCanceled
+		}
+		throttleHeld = true
+		err = rc.ImageCopy(ctx, src, tgt, opts...)
+	}
 	if err != nil {
+		if result.Scan != nil && result.Scan.Vetoed {
+			log.WithFields(logrus.Fields{
+				"source":    src.CommonName(),
+				"target":    tgt.CommonName(),
+				"scanner":   result.Scan.Scanner,
+				"findings":  result.Scan.Summary(),
+				"threshold": result.Scan.Threshold.String(),
+			}).Warn("Image scan refused the copy")
+		}
 		log.WithFields(logrus.Fields{
 			"source": src.CommonName(),
 			"target": tgt.CommonName(),
@@ -829,6 +1309,60 @@ func (s ConfigSync) processRef(ctx context.Context, src, tgt ref.Ref, action act
 		}).Error("Failed to copy image")
 		return err
 	}
+	if stateFile != "" {
+		err = os.Remove(stateFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.WithFields(logrus.Fields{
+				"target": tgt.CommonName(),
+				"state":  stateFile,
+				"error":  err,
+			}).Warn("Failed to remove copy state")
+		}
+	}
+	if result.Unchanged {
+		log.WithFields(logrus.Fields{
+			"source": src.CommonName(),
+			"target": tgt.CommonName(),
+			"digest": result.Digest.String(),
+		}).Debug("Image unchanged")
+	}
+	if !result.Unchanged {
+		metrics.imageCopied(s)
+		log.WithFields(logrus.Fields{
+			"source":           src.CommonName(),
+			"target":           tgt.CommonName(),
+			"digest":           result.Digest.String(),
+			"manifestsCopied":  result.ManifestsCopied,
+			"manifestsSkipped": result.ManifestsSkipped,
+			"blobsCopied":      result.BlobsCopied,
+			"blobsSkipped":     result.BlobsSkipped,
+			"bytesCopied":      result.BytesCopied,
+			"duration":         result.Duration.String(),
+		}).Info("Image copied")
+		if result.Scan != nil {
+			log.WithFields(logrus.Fields{
+				"target":   tgt.CommonName(),
+				"scanner":  result.Scan.Scanner,
+				"findings": result.Scan.Summary(),
+			}).Info("Image scanned")
+		}
+	}
+	if !tgtMatches && !result.Unchanged {
+		event := WebhookEvent{
+			Event:  webhookCopied,
+			Source: src.CommonName(),
+			Target: tgt.CommonName(),
+			Digest: src.Digest,
+		}
+		if event.Digest == "" {
+			event.Digest = manifest.GetDigest(mSrc).String()
+		}
+		if tgtExists {
+			event.PrevDigest = manifest.GetDigest(mTgt).String()
+		}
+		event.Scan = result.Scan
+		s.webhookSend(ctx, event)
+	}
 	return nil
 }
 
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/pkg/template"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// webhookCopied is sent after a new digest is copied to the target
+	webhookCopied = "copied"
+	// webhookFailed is sent when a sync of an image fails
+	webhookFailed = "failed"
+	// webhookDeleted is sent when a tag on the target no longer exists on the source
+	webhookDeleted = "deleted"
+	// webhookUnhealthy is sent when a sync step reaches the failure threshold
+	webhookUnhealthy = "unhealthy"
+)
+
+var webhookTimeoutDefault = time.Second * 30
+
+// webhookDeletedSent tracks the target tags and digests with a sent deleted event, preventing a repeat on every run
+var webhookDeletedSent struct {
+	mu   sync.Mutex
+	tags map[string]string
+}
+
+func init() {
+	webhookDeletedSent.tags = map[string]string{}
+}
+
+// WebhookEvent is the data sent to a webhook, and available to the body template
+type WebhookEvent struct {
+	Event      string                     `json:"event"`
+	Source     string                     `json:"source"`
+	Target     string                     `json:"target"`
+	Digest     string                     `json:"digest,omitempty"`
+	PrevDigest string                     `json:"prevDigest,omitempty"`
+	Error      string                     `json:"error,omitempty"`
+	Scan       *regclient.ImageScanResult `json:"scan,omitempty"`
+	Time       time.Time                  `json:"time"`
+	Sync       ConfigSync                 `json:"-"`
+}
+
+// webhookSend delivers an event to each webhook subscribed to it.
+// Failures are logged without failing the sync.
+func (s ConfigSync) webhookSend(ctx context.Context, event WebhookEvent) {
+	event.Time = time.Now().UTC()
+	event.Sync = s
+	for _, wh := range s.Webhooks {
+		if !wh.subscribed(event.Event) {
+			continue
+		}
+		err := wh.send(ctx, event)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"event":  event.Event,
+				"target": event.Target,
+				"error":  err,
+			}).Warn("Failed to send webhook")
+		}
+	}
+}
+
+// webhookWants returns true if any webhook is subscribed to the event
+func (s ConfigSync) webhookWants(event string) bool {
+	for _, wh := range s.Webhooks {
+		if wh.subscribed(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (wh ConfigWebhook) subscribed(event string) bool {
+	if len(wh.Events) == 0 {
+		return true
+	}
+	for _, e := range wh.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (wh ConfigWebhook) send(ctx context.Context, event WebhookEvent) error {
+	url, err := template.String(wh.URL, event)
+	if err != nil {
+		return fmt.Errorf("failed to expand url template: %w", err)
+	}
+	var body []byte
+	if wh.Body != "" {
+		bodyStr, err := template.String(wh.Body, event)
+		if err != nil {
+			return fmt.Errorf("failed to expand body template: %w", err)
+		}
+		body = []byte(bodyStr)
+	} else {
+		body, err = json.Marshal(event)
+		if err != nil {
+			return err
+		}
+	}
+	method := wh.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	timeout := wh.Timeout
+	if timeout <= 0 {
+		timeout = webhookTimeoutDefault
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), strings.TrimSpace(url), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if wh.Body == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range wh.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, req.URL.Redacted())
+	}
+	log.WithFields(logrus.Fields{
+		"event":  event.Event,
+		"target": event.Target,
+		"url":    req.URL.Redacted(),
+	}).Debug("Webhook sent")
+	return nil
+}
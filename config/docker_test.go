@@ -59,6 +59,23 @@ func TestDocker(t *testing.T) {
 			expectHostname: "localhost:5001",
 			expectTLS:      TLSEnabled,
 		},
+		{
+			name:           "[::1]:5002",
+			hostname:       "[::1]:5002",
+			expectUser:     "hello",
+			expectPass:     "docker",
+			expectHostname: "[::1]:5002",
+			expectTLS:      TLSEnabled,
+		},
+		{
+			name:           "registry.example.com:5003",
+			hostname:       "registry.example.com:5003",
+			expectUser:     "hello",
+			expectPass:     "docker",
+			expectHostname: "registry.example.com:5003",
+			expectTLS:      TLSEnabled,
+			expectCredHost: "https://registry.example.com:5003/v1/",
+		},
 		{
 			name:             "docker.io",
 			hostname:         DockerRegistry,
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ecrPublicUser is the registry username returned by the ECR Public token exchange
+	ecrPublicUser = "AWS"
+	// ecrPublicRegion is the only region with the ECR Public API
+	ecrPublicRegion  = "us-east-1"
+	ecrPublicService = "ecr-public"
+	ecrPublicTarget  = "SpencerFrontendService.GetAuthorizationToken"
+	// ecrPublicOptSession is the APIOpts key for an AWS session token
+	ecrPublicOptSession = "awsSessionToken"
+	// ecrPublicEarly refreshes the token before it expires
+	ecrPublicEarly   = time.Minute * 5
+	ecrPublicTimeout = time.Second * 30
+)
+
+// ecrPublicURL is the ECR Public API, a variable to allow tests to override the server
+var ecrPublicURL = url.URL{Scheme: "https", Host: "api.ecr-public." + ecrPublicRegion + ".amazonaws.com", Path: "/"}
+
+// ecrPublicExchange is used when the host user is an AWS access key rather than the registry user
+func (host *Host) ecrPublicExchange() bool {
+	return host.API == APIECRPublic && host.User != "" && host.User != ecrPublicUser && host.Pass != ""
+}
+
+// refreshECRPublic exchanges the AWS access key for a registry password that is cached until it expires
+func (host *Host) refreshECRPublic() {
+	if host.ecrCredKey == host.User && !host.ecrCredRefresh.IsZero() && time.Now().Before(host.ecrCredRefresh) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ecrPublicTimeout)
+	defer cancel()
+	host.ecrCredKey = host.User
+	pass, expire, err := ecrPublicToken(ctx, host.User, host.Pass, host.APIOpts[ecrPublicOptSession], time.Now().UTC())
+	if err != nil {
+		host.ecrCred = Cred{}
+		host.ecrCredRefresh = time.Now().Add(defaultCredHelperRetry)
+		return
+	}
+	host.ecrCred = Cred{User: ecrPublicUser, Password: pass}
+	host.ecrCredRefresh = expire.Add(-1 * ecrPublicEarly)
+}
+
+// ecrPublicToken calls GetAuthorizationToken with a request signed by the AWS access key
+func ecrPublicToken(ctx context.Context, keyID, secret, session string, now time.Time) (string, time.Time, error) {
+	body := []byte("{}")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ecrPublicURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", ecrPublicTarget)
+	if session != "" {
+		req.Header.Set("X-Amz-Security-Token", session)
+	}
+	awsSign(req, body, keyID, secret, ecrPublicRegion, ecrPublicService, now)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("ecr-public token request failed, status %d: %s", resp.StatusCode, string(respBody))
+	}
+	tokenResp := struct {
+		AuthorizationData struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"`
+		} `json:"authorizationData"`
+	}{}
+	err = json.Unmarshal(respBody, &tokenResp)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse ecr-public token: %w", err)
+	}
+	userPass, err := base64.StdEncoding.DecodeString(tokenResp.AuthorizationData.AuthorizationToken)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode ecr-public token: %w", err)
+	}
+	user, pass, ok := strings.Cut(string(userPass), ":")
+	if !ok || user != ecrPublicUser || pass == "" {
+		return "", time.Time{}, fmt.Errorf("unexpected ecr-public token")
+	}
+	expire := time.Unix(int64(tokenResp.AuthorizationData.ExpiresAt), 0)
+	return pass, expire, nil
+}
+
+// awsSign adds an AWS signature version 4 Authorization header to a request
+func awsSign(req *http.Request, body []byte, keyID, secret, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	bodyHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	canonHeaders := &strings.Builder{}
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonReq := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonHeaders.String(), signedHeaders, hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	canonHash := sha256.Sum256([]byte(canonReq))
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonHash[:])
+
+	key := []byte("AWS4" + secret)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+keyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+sig)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAWSSign(t *testing.T) {
+	// get-vanilla from the AWS signature version 4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	awsSign(req, []byte{}, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", now)
+	expect := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != expect {
+		t.Errorf("unexpected authorization, expected %s, received %s", expect, auth)
+	}
+}
+
+func TestECRPublicCred(t *testing.T) {
+	keyID, secret, pass := "AKIDEXAMPLE", "secret", "registry-pass"
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method != http.MethodPost || r.Header.Get("X-Amz-Target") != ecrPublicTarget ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+keyID+"/") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		token := base64.StdEncoding.EncodeToString([]byte(ecrPublicUser + ":" + pass))
+		fmt.Fprintf(w, `{"authorizationData":{"authorizationToken":"%s","expiresAt":%d}}`, token, time.Now().Add(time.Hour*12).Unix())
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL + "/")
+	origURL := ecrPublicURL
+	ecrPublicURL = *tsURL
+	defer func() { ecrPublicURL = origURL }()
+
+	t.Run("exchange", func(t *testing.T) {
+		h := HostNewName("public.ecr.aws")
+		h.API = APIECRPublic
+		h.User = keyID
+		h.Pass = secret
+		h.APIOpts[ecrPublicOptSession] = "session"
+		for i := 0; i < 2; i++ {
+			cred := h.GetCred()
+			if cred.User != ecrPublicUser || cred.Password != pass {
+				t.Errorf("unexpected cred: %v", cred)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("token was not cached, calls: %d", calls)
+		}
+	})
+	t.Run("failed", func(t *testing.T) {
+		h := HostNewName("public.ecr.aws")
+		h.API = APIECRPublic
+		h.User = keyID
+		h.Pass = secret
+		cred := h.GetCred()
+		if cred.User != "" || cred.Password != "" {
+			t.Errorf("access key returned as a registry login: %v", cred)
+		}
+	})
+	t.Run("registry login", func(t *testing.T) {
+		calls = 0
+		h := HostNewName("public.ecr.aws")
+		h.API = APIECRPublic
+		h.User = ecrPublicUser
+		h.Pass = pass
+		cred := h.GetCred()
+		if cred.User != ecrPublicUser || cred.Password != pass || calls != 0 {
+			t.Errorf("unexpected cred: %v, calls: %d", cred, calls)
+		}
+	})
+}
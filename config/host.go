@@ -3,14 +3,19 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/regclient/regclient/internal/throttle"
 	"github.com/regclient/regclient/internal/timejson"
+	"github.com/regclient/regclient/pkg/template"
+	"github.com/regclient/regclient/types"
 	"github.com/sirupsen/logrus"
 )
 
@@ -39,6 +44,49 @@ const (
 	tokenUser = "<token>"
 )
 
+// API profiles set in the Host API field to select registry specific behaviors
+const (
+	// APIHub is Docker Hub, tags are deleted with the Hub repository API after a login with the host credentials
+	APIHub = "hub"
+	// APIECRPublic is AWS ECR Public, credentials are an AWS access key and secret that are exchanged for a registry login,
+	// or the AWS user and the password from "aws ecr-public get-login-password"
+	APIECRPublic = "ecr-public"
+	// APIGHCR is the GitHub Container Registry
+	APIGHCR = "ghcr"
+	// APIArtifactory is JFrog Artifactory, the catalog is listed with the Artifactory docker API for the PathPrefix repository key
+	APIArtifactory = "artifactory"
+	// APIHarbor is the Harbor registry, repositories are searched with the Harbor API
+	APIHarbor = "harbor"
+	// APIQuay is the Quay registry, repositories are searched with the Quay API
+	APIQuay = "quay"
+)
+
+// APIProfile describes the quirks of a registry API, selected by the Host API field
+type APIProfile struct {
+	NoCatalog    bool  // catalog API is not available
+	NoReferrers  bool  // referrers API is not available, the fallback tag is always used
+	NoTagDelete  bool  // delete by tag is not available, tags are deleted by replacing them with a temporary manifest
+	BlobChunkMin int64 // minimum size of each chunk in a chunked blob upload
+}
+
+var apiProfiles = map[string]APIProfile{
+	"":     {},
+	APIHub: {NoCatalog: true},
+	APIECRPublic: {
+		NoCatalog:    true,
+		NoTagDelete:  true,
+		BlobChunkMin: 5 * 1024 * 1024,
+	},
+	APIGHCR: {
+		NoCatalog:   true,
+		NoReferrers: true,
+		NoTagDelete: true,
+	},
+	APIArtifactory: {},
+	APIHarbor:      {},
+	APIQuay:        {},
+}
+
 var (
 	defaultExpire          = time.Hour * 1
 	defaultCredHelperRetry = time.Second * 5
@@ -100,38 +148,53 @@ func (t *TLSConf) UnmarshalText(b []byte) error {
 
 // Host struct contains host specific settings
 type Host struct {
-	Name          string             `json:"-" yaml:"registry,omitempty"`                  // name of the host, read from yaml, not written in json
-	Scheme        string             `json:"scheme,omitempty" yaml:"scheme"`               // TODO: deprecate, delete
-	TLS           TLSConf            `json:"tls,omitempty" yaml:"tls"`                     // enabled, disabled, insecure
-	RegCert       string             `json:"regcert,omitempty" yaml:"regcert"`             // public pem cert of registry
-	ClientCert    string             `json:"clientCert,omitempty" yaml:"clientCert"`       // public pem cert for client (mTLS)
-	ClientKey     string             `json:"clientKey,omitempty" yaml:"clientKey"`         // private pem cert for client (mTLS)
-	DNS           []string           `json:"dns,omitempty" yaml:"dns"`                     // TODO: remove slice, single string, or remove entirely?
-	Hostname      string             `json:"hostname,omitempty" yaml:"hostname"`           // replaces DNS array with single string
-	User          string             `json:"user,omitempty" yaml:"user"`                   // username, not used with credHelper
-	Pass          string             `json:"pass,omitempty" yaml:"pass"`                   // password, not used with credHelper
-	Token         string             `json:"token,omitempty" yaml:"token"`                 // token, experimental for specific APIs
-	CredHelper    string             `json:"credHelper,omitempty" yaml:"credHelper"`       // credential helper command for requesting logins
-	CredExpire    timejson.Duration  `json:"credExpire,omitempty" yaml:"credExpire"`       // time until credential expires
-	CredHost      string             `json:"credHost" yaml:"credHost"`                     // used when a helper hostname doesn't match Hostname
-	credRefresh   time.Time          `json:"-" yaml:"-"`                                   // internal use, when to refresh credentials
-	PathPrefix    string             `json:"pathPrefix,omitempty" yaml:"pathPrefix"`       // used for mirrors defined within a repository namespace
-	Mirrors       []string           `json:"mirrors,omitempty" yaml:"mirrors"`             // list of other Host Names to use as mirrors
-	Priority      uint               `json:"priority,omitempty" yaml:"priority"`           // priority when sorting mirrors, higher priority attempted first
-	RepoAuth      bool               `json:"repoAuth,omitempty" yaml:"repoAuth"`           // tracks a separate auth per repo
-	API           string             `json:"api,omitempty" yaml:"api"`                     // experimental: registry API to use
-	APIOpts       map[string]string  `json:"apiOpts,omitempty" yaml:"apiOpts"`             // options for APIs
-	BlobChunk     int64              `json:"blobChunk,omitempty" yaml:"blobChunk"`         // size of each blob chunk
-	BlobMax       int64              `json:"blobMax,omitempty" yaml:"blobMax"`             // threshold to switch to chunked upload, -1 to disable, 0 for regclient.blobMaxPut
-	ReqPerSec     float64            `json:"reqPerSec,omitempty" yaml:"reqPerSec"`         // requests per second
-	ReqConcurrent int64              `json:"reqConcurrent,omitempty" yaml:"reqConcurrent"` // concurrent requests
-	throttle      *throttle.Throttle // limit for concurrent requests
+	Name           string             `json:"-" yaml:"registry,omitempty"`                    // name of the host, read from yaml, not written in json
+	Scheme         string             `json:"scheme,omitempty" yaml:"scheme"`                 // TODO: deprecate, delete
+	TLS            TLSConf            `json:"tls,omitempty" yaml:"tls"`                       // enabled, disabled, insecure
+	RegCert        string             `json:"regcert,omitempty" yaml:"regcert"`               // public pem cert of registry
+	ClientCert     string             `json:"clientCert,omitempty" yaml:"clientCert"`         // public pem cert for client (mTLS)
+	ClientKey      string             `json:"clientKey,omitempty" yaml:"clientKey"`           // private pem cert for client (mTLS)
+	DNS            []string           `json:"dns,omitempty" yaml:"dns"`                       // TODO: remove slice, single string, or remove entirely?
+	Hostname       string             `json:"hostname,omitempty" yaml:"hostname"`             // replaces DNS array with single string
+	User           string             `json:"user,omitempty" yaml:"user"`                     // username, not used with credHelper
+	Pass           string             `json:"pass,omitempty" yaml:"pass"`                     // password, not used with credHelper
+	Token          string             `json:"token,omitempty" yaml:"token"`                   // token, experimental for specific APIs
+	CredHelper     string             `json:"credHelper,omitempty" yaml:"credHelper"`         // credential helper command for requesting logins
+	CredExpire     timejson.Duration  `json:"credExpire,omitempty" yaml:"credExpire"`         // time until credential expires
+	CredHost       string             `json:"credHost" yaml:"credHost"`                       // used when a helper hostname doesn't match Hostname
+	credRefresh    time.Time          `json:"-" yaml:"-"`                                     // internal use, when to refresh credentials
+	PathPrefix     string             `json:"pathPrefix,omitempty" yaml:"pathPrefix"`         // used for mirrors defined within a repository namespace
+	Mirrors        []string           `json:"mirrors,omitempty" yaml:"mirrors"`               // list of other Host Names to use as mirrors
+	Priority       uint               `json:"priority,omitempty" yaml:"priority"`             // priority when sorting mirrors, higher priority attempted first
+	RepoAuth       bool               `json:"repoAuth,omitempty" yaml:"repoAuth"`             // tracks a separate auth per repo
+	API            string             `json:"api,omitempty" yaml:"api"`                       // experimental: registry API to use
+	APIOpts        map[string]string  `json:"apiOpts,omitempty" yaml:"apiOpts"`               // options for APIs
+	BlobChunk      int64              `json:"blobChunk,omitempty" yaml:"blobChunk"`           // size of each blob chunk
+	BlobMax        int64              `json:"blobMax,omitempty" yaml:"blobMax"`               // threshold to switch to chunked upload, -1 to disable, 0 for regclient.blobMaxPut
+	BlobRate       int64              `json:"blobRate,omitempty" yaml:"blobRate"`             // bandwidth limit for blob transfers in bytes per second
+	ReqPerSec      float64            `json:"reqPerSec,omitempty" yaml:"reqPerSec"`           // requests per second
+	ReqConcurrent  int64              `json:"reqConcurrent,omitempty" yaml:"reqConcurrent"`   // concurrent requests
+	MaxIdleConns   int                `json:"maxIdleConns,omitempty" yaml:"maxIdleConns"`     // idle connections kept open to the host
+	IdleTimeout    timejson.Duration  `json:"idleTimeout,omitempty" yaml:"idleTimeout"`       // time before an idle connection is closed
+	KeepAlive      timejson.Duration  `json:"keepAlive,omitempty" yaml:"keepAlive"`           // interval for TCP keepalive probes, negative to disable
+	DisableHTTP2   bool               `json:"disableHTTP2,omitempty" yaml:"disableHTTP2"`     // force HTTP/1.1 for registries with a broken HTTP/2 implementation
+	DigestOnly     bool               `json:"digestOnly,omitempty" yaml:"digestOnly"`         // pull manifests only by digest and send no credentials, for public mirrors
+	Proxy          string             `json:"proxy,omitempty" yaml:"proxy"`                   // http, https, or socks5 proxy url, "direct" ignores the environment proxy
+	Resolve        []string           `json:"resolve,omitempty" yaml:"resolve"`               // addresses to connect to in place of the hostname, TLS and the Host header still use the hostname
+	ManifestAccept []string           `json:"manifestAccept,omitempty" yaml:"manifestAccept"` // media types in the Accept header of manifest requests, replacing the default list
+	throttle       *throttle.Throttle // limit for concurrent requests
+	ecrCred        Cred               // registry login exchanged from the AWS access key for ecr-public
+	ecrCredKey     string             // AWS access key used for ecrCred
+	ecrCredRefresh time.Time          // when to refresh ecrCred
 }
 
 type Cred struct {
 	User, Password, Token string
 }
 
+// ProxyDirect is the Proxy setting used to bypass any proxy from the environment
+const ProxyDirect = "direct"
+
 // HostNew creates a default Host entry
 func HostNew() *Host {
 	h := Host{
@@ -176,11 +239,36 @@ func HostNewName(name string) *Host {
 	return h
 }
 
+// ExpandTemplates expands Go templates in the name, hostname, and credential fields of the host.
+// Credentials may be loaded from the environment or mounted secrets, e.g. {{ env "PASS" }} or {{ file "/run/secrets/pass" }}.
+func (host *Host) ExpandTemplates() error {
+	for _, field := range []*string{
+		&host.Name, &host.Hostname, &host.User, &host.Pass, &host.Token,
+		&host.RegCert, &host.ClientCert, &host.ClientKey,
+	} {
+		val, err := template.String(*field, nil)
+		if err != nil {
+			return err
+		}
+		*field = val
+	}
+	return nil
+}
+
 func (host *Host) GetCred() Cred {
+	// digest only hosts are accessed anonymously
+	if host.DigestOnly {
+		return Cred{}
+	}
 	// refresh from credHelper if needed
 	if host.CredHelper != "" && (host.credRefresh.IsZero() || time.Now().After(host.credRefresh)) {
 		host.refreshHelper()
 	}
+	// exchange an AWS access key for the ecr-public registry login
+	if host.ecrPublicExchange() {
+		host.refreshECRPublic()
+		return host.ecrCred
+	}
 	return Cred{User: host.User, Password: host.Pass, Token: host.Token}
 }
 
@@ -201,6 +289,31 @@ func (host *Host) refreshHelper() {
 	}
 }
 
+// Validate checks for settings that would fail the first request to the registry.
+// Each error is prefixed with the registry name and all errors found are returned together.
+func (host Host) Validate() error {
+	name := host.Name
+	if name == "" {
+		name = host.Hostname
+	}
+	if name == "" {
+		return fmt.Errorf("registry name is required%.0w", types.ErrMissingName)
+	}
+	errs := []error{}
+	if host.User != "" && host.Pass == "" {
+		errs = append(errs, fmt.Errorf("%s: user %s is set without a password", name, host.User))
+	}
+	if host.CredHelper != "" {
+		if _, err := exec.LookPath(host.CredHelper); err != nil {
+			errs = append(errs, fmt.Errorf("%s: credential helper not found: %w", name, err))
+		}
+	}
+	if (host.ClientCert == "") != (host.ClientKey == "") {
+		errs = append(errs, fmt.Errorf("%s: clientCert and clientKey must be set together", name))
+	}
+	return errors.Join(errs...)
+}
+
 // Merge adds fields from a new config host entry
 func (host *Host) Merge(newHost Host, log *logrus.Logger) error {
 	name := newHost.Name
@@ -385,6 +498,12 @@ func (host *Host) Merge(newHost Host, log *logrus.Logger) error {
 	}
 
 	if newHost.API != "" {
+		if _, ok := apiProfiles[newHost.API]; !ok {
+			log.WithFields(logrus.Fields{
+				"api":  newHost.API,
+				"host": name,
+			}).Warn("Unknown API profile for registry")
+		}
 		if host.API != "" && host.API != newHost.API {
 			log.WithFields(logrus.Fields{
 				"orig": host.API,
@@ -437,6 +556,17 @@ func (host *Host) Merge(newHost Host, log *logrus.Logger) error {
 		host.BlobMax = newHost.BlobMax
 	}
 
+	if newHost.BlobRate > 0 {
+		if host.BlobRate != 0 && host.BlobRate != newHost.BlobRate {
+			log.WithFields(logrus.Fields{
+				"orig": host.BlobRate,
+				"new":  newHost.BlobRate,
+				"host": name,
+			}).Warn("Changing blobRate settings for registry")
+		}
+		host.BlobRate = newHost.BlobRate
+	}
+
 	if newHost.ReqPerSec > 0 {
 		if host.ReqPerSec != 0 && host.ReqPerSec != newHost.ReqPerSec {
 			log.WithFields(logrus.Fields{
@@ -467,9 +597,89 @@ func (host *Host) Merge(newHost Host, log *logrus.Logger) error {
 		host.ReqConcurrent = newHost.ReqConcurrent
 	}
 
+	if newHost.MaxIdleConns > 0 {
+		if host.MaxIdleConns != 0 && host.MaxIdleConns != newHost.MaxIdleConns {
+			log.WithFields(logrus.Fields{
+				"orig": host.MaxIdleConns,
+				"new":  newHost.MaxIdleConns,
+				"host": name,
+			}).Warn("Changing maxIdleConns settings for registry")
+		}
+		host.MaxIdleConns = newHost.MaxIdleConns
+	}
+
+	if newHost.IdleTimeout != 0 {
+		if host.IdleTimeout != 0 && host.IdleTimeout != newHost.IdleTimeout {
+			log.WithFields(logrus.Fields{
+				"orig": host.IdleTimeout,
+				"new":  newHost.IdleTimeout,
+				"host": name,
+			}).Warn("Changing idleTimeout settings for registry")
+		}
+		host.IdleTimeout = newHost.IdleTimeout
+	}
+
+	if newHost.KeepAlive != 0 {
+		if host.KeepAlive != 0 && host.KeepAlive != newHost.KeepAlive {
+			log.WithFields(logrus.Fields{
+				"orig": host.KeepAlive,
+				"new":  newHost.KeepAlive,
+				"host": name,
+			}).Warn("Changing keepAlive settings for registry")
+		}
+		host.KeepAlive = newHost.KeepAlive
+	}
+
+	if newHost.DisableHTTP2 {
+		host.DisableHTTP2 = newHost.DisableHTTP2
+	}
+
+	if newHost.DigestOnly {
+		host.DigestOnly = newHost.DigestOnly
+	}
+
+	if newHost.Proxy != "" {
+		if host.Proxy != "" && host.Proxy != newHost.Proxy {
+			log.WithFields(logrus.Fields{
+				"orig": redactURL(host.Proxy),
+				"new":  redactURL(newHost.Proxy),
+				"host": name,
+			}).Warn("Changing proxy settings for registry")
+		}
+		host.Proxy = newHost.Proxy
+	}
+
+	if len(newHost.Resolve) > 0 {
+		if len(host.Resolve) > 0 && !stringSliceEq(host.Resolve, newHost.Resolve) {
+			log.WithFields(logrus.Fields{
+				"orig": host.Resolve,
+				"new":  newHost.Resolve,
+				"host": name,
+			}).Warn("Changing resolve settings for registry")
+		}
+		host.Resolve = newHost.Resolve
+	}
+
+	if len(newHost.ManifestAccept) > 0 {
+		if len(host.ManifestAccept) > 0 && !stringSliceEq(host.ManifestAccept, newHost.ManifestAccept) {
+			log.WithFields(logrus.Fields{
+				"orig": host.ManifestAccept,
+				"new":  newHost.ManifestAccept,
+				"host": name,
+			}).Warn("Changing manifestAccept settings for registry")
+		}
+		host.ManifestAccept = newHost.ManifestAccept
+	}
+
 	return nil
 }
 
+// APIProfile returns the quirks of the registry API selected by the API field.
+// Unknown API values return the default profile.
+func (host *Host) APIProfile() APIProfile {
+	return apiProfiles[host.API]
+}
+
 func (host *Host) Throttle() *throttle.Throttle {
 	if host.ReqConcurrent <= 0 {
 		return nil
@@ -503,3 +713,12 @@ func stringSliceEq(a, b []string) bool {
 	}
 	return true
 }
+
+// redactURL hides any password included in a url
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
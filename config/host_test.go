@@ -441,3 +441,76 @@ func TestConfig(t *testing.T) {
 	}
 
 }
+
+func TestExpandTemplates(t *testing.T) {
+	t.Setenv("REGCLIENT_TEST_USER", "test-user")
+	passFile := filepath.Join(t.TempDir(), "pass")
+	err := os.WriteFile(passFile, []byte("test-pass\n"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write pass file: %v", err)
+	}
+	h := Host{
+		Name:     "registry.example.org",
+		Hostname: "registry.example.org",
+		User:     `{{ env "REGCLIENT_TEST_USER" }}`,
+		Pass:     `{{ file "` + passFile + `" }}`,
+	}
+	err = h.ExpandTemplates()
+	if err != nil {
+		t.Fatalf("failed to expand templates: %v", err)
+	}
+	if h.User != "test-user" || h.Pass != "test-pass" || h.Hostname != "registry.example.org" {
+		t.Errorf("unexpected host after expanding templates: user %s, pass %s, hostname %s", h.User, h.Pass, h.Hostname)
+	}
+	h.Token = "{{ env"
+	err = h.ExpandTemplates()
+	if err == nil {
+		t.Errorf("invalid template did not fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		name   string
+		host   Host
+		expErr []string
+	}{
+		{
+			name: "valid",
+			host: Host{Name: "registry.example.org", User: "user", Pass: "pass", ClientCert: "cert", ClientKey: "key"},
+		},
+		{
+			name:   "missing name",
+			host:   Host{User: "user", Pass: "pass"},
+			expErr: []string{"registry name is required"},
+		},
+		{
+			name: "invalid",
+			host: Host{Hostname: "registry.example.org", User: "user", CredHelper: "docker-credential-missing-helper", ClientKey: "key"},
+			expErr: []string{
+				"registry.example.org: user user is set without a password",
+				"registry.example.org: credential helper not found",
+				"registry.example.org: clientCert and clientKey must be set together",
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.host.Validate()
+			if len(tc.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validate did not fail")
+			}
+			for _, exp := range tc.expErr {
+				if !strings.Contains(err.Error(), exp) {
+					t.Errorf("error missing %q: %v", exp, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package regclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/docker/schema2"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+// downgradeLayerMT maps OCI layer media types to the equivalent docker schema2 media types
+var downgradeLayerMT = map[string]string{
+	types.MediaTypeOCI1LayerGzip:        types.MediaTypeDocker2LayerGzip,
+	types.MediaTypeOCI1ForeignLayerGzip: types.MediaTypeDocker2ForeignLayer,
+	types.MediaTypeDocker2LayerGzip:     types.MediaTypeDocker2LayerGzip,
+	types.MediaTypeDocker2ForeignLayer:  types.MediaTypeDocker2ForeignLayer,
+}
+
+// downgradeRetry returns true when a push error indicates the registry rejected the manifest content.
+// Auth, missing repository, and rate limit errors are not retried with a converted manifest.
+func downgradeRetry(err error) bool {
+	status := types.HTTPStatus(err)
+	if status < 400 || status >= 500 {
+		return false
+	}
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
+
+// manifestDowngrade converts an OCI image manifest or index to the docker schema2 media types.
+// The children map replaces index entries with child manifests that were already converted.
+// Manifests using OCI only features, e.g. an artifactType, subject, annotations, or zstd layers, are not converted.
+func manifestDowngrade(m manifest.Manifest, children map[digest.Digest]types.Descriptor) (manifest.Manifest, error) {
+	switch orig := m.GetOrig().(type) {
+	case v1.Manifest:
+		if orig.ArtifactType != "" || orig.Subject != nil || len(orig.Annotations) > 0 {
+			return nil, fmt.Errorf("manifest uses OCI only fields%.0w", types.ErrUnsupportedMediaType)
+		}
+		if orig.Config.MediaType != types.MediaTypeOCI1ImageConfig && orig.Config.MediaType != types.MediaTypeDocker2ImageConfig {
+			return nil, fmt.Errorf("config media type %s has no docker equivalent%.0w", orig.Config.MediaType, types.ErrUnsupportedMediaType)
+		}
+		orig.Config.MediaType = types.MediaTypeDocker2ImageConfig
+		orig.Layers = append([]types.Descriptor{}, orig.Layers...)
+		for i, l := range orig.Layers {
+			mt, ok := downgradeLayerMT[l.MediaType]
+			if !ok {
+				return nil, fmt.Errorf("layer media type %s has no docker equivalent%.0w", l.MediaType, types.ErrUnsupportedMediaType)
+			}
+			orig.Layers[i].MediaType = mt
+		}
+		sm := schema2.Manifest{}
+		err := manifest.OCIManifestToAny(orig, &sm)
+		if err != nil {
+			return nil, err
+		}
+		return manifest.New(manifest.WithOrig(sm))
+	case v1.Index:
+		if orig.ArtifactType != "" || orig.Subject != nil || len(orig.Annotations) > 0 {
+			return nil, fmt.Errorf("index uses OCI only fields%.0w", types.ErrUnsupportedMediaType)
+		}
+		orig.Manifests = append([]types.Descriptor{}, orig.Manifests...)
+		for i, d := range orig.Manifests {
+			if dConv, ok := children[d.Digest]; ok {
+				orig.Manifests[i].MediaType = dConv.MediaType
+				orig.Manifests[i].Digest = dConv.Digest
+				orig.Manifests[i].Size = dConv.Size
+			}
+			switch orig.Manifests[i].MediaType {
+			case types.MediaTypeDocker2Manifest, types.MediaTypeDocker2ManifestList:
+			default:
+				return nil, fmt.Errorf("index entry %s has media type %s%.0w", d.Digest, orig.Manifests[i].MediaType, types.ErrUnsupportedMediaType)
+			}
+		}
+		sml := schema2.ManifestList{}
+		err := manifest.OCIIndexToAny(orig, &sml)
+		if err != nil {
+			return nil, err
+		}
+		return manifest.New(manifest.WithOrig(sml))
+	}
+	return nil, fmt.Errorf("manifest media type %s cannot be downgraded%.0w", m.GetDescriptor().MediaType, types.ErrUnsupportedMediaType)
+}
+
+// imageCopyPut pushes a manifest during an image copy.
+// With the downgrade option, a manifest rejected by the registry is converted to docker media types and pushed again.
+// An index referencing converted children is converted before the first push.
+func (rc *RegClient) imageCopyPut(ctx context.Context, refTgt ref.Ref, sDig digest.Digest, m manifest.Manifest, opt *imageOpt, mOpts ...ManifestOpts) error {
+	if !opt.downgrade {
+		return rc.ManifestPut(ctx, refTgt, m, mOpts...)
+	}
+	opt.mu.Lock()
+	children := map[digest.Digest]types.Descriptor{}
+	for k, v := range opt.downgraded {
+		children[k] = v
+	}
+	opt.mu.Unlock()
+	childConv := false
+	if mi, ok := m.(manifest.Indexer); ok && len(children) > 0 {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return err
+		}
+		for _, d := range dl {
+			if _, ok := children[d.Digest]; ok {
+				childConv = true
+				break
+			}
+		}
+	}
+	var err error
+	if !childConv {
+		err = rc.ManifestPut(ctx, refTgt, m, mOpts...)
+		if err == nil || !downgradeRetry(err) {
+			return err
+		}
+	}
+	mConv, errConv := manifestDowngrade(m, children)
+	if errConv != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to convert %s after converting the child manifests: %w", refTgt.CommonName(), errConv)
+	}
+	refConv := refTgt
+	if refConv.Digest != "" {
+		refConv.Digest = mConv.GetDescriptor().Digest.String()
+	}
+	rc.log.WithFields(logrus.Fields{
+		"target":    refConv.CommonName(),
+		"mediaType": mConv.GetDescriptor().MediaType,
+		"err":       err,
+	}).Info("Pushing manifest converted to docker media types")
+	err = rc.ManifestPut(ctx, refConv, mConv, mOpts...)
+	if err != nil {
+		return err
+	}
+	opt.mu.Lock()
+	opt.downgraded[sDig] = mConv.GetDescriptor()
+	opt.mu.Unlock()
+	return nil
+}
+
+// imageDowngradeMatch returns the converted descriptor when the target contains the docker conversion of the source manifest.
+// Children of an index are converted to compare the full index without pushing content.
+func (rc *RegClient) imageDowngradeMatch(ctx context.Context, refSrc ref.Ref, sDig digest.Digest, mTgt manifest.Manifest) (types.Descriptor, bool) {
+	switch mTgt.GetDescriptor().MediaType {
+	case types.MediaTypeDocker2Manifest, types.MediaTypeDocker2ManifestList:
+	default:
+		return types.Descriptor{}, false
+	}
+	rGet := refSrc
+	rGet.Tag = ""
+	rGet.Digest = sDig.String()
+	mSrc, err := rc.ManifestGet(ctx, rGet)
+	if err != nil {
+		return types.Descriptor{}, false
+	}
+	children := map[digest.Digest]types.Descriptor{}
+	if mi, ok := mSrc.(manifest.Indexer); ok {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return types.Descriptor{}, false
+		}
+		for _, d := range dl {
+			if d.MediaType == types.MediaTypeDocker2Manifest || d.MediaType == types.MediaTypeDocker2ManifestList {
+				continue
+			}
+			mChild, err := rc.ManifestGet(ctx, rGet, WithManifestDesc(d))
+			if err != nil {
+				return types.Descriptor{}, false
+			}
+			mConv, err := manifestDowngrade(mChild, nil)
+			if err != nil {
+				return types.Descriptor{}, false
+			}
+			children[d.Digest] = mConv.GetDescriptor()
+		}
+	}
+	mConv, err := manifestDowngrade(mSrc, children)
+	if err != nil || mConv.GetDescriptor().Digest != mTgt.GetDescriptor().Digest {
+		return types.Descriptor{}, false
+	}
+	return mConv.GetDescriptor(), true
+}
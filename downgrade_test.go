@@ -0,0 +1,187 @@
+package regclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestDowngrade(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	// registry rejects OCI manifests and records the pushed docker manifests
+	var mu sync.Mutex
+	pushed := map[string]string{}
+	bodies := map[string][]byte{}
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		switch {
+		case req.URL.Path == "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case strings.Contains(req.URL.Path, "/blobs/") && req.Method == http.MethodHead:
+			rw.WriteHeader(http.StatusOK)
+		case strings.Contains(req.URL.Path, "/manifests/") && req.Method == http.MethodPut:
+			mt := req.Header.Get("Content-Type")
+			if mt != types.MediaTypeDocker2Manifest && mt != types.MediaTypeDocker2ManifestList {
+				rw.WriteHeader(http.StatusBadRequest)
+				_, _ = rw.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"unsupported media type"}]}`))
+				return
+			}
+			mu.Lock()
+			pushed[req.URL.Path] = mt
+			bodies[req.URL.Path] = body
+			// content pushed by tag is also available by digest
+			pathDig := req.URL.Path[:strings.LastIndex(req.URL.Path, "/")+1] + digest.FromBytes(body).String()
+			bodies[pathDig] = body
+			mu.Unlock()
+			rw.WriteHeader(http.StatusCreated)
+		case strings.Contains(req.URL.Path, "/manifests/") && (req.Method == http.MethodGet || req.Method == http.MethodHead):
+			mu.Lock()
+			b, ok := bodies[req.URL.Path]
+			mu.Unlock()
+			if !ok {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			mt := struct {
+				MediaType string `json:"mediaType"`
+			}{}
+			_ = json.Unmarshal(b, &mt)
+			rw.Header().Set("Content-Type", mt.MediaType)
+			rw.Header().Set("Content-Length", fmt.Sprintf("%d", len(b)))
+			rw.Header().Set("Docker-Content-Digest", digest.FromBytes(b).String())
+			rw.WriteHeader(http.StatusOK)
+			if req.Method == http.MethodGet {
+				_, _ = rw.Write(b)
+			}
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	rc := New(
+		WithFS(fsMem),
+		WithConfigHost(config.Host{
+			Name:      tsHost,
+			Hostname:  tsHost,
+			TLS:       config.TLSDisabled,
+			ReqPerSec: 100,
+		}),
+		WithRetryDelay(time.Millisecond, time.Millisecond),
+	)
+	// create an index without OCI specific fields from the platform images in v1
+	rV1, _ := ref.New("ocidir://testrepo:v1")
+	mV1, err := rc.ManifestGet(ctx, rV1)
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	dl, err := mV1.(manifest.Indexer).GetManifestList()
+	if err != nil {
+		t.Errorf("failed to get manifest list: %v", err)
+		return
+	}
+	mIndex, err := manifest.New(manifest.WithOrig(v1.Index{
+		Versioned: v1.IndexSchemaVersion,
+		MediaType: types.MediaTypeOCI1ManifestList,
+		Manifests: dl[:2],
+	}))
+	if err != nil {
+		t.Errorf("failed to create index: %v", err)
+		return
+	}
+	rSrc, _ := ref.New("ocidir://testrepo:downgrade")
+	err = rc.ManifestPut(ctx, rSrc, mIndex)
+	if err != nil {
+		t.Errorf("failed to put index: %v", err)
+		return
+	}
+	rTgt, _ := ref.New(tsHost + "/proj:downgrade")
+
+	t.Run("Rejected", func(t *testing.T) {
+		err := rc.ImageCopy(ctx, rSrc, rTgt)
+		if err == nil || types.HTTPStatus(err) != http.StatusBadRequest {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Index", func(t *testing.T) {
+		err := rc.ImageCopy(ctx, rSrc, rTgt, ImageWithDowngrade())
+		if err != nil {
+			t.Errorf("failed to copy: %v", err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if pushed["/v2/proj/manifests/downgrade"] != types.MediaTypeDocker2ManifestList {
+			t.Errorf("index was not converted: %v", pushed)
+		}
+		for _, d := range dl[:2] {
+			if _, ok := pushed["/v2/proj/manifests/"+d.Digest.String()]; ok {
+				t.Errorf("original digest %s was pushed", d.Digest)
+			}
+		}
+		count := 0
+		for path, mt := range pushed {
+			if mt == types.MediaTypeDocker2Manifest && strings.HasPrefix(path, "/v2/proj/manifests/"+digest.Canonical.String()+":") {
+				count++
+			}
+		}
+		if count != 2 {
+			t.Errorf("expected 2 converted images, received %d: %v", count, pushed)
+		}
+	})
+	t.Run("Rerun", func(t *testing.T) {
+		mu.Lock()
+		dConv := digest.FromBytes(bodies["/v2/proj/manifests/downgrade"])
+		pushCount := len(pushed)
+		mu.Unlock()
+		result := ImageCopyResult{}
+		err := rc.ImageCopy(ctx, rSrc, rTgt, ImageWithDowngrade(), ImageWithCopyResult(&result))
+		if err != nil {
+			t.Errorf("failed to copy: %v", err)
+			return
+		}
+		if !result.Unchanged || result.Digest != dConv {
+			t.Errorf("unexpected result, expected unchanged %s, received %v", dConv, result)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(pushed) != pushCount {
+			t.Errorf("manifests pushed on the rerun: %v", pushed)
+		}
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		// the v1 index includes annotations and attestations that cannot be converted
+		err := rc.ImageCopy(ctx, rV1, rTgt, ImageWithDowngrade())
+		if err == nil {
+			t.Errorf("copy did not fail")
+		} else if !errors.Is(err, types.ErrUnsupportedMediaType) && types.HTTPStatus(err) != http.StatusBadRequest {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package regclient
+
+import (
+	"context"
+
+	"github.com/regclient/regclient/scheme/reg"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+type extensionLister interface {
+	ExtensionList(ctx context.Context, r ref.Ref) (reg.ExtensionList, error)
+}
+
+// ExtensionList returns the distribution-spec extensions advertised by a registry.
+// Leave the repository of the reference empty to query the registry, or include it to query the repository.
+func (rc *RegClient) ExtensionList(ctx context.Context, r ref.Ref) (reg.ExtensionList, error) {
+	schemeAPI, err := rc.schemeGet(r.Scheme)
+	if err != nil {
+		return reg.ExtensionList{}, err
+	}
+	el, ok := schemeAPI.(extensionLister)
+	if !ok {
+		return reg.ExtensionList{}, types.ErrNotImplemented
+	}
+	return el.ExtensionList(ctx, r)
+}
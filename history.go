@@ -0,0 +1,142 @@
+package regclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/units"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// imageHistoryCreatedByLen limits the created by column in MarshalPretty, similar to docker history
+const imageHistoryCreatedByLen = 60
+
+// ImageHistoryEntry is a history entry from the image config with the layer it created
+type ImageHistoryEntry struct {
+	Created    *time.Time        `json:"created,omitempty"`
+	CreatedBy  string            `json:"createdBy,omitempty"`
+	Author     string            `json:"author,omitempty"`
+	Comment    string            `json:"comment,omitempty"`
+	EmptyLayer bool              `json:"emptyLayer,omitempty"`
+	Layer      *types.Descriptor `json:"layer,omitempty"` // layer created by this step, nil for an empty layer
+	Size       int64             `json:"size"`            // compressed size of the layer
+}
+
+// ImageHistoryReport contains the history of a single platform image, oldest entry first
+type ImageHistoryReport struct {
+	Digest   digest.Digest       `json:"digest"`
+	Platform *platform.Platform  `json:"platform,omitempty"`
+	History  []ImageHistoryEntry `json:"history"`
+}
+
+// ImageHistory returns the history of an image from the config, matching each entry to the layer it created.
+// This only pulls the manifest and config, the layers are not pulled.
+// For an index, ImageWithPlatform selects the image, defaulting to the local platform.
+func (rc *RegClient) ImageHistory(ctx context.Context, r ref.Ref, opts ...ImageOpts) (ImageHistoryReport, error) {
+	var opt imageOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	report := ImageHistoryReport{
+		History: []ImageHistoryEntry{},
+	}
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		return report, err
+	}
+	if m.IsList() {
+		pStr := opt.platform
+		if pStr == "" {
+			pStr = "local"
+		}
+		p, err := platform.Parse(pStr)
+		if err != nil {
+			return report, err
+		}
+		d, err := manifest.GetPlatformDesc(m, &p)
+		if err != nil {
+			return report, err
+		}
+		report.Platform = d.Platform
+		rp := r
+		rp.Tag = ""
+		rp.Digest = d.Digest.String()
+		m, err = rc.ManifestGet(ctx, rp, WithManifestDesc(*d))
+		if err != nil {
+			return report, err
+		}
+	}
+	report.Digest = m.GetDescriptor().Digest
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return report, fmt.Errorf("manifest is not an image: %s%.0w", m.GetDescriptor().MediaType, types.ErrUnsupportedMediaType)
+	}
+	cd, err := mi.GetConfig()
+	if err != nil {
+		return report, err
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return report, err
+	}
+	conf, err := rc.BlobGetOCIConfig(ctx, r, cd)
+	if err != nil {
+		return report, err
+	}
+	// each history entry that is not an empty layer created the next layer in the manifest
+	li := 0
+	for _, h := range conf.GetConfig().History {
+		entry := ImageHistoryEntry{
+			Created:    h.Created,
+			CreatedBy:  h.CreatedBy,
+			Author:     h.Author,
+			Comment:    h.Comment,
+			EmptyLayer: h.EmptyLayer,
+		}
+		if !h.EmptyLayer && li < len(layers) {
+			l := layers[li]
+			entry.Layer = &l
+			entry.Size = l.Size
+			li++
+		}
+		report.History = append(report.History, entry)
+	}
+	// layers without a history entry are included so the sizes add up to the image
+	for ; li < len(layers); li++ {
+		l := layers[li]
+		report.History = append(report.History, ImageHistoryEntry{
+			Layer: &l,
+			Size:  l.Size,
+		})
+	}
+	return report, nil
+}
+
+// MarshalPretty outputs the history in the format of docker history, newest entry first.
+func (report ImageHistoryReport) MarshalPretty() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Created\tCreated By\tSize\tComment\n")
+	for i := len(report.History) - 1; i >= 0; i-- {
+		h := report.History[i]
+		created := "<missing>"
+		if h.Created != nil {
+			created = h.Created.Format(time.RFC3339)
+		}
+		createdBy := strings.Join(strings.Fields(h.CreatedBy), " ")
+		if len(createdBy) > imageHistoryCreatedByLen {
+			createdBy = createdBy[:imageHistoryCreatedByLen-3] + "..."
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", created, createdBy, units.HumanSize(float64(h.Size)), h.Comment)
+	}
+	err := tw.Flush()
+	return buf.Bytes(), err
+}
@@ -0,0 +1,68 @@
+package regclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestImageHistory(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	rc := New(WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	report, err := rc.ImageHistory(ctx, r, ImageWithPlatform("linux/amd64"))
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if report.Platform == nil || report.Platform.Architecture != "amd64" {
+		t.Errorf("unexpected platform: %v", report.Platform)
+	}
+	// compare the layers with the manifest
+	rImg := r
+	rImg.Tag = ""
+	rImg.Digest = report.Digest.String()
+	m, err := rc.ManifestGet(ctx, rImg)
+	if err != nil {
+		t.Fatalf("failed to get manifest: %v", err)
+	}
+	layers, err := m.(manifest.Imager).GetLayers()
+	if err != nil {
+		t.Fatalf("failed to get layers: %v", err)
+	}
+	li := 0
+	for _, h := range report.History {
+		if h.Layer == nil {
+			if h.Size != 0 {
+				t.Errorf("size set without a layer: %v", h)
+			}
+			continue
+		}
+		if li >= len(layers) || h.Layer.Digest != layers[li].Digest || h.Size != layers[li].Size {
+			t.Errorf("layer %d mismatch: %v", li, h)
+		}
+		li++
+	}
+	if li != len(layers) {
+		t.Errorf("layer count mismatch, expected %d, received %d", len(layers), li)
+	}
+	out, err := report.MarshalPretty()
+	if err != nil || len(out) == 0 {
+		t.Errorf("failed to marshal pretty: %v", err)
+	}
+	_, err = rc.ImageHistory(ctx, r, ImageWithPlatform("linux/s390x"))
+	if err == nil {
+		t.Errorf("history returned for a missing platform")
+	}
+}
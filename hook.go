@@ -0,0 +1,114 @@
+package regclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// PushHook validates content before it is pushed to a repository, enforcing policies like denying the latest tag or requiring annotations.
+// PrePush is called with the target reference and the descriptor of each manifest and blob before the push.
+// Manifest descriptors include the annotations and the manifest content in the Data field.
+// Blob descriptors may be missing the digest and size when the blob is streamed.
+// Returning an error blocks the push and the error is returned to the caller.
+type PushHook interface {
+	PrePush(ctx context.Context, r ref.Ref, d types.Descriptor) error
+}
+
+// PushHookFunc adapts a function to the PushHook interface
+type PushHookFunc func(ctx context.Context, r ref.Ref, d types.Descriptor) error
+
+// PrePush calls the function
+func (fn PushHookFunc) PrePush(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	return fn(ctx, r, d)
+}
+
+// WithPushHook adds hooks that are called before each manifest and blob push
+func WithPushHook(hooks ...PushHook) Opt {
+	return func(rc *RegClient) {
+		rc.pushHooks = append(rc.pushHooks, hooks...)
+	}
+}
+
+// PostPushHook is called after a manifest is pushed, allowing integrators to sign the pushed digest in-process.
+// PostPush is called with the target reference, including the digest of the pushed manifest, and the manifest descriptor.
+// Hooks are not called for child manifests of an index, or for the referrers and digest tags copied with an image.
+// Pushes made by the hook with the provided context, e.g. the signature, do not call the hooks again.
+// The manifest has already been pushed when an error is returned from the hook.
+// The signcmd package provides a hook that runs the cosign or notation command, or signs with a local cosign key when built with the "cosign" tag.
+type PostPushHook interface {
+	PostPush(ctx context.Context, r ref.Ref, d types.Descriptor) error
+}
+
+// PostPushHookFunc adapts a function to the PostPushHook interface
+type PostPushHookFunc func(ctx context.Context, r ref.Ref, d types.Descriptor) error
+
+// PostPush calls the function
+func (fn PostPushHookFunc) PostPush(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	return fn(ctx, r, d)
+}
+
+// WithPostPushHook adds hooks that are called after each manifest push, see PostPushHook
+func WithPostPushHook(hooks ...PostPushHook) Opt {
+	return func(rc *RegClient) {
+		rc.postPushHooks = append(rc.postPushHooks, hooks...)
+	}
+}
+
+type postPushSkipKey struct{}
+
+// postPushSkip returns a context that does not run the post push hooks
+func postPushSkip(ctx context.Context) context.Context {
+	return context.WithValue(ctx, postPushSkipKey{}, true)
+}
+
+// postPushRun calls each post push hook with the digest of the pushed manifest, stopping on the first error
+func (rc *RegClient) postPushRun(ctx context.Context, r ref.Ref, m manifest.Manifest) error {
+	if len(rc.postPushHooks) == 0 || ctx.Value(postPushSkipKey{}) != nil {
+		return nil
+	}
+	ctx = postPushSkip(ctx)
+	d := m.GetDescriptor()
+	r.Digest = d.Digest.String()
+	for _, hook := range rc.postPushHooks {
+		err := hook.PostPush(ctx, r, d)
+		if err != nil {
+			return fmt.Errorf("post push hook for %s failed: %w", r.CommonName(), err)
+		}
+	}
+	return nil
+}
+
+// pushHookRun calls each push hook, stopping on the first error
+func (rc *RegClient) pushHookRun(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	for _, hook := range rc.pushHooks {
+		err := hook.PrePush(ctx, r, d)
+		if err != nil {
+			return fmt.Errorf("push to %s denied: %w", r.CommonName(), err)
+		}
+	}
+	return nil
+}
+
+// pushHookManifest calls the push hooks with the descriptor of a manifest
+func (rc *RegClient) pushHookManifest(ctx context.Context, r ref.Ref, m manifest.Manifest) error {
+	if len(rc.pushHooks) == 0 {
+		return nil
+	}
+	d := m.GetDescriptor()
+	raw, err := m.RawBody()
+	if err != nil {
+		return err
+	}
+	d.Data = raw
+	if ma, ok := m.(manifest.Annotator); ok {
+		annots, err := ma.GetAnnotations()
+		if err == nil && len(annots) > 0 {
+			d.Annotations = annots
+		}
+	}
+	return rc.pushHookRun(ctx, r, d)
+}
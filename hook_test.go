@@ -0,0 +1,172 @@
+package regclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestPushHook(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	errLatest := errors.New("latest tag denied")
+	var mu sync.Mutex
+	manifests := []types.Descriptor{}
+	blobs := []types.Descriptor{}
+	hook := PushHookFunc(func(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+		if r.Tag == "latest" {
+			return errLatest
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch d.MediaType {
+		case types.MediaTypeOCI1Manifest, types.MediaTypeOCI1ManifestList, types.MediaTypeDocker2Manifest, types.MediaTypeDocker2ManifestList:
+			manifests = append(manifests, d)
+		default:
+			blobs = append(blobs, d)
+		}
+		return nil
+	})
+	rc := New(WithFS(fsMem), WithPushHook(hook))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+
+	t.Run("Allowed", func(t *testing.T) {
+		rTgt, err := ref.New("ocidir://testhook:v1")
+		if err != nil {
+			t.Errorf("failed to parse tgt ref: %v", err)
+			return
+		}
+		err = rc.ImageCopy(ctx, rSrc, rTgt)
+		if err != nil {
+			t.Errorf("failed to copy: %v", err)
+			return
+		}
+		if len(manifests) == 0 || len(blobs) == 0 {
+			t.Errorf("hook was not called, manifests %d, blobs %d", len(manifests), len(blobs))
+			return
+		}
+		for _, d := range manifests {
+			m, err := manifest.New(manifest.WithRaw(d.Data))
+			if err != nil {
+				t.Errorf("failed to parse manifest from the descriptor data: %v", err)
+				continue
+			}
+			if m.GetDescriptor().Digest != d.Digest {
+				t.Errorf("unexpected digest, expected %s, received %s", d.Digest, m.GetDescriptor().Digest)
+			}
+		}
+	})
+	t.Run("Denied", func(t *testing.T) {
+		rTgt, err := ref.New("ocidir://testhook:latest")
+		if err != nil {
+			t.Errorf("failed to parse tgt ref: %v", err)
+			return
+		}
+		err = rc.ImageCopy(ctx, rSrc, rTgt)
+		if !errors.Is(err, errLatest) {
+			t.Errorf("unexpected error, expected %v, received %v", errLatest, err)
+		}
+		_, err = rc.ManifestHead(ctx, rTgt)
+		if err == nil {
+			t.Errorf("denied manifest was pushed")
+		}
+	})
+}
+
+func TestPostPushHook(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	errSign := errors.New("signing failed")
+	var mu sync.Mutex
+	pushed := []ref.Ref{}
+	var rc *RegClient
+	hook := PostPushHookFunc(func(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+		if r.Tag == "fail" {
+			return errSign
+		}
+		mu.Lock()
+		pushed = append(pushed, r)
+		mu.Unlock()
+		// a push from within the hook does not call the hook again
+		rSig := r
+		rSig.Tag = r.Tag + "-sig"
+		rSig.Digest = ""
+		m, err := rc.ManifestGet(ctx, r)
+		if err != nil {
+			return err
+		}
+		return rc.ManifestPut(ctx, rSig, m)
+	})
+	rc = New(WithFS(fsMem), WithPostPushHook(hook))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+	mSrc, err := rc.ManifestHead(ctx, rSrc, WithManifestRequireDigest())
+	if err != nil {
+		t.Errorf("failed to head src: %v", err)
+		return
+	}
+
+	t.Run("Copy", func(t *testing.T) {
+		rTgt, err := ref.New("ocidir://testpostpush:v1")
+		if err != nil {
+			t.Errorf("failed to parse tgt ref: %v", err)
+			return
+		}
+		err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithReferrers(), ImageWithDigestTags())
+		if err != nil {
+			t.Errorf("failed to copy: %v", err)
+			return
+		}
+		// only the top level manifest is passed to the hook, skipping children, referrers, and digest tags
+		if len(pushed) != 1 || pushed[0].Tag != "v1" || pushed[0].Digest != mSrc.GetDescriptor().Digest.String() {
+			t.Errorf("unexpected hook calls: %v", pushed)
+		}
+		rSig := rTgt
+		rSig.Tag = "v1-sig"
+		_, err = rc.ManifestHead(ctx, rSig)
+		if err != nil {
+			t.Errorf("push from the hook failed: %v", err)
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		rTgt, err := ref.New("ocidir://testpostpush:fail")
+		if err != nil {
+			t.Errorf("failed to parse tgt ref: %v", err)
+			return
+		}
+		err = rc.ImageCopy(ctx, rSrc, rTgt)
+		if !errors.Is(err, errSign) {
+			t.Errorf("unexpected error, expected %v, received %v", errSign, err)
+		}
+		// the manifest was pushed before the hook
+		_, err = rc.ManifestHead(ctx, rTgt)
+		if err != nil {
+			t.Errorf("manifest was not pushed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package regclient
+
+import (
+	"context"
+
+	"github.com/regclient/regclient/scheme/reg"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+type hubInfoer interface {
+	HubInfo(ctx context.Context, r ref.Ref) (reg.HubInfo, error)
+}
+
+// HubInfo returns the Docker Hub metadata for a repository, including the star count, pull count, last updated time, and description.
+// This is only available for docker.io references, and reuses the docker.io login when the Hub API requires authentication.
+func (rc *RegClient) HubInfo(ctx context.Context, r ref.Ref) (reg.HubInfo, error) {
+	schemeAPI, err := rc.schemeGet(r.Scheme)
+	if err != nil {
+		return reg.HubInfo{}, err
+	}
+	hi, ok := schemeAPI.(hubInfoer)
+	if !ok {
+		return reg.HubInfo{}, types.ErrNotImplemented
+	}
+	return hi.HubInfo(ctx, r)
+}
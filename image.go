@@ -2,14 +2,20 @@ package regclient
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/url"
+	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +25,8 @@ import (
 	_ "crypto/sha512"
 
 	digest "github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/internal/units"
 	"github.com/regclient/regclient/pkg/archive"
 	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types"
@@ -32,12 +40,14 @@ import (
 )
 
 const (
-	dockerManifestFilename = "manifest.json"
-	ociLayoutVersion       = "1.0.0"
-	ociIndexFilename       = "index.json"
-	ociLayoutFilename      = "oci-layout"
-	annotationRefName      = "org.opencontainers.image.ref.name"
-	annotationImageName    = "io.containerd.image.name"
+	dockerManifestFilename     = "manifest.json"
+	dockerRepositoriesFilename = "repositories"
+	ociLayoutVersion           = "1.0.0"
+	ociIndexFilename           = "index.json"
+	ociLayoutFilename          = "oci-layout"
+	annotationRefName          = "org.opencontainers.image.ref.name"
+	annotationImageName        = "io.containerd.image.name"
+	imageParallelDefault       = 1
 )
 
 // used by import/export to match docker tar expected format
@@ -51,13 +61,18 @@ type dockerTarManifest struct {
 
 type tarFileHandler func(header *tar.Header, trd *tarReadData) error
 type tarReadData struct {
-	tr          *tar.Reader
-	name        string
-	handleAdded bool
-	handlers    map[string]tarFileHandler
-	links       map[string][]string
-	processed   map[string]bool
-	finish      []func() error
+	tr              *tar.Reader
+	name            string
+	includeExternal bool
+	handleAdded     bool
+	pushSlots       chan struct{}
+	pushWG          sync.WaitGroup
+	pushMu          sync.Mutex
+	pushErr         error
+	handlers        map[string]tarFileHandler
+	links           map[string][]string
+	processed       map[string]bool
+	finish          []func() error
 	// data processed from various handlers
 	manifests           map[digest.Digest]manifest.Manifest
 	ociIndex            v1.Index
@@ -67,15 +82,29 @@ type tarReadData struct {
 	dockerManifest      schema2.Manifest
 }
 type tarWriteData struct {
-	tw    *tar.Writer
-	dirs  map[string]bool
-	files map[string]bool
+	tw      *tar.Writer
+	dirs    map[string]bool
+	files   map[string]bool
+	planned map[string]bool
+	entries []*tarWriteEntry
 	// uid, gid  int
 	mode      int64
 	timestamp time.Time
 }
 
+// tarWriteEntry is a manifest or blob planned for an export, written to the tar in the order planned
+type tarWriteEntry struct {
+	filename string
+	ref      ref.Ref
+	desc     types.Descriptor
+	body     []byte     // manifest content
+	blob     bool       // blobs are pulled when written, or spooled by a parallel export
+	spool    string     // temp file holding the blob content
+	done     chan error // signals a spooled blob is ready
+}
+
 type imageOpt struct {
+	batch           *ImageCopyBatch
 	callback        func(kind types.CallbackKind, instance string, state types.CallbackState, cur, total int64)
 	checkBaseDigest string
 	checkBaseRef    string
@@ -85,18 +114,216 @@ type imageOpt struct {
 	exportRef       ref.Ref
 	fastCheck       bool
 	forceRecursive  bool
+	immutableAnnot  string
+	immutableTags   []*regexp.Regexp
 	importName      string
 	includeExternal bool
+	parallel        int
 	digestTags      bool
+	downgrade       bool
+	downgraded      map[digest.Digest]types.Descriptor
 	platform        string
+	plan            *ImageCopyPlan
 	platforms       []string
 	referrerConfs   []scheme.ReferrerConfig
+	result          *ImageCopyResult
+	resultRef       ref.Ref
+	scanner         ImageScanner
+	scanThreshold   ScanSeverity
+	schema1ToOCI    bool
+	stateFile       string
+	state           *imageCopyState
 	tagList         []string
 	mu              sync.Mutex
 	seen            map[string]*imageSeen
 	finalFn         []func(context.Context) error
 }
 
+// imageCopyState is persisted to track blobs already copied to each target repository
+type imageCopyState struct {
+	mu    sync.Mutex
+	Blobs map[string][]digest.Digest `json:"blobs"`
+	dirty bool                       // blobs were added since the last save
+	saved time.Time                  // time of the last save
+}
+
+// imageCopyStateInterval limits how often the state file is written during a copy
+var imageCopyStateInterval = time.Second * 5
+
+// ImageCopyResult reports the outcome of an ImageCopy
+type ImageCopyResult struct {
+	Digest           digest.Digest    `json:"digest"`           // digest of the manifest on the target, which differs from the source after a conversion
+	Unchanged        bool             `json:"unchanged"`        // target already matched the source and nothing was copied
+	ManifestsCopied  int              `json:"manifestsCopied"`  // manifests pushed to the target
+	ManifestsSkipped int              `json:"manifestsSkipped"` // manifests already on the target
+	BlobsCopied      int              `json:"blobsCopied"`      // blobs pulled from the source and pushed to the target
+	BlobsSkipped     int              `json:"blobsSkipped"`     // blobs found on the target or mounted from the source repository
+	BytesCopied      int64            `json:"bytesCopied"`      // size of the blobs pulled and pushed
+	Duration         time.Duration    `json:"duration"`         // time to run the copy
+	Scan             *ImageScanResult `json:"scan,omitempty"`   // result of the image scan, see ImageWithScanner
+}
+
+// Rate returns the bytes copied per second
+func (result ImageCopyResult) Rate() float64 {
+	if result.Duration <= 0 {
+		return 0
+	}
+	return float64(result.BytesCopied) / result.Duration.Seconds()
+}
+
+// MarshalPretty outputs a summary of the copy
+func (result ImageCopyResult) MarshalPretty() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Digest:    %s\n", result.Digest.String())
+	fmt.Fprintf(buf, "Manifests: %d copied, %d skipped\n", result.ManifestsCopied, result.ManifestsSkipped)
+	fmt.Fprintf(buf, "Blobs:     %d copied, %d skipped\n", result.BlobsCopied, result.BlobsSkipped)
+	fmt.Fprintf(buf, "Copied:    %s in %s (%s/s)\n", units.HumanSize(float64(result.BytesCopied)), result.Duration.Round(time.Millisecond).String(), units.HumanSize(result.Rate()))
+	if result.Scan != nil {
+		scan, err := result.Scan.MarshalPretty()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(scan)
+	}
+	return buf.Bytes(), nil
+}
+
+// ImageCopyPlan lists the content a copy would transfer, see ImageWithCopyPlan
+type ImageCopyPlan struct {
+	Manifests []types.Descriptor `json:"manifests"` // manifests missing or different in the target
+	Blobs     []types.Descriptor `json:"blobs"`     // blobs missing from the target
+	Bytes     int64              `json:"bytes"`     // total size of the manifests and blobs
+	mu        sync.Mutex
+}
+
+func (plan *ImageCopyPlan) add(list *[]types.Descriptor, d types.Descriptor) {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	*list = append(*list, d)
+	if d.Size > 0 {
+		plan.Bytes += d.Size
+	}
+}
+
+// ImageCopyBatch shares blob transfers between multiple ImageCopy calls, see ImageWithCopyBatch
+type ImageCopyBatch struct {
+	Blobs     []types.Descriptor `json:"blobs"` // unique blobs missing from the targets, found when planning
+	Bytes     int64              `json:"bytes"` // total size of the missing blobs
+	mu        sync.Mutex
+	seen      map[string]*imageSeen
+	planned   map[string]bool              // blobs checked when planning, true when missing from the target
+	manifests map[string]manifest.Manifest // source manifests read when planning, reused by the copy
+}
+
+// blobKey identifies a blob or manifest in a repository
+func (batch *ImageCopyBatch) blobKey(r ref.Ref, d digest.Digest) string {
+	return imageCopyStateKey(r) + "@" + d.String()
+}
+
+// planCheck returns true when the blob needs to be checked on the target,
+// otherwise the blob was already checked and the previous result is returned
+func (batch *ImageCopyBatch) planCheck(r ref.Ref, d types.Descriptor) (bool, bool) {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	missing, ok := batch.planned[batch.blobKey(r, d.Digest)]
+	return !ok, missing
+}
+
+// planAdd records the result of checking a blob on the target, adding missing blobs to the union
+func (batch *ImageCopyBatch) planAdd(r ref.Ref, d types.Descriptor, missing bool) {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	if batch.planned == nil {
+		batch.planned = map[string]bool{}
+	}
+	key := batch.blobKey(r, d.Digest)
+	if _, ok := batch.planned[key]; ok {
+		return
+	}
+	batch.planned[key] = missing
+	if missing {
+		batch.Blobs = append(batch.Blobs, d)
+		if d.Size > 0 {
+			batch.Bytes += d.Size
+		}
+	}
+}
+
+// manifestGet returns a copy of a source manifest read when planning, or nil when the manifest was not read
+func (batch *ImageCopyBatch) manifestGet(r ref.Ref, d digest.Digest) manifest.Manifest {
+	batch.mu.Lock()
+	m, ok := batch.manifests[batch.blobKey(r, d)]
+	batch.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	raw, err := m.RawBody()
+	if err != nil {
+		return nil
+	}
+	header, _ := m.RawHeaders()
+	mCopy, err := manifest.New(
+		manifest.WithRef(m.GetRef()),
+		manifest.WithDesc(m.GetDescriptor()),
+		manifest.WithHeader(header.Clone()),
+		manifest.WithRaw(bytes.Clone(raw)),
+	)
+	if err != nil {
+		return nil
+	}
+	return mCopy
+}
+
+// manifestAdd saves a source manifest read when planning
+func (batch *ImageCopyBatch) manifestAdd(r ref.Ref, m manifest.Manifest) {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	if batch.manifests == nil {
+		batch.manifests = map[string]manifest.Manifest{}
+	}
+	batch.manifests[batch.blobKey(r, m.GetDescriptor().Digest)] = m
+}
+
+// blobSeenOrWait returns a callback when the blob should be copied to the target.
+// Blobs found on the target when planning, or copied by another image in the batch, return a nil callback.
+// When another image is copying the blob, this waits for that copy to finish.
+func (batch *ImageCopyBatch) blobSeenOrWait(ctx context.Context, r ref.Ref, d digest.Digest) (func(error), error) {
+	key := batch.blobKey(r, d)
+	batch.mu.Lock()
+	if batch.seen == nil {
+		batch.seen = map[string]*imageSeen{}
+	}
+	seen := batch.seen[key]
+	if seen == nil {
+		if missing, ok := batch.planned[key]; ok && !missing {
+			batch.mu.Unlock()
+			return nil, nil
+		}
+		seenNew := &imageSeen{
+			done: make(chan struct{}),
+		}
+		batch.seen[key] = seenNew
+		batch.mu.Unlock()
+		return func(err error) {
+			seenNew.err = err
+			close(seenNew.done)
+			// on failures, delete the history to allow a retry
+			if err != nil {
+				batch.mu.Lock()
+				delete(batch.seen, key)
+				batch.mu.Unlock()
+			}
+		}, nil
+	}
+	batch.mu.Unlock()
+	select {
+	case <-seen.done:
+		return nil, seen.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 type imageSeen struct {
 	done chan struct{}
 	err  error
@@ -140,6 +367,17 @@ func ImageWithChild() ImageOpts {
 	}
 }
 
+// ImageWithDowngrade converts OCI manifests to docker schema2 media types when the target registry rejects the push.
+// This supports older registries without OCI support, and only applies to images and indexes without OCI specific features
+// like an artifactType, subject, annotations, or zstd layers.
+// The digest of a converted manifest changes, and indexes are updated to reference the converted manifests.
+func ImageWithDowngrade() ImageOpts {
+	return func(opts *imageOpt) {
+		opts.downgrade = true
+		opts.downgraded = map[digest.Digest]types.Descriptor{}
+	}
+}
+
 // ImageWithExportCompress adds gzip compression to tar export output
 func ImageWithExportCompress() ImageOpts {
 	return func(opts *imageOpt) {
@@ -162,12 +400,24 @@ func ImageWithFastCheck() ImageOpts {
 }
 
 // ImageWithForceRecursive attempts to copy every manifest and blob even if parent manifests already exist.
+// Each child manifest and blob is checked on the target, repairing images where content was removed, e.g. by a garbage collection.
 func ImageWithForceRecursive() ImageOpts {
 	return func(opts *imageOpt) {
 		opts.forceRecursive = true
 	}
 }
 
+// ImageWithImmutable refuses to overwrite an existing target tag with a different manifest.
+// The tag is protected when the existing manifest has the annotation with any value, or when the tag matches one of the regexps.
+// An empty annotation only protects the matching tags.
+// The copy fails with types.ErrTagImmutable, omit this option to force the overwrite.
+func ImageWithImmutable(annotation string, tags ...*regexp.Regexp) ImageOpts {
+	return func(opts *imageOpt) {
+		opts.immutableAnnot = annotation
+		opts.immutableTags = tags
+	}
+}
+
 // ImageWithImportName selects the name of the image to import when multiple images are included
 func ImageWithImportName(name string) ImageOpts {
 	return func(opts *imageOpt) {
@@ -175,7 +425,9 @@ func ImageWithImportName(name string) ImageOpts {
 	}
 }
 
-// ImageWithIncludeExternal attempts to copy every manifest and blob even if parent manifests already exist.
+// ImageWithIncludeExternal includes external layers in a copy, export, or import.
+// External layers have URLs in their descriptor (e.g. Windows foreign layers) and are skipped by default.
+// Skipped layers remain in the manifest with their URLs, and are pulled from the URLs by the runtime.
 func ImageWithIncludeExternal() ImageOpts {
 	return func(opts *imageOpt) {
 		opts.includeExternal = true
@@ -190,6 +442,65 @@ func ImageWithDigestTags() ImageOpts {
 	}
 }
 
+// ImageWithSchema1ToOCI converts a docker schema1 source to an OCI image during a copy.
+// The config is generated from the v1 compatibility history and each layer is pulled to compute the uncompressed digest.
+// The converted manifest has a different digest than the source, so the target must be a tag.
+// Schema1 manifests within an index are copied without conversion.
+func ImageWithSchema1ToOCI() ImageOpts {
+	return func(opts *imageOpt) {
+		opts.schema1ToOCI = true
+	}
+}
+
+// ImageWithCopyState persists the blobs copied to a state file.
+// When the copy is rerun with the same file, previously copied blobs are skipped without checking the target.
+// The file is saved every few seconds during the copy and when the copy returns.
+func ImageWithCopyState(filename string) ImageOpts {
+	return func(opts *imageOpt) {
+		opts.stateFile = filename
+	}
+}
+
+// ImageWithCopyResult is populated with the result of an ImageCopy.
+// This can be used to detect when the target was unchanged.
+func ImageWithCopyResult(result *ImageCopyResult) ImageOpts {
+	return func(opts *imageOpt) {
+		opts.result = result
+	}
+}
+
+// ImageWithCopyPlan compares the source and target without copying any content.
+// Manifests are compared by digest and blobs are checked on the target with a HEAD request.
+// Content that would be copied is added to the plan, giving an estimate of the bytes a copy would transfer.
+// Schema1 conversion is not included in the plan.
+func ImageWithCopyPlan(plan *ImageCopyPlan) ImageOpts {
+	return func(opts *imageOpt) {
+		opts.plan = plan
+	}
+}
+
+// ImageWithCopyBatch shares blob transfers between each ImageCopy using the same batch.
+// Each unique blob is checked and copied to a target repository once, even when it is referenced by many images,
+// and concurrent copies needing the same blob wait for the first transfer rather than pulling it again.
+// Combined with ImageWithCopyPlan, the union of blobs missing from the targets is added to the batch,
+// and blobs found on a target when planning are skipped without another check when the images are copied.
+func ImageWithCopyBatch(batch *ImageCopyBatch) ImageOpts {
+	return func(opts *imageOpt) {
+		opts.batch = batch
+	}
+}
+
+// ImageWithParallel sets the number of blobs transferred concurrently by an export or import.
+// Export pulls blobs for every platform ahead of the tar writer, spooling them to temp files,
+// and the tar is written in the same order as a sequential export.
+// Import spools blobs from the tar to temp files and pushes them concurrently.
+// The default is 1, streaming each blob without a temp file.
+func ImageWithParallel(count int) ImageOpts {
+	return func(opts *imageOpt) {
+		opts.parallel = count
+	}
+}
+
 // ImageWithPlatform requests specific platforms from a manifest list.
 // This is used by ImageCheckBase.
 func ImageWithPlatform(p string) ImageOpts {
@@ -437,7 +748,7 @@ func (rc *RegClient) ImageCheckBase(ctx context.Context, r ref.Ref, opts ...Imag
 // This will retag an image in the same repository, only pushing and pulling the top level manifest
 // On the same registry, it will attempt to use cross-repository blob mounts to avoid pulling blobs
 // Blobs are only pulled when they don't exist on the target and a blob mount fails
-func (rc *RegClient) ImageCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, opts ...ImageOpts) error {
+func (rc *RegClient) ImageCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, opts ...ImageOpts) (err error) {
 	opt := imageOpt{
 		seen:    map[string]*imageSeen{},
 		finalFn: []func(context.Context) error{},
@@ -445,6 +756,10 @@ func (rc *RegClient) ImageCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.R
 	for _, optFn := range opts {
 		optFn(&opt)
 	}
+	// a converted schema1 manifest cannot match a target digest
+	if opt.schema1ToOCI && refTgt.Digest != "" {
+		return fmt.Errorf("schema1 conversion requires a tag on the target, %s%.0w", refTgt.CommonName(), types.ErrUnsupported)
+	}
 	// dedup warnings
 	if w := warning.FromContext(ctx); w == nil {
 		ctx = warning.NewContext(ctx, &warning.Warning{Hook: warning.DefaultHook()})
@@ -458,6 +773,40 @@ func (rc *RegClient) ImageCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.R
 		tgtGCLocker.GCLock(refTgt)
 		defer tgtGCLocker.GCUnlock(refTgt)
 	}
+	if opt.stateFile != "" {
+		opt.state, err = rc.imageCopyStateLoad(opt.stateFile)
+		if err != nil {
+			return err
+		}
+		// save the blobs added since the last periodic save, including after a failed copy
+		defer func() {
+			errSave := rc.imageCopyStateSave(opt.stateFile, opt.state)
+			if err == nil {
+				err = errSave
+			}
+		}()
+	}
+	if opt.result != nil {
+		*opt.result = ImageCopyResult{}
+		opt.resultRef = refTgt
+		start := time.Now()
+		defer func() {
+			opt.result.Duration = time.Since(start)
+		}()
+	}
+	if opt.plan != nil {
+		opt.plan.Manifests = []types.Descriptor{}
+		opt.plan.Blobs = []types.Descriptor{}
+		opt.plan.Bytes = 0
+	}
+	err = rc.imageCopyImmutable(ctx, refSrc, refTgt, &opt)
+	if err != nil {
+		return err
+	}
+	err = rc.imageCopyScan(ctx, refSrc, &opt)
+	if err != nil {
+		return err
+	}
 	// run the copy of manifests and blobs recursively
 	err = rc.imageCopyOpt(ctx, refSrc, refTgt, types.Descriptor{}, opt.child, []digest.Digest{}, &opt)
 	if err != nil {
@@ -473,6 +822,99 @@ func (rc *RegClient) ImageCopy(ctx context.Context, refSrc ref.Ref, refTgt ref.R
 	return nil
 }
 
+// imageCopyImmutable returns an error when the copy would replace a protected tag on the target with a different manifest
+func (rc *RegClient) imageCopyImmutable(ctx context.Context, refSrc, refTgt ref.Ref, opt *imageOpt) error {
+	if refTgt.Tag == "" || (opt.immutableAnnot == "" && len(opt.immutableTags) == 0) {
+		return nil
+	}
+	mTgt, err := rc.ManifestHead(ctx, refTgt, WithManifestRequireDigest())
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to check target %s: %w", refTgt.CommonName(), err)
+	}
+	dSrc := refSrc.Digest
+	if dSrc == "" {
+		mSrc, err := rc.ManifestHead(ctx, refSrc, WithManifestRequireDigest())
+		if err != nil {
+			return fmt.Errorf("failed to check source %s: %w", refSrc.CommonName(), err)
+		}
+		dSrc = mSrc.GetDescriptor().Digest.String()
+	}
+	if dSrc == mTgt.GetDescriptor().Digest.String() {
+		return nil
+	}
+	for _, re := range opt.immutableTags {
+		if re.MatchString(refTgt.Tag) {
+			return fmt.Errorf("refusing to overwrite %s, tag matches %s%.0w", refTgt.CommonName(), re.String(), types.ErrTagImmutable)
+		}
+	}
+	if opt.immutableAnnot != "" {
+		mTgt, err = rc.ManifestGet(ctx, refTgt, WithManifestDesc(mTgt.GetDescriptor()))
+		if err != nil {
+			return fmt.Errorf("failed to check target %s: %w", refTgt.CommonName(), err)
+		}
+		if ma, ok := mTgt.(manifest.Annotator); ok {
+			annots, err := ma.GetAnnotations()
+			if err == nil {
+				if _, ok := annots[opt.immutableAnnot]; ok {
+					return fmt.Errorf("refusing to overwrite %s, manifest has annotation %s%.0w", refTgt.CommonName(), opt.immutableAnnot, types.ErrTagImmutable)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// imageCopyChildrenExist verifies each child manifest of an index exists on the target.
+// Platforms excluded from the copy are not checked.
+func (rc *RegClient) imageCopyChildrenExist(ctx context.Context, refTgt ref.Ref, mTgt manifest.Manifest, opt *imageOpt) bool {
+	if !mTgt.IsList() {
+		return true
+	}
+	if !mTgt.IsSet() {
+		m, err := rc.ManifestGet(ctx, refTgt, WithManifestDesc(mTgt.GetDescriptor()))
+		if err != nil {
+			return false
+		}
+		mTgt = m
+	}
+	mi, ok := mTgt.(manifest.Indexer)
+	if !ok {
+		return false
+	}
+	dl, err := mi.GetManifestList()
+	if err != nil {
+		return false
+	}
+	for _, dEntry := range dl {
+		if len(opt.platforms) > 0 {
+			match, err := imagePlatformInList(dEntry.Platform, opt.platforms)
+			if err != nil || !match {
+				continue
+			}
+		}
+		rEntry := refTgt
+		rEntry.Tag = ""
+		rEntry.Digest = dEntry.Digest.String()
+		_, err := rc.ManifestHead(ctx, rEntry)
+		if err != nil {
+			rc.log.WithFields(logrus.Fields{
+				"ref": rEntry.CommonName(),
+				"err": err,
+			}).Debug("Child manifest missing on target")
+			return false
+		}
+	}
+	return true
+}
+
+// imageCopyIsTop returns true for the target requested by ImageCopy, excluding digest tags, referrers, and children
+func imageCopyIsTop(refTgt ref.Ref, child bool, opt *imageOpt) bool {
+	return !child && refTgt.CommonName() == opt.resultRef.CommonName()
+}
+
 // imageCopyOpt is a thread safe copy of a manifest and nested content
 func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, d types.Descriptor, child bool, parents []digest.Digest, opt *imageOpt) (err error) {
 	var mSrc, mTgt manifest.Manifest
@@ -512,12 +954,34 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 				return err
 			}
 		}
-		if sDig == mTgt.GetDescriptor().Digest {
+		if sDig == mTgt.GetDescriptor().Digest && (child || rc.imageCopyChildrenExist(ctx, refTgt, mTgt, opt)) {
 			if opt.callback != nil {
 				opt.callback(types.CallbackManifest, d.Digest.String(), types.CallbackSkipped, mTgt.GetDescriptor().Size, mTgt.GetDescriptor().Size)
 			}
+			opt.resultAdd(func(result *ImageCopyResult) { result.ManifestsSkipped++ })
+			if opt.result != nil && imageCopyIsTop(refTgt, child, opt) {
+				opt.result.Digest = sDig
+				opt.result.Unchanged = true
+			}
 			return nil
 		}
+		// a target downgraded by a previous copy is compared to the converted source
+		if opt.downgrade && opt.plan == nil && sDig != mTgt.GetDescriptor().Digest && (child || rc.imageCopyChildrenExist(ctx, refTgt, mTgt, opt)) {
+			if dConv, ok := rc.imageDowngradeMatch(ctx, refSrc, sDig, mTgt); ok {
+				opt.mu.Lock()
+				opt.downgraded[sDig] = dConv
+				opt.mu.Unlock()
+				if opt.callback != nil {
+					opt.callback(types.CallbackManifest, d.Digest.String(), types.CallbackSkipped, mTgt.GetDescriptor().Size, mTgt.GetDescriptor().Size)
+				}
+				opt.resultAdd(func(result *ImageCopyResult) { result.ManifestsSkipped++ })
+				if opt.result != nil && imageCopyIsTop(refTgt, child, opt) {
+					opt.result.Digest = dConv.Digest
+					opt.result.Unchanged = true
+				}
+				return nil
+			}
+		}
 	}
 	// when copying/updating digest tags or referrers, only the source digest is needed for an image
 	if mTgt != nil && mSrc == nil && !opt.forceRecursive && sDig == "" {
@@ -532,9 +996,18 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 	}
 	// get the source manifest when a copy is needed or recursion into the content is needed
 	if sDig == "" || mTgt == nil || sDig != mTgt.GetDescriptor().Digest || opt.forceRecursive || mTgt.IsList() {
-		mSrc, err = rc.ManifestGet(ctx, refSrc, WithManifestDesc(d))
-		if err != nil {
-			return fmt.Errorf("copy failed, error getting source: %w", err)
+		// manifests read while planning the batch are not requested from the source again
+		if opt.batch != nil && opt.plan == nil && sDig != "" {
+			mSrc = opt.batch.manifestGet(refSrc, sDig)
+		}
+		if mSrc == nil || !mSrc.IsSet() {
+			mSrc, err = rc.ManifestGet(ctx, refSrc, WithManifestDesc(d))
+			if err != nil {
+				return fmt.Errorf("copy failed, error getting source: %w", err)
+			}
+			if opt.batch != nil && opt.plan != nil {
+				opt.batch.manifestAdd(refSrc, mSrc)
+			}
 		}
 		if sDig == "" {
 			sDig = mSrc.GetDescriptor().Digest
@@ -543,6 +1016,54 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 			}
 		}
 	}
+	// schema1 is deprecated and rejected by many registries
+	converted := false
+	resultDig := sDig
+	if mSrc != nil && isSchema1(mSrc.GetDescriptor().MediaType) {
+		if opt.schema1ToOCI && !child && opt.plan == nil {
+			if !mSrc.IsSet() {
+				mSrc, err = rc.ManifestGet(ctx, refSrc, WithManifestDesc(d))
+				if err != nil {
+					return fmt.Errorf("copy failed, error getting source: %w", err)
+				}
+			}
+			// skip the conversion when the target already contains the converted manifest
+			if rc.imageSchema1Match(ctx, refTgt, mSrc, mTgt) {
+				if opt.callback != nil {
+					opt.callback(types.CallbackManifest, d.Digest.String(), types.CallbackSkipped, mTgt.GetDescriptor().Size, mTgt.GetDescriptor().Size)
+				}
+				opt.resultAdd(func(result *ImageCopyResult) { result.ManifestsSkipped++ })
+				if opt.result != nil && imageCopyIsTop(refTgt, child, opt) {
+					opt.result.Digest = mTgt.GetDescriptor().Digest
+					opt.result.Unchanged = true
+				}
+				return nil
+			}
+			mConv, confB, err := rc.imageSchema1ToOCI(ctx, refSrc, mSrc)
+			if err != nil {
+				return fmt.Errorf("failed to convert schema1 manifest %s: %w", refSrc.CommonName(), err)
+			}
+			err = rc.imageSchema1Put(ctx, refTgt, mConv, confB)
+			if err != nil {
+				return err
+			}
+			rc.log.WithFields(logrus.Fields{
+				"source": refSrc.CommonName(),
+				"digest": mConv.GetDescriptor().Digest.String(),
+			}).Info("Converted schema1 manifest to OCI")
+			mSrc = mConv
+			resultDig = mConv.GetDescriptor().Digest
+			converted = mTgt == nil || mConv.GetDescriptor().Digest != mTgt.GetDescriptor().Digest
+		} else {
+			rc.log.WithFields(logrus.Fields{
+				"source":    refSrc.CommonName(),
+				"mediaType": mSrc.GetDescriptor().MediaType,
+			}).Warn("Source uses the deprecated docker schema1 manifest, many registries reject these, see the option to convert to OCI")
+		}
+	}
+	if opt.result != nil && imageCopyIsTop(refTgt, child, opt) {
+		opt.result.Digest = resultDig
+	}
 	// setup vars for a copy
 	mOpts := []ManifestOpts{}
 	if child {
@@ -556,6 +1077,18 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 	waitCount := 0
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	// referrers and digest tags are started after the manifest push so the subject exists on the target first
+	extraFns := []func(){}
+	waitExtraCh := make(chan error)
+	waitExtraCount := 0
+	defer func() {
+		if waitExtraCount > 0 {
+			cancel()
+			for ; waitExtraCount > 0; waitExtraCount-- {
+				<-waitExtraCh
+			}
+		}
+	}()
 	parentsNew := make([]digest.Digest, len(parents)+1)
 	copy(parentsNew, parents)
 	parentsNew[len(parentsNew)-1] = sDig
@@ -652,8 +1185,7 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 			referrerTgt.Tag = ""
 			referrerTgt.Digest = rDesc.Digest.String()
 			rDesc := rDesc
-			waitCount++
-			go func() {
+			extraFns = append(extraFns, func() {
 				err := rc.imageCopyOpt(ctx, referrerSrc, referrerTgt, rDesc, true, parentsNew, opt)
 				if errors.Is(err, types.ErrLoopDetected) {
 					// if a loop is detected, push the referrers copy to the end
@@ -662,7 +1194,7 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 						return rc.imageCopyOpt(ctx, referrerSrc, referrerTgt, rDesc, true, []digest.Digest{}, opt)
 					})
 					opt.mu.Unlock()
-					waitCh <- nil
+					waitExtraCh <- nil
 				} else {
 					if err != nil {
 						rc.log.WithFields(logrus.Fields{
@@ -671,9 +1203,9 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 							"tgt":    referrerTgt.CommonName(),
 						}).Warn("Failed to copy referrer")
 					}
-					waitCh <- err
+					waitExtraCh <- err
 				}
-			}()
+			})
 		}
 	}
 
@@ -727,17 +1259,17 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 				refTagTgt.Tag = tag
 				refTagTgt.Digest = ""
 				tag := tag
-				waitCount++
-				go func() {
-					err := rc.imageCopyOpt(ctx, refTagSrc, refTagTgt, types.Descriptor{}, false, parentsNew, opt)
+				extraFns = append(extraFns, func() {
+					// digest tags are signatures and attestations of the image, skip the post push hooks
+					err := rc.imageCopyOpt(postPushSkip(ctx), refTagSrc, refTagTgt, types.Descriptor{}, false, parentsNew, opt)
 					if errors.Is(err, types.ErrLoopDetected) {
 						// if a loop is detected, push the digest tag copy back to the end
 						opt.mu.Lock()
 						opt.finalFn = append(opt.finalFn, func(ctx context.Context) error {
-							return rc.imageCopyOpt(ctx, refTagSrc, refTagTgt, types.Descriptor{}, false, []digest.Digest{}, opt)
+							return rc.imageCopyOpt(postPushSkip(ctx), refTagSrc, refTagTgt, types.Descriptor{}, false, []digest.Digest{}, opt)
 						})
 						opt.mu.Unlock()
-						waitCh <- nil
+						waitExtraCh <- nil
 					} else {
 						if err != nil {
 							rc.log.WithFields(logrus.Fields{
@@ -746,9 +1278,9 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 								"tgt": refTagTgt.CommonName(),
 							}).Warn("Failed to copy digest-tag")
 						}
-						waitCh <- err
+						waitExtraCh <- err
 					}
-				}()
+				})
 			}
 		}
 	}
@@ -815,12 +1347,12 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 			}()
 		}
 
-		// copy filesystem layers
+		// copy filesystem layers, smallest first so more of the image is on the target early in the copy
 		l, err := mSrcImg.GetLayers()
 		if err != nil {
 			return err
 		}
-		for _, layerSrc := range l {
+		for _, layerSrc := range imageCopyLayerOrder(l) {
 			if len(layerSrc.URLs) > 0 && !opt.includeExternal {
 				// skip blobs where the URLs are defined, these aren't hosted and won't be pulled from the source
 				rc.log.WithFields(logrus.Fields{
@@ -870,9 +1402,14 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 		return err
 	}
 
-	// push manifest
-	if mTgt == nil || sDig != mTgt.GetDescriptor().Digest || opt.forceRecursive {
-		err = rc.ManifestPut(ctx, refTgt, mSrc, mOpts...)
+	// add the manifest to the plan instead of pushing
+	if opt.plan != nil {
+		if mTgt == nil || sDig != mTgt.GetDescriptor().Digest {
+			opt.plan.add(&opt.plan.Manifests, mSrc.GetDescriptor())
+		}
+	} else if mTgt == nil || sDig != mTgt.GetDescriptor().Digest || opt.forceRecursive || converted {
+		// push manifest
+		err = rc.imageCopyPut(ctx, refTgt, sDig, mSrc, opt, mOpts...)
 		if err != nil {
 			rc.log.WithFields(logrus.Fields{
 				"target": refTgt.Reference,
@@ -880,13 +1417,42 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 			}).Warn("Failed to push manifest")
 			return err
 		}
+		if opt.downgrade && opt.result != nil && imageCopyIsTop(refTgt, child, opt) {
+			opt.mu.Lock()
+			if dConv, ok := opt.downgraded[sDig]; ok {
+				opt.result.Digest = dConv.Digest
+			}
+			opt.mu.Unlock()
+		}
 		if opt.callback != nil {
 			opt.callback(types.CallbackManifest, d.Digest.String(), types.CallbackFinished, d.Size, d.Size)
 		}
+		opt.resultAdd(func(result *ImageCopyResult) { result.ManifestsCopied++ })
 	} else {
 		if opt.callback != nil {
 			opt.callback(types.CallbackManifest, d.Digest.String(), types.CallbackSkipped, d.Size, d.Size)
 		}
+		opt.resultAdd(func(result *ImageCopyResult) { result.ManifestsSkipped++ })
+	}
+
+	// copy referrers and digest tags
+	for _, fn := range extraFns {
+		waitExtraCount++
+		go fn()
+	}
+	for waitExtraCount > 0 {
+		if err == nil {
+			err = <-waitExtraCh
+			if err != nil {
+				cancel()
+			}
+		} else {
+			<-waitExtraCh
+		}
+		waitExtraCount--
+	}
+	if err != nil {
+		return err
 	}
 	if seenCB != nil {
 		seenCB(nil)
@@ -896,16 +1462,198 @@ func (rc *RegClient) imageCopyOpt(ctx context.Context, refSrc ref.Ref, refTgt re
 	return nil
 }
 
+// imageCopyLayerOrder returns the layers sorted by size, smallest first, preserving the order of equal sized layers
+func imageCopyLayerOrder(layers []types.Descriptor) []types.Descriptor {
+	sorted := make([]types.Descriptor, len(layers))
+	copy(sorted, layers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Size < sorted[j].Size
+	})
+	return sorted
+}
+
 func (rc *RegClient) imageCopyBlob(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, d types.Descriptor, opt *imageOpt, bOpt ...BlobOpts) error {
 	seenCB, err := imageSeenOrWait(ctx, opt, "", d.Digest, []digest.Digest{})
 	if seenCB == nil {
 		return err
 	}
+	if opt.state != nil && opt.state.blobDone(refTgt, d.Digest) {
+		rc.log.WithFields(logrus.Fields{
+			"tgt":    refTgt.CommonName(),
+			"digest": d.Digest.String(),
+		}).Debug("Blob copy skipped, found in state file")
+		opt.resultAdd(func(result *ImageCopyResult) { result.BlobsSkipped++ })
+		seenCB(nil)
+		return nil
+	}
+	if opt.plan != nil {
+		// only check the target for the blob, skipping blobs already checked by the batch
+		if !ref.EqualRepository(refSrc, refTgt) {
+			check, missing := true, false
+			if opt.batch != nil {
+				check, missing = opt.batch.planCheck(refTgt, d)
+			}
+			if check {
+				_, errHead := rc.BlobHead(ctx, refTgt, d)
+				missing = errHead != nil
+				if opt.batch != nil {
+					opt.batch.planAdd(refTgt, d, missing)
+				}
+			}
+			if missing {
+				opt.plan.add(&opt.plan.Blobs, d)
+			}
+		}
+		seenCB(nil)
+		return nil
+	}
+	if opt.batch != nil {
+		var batchCB func(error)
+		batchCB, err = opt.batch.blobSeenOrWait(ctx, refTgt, d.Digest)
+		if batchCB == nil {
+			if err == nil {
+				rc.log.WithFields(logrus.Fields{
+					"tgt":    refTgt.CommonName(),
+					"digest": d.Digest.String(),
+				}).Debug("Blob copy skipped, handled by the batch")
+				opt.resultAdd(func(result *ImageCopyResult) { result.BlobsSkipped++ })
+			}
+			seenCB(err)
+			return err
+		}
+		defer func() { batchCB(err) }()
+	}
+	if opt.result != nil {
+		// track the blobs copied and skipped, passing the progress to any callback
+		bOpt = append(bOpt, BlobWithCallback(func(kind types.CallbackKind, instance string, state types.CallbackState, cur, total int64) {
+			switch state {
+			case types.CallbackSkipped:
+				opt.resultAdd(func(result *ImageCopyResult) { result.BlobsSkipped++ })
+			case types.CallbackFinished:
+				opt.resultAdd(func(result *ImageCopyResult) {
+					result.BlobsCopied++
+					result.BytesCopied += total
+				})
+			}
+			if opt.callback != nil {
+				opt.callback(kind, instance, state, cur, total)
+			}
+		}))
+	}
 	err = rc.BlobCopy(ctx, refSrc, refTgt, d, bOpt...)
+	if err == nil && opt.state != nil {
+		err = rc.imageCopyStateAdd(opt.stateFile, opt.state, refTgt, d.Digest)
+	}
 	seenCB(err)
 	return err
 }
 
+// resultAdd updates the copy result when requested
+func (opt *imageOpt) resultAdd(fn func(result *ImageCopyResult)) {
+	if opt.result == nil {
+		return
+	}
+	opt.mu.Lock()
+	defer opt.mu.Unlock()
+	fn(opt.result)
+}
+
+// imageCopyStateLoad reads the state file, returning an empty state if the file does not exist
+func (rc *RegClient) imageCopyStateLoad(filename string) (*imageCopyState, error) {
+	state := &imageCopyState{
+		Blobs: map[string][]digest.Digest{},
+	}
+	b, err := rwfs.ReadFile(rc.fs, filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read copy state %s: %w", filename, err)
+	}
+	err = json.Unmarshal(b, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse copy state %s: %w", filename, err)
+	}
+	if state.Blobs == nil {
+		state.Blobs = map[string][]digest.Digest{}
+	}
+	return state, nil
+}
+
+// imageCopyStateAdd records a copied blob, saving the state file when imageCopyStateInterval has passed since the last save
+func (rc *RegClient) imageCopyStateAdd(filename string, state *imageCopyState, r ref.Ref, d digest.Digest) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	key := imageCopyStateKey(r)
+	state.Blobs[key] = append(state.Blobs[key], d)
+	state.dirty = true
+	if time.Since(state.saved) < imageCopyStateInterval {
+		return nil
+	}
+	return rc.imageCopyStateWrite(filename, state)
+}
+
+// imageCopyStateSave writes the state file when blobs were added since the last save
+func (rc *RegClient) imageCopyStateSave(filename string, state *imageCopyState) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.dirty {
+		return nil
+	}
+	return rc.imageCopyStateWrite(filename, state)
+}
+
+// imageCopyStateWrite saves the state file, the state must be locked
+func (rc *RegClient) imageCopyStateWrite(filename string, state *imageCopyState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	// write to a tmp file and rename to avoid a corrupt state when interrupted
+	dir := path.Dir(filename)
+	tmpFile, err := rwfs.CreateTemp(rc.fs, dir, path.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create copy state tmpfile: %w", err)
+	}
+	fi, err := tmpFile.Stat()
+	if err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to stat copy state tmpfile: %w", err)
+	}
+	tmpName := path.Join(dir, fi.Name())
+	_, err = tmpFile.Write(b)
+	tmpFile.Close()
+	if err != nil {
+		_ = rc.fs.Remove(tmpName)
+		return fmt.Errorf("failed to write copy state tmpfile: %w", err)
+	}
+	err = rc.fs.Rename(tmpName, filename)
+	if err != nil {
+		return fmt.Errorf("failed to write copy state %s: %w", filename, err)
+	}
+	state.dirty = false
+	state.saved = time.Now()
+	return nil
+}
+
+func (state *imageCopyState) blobDone(r ref.Ref, d digest.Digest) bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, cur := range state.Blobs[imageCopyStateKey(r)] {
+		if cur == d {
+			return true
+		}
+	}
+	return false
+}
+
+// imageCopyStateKey returns the repository without the tag or digest
+func imageCopyStateKey(r ref.Ref) string {
+	r.Tag = ""
+	r.Digest = ""
+	return r.CommonName()
+}
+
 // imageSeenOrWait returns either a callback to report the error when the digest hasn't been seen before
 // or it will wait for the previous copy to run and return the error from that copy
 func imageSeenOrWait(ctx context.Context, opt *imageOpt, tag string, dig digest.Digest, parents []digest.Digest) (func(error), error) {
@@ -966,17 +1714,41 @@ func imageSeenOrWait(ctx context.Context, opt *imageOpt, tag string, dig digest.
 // oci-layout: created at top level, can be done at the start
 // index.json: created at top level, single descriptor with org.opencontainers.image.ref.name annotation pointing to the tag
 // manifest.json: created at top level, based on every layer added, only works for a single arch image
+// repositories: created at top level, legacy mapping of repository and tag to the top layer
 // blobs/$algo/$hash: each content addressable object (manifest, config, or layer), created recursively
+//
+// The output is reproducible, entries are written in a fixed order with the Unix epoch timestamp, fixed permissions, and no owner,
+// so exporting the same digest with the same options produces an identical file.
 func (rc *RegClient) ImageExport(ctx context.Context, r ref.Ref, outStream io.Writer, opts ...ImageOpts) error {
-	var ociIndex v1.Index
+	var opt imageOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	name := opt.exportRef
+	if name.IsZero() {
+		name = r
+	}
+	return rc.imageExport(ctx, []ref.Ref{r}, []ref.Ref{name}, outStream, &opt)
+}
 
+// ImageExportMulti exports multiple images to a single output stream.
+// Each ref is included in the index.json, and each single platform image is included in the manifest.json with its tag.
+// Refs pointing to the same digest are exported once with multiple tags.
+// ImageWithExportRef is ignored, each image is named by its ref.
+// See ImageExport for details on the format.
+func (rc *RegClient) ImageExportMulti(ctx context.Context, refs []ref.Ref, outStream io.Writer, opts ...ImageOpts) error {
 	var opt imageOpt
 	for _, optFn := range opts {
 		optFn(&opt)
 	}
-	if opt.exportRef.IsZero() {
-		opt.exportRef = r
+	if len(refs) == 0 {
+		return fmt.Errorf("at least one image is required for export%.0w", types.ErrMissingName)
 	}
+	return rc.imageExport(ctx, refs, refs, outStream, &opt)
+}
+
+func (rc *RegClient) imageExport(ctx context.Context, refs, names []ref.Ref, outStream io.Writer, opt *imageOpt) error {
+	var ociIndex v1.Index
 
 	// create tar writer object
 	out := outStream
@@ -988,96 +1760,131 @@ func (rc *RegClient) ImageExport(ctx context.Context, r ref.Ref, outStream io.Wr
 	tw := tar.NewWriter(out)
 	defer tw.Close()
 	twd := &tarWriteData{
-		tw:    tw,
-		dirs:  map[string]bool{},
-		files: map[string]bool{},
-		mode:  0644,
+		tw:        tw,
+		dirs:      map[string]bool{},
+		files:     map[string]bool{},
+		planned:   map[string]bool{},
+		mode:      0644,
+		timestamp: exportTimestamp,
 	}
 
-	// retrieve image manifest
-	m, err := rc.ManifestGet(ctx, r)
-	if err != nil {
-		rc.log.WithFields(logrus.Fields{
-			"ref": r.CommonName(),
-			"err": err,
-		}).Warn("Failed to get manifest")
-		return err
+	// retrieve each image manifest
+	ociIndex.Versioned = v1.IndexSchemaVersion
+	ociIndex.Manifests = []types.Descriptor{}
+	dockerManifests := []dockerTarManifest{}
+	dockerManifestIndex := map[digest.Digest]int{}
+	repositories := map[string]map[string]string{}
+	for i, r := range refs {
+		m, err := rc.ManifestGet(ctx, r)
+		if err != nil {
+			rc.log.WithFields(logrus.Fields{
+				"ref": r.CommonName(),
+				"err": err,
+			}).Warn("Failed to get manifest")
+			return err
+		}
+
+		// create a manifest descriptor
+		mDesc := m.GetDescriptor()
+		mDesc.Annotations = map[string]string{}
+		for k, v := range m.GetDescriptor().Annotations {
+			mDesc.Annotations[k] = v
+		}
+		mDesc.Annotations[annotationImageName] = names[i].CommonName()
+		mDesc.Annotations[annotationRefName] = names[i].Tag
+		ociIndex.Manifests = append(ociIndex.Manifests, mDesc)
+
+		// append to docker manifest with tag, config filename, each layer filename, and layer descriptors
+		mi, ok := m.(manifest.Imager)
+		if !ok {
+			continue
+		}
+		refTag := names[i].ToReg()
+		if refTag.Digest != "" {
+			refTag.Digest = ""
+		}
+		if refTag.Tag == "" {
+			refTag.Tag = "latest"
+		}
+		if di, ok := dockerManifestIndex[mDesc.Digest]; ok {
+			dockerManifests[di].RepoTags = append(dockerManifests[di].RepoTags, refTag.CommonName())
+		} else {
+			conf, err := mi.GetConfig()
+			if err != nil {
+				return err
+			}
+			dockerManifest := dockerTarManifest{
+				RepoTags:     []string{refTag.CommonName()},
+				Config:       tarOCILayoutDescPath(conf),
+				Layers:       []string{},
+				LayerSources: map[digest.Digest]types.Descriptor{},
+			}
+			dl, err := mi.GetLayers()
+			if err != nil {
+				return err
+			}
+			for _, d := range dl {
+				dockerManifest.Layers = append(dockerManifest.Layers, tarOCILayoutDescPath(d))
+				dockerManifest.LayerSources[d.Digest] = d
+			}
+			dockerManifestIndex[mDesc.Digest] = len(dockerManifests)
+			dockerManifests = append(dockerManifests, dockerManifest)
+		}
+		// the legacy repositories file points to the top layer
+		if dl, err := mi.GetLayers(); err == nil && len(dl) > 0 {
+			repoName := refTag
+			repoName.Tag = ""
+			if repositories[repoName.CommonName()] == nil {
+				repositories[repoName.CommonName()] = map[string]string{}
+			}
+			repositories[repoName.CommonName()][refTag.Tag] = dl[len(dl)-1].Digest.Encoded()
+		}
 	}
 
 	// build/write oci-layout
 	ociLayout := v1.ImageLayout{Version: ociLayoutVersion}
-	err = twd.tarWriteFileJSON(ociLayoutFilename, ociLayout)
+	err := twd.tarWriteFileJSON(ociLayoutFilename, ociLayout)
 	if err != nil {
 		return err
 	}
-
-	// create a manifest descriptor
-	mDesc := m.GetDescriptor()
-	if mDesc.Annotations == nil {
-		mDesc.Annotations = map[string]string{}
-	}
-	mDesc.Annotations[annotationImageName] = opt.exportRef.CommonName()
-	mDesc.Annotations[annotationRefName] = opt.exportRef.Tag
-
-	// generate/write an OCI index
-	ociIndex.Versioned = v1.IndexSchemaVersion
-	ociIndex.Manifests = []types.Descriptor{mDesc} // initialize with the descriptor to the manifest list
+	// write the OCI index
 	err = twd.tarWriteFileJSON(ociIndexFilename, ociIndex)
 	if err != nil {
 		return err
 	}
-
-	// append to docker manifest with tag, config filename, each layer filename, and layer descriptors
-	if mi, ok := m.(manifest.Imager); ok {
-		conf, err := mi.GetConfig()
+	// marshal manifest and write manifest.json and repositories
+	if len(dockerManifests) > 0 {
+		err = twd.tarWriteFileJSON(dockerManifestFilename, dockerManifests)
 		if err != nil {
 			return err
 		}
-		refTag := opt.exportRef.ToReg()
-		if refTag.Digest != "" {
-			refTag.Digest = ""
-		}
-		if refTag.Tag == "" {
-			refTag.Tag = "latest"
-		}
-		dockerManifest := dockerTarManifest{
-			RepoTags:     []string{refTag.CommonName()},
-			Config:       tarOCILayoutDescPath(conf),
-			Layers:       []string{},
-			LayerSources: map[digest.Digest]types.Descriptor{},
-		}
-		dl, err := mi.GetLayers()
+	}
+	if len(repositories) > 0 {
+		err = twd.tarWriteFileJSON(dockerRepositoriesFilename, repositories)
 		if err != nil {
 			return err
 		}
-		for _, d := range dl {
-			dockerManifest.Layers = append(dockerManifest.Layers, tarOCILayoutDescPath(d))
-			dockerManifest.LayerSources[d.Digest] = d
-		}
+	}
 
-		// marshal manifest and write manifest.json
-		err = twd.tarWriteFileJSON(dockerManifestFilename, []dockerTarManifest{dockerManifest})
+	// recursively plan manifests and nested blobs
+	for i, r := range refs {
+		err = rc.imageExportDescriptor(ctx, r, ociIndex.Manifests[i], twd, opt)
 		if err != nil {
 			return err
 		}
 	}
 
-	// recursively include manifests and nested blobs
-	err = rc.imageExportDescriptor(ctx, r, mDesc, twd)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return rc.imageExportWrite(ctx, twd, opt)
 }
 
-// imageExportDescriptor pulls a manifest or blob, outputs to a tar file, and recursively processes any nested manifests or blobs
-func (rc *RegClient) imageExportDescriptor(ctx context.Context, ref ref.Ref, desc types.Descriptor, twd *tarWriteData) error {
+// imageExportDescriptor pulls a manifest or plans a blob for the tar file, and recursively processes any nested manifests or blobs
+func (rc *RegClient) imageExportDescriptor(ctx context.Context, ref ref.Ref, desc types.Descriptor, twd *tarWriteData, opt *imageOpt) error {
 	tarFilename := tarOCILayoutDescPath(desc)
-	if twd.files[tarFilename] {
-		// blob has already been imported into tar, skip
+	if twd.planned[tarFilename] {
+		// blob has already been included in the tar, skip
 		return nil
 	}
+	twd.planned[tarFilename] = true
 	switch desc.MediaType {
 	case types.MediaTypeDocker1Manifest, types.MediaTypeDocker1ManifestSigned, types.MediaTypeDocker2Manifest, types.MediaTypeOCI1Manifest:
 		// Handle single platform manifests
@@ -1095,14 +1902,7 @@ func (rc *RegClient) imageExportDescriptor(ctx context.Context, ref ref.Ref, des
 		if err != nil {
 			return err
 		}
-		err = twd.tarWriteHeader(tarFilename, int64(len(mBody)))
-		if err != nil {
-			return err
-		}
-		_, err = twd.tw.Write(mBody)
-		if err != nil {
-			return err
-		}
+		twd.entries = append(twd.entries, &tarWriteEntry{filename: tarFilename, body: mBody})
 
 		// add config
 		confD, err := mi.GetConfig()
@@ -1111,7 +1911,7 @@ func (rc *RegClient) imageExportDescriptor(ctx context.Context, ref ref.Ref, des
 			return err
 		}
 		if err == nil {
-			err = rc.imageExportDescriptor(ctx, ref, confD, twd)
+			err = rc.imageExportDescriptor(ctx, ref, confD, twd, opt)
 			if err != nil {
 				return err
 			}
@@ -1125,7 +1925,16 @@ func (rc *RegClient) imageExportDescriptor(ctx context.Context, ref ref.Ref, des
 		}
 		if err == nil {
 			for _, layerD := range layerDL {
-				err = rc.imageExportDescriptor(ctx, ref, layerD, twd)
+				if len(layerD.URLs) > 0 && !opt.includeExternal {
+					// skip external layers, these are pulled from the URLs by the runtime
+					rc.log.WithFields(logrus.Fields{
+						"ref":           ref.CommonName(),
+						"layer":         layerD.Digest.String(),
+						"external-urls": layerD.URLs,
+					}).Debug("Skipping external layer")
+					continue
+				}
+				err = rc.imageExportDescriptor(ctx, ref, layerD, twd, opt)
 				if err != nil {
 					return err
 				}
@@ -1148,48 +1957,261 @@ func (rc *RegClient) imageExportDescriptor(ctx context.Context, ref ref.Ref, des
 		if err != nil {
 			return err
 		}
-		err = twd.tarWriteHeader(tarFilename, int64(len(mBody)))
-		if err != nil {
-			return err
-		}
-		_, err = twd.tw.Write(mBody)
-		if err != nil {
-			return err
-		}
+		twd.entries = append(twd.entries, &tarWriteEntry{filename: tarFilename, body: mBody})
 		// recurse over entries in the list/index
 		mdl, err := mi.GetManifestList()
 		if err != nil {
 			return err
 		}
 		for _, md := range mdl {
-			err = rc.imageExportDescriptor(ctx, ref, md, twd)
+			err = rc.imageExportDescriptor(ctx, ref, md, twd, opt)
 			if err != nil {
 				return err
 			}
 		}
 
 	default:
-		// get blob
-		blobR, err := rc.BlobGet(ctx, ref, desc)
+		// blobs are pulled when the tar is written
+		twd.entries = append(twd.entries, &tarWriteEntry{
+			filename: tarFilename,
+			ref:      ref,
+			desc:     desc,
+			blob:     true,
+			done:     make(chan error, 1),
+		})
+	}
+
+	return nil
+}
+
+// imageExportWrite outputs each planned manifest and blob to the tar file in the order they were planned.
+// With more than one parallel transfer, a bounded pool pulls blobs ahead of the writer into temp files,
+// so platforms of an index are pulled concurrently while the tar content matches a sequential export.
+func (rc *RegClient) imageExportWrite(ctx context.Context, twd *tarWriteData, opt *imageOpt) error {
+	parallel := opt.parallel
+	if parallel <= 0 {
+		parallel = imageParallelDefault
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		// stop any workers and cleanup spooled blobs that were not written
+		cancel()
+		wg.Wait()
+		for _, e := range twd.entries {
+			if e.spool != "" {
+				_ = os.Remove(e.spool)
+			}
+		}
+	}()
+	var slots chan struct{}
+	if parallel > 1 {
+		// the slots limit the blobs pulled and waiting to be written
+		slots = make(chan struct{}, parallel)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, e := range twd.entries {
+				if !e.blob {
+					continue
+				}
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				wg.Add(1)
+				go func(e *tarWriteEntry) {
+					defer wg.Done()
+					e.done <- rc.imageExportSpool(ctx, e)
+				}(e)
+			}
+		}()
+	}
+
+	for _, e := range twd.entries {
+		if !e.blob {
+			err := twd.tarWriteHeader(e.filename, int64(len(e.body)))
+			if err != nil {
+				return err
+			}
+			_, err = twd.tw.Write(e.body)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if slots == nil {
+			err := rc.imageExportBlob(ctx, twd, e)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		select {
+		case err := <-e.done:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		err := rc.imageExportBlob(ctx, twd, e)
 		if err != nil {
 			return err
 		}
-		defer blobR.Close()
-		// write blob by digest
-		err = twd.tarWriteHeader(tarFilename, int64(desc.Size))
+		err = os.Remove(e.spool)
 		if err != nil {
 			return err
 		}
-		size, err := io.Copy(twd.tw, blobR)
+		e.spool = ""
+		<-slots
+	}
+	return nil
+}
+
+// imageExportSpool pulls a blob into a temp file
+func (rc *RegClient) imageExportSpool(ctx context.Context, e *tarWriteEntry) error {
+	blobR, err := rc.BlobGet(ctx, e.ref, e.desc)
+	if err != nil {
+		return err
+	}
+	defer blobR.Close()
+	fh, err := os.CreateTemp("", "regclient.*")
+	if err != nil {
+		return err
+	}
+	e.spool = fh.Name()
+	size, err := io.Copy(fh, blobR)
+	errC := fh.Close()
+	if err != nil {
+		return fmt.Errorf("failed to export blob %s: %w", e.desc.Digest.String(), err)
+	}
+	if errC != nil {
+		return errC
+	}
+	if size != e.desc.Size {
+		return fmt.Errorf("blob size mismatch, descriptor %d, received %d", e.desc.Size, size)
+	}
+	return nil
+}
+
+// imageExportBlob writes a blob to the tar file from the spooled temp file, or pulls the blob when it was not spooled
+func (rc *RegClient) imageExportBlob(ctx context.Context, twd *tarWriteData, e *tarWriteEntry) error {
+	var rdr io.ReadCloser
+	var err error
+	if e.spool != "" {
+		rdr, err = os.Open(e.spool)
+	} else {
+		rdr, err = rc.BlobGet(ctx, e.ref, e.desc)
+	}
+	if err != nil {
+		return err
+	}
+	defer rdr.Close()
+	// write blob by digest
+	err = twd.tarWriteHeader(e.filename, int64(e.desc.Size))
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(twd.tw, rdr)
+	if err != nil {
+		return fmt.Errorf("failed to export blob %s: %w", e.desc.Digest.String(), err)
+	}
+	if size != e.desc.Size {
+		return fmt.Errorf("blob size mismatch, descriptor %d, received %d", e.desc.Size, size)
+	}
+	return nil
+}
+
+// ImageGetFile returns a single file from an image.
+// Layers are searched from the top down, stopping when the file is found or a whiteout deletes it.
+// Each layer is streamed, and layers above the matching one are only read until the file or EOF is seen.
+// The platform defaults to the local platform when the reference is a manifest list (see ImageWithPlatform).
+// The returned reader must be closed by the caller.
+func (rc *RegClient) ImageGetFile(ctx context.Context, r ref.Ref, filename string, opts ...ImageOpts) (*tar.Header, io.ReadCloser, error) {
+	var opt imageOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	if opt.platform == "" {
+		opt.platform = "local"
+	}
+	filename = strings.TrimPrefix(filename, "/")
+	if filename == "" {
+		return nil, nil, fmt.Errorf("filename is required%.0w", types.ErrFileNotFound)
+	}
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	// resolve the platform, looping for nested manifest lists
+	for m.IsList() {
+		p, err := platform.Parse(opt.platform)
+		if err != nil {
+			return nil, nil, err
+		}
+		d, err := manifest.GetPlatformDesc(m, &p)
 		if err != nil {
-			return fmt.Errorf("failed to export blob %s: %w", desc.Digest.String(), err)
+			return nil, nil, err
 		}
-		if size != desc.Size {
-			return fmt.Errorf("blob size mismatch, descriptor %d, received %d", desc.Size, size)
+		m, err = rc.ManifestGet(ctx, r, WithManifestDesc(*d))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to pull platform specific digest: %w", err)
 		}
 	}
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return nil, nil, fmt.Errorf("reference is not a known image media type%.0w", types.ErrUnsupportedMediaType)
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		b, err := rc.BlobGet(ctx, r, layers[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed pulling layer %d: %w", i, err)
+		}
+		btr, err := b.ToTarReader()
+		if err != nil {
+			b.Close()
+			return nil, nil, fmt.Errorf("could not convert layer %d to tar reader: %w", i, err)
+		}
+		th, rdr, err := btr.ReadFile(filename)
+		if err != nil {
+			btr.Close()
+			b.Close()
+			if errors.Is(err, types.ErrFileNotFound) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed pulling from layer %d: %w", i, err)
+		}
+		rc.log.WithFields(logrus.Fields{
+			"ref":      r.CommonName(),
+			"filename": filename,
+			"layer":    i,
+		}).Debug("Found file in image")
+		return th, &imageFileReader{Reader: rdr, closers: []io.Closer{btr, b}}, nil
+	}
+	return nil, nil, fmt.Errorf("%s was not found in %s: %w", filename, r.CommonName(), types.ErrFileNotFound)
+}
 
-	return nil
+// imageFileReader closes the underlying layer when the file has been read
+type imageFileReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (ifr *imageFileReader) Close() error {
+	var errFirst error
+	for _, c := range ifr.closers {
+		if err := c.Close(); err != nil && errFirst == nil {
+			errFirst = err
+		}
+	}
+	ifr.closers = nil
+	return errFirst
 }
 
 // ImageImport pushes an image from a tar file to a registry
@@ -1200,13 +2222,25 @@ func (rc *RegClient) ImageImport(ctx context.Context, ref ref.Ref, rs io.ReadSee
 	}
 
 	trd := &tarReadData{
-		name:      opt.importName,
-		handlers:  map[string]tarFileHandler{},
-		links:     map[string][]string{},
-		processed: map[string]bool{},
-		finish:    []func() error{},
-		manifests: map[digest.Digest]manifest.Manifest{},
-	}
+		name:            opt.importName,
+		includeExternal: opt.includeExternal,
+		handlers:        map[string]tarFileHandler{},
+		links:           map[string][]string{},
+		processed:       map[string]bool{},
+		finish:          []func() error{},
+		manifests:       map[digest.Digest]manifest.Manifest{},
+	}
+	parallel := opt.parallel
+	if parallel <= 0 {
+		parallel = imageParallelDefault
+	}
+	if parallel > 1 {
+		trd.pushSlots = make(chan struct{}, parallel)
+	}
+	// wait for blob pushes to finish before returning, cancelling any pushes on an error
+	defer trd.pushWait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// add handler for oci-layout, index.json, and manifest.json
 	rc.imageImportOCIAddHandler(ctx, ref, trd)
@@ -1218,7 +2252,10 @@ func (rc *RegClient) ImageImport(ctx context.Context, ref ref.Ref, rs io.ReadSee
 	if err != nil && errors.Is(err, types.ErrNotFound) && trd.dockerManifestFound {
 		// import failed but manifest.json found, fall back to manifest.json processing
 		// add handlers for the docker manifest layers
-		rc.imageImportDockerAddLayerHandlers(ctx, ref, trd)
+		err = rc.imageImportDockerAddLayerHandlers(ctx, ref, trd)
+		if err != nil {
+			return err
+		}
 		// reprocess the tar looking for manifest.json files
 		err = trd.tarReadAll(rs)
 		if err != nil {
@@ -1238,6 +2275,10 @@ func (rc *RegClient) ImageImport(ctx context.Context, ref ref.Ref, rs io.ReadSee
 		return err
 	} else {
 		// successful load of OCI blobs, now push manifest and tag
+		err = trd.pushWait()
+		if err != nil {
+			return err
+		}
 		err = rc.imageImportOCIPushManifests(ctx, ref, trd)
 		if err != nil {
 			return err
@@ -1252,14 +2293,68 @@ func (rc *RegClient) imageImportBlob(ctx context.Context, ref ref.Ref, desc type
 	if err == nil {
 		return nil
 	}
-	// upload blob
-	_, err = rc.BlobPut(ctx, ref, desc, trd.tr)
+	if trd.pushSlots == nil {
+		// upload blob
+		_, err = rc.BlobPut(ctx, ref, desc, trd.tr)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+	// spool the blob to a temp file so the tar can be read while blobs are pushed concurrently
+	trd.pushMu.Lock()
+	err = trd.pushErr
+	trd.pushMu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case trd.pushSlots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	fh, err := os.CreateTemp("", "regclient.*")
 	if err != nil {
+		<-trd.pushSlots
 		return err
 	}
+	cleanup := func() {
+		_ = fh.Close()
+		_ = os.Remove(fh.Name())
+		<-trd.pushSlots
+	}
+	_, err = io.Copy(fh, trd.tr)
+	if err == nil {
+		_, err = fh.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return err
+	}
+	trd.pushWG.Add(1)
+	go func() {
+		defer trd.pushWG.Done()
+		defer cleanup()
+		_, err := rc.BlobPut(ctx, ref, desc, fh)
+		if err != nil {
+			trd.pushMu.Lock()
+			if trd.pushErr == nil {
+				trd.pushErr = err
+			}
+			trd.pushMu.Unlock()
+		}
+	}()
 	return nil
 }
 
+// pushWait waits for concurrent blob pushes to finish and returns the first error
+func (trd *tarReadData) pushWait() error {
+	trd.pushWG.Wait()
+	trd.pushMu.Lock()
+	defer trd.pushMu.Unlock()
+	return trd.pushErr
+}
+
 // imageImportDockerAddHandler processes tar files generated by docker
 func (rc *RegClient) imageImportDockerAddHandler(trd *tarReadData) {
 	trd.handlers[dockerManifestFilename] = func(header *tar.Header, trd *tarReadData) error {
@@ -1273,11 +2368,14 @@ func (rc *RegClient) imageImportDockerAddHandler(trd *tarReadData) {
 }
 
 // imageImportDockerAddLayerHandlers imports the docker layers when OCI import fails and docker manifest found
-func (rc *RegClient) imageImportDockerAddLayerHandlers(ctx context.Context, ref ref.Ref, trd *tarReadData) {
+func (rc *RegClient) imageImportDockerAddLayerHandlers(ctx context.Context, ref ref.Ref, trd *tarReadData) error {
 	// remove handlers for OCI
 	delete(trd.handlers, ociLayoutFilename)
 	delete(trd.handlers, ociIndexFilename)
 
+	if len(trd.dockerManifestList) == 0 {
+		return fmt.Errorf("no images found in %s%.0w", dockerManifestFilename, types.ErrNotFound)
+	}
 	index := 0
 	if trd.name != "" {
 		found := false
@@ -1285,7 +2383,7 @@ func (rc *RegClient) imageImportDockerAddLayerHandlers(ctx context.Context, ref
 		for i, entry := range trd.dockerManifestList {
 			tags = append(tags, entry.RepoTags...)
 			for _, tag := range entry.RepoTags {
-				if tag == trd.name {
+				if imageImportNameMatch(trd.name, tag) {
 					index = i
 					found = true
 					break
@@ -1300,8 +2398,12 @@ func (rc *RegClient) imageImportDockerAddLayerHandlers(ctx context.Context, ref
 				"tags": tags,
 				"name": trd.name,
 			}).Warn("Could not find requested name")
-			return
+			return fmt.Errorf("could not find requested name in %s, %s%.0w", dockerManifestFilename, trd.name, types.ErrNotFound)
 		}
+	} else if len(trd.dockerManifestList) > 1 {
+		rc.log.WithFields(logrus.Fields{
+			"tags": trd.dockerManifestList[0].RepoTags,
+		}).Info("Multiple images found, importing the first image")
 	}
 
 	// make a docker v2 manifest from first json array entry (can only tag one image)
@@ -1327,6 +2429,11 @@ func (rc *RegClient) imageImportDockerAddLayerHandlers(ctx context.Context, ref
 	}
 	// add handlers for each layer
 	for i, layerFile := range trd.dockerManifestList[index].Layers {
+		// external layers are not included in the tar, the descriptor is copied from the layer sources
+		if od, ok := imageImportDockerExternal(trd.dockerManifestList[index], layerFile); ok && !trd.includeExternal {
+			trd.dockerManifest.Layers[i] = od
+			continue
+		}
 		func(i int) {
 			trd.handlers[filepath.Clean(layerFile)] = func(header *tar.Header, trd *tarReadData) error {
 				// ensure blob is compressed with gzip to match media type
@@ -1351,6 +2458,47 @@ func (rc *RegClient) imageImportDockerAddLayerHandlers(ctx context.Context, ref
 		}(i)
 	}
 	trd.handleAdded = true
+	return nil
+}
+
+// imageImportDockerExternal returns the descriptor from the layer sources when the layer file is an external layer
+func imageImportDockerExternal(dtm dockerTarManifest, layerFile string) (types.Descriptor, bool) {
+	// layer filenames in an OCI layout are the digest of the blob
+	dir, enc := path.Split(filepath.ToSlash(filepath.Clean(layerFile)))
+	alg := path.Base(dir)
+	if !strings.HasPrefix(dir, "blobs/") {
+		return types.Descriptor{}, false
+	}
+	d, err := digest.Parse(alg + ":" + enc)
+	if err != nil {
+		return types.Descriptor{}, false
+	}
+	od, ok := dtm.LayerSources[d]
+	if !ok || len(od.URLs) == 0 {
+		return types.Descriptor{}, false
+	}
+	return od, true
+}
+
+// imageImportNameMatch returns true when the requested name matches a name from the tar.
+// Names are compared as references, e.g. "alpine" matches "docker.io/library/alpine:latest".
+func imageImportNameMatch(want, have string) bool {
+	if want == "" || have == "" {
+		return false
+	}
+	if want == have {
+		return true
+	}
+	rWant, err := ref.New(want)
+	if err != nil {
+		return false
+	}
+	rHave, err := ref.New(have)
+	if err != nil {
+		return false
+	}
+	rWant, rHave = rWant.ToReg(), rHave.ToReg()
+	return ref.EqualRepository(rWant, rHave) && rWant.Tag == rHave.Tag
 }
 
 // imageImportOCIAddHandler adds handlers for oci-layout and index.json found in OCI layout tar files
@@ -1472,7 +2620,7 @@ func (rc *RegClient) imageImportOCIHandleManifest(ctx context.Context, ref ref.R
 			d.Digest = digest.Digest(ref.Digest)
 		} else if trd.name != "" {
 			for _, cur := range dl {
-				if cur.Annotations[annotationRefName] == trd.name {
+				if cur.Annotations[annotationRefName] == trd.name || imageImportNameMatch(trd.name, cur.Annotations[annotationImageName]) {
 					d = cur
 					break
 				}
@@ -1541,6 +2689,10 @@ func (rc *RegClient) imageImportOCIHandleManifest(ctx context.Context, ref ref.R
 			return err
 		}
 		for _, d := range layers {
+			if len(d.URLs) > 0 && !trd.includeExternal {
+				// external layers are not included in the tar
+				continue
+			}
 			filename := tarOCILayoutDescPath(d)
 			if !trd.processed[filename] && trd.handlers[filename] == nil {
 				func(d types.Descriptor) {
@@ -1733,6 +2885,9 @@ func (trd *tarReadData) tarReadFileJSON(data interface{}) error {
 
 var errTarFileExists = errors.New("tar file already exists")
 
+// exportTimestamp is used for every tar entry to make exports reproducible
+var exportTimestamp = time.Unix(0, 0).UTC()
+
 func (td *tarWriteData) tarWriteHeader(filename string, size int64) error {
 	dirname := filepath.Dir(filename)
 	if !td.dirs[dirname] && dirname != "." {
@@ -2,14 +2,25 @@ package regclient
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"regexp"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/internal/throttle"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/scheme/ocidir"
 	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
 	"github.com/regclient/regclient/types/ref"
 )
 
@@ -211,6 +222,976 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+func TestCopyState(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	stateFile := "copy-state.json"
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://teststate:v1")
+	if err != nil {
+		t.Errorf("failed to parse tgt ref: %v", err)
+		return
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyState(stateFile))
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	state, err := rc.imageCopyStateLoad(stateFile)
+	if err != nil {
+		t.Errorf("failed to load state: %v", err)
+		return
+	}
+	blobs := state.Blobs[imageCopyStateKey(rTgt)]
+	if len(blobs) == 0 {
+		t.Errorf("no blobs recorded in state file")
+		return
+	}
+	// remove a blob from the target, a copy using the state should not restore it
+	err = fsMem.Remove("teststate/blobs/" + blobs[0].Algorithm().String() + "/" + blobs[0].Encoded())
+	if err != nil {
+		t.Errorf("failed to remove blob: %v", err)
+		return
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyState(stateFile), ImageWithForceRecursive())
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	_, err = rwfs.Stat(fsMem, "teststate/blobs/"+blobs[0].Algorithm().String()+"/"+blobs[0].Encoded())
+	if err == nil {
+		t.Errorf("blob in state file was copied")
+	}
+	// without the state, the blob is restored
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithForceRecursive())
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	_, err = rwfs.Stat(fsMem, "teststate/blobs/"+blobs[0].Algorithm().String()+"/"+blobs[0].Encoded())
+	if err != nil {
+		t.Errorf("blob was not restored: %v", err)
+	}
+}
+
+func TestCopyStateSave(t *testing.T) {
+	rc := New(WithFS(rwfs.MemNew()))
+	stateFile := "copy-state.json"
+	intervalOrig := imageCopyStateInterval
+	imageCopyStateInterval = time.Hour
+	defer func() { imageCopyStateInterval = intervalOrig }()
+	r, err := ref.New("ocidir://teststate:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	state, err := rc.imageCopyStateLoad(stateFile)
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	dList := []digest.Digest{digest.FromString("a"), digest.FromString("b"), digest.FromString("c")}
+	for _, d := range dList {
+		err = rc.imageCopyStateAdd(stateFile, state, r, d)
+		if err != nil {
+			t.Fatalf("failed to add to state: %v", err)
+		}
+	}
+	// only the first blob is saved before the interval
+	saved, err := rc.imageCopyStateLoad(stateFile)
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	if len(saved.Blobs[imageCopyStateKey(r)]) != 1 {
+		t.Errorf("unexpected blobs saved before the interval: %v", saved.Blobs)
+	}
+	err = rc.imageCopyStateSave(stateFile, state)
+	if err != nil {
+		t.Fatalf("failed to save state: %v", err)
+	}
+	saved, err = rc.imageCopyStateLoad(stateFile)
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	if len(saved.Blobs[imageCopyStateKey(r)]) != len(dList) {
+		t.Errorf("unexpected blobs after the save: %v", saved.Blobs)
+	}
+}
+
+func TestCopyUnchanged(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://testunchanged:v1")
+	if err != nil {
+		t.Errorf("failed to parse tgt ref: %v", err)
+		return
+	}
+	mSrc, err := rc.ManifestGet(ctx, rSrc)
+	if err != nil {
+		t.Errorf("failed to get src manifest: %v", err)
+		return
+	}
+	result := ImageCopyResult{}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyResult(&result))
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	if result.Unchanged || result.Digest != mSrc.GetDescriptor().Digest {
+		t.Errorf("unexpected result on first copy: %v", result)
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyResult(&result))
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	if !result.Unchanged || result.Digest != mSrc.GetDescriptor().Digest {
+		t.Errorf("unexpected result on second copy: %v", result)
+	}
+	// remove a child manifest from the target, the copy should restore it
+	mi, ok := mSrc.(manifest.Indexer)
+	if !ok {
+		t.Errorf("source is not an index")
+		return
+	}
+	dl, err := mi.GetManifestList()
+	if err != nil || len(dl) == 0 {
+		t.Errorf("failed to get manifest list: %v", err)
+		return
+	}
+	childFile := "testunchanged/blobs/" + dl[0].Digest.Algorithm().String() + "/" + dl[0].Digest.Encoded()
+	err = fsMem.Remove(childFile)
+	if err != nil {
+		t.Errorf("failed to remove child manifest: %v", err)
+		return
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyResult(&result))
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	if result.Unchanged {
+		t.Errorf("copy with a missing child reported unchanged")
+	}
+	_, err = rwfs.Stat(fsMem, childFile)
+	if err != nil {
+		t.Errorf("child manifest was not restored: %v", err)
+	}
+}
+
+func TestCopyResult(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://testresult:v1")
+	if err != nil {
+		t.Errorf("failed to parse tgt ref: %v", err)
+		return
+	}
+	plan := ImageCopyPlan{}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyPlan(&plan))
+	if err != nil {
+		t.Errorf("failed to plan copy: %v", err)
+		return
+	}
+	planBlobBytes := int64(0)
+	for _, d := range plan.Blobs {
+		planBlobBytes += d.Size
+	}
+	result := ImageCopyResult{}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyResult(&result))
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	if result.ManifestsCopied != len(plan.Manifests) || result.BlobsCopied != len(plan.Blobs) || result.BytesCopied != planBlobBytes {
+		t.Errorf("result does not match the plan, plan %d manifests, %d blobs, %d bytes, result %v", len(plan.Manifests), len(plan.Blobs), planBlobBytes, result)
+	}
+	if result.BlobsSkipped != 0 || result.Duration <= 0 || result.Rate() <= 0 {
+		t.Errorf("unexpected result on first copy: %v", result)
+	}
+	out, err := result.MarshalPretty()
+	if err != nil || len(out) == 0 {
+		t.Errorf("failed to marshal result: %v", err)
+	}
+	// a second tag in the same target only pushes the index, skipping the existing children
+	rTgt.Tag = "v1-copy"
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyResult(&result))
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	if result.BlobsCopied != 0 || result.BytesCopied != 0 || result.ManifestsCopied != 1 || result.ManifestsSkipped == 0 {
+		t.Errorf("unexpected result on second copy: %v", result)
+	}
+}
+
+func TestCopyLayerOrder(t *testing.T) {
+	layers := []types.Descriptor{
+		{Digest: digest.FromString("large"), Size: 3000},
+		{Digest: digest.FromString("small"), Size: 10},
+		{Digest: digest.FromString("medium-a"), Size: 500},
+		{Digest: digest.FromString("medium-b"), Size: 500},
+	}
+	expect := []string{"small", "medium-a", "medium-b", "large"}
+	sorted := imageCopyLayerOrder(layers)
+	if len(sorted) != len(expect) {
+		t.Fatalf("unexpected length: %d", len(sorted))
+	}
+	for i, name := range expect {
+		if sorted[i].Digest != digest.FromString(name) {
+			t.Errorf("unexpected layer %d, expected %s, received %v", i, name, sorted[i])
+		}
+	}
+	if layers[0].Digest != digest.FromString("large") {
+		t.Errorf("source list was modified")
+	}
+}
+
+// orderScheme records the digest of each pushed manifest
+type orderScheme struct {
+	scheme.API
+	mu   sync.Mutex
+	puts []digest.Digest
+}
+
+func (s *orderScheme) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest, opts ...scheme.ManifestOpts) error {
+	err := s.API.ManifestPut(ctx, r, m, opts...)
+	if err == nil {
+		s.mu.Lock()
+		s.puts = append(s.puts, m.GetDescriptor().Digest)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func TestCopyPushOrder(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	orderS := &orderScheme{API: ocidir.New(ocidir.WithFS(fsMem))}
+	rc := New(WithFS(fsMem), WithScheme("order", orderS))
+	rSrc, err := rc.RefNew("ocidir://testrepo:v2")
+	if err != nil {
+		t.Fatalf("failed to parse src ref: %v", err)
+	}
+	rTgt, err := rc.RefNew("order://testorder:v2")
+	if err != nil {
+		t.Fatalf("failed to parse tgt ref: %v", err)
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithReferrers(), ImageWithDigestTags())
+	if err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	pushed := map[digest.Digest]bool{}
+	subjects := 0
+	for _, dig := range orderS.puts {
+		rDig := rTgt
+		rDig.Tag = ""
+		rDig.Digest = dig.String()
+		m, err := rc.ManifestGet(ctx, rDig)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", dig, err)
+		}
+		if ms, ok := m.(manifest.Subjecter); ok {
+			subject, err := ms.GetSubject()
+			if err == nil && subject != nil {
+				subjects++
+				if !pushed[subject.Digest] {
+					t.Errorf("referrer %s pushed before the subject %s", dig, subject.Digest)
+				}
+			}
+		}
+		pushed[dig] = true
+	}
+	if subjects == 0 {
+		t.Errorf("no referrers were copied")
+	}
+}
+
+type blobCountScheme struct {
+	scheme.API
+	mu     sync.Mutex
+	active int
+	max    int
+}
+
+func (s *blobCountScheme) Throttle(r ref.Ref, put bool) []*throttle.Throttle {
+	return s.API.(scheme.Throttler).Throttle(r, put)
+}
+
+func (s *blobCountScheme) BlobPut(ctx context.Context, r ref.Ref, d types.Descriptor, rdr io.Reader) (types.Descriptor, error) {
+	s.mu.Lock()
+	s.active++
+	if s.active > s.max {
+		s.max = s.active
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+	time.Sleep(time.Millisecond * 5)
+	return s.API.BlobPut(ctx, r, d, rdr)
+}
+
+func TestCopyBlobConcurrent(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	countS := &blobCountScheme{API: ocidir.New(ocidir.WithFS(fsMem))}
+	rc := New(WithFS(fsMem), WithScheme("count", countS), WithBlobConcurrent(1))
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i, tag := range []string{"v1", "v2", "v3"} {
+		wg.Add(1)
+		go func(i int, tag string) {
+			defer wg.Done()
+			rSrc, err := rc.RefNew("ocidir://testrepo:" + tag)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			rTgt, err := rc.RefNew("count://testcount" + tag + ":" + tag)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = rc.ImageCopy(ctx, rSrc, rTgt)
+		}(i, tag)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("failed to copy %d: %v", i, err)
+		}
+	}
+	if countS.max != 1 {
+		t.Errorf("unexpected concurrent blob puts, expected 1, received %d", countS.max)
+	}
+}
+
+func TestCopyPlan(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://testplan:v1")
+	if err != nil {
+		t.Errorf("failed to parse tgt ref: %v", err)
+		return
+	}
+	mSrc, err := rc.ManifestGet(ctx, rSrc)
+	if err != nil {
+		t.Errorf("failed to get src manifest: %v", err)
+		return
+	}
+	dl, err := mSrc.(manifest.Indexer).GetManifestList()
+	if err != nil {
+		t.Errorf("failed to get manifest list: %v", err)
+		return
+	}
+	plan := ImageCopyPlan{}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyPlan(&plan))
+	if err != nil {
+		t.Errorf("failed to plan copy: %v", err)
+		return
+	}
+	// the index may list the same manifest more than once
+	unique := map[digest.Digest]bool{}
+	for _, d := range dl {
+		unique[d.Digest] = true
+	}
+	if len(plan.Manifests) != len(unique)+1 || len(plan.Blobs) == 0 {
+		t.Errorf("unexpected plan, manifests %d, blobs %d", len(plan.Manifests), len(plan.Blobs))
+	}
+	total := int64(0)
+	for _, d := range append(plan.Manifests, plan.Blobs...) {
+		total += d.Size
+	}
+	if plan.Bytes != total || total == 0 {
+		t.Errorf("unexpected plan size, expected %d, received %d", total, plan.Bytes)
+	}
+	_, err = rc.ManifestHead(ctx, rTgt)
+	if err == nil {
+		t.Errorf("plan copied the manifest")
+	}
+	// after a copy, the plan is empty
+	err = rc.ImageCopy(ctx, rSrc, rTgt)
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyPlan(&plan), ImageWithForceRecursive())
+	if err != nil {
+		t.Errorf("failed to plan copy: %v", err)
+		return
+	}
+	if len(plan.Manifests) != 0 || len(plan.Blobs) != 0 || plan.Bytes != 0 {
+		t.Errorf("unexpected plan after copy: %v", plan.Blobs)
+	}
+	// a missing blob is included in the plan
+	rChild := rSrc
+	rChild.Tag = ""
+	rChild.Digest = dl[0].Digest.String()
+	mChild, err := rc.ManifestGet(ctx, rChild)
+	if err != nil {
+		t.Errorf("failed to get child manifest: %v", err)
+		return
+	}
+	layers, err := mChild.(manifest.Imager).GetLayers()
+	if err != nil || len(layers) == 0 {
+		t.Errorf("failed to get layers: %v", err)
+		return
+	}
+	err = fsMem.Remove("testplan/blobs/" + layers[0].Digest.Algorithm().String() + "/" + layers[0].Digest.Encoded())
+	if err != nil {
+		t.Errorf("failed to remove layer: %v", err)
+		return
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyPlan(&plan), ImageWithForceRecursive())
+	if err != nil {
+		t.Errorf("failed to plan copy: %v", err)
+		return
+	}
+	if len(plan.Manifests) != 0 || len(plan.Blobs) != 1 || plan.Blobs[0].Digest != layers[0].Digest || plan.Bytes != layers[0].Size {
+		t.Errorf("unexpected plan with a missing layer: %v", plan.Blobs)
+	}
+}
+
+func TestCopyBatch(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	tags := []string{"v1", "v2", "v3"}
+	batch := ImageCopyBatch{}
+	planBlobs := 0
+	for _, tag := range tags {
+		rSrc, err := ref.New("ocidir://testrepo:" + tag)
+		if err != nil {
+			t.Fatalf("failed to parse src ref: %v", err)
+		}
+		rTgt, err := ref.New("ocidir://testbatch:" + tag)
+		if err != nil {
+			t.Fatalf("failed to parse tgt ref: %v", err)
+		}
+		plan := ImageCopyPlan{}
+		err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyPlan(&plan), ImageWithCopyBatch(&batch))
+		if err != nil {
+			t.Fatalf("failed to plan copy of %s: %v", tag, err)
+		}
+		planBlobs += len(plan.Blobs)
+	}
+	// the tags share layers, the batch only includes each blob once
+	unique := map[digest.Digest]bool{}
+	total := int64(0)
+	for _, d := range batch.Blobs {
+		if unique[d.Digest] {
+			t.Errorf("duplicate blob in batch: %s", d.Digest)
+		}
+		unique[d.Digest] = true
+		total += d.Size
+	}
+	if len(batch.Blobs) == 0 || len(batch.Blobs) > planBlobs || batch.Bytes != total {
+		t.Errorf("unexpected batch, %d blobs, %d bytes, %d planned blobs", len(batch.Blobs), batch.Bytes, planBlobs)
+	}
+	// copy the tags concurrently, each blob is copied by one of the images
+	results := make([]ImageCopyResult, len(tags))
+	errs := make([]error, len(tags))
+	var wg sync.WaitGroup
+	for i, tag := range tags {
+		i, tag := i, tag
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rSrc, _ := ref.New("ocidir://testrepo:" + tag)
+			rTgt, _ := ref.New("ocidir://testbatch:" + tag)
+			errs[i] = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithCopyBatch(&batch), ImageWithCopyResult(&results[i]))
+		}()
+	}
+	wg.Wait()
+	copied := 0
+	for i, tag := range tags {
+		if errs[i] != nil {
+			t.Fatalf("failed to copy %s: %v", tag, errs[i])
+		}
+		copied += results[i].BlobsCopied
+		rTgt, _ := ref.New("ocidir://testbatch:" + tag)
+		if _, err := rc.ManifestHead(ctx, rTgt); err != nil {
+			t.Errorf("failed to head %s: %v", tag, err)
+		}
+	}
+	if copied != len(batch.Blobs) {
+		t.Errorf("blobs copied mismatch, expected %d, received %d", len(batch.Blobs), copied)
+	}
+}
+
+func TestCopyForceRecursive(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v2")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://testrepair:v2")
+	if err != nil {
+		t.Errorf("failed to parse tgt ref: %v", err)
+		return
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt)
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	// remove a layer from the target, simulating a registry GC
+	mIndex, err := rc.ManifestGet(ctx, rTgt)
+	if err != nil {
+		t.Errorf("failed to get index: %v", err)
+		return
+	}
+	dPlat, err := manifest.GetPlatformDesc(mIndex, &platform.Platform{OS: "linux", Architecture: "amd64"})
+	if err != nil {
+		t.Errorf("failed to get platform descriptor: %v", err)
+		return
+	}
+	mPlat, err := rc.ManifestGet(ctx, rTgt, WithManifestDesc(*dPlat))
+	if err != nil {
+		t.Errorf("failed to get platform manifest: %v", err)
+		return
+	}
+	mi, ok := mPlat.(manifest.Imager)
+	if !ok {
+		t.Errorf("platform manifest is not an image")
+		return
+	}
+	layers, err := mi.GetLayers()
+	if err != nil || len(layers) == 0 {
+		t.Errorf("failed to get layers: %v", err)
+		return
+	}
+	layerFile := "testrepair/blobs/" + layers[0].Digest.Algorithm().String() + "/" + layers[0].Digest.Encoded()
+	err = fsMem.Remove(layerFile)
+	if err != nil {
+		t.Errorf("failed to remove layer: %v", err)
+		return
+	}
+	// a normal copy does not check the layers
+	err = rc.ImageCopy(ctx, rSrc, rTgt)
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	_, err = rwfs.Stat(fsMem, layerFile)
+	if err == nil {
+		t.Errorf("layer restored without force recursive")
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithForceRecursive())
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	_, err = rwfs.Stat(fsMem, layerFile)
+	if err != nil {
+		t.Errorf("layer was not restored: %v", err)
+	}
+}
+
+func TestCopyDigestTags(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v2")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://testdigesttags:v2")
+	if err != nil {
+		t.Errorf("failed to parse tgt ref: %v", err)
+		return
+	}
+	mSrc, err := rc.ManifestHead(ctx, rSrc, WithManifestRequireDigest())
+	if err != nil {
+		t.Errorf("failed to head src: %v", err)
+		return
+	}
+	dig := mSrc.GetDescriptor().Digest
+	digTag := fmt.Sprintf("%s-%s", dig.Algorithm().String(), dig.Encoded())
+	result := ImageCopyResult{}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithDigestTags(), ImageWithCopyResult(&result))
+	if err != nil {
+		t.Errorf("failed to copy: %v", err)
+		return
+	}
+	if result.Digest != dig {
+		t.Errorf("unexpected result digest, expected %s, received %s", dig, result.Digest)
+	}
+	tl, err := rc.TagList(ctx, rTgt)
+	if err != nil {
+		t.Errorf("failed to list tags: %v", err)
+		return
+	}
+	tags, err := tl.GetTags()
+	if err != nil {
+		t.Errorf("failed to get tags: %v", err)
+		return
+	}
+	found := false
+	for _, tag := range tags {
+		if tag == digTag {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("digest tag %s not copied, tags: %v", digTag, tags)
+	}
+}
+
+func TestCopyNotation(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v2")
+	if err != nil {
+		t.Fatalf("failed to parse src ref: %v", err)
+	}
+	rTgt, err := ref.New("ocidir://testnotation:v2")
+	if err != nil {
+		t.Fatalf("failed to parse tgt ref: %v", err)
+	}
+	mSrc, err := rc.ManifestHead(ctx, rSrc, WithManifestRequireDigest())
+	if err != nil {
+		t.Fatalf("failed to head src: %v", err)
+	}
+	dSubject := mSrc.GetDescriptor()
+	// push a notation style signature, the formatting of the raw manifest must be preserved by the copy
+	sig := []byte(`{"payload":"e30","protected":"e30","signature":"c2ln"}`)
+	dSig, err := rc.BlobPut(ctx, rSrc, types.Descriptor{}, bytes.NewReader(sig))
+	if err != nil {
+		t.Fatalf("failed to push signature blob: %v", err)
+	}
+	dEmpty, err := rc.BlobPut(ctx, rSrc, types.Descriptor{}, bytes.NewReader([]byte("{}")))
+	if err != nil {
+		t.Fatalf("failed to push empty blob: %v", err)
+	}
+	raw := []byte(fmt.Sprintf(`{
+  "schemaVersion": 2,
+  "mediaType": "%s",
+  "artifactType": "%s",
+  "config": {"mediaType": "%s", "digest": "%s", "size": %d},
+  "layers": [{"mediaType": "%s", "digest": "%s", "size": %d}],
+  "subject": {"mediaType": "%s", "digest": "%s", "size": %d},
+  "annotations": {"%s": "[\"0123\"]", "io.cncf.notary.signingTime": "2023-01-01T00:00:00Z"}
+}`, types.MediaTypeOCI1Manifest, types.MediaTypeNotarySignature,
+		types.MediaTypeOCI1Empty, dEmpty.Digest, dEmpty.Size,
+		types.MediaTypeJWS, dSig.Digest, dSig.Size,
+		dSubject.MediaType, dSubject.Digest, dSubject.Size,
+		types.AnnotationNotaryThumbprint))
+	mSig, err := manifest.New(manifest.WithRaw(raw), manifest.WithDesc(types.Descriptor{MediaType: types.MediaTypeOCI1Manifest}))
+	if err != nil {
+		t.Fatalf("failed to parse signature manifest: %v", err)
+	}
+	rSig := rSrc
+	rSig.Tag = ""
+	rSig.Digest = mSig.GetDescriptor().Digest.String()
+	err = rc.ManifestPut(ctx, rSig, mSig)
+	if err != nil {
+		t.Fatalf("failed to push signature manifest: %v", err)
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithReferrers())
+	if err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	rl, err := rc.ReferrerList(ctx, rTgt, scheme.WithReferrerAT(types.MediaTypeNotarySignature))
+	if err != nil {
+		t.Fatalf("failed to list referrers: %v", err)
+	}
+	sigs := rl.Signatures()
+	if len(sigs) != 1 || sigs[0].Digest != mSig.GetDescriptor().Digest {
+		t.Fatalf("unexpected signatures, expected %s, received %v", mSig.GetDescriptor().Digest, sigs)
+	}
+	rSigTgt := rTgt
+	rSigTgt.Tag = ""
+	rSigTgt.Digest = sigs[0].Digest.String()
+	mSigTgt, err := rc.ManifestGet(ctx, rSigTgt)
+	if err != nil {
+		t.Fatalf("failed to get copied signature: %v", err)
+	}
+	rawTgt, err := mSigTgt.RawBody()
+	if err != nil {
+		t.Fatalf("failed to get raw body: %v", err)
+	}
+	if !bytes.Equal(raw, rawTgt) {
+		t.Errorf("signature manifest changed, expected %s, received %s", raw, rawTgt)
+	}
+}
+
+func TestCopyImmutable(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	annot := "org.example.immutable"
+	rV1, _ := ref.New("ocidir://testrepo:v1")
+	rV2, _ := ref.New("ocidir://testrepo:v2")
+	rTgt, _ := ref.New("ocidir://testimmutable")
+	for _, tag := range []string{"v1", "v2"} {
+		rSrc := rV1
+		rSrc.Tag = tag
+		rTgt.Tag = tag
+		err = rc.ImageCopy(ctx, rSrc, rTgt)
+		if err != nil {
+			t.Errorf("failed to copy %s: %v", tag, err)
+			return
+		}
+	}
+	// push a manifest with the immutable annotation
+	m, err := rc.ManifestGet(ctx, rV2)
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	ma, ok := m.(manifest.Annotator)
+	if !ok {
+		t.Errorf("manifest does not support annotations")
+		return
+	}
+	err = ma.SetAnnotation(annot, "true")
+	if err != nil {
+		t.Errorf("failed to set annotation: %v", err)
+		return
+	}
+	rTgt.Tag = "release"
+	err = rc.ManifestPut(ctx, rTgt, m)
+	if err != nil {
+		t.Errorf("failed to put manifest: %v", err)
+		return
+	}
+	reTag := regexp.MustCompile(`^v[0-9]+$`)
+
+	tests := []struct {
+		name      string
+		src       ref.Ref
+		tgtTag    string
+		opts      []ImageOpts
+		expectErr error
+	}{
+		{
+			name:      "protected tag",
+			src:       rV2,
+			tgtTag:    "v1",
+			opts:      []ImageOpts{ImageWithImmutable("", reTag)},
+			expectErr: types.ErrTagImmutable,
+		},
+		{
+			name:   "unchanged tag",
+			src:    rV1,
+			tgtTag: "v1",
+			opts:   []ImageOpts{ImageWithImmutable("", reTag)},
+		},
+		{
+			name:   "new tag",
+			src:    rV1,
+			tgtTag: "v3",
+			opts:   []ImageOpts{ImageWithImmutable("", reTag)},
+		},
+		{
+			name:      "annotation",
+			src:       rV1,
+			tgtTag:    "release",
+			opts:      []ImageOpts{ImageWithImmutable(annot)},
+			expectErr: types.ErrTagImmutable,
+		},
+		{
+			name:   "without annotation",
+			src:    rV1,
+			tgtTag: "v2",
+			opts:   []ImageOpts{ImageWithImmutable(annot)},
+		},
+		{
+			name:   "force",
+			src:    rV2,
+			tgtTag: "release",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rTgt := rTgt
+			rTgt.Tag = tt.tgtTag
+			err := rc.ImageCopy(ctx, tt.src, rTgt, tt.opts...)
+			if tt.expectErr != nil {
+				if !errors.Is(err, tt.expectErr) {
+					t.Errorf("unexpected error, expected %v, received %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("failed to copy: %v", err)
+			}
+		})
+	}
+}
+
+func TestImageGetFile(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	tt := []struct {
+		name     string
+		filename string
+		platform string
+		expect   string
+		wantErr  error
+	}{
+		{
+			name:     "top layer",
+			filename: "layer1",
+			platform: "linux/amd64",
+			expect:   "1",
+		},
+		{
+			name:     "base layer",
+			filename: "/base.txt",
+			platform: "linux/amd64",
+			expect:   "A",
+		},
+		{
+			name:     "missing",
+			filename: "missing.txt",
+			platform: "linux/amd64",
+			wantErr:  types.ErrFileNotFound,
+		},
+		{
+			name:     "missing platform",
+			filename: "layer1",
+			platform: "linux/s390x",
+			wantErr:  types.ErrNotFound,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			th, rdr, err := rc.ImageGetFile(ctx, r, tc.filename, ImageWithPlatform(tc.platform))
+			if tc.wantErr != nil {
+				if err == nil {
+					rdr.Close()
+					t.Errorf("did not fail")
+				} else if !errors.Is(err, tc.wantErr) {
+					t.Errorf("unexpected error, expected %v, received %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("failed to get file: %v", err)
+				return
+			}
+			defer rdr.Close()
+			if th == nil || th.Name == "" {
+				t.Errorf("missing tar header")
+			}
+			b, err := io.ReadAll(rdr)
+			if err != nil {
+				t.Errorf("failed to read file: %v", err)
+				return
+			}
+			if len(b) == 0 || string(b[:1]) != tc.expect {
+				t.Errorf("unexpected content, expected %s, received %s", tc.expect, string(b))
+			}
+		})
+	}
+}
+
 func TestExportImport(t *testing.T) {
 	ctx := context.Background()
 	// copy testdata images into memory
@@ -323,3 +1304,468 @@ func TestExportImport(t *testing.T) {
 		t.Errorf("failed to import: %v", err)
 	}
 }
+
+func TestExportImportMulti(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	p, err := platform.Parse("linux/amd64")
+	if err != nil {
+		t.Errorf("failed to parse platform: %v", err)
+		return
+	}
+	// resolve single platform images for docker load
+	refs := []ref.Ref{}
+	names := []string{}
+	confDigests := []string{}
+	for _, s := range []string{"ocidir://testrepo:v1", "ocidir://testrepo:v3"} {
+		r, err := ref.New(s)
+		if err != nil {
+			t.Errorf("failed to parse ref: %v", err)
+			return
+		}
+		m, err := rc.ManifestGet(ctx, r)
+		if err != nil {
+			t.Errorf("failed to get manifest: %v", err)
+			return
+		}
+		d, err := manifest.GetPlatformDesc(m, &p)
+		if err != nil {
+			t.Errorf("failed to get platform: %v", err)
+			return
+		}
+		mp, err := rc.ManifestGet(ctx, r, WithManifestDesc(*d))
+		if err != nil {
+			t.Errorf("failed to get manifest: %v", err)
+			return
+		}
+		conf, err := mp.(manifest.Imager).GetConfig()
+		if err != nil {
+			t.Errorf("failed to get config: %v", err)
+			return
+		}
+		names = append(names, r.ToReg().CommonName())
+		r.Digest = d.Digest.String()
+		refs = append(refs, r)
+		confDigests = append(confDigests, conf.Digest.String())
+	}
+	// include a second tag for the first image
+	rLatest := refs[0]
+	rLatest.Tag = "latest"
+	refs = append(refs, rLatest)
+
+	fileOut, err := fsMem.Create("multi.tar")
+	if err != nil {
+		t.Errorf("failed to create output tar: %v", err)
+		return
+	}
+	err = rc.ImageExportMulti(ctx, refs, fileOut)
+	fileOut.Close()
+	if err != nil {
+		t.Errorf("failed to export: %v", err)
+		return
+	}
+
+	// verify the docker files, and create a docker only tar without the OCI layout
+	fileR, err := fsMem.Open("multi.tar")
+	if err != nil {
+		t.Errorf("failed to open tar: %v", err)
+		return
+	}
+	fileW, err := fsMem.Create("multi-docker.tar")
+	if err != nil {
+		t.Errorf("failed to create tar: %v", err)
+		return
+	}
+	dockerManifests := []dockerTarManifest{}
+	repositories := map[string]map[string]string{}
+	tr := tar.NewReader(fileR)
+	tw := tar.NewWriter(fileW)
+	for {
+		th, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			t.Errorf("failed to read tar header: %v", err)
+			return
+		}
+		switch th.Name {
+		case ociLayoutFilename, ociIndexFilename:
+			continue
+		case dockerManifestFilename:
+			err = json.NewDecoder(tr).Decode(&dockerManifests)
+		case dockerRepositoriesFilename:
+			err = json.NewDecoder(tr).Decode(&repositories)
+		default:
+			err = tw.WriteHeader(th)
+			if err == nil && th.Size > 0 {
+				_, err = io.Copy(tw, tr)
+			}
+			if err != nil {
+				t.Errorf("failed to copy tar file %s: %v", th.Name, err)
+				return
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", th.Name, err)
+			return
+		}
+		b, err := json.Marshal(map[string]interface{}{dockerManifestFilename: dockerManifests, dockerRepositoriesFilename: repositories}[th.Name])
+		if err != nil {
+			t.Errorf("failed to marshal %s: %v", th.Name, err)
+			return
+		}
+		th.Size = int64(len(b))
+		err = tw.WriteHeader(th)
+		if err == nil {
+			_, err = tw.Write(b)
+		}
+		if err != nil {
+			t.Errorf("failed to write %s: %v", th.Name, err)
+			return
+		}
+	}
+	tw.Close()
+	fileR.Close()
+	fileW.Close()
+	if len(dockerManifests) != 2 {
+		t.Errorf("unexpected docker manifest count, expected 2, received %d", len(dockerManifests))
+	} else if len(dockerManifests[0].RepoTags) != 2 || len(dockerManifests[1].RepoTags) != 1 {
+		t.Errorf("unexpected repo tags: %v, %v", dockerManifests[0].RepoTags, dockerManifests[1].RepoTags)
+	}
+	if len(repositories) != 1 {
+		t.Errorf("unexpected repositories: %v", repositories)
+	} else {
+		for _, tags := range repositories {
+			if len(tags) != 3 || tags["v1"] == "" || tags["v1"] != tags["latest"] {
+				t.Errorf("unexpected repositories tags: %v", tags)
+			}
+		}
+	}
+
+	tt := []struct {
+		name       string
+		file       string
+		importRef  string
+		expectConf string
+		expectErr  error
+	}{
+		{
+			name:       "oci v3",
+			file:       "multi.tar",
+			importRef:  names[1],
+			expectConf: confDigests[1],
+		},
+		{
+			name:       "docker v1",
+			file:       "multi-docker.tar",
+			importRef:  names[0],
+			expectConf: confDigests[0],
+		},
+		{
+			name:       "docker v3",
+			file:       "multi-docker.tar",
+			importRef:  names[1],
+			expectConf: confDigests[1],
+		},
+		{
+			name:      "docker missing",
+			file:      "multi-docker.tar",
+			importRef: "localhost/missing:v1",
+			expectErr: types.ErrNotFound,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rOut, err := ref.New("ocidir://testmulti:" + tc.name[len(tc.name)-2:])
+			if err != nil {
+				t.Errorf("failed to parse ref: %v", err)
+				return
+			}
+			fileIn, err := fsMem.Open(tc.file)
+			if err != nil {
+				t.Errorf("failed to open tar: %v", err)
+				return
+			}
+			defer fileIn.Close()
+			rs, ok := fileIn.(io.ReadSeeker)
+			if !ok {
+				t.Fatalf("could not convert fileIn to io.ReadSeeker, type %T", fileIn)
+			}
+			err = rc.ImageImport(ctx, rOut, rs, ImageWithImportName(tc.importRef))
+			if tc.expectErr != nil {
+				if err == nil {
+					t.Errorf("import did not fail")
+				} else if !errors.Is(err, tc.expectErr) {
+					t.Errorf("unexpected error, expected %v, received %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("failed to import: %v", err)
+				return
+			}
+			m, err := rc.ManifestGet(ctx, rOut)
+			if err != nil {
+				t.Errorf("failed to get imported image: %v", err)
+				return
+			}
+			conf, err := m.(manifest.Imager).GetConfig()
+			if err != nil {
+				t.Errorf("failed to get config: %v", err)
+				return
+			}
+			if conf.Digest.String() != tc.expectConf {
+				t.Errorf("unexpected config, expected %s, received %s", tc.expectConf, conf.Digest.String())
+			}
+		})
+	}
+}
+
+func TestExportReproducible(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	for _, compress := range []bool{false, true} {
+		opts := []ImageOpts{}
+		if compress {
+			opts = append(opts, ImageWithExportCompress())
+		}
+		digests := []digest.Digest{}
+		var last []byte
+		for i := 0; i < 2; i++ {
+			buf := &bytes.Buffer{}
+			err = rc.ImageExport(ctx, r, buf, opts...)
+			if err != nil {
+				t.Errorf("failed to export: %v", err)
+				return
+			}
+			last = buf.Bytes()
+			digests = append(digests, digest.FromBytes(last))
+		}
+		if digests[0] != digests[1] {
+			t.Errorf("export is not reproducible, compress %t: %s != %s", compress, digests[0], digests[1])
+		}
+		if compress {
+			continue
+		}
+		tr := tar.NewReader(bytes.NewReader(last))
+		for {
+			th, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				t.Errorf("failed to read tar: %v", err)
+				return
+			}
+			if !th.ModTime.Equal(time.Unix(0, 0)) || th.Uid != 0 || th.Gid != 0 || th.Uname != "" || th.Gname != "" {
+				t.Errorf("unexpected header metadata for %s: %v", th.Name, th)
+			}
+			if (th.Typeflag == tar.TypeDir && th.Mode != 0755) || (th.Typeflag == tar.TypeReg && th.Mode != 0644) {
+				t.Errorf("unexpected mode for %s: %o", th.Name, th.Mode)
+			}
+		}
+	}
+}
+
+func TestExportImportParallel(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	mSrc, err := rc.ManifestHead(ctx, r, WithManifestRequireDigest())
+	if err != nil {
+		t.Errorf("failed to head source: %v", err)
+		return
+	}
+	// a parallel export must match the sequential export
+	bufSeq := &bytes.Buffer{}
+	err = rc.ImageExport(ctx, r, bufSeq, ImageWithParallel(1))
+	if err != nil {
+		t.Errorf("failed to export: %v", err)
+		return
+	}
+	for _, parallel := range []int{1, 2, 8} {
+		buf := &bytes.Buffer{}
+		err = rc.ImageExport(ctx, r, buf, ImageWithParallel(parallel))
+		if err != nil {
+			t.Errorf("failed to export with parallel %d: %v", parallel, err)
+			return
+		}
+		if !bytes.Equal(buf.Bytes(), bufSeq.Bytes()) {
+			t.Errorf("export with parallel %d does not match sequential export", parallel)
+		}
+		rOut, err := ref.New(fmt.Sprintf("ocidir://testparallel%d:v1", parallel))
+		if err != nil {
+			t.Errorf("failed to parse ref: %v", err)
+			return
+		}
+		err = rc.ImageImport(ctx, rOut, bytes.NewReader(buf.Bytes()), ImageWithParallel(parallel))
+		if err != nil {
+			t.Errorf("failed to import with parallel %d: %v", parallel, err)
+			return
+		}
+		mOut, err := rc.ManifestHead(ctx, rOut, WithManifestRequireDigest())
+		if err != nil {
+			t.Errorf("failed to head import with parallel %d: %v", parallel, err)
+			return
+		}
+		if mOut.GetDescriptor().Digest != mSrc.GetDescriptor().Digest {
+			t.Errorf("import digest mismatch with parallel %d, expected %s, received %s", parallel, mSrc.GetDescriptor().Digest, mOut.GetDescriptor().Digest)
+		}
+		// every blob of the image must be pushed before the manifests
+		bufOut := &bytes.Buffer{}
+		err = rc.ImageExport(ctx, rOut, bufOut, ImageWithParallel(1))
+		if err != nil {
+			t.Errorf("failed to export import with parallel %d: %v", parallel, err)
+		}
+	}
+}
+
+func TestExternalLayers(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	rExt, err := ref.New("ocidir://testrepo:external")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	rCopy, err := ref.New("ocidir://testext:copy")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	rImport, err := ref.New("ocidir://testext:import")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	// create an image with an external layer that does not exist in the repository
+	mList, err := rc.ManifestGet(ctx, rSrc)
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	dPlat, err := manifest.GetPlatformDesc(mList, &platform.Platform{OS: "linux", Architecture: "amd64"})
+	if err != nil {
+		t.Errorf("failed to get platform: %v", err)
+		return
+	}
+	m, err := rc.ManifestGet(ctx, rSrc, WithManifestDesc(*dPlat))
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	mi := m.(manifest.Imager)
+	layers, err := mi.GetLayers()
+	if err != nil {
+		t.Errorf("failed to get layers: %v", err)
+		return
+	}
+	dExt := types.Descriptor{
+		MediaType: types.MediaTypeOCI1ForeignLayerGzip,
+		Digest:    digest.FromString("external layer"),
+		Size:      42,
+		URLs:      []string{"https://example.com/external-layer.tar.gz"},
+	}
+	err = mi.SetLayers(append([]types.Descriptor{dExt}, layers...))
+	if err != nil {
+		t.Errorf("failed to set layers: %v", err)
+		return
+	}
+	err = rc.ManifestPut(ctx, rExt, m)
+	if err != nil {
+		t.Errorf("failed to put manifest: %v", err)
+		return
+	}
+	hasExternal := func(t *testing.T, r ref.Ref) {
+		t.Helper()
+		m, err := rc.ManifestGet(ctx, r)
+		if err != nil {
+			t.Errorf("failed to get manifest: %v", err)
+			return
+		}
+		layers, err := m.(manifest.Imager).GetLayers()
+		if err != nil || len(layers) == 0 {
+			t.Errorf("failed to get layers: %v", err)
+			return
+		}
+		if layers[0].Digest != dExt.Digest || len(layers[0].URLs) != 1 || layers[0].URLs[0] != dExt.URLs[0] {
+			t.Errorf("external layer not preserved: %v", layers[0])
+		}
+	}
+
+	t.Run("Copy", func(t *testing.T) {
+		err := rc.ImageCopy(ctx, rExt, rCopy)
+		if err != nil {
+			t.Errorf("failed to copy: %v", err)
+			return
+		}
+		hasExternal(t, rCopy)
+		report, err := rc.ImageCheck(ctx, rCopy)
+		if err != nil || !report.OK() {
+			t.Errorf("check failed: %v, %v", report, err)
+		}
+	})
+	t.Run("Export Import", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := rc.ImageExport(ctx, rExt, buf)
+		if err != nil {
+			t.Errorf("failed to export: %v", err)
+			return
+		}
+		err = rc.ImageImport(ctx, rImport, bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Errorf("failed to import: %v", err)
+			return
+		}
+		hasExternal(t, rImport)
+	})
+	t.Run("Export Include External", func(t *testing.T) {
+		err := rc.ImageExport(ctx, rExt, io.Discard, ImageWithIncludeExternal())
+		if err == nil {
+			t.Errorf("export of unavailable external layer did not fail")
+		}
+	})
+}
@@ -0,0 +1,316 @@
+package regclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/docker/schema2"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+type indexOpt struct {
+	mediaType       string
+	annotations     map[string]string
+	artifactType    string
+	subject         *types.Descriptor
+	descAnnotations map[string]string
+	descPlatform    *platform.Platform
+}
+
+// IndexOpts define options for IndexCreate and IndexAdd
+type IndexOpts func(*indexOpt)
+
+// IndexWithAnnotations sets the annotations on a created index.
+func IndexWithAnnotations(annotations map[string]string) IndexOpts {
+	return func(opts *indexOpt) {
+		opts.annotations = annotations
+	}
+}
+
+// IndexWithArtifactType sets the artifactType on a created OCI Index.
+func IndexWithArtifactType(artifactType string) IndexOpts {
+	return func(opts *indexOpt) {
+		opts.artifactType = artifactType
+	}
+}
+
+// IndexWithDescAnnotations sets the annotations on each added descriptor.
+func IndexWithDescAnnotations(annotations map[string]string) IndexOpts {
+	return func(opts *indexOpt) {
+		opts.descAnnotations = annotations
+	}
+}
+
+// IndexWithDescPlatform sets the platform on each added descriptor instead of reading it from the image config.
+func IndexWithDescPlatform(p platform.Platform) IndexOpts {
+	return func(opts *indexOpt) {
+		opts.descPlatform = &p
+	}
+}
+
+// IndexWithMediaType sets the media type of a created index.
+// This defaults to an OCI Index, a Docker Manifest List is also supported.
+func IndexWithMediaType(mediaType string) IndexOpts {
+	return func(opts *indexOpt) {
+		opts.mediaType = mediaType
+	}
+}
+
+// IndexWithSubject sets the subject on a created OCI Index.
+func IndexWithSubject(subject types.Descriptor) IndexOpts {
+	return func(opts *indexOpt) {
+		opts.subject = &subject
+	}
+}
+
+// IndexCreate pushes a new index to r containing each of the child manifests.
+// Children must already exist in the same repository as r, and duplicate descriptors are removed.
+// The platform of each descriptor is set from the child's image config when it can be read.
+// When r does not include a tag, the returned manifest is pushed by digest.
+func (rc *RegClient) IndexCreate(ctx context.Context, r ref.Ref, children []ref.Ref, opts ...IndexOpts) (manifest.Manifest, error) {
+	opt := indexOpt{
+		mediaType: types.MediaTypeOCI1ManifestList,
+	}
+	for _, fn := range opts {
+		fn(&opt)
+	}
+	dl, err := rc.indexDescList(ctx, r, children, opt)
+	if err != nil {
+		return nil, err
+	}
+	dl = indexDescListRmDup(dl)
+	var orig interface{}
+	switch opt.mediaType {
+	case types.MediaTypeOCI1ManifestList:
+		mi := v1.Index{
+			Versioned:    v1.IndexSchemaVersion,
+			MediaType:    types.MediaTypeOCI1ManifestList,
+			ArtifactType: opt.artifactType,
+			Manifests:    dl,
+			Subject:      opt.subject,
+		}
+		if len(opt.annotations) > 0 {
+			mi.Annotations = opt.annotations
+		}
+		orig = mi
+	case types.MediaTypeDocker2ManifestList:
+		ml := schema2.ManifestList{
+			Versioned: schema2.ManifestListSchemaVersion,
+			Manifests: dl,
+		}
+		if len(opt.annotations) > 0 {
+			ml.Annotations = opt.annotations
+		}
+		orig = ml
+	default:
+		return nil, fmt.Errorf("unsupported manifest media type: %s%.0w", opt.mediaType, types.ErrUnsupportedMediaType)
+	}
+	m, err := manifest.New(manifest.WithOrig(orig))
+	if err != nil {
+		return nil, err
+	}
+	if r.Tag == "" {
+		r.Digest = m.GetDescriptor().Digest.String()
+	}
+	err = rc.ManifestPut(ctx, r, m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// IndexAdd appends each of the child manifests to the existing index at r.
+// Children must already exist in the same repository as r, and duplicate descriptors are removed.
+// The platform of each descriptor is set from the child's image config when it can be read.
+// When r is a digest, the updated index is pushed by its new digest.
+func (rc *RegClient) IndexAdd(ctx context.Context, r ref.Ref, children []ref.Ref, opts ...IndexOpts) (manifest.Manifest, error) {
+	opt := indexOpt{}
+	for _, fn := range opts {
+		fn(&opt)
+	}
+	m, mi, dl, err := rc.indexGet(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	dlAdd, err := rc.indexDescList(ctx, r, children, opt)
+	if err != nil {
+		return nil, err
+	}
+	dl = indexDescListRmDup(append(dl, dlAdd...))
+	err = mi.SetManifestList(dl)
+	if err != nil {
+		return nil, err
+	}
+	err = rc.indexPut(ctx, r, m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// IndexRemove deletes entries from the existing index at r.
+// An entry is removed when it matches any of the digests or platforms.
+// When r is a digest, the updated index is pushed by its new digest.
+func (rc *RegClient) IndexRemove(ctx context.Context, r ref.Ref, digests []digest.Digest, platforms []platform.Platform) (manifest.Manifest, error) {
+	m, mi, dl, err := rc.indexGet(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	keep := []types.Descriptor{}
+	for _, d := range dl {
+		if indexDescMatch(d, digests, platforms) {
+			continue
+		}
+		keep = append(keep, d)
+	}
+	err = mi.SetManifestList(keep)
+	if err != nil {
+		return nil, err
+	}
+	err = rc.indexPut(ctx, r, m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// indexGet pulls an existing index and its list of descriptors
+func (rc *RegClient) indexGet(ctx context.Context, r ref.Ref) (manifest.Manifest, manifest.Indexer, []types.Descriptor, error) {
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	mi, ok := m.(manifest.Indexer)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("current manifest is not an index/manifest list, \"%s\"%.0w", m.GetDescriptor().MediaType, types.ErrUnsupportedMediaType)
+	}
+	dl, err := mi.GetManifestList()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return m, mi, dl, nil
+}
+
+// indexPut pushes a modified index, replacing the digest when the index was referenced by digest
+func (rc *RegClient) indexPut(ctx context.Context, r ref.Ref, m manifest.Manifest) error {
+	if r.Tag == "" && r.Digest != "" {
+		r.Digest = m.GetDescriptor().Digest.String()
+	}
+	return rc.ManifestPut(ctx, r, m)
+}
+
+// indexDescList builds a descriptor for each child, setting the platform from the image config.
+// A child with a platform that cannot be read is added without a platform.
+func (rc *RegClient) indexDescList(ctx context.Context, r ref.Ref, children []ref.Ref, opt indexOpt) ([]types.Descriptor, error) {
+	dl := []types.Descriptor{}
+	for _, rChild := range children {
+		if !ref.EqualRepository(r, rChild) {
+			return nil, fmt.Errorf("child %s must be in the same repository as %s%.0w", rChild.CommonName(), r.CommonName(), types.ErrInvalidReference)
+		}
+		mChild, err := rc.ManifestHead(ctx, rChild, WithManifestRequireDigest())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get child %s: %w", rChild.CommonName(), err)
+		}
+		d := mChild.GetDescriptor()
+		d.Annotations = nil
+		d.Platform = nil
+		if opt.descPlatform != nil {
+			p := *opt.descPlatform
+			d.Platform = &p
+		} else {
+			rDig := rChild
+			rDig.Digest = d.Digest.String()
+			p, err := rc.indexChildPlatform(ctx, rDig, mChild)
+			if err != nil {
+				rc.log.WithFields(logrus.Fields{
+					"child": rChild.CommonName(),
+					"err":   err,
+				}).Warn("Failed to get platform, adding child without a platform")
+			}
+			d.Platform = p
+		}
+		if len(opt.descAnnotations) > 0 {
+			d.Annotations = map[string]string{}
+			for k, v := range opt.descAnnotations {
+				d.Annotations[k] = v
+			}
+		}
+		dl = append(dl, d)
+	}
+	return dl, nil
+}
+
+// indexChildPlatform returns the platform from an image config, or nil for other manifests
+func (rc *RegClient) indexChildPlatform(ctx context.Context, r ref.Ref, m manifest.Manifest) (*platform.Platform, error) {
+	if _, ok := m.(manifest.Imager); !ok {
+		return nil, nil
+	}
+	if !m.IsSet() {
+		var err error
+		m, err = rc.ManifestGet(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return nil, nil
+	}
+	cd, err := mi.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cd.MediaType != types.MediaTypeOCI1ImageConfig && cd.MediaType != types.MediaTypeDocker2ImageConfig {
+		// artifacts do not have a platform
+		return nil, nil
+	}
+	blobConfig, err := rc.BlobGetOCIConfig(ctx, r, cd)
+	if err != nil {
+		return nil, err
+	}
+	conf := blobConfig.GetConfig()
+	if conf.OS == "" {
+		return nil, nil
+	}
+	p := conf.Platform
+	return &p, nil
+}
+
+func indexDescMatch(d types.Descriptor, digests []digest.Digest, platforms []platform.Platform) bool {
+	for _, dig := range digests {
+		if d.Digest == dig {
+			return true
+		}
+	}
+	if d.Platform != nil {
+		for _, p := range platforms {
+			if platform.Match(p, *d.Platform) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func indexDescListRmDup(dl []types.Descriptor) []types.Descriptor {
+	result := []types.Descriptor{}
+	for _, d := range dl {
+		dup := false
+		for _, cur := range result {
+			if cur.Equal(d) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			result = append(result, d)
+		}
+	}
+	return result
+}
@@ -0,0 +1,202 @@
+package regclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://testrepo:combined")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	rOther, err := ref.New("ocidir://testother:v1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	// get the platform specific children of v1
+	mSrc, err := rc.ManifestGet(ctx, rSrc)
+	if err != nil {
+		t.Errorf("failed to get v1: %v", err)
+		return
+	}
+	dlSrc, err := mSrc.(manifest.Indexer).GetManifestList()
+	if err != nil {
+		t.Errorf("failed to get manifest list: %v", err)
+		return
+	}
+	children := []ref.Ref{}
+	platforms := []platform.Platform{}
+	for _, d := range dlSrc {
+		if d.Platform == nil || d.Platform.OS == "unknown" {
+			continue
+		}
+		rChild := rSrc
+		rChild.Tag = ""
+		rChild.Digest = d.Digest.String()
+		children = append(children, rChild)
+		platforms = append(platforms, *d.Platform)
+	}
+	if len(children) < 2 {
+		t.Fatalf("not enough platforms in v1: %v", dlSrc)
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		m, err := rc.IndexCreate(ctx, rTgt, children[:1], IndexWithAnnotations(map[string]string{"test": "create"}))
+		if err != nil {
+			t.Errorf("failed to create index: %v", err)
+			return
+		}
+		if m.GetDescriptor().MediaType != types.MediaTypeOCI1ManifestList {
+			t.Errorf("unexpected media type: %s", m.GetDescriptor().MediaType)
+		}
+		mGet, err := rc.ManifestGet(ctx, rTgt)
+		if err != nil {
+			t.Errorf("failed to get created index: %v", err)
+			return
+		}
+		dl, _ := mGet.(manifest.Indexer).GetManifestList()
+		if len(dl) != 1 || dl[0].Digest.String() != children[0].Digest {
+			t.Errorf("unexpected descriptors: %v", dl)
+			return
+		}
+		if dl[0].Platform == nil || !platform.Match(*dl[0].Platform, platforms[0]) {
+			t.Errorf("platform not set from config, expected %s, received %v", platforms[0], dl[0].Platform)
+		}
+	})
+	t.Run("Create Manifest List", func(t *testing.T) {
+		rDig := rTgt
+		rDig.Tag = ""
+		m, err := rc.IndexCreate(ctx, rDig, children, IndexWithMediaType(types.MediaTypeDocker2ManifestList))
+		if err != nil {
+			t.Errorf("failed to create manifest list: %v", err)
+			return
+		}
+		rDig.Digest = m.GetDescriptor().Digest.String()
+		mGet, err := rc.ManifestHead(ctx, rDig)
+		if err != nil {
+			t.Errorf("failed to head manifest list by digest: %v", err)
+			return
+		}
+		if mGet.GetDescriptor().MediaType != types.MediaTypeDocker2ManifestList {
+			t.Errorf("unexpected media type: %s", mGet.GetDescriptor().MediaType)
+		}
+	})
+	t.Run("Create Other Repository", func(t *testing.T) {
+		_, err := rc.IndexCreate(ctx, rOther, children)
+		if err == nil || !errors.Is(err, types.ErrInvalidReference) {
+			t.Errorf("child in another repository did not fail: %v", err)
+		}
+	})
+	t.Run("Add", func(t *testing.T) {
+		m, err := rc.IndexAdd(ctx, rTgt, children)
+		if err != nil {
+			t.Errorf("failed to add to index: %v", err)
+			return
+		}
+		dl, _ := m.(manifest.Indexer).GetManifestList()
+		if len(dl) != len(children) {
+			t.Errorf("unexpected descriptors, duplicates not removed: %v", dl)
+			return
+		}
+		for i, d := range dl {
+			if d.Platform == nil || !platform.Match(*d.Platform, platforms[i]) {
+				t.Errorf("platform not set from config, expected %s, received %v", platforms[i], d.Platform)
+			}
+		}
+		annotations, err := m.(manifest.Annotator).GetAnnotations()
+		if err != nil || annotations["test"] != "create" {
+			t.Errorf("index annotations were not preserved")
+		}
+	})
+	t.Run("Remove", func(t *testing.T) {
+		m, err := rc.IndexRemove(ctx, rTgt, []digest.Digest{digest.Digest(children[0].Digest)}, platforms[1:2])
+		if err != nil {
+			t.Errorf("failed to remove from index: %v", err)
+			return
+		}
+		dl, _ := m.(manifest.Indexer).GetManifestList()
+		if len(dl) != len(children)-2 {
+			t.Errorf("unexpected descriptors: %v", dl)
+		}
+		mGet, err := rc.ManifestHead(ctx, rTgt)
+		if err != nil {
+			t.Errorf("failed to head index: %v", err)
+			return
+		}
+		if mGet.GetDescriptor().Digest != m.GetDescriptor().Digest {
+			t.Errorf("index was not pushed")
+		}
+	})
+	t.Run("Remove Not Index", func(t *testing.T) {
+		_, err := rc.IndexRemove(ctx, children[0], nil, platforms[:1])
+		if err == nil || !errors.Is(err, types.ErrUnsupportedMediaType) {
+			t.Errorf("remove from image did not fail: %v", err)
+		}
+	})
+	t.Run("Create Duplicates", func(t *testing.T) {
+		m, err := rc.IndexCreate(ctx, rTgt, []ref.Ref{children[0], children[1], children[0]})
+		if err != nil {
+			t.Errorf("failed to create index: %v", err)
+			return
+		}
+		dl, _ := m.(manifest.Indexer).GetManifestList()
+		if len(dl) != 2 {
+			t.Errorf("unexpected descriptors, duplicates not removed: %v", dl)
+		}
+	})
+	t.Run("Create Missing Config", func(t *testing.T) {
+		mChild, err := rc.ManifestGet(ctx, children[0])
+		if err != nil {
+			t.Errorf("failed to get child: %v", err)
+			return
+		}
+		cd, err := mChild.(manifest.Imager).GetConfig()
+		if err != nil {
+			t.Errorf("failed to get config descriptor: %v", err)
+			return
+		}
+		err = rc.BlobDelete(ctx, children[0], cd)
+		if err != nil {
+			t.Errorf("failed to delete config: %v", err)
+			return
+		}
+		m, err := rc.IndexCreate(ctx, rTgt, children[:2])
+		if err != nil {
+			t.Errorf("failed to create index without a config: %v", err)
+			return
+		}
+		dl, _ := m.(manifest.Indexer).GetManifestList()
+		if len(dl) != 2 || dl[0].Platform != nil {
+			t.Errorf("unexpected descriptors: %v", dl)
+			return
+		}
+		if dl[1].Platform == nil || !platform.Match(*dl[1].Platform, platforms[1]) {
+			t.Errorf("platform not set from config, expected %s, received %v", platforms[1], dl[1].Platform)
+		}
+	})
+}
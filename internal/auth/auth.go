@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/regclient/regclient/internal/redact"
 	"github.com/sirupsen/logrus"
 )
 
@@ -659,7 +660,7 @@ func (b *BearerHandler) tryGet() error {
 
 	resp, err := b.client.Do(req)
 	if err != nil {
-		return err
+		return redact.Error(err)
 	}
 	defer resp.Body.Close()
 
@@ -698,7 +699,7 @@ func (b *BearerHandler) tryPost() error {
 
 	resp, err := b.client.Do(req)
 	if err != nil {
-		return err
+		return redact.Error(err)
 	}
 	defer resp.Body.Close()
 
@@ -822,7 +823,7 @@ func (j *JWTHubHandler) ProcessChallenge(c Challenge) error {
 
 	resp, err := j.client.Do(req)
 	if err != nil {
-		return err
+		return redact.Error(err)
 	}
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
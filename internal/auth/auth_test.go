@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -480,3 +481,51 @@ func TestBearer(t *testing.T) {
 		t.Errorf("token2 (push) expires early, expected %d, received %d", minTokenLife, bearer.token.ExpiresIn)
 	}
 }
+
+func TestRedactErrors(t *testing.T) {
+	user := "user"
+	pass := "secret-pass"
+	// requests to a closed server fail with an error that includes the url
+	ts := httptest.NewServer(http.NotFoundHandler())
+	tsURL, _ := url.Parse(ts.URL)
+	ts.Close()
+	realm := "http://" + user + ":" + pass + "@" + tsURL.Host + "/token?token=secret-query"
+	secrets := []string{pass, "secret-query"}
+	credsFn := func(h string) Cred { return Cred{User: user, Password: pass} }
+	checkErr := func(t *testing.T, err error) {
+		t.Helper()
+		if err == nil {
+			t.Fatalf("request did not fail")
+		}
+		for _, s := range secrets {
+			if strings.Contains(err.Error(), s) {
+				t.Errorf("error contains %s: %v", s, err)
+			}
+		}
+	}
+
+	t.Run("Bearer", func(t *testing.T) {
+		bearer := NewBearerHandler(&http.Client{}, "test", tsURL.Host, credsFn, &logrus.Logger{})
+		err := bearer.ProcessChallenge(Challenge{
+			authType: "bearer",
+			params: map[string]string{
+				"realm":   realm,
+				"service": "test",
+				"scope":   "repository:reponame:pull",
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to process challenge: %v", err)
+		}
+		_, err = bearer.GenerateAuth()
+		checkErr(t, err)
+		err = bearer.(*BearerHandler).tryGet()
+		checkErr(t, err)
+	})
+	t.Run("JWT", func(t *testing.T) {
+		jwt := NewJWTHandler(&http.Client{}, "test", "hub.docker.com", credsFn, &logrus.Logger{}).(*JWTHubHandler)
+		jwt.realm = realm
+		err := jwt.ProcessChallenge(Challenge{authType: "jwt"})
+		checkErr(t, err)
+	})
+}
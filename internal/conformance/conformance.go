@@ -0,0 +1,425 @@
+// Package conformance runs the client against a registry to report which distribution-spec features are supported.
+// Each check pushes, pulls, lists, or deletes content in a repository, and checks that depend on a failed check are skipped.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// StatusPass indicates the registry supports the feature
+	StatusPass Status = "pass"
+	// StatusFail indicates the feature returned an error or unexpected content
+	StatusFail Status = "fail"
+	// StatusSkip indicates the check was not run, because it was disabled or a dependency failed
+	StatusSkip Status = "skip"
+)
+
+// Result is the output of a single check
+type Result struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Err    string `json:"error,omitempty"`
+}
+
+// Report contains the results of each check
+type Report struct {
+	Repository string   `json:"repository"`
+	Results    []Result `json:"results"`
+}
+
+// Opts configures the conformance checks
+type Opts func(*runner)
+
+// WithoutDelete skips the delete checks, leaving the pushed content in the repository
+func WithoutDelete() Opts {
+	return func(run *runner) {
+		run.noDelete = true
+	}
+}
+
+// WithLayerSize sets the size of the generated layer (defaults to 1024 bytes)
+func WithLayerSize(size int64) Opts {
+	return func(run *runner) {
+		if size > 0 {
+			run.layerSize = size
+		}
+	}
+}
+
+type runner struct {
+	rc        *regclient.RegClient
+	r         ref.Ref
+	noDelete  bool
+	layerSize int64
+	report    *Report
+	status    map[string]Status
+}
+
+// Run pushes generated content to the repository of r and reports the result of each check.
+// The tag in r is ignored, content is pushed to a unique tag to avoid overwriting existing images.
+func Run(ctx context.Context, rc *regclient.RegClient, r ref.Ref, opts ...Opts) Report {
+	report := Report{
+		Repository: r.CommonName(),
+		Results:    []Result{},
+	}
+	run := runner{
+		rc:        rc,
+		layerSize: 1024,
+		report:    &report,
+		status:    map[string]Status{},
+	}
+	for _, opt := range opts {
+		opt(&run)
+	}
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	run.r = r
+	run.r.Digest = ""
+	run.r.Tag = "conformance-" + suffix
+	report.Repository = run.r.CommonName()
+	run.run(ctx, suffix)
+	return report
+}
+
+// Failed returns true if any check failed
+func (report Report) Failed() bool {
+	for _, result := range report.Results {
+		if result.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalPretty outputs a table of the checks
+func (report Report) MarshalPretty() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Repository:\t%s\n", report.Repository)
+	fmt.Fprintf(tw, "\t\n")
+	fmt.Fprintf(tw, "Check\tStatus\tDetail\n")
+	for _, result := range report.Results {
+		detail := result.Detail
+		if result.Err != "" {
+			detail = result.Err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Name, result.Status, detail)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// check runs fn when each dependency passed, and records the result
+func (run *runner) check(name string, deps []string, fn func() (string, error)) {
+	result := Result{Name: name}
+	for _, dep := range deps {
+		if run.status[dep] != StatusPass {
+			result.Status = StatusSkip
+			result.Detail = "requires " + dep
+			run.add(result)
+			return
+		}
+	}
+	detail, err := fn()
+	result.Detail = detail
+	if err != nil {
+		result.Status = StatusFail
+		result.Err = err.Error()
+	} else {
+		result.Status = StatusPass
+	}
+	run.add(result)
+}
+
+// checkDelete runs a check that deletes content, unless deletes are disabled
+func (run *runner) checkDelete(name string, deps []string, fn func() (string, error)) {
+	if run.noDelete {
+		run.add(Result{Name: name, Status: StatusSkip, Detail: "deletes disabled"})
+		return
+	}
+	run.check(name, deps, fn)
+}
+
+func (run *runner) add(result Result) {
+	run.status[result.Name] = result.Status
+	run.report.Results = append(run.report.Results, result)
+}
+
+func (run *runner) run(ctx context.Context, suffix string) {
+	rc := run.rc
+	// generate a unique image so each check pushes new content
+	layer := make([]byte, run.layerSize)
+	_, err := io.ReadFull(rand.Reader, layer)
+	if err != nil {
+		run.add(Result{Name: "generate content", Status: StatusFail, Err: err.Error()})
+		return
+	}
+	created := time.Now().UTC()
+	conf := v1.Image{
+		Created:  &created,
+		Platform: platform.Platform{OS: "linux", Architecture: "amd64"},
+		RootFS: v1.RootFS{
+			Type:    "layers",
+			DiffIDs: []digest.Digest{digest.FromBytes(layer)},
+		},
+	}
+	confB, err := json.Marshal(conf)
+	if err != nil {
+		run.add(Result{Name: "generate content", Status: StatusFail, Err: err.Error()})
+		return
+	}
+	dConf := types.Descriptor{
+		MediaType: types.MediaTypeOCI1ImageConfig,
+		Digest:    digest.FromBytes(confB),
+		Size:      int64(len(confB)),
+	}
+	dLayer := types.Descriptor{
+		MediaType: types.MediaTypeOCI1Layer,
+		Digest:    digest.FromBytes(layer),
+		Size:      int64(len(layer)),
+	}
+	mImage, err := manifest.New(manifest.WithOrig(v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: types.MediaTypeOCI1Manifest,
+		Config:    dConf,
+		Layers:    []types.Descriptor{dLayer},
+	}))
+	if err != nil {
+		run.add(Result{Name: "generate content", Status: StatusFail, Err: err.Error()})
+		return
+	}
+	rImage := run.r
+	rDigest := refDigest(run.r, mImage.GetDescriptor().Digest.String())
+	rTag2 := run.r
+	rTag2.Tag = "conformance-delete-" + suffix
+	var mUntagged, mArtifact manifest.Manifest
+
+	run.check("blob push", nil, func() (string, error) {
+		for _, bp := range []struct {
+			d    types.Descriptor
+			data []byte
+		}{{d: dConf, data: confB}, {d: dLayer, data: layer}} {
+			_, err := rc.BlobPut(ctx, run.r, bp.d, bytes.NewReader(bp.data))
+			if err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	})
+	run.check("blob head", []string{"blob push"}, func() (string, error) {
+		br, err := rc.BlobHead(ctx, run.r, dLayer)
+		if err != nil {
+			return "", err
+		}
+		_ = br.Close()
+		if size := br.GetDescriptor().Size; size > 0 && size != dLayer.Size {
+			return "", fmt.Errorf("unexpected size, expected %d, received %d", dLayer.Size, size)
+		}
+		return "", nil
+	})
+	run.check("blob get", []string{"blob push"}, func() (string, error) {
+		br, err := rc.BlobGet(ctx, run.r, dLayer)
+		if err != nil {
+			return "", err
+		}
+		defer br.Close()
+		// the blob reader verifies the digest when the content is fully read
+		b, err := io.ReadAll(br)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(b, layer) {
+			return "", fmt.Errorf("blob content does not match")
+		}
+		return "", nil
+	})
+	run.check("manifest push by tag", []string{"blob push"}, func() (string, error) {
+		return "", rc.ManifestPut(ctx, rImage, mImage)
+	})
+	run.check("manifest head", []string{"manifest push by tag"}, func() (string, error) {
+		m, err := rc.ManifestHead(ctx, rImage)
+		if err != nil {
+			return "", err
+		}
+		if m.GetDescriptor().Digest == "" {
+			return "digest header missing", nil
+		}
+		if m.GetDescriptor().Digest != mImage.GetDescriptor().Digest {
+			return "", fmt.Errorf("unexpected digest, expected %s, received %s", mImage.GetDescriptor().Digest, m.GetDescriptor().Digest)
+		}
+		return "", nil
+	})
+	run.check("manifest get by tag", []string{"manifest push by tag"}, func() (string, error) {
+		return "", run.manifestCompare(ctx, rImage, mImage)
+	})
+	run.check("manifest get by digest", []string{"manifest push by tag"}, func() (string, error) {
+		return "", run.manifestCompare(ctx, rDigest, mImage)
+	})
+	run.check("manifest push by digest", []string{"blob push"}, func() (string, error) {
+		mUntagged, err = manifest.New(manifest.WithOrig(v1.Manifest{
+			Versioned:   v1.ManifestSchemaVersion,
+			MediaType:   types.MediaTypeOCI1Manifest,
+			Config:      dConf,
+			Layers:      []types.Descriptor{dLayer},
+			Annotations: map[string]string{"org.example.conformance": "untagged"},
+		}))
+		if err != nil {
+			return "", err
+		}
+		rUntagged := refDigest(run.r, mUntagged.GetDescriptor().Digest.String())
+		err = rc.ManifestPut(ctx, rUntagged, mUntagged)
+		if err != nil {
+			return "", err
+		}
+		return "", run.manifestCompare(ctx, rUntagged, mUntagged)
+	})
+	run.check("tag list", []string{"manifest push by tag"}, func() (string, error) {
+		tl, err := rc.TagList(ctx, run.r)
+		if err != nil {
+			return "", err
+		}
+		tags, err := tl.GetTags()
+		if err != nil {
+			return "", err
+		}
+		for _, t := range tags {
+			if t == rImage.Tag {
+				return "", nil
+			}
+		}
+		return "", fmt.Errorf("tag %s not found in the tag list", rImage.Tag)
+	})
+	run.check("referrers push", []string{"manifest push by tag"}, func() (string, error) {
+		_, err := rc.BlobPut(ctx, run.r, types.EmptyDescriptor(), bytes.NewReader(types.EmptyData))
+		if err != nil {
+			return "", err
+		}
+		dImage := mImage.GetDescriptor()
+		mArtifact, err = manifest.New(manifest.WithOrig(v1.Manifest{
+			Versioned:    v1.ManifestSchemaVersion,
+			MediaType:    types.MediaTypeOCI1Manifest,
+			ArtifactType: "application/vnd.example.conformance",
+			Config:       types.EmptyDescriptor(),
+			Layers:       []types.Descriptor{types.EmptyDescriptor()},
+			Subject: &types.Descriptor{
+				MediaType: dImage.MediaType,
+				Digest:    dImage.Digest,
+				Size:      dImage.Size,
+			},
+		}))
+		if err != nil {
+			return "", err
+		}
+		rArtifact := refDigest(run.r, mArtifact.GetDescriptor().Digest.String())
+		return "", rc.ManifestPut(ctx, rArtifact, mArtifact)
+	})
+	run.check("referrers list", []string{"referrers push"}, func() (string, error) {
+		rl, err := rc.ReferrerList(ctx, rDigest)
+		if err != nil {
+			return "", err
+		}
+		detail := "referrers API"
+		if len(rl.Tags) > 0 {
+			detail = "fallback tag"
+		}
+		for _, d := range rl.Descriptors {
+			if d.Digest == mArtifact.GetDescriptor().Digest {
+				return detail, nil
+			}
+		}
+		return detail, fmt.Errorf("artifact %s not found in the referrers", mArtifact.GetDescriptor().Digest)
+	})
+	run.checkDelete("tag delete", []string{"manifest push by tag"}, func() (string, error) {
+		err := rc.ManifestPut(ctx, rTag2, mImage)
+		if err != nil {
+			return "", err
+		}
+		err = rc.TagDelete(ctx, rTag2)
+		if err != nil {
+			return "", err
+		}
+		if _, err := rc.ManifestHead(ctx, rTag2); err == nil {
+			return "", fmt.Errorf("tag %s found after delete", rTag2.Tag)
+		}
+		return "", nil
+	})
+	run.checkDelete("manifest delete", []string{"manifest push by tag"}, func() (string, error) {
+		manifests := []manifest.Manifest{}
+		if run.status["referrers push"] == StatusPass {
+			manifests = append(manifests, mArtifact)
+		}
+		if run.status["manifest push by digest"] == StatusPass {
+			manifests = append(manifests, mUntagged)
+		}
+		manifests = append(manifests, mImage)
+		for _, m := range manifests {
+			rDel := refDigest(run.r, m.GetDescriptor().Digest.String())
+			err := rc.ManifestDelete(ctx, rDel, regclient.WithManifest(m))
+			if err != nil {
+				return "", err
+			}
+		}
+		if _, err := rc.ManifestHead(ctx, rDigest); err == nil {
+			return "", fmt.Errorf("manifest %s found after delete", rDigest.Digest)
+		}
+		return "", nil
+	})
+	run.checkDelete("blob delete", []string{"blob push"}, func() (string, error) {
+		for _, d := range []types.Descriptor{dLayer, dConf} {
+			err := rc.BlobDelete(ctx, run.r, d)
+			if err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	})
+}
+
+// refDigest returns a reference to a digest in the repository of r
+func refDigest(r ref.Ref, dig string) ref.Ref {
+	r.Tag = ""
+	r.Digest = dig
+	return r
+}
+
+// manifestCompare pulls a manifest and verifies the content matches the pushed manifest
+func (run *runner) manifestCompare(ctx context.Context, r ref.Ref, mExpect manifest.Manifest) error {
+	m, err := run.rc.ManifestGet(ctx, r)
+	if err != nil {
+		return err
+	}
+	raw, err := m.RawBody()
+	if err != nil {
+		return err
+	}
+	rawExpect, err := mExpect.RawBody()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(raw, rawExpect) {
+		return fmt.Errorf("manifest content does not match for %s", r.CommonName())
+	}
+	return nil
+}
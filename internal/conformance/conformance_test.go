@@ -0,0 +1,67 @@
+package conformance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	fsMem := rwfs.MemNew()
+	rc := regclient.New(regclient.WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:latest")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+
+	t.Run("All", func(t *testing.T) {
+		report := Run(ctx, rc, r)
+		if report.Failed() {
+			t.Errorf("conformance failed: %v", report.Results)
+		}
+		for _, result := range report.Results {
+			if result.Status != StatusPass {
+				t.Errorf("check %s did not pass: %v", result.Name, result)
+			}
+		}
+		if _, err := report.MarshalPretty(); err != nil {
+			t.Errorf("failed to marshal report: %v", err)
+		}
+	})
+	t.Run("Without delete", func(t *testing.T) {
+		report := Run(ctx, rc, r, WithoutDelete())
+		if report.Failed() {
+			t.Errorf("conformance failed: %v", report.Results)
+		}
+		skipped := 0
+		for _, result := range report.Results {
+			if result.Status == StatusSkip {
+				skipped++
+			}
+		}
+		if skipped != 3 {
+			t.Errorf("unexpected number of skipped checks, expected 3, received %d", skipped)
+		}
+	})
+	t.Run("Failed dependency", func(t *testing.T) {
+		// a read-only filesystem fails every push, and the dependent checks are skipped
+		rcRO := regclient.New(regclient.WithFS(rwfs.OSNew("/proc/nonexistent")))
+		report := Run(ctx, rcRO, r)
+		if !report.Failed() {
+			t.Errorf("conformance did not fail")
+		}
+		if len(report.Results) == 0 || report.Results[0].Status != StatusFail {
+			t.Errorf("blob push did not fail: %v", report.Results)
+			return
+		}
+		for _, result := range report.Results[1:] {
+			if result.Status == StatusPass {
+				t.Errorf("check %s passed after blob push failed", result.Name)
+			}
+		}
+	})
+}
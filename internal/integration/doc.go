@@ -0,0 +1,7 @@
+// Package integration runs regclient against real registry implementations.
+//
+// The tests are excluded from the default build with the "integration" build tag.
+// Start the registries with "docker compose -f build/docker-compose.yml up -d" and run the tests with
+// "go test -tags integration ./internal/integration/", or use "make test-integration" for both steps.
+// Registries may be overridden with REGCLIENT_INTEGRATION_HOSTS, e.g. "distribution=localhost:5001,zot=localhost:5002".
+package integration
@@ -0,0 +1,195 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// defaultHosts matches the ports in build/docker-compose.yml
+const defaultHosts = "distribution=localhost:5001,zot=localhost:5002"
+
+type registry struct {
+	name string
+	host string
+}
+
+func registries(t *testing.T) []registry {
+	t.Helper()
+	hosts := os.Getenv("REGCLIENT_INTEGRATION_HOSTS")
+	if hosts == "" {
+		hosts = defaultHosts
+	}
+	regs := []registry{}
+	for _, entry := range strings.Split(hosts, ",") {
+		name, host, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || host == "" {
+			t.Fatalf("invalid entry in REGCLIENT_INTEGRATION_HOSTS: %s", entry)
+		}
+		regs = append(regs, registry{name: name, host: host})
+	}
+	return regs
+}
+
+func TestRegistries(t *testing.T) {
+	ctx := context.Background()
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(rwfs.OSNew(""), "../../testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	for _, reg := range registries(t) {
+		reg := reg
+		t.Run(reg.name, func(t *testing.T) {
+			resp, err := http.Get("http://" + reg.host + "/v2/")
+			if err != nil {
+				t.Fatalf("registry %s is not reachable on %s, start it with \"make test-integration\": %v", reg.name, reg.host, err)
+			}
+			resp.Body.Close()
+			rc := regclient.New(
+				regclient.WithFS(fsMem),
+				regclient.WithConfigHost(config.Host{
+					Name: reg.host,
+					TLS:  config.TLSDisabled,
+				}),
+			)
+			runMatrix(ctx, t, rc, reg.host)
+		})
+	}
+}
+
+func runMatrix(ctx context.Context, t *testing.T, rc *regclient.RegClient, host string) {
+	tags := []string{"v1", "v2", "v3"}
+	repo := host + "/regclient/integration"
+	t.Run("Copy", func(t *testing.T) {
+		for _, tag := range tags {
+			rSrc, _ := ref.New("ocidir://testrepo:" + tag)
+			rTgt, _ := ref.New(repo + ":" + tag)
+			err := rc.ImageCopy(ctx, rSrc, rTgt, regclient.ImageWithReferrers(), regclient.ImageWithDigestTags())
+			if err != nil {
+				t.Fatalf("failed to copy %s: %v", tag, err)
+			}
+			mSrc, err := rc.ManifestHead(ctx, rSrc, regclient.WithManifestRequireDigest())
+			if err != nil {
+				t.Fatalf("failed to head source %s: %v", tag, err)
+			}
+			mTgt, err := rc.ManifestHead(ctx, rTgt, regclient.WithManifestRequireDigest())
+			if err != nil {
+				t.Fatalf("failed to head target %s: %v", tag, err)
+			}
+			if mSrc.GetDescriptor().Digest != mTgt.GetDescriptor().Digest {
+				t.Errorf("digest mismatch for %s, expected %s, received %s", tag, mSrc.GetDescriptor().Digest, mTgt.GetDescriptor().Digest)
+			}
+		}
+	})
+	t.Run("TagList", func(t *testing.T) {
+		r, _ := ref.New(repo)
+		tl, err := rc.TagList(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		tlTags, err := tl.GetTags()
+		if err != nil {
+			t.Fatalf("failed to get tags: %v", err)
+		}
+		for _, tag := range tags {
+			found := false
+			for _, tlTag := range tlTags {
+				if tlTag == tag {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("tag %s missing from %v", tag, tlTags)
+			}
+		}
+	})
+	t.Run("Referrers", func(t *testing.T) {
+		for _, tag := range tags {
+			rSrc, _ := ref.New("ocidir://testrepo:" + tag)
+			rTgt, _ := ref.New(repo + ":" + tag)
+			rlSrc, err := rc.ReferrerList(ctx, rSrc)
+			if err != nil {
+				t.Fatalf("failed to list source referrers for %s: %v", tag, err)
+			}
+			rlTgt, err := rc.ReferrerList(ctx, rTgt)
+			if err != nil {
+				t.Fatalf("failed to list target referrers for %s: %v", tag, err)
+			}
+			dSrc := referrerDigests(rlSrc.Descriptors)
+			dTgt := referrerDigests(rlTgt.Descriptors)
+			if strings.Join(dSrc, ",") != strings.Join(dTgt, ",") {
+				t.Errorf("referrers mismatch for %s, expected %v, received %v", tag, dSrc, dTgt)
+			}
+		}
+	})
+	t.Run("TagDelete", func(t *testing.T) {
+		rSrc, _ := ref.New(repo + ":v2")
+		rTgt, _ := ref.New(repo + ":delete-tag")
+		err := rc.ImageCopy(ctx, rSrc, rTgt)
+		if err != nil {
+			t.Fatalf("failed to copy: %v", err)
+		}
+		err = rc.TagDelete(ctx, rTgt)
+		if err != nil {
+			t.Fatalf("failed to delete tag: %v", err)
+		}
+		_, err = rc.ManifestHead(ctx, rTgt)
+		if err == nil {
+			t.Errorf("tag was not deleted")
+		} else if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		// the image is still available with the original tag
+		_, err = rc.ManifestHead(ctx, rSrc)
+		if err != nil {
+			t.Errorf("failed to head v2 after deleting the tag: %v", err)
+		}
+	})
+	t.Run("ManifestDelete", func(t *testing.T) {
+		rSrc, _ := ref.New("ocidir://testrepo:a-docker")
+		rTgt, _ := ref.New(host + "/regclient/integration-delete:a-docker")
+		err := rc.ImageCopy(ctx, rSrc, rTgt)
+		if err != nil {
+			t.Fatalf("failed to copy: %v", err)
+		}
+		m, err := rc.ManifestHead(ctx, rTgt, regclient.WithManifestRequireDigest())
+		if err != nil {
+			t.Fatalf("failed to head: %v", err)
+		}
+		rDel := rTgt
+		rDel.Digest = m.GetDescriptor().Digest.String()
+		err = rc.ManifestDelete(ctx, rDel)
+		if err != nil {
+			t.Fatalf("failed to delete manifest: %v", err)
+		}
+		_, err = rc.ManifestHead(ctx, rDel)
+		if err == nil {
+			t.Errorf("manifest was not deleted")
+		} else if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func referrerDigests(dl []types.Descriptor) []string {
+	digests := make([]string, 0, len(dl))
+	for _, d := range dl {
+		digests = append(digests, d.Digest.String())
+	}
+	sort.Strings(digests)
+	return digests
+}
@@ -0,0 +1,144 @@
+// Package redact removes credentials from requests, responses, and urls.
+// Values are redacted before they are logged, included in an error, or recorded in a transcript.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces each sensitive value
+const Redacted = "REDACTED"
+
+var (
+	redactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	redactJSON    = []string{"token", "access_token", "refresh_token", "password"}
+	redactForm    = []string{"password", "refresh_token"}
+	redactQuery   = []string{"signature", "credential", "token", "password", "sig"}
+)
+
+// Header returns a copy of the headers with credentials and cookies redacted.
+// Urls in the Location header are redacted with URL.
+func Header(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	h = h.Clone()
+	for _, k := range redactHeaders {
+		if _, ok := h[k]; ok {
+			h[k] = []string{Redacted}
+		}
+	}
+	// redirects to signed urls include credentials in the query
+	if loc := h.Get("Location"); loc != "" {
+		h.Set("Location", URLString(loc))
+	}
+	return h
+}
+
+// URL returns the url as a string with the userinfo and any signature, credential, or token query parameters redacted
+func URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	uCopy := *u
+	if uCopy.User != nil {
+		uCopy.User = url.User(Redacted)
+	}
+	if uCopy.RawQuery != "" {
+		q := uCopy.Query()
+		changed := false
+		for k := range q {
+			if queryKey(k) {
+				q[k] = []string{Redacted}
+				changed = true
+			}
+		}
+		if changed {
+			uCopy.RawQuery = q.Encode()
+		}
+	}
+	return uCopy.String()
+}
+
+// URLString parses and redacts a url with URL, an unparsable url is returned as is
+func URLString(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return URL(u)
+}
+
+func queryKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, rk := range redactQuery {
+		if k == rk || (len(rk) > 3 && strings.Contains(k, rk)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Body redacts passwords and tokens from a form or json body.
+// Any body with a json object is parsed since error responses often have the wrong content type.
+// Other json, e.g. a manifest, is returned unchanged.
+func Body(ct string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	ct, _, _ = strings.Cut(ct, ";")
+	if strings.TrimSpace(ct) == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		changed := false
+		for _, k := range redactForm {
+			if _, ok := form[k]; ok {
+				form[k] = []string{Redacted}
+				changed = true
+			}
+		}
+		if changed {
+			return []byte(form.Encode())
+		}
+		return body
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return body
+	}
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return body
+	}
+	changed := false
+	for _, k := range redactJSON {
+		if _, ok := obj[k]; ok {
+			obj[k] = json.RawMessage(`"` + Redacted + `"`)
+			changed = true
+		}
+	}
+	if changed {
+		out, err := json.Marshal(obj)
+		if err == nil {
+			return out
+		}
+	}
+	return body
+}
+
+// Error redacts the url of a *url.Error returned by the http client.
+// Other errors are returned unchanged.
+func Error(err error) error {
+	var ue *url.Error
+	if err == nil || !errors.As(err, &ue) {
+		return err
+	}
+	ue.URL = URLString(ue.URL)
+	return err
+}
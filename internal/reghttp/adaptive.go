@@ -0,0 +1,154 @@
+package reghttp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveDelayInit is the delay between requests added after the first 429
+	adaptiveDelayInit = 100 * time.Millisecond
+	// adaptiveDelayMin is the delay below which the delay is removed
+	adaptiveDelayMin = 10 * time.Millisecond
+)
+
+// adaptive limits the requests to a host after the host responds with a 429.
+// Each 429 halves the concurrency limit and doubles the delay between requests (multiplicative decrease).
+// Each successful request raises the limit by a fraction of a request and shortens the delay (additive increase).
+// Once the limit returns to the concurrency seen before the first 429 and the delay is removed, requests are no longer limited.
+type adaptive struct {
+	mu       sync.Mutex
+	limited  bool
+	limit    float64       // concurrent requests allowed
+	ceiling  int           // concurrent requests before the first 429
+	delay    time.Duration // delay between the start of each request
+	delayMax time.Duration
+	active   int
+	next     time.Time // earliest start of the next request
+	wake     chan struct{}
+}
+
+func newAdaptive(delayMax time.Duration) *adaptive {
+	return &adaptive{
+		delayMax: delayMax,
+		wake:     make(chan struct{}),
+	}
+}
+
+// acquire waits for the concurrency limit and delay before a request is sent
+func (a *adaptive) acquire(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	for {
+		a.mu.Lock()
+		now := time.Now()
+		if !a.limited {
+			a.active++
+			a.mu.Unlock()
+			return nil
+		}
+		if a.active < int(a.limit) && !now.Before(a.next) {
+			a.active++
+			a.next = now.Add(a.delay)
+			a.mu.Unlock()
+			return nil
+		}
+		wake := a.wake
+		var timer *time.Timer
+		var sleep <-chan time.Time
+		if a.active < int(a.limit) {
+			timer = time.NewTimer(a.next.Sub(now))
+			sleep = timer.C
+		}
+		a.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-wake:
+		case <-sleep:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// release is called when a request completes
+func (a *adaptive) release() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active > 0 {
+		a.active--
+	}
+	a.wakeAll()
+}
+
+// throttled reduces the limits after a 429, returning the new concurrency and delay
+func (a *adaptive) throttled() (int, time.Duration) {
+	if a == nil {
+		return 0, 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.limited {
+		a.limited = true
+		a.ceiling = a.active
+		if a.ceiling < 1 {
+			a.ceiling = 1
+		}
+		a.limit = float64(a.ceiling)
+	}
+	a.limit = a.limit / 2
+	if a.limit < 1 {
+		a.limit = 1
+	}
+	if a.delay == 0 {
+		a.delay = adaptiveDelayInit
+	} else {
+		a.delay = a.delay * 2
+	}
+	if a.delayMax > 0 && a.delay > a.delayMax {
+		a.delay = a.delayMax
+	}
+	a.next = time.Now().Add(a.delay)
+	return int(a.limit), a.delay
+}
+
+// success gradually restores the limits after a successful request
+func (a *adaptive) success() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.limited {
+		return
+	}
+	// increase by one request for each window of successful requests
+	a.limit += 1 / a.limit
+	if a.limit > float64(a.ceiling) {
+		a.limit = float64(a.ceiling)
+	}
+	a.delay = a.delay * 3 / 4
+	if a.delay < adaptiveDelayMin {
+		a.delay = 0
+	}
+	if int(a.limit) >= a.ceiling && a.delay == 0 {
+		a.limited = false
+	}
+	a.wakeAll()
+}
+
+// wakeAll signals waiting requests to check the limits, the lock must be held
+func (a *adaptive) wakeAll() {
+	close(a.wake)
+	a.wake = make(chan struct{})
+}
@@ -6,10 +6,12 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -28,14 +30,19 @@ import (
 	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/auth"
+	"github.com/regclient/regclient/internal/redact"
 	"github.com/regclient/regclient/internal/throttle"
+	"github.com/regclient/regclient/pkg/bwlimit"
+	"github.com/regclient/regclient/pkg/trace"
 	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/reqmeta"
 	"github.com/regclient/regclient/types/warning"
 	"github.com/sirupsen/logrus"
 )
 
 var defaultDelayInit, _ = time.ParseDuration("1s")
 var defaultDelayMax, _ = time.ParseDuration("30s")
+var defaultDialTimeout = 30 * time.Second
 var warnRegexp = regexp.MustCompile(`^299\s+-\s+"([^"]+)"`)
 
 const (
@@ -54,6 +61,9 @@ type Client struct {
 	delayInit     time.Duration
 	delayMax      time.Duration
 	log           *logrus.Logger
+	reqHooks      []func(*http.Request) error
+	tracer        trace.Tracer
+	transportWrap func(http.RoundTripper) http.RoundTripper
 	userAgent     string
 	mu            sync.Mutex
 }
@@ -68,6 +78,8 @@ type clientHost struct {
 	newAuth      func() auth.Auth
 	mu           sync.Mutex
 	ratelimit    *time.Ticker
+	adapt        *adaptive
+	bwlimit      *bwlimit.Limiter
 }
 
 // Req is a request to send to a registry
@@ -111,6 +123,7 @@ type clientResp struct {
 	reader           io.Reader
 	readCur, readMax int64
 	throttle         *throttle.Throttle
+	adapt            *adaptive
 }
 
 // Opts is used to configure client options
@@ -196,6 +209,15 @@ func WithHTTPClient(hc *http.Client) Opts {
 	}
 }
 
+// WithRequestHook adds a function to modify each request before it is sent.
+// Hooks run in the order they are added, after the auth headers are set, and again on every retry.
+// An error from a hook fails the request to that host.
+func WithRequestHook(hook func(*http.Request) error) Opts {
+	return func(c *Client) {
+		c.reqHooks = append(c.reqHooks, hook)
+	}
+}
+
 // WithRetryLimit restricts the number of retries (defaults to 5)
 func WithRetryLimit(rl int) Opts {
 	return func(c *Client) {
@@ -219,6 +241,21 @@ func WithTransport(t *http.Transport) Opts {
 	}
 }
 
+// WithTracer creates a span for each request attempt
+func WithTracer(t trace.Tracer) Opts {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+// WithTransportWrap wraps the round tripper used for each host, after the TLS and connection settings are applied.
+// Requests to the registry and to the auth server both pass through the wrapper.
+func WithTransportWrap(wrap func(http.RoundTripper) http.RoundTripper) Opts {
+	return func(c *Client) {
+		c.transportWrap = wrap
+	}
+}
+
 // WithUserAgent sets a user agent header
 func WithUserAgent(ua string) Opts {
 	return func(c *Client) {
@@ -243,6 +280,11 @@ func (resp *clientResp) Next() error {
 	var err error
 	c := resp.client
 	req := resp.req
+	// a retry of a previous response releases the adaptive limit before the next attempt
+	if resp.adapt != nil {
+		resp.adapt.release()
+		resp.adapt = nil
+	}
 	// lookup reqHost entry
 	reqHost := c.getHost(req.Host)
 	// create sorted list of mirrors, based on backoffs, upstream, and priority
@@ -256,10 +298,12 @@ func (resp *clientResp) Next() error {
 	sort.Slice(hosts, sortHostsCmp(hosts, reqHost.config.Name))
 	// loop over requests to mirrors and retries
 	curHost := 0
+	attempt := 0
 	for {
 		backoff := false
 		dropHost := false
 		retryHost := false
+		noReplay := false
 		if len(hosts) == 0 {
 			if err != nil {
 				return err
@@ -287,7 +331,21 @@ func (resp *clientResp) Next() error {
 		if throttleErr != nil {
 			return throttleErr
 		}
+		// wait for the adaptive limit after the host returned a 429
+		adaptErr := h.adapt.acquire(resp.ctx)
+		if adaptErr != nil {
+			_ = h.config.Throttle().Release(resp.ctx)
+			return adaptErr
+		}
 
+		attempt++
+		reqCtx, span := trace.Start(resp.ctx, c.tracer, "regclient.request",
+			trace.Attr{Key: "registry.host", Value: h.config.Name},
+			trace.Attr{Key: "registry.repository", Value: api.Repository},
+			trace.Attr{Key: "http.request.method", Value: api.Method},
+			trace.Attr{Key: "regclient.attempt", Value: attempt},
+		)
+		var attemptResp *http.Response
 		// try each host in a closure to handle all the backoff/dropHost from one place
 		err = func() error {
 			var err error
@@ -351,7 +409,7 @@ func (resp *clientResp) Next() error {
 				}
 			}
 			var httpReq *http.Request
-			httpReq, err = http.NewRequestWithContext(resp.ctx, api.Method, u.String(), nil)
+			httpReq, err = http.NewRequestWithContext(reqCtx, api.Method, u.String(), nil)
 			if err != nil {
 				dropHost = true
 				return err
@@ -362,8 +420,15 @@ func (resp *clientResp) Next() error {
 					dropHost = true
 					return err
 				}
-				httpReq.Body = body
-				httpReq.GetBody = api.BodyFunc
+				// limit the bandwidth of blob uploads
+				httpReq.Body = bwlimit.NewReadCloser(resp.ctx, body, h.bwlimit, bwlimit.FromContext(resp.ctx))
+				httpReq.GetBody = func() (io.ReadCloser, error) {
+					body, err := api.BodyFunc()
+					if err != nil {
+						return nil, err
+					}
+					return bwlimit.NewReadCloser(resp.ctx, body, h.bwlimit, bwlimit.FromContext(resp.ctx)), nil
+				}
 				httpReq.ContentLength = api.BodyLen
 			} else if len(api.BodyBytes) > 0 {
 				body := io.NopCloser(bytes.NewReader(api.BodyBytes))
@@ -374,6 +439,15 @@ func (resp *clientResp) Next() error {
 			if len(api.Headers) > 0 {
 				httpReq.Header = api.Headers.Clone()
 			}
+			// add request scoped metadata without replacing headers from the API
+			for k, vl := range reqmeta.FromContext(resp.ctx) {
+				if httpReq.Header.Get(k) != "" {
+					continue
+				}
+				for _, v := range vl {
+					httpReq.Header.Add(k, v)
+				}
+			}
 			if c.userAgent != "" && httpReq.Header.Get("User-Agent") == "" {
 				httpReq.Header.Add("User-Agent", c.userAgent)
 			}
@@ -404,6 +478,15 @@ func (resp *clientResp) Next() error {
 				}
 			}
 
+			// run hooks to modify the request, e.g. signing
+			for _, hook := range c.reqHooks {
+				err = hook(httpReq)
+				if err != nil {
+					dropHost = true
+					return fmt.Errorf("request hook failed: %w", err)
+				}
+			}
+
 			// delay for the rate limit
 			if h.ratelimit != nil {
 				<-h.ratelimit.C
@@ -414,15 +497,19 @@ func (resp *clientResp) Next() error {
 
 			// send request
 			resp.client.log.WithFields(logrus.Fields{
-				"url":      httpReq.URL.String(),
+				"url":      redact.URL(httpReq.URL),
 				"method":   httpReq.Method,
 				"withAuth": (len(httpReq.Header.Values("Authorization")) > 0),
 			}).Debug("http req")
 			resp.resp, err = httpClient.Do(httpReq)
+			attemptResp = resp.resp
+			// once sent, the registry may have acted on a request that is not safe to replay
+			noReplay = !methodReplay(api.Method)
 
 			if err != nil {
+				err = redact.Error(err)
 				c.log.WithFields(logrus.Fields{
-					"URL": u.String(),
+					"URL": redact.URL(&u),
 					"err": err,
 				}).Debug("Request failed")
 				backoff = true
@@ -436,7 +523,9 @@ func (resp *clientResp) Next() error {
 				}
 			}
 			statusCode := resp.resp.StatusCode
-			if statusCode < 200 || statusCode >= 300 {
+			// a conditional request returns the 304 to the caller to use the cached content
+			notModified := statusCode == http.StatusNotModified && (httpReq.Header.Get("If-None-Match") != "" || httpReq.Header.Get("If-Modified-Since") != "")
+			if (statusCode < 200 || statusCode >= 300) && !notModified {
 				switch statusCode {
 				case http.StatusUnauthorized:
 					// if auth can be done, retry same host without delay, otherwise drop/backoff
@@ -445,15 +534,16 @@ func (resp *clientResp) Next() error {
 					} else {
 						err = fmt.Errorf("authentication handler unavailable")
 					}
+					noReplay = false
 					if err != nil {
 						if errors.Is(err, types.ErrEmptyChallenge) || errors.Is(err, types.ErrNoNewChallenge) || errors.Is(err, types.ErrHTTPUnauthorized) {
 							c.log.WithFields(logrus.Fields{
-								"URL": u.String(),
+								"URL": redact.URL(&u),
 								"Err": err,
 							}).Debug("Failed to handle auth request")
 						} else {
 							c.log.WithFields(logrus.Fields{
-								"URL": u.String(),
+								"URL": redact.URL(&u),
 								"Err": err,
 							}).Warn("Failed to handle auth request")
 						}
@@ -469,7 +559,17 @@ func (resp *clientResp) Next() error {
 				case http.StatusRequestedRangeNotSatisfiable:
 					// if range request error (blob push), drop mirror for this req, but other requests don't need backoff
 					dropHost = true
-				case http.StatusTooManyRequests, http.StatusRequestTimeout, http.StatusGatewayTimeout, http.StatusInternalServerError:
+				case http.StatusTooManyRequests:
+					// reduce the concurrency and rate of requests to the host, backoff but still retry
+					noReplay = false
+					limit, delay := h.adapt.throttled()
+					c.log.WithFields(logrus.Fields{
+						"host":        h.config.Name,
+						"concurrency": limit,
+						"delay":       delay.String(),
+					}).Info("Reducing request rate after a 429")
+					backoff = true
+				case http.StatusRequestTimeout, http.StatusGatewayTimeout, http.StatusInternalServerError:
 					// server is likely overloaded, backoff but still retry
 					backoff = true
 				default:
@@ -478,17 +578,28 @@ func (resp *clientResp) Next() error {
 					dropHost = true
 				}
 				c.log.WithFields(logrus.Fields{
-					"URL":    u.String(),
+					"URL":    redact.URL(&u),
 					"Status": http.StatusText(statusCode),
 				}).Debug("Request failed")
-				errHTTP := HTTPError(resp.resp.StatusCode)
 				errBody, _ := io.ReadAll(resp.resp.Body)
 				resp.resp.Body.Close()
+				errBody = redact.Body(resp.resp.Header.Get("Content-Type"), errBody)
+				errHTTP := HTTPErrorBody(resp.resp.StatusCode, errBody)
+				if len(types.RegistryErrors(errHTTP)) > 0 {
+					// the registry errors are included in the error message
+					return fmt.Errorf("request failed: %w", errHTTP)
+				}
 				return fmt.Errorf("request failed: %w: %s", errHTTP, errBody)
 			}
 
+			h.adapt.success()
+
 			// update digester
 			resp.reader = io.TeeReader(resp.resp.Body, resp.digester.Hash())
+			// limit the bandwidth of blob downloads, including redirects to external storage
+			if strings.HasPrefix(api.Path, "blobs/") || api.DirectURL != nil {
+				resp.reader = bwlimit.NewReader(resp.ctx, resp.reader, h.bwlimit, bwlimit.FromContext(resp.ctx))
+			}
 			resp.done = false
 			// set variables from headers if found
 			if resp.readCur == 0 && resp.readMax == 0 && resp.resp.Header.Get("Content-Length") != "" {
@@ -505,12 +616,22 @@ func (resp *clientResp) Next() error {
 			}
 			return nil
 		}()
+		if attemptResp != nil {
+			span.SetAttributes(
+				trace.Attr{Key: "http.response.status_code", Value: attemptResp.StatusCode},
+				trace.Attr{Key: "http.request.body.size", Value: api.BodyLen},
+				trace.Attr{Key: "http.response.body.size", Value: attemptResp.ContentLength},
+			)
+		}
+		trace.End(span, err)
 		// return on success
 		if err == nil {
 			resp.throttle = h.config.Throttle()
+			resp.adapt = h.adapt
 			return nil
 		}
 		// backoff, dropHost, and/or go to next host in the list
+		h.adapt.release()
 		throttleErr = h.config.Throttle().Release(resp.ctx)
 		if throttleErr != nil {
 			return throttleErr
@@ -527,6 +648,15 @@ func (resp *clientResp) Next() error {
 				}
 			}
 		}
+		if noReplay && !dropHost {
+			// the caller needs to restart the workflow, e.g. a new blob upload session
+			c.log.WithFields(logrus.Fields{
+				"method": api.Method,
+				"host":   h.config.Name,
+				"err":    err,
+			}).Debug("Request is not safe to replay")
+			return fmt.Errorf("%w%.0w", err, types.ErrRetryNeeded)
+		}
 		if dropHost {
 			hosts = append(hosts[:curHost], hosts[curHost+1:]...)
 		} else if !retryHost {
@@ -599,6 +729,10 @@ func (resp *clientResp) Close() error {
 		_ = resp.throttle.Release(resp.ctx)
 		resp.throttle = nil
 	}
+	if resp.adapt != nil {
+		resp.adapt.release()
+		resp.adapt = nil
+	}
 	if resp.resp == nil {
 		return types.ErrNotFound
 	}
@@ -709,21 +843,33 @@ func (c *Client) getHost(host string) *clientHost {
 	if h.auth == nil {
 		h.auth = map[string]auth.Auth{}
 	}
+	if h.adapt == nil {
+		h.adapt = newAdaptive(c.delayMax)
+	}
+	if h.bwlimit == nil && h.config.BlobRate > 0 {
+		h.bwlimit = bwlimit.New(h.config.BlobRate)
+	}
 	if h.ratelimit == nil && h.config.ReqPerSec > 0 {
 		h.ratelimit = time.NewTicker(time.Duration(float64(time.Second) / h.config.ReqPerSec))
 	}
 
 	if h.httpClient == nil {
 		h.httpClient = c.httpClient
-		// update http client for insecure requests and root certs
-		if h.config.TLS == config.TLSInsecure || len(c.rootCAPool) > 0 || len(c.rootCADirs) > 0 || h.config.RegCert != "" || (h.config.ClientCert != "" && h.config.ClientKey != "") {
+		tlsChange := h.config.TLS == config.TLSInsecure || len(c.rootCAPool) > 0 || len(c.rootCADirs) > 0 || h.config.RegCert != "" || (h.config.ClientCert != "" && h.config.ClientKey != "")
+		tuneChange := h.config.MaxIdleConns > 0 || h.config.IdleTimeout != 0 || h.config.KeepAlive != 0 || h.config.DisableHTTP2 || h.config.Proxy != "" || len(h.config.Resolve) > 0
+		// update http client for insecure requests, root certs, and connection tuning
+		if tlsChange || tuneChange {
 			// create a new client and modify the transport
 			httpClient := *c.httpClient
 			if httpClient.Transport == nil {
-				httpClient.Transport = http.DefaultTransport.(*http.Transport).Clone()
+				httpClient.Transport = http.DefaultTransport
 			}
 			t, ok := httpClient.Transport.(*http.Transport)
 			if ok {
+				// clone to avoid modifying a transport shared with other hosts
+				t = t.Clone()
+			}
+			if ok && tlsChange {
 				var tlsc *tls.Config
 				if t.TLSClientConfig != nil {
 					tlsc = t.TLSClientConfig.Clone()
@@ -753,8 +899,65 @@ func (c *Client) getHost(host string) *clientHost {
 					}
 				}
 				t.TLSClientConfig = tlsc
+			}
+			if ok && tuneChange {
+				if h.config.MaxIdleConns > 0 {
+					t.MaxIdleConnsPerHost = h.config.MaxIdleConns
+					if t.MaxIdleConns > 0 && t.MaxIdleConns < h.config.MaxIdleConns {
+						t.MaxIdleConns = h.config.MaxIdleConns
+					}
+				}
+				if h.config.IdleTimeout != 0 {
+					t.IdleConnTimeout = time.Duration(h.config.IdleTimeout)
+				}
+				if h.config.KeepAlive != 0 {
+					t.DialContext = (&net.Dialer{
+						Timeout:   defaultDialTimeout,
+						KeepAlive: time.Duration(h.config.KeepAlive),
+					}).DialContext
+				}
+				if len(h.config.Resolve) > 0 {
+					dial := t.DialContext
+					if dial == nil {
+						dial = (&net.Dialer{Timeout: defaultDialTimeout}).DialContext
+					}
+					t.DialContext = resolveDial(h.config.Hostname, h.config.Resolve, dial)
+				}
+				if h.config.DisableHTTP2 {
+					// a non-nil empty map disables the automatic upgrade to HTTP/2
+					t.ForceAttemptHTTP2 = false
+					t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+				}
+				if h.config.Proxy == config.ProxyDirect {
+					t.Proxy = nil
+				} else if h.config.Proxy != "" {
+					// credentials in the url are used for proxy authentication
+					proxyURL, err := url.Parse(h.config.Proxy)
+					if err != nil || proxyURL.Host == "" {
+						c.log.WithFields(logrus.Fields{
+							"host": host,
+							"err":  err,
+						}).Warn("failed to parse proxy url")
+					} else {
+						t.Proxy = http.ProxyURL(proxyURL)
+					}
+				}
+			}
+			if ok {
 				httpClient.Transport = t
+			} else if tuneChange {
+				c.log.WithFields(logrus.Fields{
+					"host": host,
+				}).Warn("Unable to apply connection settings to a custom transport")
+			}
+			h.httpClient = &httpClient
+		}
+		if c.transportWrap != nil {
+			httpClient := *h.httpClient
+			if httpClient.Transport == nil {
+				httpClient.Transport = http.DefaultTransport
 			}
+			httpClient.Transport = c.transportWrap(httpClient.Transport)
 			h.httpClient = &httpClient
 		}
 	}
@@ -798,19 +1001,81 @@ func (ch *clientHost) AuthCreds() func(h string) auth.Cred {
 	}
 }
 
-// HTTPError returns an error based on the status code
+// methodReplay returns true for idempotent methods that may be sent again after a failure.
+// Methods like POST and PATCH create or modify state on the registry, e.g. a blob upload session,
+// and a failed request may have been processed before the response was lost.
+func methodReplay(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// HTTPError returns a typed error based on the status code, the status code is preserved in a types.HTTPStatusError
 func HTTPError(statusCode int) error {
+	var err error
 	switch statusCode {
-	case 401:
-		return fmt.Errorf("%w [http %d]", types.ErrHTTPUnauthorized, statusCode)
-	case 403:
-		return fmt.Errorf("%w [http %d]", types.ErrHTTPUnauthorized, statusCode)
-	case 404:
-		return fmt.Errorf("%w [http %d]", types.ErrNotFound, statusCode)
-	case 429:
-		return fmt.Errorf("%w [http %d]", types.ErrHTTPRateLimit, statusCode)
+	case http.StatusUnauthorized:
+		err = types.ErrHTTPUnauthorized
+	case http.StatusForbidden:
+		err = types.ErrHTTPForbidden
+	case http.StatusNotFound:
+		err = types.ErrNotFound
+	case http.StatusTooManyRequests:
+		err = types.ErrHTTPRateLimit
 	default:
-		return fmt.Errorf("%w: %s [http %d]", types.ErrHTTPStatus, http.StatusText(statusCode), statusCode)
+		err = fmt.Errorf("%w: %s", types.ErrHTTPStatus, http.StatusText(statusCode))
+	}
+	return &types.HTTPStatusError{StatusCode: statusCode, Err: err}
+}
+
+// HTTPErrorBody returns the typed error for the status code with the errors list from an OCI error response body.
+// The body is ignored when it is not a JSON error response.
+func HTTPErrorBody(statusCode int, body []byte) error {
+	err := HTTPError(statusCode)
+	errResp := struct {
+		Errors []types.RegistryError `json:"errors"`
+	}{}
+	if json.Unmarshal(body, &errResp) != nil {
+		return err
+	}
+	var se *types.HTTPStatusError
+	if !errors.As(err, &se) {
+		return err
+	}
+	for _, re := range errResp.Errors {
+		if re.Code != "" || re.Message != "" {
+			se.Errors = append(se.Errors, re)
+		}
+	}
+	return err
+}
+
+// resolveDial connects to the resolve addresses in place of the registry hostname.
+// Connections to other hosts, like an auth server, are dialed unchanged.
+func resolveDial(hostname string, addrs []string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if h, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = h
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || !strings.EqualFold(host, hostname) {
+			return dial(ctx, network, addr)
+		}
+		errs := []error{}
+		for _, a := range addrs {
+			target := a
+			if _, _, err := net.SplitHostPort(a); err != nil {
+				target = net.JoinHostPort(strings.Trim(a, "[]"), port)
+			}
+			conn, err := dial(ctx, network, target)
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
 	}
 }
 
@@ -0,0 +1,25 @@
+// Package rematch filters names with lists of include and exclude regular expressions
+package rematch
+
+import "regexp"
+
+// Match returns true when s matches any include expression and no exclude expression.
+// An empty include list matches every name.
+func Match(s string, include, exclude []*regexp.Regexp) bool {
+	included := len(include) == 0
+	for _, re := range include {
+		if re.MatchString(s) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, re := range exclude {
+		if re.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
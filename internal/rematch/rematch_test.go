@@ -0,0 +1,32 @@
+package rematch
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	reV := regexp.MustCompile(`^v.*$`)
+	reRC := regexp.MustCompile(`-rc$`)
+	reLatest := regexp.MustCompile(`^latest$`)
+	tests := []struct {
+		name    string
+		s       string
+		include []*regexp.Regexp
+		exclude []*regexp.Regexp
+		expect  bool
+	}{
+		{name: "empty", s: "v1", expect: true},
+		{name: "included", s: "v1", include: []*regexp.Regexp{reLatest, reV}, expect: true},
+		{name: "not included", s: "edge", include: []*regexp.Regexp{reLatest, reV}, expect: false},
+		{name: "excluded", s: "v1-rc", include: []*regexp.Regexp{reV}, exclude: []*regexp.Regexp{reRC}, expect: false},
+		{name: "exclude only", s: "latest", exclude: []*regexp.Regexp{reRC}, expect: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := Match(tt.s, tt.include, tt.exclude); result != tt.expect {
+				t.Errorf("unexpected result for %s, expected %t, received %t", tt.s, tt.expect, result)
+			}
+		})
+	}
+}
@@ -2,7 +2,12 @@ package regclient
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
 
+	"github.com/regclient/regclient/pkg/trace"
 	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/manifest"
@@ -13,6 +18,10 @@ type manifestOpt struct {
 	d             types.Descriptor
 	schemeOpts    []scheme.ManifestOpts
 	requireDigest bool
+	checkChildren bool
+	child         bool
+	childSrc      *ref.Ref
+	ignoreMissing bool
 }
 
 // ManifestOpts define options for the Manifest* commands
@@ -35,10 +44,27 @@ func WithManifestCheckReferrers() ManifestOpts {
 // WithManifestChild for ManifestPut.
 func WithManifestChild() ManifestOpts {
 	return func(opts *manifestOpt) {
+		opts.child = true
 		opts.schemeOpts = append(opts.schemeOpts, scheme.WithManifestChild())
 	}
 }
 
+// WithManifestCheckChildren verifies every descriptor referenced by the manifest exists before ManifestPut.
+func WithManifestCheckChildren() ManifestOpts {
+	return func(opts *manifestOpt) {
+		opts.checkChildren = true
+	}
+}
+
+// WithManifestChildSource copies any missing descriptors from the source on ManifestPut.
+// This implies WithManifestCheckChildren.
+func WithManifestChildSource(src ref.Ref) ManifestOpts {
+	return func(opts *manifestOpt) {
+		opts.checkChildren = true
+		opts.childSrc = &src
+	}
+}
+
 // WithManifestDesc includes the descriptor for ManifestGet.
 // This is used to automatically extract a Data field if available.
 func WithManifestDesc(d types.Descriptor) ManifestOpts {
@@ -47,6 +73,14 @@ func WithManifestDesc(d types.Descriptor) ManifestOpts {
 	}
 }
 
+// WithManifestIgnoreMissing treats a missing manifest as a successful ManifestDelete.
+// A 401 or 403 on the delete is only ignored when a HEAD request returns a not found.
+func WithManifestIgnoreMissing() ManifestOpts {
+	return func(opts *manifestOpt) {
+		opts.ignoreMissing = true
+	}
+}
+
 // WithManifestRequireDigest falls back from a HEAD to a GET request when digest headers aren't received.
 func WithManifestRequireDigest() ManifestOpts {
 	return func(opts *manifestOpt) {
@@ -57,7 +91,9 @@ func WithManifestRequireDigest() ManifestOpts {
 // ManifestDelete removes a manifest, including all tags pointing to that registry
 // The reference must include the digest to delete (see TagDelete for deleting a tag)
 // All tags pointing to the manifest will be deleted
-func (rc *RegClient) ManifestDelete(ctx context.Context, r ref.Ref, opts ...ManifestOpts) error {
+func (rc *RegClient) ManifestDelete(ctx context.Context, r ref.Ref, opts ...ManifestOpts) (err error) {
+	ctx, span := rc.traceStart(ctx, "ManifestDelete", r)
+	defer func() { trace.End(span, err) }()
 	opt := manifestOpt{schemeOpts: []scheme.ManifestOpts{}}
 	for _, fn := range opts {
 		fn(&opt)
@@ -66,11 +102,36 @@ func (rc *RegClient) ManifestDelete(ctx context.Context, r ref.Ref, opts ...Mani
 	if err != nil {
 		return err
 	}
-	return schemeAPI.ManifestDelete(ctx, r, opt.schemeOpts...)
+	err = schemeAPI.ManifestDelete(ctx, r, opt.schemeOpts...)
+	if opt.ignoreMissing {
+		err = ignoreMissing(err, func() error {
+			_, err := schemeAPI.ManifestHead(ctx, r)
+			return err
+		})
+	}
+	return err
+}
+
+// ignoreMissing returns nil when err indicates the content is already gone.
+// Registries may return a 401 or 403 for a missing repository, so those errors are ignored when head returns a not found.
+// A missing ocidir is also ignored.
+// Content visible with head returns the original error, e.g. when the credentials are not permitted to delete.
+func ignoreMissing(err error, head func() error) error {
+	if err == nil || errors.Is(err, types.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if errors.Is(err, types.ErrHTTPUnauthorized) {
+		if headErr := head(); errors.Is(headErr, types.ErrNotFound) {
+			return nil
+		}
+	}
+	return err
 }
 
 // ManifestGet retrieves a manifest
-func (rc *RegClient) ManifestGet(ctx context.Context, r ref.Ref, opts ...ManifestOpts) (manifest.Manifest, error) {
+func (rc *RegClient) ManifestGet(ctx context.Context, r ref.Ref, opts ...ManifestOpts) (m manifest.Manifest, err error) {
+	ctx, span := rc.traceStart(ctx, "ManifestGet", r)
+	defer func() { trace.End(span, err) }()
 	opt := manifestOpt{schemeOpts: []scheme.ManifestOpts{}}
 	for _, fn := range opts {
 		fn(&opt)
@@ -86,6 +147,10 @@ func (rc *RegClient) ManifestGet(ctx context.Context, r ref.Ref, opts ...Manifes
 			)
 		}
 	}
+	err = rc.manifestDigestOnly(r)
+	if err != nil {
+		return nil, err
+	}
 	schemeAPI, err := rc.schemeGet(r.Scheme)
 	if err != nil {
 		return nil, err
@@ -94,16 +159,22 @@ func (rc *RegClient) ManifestGet(ctx context.Context, r ref.Ref, opts ...Manifes
 }
 
 // ManifestHead queries for the existence of a manifest and returns metadata (digest, media-type, size)
-func (rc *RegClient) ManifestHead(ctx context.Context, r ref.Ref, opts ...ManifestOpts) (manifest.Manifest, error) {
+func (rc *RegClient) ManifestHead(ctx context.Context, r ref.Ref, opts ...ManifestOpts) (m manifest.Manifest, err error) {
+	ctx, span := rc.traceStart(ctx, "ManifestHead", r)
+	defer func() { trace.End(span, err) }()
 	opt := manifestOpt{schemeOpts: []scheme.ManifestOpts{}}
 	for _, fn := range opts {
 		fn(&opt)
 	}
+	err = rc.manifestDigestOnly(r)
+	if err != nil {
+		return nil, err
+	}
 	schemeAPI, err := rc.schemeGet(r.Scheme)
 	if err != nil {
 		return nil, err
 	}
-	m, err := schemeAPI.ManifestHead(ctx, r)
+	m, err = schemeAPI.ManifestHead(ctx, r)
 	if err != nil {
 		return m, err
 	}
@@ -113,9 +184,23 @@ func (rc *RegClient) ManifestHead(ctx context.Context, r ref.Ref, opts ...Manife
 	return m, err
 }
 
+// manifestDigestOnly refuses to resolve a tag on a registry configured with digestOnly.
+func (rc *RegClient) manifestDigestOnly(r ref.Ref) error {
+	if r.Scheme != "reg" || r.Digest != "" {
+		return nil
+	}
+	if h, ok := rc.hosts[r.Registry]; ok && h.DigestOnly {
+		return fmt.Errorf("registry %s only permits pulls by digest, %s%.0w", r.Registry, r.CommonName(), types.ErrMissingDigest)
+	}
+	return nil
+}
+
 // ManifestPut pushes a manifest
-// Any descriptors referenced by the manifest typically need to be pushed first
-func (rc *RegClient) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest, opts ...ManifestOpts) error {
+// Any descriptors referenced by the manifest typically need to be pushed first,
+// see WithManifestCheckChildren and WithManifestChildSource to verify or copy them.
+func (rc *RegClient) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest, opts ...ManifestOpts) (err error) {
+	ctx, span := rc.traceStart(ctx, "ManifestPut", r)
+	defer func() { trace.End(span, err) }()
 	opt := manifestOpt{schemeOpts: []scheme.ManifestOpts{}}
 	for _, fn := range opts {
 		fn(&opt)
@@ -124,5 +209,90 @@ func (rc *RegClient) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Mani
 	if err != nil {
 		return err
 	}
-	return schemeAPI.ManifestPut(ctx, r, m, opt.schemeOpts...)
+	err = rc.pushHookManifest(ctx, r, m)
+	if err != nil {
+		return err
+	}
+	if opt.checkChildren {
+		err = rc.manifestPutChildren(ctx, r, m, opt)
+		if err != nil {
+			return err
+		}
+	}
+	err = schemeAPI.ManifestPut(ctx, r, m, opt.schemeOpts...)
+	if err != nil || opt.child {
+		return err
+	}
+	return rc.postPushRun(ctx, r, m)
+}
+
+// manifestPutChildren verifies the manifests and blobs referenced by m exist in the target repository,
+// copying missing content from the child source when provided.
+// Without a child source, the error lists every missing descriptor.
+func (rc *RegClient) manifestPutChildren(ctx context.Context, r ref.Ref, m manifest.Manifest, opt manifestOpt) error {
+	rTgt := r
+	rTgt.Tag = ""
+	missing := []string{}
+	if mi, ok := m.(manifest.Indexer); ok {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return err
+		}
+		for _, d := range dl {
+			rTgt.Digest = d.Digest.String()
+			_, err = rc.ManifestHead(ctx, rTgt)
+			if err == nil {
+				continue
+			}
+			if opt.childSrc == nil {
+				missing = append(missing, d.Digest.String())
+				continue
+			}
+			rSrc := *opt.childSrc
+			rSrc.Tag = ""
+			rSrc.Digest = d.Digest.String()
+			err = rc.ImageCopy(ctx, rSrc, rTgt, ImageWithChild())
+			if err != nil {
+				return fmt.Errorf("failed to copy child manifest %s: %w", rSrc.CommonName(), err)
+			}
+		}
+	}
+	if mi, ok := m.(manifest.Imager); ok {
+		dl := []types.Descriptor{}
+		cd, err := mi.GetConfig()
+		if err != nil && !errors.Is(err, types.ErrUnsupportedMediaType) {
+			return err
+		} else if err == nil {
+			dl = append(dl, cd)
+		}
+		layers, err := mi.GetLayers()
+		if err != nil {
+			return err
+		}
+		for _, l := range layers {
+			// skip external layers that are not pushed to the registry
+			if len(l.URLs) == 0 {
+				dl = append(dl, l)
+			}
+		}
+		for _, d := range dl {
+			_, err = rc.BlobHead(ctx, rTgt, d)
+			if err == nil {
+				continue
+			}
+			if opt.childSrc == nil {
+				missing = append(missing, d.Digest.String())
+				continue
+			}
+			err = rc.BlobCopy(ctx, *opt.childSrc, rTgt, d)
+			if err != nil {
+				return fmt.Errorf("failed to copy blob %s: %w", d.Digest.String(), err)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		rTgt.Digest = ""
+		return fmt.Errorf("content referenced by the manifest is missing from %s: %s%.0w", rTgt.CommonName(), strings.Join(missing, ", "), types.ErrNotFound)
+	}
+	return nil
 }
@@ -10,12 +10,14 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/reqresp"
+	"github.com/regclient/regclient/internal/rwfs"
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/docker/schema2"
 	"github.com/regclient/regclient/types/manifest"
@@ -198,6 +200,15 @@ func TestManifest(t *testing.T) {
 			},
 			ReqPerSec: 100,
 		},
+		{
+			Name:       "digest." + tsHost,
+			Hostname:   tsHost,
+			TLS:        config.TLSDisabled,
+			User:       "unused",
+			Pass:       "unused",
+			DigestOnly: true,
+			ReqPerSec:  100,
+		},
 	}
 	log := &logrus.Logger{
 		Out:       os.Stderr,
@@ -377,5 +388,273 @@ func TestManifest(t *testing.T) {
 			return
 		}
 	})
+	t.Run("Digest only", func(t *testing.T) {
+		tagRef, err := ref.New("digest." + tsHost + repoPath + ":" + getTag)
+		if err != nil {
+			t.Fatalf("Failed creating tagRef: %v", err)
+		}
+		_, err = rc.ManifestGet(ctx, tagRef)
+		if !errors.Is(err, types.ErrMissingDigest) {
+			t.Errorf("ManifestGet by tag, expected %v, received %v", types.ErrMissingDigest, err)
+		}
+		_, err = rc.ManifestHead(ctx, tagRef)
+		if !errors.Is(err, types.ErrMissingDigest) {
+			t.Errorf("ManifestHead by tag, expected %v, received %v", types.ErrMissingDigest, err)
+		}
+		digRef := tagRef
+		digRef.Tag = ""
+		digRef.Digest = mDigest.String()
+		mGet, err := rc.ManifestGet(ctx, digRef)
+		if err != nil {
+			t.Fatalf("Failed running ManifestGet by digest: %v", err)
+		}
+		if mGet.GetDescriptor().Digest != mDigest {
+			t.Errorf("Unexpected digest: %s", mGet.GetDescriptor().Digest.String())
+		}
+	})
+}
+
+func TestManifestPutChildren(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://testchildren:v1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	m, err := rc.ManifestGet(ctx, rSrc)
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	t.Run("Missing", func(t *testing.T) {
+		err := rc.ManifestPut(ctx, rTgt, m, WithManifestCheckChildren())
+		if err == nil {
+			t.Errorf("put with missing children did not fail")
+		} else if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		// every missing child is listed
+		mi, ok := m.(manifest.Indexer)
+		if !ok {
+			t.Fatalf("manifest is not an index")
+		}
+		dl, _ := mi.GetManifestList()
+		for _, d := range dl {
+			if err != nil && !strings.Contains(err.Error(), d.Digest.String()) {
+				t.Errorf("missing child %s not listed in error: %v", d.Digest.String(), err)
+			}
+		}
+	})
+	t.Run("Source", func(t *testing.T) {
+		err := rc.ManifestPut(ctx, rTgt, m, WithManifestChildSource(rSrc))
+		if err != nil {
+			t.Errorf("put with child source failed: %v", err)
+			return
+		}
+		err = rc.ImageCopy(ctx, rTgt, rSrc, ImageWithForceRecursive())
+		if err != nil {
+			t.Errorf("failed to verify children by copying back: %v", err)
+		}
+		err = rc.ManifestPut(ctx, rTgt, m, WithManifestCheckChildren())
+		if err != nil {
+			t.Errorf("put with existing children failed: %v", err)
+		}
+	})
+}
 
+func TestDeleteIgnoreMissing(t *testing.T) {
+	ctx := context.Background()
+	missingRepo := "/missing"
+	denyRepo := "/deny"
+	hiddenRepo := "/hidden"
+	mDigest := digest.FromString("manifest")
+	bDigest := digest.FromString("blob")
+	rrs := []reqresp.ReqResp{
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "delete manifest missing repo",
+				Method: "DELETE",
+				Path:   "/v2" + missingRepo + "/manifests/" + mDigest.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusForbidden,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "head manifest missing repo",
+				Method: "HEAD",
+				Path:   "/v2" + missingRepo + "/manifests/" + mDigest.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusNotFound,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "delete blob missing repo",
+				Method: "DELETE",
+				Path:   "/v2" + missingRepo + "/blobs/" + bDigest.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusNotFound,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "delete manifest denied",
+				Method: "DELETE",
+				Path:   "/v2" + denyRepo + "/manifests/" + mDigest.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusForbidden,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "head manifest denied",
+				Method: "HEAD",
+				Path:   "/v2" + denyRepo + "/manifests/" + mDigest.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusOK,
+				Headers: http.Header{
+					"Content-Length":        {"100"},
+					"Content-Type":          {types.MediaTypeOCI1Manifest},
+					"Docker-Content-Digest": {mDigest.String()},
+				},
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "delete manifest hidden",
+				Method: "DELETE",
+				Path:   "/v2" + hiddenRepo + "/manifests/" + mDigest.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusForbidden,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "head manifest hidden",
+				Method: "HEAD",
+				Path:   "/v2" + hiddenRepo + "/manifests/" + mDigest.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusUnauthorized,
+			},
+		},
+	}
+	rrs = append(rrs, reqresp.BaseEntries...)
+	ts := httptest.NewServer(reqresp.NewHandler(t, rrs))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	rc := New(
+		WithConfigHost(config.Host{
+			Name:      tsHost,
+			Hostname:  tsHost,
+			TLS:       config.TLSDisabled,
+			ReqPerSec: 100,
+		}),
+		WithLog(log),
+		WithRetryDelay(time.Millisecond, time.Millisecond),
+	)
+	rMissing, err := ref.New(tsHost + missingRepo + "@" + mDigest.String())
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	rDeny, err := ref.New(tsHost + denyRepo + "@" + mDigest.String())
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+
+	t.Run("Manifest forbidden", func(t *testing.T) {
+		err := rc.ManifestDelete(ctx, rMissing)
+		if !errors.Is(err, types.ErrHTTPForbidden) || !errors.Is(err, types.ErrHTTPUnauthorized) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if types.HTTPStatus(err) != http.StatusForbidden {
+			t.Errorf("unexpected status, expected %d, received %d", http.StatusForbidden, types.HTTPStatus(err))
+		}
+	})
+	t.Run("Manifest ignore missing", func(t *testing.T) {
+		err := rc.ManifestDelete(ctx, rMissing, WithManifestIgnoreMissing())
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Manifest ignore missing unauthorized head", func(t *testing.T) {
+		rHidden, err := ref.New(tsHost + hiddenRepo + "@" + mDigest.String())
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		err = rc.ManifestDelete(ctx, rHidden, WithManifestIgnoreMissing())
+		if !errors.Is(err, types.ErrHTTPForbidden) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Manifest ignore missing denied", func(t *testing.T) {
+		err := rc.ManifestDelete(ctx, rDeny, WithManifestIgnoreMissing())
+		if !errors.Is(err, types.ErrHTTPForbidden) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Blob not found", func(t *testing.T) {
+		err := rc.BlobDelete(ctx, rMissing, types.Descriptor{Digest: bDigest})
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if types.HTTPStatus(err) != http.StatusNotFound {
+			t.Errorf("unexpected status, expected %d, received %d", http.StatusNotFound, types.HTTPStatus(err))
+		}
+	})
+	t.Run("Blob ignore missing", func(t *testing.T) {
+		err := rc.BlobDelete(ctx, rMissing, types.Descriptor{Digest: bDigest}, BlobWithIgnoreMissing())
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Tag ignore missing", func(t *testing.T) {
+		fsMem := rwfs.MemNew()
+		err := rwfs.CopyRecursive(rwfs.OSNew(""), "testdata", fsMem, ".")
+		if err != nil {
+			t.Fatalf("failed to setup memfs copy: %v", err)
+		}
+		rcOCI := New(WithFS(fsMem))
+		for _, name := range []string{"ocidir://testrepo:missing", "ocidir://missing:v1"} {
+			rTag, err := ref.New(name)
+			if err != nil {
+				t.Fatalf("failed to parse ref: %v", err)
+			}
+			err = rcOCI.TagDelete(ctx, rTag)
+			if err == nil {
+				t.Errorf("delete of %s did not fail", name)
+			}
+			err = rcOCI.TagDelete(ctx, rTag, TagDeleteWithIgnoreMissing())
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", name, err)
+			}
+		}
+	})
 }
@@ -54,6 +54,24 @@ func WithBuildArgRm(arg string, value *regexp.Regexp) Opts {
 	}
 }
 
+// WithCmd sets the default arguments to the entrypoint in the image config, an empty list deletes the value
+func WithCmd(args []string) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		dc.stepsOCIConfig = append(dc.stepsOCIConfig, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, doc *dagOCIConfig) error {
+			oc := doc.oc.GetConfig()
+			if strSliceEq(oc.Config.Cmd, args) {
+				return nil
+			}
+			oc.Config.Cmd = args
+			doc.oc.SetConfig(oc)
+			doc.modified = true
+			doc.newDesc = doc.oc.GetDescriptor()
+			return nil
+		})
+		return nil
+	}
+}
+
 // WithConfigTimestamp sets the timestamp on the config entries based on options
 func WithConfigTimestamp(optTime OptTime) Opts {
 	return func(dc *dagConfig, dm *dagManifest) error {
@@ -212,6 +230,86 @@ func WithConfigTimestampMax(t time.Time) Opts {
 	})
 }
 
+// WithEntrypoint sets the entrypoint in the image config, an empty list deletes the value
+func WithEntrypoint(args []string) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		dc.stepsOCIConfig = append(dc.stepsOCIConfig, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, doc *dagOCIConfig) error {
+			oc := doc.oc.GetConfig()
+			if strSliceEq(oc.Config.Entrypoint, args) {
+				return nil
+			}
+			oc.Config.Entrypoint = args
+			doc.oc.SetConfig(oc)
+			doc.modified = true
+			doc.newDesc = doc.oc.GetDescriptor()
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithEnv sets an environment variable in the image config
+func WithEnv(name, value string) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		if name == "" || strings.Contains(name, "=") {
+			return fmt.Errorf("invalid environment variable name %q%.0w", name, types.ErrParsingFailed)
+		}
+		dc.stepsOCIConfig = append(dc.stepsOCIConfig, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, doc *dagOCIConfig) error {
+			oc := doc.oc.GetConfig()
+			entry := name + "=" + value
+			env := []string{}
+			found, changed := false, false
+			for _, cur := range oc.Config.Env {
+				if strings.SplitN(cur, "=", 2)[0] != name {
+					env = append(env, cur)
+				} else if !found {
+					found = true
+					env = append(env, entry)
+					changed = changed || cur != entry
+				} else {
+					// drop duplicate entries
+					changed = true
+				}
+			}
+			if !found {
+				env = append(env, entry)
+				changed = true
+			}
+			if changed {
+				oc.Config.Env = env
+				doc.oc.SetConfig(oc)
+				doc.modified = true
+				doc.newDesc = doc.oc.GetDescriptor()
+			}
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithEnvRm deletes an environment variable from the image config
+func WithEnvRm(name string) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		dc.stepsOCIConfig = append(dc.stepsOCIConfig, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, doc *dagOCIConfig) error {
+			oc := doc.oc.GetConfig()
+			env := []string{}
+			for _, cur := range oc.Config.Env {
+				if strings.SplitN(cur, "=", 2)[0] != name {
+					env = append(env, cur)
+				}
+			}
+			if len(env) != len(oc.Config.Env) {
+				oc.Config.Env = env
+				doc.oc.SetConfig(oc)
+				doc.modified = true
+				doc.newDesc = doc.oc.GetDescriptor()
+			}
+			return nil
+		})
+		return nil
+	}
+}
+
 // WithExposeAdd defines an exposed port in the image config
 func WithExposeAdd(port string) Opts {
 	return func(dc *dagConfig, dm *dagManifest) error {
@@ -321,6 +419,50 @@ func WithLabel(name, value string) Opts {
 	}
 }
 
+// WithLabelRmRegex deletes labels with a name matching the regexp from every image config
+func WithLabelRmRegex(re *regexp.Regexp) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		dc.stepsOCIConfig = append(dc.stepsOCIConfig, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, doc *dagOCIConfig) error {
+			changed := false
+			oc := doc.oc.GetConfig()
+			labels := map[string]string{}
+			for name, value := range oc.Config.Labels {
+				if re.MatchString(name) {
+					changed = true
+				} else {
+					labels[name] = value
+				}
+			}
+			if changed {
+				oc.Config.Labels = labels
+				doc.oc.SetConfig(oc)
+				doc.modified = true
+				doc.newDesc = doc.oc.GetDescriptor()
+			}
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithUser sets the user in the image config, an empty value deletes the user
+func WithUser(user string) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		dc.stepsOCIConfig = append(dc.stepsOCIConfig, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, doc *dagOCIConfig) error {
+			oc := doc.oc.GetConfig()
+			if oc.Config.User == user {
+				return nil
+			}
+			oc.Config.User = user
+			doc.oc.SetConfig(oc)
+			doc.modified = true
+			doc.newDesc = doc.oc.GetDescriptor()
+			return nil
+		})
+		return nil
+	}
+}
+
 // WithVolumeAdd defines a volume in the image config
 func WithVolumeAdd(volume string) Opts {
 	return func(dc *dagConfig, dm *dagManifest) error {
@@ -14,6 +14,7 @@ import (
 	"github.com/regclient/regclient/types/blob"
 	"github.com/regclient/regclient/types/manifest"
 	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
 	"github.com/regclient/regclient/types/ref"
 )
 
@@ -32,6 +33,8 @@ type dagConfig struct {
 	stepsLayerFile []func(context.Context, *regclient.RegClient, ref.Ref, ref.Ref, *dagLayer, *tar.Header, io.Reader) (*tar.Header, io.Reader, changes, error)
 	maxDataSize    int64
 	rTgt           ref.Ref
+	whiteouts      []string            // paths deleted by WithLayerWhiteout
+	platformsKeep  []platform.Platform // platforms kept by WithPlatformKeep
 }
 
 type dagManifest struct {
@@ -2,21 +2,61 @@ package mod
 
 import (
 	"archive/tar"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/pkg/archive"
+	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
 	"github.com/regclient/regclient/types/ref"
 )
 
+// WithLayerAddDir adds a layer with the contents of a local directory, placed under the target path in the image.
+// This injects files like certificates or configuration without rebuilding the image.
+// Files are owned by root in the layer, and the permissions and timestamps are copied from the directory.
+func WithLayerAddDir(dir, target string) Opts {
+	target = strings.Trim(path.Clean("/"+target), "/")
+	return func(dc *dagConfig, dm *dagManifest) error {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("layer source is not a directory: %s", dir)
+		}
+		layerAddStep(dc, func() string { return "add " + dir + " /" + target }, func(tw *tar.Writer) error {
+			return layerTarDir(tw, dir, target)
+		})
+		return nil
+	}
+}
+
+// WithLayerAddTar adds a layer from a local tar file, the tar may be compressed.
+func WithLayerAddTar(file string) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+		layerAddStep(dc, func() string { return "add " + file }, func(tw *tar.Writer) error {
+			return layerTarFile(tw, file)
+		})
+		return nil
+	}
+}
+
 // WithLayerReproducible modifies the layer with reproducible options.
 // This currently configures users and groups with numeric ids.
 func WithLayerReproducible() Opts {
@@ -125,6 +165,423 @@ func WithLayerRmIndex(index int) Opts {
 	}
 }
 
+// WithLayerSquash merges the top layers of an image into a single layer.
+// A count of 0 or less, or a count greater than the number of layers, squashes every layer.
+// Files deleted or replaced by a newer layer are removed, and the config diff_ids and history are regenerated.
+// When layers remain below the squashed layer, whiteout files are preserved to delete content from those layers.
+// The layers are streamed from the source repository, and the squashed layer is pushed to the target before the image is modified.
+func WithLayerSquash(count int) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		dc.stepsManifest = append(dc.stepsManifest, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, dm *dagManifest) error {
+			if dm.m.IsList() || dm.mod == deleted || dm.config == nil {
+				return nil
+			}
+			mi, ok := dm.m.(manifest.Imager)
+			if !ok {
+				return fmt.Errorf("manifest is not an image")
+			}
+			layers, err := mi.GetLayers()
+			if err != nil {
+				return err
+			}
+			if len(layers) != len(dm.layers) {
+				return fmt.Errorf("layer squash cannot be combined with other layer changes%.0w", types.ErrUnsupported)
+			}
+			for _, dl := range dm.layers {
+				if dl.mod != unchanged {
+					return fmt.Errorf("layer squash cannot be combined with other layer changes%.0w", types.ErrUnsupported)
+				}
+			}
+			start := 0
+			if count > 0 && count < len(layers) {
+				start = len(layers) - count
+			}
+			if len(layers)-start < 2 {
+				return nil
+			}
+			for _, l := range layers[start:] {
+				if len(l.URLs) > 0 || !inListStr(l.MediaType, mtWLTar) {
+					return fmt.Errorf("layer squash does not support layer %s, media type %s%.0w", l.Digest.String(), l.MediaType, types.ErrUnsupportedMediaType)
+				}
+			}
+			oc := dm.config.oc.GetConfig()
+			if len(oc.RootFS.DiffIDs) != len(layers) {
+				return fmt.Errorf("config rootfs does not match layer count%.0w", types.ErrMismatch)
+			}
+			// locate the history entries for the squashed layers
+			hStart, hEnd, hLayer := -1, -1, 0
+			for i, h := range oc.History {
+				if h.EmptyLayer {
+					continue
+				}
+				if hLayer == start {
+					hStart = i
+				}
+				hEnd = i
+				hLayer++
+			}
+			if len(oc.History) > 0 && hLayer != len(layers) {
+				return fmt.Errorf("config history does not match layer count%.0w", types.ErrMismatch)
+			}
+
+			squashed := len(layers) - start
+			d, ucDigest, err := layerSquash(ctx, rc, rSrc, rTgt, layers[start:], start > 0, layerMediaType(dm))
+			if err != nil {
+				return err
+			}
+
+			// replace the squashed layers in the manifest, config, and dag
+			layers = append(layers[:start], d)
+			err = mi.SetLayers(layers)
+			if err != nil {
+				return err
+			}
+			// the squashed layer is only on the target, it is not copied from the source
+			dm.layers = append(dm.layers[:start], &dagLayer{
+				mod:      replaced,
+				ucDigest: ucDigest,
+				desc:     d,
+				newDesc:  d,
+			})
+			oc.RootFS.DiffIDs = append(oc.RootFS.DiffIDs[:start], ucDigest)
+			if hStart >= 0 {
+				// empty layer entries are kept, followed by a single entry for the squashed layer
+				history := append([]v1.History{}, oc.History[:hStart]...)
+				created := oc.History[hEnd].Created
+				for _, h := range oc.History[hStart : hEnd+1] {
+					if h.EmptyLayer {
+						history = append(history, h)
+					}
+				}
+				history = append(history, v1.History{
+					Created:   created,
+					CreatedBy: fmt.Sprintf("squash %d layers", squashed),
+					Comment:   "regclient",
+				})
+				oc.History = append(history, oc.History[hEnd+1:]...)
+			}
+			dm.config.oc.SetConfig(oc)
+			dm.config.newDesc = dm.config.oc.GetDescriptor()
+			dm.config.modified = true
+			dm.mod = replaced
+			return nil
+		})
+		return nil
+	}
+}
+
+// layerSquash streams the layers from rSrc into a single gzip compressed layer pushed to rTgt.
+// The layers are read twice, the first pass from the newest layer selects the files to keep,
+// and the second pass from the oldest layer writes those files, preserving the order for hard links.
+func layerSquash(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, layers []types.Descriptor, keepWhiteout bool, mt string) (types.Descriptor, digest.Digest, error) {
+	keep := map[string]int{}
+	whiteout := map[string]int{}
+	opaque := map[string]int{}
+	nondir := map[string]int{}
+	// hidden returns true if a newer layer deleted or replaced a parent of the file
+	hidden := func(name string, i int) bool {
+		for p := name; p != "." && p != ""; {
+			if li, ok := whiteout[p]; ok && li > i {
+				return true
+			}
+			p = path.Dir(p)
+			if li, ok := opaque[p]; ok && li > i {
+				return true
+			}
+			if li, ok := nondir[p]; ok && li > i {
+				return true
+			}
+		}
+		return false
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		err := layerSquashRead(ctx, rc, rSrc, layers[i], func(th *tar.Header, name string, _ io.Reader) error {
+			dir, base := path.Split(name)
+			dir = path.Clean(dir)
+			if hidden(name, i) {
+				return nil
+			}
+			if base == ".wh..wh..opq" {
+				if _, ok := opaque[dir]; !ok {
+					opaque[dir] = i
+				}
+			} else if strings.HasPrefix(base, ".wh.") {
+				target := path.Join(dir, strings.TrimPrefix(base, ".wh."))
+				if _, ok := keep[target]; ok {
+					// recreated by a newer layer
+					return nil
+				}
+				if _, ok := whiteout[target]; !ok {
+					whiteout[target] = i
+				}
+			} else {
+				if _, ok := keep[name]; !ok {
+					keep[name] = i
+					if th.Typeflag != tar.TypeDir {
+						nondir[name] = i
+					}
+				}
+				return nil
+			}
+			if _, ok := keep[name]; !ok && keepWhiteout {
+				keep[name] = i
+			}
+			return nil
+		})
+		if err != nil {
+			return types.Descriptor{}, "", err
+		}
+	}
+
+	return layerCreate(ctx, rc, rTgt, mt, func(tw *tar.Writer) error {
+		for i, l := range layers {
+			err := layerSquashRead(ctx, rc, rSrc, l, func(th *tar.Header, name string, rdr io.Reader) error {
+				if li, ok := keep[name]; !ok || li != i {
+					return nil
+				}
+				err := tw.WriteHeader(th)
+				if err != nil {
+					return err
+				}
+				if th.Typeflag == tar.TypeReg && th.Size > 0 {
+					_, err = io.CopyN(tw, rdr, th.Size)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// layerCreate pushes a gzip compressed layer with the tar content written by fn to the repository.
+// The compressed descriptor and uncompressed digest are returned.
+func layerCreate(ctx context.Context, rc *regclient.RegClient, r ref.Ref, mt string, fn func(tw *tar.Writer) error) (types.Descriptor, digest.Digest, error) {
+	fh, err := os.CreateTemp("", "regclient-mod-")
+	if err != nil {
+		return types.Descriptor{}, "", err
+	}
+	defer fh.Close()
+	defer os.Remove(fh.Name())
+	digRaw := digest.Canonical.Digester()
+	digUC := digest.Canonical.Digester()
+	gw := gzip.NewWriter(io.MultiWriter(fh, digRaw.Hash()))
+	tw := tar.NewWriter(io.MultiWriter(gw, digUC.Hash()))
+	if err = fn(tw); err != nil {
+		return types.Descriptor{}, "", err
+	}
+	if err = tw.Close(); err != nil {
+		return types.Descriptor{}, "", err
+	}
+	if err = gw.Close(); err != nil {
+		return types.Descriptor{}, "", err
+	}
+	size, err := fh.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return types.Descriptor{}, "", err
+	}
+	_, err = fh.Seek(0, io.SeekStart)
+	if err != nil {
+		return types.Descriptor{}, "", err
+	}
+	d := types.Descriptor{
+		MediaType: mt,
+		Digest:    digRaw.Digest(),
+		Size:      size,
+	}
+	_, err = rc.BlobPut(ctx, r, d, fh)
+	if err != nil {
+		return types.Descriptor{}, "", fmt.Errorf("failed to push layer: %w", err)
+	}
+	return d, digUC.Digest(), nil
+}
+
+// layerAddStep adds a layer with the tar content written by fn to the end of each image.
+// The layer is pushed to the target once for each media type, and createdBy is used for the config history.
+func layerAddStep(dc *dagConfig, createdBy func() string, fn func(tw *tar.Writer) error) {
+	type layerPushed struct {
+		desc     types.Descriptor
+		ucDigest digest.Digest
+	}
+	pushed := map[string]layerPushed{}
+	dc.stepsManifest = append(dc.stepsManifest, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, dm *dagManifest) error {
+		if dm.m.IsList() || dm.mod == deleted || dm.config == nil {
+			return nil
+		}
+		mt := layerMediaType(dm)
+		lp, ok := pushed[mt]
+		if !ok {
+			d, ucDigest, err := layerCreate(ctx, rc, rTgt, mt, fn)
+			if err != nil {
+				return err
+			}
+			lp = layerPushed{desc: d, ucDigest: ucDigest}
+			pushed[mt] = lp
+		}
+		return layerAppend(dm, lp.desc, lp.ucDigest, v1.History{
+			Created:   &timeStart,
+			CreatedBy: createdBy(),
+			Comment:   "regclient",
+		})
+	})
+}
+
+// layerAppend adds a layer to the end of the image, updating the manifest, config, and dag
+func layerAppend(dm *dagManifest, d types.Descriptor, ucDigest digest.Digest, h v1.History) error {
+	mi, ok := dm.m.(manifest.Imager)
+	if !ok {
+		return fmt.Errorf("manifest is not an image")
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return err
+	}
+	err = mi.SetLayers(append(layers, d))
+	if err != nil {
+		return err
+	}
+	// the new layer is only on the target, it is not copied from the source
+	dm.layers = append(dm.layers, &dagLayer{
+		mod:      replaced,
+		ucDigest: ucDigest,
+		desc:     d,
+		newDesc:  d,
+	})
+	oc := dm.config.oc.GetConfig()
+	oc.RootFS.DiffIDs = append(oc.RootFS.DiffIDs, ucDigest)
+	if len(oc.History) > 0 || len(layers) == 0 {
+		oc.History = append(oc.History, h)
+	}
+	dm.config.oc.SetConfig(oc)
+	dm.config.newDesc = dm.config.oc.GetDescriptor()
+	dm.config.modified = true
+	dm.mod = replaced
+	return nil
+}
+
+// layerMediaType returns the media type for a new gzip compressed layer in the image
+func layerMediaType(dm *dagManifest) string {
+	if dm.m.GetDescriptor().MediaType == types.MediaTypeDocker2Manifest {
+		return types.MediaTypeDocker2LayerGzip
+	}
+	return types.MediaTypeOCI1LayerGzip
+}
+
+// layerTarDir writes the contents of a directory to the tar under the target path
+func layerTarDir(tw *tar.Writer, dir, target string) error {
+	return filepath.WalkDir(dir, func(file string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		name := path.Join(target, filepath.ToSlash(rel))
+		if name == "." {
+			return nil
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			link, err = os.Readlink(file)
+			if err != nil {
+				return err
+			}
+		} else if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return fmt.Errorf("unsupported file type for %s: %s", file, fi.Mode().Type().String())
+		}
+		th, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		th.Name = name
+		if fi.IsDir() {
+			th.Name += "/"
+		}
+		th.Uid, th.Gid, th.Uname, th.Gname = 0, 0, "", ""
+		th.AccessTime, th.ChangeTime = time.Time{}, time.Time{}
+		th.Format = tar.FormatPAX
+		err = tw.WriteHeader(th)
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		fh, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		_, err = io.CopyN(tw, fh, th.Size)
+		return err
+	})
+}
+
+// layerTarFile copies the entries from a local tar file, which may be compressed
+func layerTarFile(tw *tar.Writer, file string) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	dr, err := archive.Decompress(fh)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(dr)
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar %s: %w", file, err)
+		}
+		err = tw.WriteHeader(th)
+		if err != nil {
+			return err
+		}
+		if th.Typeflag == tar.TypeReg && th.Size > 0 {
+			_, err = io.CopyN(tw, tr, th.Size)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// layerSquashRead calls fn for each file in a layer with the cleaned filename
+func layerSquashRead(ctx context.Context, rc *regclient.RegClient, r ref.Ref, d types.Descriptor, fn func(th *tar.Header, name string, rdr io.Reader) error) error {
+	br, err := rc.BlobGet(ctx, r, d)
+	if err != nil {
+		return err
+	}
+	defer br.Close()
+	dr, err := archive.Decompress(br)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(dr)
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read layer %s: %w", d.Digest.String(), err)
+		}
+		name := strings.TrimPrefix(path.Clean("/"+th.Name), "/")
+		err = fn(th, name, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
 // WithLayerStripFile removes a file from within the layer tar
 func WithLayerStripFile(file string) Opts {
 	file = strings.Trim(file, "/")
@@ -339,6 +796,39 @@ func WithLayerTimestampMax(t time.Time) Opts {
 	})
 }
 
+// WithLayerWhiteout adds a layer with a whiteout file that deletes a path from the image.
+// The existing layers are not modified, so the content remains in the image blobs, see WithLayerStripFile to remove it.
+// Multiple paths are deleted with a single layer.
+func WithLayerWhiteout(file string) Opts {
+	file = strings.Trim(path.Clean("/"+file), "/")
+	return func(dc *dagConfig, dm *dagManifest) error {
+		if file == "" {
+			return fmt.Errorf("the root directory cannot be deleted")
+		}
+		dc.whiteouts = append(dc.whiteouts, file)
+		if len(dc.whiteouts) > 1 {
+			// the layer is already added, the closure includes every path
+			return nil
+		}
+		layerAddStep(dc, func() string { return "delete /" + strings.Join(dc.whiteouts, " /") }, func(tw *tar.Writer) error {
+			for _, f := range dc.whiteouts {
+				dir, base := path.Split(f)
+				err := tw.WriteHeader(&tar.Header{
+					Typeflag: tar.TypeReg,
+					Name:     dir + ".wh." + base,
+					Mode:     0644,
+					ModTime:  timeStart,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return nil
+	}
+}
+
 // WithFileTarTime processes a tar file within a layer and adjusts the timestamps according to optTime
 func WithFileTarTime(name string, optTime OptTime) Opts {
 	name = strings.TrimPrefix(name, "/")
@@ -0,0 +1,519 @@
+package mod
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/pkg/archive"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// testLayerFile is an entry in a generated layer, directories end with a slash
+type testLayerFile struct {
+	name    string
+	content string
+}
+
+func testLayerPut(t *testing.T, ctx context.Context, rc *regclient.RegClient, r ref.Ref, files []testLayerFile) (types.Descriptor, digest.Digest) {
+	t.Helper()
+	ucBuf := &bytes.Buffer{}
+	tw := tar.NewWriter(ucBuf)
+	for _, f := range files {
+		th := &tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.content))}
+		if strings.HasSuffix(f.name, "/") {
+			th = &tar.Header{Name: f.name, Typeflag: tar.TypeDir, Mode: 0755}
+		}
+		if err := tw.WriteHeader(th); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatalf("failed to write content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	gzBuf := &bytes.Buffer{}
+	gw := gzip.NewWriter(gzBuf)
+	_, _ = gw.Write(ucBuf.Bytes())
+	if err := gw.Close(); err != nil {
+		t.Fatalf("failed to close gzip: %v", err)
+	}
+	d := types.Descriptor{
+		MediaType: types.MediaTypeOCI1LayerGzip,
+		Digest:    digest.FromBytes(gzBuf.Bytes()),
+		Size:      int64(gzBuf.Len()),
+	}
+	if _, err := rc.BlobPut(ctx, r, d, bytes.NewReader(gzBuf.Bytes())); err != nil {
+		t.Fatalf("failed to put layer: %v", err)
+	}
+	return d, digest.FromBytes(ucBuf.Bytes())
+}
+
+// testLayerList returns the files in a layer with the content of regular files
+func testLayerList(t *testing.T, ctx context.Context, rc *regclient.RegClient, r ref.Ref, d types.Descriptor) map[string]string {
+	t.Helper()
+	br, err := rc.BlobGet(ctx, r, d)
+	if err != nil {
+		t.Fatalf("failed to get layer: %v", err)
+	}
+	defer br.Close()
+	dr, err := archive.Decompress(br)
+	if err != nil {
+		t.Fatalf("failed to decompress layer: %v", err)
+	}
+	tr := tar.NewReader(dr)
+	files := map[string]string{}
+	for {
+		th, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("failed to read layer: %v", err)
+		}
+		b, _ := io.ReadAll(tr)
+		files[th.Name] = string(b)
+	}
+}
+
+// testImagePut pushes an image with a layer for each list of files, the history includes an empty layer entry before the last layer
+func testImagePut(t *testing.T, ctx context.Context, rc *regclient.RegClient, r ref.Ref, layerFiles [][]testLayerFile) {
+	t.Helper()
+	layers := []types.Descriptor{}
+	diffIDs := []digest.Digest{}
+	history := []v1.History{}
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, files := range layerFiles {
+		d, ucDig := testLayerPut(t, ctx, rc, r, files)
+		layers = append(layers, d)
+		diffIDs = append(diffIDs, ucDig)
+		if i > 0 && i == len(layerFiles)-1 {
+			history = append(history, v1.History{Created: &created, CreatedBy: "env", EmptyLayer: true})
+		}
+		history = append(history, v1.History{Created: &created, CreatedBy: fmt.Sprintf("layer %d", i+1)})
+	}
+	conf := v1.Image{
+		Platform: platform.Platform{OS: "linux", Architecture: "amd64"},
+		RootFS:   v1.RootFS{Type: "layers", DiffIDs: diffIDs},
+		History:  history,
+	}
+	cb, _ := json.Marshal(conf)
+	cd := types.Descriptor{MediaType: types.MediaTypeOCI1ImageConfig, Digest: digest.FromBytes(cb), Size: int64(len(cb))}
+	if _, err := rc.BlobPut(ctx, r, cd, bytes.NewReader(cb)); err != nil {
+		t.Fatalf("failed to put config: %v", err)
+	}
+	m, err := manifest.New(manifest.WithOrig(v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: types.MediaTypeOCI1Manifest,
+		Config:    cd,
+		Layers:    layers,
+	}))
+	if err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+	if err := rc.ManifestPut(ctx, r, m); err != nil {
+		t.Fatalf("failed to put manifest: %v", err)
+	}
+}
+
+func TestLayerSquash(t *testing.T) {
+	ctx := context.Background()
+	rc := regclient.New(regclient.WithFS(rwfs.MemNew()))
+	r, err := ref.New("ocidir://squash:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	testImagePut(t, ctx, rc, r, [][]testLayerFile{
+		{{name: "dir/"}, {name: "dir/a.txt", content: "a1"}, {name: "dir/b.txt", content: "b1"}, {name: "keep/"}, {name: "keep/x", content: "x"}},
+		{{name: "dir/a.txt", content: "a2"}, {name: "dir/.wh.b.txt"}, {name: "gone/"}, {name: "gone/y", content: "y"}},
+		{{name: ".wh.gone"}, {name: "dir/c.txt", content: "c3"}},
+	})
+
+	tt := []struct {
+		name         string
+		count        int
+		expectLayers int
+		expectFiles  map[string]string
+		expectHist   []string
+	}{
+		{
+			name:         "All",
+			count:        0,
+			expectLayers: 1,
+			expectFiles: map[string]string{
+				"dir/":      "",
+				"dir/a.txt": "a2",
+				"dir/c.txt": "c3",
+				"keep/":     "",
+				"keep/x":    "x",
+			},
+			expectHist: []string{"env", "squash 3 layers"},
+		},
+		{
+			name:         "Top",
+			count:        2,
+			expectLayers: 2,
+			expectFiles: map[string]string{
+				"dir/a.txt":     "a2",
+				"dir/.wh.b.txt": "",
+				".wh.gone":      "",
+				"dir/c.txt":     "c3",
+			},
+			expectHist: []string{"layer 1", "env", "squash 2 layers"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rTgt := r
+			rTgt.Tag = strings.ToLower(tc.name)
+			rOut, err := Apply(ctx, rc, r, WithLayerSquash(tc.count), WithRefTgt(rTgt))
+			if err != nil {
+				t.Fatalf("failed to squash: %v", err)
+			}
+			mOut, err := rc.ManifestGet(ctx, rOut)
+			if err != nil {
+				t.Fatalf("failed to get manifest: %v", err)
+			}
+			mi := mOut.(manifest.Imager)
+			outLayers, _ := mi.GetLayers()
+			if len(outLayers) != tc.expectLayers {
+				t.Fatalf("unexpected layer count: %d", len(outLayers))
+			}
+			files := testLayerList(t, ctx, rc, rOut, outLayers[len(outLayers)-1])
+			if len(files) != len(tc.expectFiles) {
+				t.Errorf("unexpected files, expected %v, received %v", tc.expectFiles, files)
+			}
+			for name, content := range tc.expectFiles {
+				if got, ok := files[name]; !ok || got != content {
+					t.Errorf("unexpected file %s, expected %q, received %q, %t", name, content, got, ok)
+				}
+			}
+			cdOut, _ := mi.GetConfig()
+			oc, err := rc.BlobGetOCIConfig(ctx, rOut, cdOut)
+			if err != nil {
+				t.Fatalf("failed to get config: %v", err)
+			}
+			confOut := oc.GetConfig()
+			if len(confOut.RootFS.DiffIDs) != len(outLayers) {
+				t.Errorf("diff ids do not match layers: %v", confOut.RootFS.DiffIDs)
+			}
+			hist := []string{}
+			for _, h := range confOut.History {
+				hist = append(hist, h.CreatedBy)
+			}
+			if strings.Join(hist, ",") != strings.Join(tc.expectHist, ",") {
+				t.Errorf("unexpected history, expected %v, received %v", tc.expectHist, hist)
+			}
+		})
+	}
+	t.Run("Target", func(t *testing.T) {
+		// the squashed layer is pushed to a different target repository, leaving the source unchanged
+		r, err := ref.New("ocidir://squashsrc:v1")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		testImagePut(t, ctx, rc, r, [][]testLayerFile{
+			{{name: "dir/"}, {name: "dir/a.txt", content: "a1"}, {name: "keep/"}, {name: "keep/x", content: "x"}},
+			{{name: "dir/a.txt", content: "a2"}},
+		})
+		rTgt, err := ref.New("ocidir://squashtgt:v1")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		rOut, err := Apply(ctx, rc, r, WithLayerSquash(0), WithRefTgt(rTgt))
+		if err != nil {
+			t.Fatalf("failed to squash: %v", err)
+		}
+		mOut, err := rc.ManifestGet(ctx, rOut)
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		outLayers, _ := mOut.(manifest.Imager).GetLayers()
+		if len(outLayers) != 1 {
+			t.Fatalf("unexpected layer count: %d", len(outLayers))
+		}
+		_, err = rc.BlobHead(ctx, r, outLayers[0])
+		if err == nil {
+			t.Errorf("squashed layer was pushed to the source")
+		}
+		// file changes read the squashed layer from the target
+		rTgt.Tag = "strip"
+		rOut, err = Apply(ctx, rc, r, WithLayerSquash(0), WithLayerStripFile("/keep/x"), WithRefTgt(rTgt))
+		if err != nil {
+			t.Fatalf("failed to squash and strip: %v", err)
+		}
+		mOut, err = rc.ManifestGet(ctx, rOut)
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		outLayers, _ = mOut.(manifest.Imager).GetLayers()
+		files := testLayerList(t, ctx, rc, rOut, outLayers[0])
+		if _, ok := files["keep/x"]; ok || files["dir/a.txt"] != "a2" {
+			t.Errorf("unexpected files: %v", files)
+		}
+	})
+	t.Run("Combined", func(t *testing.T) {
+		rTgt := r
+		rTgt.Tag = "combined"
+		_, err := Apply(ctx, rc, r, WithLayerRmIndex(0), WithLayerSquash(0), WithRefTgt(rTgt))
+		if !errors.Is(err, types.ErrUnsupported) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestLayerAdd(t *testing.T) {
+	ctx := context.Background()
+	rc := regclient.New(regclient.WithFS(rwfs.MemNew()))
+	r, err := ref.New("ocidir://add:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	testImagePut(t, ctx, rc, r, [][]testLayerFile{
+		{{name: "etc/"}, {name: "etc/secret", content: "password"}, {name: "etc/ssl/"}},
+	})
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "certs", "sub"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "certs", "ca.pem"), []byte("cert"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Symlink("ca.pem", filepath.Join(dir, "certs", "link.pem")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	tarFile := filepath.Join(dir, "layer.tar")
+	fh, err := os.Create(tarFile)
+	if err != nil {
+		t.Fatalf("failed to create tar: %v", err)
+	}
+	tw := tar.NewWriter(fh)
+	_ = tw.WriteHeader(&tar.Header{Name: "app/config.yml", Typeflag: tar.TypeReg, Mode: 0644, Size: 5})
+	_, _ = tw.Write([]byte("debug"))
+	_ = tw.Close()
+	_ = fh.Close()
+
+	tt := []struct {
+		name        string
+		opts        []Opts
+		expectFiles map[string]string
+		expectHist  string
+	}{
+		{
+			name: "Dir",
+			opts: []Opts{WithLayerAddDir(filepath.Join(dir, "certs"), "/etc/ssl/certs")},
+			expectFiles: map[string]string{
+				"etc/ssl/certs/":         "",
+				"etc/ssl/certs/ca.pem":   "cert",
+				"etc/ssl/certs/link.pem": "",
+				"etc/ssl/certs/sub/":     "",
+			},
+			expectHist: "add " + filepath.Join(dir, "certs") + " /etc/ssl/certs",
+		},
+		{
+			name: "Tar",
+			opts: []Opts{WithLayerAddTar(tarFile)},
+			expectFiles: map[string]string{
+				"app/config.yml": "debug",
+			},
+			expectHist: "add " + tarFile,
+		},
+		{
+			name: "Whiteout",
+			opts: []Opts{WithLayerWhiteout("/etc/secret"), WithLayerWhiteout("etc/ssl/")},
+			expectFiles: map[string]string{
+				"etc/.wh.secret": "",
+				"etc/.wh.ssl":    "",
+			},
+			expectHist: "delete /etc/secret /etc/ssl",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rTgt := r
+			rTgt.Tag = strings.ToLower(tc.name)
+			rOut, err := Apply(ctx, rc, r, append(tc.opts, WithRefTgt(rTgt))...)
+			if err != nil {
+				t.Fatalf("failed to add layer: %v", err)
+			}
+			mOut, err := rc.ManifestGet(ctx, rOut)
+			if err != nil {
+				t.Fatalf("failed to get manifest: %v", err)
+			}
+			mi := mOut.(manifest.Imager)
+			outLayers, _ := mi.GetLayers()
+			if len(outLayers) != 2 {
+				t.Fatalf("unexpected layer count: %d", len(outLayers))
+			}
+			files := testLayerList(t, ctx, rc, rOut, outLayers[1])
+			if len(files) != len(tc.expectFiles) {
+				t.Errorf("unexpected files, expected %v, received %v", tc.expectFiles, files)
+			}
+			for name, content := range tc.expectFiles {
+				if got, ok := files[name]; !ok || got != content {
+					t.Errorf("unexpected file %s, expected %q, received %q, %t", name, content, got, ok)
+				}
+			}
+			cdOut, _ := mi.GetConfig()
+			oc, err := rc.BlobGetOCIConfig(ctx, rOut, cdOut)
+			if err != nil {
+				t.Fatalf("failed to get config: %v", err)
+			}
+			confOut := oc.GetConfig()
+			if len(confOut.RootFS.DiffIDs) != 2 || len(confOut.History) != 2 || confOut.History[1].CreatedBy != tc.expectHist {
+				t.Errorf("unexpected config: %v, %v", confOut.RootFS.DiffIDs, confOut.History)
+			}
+		})
+	}
+	t.Run("Target", func(t *testing.T) {
+		// the new layer is pushed to a different target repository, leaving the source unchanged
+		rTgt, err := ref.New("ocidir://addtgt:v1")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		rOut, err := Apply(ctx, rc, r, WithLayerAddDir(filepath.Join(dir, "certs", "sub"), "/opt/sub"), WithRefTgt(rTgt))
+		if err != nil {
+			t.Fatalf("failed to add layer: %v", err)
+		}
+		mOut, err := rc.ManifestGet(ctx, rOut)
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		outLayers, _ := mOut.(manifest.Imager).GetLayers()
+		if len(outLayers) != 2 {
+			t.Fatalf("unexpected layer count: %d", len(outLayers))
+		}
+		_, err = rc.BlobHead(ctx, r, outLayers[1])
+		if err == nil {
+			t.Errorf("new layer was pushed to the source")
+		}
+		_, err = rc.BlobHead(ctx, rOut, outLayers[1])
+		if err != nil {
+			t.Errorf("new layer is missing from the target: %v", err)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		_, err := Apply(ctx, rc, r, WithLayerAddDir(filepath.Join(dir, "missing"), "/"))
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestRebaseBuildpacks(t *testing.T) {
+	ctx := context.Background()
+	rc := regclient.New(regclient.WithFS(rwfs.MemNew()))
+	rBaseOld, _ := ref.New("ocidir://run:v1")
+	rBaseNew, _ := ref.New("ocidir://run:v2")
+	rApp, _ := ref.New("ocidir://app:v1")
+	baseOldFiles := []testLayerFile{{name: "etc/"}, {name: "etc/os-release", content: "v1"}}
+	testImagePut(t, ctx, rc, rBaseOld, [][]testLayerFile{baseOldFiles})
+	testImagePut(t, ctx, rc, rBaseNew, [][]testLayerFile{
+		{{name: "etc/"}, {name: "etc/os-release", content: "v2"}},
+		{{name: "etc/ssl/"}, {name: "etc/ssl/ca.pem", content: "cert"}},
+	})
+	testImagePut(t, ctx, rc, rApp, [][]testLayerFile{
+		baseOldFiles,
+		{{name: "app/"}, {name: "app/main", content: "app"}},
+	})
+	_, oldTop := testLayerPut(t, ctx, rc, rBaseOld, baseOldFiles)
+	label := `{"runImage":{"topLayer":"` + oldTop.String() + `","reference":"run@sha256:old","image":"ocidir://run:v2"},"buildpacks":[{"key":"example"}]}`
+	rApp, err := Apply(ctx, rc, rApp, WithLabel(labelBuildpacksMetadata, label))
+	if err != nil {
+		t.Fatalf("failed to add label: %v", err)
+	}
+	mBaseNew, err := rc.ManifestGet(ctx, rBaseNew)
+	if err != nil {
+		t.Fatalf("failed to get base: %v", err)
+	}
+	confBaseNew, err := rc.BlobGetOCIConfig(ctx, rBaseNew, mustConfig(t, mBaseNew))
+	if err != nil {
+		t.Fatalf("failed to get base config: %v", err)
+	}
+	diffIDsNew := confBaseNew.GetConfig().RootFS.DiffIDs
+
+	rOut, err := Apply(ctx, rc, rApp, WithRebase())
+	if err != nil {
+		t.Fatalf("failed to rebase: %v", err)
+	}
+	mOut, err := rc.ManifestGet(ctx, rOut)
+	if err != nil {
+		t.Fatalf("failed to get manifest: %v", err)
+	}
+	mi := mOut.(manifest.Imager)
+	outLayers, _ := mi.GetLayers()
+	baseLayers, _ := mBaseNew.(manifest.Imager).GetLayers()
+	if len(outLayers) != 3 || outLayers[0].Digest != baseLayers[0].Digest || outLayers[1].Digest != baseLayers[1].Digest {
+		t.Fatalf("unexpected layers: %v", outLayers)
+	}
+	if files := testLayerList(t, ctx, rc, rOut, outLayers[2]); files["app/main"] != "app" {
+		t.Errorf("app layer not preserved: %v", files)
+	}
+	confOut, err := rc.BlobGetOCIConfig(ctx, rOut, mustConfig(t, mOut))
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	oc := confOut.GetConfig()
+	if len(oc.RootFS.DiffIDs) != 3 || oc.RootFS.DiffIDs[0] != diffIDsNew[0] || oc.RootFS.DiffIDs[1] != diffIDsNew[1] {
+		t.Errorf("unexpected diff_ids: %v", oc.RootFS.DiffIDs)
+	}
+	if len(oc.History) != 5 || oc.History[2].CreatedBy != "layer 2" || oc.History[3].CreatedBy != "env" || oc.History[4].CreatedBy != "layer 2" {
+		t.Errorf("unexpected history: %v", oc.History)
+	}
+	meta := struct {
+		RunImage   buildpacksRunImage `json:"runImage"`
+		Buildpacks []interface{}      `json:"buildpacks"`
+	}{}
+	if err := json.Unmarshal([]byte(oc.Config.Labels[labelBuildpacksMetadata]), &meta); err != nil {
+		t.Fatalf("failed to parse label: %v", err)
+	}
+	if meta.RunImage.TopLayer != diffIDsNew[1].String() || !strings.HasSuffix(meta.RunImage.Reference, "@"+mBaseNew.GetDescriptor().Digest.String()) || meta.RunImage.Image != "ocidir://run:v2" || len(meta.Buildpacks) != 1 {
+		t.Errorf("unexpected label: %s", oc.Config.Labels[labelBuildpacksMetadata])
+	}
+	// rebasing again is a noop
+	rAgain, err := Apply(ctx, rc, rOut, WithRebaseTopLayer(rBaseNew, diffIDsNew[1]))
+	if err != nil {
+		t.Fatalf("failed to rebase again: %v", err)
+	}
+	if rAgain.Digest != rOut.Digest {
+		t.Errorf("second rebase changed the image, %s != %s", rAgain.Digest, rOut.Digest)
+	}
+	// an unknown top layer is an error
+	_, err = Apply(ctx, rc, rApp, WithRebaseTopLayer(rBaseNew, digest.FromString("missing")))
+	if !errors.Is(err, types.ErrMismatch) {
+		t.Errorf("expected mismatch, received %v", err)
+	}
+}
+
+func mustConfig(t *testing.T, m manifest.Manifest) types.Descriptor {
+	t.Helper()
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		t.Fatalf("manifest is not an image")
+	}
+	cd, err := mi.GetConfig()
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	return cd
+}
@@ -2,7 +2,9 @@ package mod
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/opencontainers/go-digest"
@@ -10,6 +12,7 @@ import (
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/docker/schema2"
 	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
 	"github.com/regclient/regclient/types/platform"
 	"github.com/regclient/regclient/types/ref"
 )
@@ -21,25 +24,9 @@ import (
 func WithAnnotation(name, value string) Opts {
 	return func(dc *dagConfig, dm *dagManifest) error {
 		// extract the list for platforms to update from the name
-		name = strings.TrimSpace(name)
-		platforms := []platform.Platform{}
-		allPlatforms := false
-		if name[0] == '[' && strings.Index(name, "]") > 0 {
-			end := strings.Index(name, "]")
-			list := strings.Split(name[1:end], ",")
-			for _, entry := range list {
-				entry = strings.TrimSpace(entry)
-				if entry == "*" {
-					allPlatforms = true
-					continue
-				}
-				p, err := platform.Parse(entry)
-				if err != nil {
-					return fmt.Errorf("failed to parse annotation platform %s: %w", entry, err)
-				}
-				platforms = append(platforms, p)
-			}
-			name = name[end+1:]
+		name, platforms, allPlatforms, err := annotationPlatforms(name)
+		if err != nil {
+			return err
 		}
 		dc.stepsManifest = append(dc.stepsManifest, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, dm *dagManifest) error {
 			// skip deleted manifest, those not in the platform list, or the non-top manifest if no platform list provided
@@ -91,6 +78,168 @@ func WithAnnotation(name, value string) Opts {
 	}
 }
 
+// WithAnnotationDescriptor adds an annotation to the descriptors in a manifest list, or deletes it when the value is empty.
+// Name may be prefixed with a list of platforms "[p1,p2,...]name" to only modify matching descriptors,
+// otherwise every descriptor in the manifest list is modified.
+func WithAnnotationDescriptor(name, value string) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		name, platforms, allPlatforms, err := annotationPlatforms(name)
+		if err != nil {
+			return err
+		}
+		if len(platforms) == 0 {
+			allPlatforms = true
+		}
+		dc.stepsManifest = append(dc.stepsManifest, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, dm *dagManifest) error {
+			if dm.mod == deleted || !dm.m.IsList() {
+				return nil
+			}
+			om := dm.m.GetOrig()
+			ociI, err := manifest.OCIIndexFromAny(om)
+			if err != nil {
+				return err
+			}
+			changed := false
+			for i, d := range ociI.Manifests {
+				if !allPlatforms {
+					if d.Platform == nil {
+						continue
+					}
+					found := false
+					for _, pe := range platforms {
+						if platform.Match(*d.Platform, pe) {
+							found = true
+							break
+						}
+					}
+					if !found {
+						continue
+					}
+				}
+				cur, ok := d.Annotations[name]
+				if (value == "" && !ok) || (value != "" && value == cur) {
+					continue
+				}
+				if value == "" {
+					delete(ociI.Manifests[i].Annotations, name)
+				} else {
+					if ociI.Manifests[i].Annotations == nil {
+						ociI.Manifests[i].Annotations = map[string]string{}
+					}
+					ociI.Manifests[i].Annotations[name] = value
+				}
+				changed = true
+			}
+			if !changed {
+				return nil
+			}
+			err = manifest.OCIIndexToAny(ociI, &om)
+			if err != nil {
+				return err
+			}
+			err = dm.m.SetOrig(om)
+			if err != nil {
+				return err
+			}
+			dm.mod = replaced
+			dm.newDesc = dm.m.GetDescriptor()
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithAnnotationRmRegex deletes annotations with a name matching the regexp from every manifest,
+// including the descriptors in a manifest list.
+func WithAnnotationRmRegex(re *regexp.Regexp) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		dc.stepsManifest = append(dc.stepsManifest, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, dm *dagManifest) error {
+			if dm.mod == deleted {
+				return nil
+			}
+			changed := false
+			// delete annotations from the descriptors in a manifest list
+			if dm.m.IsList() {
+				om := dm.m.GetOrig()
+				ociI, err := manifest.OCIIndexFromAny(om)
+				if err != nil {
+					return err
+				}
+				for i := range ociI.Manifests {
+					for name := range ociI.Manifests[i].Annotations {
+						if re.MatchString(name) {
+							delete(ociI.Manifests[i].Annotations, name)
+							changed = true
+						}
+					}
+				}
+				if changed {
+					err = manifest.OCIIndexToAny(ociI, &om)
+					if err != nil {
+						return err
+					}
+					err = dm.m.SetOrig(om)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			// delete annotations from the manifest
+			ma, ok := dm.m.(manifest.Annotator)
+			if ok {
+				annotations, err := ma.GetAnnotations()
+				if err != nil {
+					return err
+				}
+				names := []string{}
+				for name := range annotations {
+					if re.MatchString(name) {
+						names = append(names, name)
+					}
+				}
+				for _, name := range names {
+					err = ma.SetAnnotation(name, "")
+					if err != nil {
+						return err
+					}
+					changed = true
+				}
+			}
+			if changed {
+				dm.mod = replaced
+				dm.newDesc = dm.m.GetDescriptor()
+			}
+			return nil
+		})
+		return nil
+	}
+}
+
+// annotationPlatforms extracts the optional "[p1,p2,...]" platform selector from an annotation name
+func annotationPlatforms(name string) (string, []platform.Platform, bool, error) {
+	name = strings.TrimSpace(name)
+	platforms := []platform.Platform{}
+	allPlatforms := false
+	if len(name) > 0 && name[0] == '[' && strings.Index(name, "]") > 0 {
+		end := strings.Index(name, "]")
+		list := strings.Split(name[1:end], ",")
+		for _, entry := range list {
+			entry = strings.TrimSpace(entry)
+			if entry == "*" {
+				allPlatforms = true
+				continue
+			}
+			p, err := platform.Parse(entry)
+			if err != nil {
+				return name, nil, false, fmt.Errorf("failed to parse annotation platform %s: %w", entry, err)
+			}
+			platforms = append(platforms, p)
+		}
+		name = name[end+1:]
+	}
+	return name, platforms, allPlatforms, nil
+}
+
 // WithAnnotationOCIBase adds annotations for the base image
 func WithAnnotationOCIBase(rBase ref.Ref, dBase digest.Digest) Opts {
 	return func(dc *dagConfig, dm *dagManifest) error {
@@ -340,11 +489,6 @@ func WithManifestToOCI() Opts {
 	}
 }
 
-const (
-	dockerReferenceType   = "vnd.docker.reference.type"
-	dockerReferenceDigest = "vnd.docker.reference.digest"
-)
-
 // WithManifestToOCIReferrers converts other referrer types to OCI subject/referrers
 func WithManifestToOCIReferrers() Opts {
 	return func(dc *dagConfig, dm *dagManifest) error {
@@ -381,13 +525,13 @@ func WithManifestToOCIReferrers() Opts {
 					}
 					desc := ml[mlI]
 					mlI++
-					if len(desc.Annotations) == 0 || desc.Annotations[dockerReferenceType] == "" || desc.Annotations[dockerReferenceDigest] == "" {
+					if len(desc.Annotations) == 0 || desc.Annotations[types.AnnotationDockerReferenceType] == "" || desc.Annotations[types.AnnotationDockerReferenceDigest] == "" {
 						continue
 					}
 					// find the subjectDM
-					subjectDM, ok := dmLU[desc.Annotations[dockerReferenceDigest]]
+					subjectDM, ok := dmLU[desc.Annotations[types.AnnotationDockerReferenceDigest]]
 					if !ok || subjectDM == nil {
-						return fmt.Errorf("could not find digest, convert referrers before other mod actions, digest=%s", desc.Annotations[dockerReferenceDigest])
+						return fmt.Errorf("could not find digest, convert referrers before other mod actions, digest=%s", desc.Annotations[types.AnnotationDockerReferenceDigest])
 					}
 					// validate the manifest being converted
 					_, ok = childDM.m.(manifest.Subjecter)
@@ -398,7 +542,7 @@ func WithManifestToOCIReferrers() Opts {
 					if !ok {
 						return fmt.Errorf("docker reference type does not support annotations, mt=%s", childDM.m.GetDescriptor().MediaType)
 					}
-					err := am.SetAnnotation(dockerReferenceType, desc.Annotations[dockerReferenceType])
+					err := am.SetAnnotation(types.AnnotationDockerReferenceType, desc.Annotations[types.AnnotationDockerReferenceType])
 					if err != nil {
 						return fmt.Errorf("failed to set annotations: %w", err)
 					}
@@ -489,22 +633,117 @@ func WithExternalURLsRm() Opts {
 	}
 }
 
-func WithRebase() Opts {
+// WithPlatformDrop deletes the platforms from a manifest list.
+// Attestations referencing the deleted platforms with the docker reference annotation are also deleted.
+func WithPlatformDrop(platforms []platform.Platform) Opts {
 	return func(dc *dagConfig, dm *dagManifest) error {
-		ma, ok := dm.m.(manifest.Annotator)
+		if len(platforms) == 0 {
+			return fmt.Errorf("at least one platform is required")
+		}
+		return platformPruneAddStep(dc, func() []platform.Platform { return platforms }, false)
+	}
+}
+
+// WithPlatformKeep deletes every platform from a manifest list that is not in the list of platforms.
+// Attestations referencing the kept platforms, and descriptors without a platform, are also kept.
+// Multiple uses of this option keep the platforms from each list.
+func WithPlatformKeep(platforms []platform.Platform) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		if len(platforms) == 0 {
+			return fmt.Errorf("at least one platform is required")
+		}
+		dc.platformsKeep = append(dc.platformsKeep, platforms...)
+		if len(dc.platformsKeep) > len(platforms) {
+			// the step is already added and uses the full list
+			return nil
+		}
+		return platformPruneAddStep(dc, func() []platform.Platform { return dc.platformsKeep }, true)
+	}
+}
+
+func platformPruneAddStep(dc *dagConfig, platformsFn func() []platform.Platform, keep bool) error {
+	dc.stepsManifest = append(dc.stepsManifest, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, dm *dagManifest) error {
+		if dm.mod == deleted || !dm.m.IsList() {
+			return nil
+		}
+		mi, ok := dm.m.(manifest.Indexer)
 		if !ok {
-			return fmt.Errorf("rebased failed, manifest does not support annotations")
+			return fmt.Errorf("manifest list is not an indexer")
 		}
-		annot, err := ma.GetAnnotations()
+		dl, err := mi.GetManifestList()
 		if err != nil {
-			return fmt.Errorf("failed getting annotations: %w", err)
+			return err
 		}
-		baseName, ok := annot[types.AnnotationBaseImageName]
-		if !ok {
-			return fmt.Errorf("annotation for base image is missing (%s or %s)%.0w", types.AnnotationBaseImageName, types.AnnotationBaseImageDigest, types.ErrMissingAnnotation)
+		if len(dl) != len(dm.manifests) {
+			return fmt.Errorf("manifest list does not match the child manifests%.0w", types.ErrMismatch)
 		}
-		baseDigest, ok := annot[types.AnnotationBaseImageDigest]
-		if !ok {
+		// first pass removes images by platform
+		dropped := map[digest.Digest]bool{}
+		for i, d := range dl {
+			if d.Platform == nil || d.Annotations[types.AnnotationDockerReferenceDigest] != "" {
+				continue
+			}
+			found := false
+			for _, p := range platformsFn() {
+				if platform.Match(*d.Platform, p) {
+					found = true
+					break
+				}
+			}
+			if found != keep {
+				dropped[d.Digest] = true
+				dm.manifests[i].mod = deleted
+			}
+		}
+		if len(dropped) == 0 {
+			return nil
+		}
+		// second pass removes attestations that reference the dropped images
+		remain := 0
+		for i, d := range dl {
+			if dm.manifests[i].mod == deleted {
+				continue
+			}
+			if dig, err := digest.Parse(d.Annotations[types.AnnotationDockerReferenceDigest]); err == nil && dropped[dig] {
+				dm.manifests[i].mod = deleted
+				continue
+			}
+			remain++
+		}
+		if remain == 0 {
+			return fmt.Errorf("every platform would be removed from the manifest list%.0w", types.ErrNotFound)
+		}
+		dm.mod = replaced
+		return nil
+	})
+	return nil
+}
+
+// WithRebase swaps the base image layers using the base image annotations on the manifest.
+// Images built by buildpacks are rebased with the run image from the lifecycle metadata label,
+// where the old base layers are located by the top layer diff_id.
+func WithRebase() Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		annot := map[string]string{}
+		if ma, ok := dm.m.(manifest.Annotator); ok {
+			var err error
+			annot, err = ma.GetAnnotations()
+			if err != nil {
+				return fmt.Errorf("failed getting annotations: %w", err)
+			}
+		}
+		baseName, okName := annot[types.AnnotationBaseImageName]
+		baseDigest, okDigest := annot[types.AnnotationBaseImageDigest]
+		if !okName || !okDigest {
+			if dm.config != nil {
+				if _, ok := dm.config.oc.GetConfig().Config.Labels[labelBuildpacksMetadata]; ok {
+					rNew, topLayer, err := rebaseBuildpacks(dm.config.oc.GetConfig())
+					if err != nil {
+						return err
+					}
+					return rebaseTopLayerAddStep(dc, rNew, topLayer)
+				}
+			}
 			return fmt.Errorf("annotation for base image is missing (%s or %s)%.0w", types.AnnotationBaseImageName, types.AnnotationBaseImageDigest, types.ErrMissingAnnotation)
 		}
 		rNew, err := ref.New(baseName)
@@ -555,78 +794,16 @@ func rebaseAddStep(dc *dagConfig, rBaseOld, rBaseNew ref.Ref) error {
 		if mbOldCache.GetDescriptor().Equal(mbNewCache.GetDescriptor()) {
 			return nil
 		}
-		// get the platform from the config, pull the relevant manifest
-		oc := dm.config.oc.GetConfig()
-		p := platform.Platform{
-			OS:           oc.OS,
-			Architecture: oc.Architecture,
-			Variant:      oc.Variant,
-			OSVersion:    oc.OSVersion,
-			OSFeatures:   oc.OSFeatures,
-			Features:     oc.OSFeatures,
-		}
-		mbOld := mbOldCache
-		if mbOld.IsList() {
-			d, err := manifest.GetPlatformDesc(mbOld, &p)
-			if err != nil {
-				return err
-			}
-			rp := rBaseOld
-			rp.Digest = d.Digest.String()
-			mbOld, err = rc.ManifestGet(ctx, rp)
-			if err != nil {
-				return err
-			}
-		}
-		mbNew := mbNewCache
-		if mbNew.IsList() {
-			d, err := manifest.GetPlatformDesc(mbNew, &p)
-			if err != nil {
-				return err
-			}
-			rp := rBaseNew
-			rp.Digest = d.Digest.String()
-			mbNew, err = rc.ManifestGet(ctx, rp)
-			if err != nil {
-				return err
-			}
-		}
-		// load layers and config from image and old/new base images
-		imageOld, ok := mbOld.(manifest.Imager)
-		if !ok {
-			return fmt.Errorf("base original image is not an image")
-		}
-		layersOld, err := imageOld.GetLayers()
+		// load layers and config from old/new base images for the platform of the image
+		p := rebasePlatform(dm.config.oc.GetConfig())
+		_, layersOld, confOCIOld, err := rebaseBaseGet(ctx, rc, rBaseOld, mbOldCache, p)
 		if err != nil {
-			return err
-		}
-		cdOld, err := imageOld.GetConfig()
-		if err != nil {
-			return err
-		}
-		confOld, err := rc.BlobGetOCIConfig(ctx, rBaseOld, cdOld)
-		if err != nil {
-			return err
-		}
-		confOCIOld := confOld.GetConfig()
-
-		imageNew, ok := mbNew.(manifest.Imager)
-		if !ok {
-			return fmt.Errorf("base new image is not an image")
+			return fmt.Errorf("base original image: %w", err)
 		}
-		layersNew, err := imageNew.GetLayers()
+		_, layersNew, confOCINew, err := rebaseBaseGet(ctx, rc, rBaseNew, mbNewCache, p)
 		if err != nil {
-			return err
-		}
-		cdNew, err := imageNew.GetConfig()
-		if err != nil {
-			return err
+			return fmt.Errorf("base new image: %w", err)
 		}
-		confNew, err := rc.BlobGetOCIConfig(ctx, rBaseNew, cdNew)
-		if err != nil {
-			return err
-		}
-		confOCINew := confNew.GetConfig()
 
 		mi, ok := dm.m.(manifest.Imager)
 		if !ok {
@@ -741,3 +918,249 @@ func rebaseAddStep(dc *dagConfig, rBaseOld, rBaseNew ref.Ref) error {
 	})
 	return nil
 }
+
+// labelBuildpacksMetadata is the config label with the run image used by buildpacks
+const labelBuildpacksMetadata = "io.buildpacks.lifecycle.metadata"
+
+// buildpacksRunImage is the run image in the buildpacks lifecycle metadata
+type buildpacksRunImage struct {
+	TopLayer  string `json:"topLayer"`
+	Reference string `json:"reference"`
+	Image     string `json:"image,omitempty"`
+}
+
+// rebaseBuildpacks returns the run image name and top layer diff_id from the buildpacks lifecycle metadata
+func rebaseBuildpacks(oc v1.Image) (ref.Ref, digest.Digest, error) {
+	meta := struct {
+		RunImage buildpacksRunImage `json:"runImage"`
+		Stack    struct {
+			RunImage struct {
+				Image string `json:"image"`
+			} `json:"runImage"`
+		} `json:"stack"`
+	}{}
+	err := json.Unmarshal([]byte(oc.Config.Labels[labelBuildpacksMetadata]), &meta)
+	if err != nil {
+		return ref.Ref{}, "", fmt.Errorf("failed to parse %s label: %w", labelBuildpacksMetadata, err)
+	}
+	name := meta.RunImage.Image
+	if name == "" {
+		name = meta.Stack.RunImage.Image
+	}
+	if name == "" || meta.RunImage.TopLayer == "" {
+		return ref.Ref{}, "", fmt.Errorf("run image is missing from the %s label%.0w", labelBuildpacksMetadata, types.ErrMissingAnnotation)
+	}
+	rNew, err := ref.New(name)
+	if err != nil {
+		return ref.Ref{}, "", fmt.Errorf("failed to parse run image: %w", err)
+	}
+	topLayer, err := digest.Parse(meta.RunImage.TopLayer)
+	if err != nil {
+		return ref.Ref{}, "", fmt.Errorf("failed to parse run image top layer: %w", err)
+	}
+	return rNew, topLayer, nil
+}
+
+// WithRebaseTopLayer swaps the base image layers for the layers of a new base image.
+// The old base layers are every layer up to and including the topLayer diff_id, the same matching used by buildpacks.
+// This is used when the old base image is not available, only the diff_id of its top layer.
+func WithRebaseTopLayer(rNew ref.Ref, topLayer digest.Digest) Opts {
+	return func(dc *dagConfig, dm *dagManifest) error {
+		return rebaseTopLayerAddStep(dc, rNew, topLayer)
+	}
+}
+
+func rebaseTopLayerAddStep(dc *dagConfig, rBaseNew ref.Ref, topLayer digest.Digest) error {
+	var mbNewCache manifest.Manifest
+	dc.stepsManifest = append(dc.stepsManifest, func(ctx context.Context, rc *regclient.RegClient, rSrc, rTgt ref.Ref, dm *dagManifest) error {
+		// skip if manifest list or deleted
+		if dm.m.IsList() || dm.mod == deleted || dm.config == nil {
+			return nil
+		}
+		var err error
+		if mbNewCache == nil {
+			mbNewCache, err = rc.ManifestGet(ctx, rBaseNew)
+			if err != nil {
+				return err
+			}
+		}
+		confOCI := dm.config.oc.GetConfig()
+		mbNew, layersNew, confOCINew, err := rebaseBaseGet(ctx, rc, rBaseNew, mbNewCache, rebasePlatform(confOCI))
+		if err != nil {
+			return fmt.Errorf("base new image: %w", err)
+		}
+		mi, ok := dm.m.(manifest.Imager)
+		if !ok {
+			return fmt.Errorf("manifest is not an image")
+		}
+		layers, err := mi.GetLayers()
+		if err != nil {
+			return err
+		}
+		if len(layers) != len(confOCI.RootFS.DiffIDs) || len(layers) != len(dm.layers) {
+			return fmt.Errorf("image layers do not match the config rootfs%.0w", types.ErrMismatch)
+		}
+		// locate the old base layers
+		baseLayers := -1
+		for i, d := range confOCI.RootFS.DiffIDs {
+			if d == topLayer {
+				baseLayers = i + 1
+				break
+			}
+		}
+		if baseLayers < 0 {
+			return fmt.Errorf("base image top layer %s not found in image%.0w", topLayer.String(), types.ErrMismatch)
+		}
+		// validate new base
+		historyLayers := 0
+		for i := range confOCINew.History {
+			if !confOCINew.History[i].EmptyLayer {
+				historyLayers++
+			}
+		}
+		if len(layersNew) != len(confOCINew.RootFS.DiffIDs) || (len(confOCINew.History) > 0 && historyLayers != len(layersNew)) {
+			return fmt.Errorf("new base image config history doesn't match layer count%.0w", types.ErrMismatch)
+		}
+		if len(layersNew) == 0 {
+			return fmt.Errorf("new base image has no layers%.0w", types.ErrMismatch)
+		}
+		// skip if already based on the new image
+		newTop := confOCINew.RootFS.DiffIDs[len(confOCINew.RootFS.DiffIDs)-1]
+		if newTop == topLayer && baseLayers == len(layersNew) {
+			return nil
+		}
+		// locate the history entries for the old base, trailing empty layer entries are kept with the image
+		baseHistory := -1
+		historyLayers = 0
+		for i, h := range confOCI.History {
+			if h.EmptyLayer {
+				continue
+			}
+			historyLayers++
+			if historyLayers == baseLayers {
+				baseHistory = i + 1
+				break
+			}
+		}
+		if len(confOCI.History) > 0 && baseHistory < 0 {
+			return fmt.Errorf("image history doesn't match layer count%.0w", types.ErrMismatch)
+		}
+		// copy blobs from new base to repo
+		for _, d := range layersNew {
+			if err := rc.BlobCopy(ctx, rBaseNew, rSrc, d); err != nil {
+				return fmt.Errorf("failed copying blobs for rebase: %w", err)
+			}
+		}
+		// replace the base layers in the dag, manifest, and config
+		dagAdd := []*dagLayer{}
+		for i, l := range layersNew {
+			dagAdd = append(dagAdd, &dagLayer{
+				mod:      unchanged,
+				ucDigest: confOCINew.RootFS.DiffIDs[i],
+				desc:     l,
+			})
+		}
+		dm.layers = append(dagAdd, dm.layers[baseLayers:]...)
+		err = mi.SetLayers(append(append([]types.Descriptor{}, layersNew...), layers[baseLayers:]...))
+		if err != nil {
+			return err
+		}
+		confOCI.RootFS.DiffIDs = append(append([]digest.Digest{}, confOCINew.RootFS.DiffIDs...), confOCI.RootFS.DiffIDs[baseLayers:]...)
+		if baseHistory >= 0 {
+			confOCI.History = append(append([]v1.History{}, confOCINew.History...), confOCI.History[baseHistory:]...)
+		}
+		// update the buildpacks metadata to the new run image
+		if _, ok := confOCI.Config.Labels[labelBuildpacksMetadata]; ok {
+			rRun := rBaseNew
+			rRun.Tag = ""
+			rRun.Digest = mbNew.GetDescriptor().Digest.String()
+			label, err := rebaseBuildpacksUpdate(confOCI.Config.Labels[labelBuildpacksMetadata], newTop, rRun)
+			if err != nil {
+				return err
+			}
+			labels := map[string]string{}
+			for k, v := range confOCI.Config.Labels {
+				labels[k] = v
+			}
+			labels[labelBuildpacksMetadata] = label
+			confOCI.Config.Labels = labels
+		}
+		dm.config.oc.SetConfig(confOCI)
+		dm.config.newDesc = dm.config.oc.GetDescriptor()
+
+		// set modified flags on config and manifest
+		dm.config.modified = true
+		dm.mod = replaced
+		return nil
+	})
+	return nil
+}
+
+// rebaseBuildpacksUpdate sets the run image top layer and reference in the buildpacks lifecycle metadata, preserving other fields
+func rebaseBuildpacksUpdate(label string, topLayer digest.Digest, r ref.Ref) (string, error) {
+	meta := map[string]json.RawMessage{}
+	err := json.Unmarshal([]byte(label), &meta)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s label: %w", labelBuildpacksMetadata, err)
+	}
+	runImage := map[string]interface{}{}
+	if len(meta["runImage"]) > 0 {
+		err = json.Unmarshal(meta["runImage"], &runImage)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s label: %w", labelBuildpacksMetadata, err)
+		}
+	}
+	runImage["topLayer"] = topLayer.String()
+	runImage["reference"] = r.CommonName()
+	meta["runImage"], err = json.Marshal(runImage)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(meta)
+	return string(b), err
+}
+
+// rebasePlatform returns the platform of an image config
+func rebasePlatform(oc v1.Image) platform.Platform {
+	return platform.Platform{
+		OS:           oc.OS,
+		Architecture: oc.Architecture,
+		Variant:      oc.Variant,
+		OSVersion:    oc.OSVersion,
+		OSFeatures:   oc.OSFeatures,
+		Features:     oc.OSFeatures,
+	}
+}
+
+// rebaseBaseGet returns the manifest, layers, and config of a base image, resolving a manifest list to the platform
+func rebaseBaseGet(ctx context.Context, rc *regclient.RegClient, r ref.Ref, m manifest.Manifest, p platform.Platform) (manifest.Manifest, []types.Descriptor, v1.Image, error) {
+	if m.IsList() {
+		d, err := manifest.GetPlatformDesc(m, &p)
+		if err != nil {
+			return nil, nil, v1.Image{}, err
+		}
+		rp := r
+		rp.Digest = d.Digest.String()
+		m, err = rc.ManifestGet(ctx, rp)
+		if err != nil {
+			return nil, nil, v1.Image{}, err
+		}
+	}
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return nil, nil, v1.Image{}, fmt.Errorf("manifest is not an image")
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return nil, nil, v1.Image{}, err
+	}
+	cd, err := mi.GetConfig()
+	if err != nil {
+		return nil, nil, v1.Image{}, err
+	}
+	conf, err := rc.BlobGetOCIConfig(ctx, r, cd)
+	if err != nil {
+		return nil, nil, v1.Image{}, err
+	}
+	return m, layers, conf.GetConfig(), nil
+}
@@ -112,7 +112,12 @@ func Apply(ctx context.Context, rc *regclient.RegClient, rSrc ref.Ref, opts ...O
 				return dl, nil
 			}
 			if len(dc.stepsLayerFile) > 0 && dl.mod != deleted && inListStr(dl.desc.MediaType, mtWLTar) {
-				br, err := rc.BlobGet(ctx, rSrc, dl.desc)
+				// layers replaced by an earlier step have already been pushed to the target
+				rLayer := rSrc
+				if dl.mod == replaced {
+					rLayer = rTgt
+				}
+				br, err := rc.BlobGet(ctx, rLayer, dl.desc)
 				if err != nil {
 					return nil, err
 				}
@@ -269,3 +274,15 @@ func inListStr(str string, list []string) bool {
 	}
 	return false
 }
+
+func strSliceEq(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
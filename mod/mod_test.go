@@ -168,6 +168,36 @@ func TestMod(t *testing.T) {
 			ref:     "ocidir://testrepo:v1",
 			wantErr: fmt.Errorf("failed to parse annotation platform linux/invalid.arch!: invalid platform component invalid.arch! in linux/invalid.arch!"),
 		},
+		{
+			name: "Add Descriptor Annotation",
+			opts: []Opts{
+				WithAnnotationDescriptor("test", "hello"),
+			},
+			ref: "ocidir://testrepo:v1",
+		},
+		{
+			name: "Add Descriptor Annotation AMD64",
+			opts: []Opts{
+				WithAnnotationDescriptor("[linux/amd64]test", "hello"),
+			},
+			ref: "ocidir://testrepo:v1",
+		},
+		{
+			name: "Add Descriptor Annotation Missing",
+			opts: []Opts{
+				WithAnnotationDescriptor("[linux/s390x]test", "hello"),
+			},
+			ref:      "ocidir://testrepo:v1",
+			wantSame: true,
+		},
+		{
+			name: "Delete Descriptor Annotation Missing",
+			opts: []Opts{
+				WithAnnotationDescriptor("test", ""),
+			},
+			ref:      "ocidir://testrepo:v1",
+			wantSame: true,
+		},
 		{
 			name: "Delete Annotation",
 			opts: []Opts{
@@ -242,6 +272,22 @@ func TestMod(t *testing.T) {
 			ref:      "ocidir://testrepo:v1",
 			wantSame: true,
 		},
+		{
+			name: "Label Rm Regex",
+			opts: []Opts{
+				WithLabelRmRegex(regexp.MustCompile(`^arg_`)),
+			},
+			ref: "ocidir://testrepo:v1",
+		},
+		{
+			name: "Label Rm Regex Unchanged",
+			opts: []Opts{
+				WithLabelRmRegex(regexp.MustCompile(`^com\.example\.`)),
+				WithAnnotationRmRegex(regexp.MustCompile(`^com\.example\.`)),
+			},
+			ref:      "ocidir://testrepo:v1",
+			wantSame: true,
+		},
 		{
 			name: "Label to Annotation",
 			opts: []Opts{
@@ -348,6 +394,60 @@ func TestMod(t *testing.T) {
 			ref:      "ocidir://testrepo:v1",
 			wantSame: true,
 		},
+		{
+			name: "Cmd",
+			opts: []Opts{
+				WithCmd([]string{"sh", "-c", "echo hello"}),
+			},
+			ref: "ocidir://testrepo:v1",
+		},
+		{
+			name: "Cmd Unchanged",
+			opts: []Opts{
+				WithCmd([]string{"sh"}),
+			},
+			ref:      "ocidir://testrepo:v3",
+			wantSame: true,
+		},
+		{
+			name: "Entrypoint",
+			opts: []Opts{
+				WithEntrypoint([]string{"/app"}),
+				WithCmd(nil),
+			},
+			ref: "ocidir://testrepo:v3",
+		},
+		{
+			name: "Env",
+			opts: []Opts{
+				WithEnv("HTTP_PROXY", "http://proxy.example.org:3128"),
+			},
+			ref: "ocidir://testrepo:v1",
+		},
+		{
+			name: "Env Unchanged",
+			opts: []Opts{
+				WithEnv("PATH", "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"),
+				WithEnvRm("MISSING"),
+			},
+			ref:      r3amd.CommonName(),
+			wantSame: true,
+		},
+		{
+			name: "Env Rm",
+			opts: []Opts{
+				WithEnvRm("PATH"),
+			},
+			ref: "ocidir://testrepo:v1",
+		},
+		{
+			name: "Env Invalid",
+			opts: []Opts{
+				WithEnv("A=B", "C"),
+			},
+			ref:     "ocidir://testrepo:v1",
+			wantErr: types.ErrParsingFailed,
+		},
 		{
 			name: "Expose Port",
 			opts: []Opts{
@@ -591,6 +691,21 @@ func TestMod(t *testing.T) {
 			ref:     r3amd.CommonName(),
 			wantErr: fmt.Errorf("layer not found"),
 		},
+		{
+			name: "User",
+			opts: []Opts{
+				WithUser("1000:1000"),
+			},
+			ref: "ocidir://testrepo:v1",
+		},
+		{
+			name: "User Unchanged",
+			opts: []Opts{
+				WithUser(""),
+			},
+			ref:      "ocidir://testrepo:v1",
+			wantSame: true,
+		},
 		{
 			name: "Add volume",
 			opts: []Opts{
@@ -755,3 +870,146 @@ func TestInList(t *testing.T) {
 		}
 	})
 }
+
+func TestMetadataRmRegex(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "../testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	rc := regclient.New(regclient.WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	// add internal metadata to the index, descriptors, manifests, and configs
+	rAdd, err := Apply(ctx, rc, r,
+		WithAnnotation("[*]com.example.internal.build", "1234"),
+		WithAnnotation("[*]org.opencontainers.image.source", "https://github.com/example/repo"),
+		WithAnnotationDescriptor("com.example.internal.host", "builder"),
+		WithLabel("com.example.internal.commit", "abcd"),
+	)
+	if err != nil {
+		t.Fatalf("failed to add metadata: %v", err)
+	}
+	re := regexp.MustCompile(`^com\.example\.internal\.`)
+	rOut, err := Apply(ctx, rc, rAdd, WithAnnotationRmRegex(re), WithLabelRmRegex(re))
+	if err != nil {
+		t.Fatalf("failed to remove metadata: %v", err)
+	}
+	mOut, err := rc.ManifestGet(ctx, rOut)
+	if err != nil {
+		t.Fatalf("failed to get manifest: %v", err)
+	}
+	annotations, _ := mOut.(manifest.Annotator).GetAnnotations()
+	if annotations["org.opencontainers.image.source"] == "" || annotations["com.example.internal.build"] != "" {
+		t.Errorf("unexpected index annotations: %v", annotations)
+	}
+	ml, _ := mOut.(manifest.Indexer).GetManifestList()
+	for _, d := range ml {
+		if d.Annotations["com.example.internal.host"] != "" {
+			t.Errorf("descriptor annotation not removed: %v", d)
+		}
+		rp := rOut
+		rp.Digest = d.Digest.String()
+		mp, err := rc.ManifestGet(ctx, rp)
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		annotations, _ := mp.(manifest.Annotator).GetAnnotations()
+		if annotations["org.opencontainers.image.source"] == "" || annotations["com.example.internal.build"] != "" {
+			t.Errorf("unexpected annotations on %s: %v", d.Digest, annotations)
+		}
+		cd, err := mp.(manifest.Imager).GetConfig()
+		if err != nil {
+			t.Fatalf("failed to get config: %v", err)
+		}
+		conf, err := rc.BlobGetOCIConfig(ctx, rp, cd)
+		if err != nil {
+			t.Fatalf("failed to get config: %v", err)
+		}
+		labels := conf.GetConfig().Config.Labels
+		if _, ok := labels["com.example.internal.commit"]; ok {
+			t.Errorf("label not removed on %s: %v", d.Digest, labels)
+		}
+	}
+}
+
+func TestPlatformPrune(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "../testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	rc := regclient.New(regclient.WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	pAmd := platform.Platform{OS: "linux", Architecture: "amd64"}
+	pArm := platform.Platform{OS: "linux", Architecture: "arm64"}
+	tt := []struct {
+		name      string
+		opts      []Opts
+		expect    []string
+		expectErr error
+	}{
+		{
+			name:   "Keep",
+			opts:   []Opts{WithPlatformKeep([]platform.Platform{pAmd})},
+			expect: []string{"linux/amd64", "unknown/unknown"},
+		},
+		{
+			name:   "Drop",
+			opts:   []Opts{WithPlatformDrop([]platform.Platform{pAmd})},
+			expect: []string{"linux/arm64", "unknown/unknown"},
+		},
+		{
+			name:   "Keep All",
+			opts:   []Opts{WithPlatformKeep([]platform.Platform{pAmd}), WithPlatformKeep([]platform.Platform{pArm})},
+			expect: []string{"linux/amd64", "linux/arm64", "unknown/unknown", "unknown/unknown"},
+		},
+		{
+			name:      "Drop All",
+			opts:      []Opts{WithPlatformDrop([]platform.Platform{pAmd, pArm})},
+			expectErr: types.ErrNotFound,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rOut, err := Apply(ctx, rc, r, tc.opts...)
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("unexpected error, expected %v, received %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to prune platforms: %v", err)
+			}
+			m, err := rc.ManifestGet(ctx, rOut)
+			if err != nil {
+				t.Fatalf("failed to get manifest: %v", err)
+			}
+			dl, err := m.(manifest.Indexer).GetManifestList()
+			if err != nil {
+				t.Fatalf("failed to get manifest list: %v", err)
+			}
+			if len(dl) != len(tc.expect) {
+				t.Fatalf("unexpected manifest list, expected %v, received %v", tc.expect, dl)
+			}
+			for i, d := range dl {
+				if d.Platform == nil || d.Platform.String() != tc.expect[i] {
+					t.Errorf("unexpected platform %d, expected %s, received %v", i, tc.expect[i], d.Platform)
+				}
+				if ref := d.Annotations[types.AnnotationDockerReferenceDigest]; ref != "" && ref != dl[0].Digest.String() && len(dl) == 2 {
+					t.Errorf("attestation for a dropped platform was kept: %s", ref)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package regclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/regclient/regclient/types/ref"
+)
+
+// Pin resolves a list of references to their digests.
+// The returned list is in the same order as the input, with the tag preserved and the digest added.
+// References that already include a digest are returned unchanged.
+// Each unique reference is only queried once.
+func (rc *RegClient) Pin(ctx context.Context, refs []ref.Ref) ([]ref.Ref, error) {
+	pinned := make([]ref.Ref, len(refs))
+	resolved := map[string]string{}
+	for i, r := range refs {
+		if r.Digest != "" {
+			pinned[i] = r
+			continue
+		}
+		name := r.CommonName()
+		dig, ok := resolved[name]
+		if !ok {
+			m, err := rc.ManifestHead(ctx, r, WithManifestRequireDigest())
+			if err != nil {
+				return pinned, fmt.Errorf("failed to resolve digest for %s: %w", name, err)
+			}
+			dig = m.GetDescriptor().Digest.String()
+			resolved[name] = dig
+		}
+		r.Digest = dig
+		pinned[i] = r
+	}
+	return pinned, nil
+}
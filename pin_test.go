@@ -0,0 +1,77 @@
+package regclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestPin(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	refs := []ref.Ref{}
+	for _, s := range []string{"ocidir://testrepo:v1", "ocidir://testrepo:v2", "ocidir://testrepo:v1"} {
+		r, err := ref.New(s)
+		if err != nil {
+			t.Errorf("failed to setup ref %s: %v", s, err)
+			return
+		}
+		refs = append(refs, r)
+	}
+	m1, err := rc.ManifestHead(ctx, refs[0])
+	if err != nil {
+		t.Errorf("failed to head v1: %v", err)
+		return
+	}
+	m2, err := rc.ManifestHead(ctx, refs[1])
+	if err != nil {
+		t.Errorf("failed to head v2: %v", err)
+		return
+	}
+	rDig := refs[1]
+	rDig.Digest = m1.GetDescriptor().Digest.String()
+	refs = append(refs, rDig)
+
+	t.Run("Pin", func(t *testing.T) {
+		pinned, err := rc.Pin(ctx, refs)
+		if err != nil {
+			t.Errorf("failed to pin: %v", err)
+			return
+		}
+		if len(pinned) != len(refs) {
+			t.Errorf("unexpected length, expected %d, received %d", len(refs), len(pinned))
+			return
+		}
+		expect := []string{
+			m1.GetDescriptor().Digest.String(),
+			m2.GetDescriptor().Digest.String(),
+			m1.GetDescriptor().Digest.String(),
+			m1.GetDescriptor().Digest.String(),
+		}
+		for i := range pinned {
+			if pinned[i].Digest != expect[i] {
+				t.Errorf("unexpected digest for %d, expected %s, received %s", i, expect[i], pinned[i].Digest)
+			}
+			if pinned[i].Tag != refs[i].Tag {
+				t.Errorf("tag changed for %d, expected %s, received %s", i, refs[i].Tag, pinned[i].Tag)
+			}
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		rMissing := refs[0]
+		rMissing.Tag = "missing"
+		_, err := rc.Pin(ctx, []ref.Ref{refs[0], rMissing})
+		if err == nil {
+			t.Errorf("pin of missing tag did not fail")
+		}
+	})
+}
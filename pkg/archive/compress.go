@@ -22,6 +22,8 @@ const (
 	CompressGzip
 	// CompressXz compression
 	CompressXz
+	// CompressZstd compression, only supported for decompression
+	CompressZstd
 )
 
 // compressHeaders are used to detect the compression type
@@ -29,6 +31,7 @@ var compressHeaders = map[CompressType][]byte{
 	CompressBzip2: []byte("\x42\x5A\x68"),
 	CompressGzip:  []byte("\x1F\x8B\x08"),
 	CompressXz:    []byte("\xFD\x37\x7A\x58\x5A\x00"),
+	CompressZstd:  []byte("\x28\xB5\x2F\xFD"),
 }
 
 func Compress(r io.Reader, oComp CompressType) (io.Reader, error) {
@@ -51,6 +54,8 @@ func Compress(r io.Reader, oComp CompressType) (io.Reader, error) {
 		case CompressXz:
 			cbr, _ := xz.NewReader(br)
 			return compressGzip(cbr)
+		case CompressZstd:
+			return compressGzip(newZstdReader(br))
 		}
 	}
 	// No other types currently supported
@@ -68,7 +73,7 @@ func compressGzip(src io.Reader) (io.Reader, error) {
 	return pipeR, nil
 }
 
-// Decompress extracts gzip and bzip streams
+// Decompress extracts gzip, bzip2, xz, and zstd streams
 func Decompress(r io.Reader) (io.Reader, error) {
 	// create bufio to peak on first few bytes
 	br := bufio.NewReader(r)
@@ -85,6 +90,8 @@ func Decompress(r io.Reader) (io.Reader, error) {
 		return gzip.NewReader(br)
 	case CompressXz:
 		return xz.NewReader(br)
+	case CompressZstd:
+		return newZstdReader(br), nil
 	default:
 		return br, nil
 	}
@@ -110,6 +117,8 @@ func (ct CompressType) String() string {
 		return "gzip"
 	case CompressXz:
 		return "xz"
+	case CompressZstd:
+		return "zstd"
 	}
 	return "unknown"
 }
@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// zstdTestLines and zstdTestRandom match the content compressed with the zstd cli in testdata
+func zstdTestLines() []byte {
+	sb := strings.Builder{}
+	for i := 0; i < 6000; i++ {
+		fmt.Fprintf(&sb, "line %d: %s\n", i, strings.Repeat("abc", i%17))
+	}
+	return []byte(sb.String())
+}
+
+func zstdTestRandom() []byte {
+	b := make([]byte, 2000)
+	x := uint32(1)
+	for i := range b {
+		x = x*1664525 + 1013904223
+		b[i] = byte(x >> 24)
+	}
+	return b
+}
+
+func TestDecompressZstd(t *testing.T) {
+	lines := zstdTestLines()
+	tests := []struct {
+		name   string
+		file   string
+		modify func([]byte) []byte
+		expect []byte
+		err    error
+	}{
+		{
+			name:   "compressed",
+			file:   "testdata/lines.zst",
+			expect: lines,
+		},
+		{
+			name:   "multiple frames",
+			file:   "testdata/multi.zst",
+			expect: append(zstdTestRandom(), lines...),
+		},
+		{
+			name: "bad checksum",
+			file: "testdata/lines.zst",
+			modify: func(b []byte) []byte {
+				b[len(b)-1] ^= 0xff
+				return b
+			},
+			err: ErrZstdInvalid,
+		},
+		{
+			name: "truncated",
+			file: "testdata/lines.zst",
+			modify: func(b []byte) []byte {
+				return b[:len(b)/2]
+			},
+			err: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "dictionary",
+			modify: func(b []byte) []byte {
+				// frame header with a one byte dictionary id
+				return []byte{0x28, 0xb5, 0x2f, 0xfd, 0x01, 0x00, 0x2a, 0x01, 0x00, 0x00}
+			},
+			err: ErrZstdUnsupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in []byte
+			if tt.file != "" {
+				var err error
+				in, err = os.ReadFile(tt.file)
+				if err != nil {
+					t.Fatalf("failed to read %s: %v", tt.file, err)
+				}
+			}
+			if tt.modify != nil {
+				in = tt.modify(in)
+			}
+			r, err := Decompress(bytes.NewReader(in))
+			if err != nil {
+				t.Fatalf("failed to decompress: %v", err)
+			}
+			out, err := io.ReadAll(r)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("unexpected error, expected %v, received %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read: %v", err)
+			}
+			if !bytes.Equal(out, tt.expect) {
+				t.Errorf("unexpected output, expected %d bytes, received %d bytes", len(tt.expect), len(out))
+			}
+		})
+	}
+	t.Run("gzip", func(t *testing.T) {
+		in, err := os.ReadFile("testdata/lines.zst")
+		if err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		r, err := Compress(bytes.NewReader(in), CompressGzip)
+		if err != nil {
+			t.Fatalf("failed to compress: %v", err)
+		}
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("failed to read gzip: %v", err)
+		}
+		out, err := io.ReadAll(gr)
+		if err != nil || !bytes.Equal(out, lines) {
+			t.Errorf("unexpected output, received %d bytes, %v", len(out), err)
+		}
+	})
+}
@@ -10,4 +10,8 @@ var (
 	// ErrXzUnsupported because there isn't a Go package for this and I'm
 	// avoiding dependencies on external binaries
 	ErrXzUnsupported = errors.New("xz compression is currently unsupported")
+	// ErrZstdInvalid used for corrupt or truncated zstd streams
+	ErrZstdInvalid = errors.New("invalid zstd stream")
+	// ErrZstdUnsupported used for zstd features that are not implemented, e.g. dictionaries
+	ErrZstdUnsupported = errors.New("zstd feature is unsupported")
 )
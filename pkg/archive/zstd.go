@@ -0,0 +1,45 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdReader decompresses a zstd stream, releasing the decoder when the stream ends.
+type zstdReader struct {
+	dec *zstd.Decoder
+	err error
+}
+
+func newZstdReader(r io.Reader) *zstdReader {
+	// a single goroutine decodes the stream synchronously
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	return &zstdReader{dec: dec, err: zstdReadErr(err)}
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	n, err := z.dec.Read(p)
+	if err != nil {
+		z.err = zstdReadErr(err)
+		z.dec.Close()
+	}
+	return n, z.err
+}
+
+// zstdReadErr converts decoder errors to the errors returned by this package
+func zstdReadErr(err error) error {
+	switch {
+	case err == nil, errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return err
+	case errors.Is(err, zstd.ErrUnknownDictionary):
+		return fmt.Errorf("%w: %w", ErrZstdUnsupported, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrZstdInvalid, err)
+	}
+}
@@ -0,0 +1,145 @@
+// Package bwlimit limits the bandwidth of data transfers.
+// A Limiter attached to a context with NewContext applies to every blob transfer made by regclient with that context,
+// in addition to the blobRate setting of the registry host.
+package bwlimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket shared by every reader using it.
+// The bucket holds up to one second of data, allowing short bursts at the start of a transfer.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+type key struct{}
+
+// New returns a Limiter for the rate in bytes per second, or nil when the rate is not positive.
+func New(rate int64) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &Limiter{
+		rate:   rate,
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// Rate returns the limit in bytes per second.
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.rate
+}
+
+// Wait removes n bytes from the bucket and sleeps until the bucket is no longer in debt.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if l.tokens > float64(l.rate) {
+		l.tokens = float64(l.rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// NewContext returns a context with the limiter, applied to transfers using the context in addition to any host limit.
+func NewContext(ctx context.Context, l *Limiter) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, key{}, l)
+}
+
+// FromContext returns the limiter added with NewContext, or nil if none was added.
+func FromContext(ctx context.Context) *Limiter {
+	l, _ := ctx.Value(key{}).(*Limiter)
+	return l
+}
+
+type reader struct {
+	ctx      context.Context
+	rdr      io.Reader
+	limiters []*Limiter
+	max      int
+}
+
+// NewReader wraps rdr to wait on each limiter after every read.
+// The reader is returned unchanged when every limiter is nil.
+func NewReader(ctx context.Context, rdr io.Reader, limiters ...*Limiter) io.Reader {
+	r := &reader{ctx: ctx, rdr: rdr}
+	for _, l := range limiters {
+		if l == nil {
+			continue
+		}
+		r.limiters = append(r.limiters, l)
+		// limit each read to a fraction of the rate to avoid long pauses between reads
+		if chunk := int(l.rate / 10); r.max == 0 || chunk < r.max {
+			r.max = chunk
+		}
+	}
+	if len(r.limiters) == 0 {
+		return rdr
+	}
+	if r.max < 1 {
+		r.max = 1
+	}
+	return r
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.max {
+		p = p[:r.max]
+	}
+	n, err := r.rdr.Read(p)
+	for _, l := range r.limiters {
+		if wErr := l.Wait(r.ctx, n); wErr != nil && err == nil {
+			err = wErr
+		}
+	}
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// NewReadCloser wraps rc with NewReader, preserving the Close method.
+func NewReadCloser(ctx context.Context, rc io.ReadCloser, limiters ...*Limiter) io.ReadCloser {
+	rdr := NewReader(ctx, rc, limiters...)
+	// compare the type rather than the interface values, the dynamic type of rc may not be comparable
+	if _, ok := rdr.(*reader); !ok {
+		return rc
+	}
+	return readCloser{Reader: rdr, Closer: rc}
+}
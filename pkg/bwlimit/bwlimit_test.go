@@ -0,0 +1,113 @@
+package bwlimit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	t.Run("Nil", func(t *testing.T) {
+		l := New(0)
+		if l != nil {
+			t.Fatalf("expected nil limiter for a zero rate")
+		}
+		if l.Rate() != 0 {
+			t.Errorf("unexpected rate: %d", l.Rate())
+		}
+		if err := l.Wait(ctx, 100); err != nil {
+			t.Errorf("wait on nil limiter: %v", err)
+		}
+		rdr := bytes.NewReader([]byte("hello"))
+		if r := NewReader(ctx, rdr, l, nil); r != io.Reader(rdr) {
+			t.Errorf("reader was wrapped without a limiter")
+		}
+		if FromContext(NewContext(ctx, l)) != nil {
+			t.Errorf("nil limiter added to context")
+		}
+		// a ReadCloser with a non-comparable dynamic type is returned without comparing interfaces
+		rc := uncomparableReadCloser{rdr: []io.Reader{rdr}}
+		if _, ok := NewReadCloser(ctx, rc, l).(uncomparableReadCloser); !ok {
+			t.Errorf("read closer was wrapped without a limiter")
+		}
+		if _, ok := NewReadCloser(ctx, rc, New(1000)).(uncomparableReadCloser); ok {
+			t.Errorf("read closer was not wrapped with a limiter")
+		}
+	})
+	t.Run("Context", func(t *testing.T) {
+		l := New(1000)
+		if FromContext(ctx) != nil {
+			t.Errorf("limiter found on an empty context")
+		}
+		if FromContext(NewContext(ctx, l)) != l {
+			t.Errorf("limiter not returned from context")
+		}
+	})
+	t.Run("Rate", func(t *testing.T) {
+		// the first second is allowed as a burst, the remaining data is limited to the rate
+		rate := int64(10000)
+		data := make([]byte, 15000)
+		l := New(rate)
+		start := time.Now()
+		out, err := io.ReadAll(NewReader(ctx, bytes.NewReader(data), l))
+		if err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		dur := time.Since(start)
+		if len(out) != len(data) {
+			t.Errorf("read length mismatch, expected %d, received %d", len(data), len(out))
+		}
+		if dur < 400*time.Millisecond || dur > 2*time.Second {
+			t.Errorf("unexpected duration for 0.5s of limited data: %s", dur)
+		}
+	})
+	t.Run("Shared", func(t *testing.T) {
+		// two readers sharing a limiter split the rate
+		rate := int64(10000)
+		l := New(rate)
+		start := time.Now()
+		errC := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := io.Copy(io.Discard, NewReader(ctx, bytes.NewReader(make([]byte, 7500)), l))
+				errC <- err
+			}()
+		}
+		for i := 0; i < 2; i++ {
+			if err := <-errC; err != nil {
+				t.Errorf("failed to read: %v", err)
+			}
+		}
+		dur := time.Since(start)
+		if dur < 400*time.Millisecond || dur > 2*time.Second {
+			t.Errorf("unexpected duration for 0.5s of limited data: %s", dur)
+		}
+	})
+	t.Run("Cancel", func(t *testing.T) {
+		l := New(10)
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		// drain the burst, then the next wait should return the context error
+		_ = l.Wait(ctx, 10)
+		err := l.Wait(cctx, 100)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context canceled, received %v", err)
+		}
+	})
+}
+
+type uncomparableReadCloser struct {
+	rdr []io.Reader
+}
+
+func (rc uncomparableReadCloser) Read(p []byte) (int, error) {
+	return rc.rdr[0].Read(p)
+}
+
+func (rc uncomparableReadCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,280 @@
+// Package cassette records and replays HTTP interactions with a registry.
+// A recorded cassette is sanitized, removing credentials and tokens, so it may be attached to a bug report.
+// Replaying a cassette reproduces the registry responses without network access.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/regclient/regclient/internal/redact"
+	"github.com/regclient/regclient/types"
+)
+
+// Redacted replaces sensitive values in a recorded interaction
+const Redacted = redact.Redacted
+
+// Cassette is a list of recorded interactions
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+	filename     string
+	offset       int64 // end of the last interaction written to filename
+	used         []bool
+	mu           sync.Mutex
+}
+
+// Interaction is a single request and the response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Response is the recorded response
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Body is the content of a request or response.
+// Text is stored as a string to be readable in the cassette, and binary content (e.g. layers) is base64 encoded.
+type Body []byte
+
+// MarshalJSON outputs a string for text content, and an object with the base64 encoded content otherwise
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(bodyBinary{Base64: []byte(b)})
+}
+
+// UnmarshalJSON parses the output of MarshalJSON
+func (b *Body) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		*b = Body(s)
+		return nil
+	}
+	var bb bodyBinary
+	err := json.Unmarshal(data, &bb)
+	if err != nil {
+		return err
+	}
+	*b = Body(bb.Base64)
+	return nil
+}
+
+type bodyBinary struct {
+	Base64 []byte `json:"base64"`
+}
+
+// New returns an empty cassette.
+// When filename is set, each recorded interaction is appended to that file,
+// preserving the transcript when a command fails.
+func New(filename string) *Cassette {
+	return &Cassette{
+		Interactions: []Interaction{},
+		filename:     filename,
+	}
+}
+
+// Load reads a cassette from a file
+func Load(filename string) (*Cassette, error) {
+	//#nosec G304 file is provided by the user
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	err = json.Unmarshal(b, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", filename, err)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to a file
+func (c *Cassette) Save(filename string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save(filename)
+}
+
+func (c *Cassette) save(filename string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0600)
+}
+
+// cassetteTrailer closes the list of interactions and the cassette
+const cassetteTrailer = "\n  ]\n}\n"
+
+// append writes the interaction over the trailer of the file, leaving a valid cassette after each call.
+// The file is created with the first interaction.
+func (c *Cassette) append(i Interaction) error {
+	b, err := json.MarshalIndent(i, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY
+	prefix := ",\n    "
+	if c.offset == 0 {
+		flags |= os.O_CREATE | os.O_TRUNC
+		prefix = "{\n  \"interactions\": [\n    "
+	}
+	//#nosec G304 file is provided by the user
+	fh, err := os.OpenFile(c.filename, flags, 0600)
+	if err != nil {
+		return err
+	}
+	out := append(append([]byte(prefix), b...), cassetteTrailer...)
+	_, err = fh.WriteAt(out, c.offset)
+	if err != nil {
+		_ = fh.Close()
+		return err
+	}
+	err = fh.Close()
+	if err != nil {
+		return err
+	}
+	c.offset += int64(len(out) - len(cassetteTrailer))
+	return nil
+}
+
+// Recorder returns a round tripper that sends requests with base and records each interaction.
+// Recording reads the full request and response bodies into memory.
+func (c *Cassette) Recorder(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &recorder{c: c, base: base}
+}
+
+// Replay returns a round tripper that responds with the recorded interactions.
+// Each request is matched by the method and url to the next unused interaction.
+// Once all matching interactions are used, the last match is repeated.
+func (c *Cassette) Replay() http.RoundTripper {
+	return &replay{c: c}
+}
+
+type recorder struct {
+	c    *Cassette
+	base http.RoundTripper
+}
+
+func (rec *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    redact.URL(req.URL),
+			Header: redact.Header(req.Header),
+		},
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// send a copy of the request with the buffered body
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		i.Request.Body = redact.Body(req.Header.Get("Content-Type"), body)
+	}
+	resp, err := rec.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	i.Response = Response{
+		Status: resp.StatusCode,
+		Header: redact.Header(resp.Header),
+		Body:   redact.Body(resp.Header.Get("Content-Type"), body),
+	}
+	rec.c.mu.Lock()
+	defer rec.c.mu.Unlock()
+	rec.c.Interactions = append(rec.c.Interactions, i)
+	if rec.c.filename != "" {
+		err = rec.c.append(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save cassette: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+type replay struct {
+	c *Cassette
+}
+
+func (rep *replay) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	reqURL := redact.URL(req.URL)
+	rep.c.mu.Lock()
+	defer rep.c.mu.Unlock()
+	if len(rep.c.used) < len(rep.c.Interactions) {
+		rep.c.used = append(rep.c.used, make([]bool, len(rep.c.Interactions)-len(rep.c.used))...)
+	}
+	match := -1
+	for n, i := range rep.c.Interactions {
+		if i.Request.Method != req.Method || i.Request.URL != reqURL {
+			continue
+		}
+		match = n
+		if !rep.c.used[n] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no recorded interaction for %s %s%.0w", req.Method, reqURL, types.ErrNotFound)
+	}
+	rep.c.used[match] = true
+	i := rep.c.Interactions[match]
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Response.Status, http.StatusText(i.Response.Status)),
+		StatusCode:    i.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(i.Response.Body)),
+		ContentLength: int64(len(i.Response.Body)),
+		Request:       req,
+	}
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	// a HEAD request returns the length of the content without a body
+	if cl, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = cl
+	}
+	return resp, nil
+}
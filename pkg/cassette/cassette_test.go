@@ -0,0 +1,242 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/reqresp"
+	"github.com/regclient/regclient/scheme/reg"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCassette(t *testing.T) {
+	ctx := context.Background()
+	repoPath := "/proj"
+	tagName := "v1"
+	token := "secret-token"
+	mBody := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	mDigest := digest.FromBytes(mBody)
+	tokenBody, _ := json.Marshal(map[string]string{"token": token})
+	var tsURL *url.URL
+	rrs := []reqresp.ReqResp{
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "get manifest with auth",
+				Method: "GET",
+				Path:   "/v2" + repoPath + "/manifests/" + tagName,
+				Headers: http.Header{
+					"Authorization": {"Bearer " + token},
+				},
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusOK,
+				Headers: http.Header{
+					"Content-Length":        {fmt.Sprintf("%d", len(mBody))},
+					"Content-Type":          {types.MediaTypeOCI1ManifestList},
+					"Docker-Content-Digest": {mDigest.String()},
+				},
+				Body: mBody,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "get manifest without auth",
+				Method: "GET",
+				Path:   "/v2" + repoPath + "/manifests/" + tagName,
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusUnauthorized,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "token",
+				Method: "POST",
+				Path:   "/token",
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusOK,
+				Headers: http.Header{
+					"Content-Type": {"application/json"},
+				},
+				Body: tokenBody,
+			},
+		},
+	}
+	rrs = append(rrs, reqresp.BaseEntries...)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:proj:pull"`, tsURL.String()))
+		reqresp.NewHandler(t, rrs).ServeHTTP(rw, req)
+	}))
+	defer ts.Close()
+	tsURL, _ = url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	rcHosts := []*config.Host{
+		{
+			Name:      tsHost,
+			Hostname:  tsHost,
+			TLS:       config.TLSDisabled,
+			ReqPerSec: 100,
+		},
+	}
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	r, err := ref.New(tsHost + repoPath + ":" + tagName)
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	filename := filepath.Join(t.TempDir(), "cassette.json")
+
+	t.Run("Record", func(t *testing.T) {
+		c := New(filename)
+		regRec := reg.New(
+			reg.WithConfigHosts(rcHosts),
+			reg.WithLog(log),
+			reg.WithTransportWrap(c.Recorder),
+		)
+		m, err := regRec.ManifestGet(ctx, r)
+		if err != nil {
+			t.Errorf("failed to get manifest: %v", err)
+			return
+		}
+		if m.GetDescriptor().Digest != mDigest {
+			t.Errorf("unexpected digest, expected %s, received %s", mDigest, m.GetDescriptor().Digest)
+		}
+		// the cassette is written after each interaction
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Errorf("failed to read cassette: %v", err)
+			return
+		}
+		if bytes.Contains(b, []byte(token)) {
+			t.Errorf("cassette contains the token: %s", string(b))
+		}
+		if len(c.Interactions) != 3 {
+			t.Errorf("unexpected number of interactions, expected 3, received %d", len(c.Interactions))
+		}
+		// each interaction is appended, leaving a cassette that can be loaded
+		cLoad, err := Load(filename)
+		if err != nil {
+			t.Errorf("failed to load cassette: %v", err)
+			return
+		}
+		if len(cLoad.Interactions) != 3 {
+			t.Errorf("unexpected number of loaded interactions, expected 3, received %d", len(cLoad.Interactions))
+		}
+	})
+	// stop the server to verify requests do not reach the network
+	ts.Close()
+	t.Run("Replay", func(t *testing.T) {
+		c, err := Load(filename)
+		if err != nil {
+			t.Errorf("failed to load cassette: %v", err)
+			return
+		}
+		regRep := reg.New(
+			reg.WithConfigHosts(rcHosts),
+			reg.WithLog(log),
+			reg.WithTransportWrap(func(http.RoundTripper) http.RoundTripper { return c.Replay() }),
+			reg.WithDelay(time.Millisecond, time.Millisecond),
+		)
+		m, err := regRep.ManifestGet(ctx, r)
+		if err != nil {
+			t.Errorf("failed to get manifest: %v", err)
+			return
+		}
+		raw, _ := m.RawBody()
+		if m.GetDescriptor().Digest != mDigest || !bytes.Equal(raw, mBody) {
+			t.Errorf("unexpected manifest, expected %s, received %s", mDigest, m.GetDescriptor().Digest)
+		}
+		rMissing := r
+		rMissing.Tag = "missing"
+		_, err = regRep.ManifestGet(ctx, rMissing)
+		if err == nil {
+			t.Errorf("unrecorded request did not fail")
+		}
+	})
+}
+
+func TestRedact(t *testing.T) {
+	c := New("")
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: "secret-cookie"})
+		rw.Header().Set("Location", "https://storage.example.com/blob?X-Amz-Signature=secret-sig&X-Amz-Credential=secret-cred&other=keep")
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(`{"access_token":"secret-access","refresh_token":"secret-refresh","expires_in":300}`))
+	}))
+	defer ts.Close()
+	hc := &http.Client{Transport: c.Recorder(nil)}
+	form := url.Values{"grant_type": {"password"}, "username": {"user"}, "password": {"secret-pass"}}
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/token?sig=secret-query&scope=pull", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("user", "secret-basic")
+	resp, err := hc.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	body := &bytes.Buffer{}
+	_, _ = body.ReadFrom(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(body.String(), "secret-access") {
+		t.Errorf("response body was modified for the caller: %s", body.String())
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("failed to marshal cassette: %v", err)
+	}
+	for _, secret := range []string{"secret-cookie", "secret-sig", "secret-cred", "secret-access", "secret-refresh", "secret-pass", "secret-basic", "secret-query"} {
+		if bytes.Contains(b, []byte(secret)) {
+			t.Errorf("cassette contains %s", secret)
+		}
+	}
+	for _, keep := range []string{"other=keep", "expires_in", "scope=pull", "username=user"} {
+		if !bytes.Contains(b, []byte(keep)) {
+			t.Errorf("cassette is missing %s", keep)
+		}
+	}
+	// replay with the same request
+	req, err = http.NewRequest(http.MethodPost, ts.URL+"/token?sig=other&scope=pull", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err = c.Replay().RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	req, err = http.NewRequest(http.MethodGet, ts.URL+"/token", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	_, err = c.Replay().RoundTrip(req)
+	if !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
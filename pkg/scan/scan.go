@@ -0,0 +1,239 @@
+// Package scan runs external vulnerability scanners before an image is copied.
+// A Scanner implements regclient.ImageScanner:
+//
+//	err := rc.ImageCopy(ctx, rSrc, rTgt, regclient.ImageWithScanner(scan.Grype(), regclient.ScanSeverityHigh))
+//
+// The tools are run as commands, so scanning libraries are not added as dependencies of regclient.
+// Only registry references are scanned, the command is run with the repository and digest of the source manifest.
+// Each copied platform of an index is scanned by digest with the --platform flag.
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// Scanner runs a scanning command and parses the json report
+type Scanner struct {
+	name   string
+	path   string
+	cmd    []string
+	args   []string
+	env    []string
+	prefix string
+	parse  func([]byte) ([]regclient.ImageScanFinding, error)
+	stderr io.Writer
+}
+
+// Opts configure a Scanner
+type Opts func(*Scanner)
+
+// Grype scans with "grype -o json --quiet [args] registry:<ref>"
+func Grype(opts ...Opts) *Scanner {
+	s := &Scanner{
+		name:   "grype",
+		path:   "grype",
+		cmd:    []string{"-o", "json", "--quiet"},
+		prefix: "registry:",
+		parse:  parseGrype,
+	}
+	return s.apply(opts)
+}
+
+// Trivy scans with "trivy image --format json --quiet [args] <ref>"
+func Trivy(opts ...Opts) *Scanner {
+	s := &Scanner{
+		name:  "trivy",
+		path:  "trivy",
+		cmd:   []string{"image", "--format", "json", "--quiet"},
+		parse: parseTrivy,
+	}
+	return s.apply(opts)
+}
+
+// TrivyServer scans with a Trivy server, running "trivy image --server <server> --format json --quiet [args] <ref>"
+func TrivyServer(server string, opts ...Opts) *Scanner {
+	s := Trivy()
+	s.cmd = append(s.cmd, "--server", server)
+	return s.apply(opts)
+}
+
+// ByName returns the scanner for "grype" or "trivy", the server is only supported with trivy
+func ByName(name, server string, opts ...Opts) (*Scanner, error) {
+	switch name {
+	case "grype":
+		if server != "" {
+			return nil, fmt.Errorf("server is not supported with grype%.0w", types.ErrUnsupported)
+		}
+		return Grype(opts...), nil
+	case "trivy":
+		if server != "" {
+			return TrivyServer(server, opts...), nil
+		}
+		return Trivy(opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown scanner %q, expected grype or trivy%.0w", name, types.ErrUnsupported)
+	}
+}
+
+func (s *Scanner) apply(opts []Opts) *Scanner {
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithArgs adds arguments before the reference, e.g. the platform to scan
+func WithArgs(args ...string) Opts {
+	return func(s *Scanner) {
+		s.args = append(s.args, args...)
+	}
+}
+
+// WithEnv adds environment variables to the command, formatted as "key=value"
+func WithEnv(env ...string) Opts {
+	return func(s *Scanner) {
+		s.env = append(s.env, env...)
+	}
+}
+
+// WithPath overrides the path to the scanning command
+func WithPath(path string) Opts {
+	return func(s *Scanner) {
+		s.path = path
+	}
+}
+
+// WithStderr sends the stderr of the command to the writer, by default it is included in any error
+func WithStderr(stderr io.Writer) Opts {
+	return func(s *Scanner) {
+		s.stderr = stderr
+	}
+}
+
+// Scan runs the scanning command for the source manifest
+func (s *Scanner) Scan(ctx context.Context, r ref.Ref, d types.Descriptor) (*regclient.ImageScanResult, error) {
+	if r.Scheme != "reg" {
+		return nil, fmt.Errorf("scanning is only supported for registry references, received %s%.0w", r.CommonName(), types.ErrUnsupported)
+	}
+	// scan the digest rather than a tag that may change
+	r.Tag = ""
+	r.Digest = d.Digest.String()
+	args := append([]string{}, s.cmd...)
+	// a platform manifest from an index is scanned with the matching platform
+	if d.Platform != nil && d.Platform.OS != "" {
+		args = append(args, "--platform", d.Platform.String())
+	}
+	args = append(append(args, s.args...), s.prefix+r.CommonName())
+	cmd := exec.CommandContext(ctx, s.path, args...)
+	cmd.Env = append(os.Environ(), s.env...)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if s.stderr != nil {
+		cmd.Stderr = s.stderr
+	}
+	err := cmd.Run()
+	if err != nil {
+		if outS := strings.TrimSpace(stderr.String()); outS != "" {
+			return nil, fmt.Errorf("failed to scan %s with %s, output: %s: %w", r.CommonName(), s.path, outS, err)
+		}
+		return nil, fmt.Errorf("failed to scan %s with %s: %w", r.CommonName(), s.path, err)
+	}
+	findings, err := s.parse(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s report for %s: %w", s.name, r.CommonName(), err)
+	}
+	return &regclient.ImageScanResult{
+		Scanner:  s.name,
+		Findings: findings,
+	}, nil
+}
+
+// severity converts the severity from a report, unrecognized values are unknown
+func severity(s string) regclient.ScanSeverity {
+	sev, err := regclient.ParseScanSeverity(s)
+	if err != nil {
+		return regclient.ScanSeverityUnknown
+	}
+	return sev
+}
+
+type grypeReport struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+			Fix      struct {
+				Versions []string `json:"versions"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+func parseGrype(raw []byte) ([]regclient.ImageScanFinding, error) {
+	report := grypeReport{}
+	err := json.Unmarshal(raw, &report)
+	if err != nil {
+		return nil, err
+	}
+	findings := []regclient.ImageScanFinding{}
+	for _, m := range report.Matches {
+		findings = append(findings, regclient.ImageScanFinding{
+			ID:       m.Vulnerability.ID,
+			Severity: severity(m.Vulnerability.Severity),
+			Package:  m.Artifact.Name,
+			Version:  m.Artifact.Version,
+			FixedIn:  strings.Join(m.Vulnerability.Fix.Versions, ", "),
+		})
+	}
+	return findings, nil
+}
+
+type trivyReport struct {
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+func parseTrivy(raw []byte) ([]regclient.ImageScanFinding, error) {
+	report := trivyReport{}
+	err := json.Unmarshal(raw, &report)
+	if err != nil {
+		return nil, err
+	}
+	findings := []regclient.ImageScanFinding{}
+	for _, result := range report.Results {
+		for _, v := range result.Vulnerabilities {
+			findings = append(findings, regclient.ImageScanFinding{
+				ID:       v.VulnerabilityID,
+				Severity: severity(v.Severity),
+				Package:  v.PkgName,
+				Version:  v.InstalledVersion,
+				FixedIn:  v.FixedVersion,
+			})
+		}
+	}
+	return findings, nil
+}
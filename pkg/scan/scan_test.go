@@ -0,0 +1,154 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+const (
+	grypeJSON = `{"matches":[{"vulnerability":{"id":"CVE-2024-0001","severity":"High","fix":{"versions":["3.0.1"]}},"artifact":{"name":"openssl","version":"3.0.0"}},{"vulnerability":{"id":"GHSA-xxxx","severity":"Negligible","fix":{"versions":[]}},"artifact":{"name":"zlib","version":"1.2"}}]}`
+	trivyJSON = `{"Results":[{"Target":"example","Vulnerabilities":[{"VulnerabilityID":"CVE-2024-0002","PkgName":"busybox","InstalledVersion":"1.36","FixedVersion":"1.37","Severity":"CRITICAL"}]},{"Target":"app"}]}`
+)
+
+func TestScanner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a shell script")
+	}
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	outFile := filepath.Join(tmpDir, "args")
+	reportFile := filepath.Join(tmpDir, "report")
+	script := filepath.Join(tmpDir, "scanner")
+	err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$SCAN_ENV $*\" > \""+outFile+"\"\n[ \"$SCAN_ENV\" != \"fail\" ] || { echo \"db missing\" >&2; exit 1; }\ncat \""+reportFile+"\"\n"), 0755)
+	if err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	d := types.Descriptor{
+		MediaType: types.MediaTypeOCI1Manifest,
+		Digest:    digest.FromString("example"),
+		Size:      7,
+	}
+	r, err := ref.New("registry.example.org/repo:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	tests := []struct {
+		name       string
+		scanner    *Scanner
+		report     string
+		platform   *platform.Platform
+		expectArgs string
+		expect     []regclient.ImageScanFinding
+	}{
+		{
+			name:       "Grype",
+			scanner:    Grype(WithPath(script), WithArgs("--platform", "linux/amd64"), WithEnv("SCAN_ENV=ok")),
+			report:     grypeJSON,
+			expectArgs: "ok -o json --quiet --platform linux/amd64 registry:registry.example.org/repo@" + d.Digest.String(),
+			expect: []regclient.ImageScanFinding{
+				{ID: "CVE-2024-0001", Severity: regclient.ScanSeverityHigh, Package: "openssl", Version: "3.0.0", FixedIn: "3.0.1"},
+				{ID: "GHSA-xxxx", Severity: regclient.ScanSeverityNegligible, Package: "zlib", Version: "1.2"},
+			},
+		},
+		{
+			name:       "TrivyServer",
+			scanner:    TrivyServer("http://trivy:4954", WithPath(script)),
+			report:     trivyJSON,
+			expectArgs: "image --format json --quiet --server http://trivy:4954 registry.example.org/repo@" + d.Digest.String(),
+			expect: []regclient.ImageScanFinding{
+				{ID: "CVE-2024-0002", Severity: regclient.ScanSeverityCritical, Package: "busybox", Version: "1.36", FixedIn: "1.37"},
+			},
+		},
+		{
+			name:       "TrivyPlatform",
+			scanner:    Trivy(WithPath(script)),
+			report:     trivyJSON,
+			platform:   &platform.Platform{OS: "linux", Architecture: "arm64"},
+			expectArgs: "image --format json --quiet --platform linux/arm64 registry.example.org/repo@" + d.Digest.String(),
+			expect: []regclient.ImageScanFinding{
+				{ID: "CVE-2024-0002", Severity: regclient.ScanSeverityCritical, Package: "busybox", Version: "1.36", FixedIn: "1.37"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := os.WriteFile(reportFile, []byte(tt.report), 0644)
+			if err != nil {
+				t.Fatalf("failed to write report: %v", err)
+			}
+			dScan := d
+			dScan.Platform = tt.platform
+			result, err := tt.scanner.Scan(ctx, r, dScan)
+			if err != nil {
+				t.Fatalf("failed to scan: %v", err)
+			}
+			out, err := os.ReadFile(outFile)
+			if err != nil {
+				t.Fatalf("failed to read args: %v", err)
+			}
+			if strings.TrimSpace(string(out)) != tt.expectArgs {
+				t.Errorf("unexpected args, expected %s, received %s", tt.expectArgs, out)
+			}
+			if result.Scanner != strings.ToLower(tt.name[:5]) {
+				t.Errorf("unexpected scanner name: %s", result.Scanner)
+			}
+			if len(result.Findings) != len(tt.expect) {
+				t.Fatalf("unexpected findings, expected %v, received %v", tt.expect, result.Findings)
+			}
+			for i := range tt.expect {
+				if result.Findings[i] != tt.expect[i] {
+					t.Errorf("unexpected finding, expected %v, received %v", tt.expect[i], result.Findings[i])
+				}
+			}
+		})
+	}
+	t.Run("Failure", func(t *testing.T) {
+		_, err := Grype(WithPath(script), WithEnv("SCAN_ENV=fail")).Scan(ctx, r, d)
+		if err == nil || !strings.Contains(err.Error(), "db missing") {
+			t.Errorf("expected the command output in the error, received %v", err)
+		}
+	})
+	t.Run("InvalidReport", func(t *testing.T) {
+		err := os.WriteFile(reportFile, []byte("not json"), 0644)
+		if err != nil {
+			t.Fatalf("failed to write report: %v", err)
+		}
+		_, err = Trivy(WithPath(script)).Scan(ctx, r, d)
+		if err == nil {
+			t.Errorf("expected a parsing error")
+		}
+	})
+	t.Run("ByName", func(t *testing.T) {
+		s, err := ByName("trivy", "http://trivy:4954")
+		if err != nil || s.name != "trivy" {
+			t.Errorf("unexpected scanner: %v, %v", s, err)
+		}
+		for _, tc := range [][2]string{{"grype", "http://trivy:4954"}, {"clair", ""}} {
+			_, err = ByName(tc[0], tc[1])
+			if !errors.Is(err, types.ErrUnsupported) {
+				t.Errorf("expected unsupported for %v, received %v", tc, err)
+			}
+		}
+	})
+	t.Run("OCIDir", func(t *testing.T) {
+		rDir, err := ref.New("ocidir://testrepo:v1")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		_, err = Grype(WithPath(script)).Scan(ctx, rDir, d)
+		if !errors.Is(err, types.ErrUnsupported) {
+			t.Errorf("expected unsupported, received %v", err)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+//go:build cosign
+
+package signcmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+)
+
+const (
+	// cosignMediaType is the layer media type of a cosign simple signing payload
+	cosignMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// cosignAnnotationSig is the layer annotation with the base64 signature of the payload
+	cosignAnnotationSig = "dev.cosignproject.cosign/signature"
+	// cosignPayloadType is the type of the simple signing payload
+	cosignPayloadType = "cosign container image signature"
+	// cosignSuffix is appended to the digest tag of the signature manifest
+	cosignSuffix = ".sig"
+)
+
+// KeySigner signs pushed manifests in-process with a cosign compatible signature.
+// This is only included when built with the "cosign" tag.
+// Signatures are not uploaded to a transparency log, verify them with "cosign verify --key cosign.pub --insecure-ignore-tlog".
+type KeySigner struct {
+	rc          *regclient.RegClient
+	key         crypto.Signer
+	annotations map[string]string
+}
+
+// KeyOpts configure a KeySigner
+type KeyOpts func(*KeySigner)
+
+// CosignKey returns a KeySigner that pushes signatures with the RegClient.
+// The RegClient should not include the KeySigner in its post push hooks.
+//
+//	rcSign := regclient.New(opts...)
+//	rc := regclient.New(append(opts, regclient.WithPostPushHook(signcmd.CosignKey(rcSign, key)))...)
+func CosignKey(rc *regclient.RegClient, key crypto.Signer, opts ...KeyOpts) *KeySigner {
+	s := &KeySigner{
+		rc:  rc,
+		key: key,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithAnnotations adds annotations to the optional section of the signed payload
+func WithAnnotations(annotations map[string]string) KeyOpts {
+	return func(s *KeySigner) {
+		if s.annotations == nil {
+			s.annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			s.annotations[k] = v
+		}
+	}
+}
+
+// LoadKey parses an unencrypted PEM private key, e.g. from "cosign generate-key-pair" after removing the password with openssl.
+// Encrypted cosign keys are not supported.
+func LoadKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode the PEM key")
+	}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %s", block.Type)
+	}
+}
+
+// PostPush signs the pushed manifest and pushes the signature to the sha256-<hex>.sig tag
+func (s *KeySigner) PostPush(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	if s.rc == nil || s.key == nil {
+		return fmt.Errorf("signer is missing the regclient or key")
+	}
+	payload, err := s.payload(r, d)
+	if err != nil {
+		return err
+	}
+	sig, err := s.sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign %s: %w", r.CommonName(), err)
+	}
+	layer := types.Descriptor{
+		MediaType: cosignMediaType,
+		Digest:    digest.FromBytes(payload),
+		Size:      int64(len(payload)),
+		Annotations: map[string]string{
+			cosignAnnotationSig: base64.StdEncoding.EncodeToString(sig),
+		},
+	}
+	rSig := r
+	rSig.Digest = ""
+	rSig.Tag = d.Digest.Algorithm().String() + "-" + d.Digest.Hex() + cosignSuffix
+	// append to an existing signature manifest
+	layers := []types.Descriptor{}
+	mOrig, err := s.rc.ManifestGet(ctx, rSig)
+	if err == nil {
+		mi, ok := mOrig.(manifest.Imager)
+		if !ok {
+			return fmt.Errorf("signature manifest %s is not an image", rSig.CommonName())
+		}
+		layers, err = mi.GetLayers()
+		if err != nil {
+			return err
+		}
+		for _, l := range layers {
+			if l.Digest == layer.Digest && l.Annotations[cosignAnnotationSig] == layer.Annotations[cosignAnnotationSig] {
+				return nil
+			}
+		}
+	} else if !errors.Is(err, types.ErrNotFound) && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to get signature manifest %s: %w", rSig.CommonName(), err)
+	}
+	layers = append(layers, layer)
+	_, err = s.rc.BlobPut(ctx, rSig, layer, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to push signature payload: %w", err)
+	}
+	conf := v1.Image{
+		RootFS: v1.RootFS{
+			Type:    "layers",
+			DiffIDs: []digest.Digest{},
+		},
+	}
+	for _, l := range layers {
+		conf.RootFS.DiffIDs = append(conf.RootFS.DiffIDs, l.Digest)
+	}
+	confBytes, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+	confDesc := types.Descriptor{
+		MediaType: types.MediaTypeOCI1ImageConfig,
+		Digest:    digest.FromBytes(confBytes),
+		Size:      int64(len(confBytes)),
+	}
+	_, err = s.rc.BlobPut(ctx, rSig, confDesc, bytes.NewReader(confBytes))
+	if err != nil {
+		return fmt.Errorf("failed to push signature config: %w", err)
+	}
+	mSig, err := manifest.New(manifest.WithOrig(v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: types.MediaTypeOCI1Manifest,
+		Config:    confDesc,
+		Layers:    layers,
+	}))
+	if err != nil {
+		return err
+	}
+	err = s.rc.ManifestPut(ctx, rSig, mSig)
+	if err != nil {
+		return fmt.Errorf("failed to push signature manifest %s: %w", rSig.CommonName(), err)
+	}
+	return nil
+}
+
+// payload returns the simple signing json identifying the repository and digest
+func (s *KeySigner) payload(r ref.Ref, d types.Descriptor) ([]byte, error) {
+	identity := r.Path
+	if r.Registry == config.DockerRegistry {
+		identity = "index.docker.io/" + r.Repository
+	} else if r.Registry != "" {
+		identity = r.Registry + "/" + r.Repository
+	}
+	payload := struct {
+		Critical struct {
+			Identity struct {
+				DockerReference string `json:"docker-reference"`
+			} `json:"identity"`
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+			Type string `json:"type"`
+		} `json:"critical"`
+		Optional map[string]string `json:"optional"`
+	}{}
+	payload.Critical.Identity.DockerReference = identity
+	payload.Critical.Image.DockerManifestDigest = d.Digest.String()
+	payload.Critical.Type = cosignPayloadType
+	payload.Optional = s.annotations
+	return json.Marshal(payload)
+}
+
+// sign returns the signature of the payload, ECDSA signatures are ASN.1 encoded
+func (s *KeySigner) sign(payload []byte) ([]byte, error) {
+	switch s.key.Public().(type) {
+	case ed25519.PublicKey:
+		return s.key.Sign(rand.Reader, payload, crypto.Hash(0))
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		h := sha256.Sum256(payload)
+		return s.key.Sign(rand.Reader, h[:], crypto.SHA256)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", s.key.Public())
+	}
+}
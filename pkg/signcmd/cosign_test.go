@@ -0,0 +1,113 @@
+//go:build cosign
+
+package signcmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestCosignKey(t *testing.T) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	signer, err := LoadKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		t.Fatalf("failed to load key: %v", err)
+	}
+	rc := regclient.New(regclient.WithFS(rwfs.MemNew()))
+	s := CosignKey(rc, signer, WithAnnotations(map[string]string{"env": "test"}))
+	r, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	d := types.Descriptor{
+		MediaType: types.MediaTypeOCI1Manifest,
+		Digest:    digest.FromString("example"),
+		Size:      7,
+	}
+	rSig, err := ref.New("ocidir://testrepo:sha256-" + d.Digest.Hex() + ".sig")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		err = s.PostPush(ctx, r, d)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		m, err := rc.ManifestGet(ctx, rSig)
+		if err != nil {
+			t.Fatalf("failed to get signature: %v", err)
+		}
+		layers, err := m.(manifest.Imager).GetLayers()
+		if err != nil {
+			t.Fatalf("failed to get layers: %v", err)
+		}
+		if len(layers) != i {
+			t.Fatalf("unexpected layer count, expected %d, received %d", i, len(layers))
+		}
+		l := layers[i-1]
+		if l.MediaType != cosignMediaType {
+			t.Errorf("unexpected media type: %s", l.MediaType)
+		}
+		br, err := rc.BlobGet(ctx, rSig, l)
+		if err != nil {
+			t.Fatalf("failed to get payload: %v", err)
+		}
+		payload, err := io.ReadAll(br)
+		br.Close()
+		if err != nil {
+			t.Fatalf("failed to read payload: %v", err)
+		}
+		sig, err := base64.StdEncoding.DecodeString(l.Annotations[cosignAnnotationSig])
+		if err != nil {
+			t.Fatalf("failed to decode signature: %v", err)
+		}
+		h := sha256.Sum256(payload)
+		if !ecdsa.VerifyASN1(&key.PublicKey, h[:], sig) {
+			t.Errorf("signature verification failed")
+		}
+		ss := struct {
+			Critical struct {
+				Identity struct {
+					DockerReference string `json:"docker-reference"`
+				} `json:"identity"`
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+				Type string `json:"type"`
+			} `json:"critical"`
+			Optional map[string]string `json:"optional"`
+		}{}
+		err = json.Unmarshal(payload, &ss)
+		if err != nil {
+			t.Fatalf("failed to parse payload: %v", err)
+		}
+		if ss.Critical.Image.DockerManifestDigest != d.Digest.String() || ss.Critical.Identity.DockerReference != "testrepo" ||
+			ss.Critical.Type != cosignPayloadType || ss.Optional["env"] != "test" {
+			t.Errorf("unexpected payload: %s", string(payload))
+		}
+	}
+}
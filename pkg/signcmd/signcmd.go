@@ -0,0 +1,118 @@
+// Package signcmd wraps external signing tools, running a command after an image is pushed.
+// A Signer implements regclient.PostPushHook:
+//
+//	rc := regclient.New(regclient.WithPostPushHook(signcmd.Cosign(signcmd.WithArgs("--key", "cosign.key"))))
+//
+// The cosign or notation command must be installed and configured with its keys.
+// Only registry references are signed, the command is run with the repository and digest of the pushed manifest.
+//
+// Building with the "cosign" tag adds CosignKey, which signs in-process with a local key and pushes a cosign compatible signature.
+// There is no built-in notation signer.
+package signcmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// Signer runs a signing command for each pushed manifest
+type Signer struct {
+	path   string
+	cmd    []string
+	args   []string
+	env    []string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Opts configure a Signer
+type Opts func(*Signer)
+
+// Cosign signs with "cosign sign --yes [args] <ref>"
+func Cosign(opts ...Opts) *Signer {
+	return New("cosign", []string{"sign", "--yes"}, opts...)
+}
+
+// Notation signs with "notation sign [args] <ref>"
+func Notation(opts ...Opts) *Signer {
+	return New("notation", []string{"sign"}, opts...)
+}
+
+// New returns a Signer running the command with the args, followed by any WithArgs, and the reference
+func New(path string, cmd []string, opts ...Opts) *Signer {
+	s := &Signer{
+		path: path,
+		cmd:  cmd,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithArgs adds arguments before the reference, e.g. the key to sign with
+func WithArgs(args ...string) Opts {
+	return func(s *Signer) {
+		s.args = append(s.args, args...)
+	}
+}
+
+// WithEnv adds environment variables to the command, formatted as "key=value"
+func WithEnv(env ...string) Opts {
+	return func(s *Signer) {
+		s.env = append(s.env, env...)
+	}
+}
+
+// WithOutput sends the output of the command to the writers, by default the output is included in any error
+func WithOutput(stdout, stderr io.Writer) Opts {
+	return func(s *Signer) {
+		s.stdout = stdout
+		s.stderr = stderr
+	}
+}
+
+// WithPath overrides the path to the signing command
+func WithPath(path string) Opts {
+	return func(s *Signer) {
+		s.path = path
+	}
+}
+
+// PostPush runs the signing command for the pushed manifest
+func (s *Signer) PostPush(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	if r.Scheme != "reg" {
+		return fmt.Errorf("signing is only supported for registry references, received %s%.0w", r.CommonName(), types.ErrUnsupported)
+	}
+	// sign the digest rather than a tag that may change
+	r.Tag = ""
+	r.Digest = d.Digest.String()
+	args := append(append(append([]string{}, s.cmd...), s.args...), r.CommonName())
+	cmd := exec.CommandContext(ctx, s.path, args...)
+	cmd.Env = append(os.Environ(), s.env...)
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if s.stdout != nil {
+		cmd.Stdout = s.stdout
+	}
+	if s.stderr != nil {
+		cmd.Stderr = s.stderr
+	}
+	err := cmd.Run()
+	if err != nil {
+		if outS := strings.TrimSpace(out.String()); outS != "" {
+			return fmt.Errorf("failed to sign %s with %s, output: %s: %w", r.CommonName(), s.path, outS, err)
+		}
+		return fmt.Errorf("failed to sign %s with %s: %w", r.CommonName(), s.path, err)
+	}
+	return nil
+}
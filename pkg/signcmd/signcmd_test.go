@@ -0,0 +1,85 @@
+package signcmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestSigner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a shell script")
+	}
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	outFile := filepath.Join(tmpDir, "args")
+	script := filepath.Join(tmpDir, "signer")
+	err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$SIGN_ENV $*\" > \""+outFile+"\"\n[ \"$SIGN_ENV\" != \"fail\" ] || { echo \"bad key\" >&2; exit 1; }\n"), 0755)
+	if err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	d := types.Descriptor{
+		MediaType: types.MediaTypeOCI1Manifest,
+		Digest:    digest.FromString("example"),
+		Size:      7,
+	}
+	r, err := ref.New("registry.example.org/repo:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	t.Run("Cosign", func(t *testing.T) {
+		s := Cosign(WithPath(script), WithArgs("--key", "cosign.key"), WithEnv("SIGN_ENV=ok"))
+		err := s.PostPush(ctx, r, d)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		out, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("failed to read args: %v", err)
+		}
+		expect := "ok sign --yes --key cosign.key registry.example.org/repo@" + d.Digest.String()
+		if strings.TrimSpace(string(out)) != expect {
+			t.Errorf("unexpected args, expected %s, received %s", expect, out)
+		}
+	})
+	t.Run("Notation", func(t *testing.T) {
+		s := Notation(WithPath(script), WithArgs("--key", "example"))
+		err := s.PostPush(ctx, r, d)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		out, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("failed to read args: %v", err)
+		}
+		expect := "sign --key example registry.example.org/repo@" + d.Digest.String()
+		if strings.TrimSpace(string(out)) != expect {
+			t.Errorf("unexpected args, expected %s, received %s", expect, out)
+		}
+	})
+	t.Run("Failure", func(t *testing.T) {
+		s := Cosign(WithPath(script), WithEnv("SIGN_ENV=fail"))
+		err := s.PostPush(ctx, r, d)
+		if err == nil || !strings.Contains(err.Error(), "bad key") {
+			t.Errorf("expected the command output in the error, received %v", err)
+		}
+	})
+	t.Run("OCIDir", func(t *testing.T) {
+		rDir, err := ref.New("ocidir://testrepo:v1")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		err = Cosign(WithPath(script)).PostPush(ctx, rDir, d)
+		if !errors.Is(err, types.ErrUnsupported) {
+			t.Errorf("expected unsupported, received %v", err)
+		}
+	})
+}
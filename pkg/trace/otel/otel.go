@@ -0,0 +1,77 @@
+// Package otel adapts an OpenTelemetry TracerProvider to the regclient trace interfaces.
+//
+//	rc := regclient.New(regclient.WithTracerProvider(otel.New(otelProvider)))
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/regclient/regclient/pkg/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type provider struct {
+	tp oteltrace.TracerProvider
+}
+
+type tracer struct {
+	t oteltrace.Tracer
+}
+
+type span struct {
+	s oteltrace.Span
+}
+
+// New returns a TracerProvider that creates spans with the OpenTelemetry TracerProvider.
+func New(tp oteltrace.TracerProvider) trace.TracerProvider {
+	return provider{tp: tp}
+}
+
+// Tracer returns a Tracer for the instrumentation name.
+func (p provider) Tracer(name string) trace.Tracer {
+	return tracer{t: p.tp.Tracer(name)}
+}
+
+// Start creates an OpenTelemetry span with the attributes.
+func (t tracer) Start(ctx context.Context, spanName string, attrs ...trace.Attr) (context.Context, trace.Span) {
+	ctx, s := t.t.Start(ctx, spanName, oteltrace.WithAttributes(attrList(attrs)...))
+	return ctx, span{s: s}
+}
+
+// SetAttributes adds the attributes to the span.
+func (s span) SetAttributes(attrs ...trace.Attr) {
+	s.s.SetAttributes(attrList(attrs)...)
+}
+
+// RecordError adds the error to the span and sets the error status.
+func (s span) RecordError(err error) {
+	s.s.RecordError(err)
+	s.s.SetStatus(codes.Error, err.Error())
+}
+
+// End completes the span.
+func (s span) End() {
+	s.s.End()
+}
+
+func attrList(attrs []trace.Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
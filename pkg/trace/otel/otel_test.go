@@ -0,0 +1,101 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/regclient/regclient/pkg/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type testProvider struct {
+	noop.TracerProvider
+	name  string
+	spans []*testSpan
+}
+
+type testTracer struct {
+	noop.Tracer
+	tp *testProvider
+}
+
+type testSpan struct {
+	noop.Span
+	name   string
+	attrs  map[attribute.Key]attribute.Value
+	err    error
+	status codes.Code
+	ended  bool
+}
+
+func (tp *testProvider) Tracer(name string, _ ...oteltrace.TracerOption) oteltrace.Tracer {
+	tp.name = name
+	return testTracer{tp: tp}
+}
+
+func (tt testTracer) Start(ctx context.Context, spanName string, opts ...oteltrace.SpanStartOption) (context.Context, oteltrace.Span) {
+	span := &testSpan{name: spanName, attrs: map[attribute.Key]attribute.Value{}}
+	conf := oteltrace.NewSpanStartConfig(opts...)
+	span.SetAttributes(conf.Attributes()...)
+	tt.tp.spans = append(tt.tp.spans, span)
+	return ctx, span
+}
+
+func (span *testSpan) SetAttributes(kvs ...attribute.KeyValue) {
+	for _, kv := range kvs {
+		span.attrs[kv.Key] = kv.Value
+	}
+}
+
+func (span *testSpan) RecordError(err error, _ ...oteltrace.EventOption) {
+	span.err = err
+}
+
+func (span *testSpan) SetStatus(code codes.Code, _ string) {
+	span.status = code
+}
+
+func (span *testSpan) End(_ ...oteltrace.SpanEndOption) {
+	span.ended = true
+}
+
+func TestOtel(t *testing.T) {
+	ctx := context.Background()
+	otelTP := &testProvider{}
+	tp := New(otelTP)
+	_, span := tp.Tracer(trace.Name).Start(ctx, "test",
+		trace.Attr{Key: "string", Value: "value"},
+		trace.Attr{Key: "bool", Value: true},
+		trace.Attr{Key: "int", Value: 42},
+	)
+	span.SetAttributes(trace.Attr{Key: "int64", Value: int64(64)}, trace.Attr{Key: "other", Value: 1.5})
+	errTest := errors.New("test error")
+	trace.End(span, errTest)
+
+	if otelTP.name != trace.Name {
+		t.Errorf("unexpected tracer name: %s", otelTP.name)
+	}
+	if len(otelTP.spans) != 1 {
+		t.Fatalf("unexpected spans: %v", otelTP.spans)
+	}
+	s := otelTP.spans[0]
+	if s.name != "test" || !s.ended || !errors.Is(s.err, errTest) || s.status != codes.Error {
+		t.Errorf("unexpected span: %v", s)
+	}
+	expect := map[attribute.Key]attribute.Value{
+		"string": attribute.StringValue("value"),
+		"bool":   attribute.BoolValue(true),
+		"int":    attribute.IntValue(42),
+		"int64":  attribute.Int64Value(64),
+		"other":  attribute.StringValue("1.5"),
+	}
+	for k, v := range expect {
+		if s.attrs[k] != v {
+			t.Errorf("attribute %s, expected %v, received %v", k, v.Emit(), s.attrs[k].Emit())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package trace defines the tracing interface used to create spans around registry operations.
+// The interfaces are modeled after the OpenTelemetry trace API but do not match its signatures,
+// an otel TracerProvider is adapted with the [github.com/regclient/regclient/pkg/trace/otel] package.
+package trace
+
+import (
+	"context"
+)
+
+// TracerProvider returns a Tracer for the instrumentation name.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer creates spans, the returned context contains the new span as the parent for nested spans.
+type Tracer interface {
+	Start(ctx context.Context, spanName string, attrs ...Attr) (context.Context, Span)
+}
+
+// Span is an in progress operation.
+type Span interface {
+	SetAttributes(attrs ...Attr)
+	RecordError(err error)
+	End()
+}
+
+// Attr is a key/value attribute attached to a span.
+// Values are a string, bool, int, or int64.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// Name is the instrumentation name used to request a Tracer from the provider.
+const Name = "github.com/regclient/regclient"
+
+// Start creates a span from the tracer.
+// When the tracer is nil, a span that does nothing is returned with the original context.
+func Start(ctx context.Context, t Tracer, spanName string, attrs ...Attr) (context.Context, Span) {
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+	return t.Start(ctx, spanName, attrs...)
+}
+
+// End records the error, if not nil, and ends the span.
+func End(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attr) {}
+func (noopSpan) RecordError(error)     {}
+func (noopSpan) End()                  {}
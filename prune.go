@@ -0,0 +1,329 @@
+package regclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/referrer"
+	"github.com/sirupsen/logrus"
+)
+
+type pruneOpt struct {
+	keepLast int
+	keepTags []*regexp.Regexp
+	minAge   time.Duration
+	delete   bool
+}
+
+// PruneOpts define options for Prune
+type PruneOpts func(*pruneOpt)
+
+// PruneEntry describes a single tag evaluated by Prune
+type PruneEntry struct {
+	Tag     string        `json:"tag"`
+	Digest  digest.Digest `json:"digest"`
+	Created time.Time     `json:"created,omitempty"`
+	Reason  string        `json:"reason,omitempty"` // reason the tag was kept
+}
+
+// PruneReport contains the tags that were kept and deleted by Prune
+type PruneReport struct {
+	Kept    []PruneEntry `json:"kept"`
+	Deleted []PruneEntry `json:"deleted"`
+	// DeletedDigests are manifests removed because every tag pointing to them was pruned
+	DeletedDigests []digest.Digest `json:"deletedDigests"`
+	// DryRun is true when deletions were computed but not performed
+	DryRun bool `json:"dryRun"`
+}
+
+// PruneWithDelete performs the deletions, without this option only the report is generated.
+func PruneWithDelete() PruneOpts {
+	return func(opts *pruneOpt) {
+		opts.delete = true
+	}
+}
+
+// PruneWithKeepLast keeps the newest count tags, sorted by the image creation time.
+func PruneWithKeepLast(count int) PruneOpts {
+	return func(opts *pruneOpt) {
+		opts.keepLast = count
+	}
+}
+
+// PruneWithKeepTag keeps any tag matching the regular expression.
+// This may be specified multiple times, a tag is kept if any expression matches.
+func PruneWithKeepTag(re *regexp.Regexp) PruneOpts {
+	return func(opts *pruneOpt) {
+		opts.keepTags = append(opts.keepTags, re)
+	}
+}
+
+// PruneWithMinAge keeps any tag for an image created more recently than the age.
+func PruneWithMinAge(age time.Duration) PruneOpts {
+	return func(opts *pruneOpt) {
+		opts.minAge = age
+	}
+}
+
+// Prune applies a retention policy to the tags in a repository.
+// A tag is kept when it matches any of the policies, all other tags are pruned.
+// Manifests are deleted when every tag pointing to them is pruned, otherwise only the tag is deleted.
+// Deletions are only performed when PruneWithDelete is included.
+func (rc *RegClient) Prune(ctx context.Context, r ref.Ref, opts ...PruneOpts) (PruneReport, error) {
+	var opt pruneOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	report := PruneReport{
+		Kept:           []PruneEntry{},
+		Deleted:        []PruneEntry{},
+		DeletedDigests: []digest.Digest{},
+		DryRun:         !opt.delete,
+	}
+	if opt.keepLast <= 0 && len(opt.keepTags) == 0 && opt.minAge <= 0 {
+		return report, fmt.Errorf("prune requires a retention policy%.0w", types.ErrUnsupported)
+	}
+	r.Tag = ""
+	r.Digest = ""
+	tl, err := rc.TagList(ctx, r)
+	if err != nil {
+		return report, err
+	}
+	tags, err := tl.GetTags()
+	if err != nil {
+		return report, err
+	}
+
+	// lookup the digest for each tag, and the created time when the policy depends on it
+	needCreated := opt.keepLast > 0 || opt.minAge > 0
+	entries := make([]PruneEntry, 0, len(tags))
+	for _, tag := range tags {
+		rTag := r
+		rTag.Tag = tag
+		if !needCreated {
+			m, err := rc.ManifestHead(ctx, rTag, WithManifestRequireDigest())
+			if err != nil {
+				return report, fmt.Errorf("failed to get manifest for %s: %w", rTag.CommonName(), err)
+			}
+			entries = append(entries, PruneEntry{
+				Tag:    tag,
+				Digest: m.GetDescriptor().Digest,
+			})
+			continue
+		}
+		m, err := rc.ManifestGet(ctx, rTag)
+		if err != nil {
+			return report, fmt.Errorf("failed to get manifest for %s: %w", rTag.CommonName(), err)
+		}
+		created, err := rc.pruneCreated(ctx, rTag, m)
+		if err != nil {
+			rc.log.WithFields(logrus.Fields{
+				"ref": rTag.CommonName(),
+				"err": err,
+			}).Debug("Unable to determine created time")
+		}
+		entries = append(entries, PruneEntry{
+			Tag:     tag,
+			Digest:  m.GetDescriptor().Digest,
+			Created: created,
+		})
+	}
+	// sort newest first, unknown creation times are treated as oldest
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+
+	// apply the policy
+	now := time.Now()
+	keepDigest := map[digest.Digest]bool{}
+	deleted := []PruneEntry{}
+	for i, e := range entries {
+		switch {
+		case opt.keepLast > 0 && i < opt.keepLast:
+			e.Reason = fmt.Sprintf("keep last %d", opt.keepLast)
+		case opt.minAge > 0 && !e.Created.IsZero() && now.Sub(e.Created) < opt.minAge:
+			e.Reason = fmt.Sprintf("min age %s", opt.minAge.String())
+		default:
+			for _, re := range opt.keepTags {
+				if re.MatchString(e.Tag) {
+					e.Reason = fmt.Sprintf("tag matches %s", re.String())
+					break
+				}
+			}
+		}
+		if e.Reason != "" {
+			keepDigest[e.Digest] = true
+			report.Kept = append(report.Kept, e)
+		} else {
+			deleted = append(deleted, e)
+		}
+	}
+
+	// manifests referenced by a kept index or referring to a kept manifest are also kept,
+	// along with the fallback tags listing the referrers
+	if len(deleted) > 0 {
+		walked := map[digest.Digest]bool{}
+		for _, e := range report.Kept {
+			err = rc.pruneKeepWalk(ctx, r, e.Digest, keepDigest, walked)
+			if err != nil {
+				return report, err
+			}
+		}
+		fallbackTags := map[string]bool{}
+		for d := range walked {
+			rDig := r
+			rDig.Digest = d.String()
+			rFallback, err := referrer.FallbackTag(rDig)
+			if err == nil {
+				fallbackTags[rFallback.Tag] = true
+			}
+		}
+		remaining := []PruneEntry{}
+		for _, e := range deleted {
+			if fallbackTags[e.Tag] {
+				e.Reason = "referrers of a kept manifest"
+				keepDigest[e.Digest] = true
+				report.Kept = append(report.Kept, e)
+			} else {
+				remaining = append(remaining, e)
+			}
+		}
+		deleted = remaining
+	}
+	report.Deleted = deleted
+
+	// delete manifests when no tags remain, otherwise delete the individual tag
+	seen := map[digest.Digest]bool{}
+	for _, e := range deleted {
+		rDel := r
+		if keepDigest[e.Digest] {
+			rDel.Tag = e.Tag
+			rc.log.WithFields(logrus.Fields{
+				"ref":    rDel.CommonName(),
+				"dryRun": !opt.delete,
+			}).Info("Pruning tag")
+			if opt.delete {
+				err = rc.TagDelete(ctx, rDel)
+				if err != nil {
+					return report, fmt.Errorf("failed to delete tag %s: %w", rDel.CommonName(), err)
+				}
+			}
+			continue
+		}
+		if seen[e.Digest] {
+			continue
+		}
+		seen[e.Digest] = true
+		rDel.Digest = e.Digest.String()
+		rc.log.WithFields(logrus.Fields{
+			"ref":    rDel.CommonName(),
+			"dryRun": !opt.delete,
+		}).Info("Pruning manifest")
+		if opt.delete {
+			err = rc.ManifestDelete(ctx, rDel, WithManifestCheckReferrers())
+			if err != nil {
+				return report, fmt.Errorf("failed to delete manifest %s: %w", rDel.CommonName(), err)
+			}
+		}
+		report.DeletedDigests = append(report.DeletedDigests, e.Digest)
+	}
+	return report, nil
+}
+
+// pruneKeepWalk adds the children and referrers of a kept manifest to the keep list.
+func (rc *RegClient) pruneKeepWalk(ctx context.Context, r ref.Ref, d digest.Digest, keep, walked map[digest.Digest]bool) error {
+	if walked[d] {
+		return nil
+	}
+	walked[d] = true
+	keep[d] = true
+	rDig := r
+	rDig.Tag = ""
+	rDig.Digest = d.String()
+	m, err := rc.ManifestGet(ctx, rDig)
+	if err != nil && errors.Is(err, types.ErrNotFound) {
+		// children of an index may be missing from the repository
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get manifest for %s: %w", rDig.CommonName(), err)
+	}
+	if mi, ok := m.(manifest.Indexer); ok {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return err
+		}
+		for _, dChild := range dl {
+			err = rc.pruneKeepWalk(ctx, r, dChild.Digest, keep, walked)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	rl, err := rc.ReferrerList(ctx, rDig)
+	if err != nil {
+		return fmt.Errorf("failed to list referrers for %s: %w", rDig.CommonName(), err)
+	}
+	for _, dRef := range rl.Descriptors {
+		err = rc.pruneKeepWalk(ctx, r, dRef.Digest, keep, walked)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneCreated returns the creation time from the annotations or image config.
+// For an index, the first platform specific image is used.
+func (rc *RegClient) pruneCreated(ctx context.Context, r ref.Ref, m manifest.Manifest) (time.Time, error) {
+	if ma, ok := m.(manifest.Annotator); ok {
+		annot, err := ma.GetAnnotations()
+		if err == nil && annot[types.AnnotationCreated] != "" {
+			t, err := time.Parse(time.RFC3339, annot[types.AnnotationCreated])
+			if err == nil {
+				return t, nil
+			}
+		}
+	}
+	if mi, ok := m.(manifest.Indexer); ok {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, d := range dl {
+			if d.Platform == nil || d.Platform.OS == "unknown" {
+				continue
+			}
+			mp, err := rc.ManifestGet(ctx, r, WithManifestDesc(d))
+			if err != nil {
+				return time.Time{}, err
+			}
+			return rc.pruneCreated(ctx, r, mp)
+		}
+		return time.Time{}, types.ErrNotFound
+	}
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return time.Time{}, types.ErrUnsupportedMediaType
+	}
+	cd, err := mi.GetConfig()
+	if err != nil {
+		return time.Time{}, err
+	}
+	conf, err := rc.BlobGetOCIConfig(ctx, r, cd)
+	if err != nil {
+		return time.Time{}, err
+	}
+	oc := conf.GetConfig()
+	if oc.Created == nil {
+		return time.Time{}, types.ErrNotFound
+	}
+	return *oc.Created, nil
+}
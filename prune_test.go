@@ -0,0 +1,213 @@
+package regclient
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rPrune, err := ref.New("ocidir://testprune")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	// setup a repo with v1, v2, v3, and stable pointing to v3
+	for _, cp := range [][2]string{{"v1", "v1"}, {"v2", "v2"}, {"v3", "v3"}, {"v3", "stable"}} {
+		rSrc, err := ref.New("ocidir://testrepo:" + cp[0])
+		if err != nil {
+			t.Errorf("failed to setup ref: %v", err)
+			return
+		}
+		rTgt := rPrune
+		rTgt.Tag = cp[1]
+		err = rc.ImageCopy(ctx, rSrc, rTgt)
+		if err != nil {
+			t.Errorf("failed to copy %s: %v", cp[0], err)
+			return
+		}
+	}
+	rV1 := rPrune
+	rV1.Tag = "v1"
+	rV3 := rPrune
+	rV3.Tag = "v3"
+	rStable := rPrune
+	rStable.Tag = "stable"
+	m1, err := rc.ManifestHead(ctx, rV1)
+	if err != nil {
+		t.Errorf("failed to head v1: %v", err)
+		return
+	}
+	m3, err := rc.ManifestHead(ctx, rV3)
+	if err != nil {
+		t.Errorf("failed to head v3: %v", err)
+		return
+	}
+
+	t.Run("No Policy", func(t *testing.T) {
+		_, err := rc.Prune(ctx, rPrune)
+		if err == nil || !errors.Is(err, types.ErrUnsupported) {
+			t.Errorf("prune without policy did not fail: %v", err)
+		}
+	})
+	t.Run("Min Age", func(t *testing.T) {
+		report, err := rc.Prune(ctx, rPrune, PruneWithMinAge(time.Hour*24*365*100))
+		if err != nil {
+			t.Errorf("prune failed: %v", err)
+			return
+		}
+		if len(report.Kept) != 4 || len(report.Deleted) != 0 {
+			t.Errorf("unexpected report, kept %d, deleted %d", len(report.Kept), len(report.Deleted))
+		}
+	})
+	t.Run("Dry Run", func(t *testing.T) {
+		report, err := rc.Prune(ctx, rPrune, PruneWithKeepTag(regexp.MustCompile(`^stable$`)))
+		if err != nil {
+			t.Errorf("prune failed: %v", err)
+			return
+		}
+		if !report.DryRun {
+			t.Errorf("dry run not reported")
+		}
+		if len(report.Kept) != 1 || report.Kept[0].Tag != "stable" {
+			t.Errorf("unexpected kept entries: %v", report.Kept)
+		}
+		if len(report.Deleted) != 3 {
+			t.Errorf("unexpected deleted entries: %v", report.Deleted)
+		}
+		if len(report.DeletedDigests) != 2 {
+			t.Errorf("unexpected deleted digests: %v", report.DeletedDigests)
+		}
+		_, err = rc.ManifestHead(ctx, rV1)
+		if err != nil {
+			t.Errorf("dry run deleted v1: %v", err)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		report, err := rc.Prune(ctx, rPrune, PruneWithKeepTag(regexp.MustCompile(`^stable$`)), PruneWithDelete())
+		if err != nil {
+			t.Errorf("prune failed: %v", err)
+			return
+		}
+		if report.DryRun {
+			t.Errorf("delete reported as a dry run")
+		}
+		for _, d := range report.DeletedDigests {
+			if d == m3.GetDescriptor().Digest {
+				t.Errorf("digest for kept tag was deleted")
+			}
+		}
+		tl, err := rc.TagList(ctx, rPrune)
+		if err != nil {
+			t.Errorf("failed to list tags: %v", err)
+			return
+		}
+		tags, _ := tl.GetTags()
+		if len(tags) != 1 || tags[0] != "stable" {
+			t.Errorf("unexpected tags after prune: %v", tags)
+		}
+		rDig := rPrune
+		rDig.Digest = m1.GetDescriptor().Digest.String()
+		_, err = rc.ManifestHead(ctx, rDig)
+		if err == nil {
+			t.Errorf("manifest for v1 was not deleted")
+		}
+		_, err = rc.ManifestHead(ctx, rStable)
+		if err != nil {
+			t.Errorf("stable tag missing: %v", err)
+		}
+	})
+	t.Run("Keep Children and Referrers", func(t *testing.T) {
+		rRepo, err := ref.New("ocidir://testprunechild")
+		if err != nil {
+			t.Fatalf("failed to setup ref: %v", err)
+		}
+		rSrc, err := ref.New("ocidir://testrepo:v3")
+		if err != nil {
+			t.Fatalf("failed to setup ref: %v", err)
+		}
+		rKeep := rRepo
+		rKeep.Tag = "stable"
+		err = rc.ImageCopy(ctx, rSrc, rKeep)
+		if err != nil {
+			t.Fatalf("failed to copy: %v", err)
+		}
+		mIdx, err := rc.ManifestGet(ctx, rKeep)
+		if err != nil {
+			t.Fatalf("failed to get index: %v", err)
+		}
+		dl, err := mIdx.(manifest.Indexer).GetManifestList()
+		if err != nil || len(dl) == 0 {
+			t.Fatalf("failed to get manifest list: %v", err)
+		}
+		// tag a platform specific child of the kept index
+		rChild := rRepo
+		rChild.Digest = dl[0].Digest.String()
+		mChild, err := rc.ManifestGet(ctx, rChild)
+		if err != nil {
+			t.Fatalf("failed to get child: %v", err)
+		}
+		rChildTag := rRepo
+		rChildTag.Tag = "stable-child"
+		err = rc.ManifestPut(ctx, rChildTag, mChild)
+		if err != nil {
+			t.Fatalf("failed to tag child: %v", err)
+		}
+		// tag a referrer to the kept index
+		idxDesc := mIdx.GetDescriptor()
+		mRef, err := manifest.New(manifest.WithOrig(v1.Manifest{
+			Versioned:    v1.ManifestSchemaVersion,
+			MediaType:    types.MediaTypeOCI1Manifest,
+			ArtifactType: "application/example.sig",
+			Config:       types.EmptyDescriptor(),
+			Layers:       []types.Descriptor{types.EmptyDescriptor()},
+			Subject:      &types.Descriptor{MediaType: idxDesc.MediaType, Digest: idxDesc.Digest, Size: idxDesc.Size},
+		}))
+		if err != nil {
+			t.Fatalf("failed to create referrer: %v", err)
+		}
+		rRefTag := rRepo
+		rRefTag.Tag = "stable-sig"
+		err = rc.ManifestPut(ctx, rRefTag, mRef)
+		if err != nil {
+			t.Fatalf("failed to put referrer: %v", err)
+		}
+		report, err := rc.Prune(ctx, rRepo, PruneWithKeepTag(regexp.MustCompile(`^stable$`)), PruneWithDelete())
+		if err != nil {
+			t.Fatalf("prune failed: %v", err)
+		}
+		if len(report.Deleted) != 2 {
+			t.Errorf("unexpected deleted entries: %v", report.Deleted)
+		}
+		if len(report.DeletedDigests) != 0 {
+			t.Errorf("manifests of a kept image were deleted: %v", report.DeletedDigests)
+		}
+		_, err = rc.ManifestHead(ctx, rChild)
+		if err != nil {
+			t.Errorf("child of kept index was deleted: %v", err)
+		}
+		rRefDig := rRepo
+		rRefDig.Digest = mRef.GetDescriptor().Digest.String()
+		_, err = rc.ManifestHead(ctx, rRefDig)
+		if err != nil {
+			t.Errorf("referrer of kept index was deleted: %v", err)
+		}
+	})
+}
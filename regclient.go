@@ -2,6 +2,7 @@
 package regclient
 
 import (
+	"context"
 	"io"
 	"time"
 
@@ -9,10 +10,16 @@ import (
 
 	"github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/internal/throttle"
 	"github.com/regclient/regclient/internal/version"
+	"github.com/regclient/regclient/pkg/trace"
 	"github.com/regclient/regclient/scheme"
-	"github.com/regclient/regclient/scheme/ocidir"
+	_ "github.com/regclient/regclient/scheme/containerd" // registers the containerd scheme with the containerd build tag
+	_ "github.com/regclient/regclient/scheme/cstorage"   // registers the containers-storage scheme
+	_ "github.com/regclient/regclient/scheme/dockerd"    // registers the docker-daemon scheme
+	_ "github.com/regclient/regclient/scheme/ocidir"     // registers the ocidir scheme
 	"github.com/regclient/regclient/scheme/reg"
+	"github.com/regclient/regclient/types/ref"
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,10 +41,14 @@ type RegClient struct {
 	hosts map[string]*config.Host
 	log   *logrus.Logger
 	// mu        sync.Mutex
-	regOpts   []reg.Opts
-	schemes   map[string]scheme.API
-	userAgent string
-	fs        rwfs.RWFS
+	blobThrottle  *throttle.Throttle
+	pushHooks     []PushHook
+	postPushHooks []PostPushHook
+	regOpts       []reg.Opts
+	schemes       map[string]scheme.API
+	tracer        trace.Tracer
+	userAgent     string
+	fs            rwfs.RWFS
 }
 
 // Opt functions are used to configure NewRegClient
@@ -80,12 +91,21 @@ func New(opts ...Opt) *RegClient {
 		reg.WithUserAgent(rc.userAgent),
 	)
 
-	// setup scheme's
-	rc.schemes["reg"] = reg.New(rc.regOpts...)
-	rc.schemes["ocidir"] = ocidir.New(
-		ocidir.WithLog(rc.log),
-		ocidir.WithFS(rc.fs),
-	)
+	// setup scheme's, skipping any added with WithScheme
+	if _, ok := rc.schemes["reg"]; !ok {
+		rc.schemes["reg"] = reg.New(rc.regOpts...)
+	}
+	schemeConf := scheme.Config{
+		Hosts:     hostList,
+		FS:        rc.fs,
+		Log:       rc.log,
+		UserAgent: rc.userAgent,
+	}
+	for name, f := range scheme.Registered() {
+		if _, ok := rc.schemes[name]; !ok {
+			rc.schemes[name] = f(schemeConf)
+		}
+	}
 
 	rc.log.WithFields(logrus.Fields{
 		"VCSRef": info.VCSRef,
@@ -104,6 +124,16 @@ func WithBlobLimit(limit int64) Opt {
 	}
 }
 
+// WithBlobConcurrent limits the number of blobs copied concurrently with this client.
+// The limit is a budget shared by every image copy, in addition to the reqConcurrent setting of each registry.
+func WithBlobConcurrent(count int) Opt {
+	return func(rc *RegClient) {
+		if count > 0 {
+			rc.blobThrottle = throttle.New(count)
+		}
+	}
+}
+
 // WithBlobSize overrides default blob sizes
 //
 // Deprecated: replace with WithRegOpts(reg.WithBlobSize(chunk, max))
@@ -198,6 +228,24 @@ func WithRetryLimit(retryLimit int) Opt {
 	}
 }
 
+// WithScheme adds or replaces the API used for a reference scheme on this RegClient.
+// References with the scheme are parsed with RefNew, since ref.New only parses the registered schemes.
+// To add a scheme to every RegClient, see scheme.Register.
+func WithScheme(name string, api scheme.API) Opt {
+	return func(rc *RegClient) {
+		rc.schemes[name] = api
+	}
+}
+
+// WithTracerProvider creates spans around manifest, blob, and tag operations, and each request to a registry.
+// An OpenTelemetry TracerProvider requires an adapter, see the trace package.
+func WithTracerProvider(tp trace.TracerProvider) Opt {
+	return func(rc *RegClient) {
+		rc.tracer = tp.Tracer(trace.Name)
+		rc.regOpts = append(rc.regOpts, reg.WithTracerProvider(tp))
+	}
+}
+
 // WithUserAgent specifies the User-Agent http header
 func WithUserAgent(ua string) Opt {
 	return func(rc *RegClient) {
@@ -230,6 +278,8 @@ func (rc *RegClient) hostLoad(src string, hosts []config.Host) {
 			"api":        configHost.API,
 			"blobMax":    configHost.BlobMax,
 			"blobChunk":  configHost.BlobChunk,
+			"blobRate":   configHost.BlobRate,
+			"digestOnly": configHost.DigestOnly,
 		}).Debugf("Loading %s config", src)
 		err := rc.hostSet(configHost)
 		if err != nil {
@@ -243,14 +293,16 @@ func (rc *RegClient) hostLoad(src string, hosts []config.Host) {
 }
 
 func (rc *RegClient) hostSet(newHost config.Host) error {
-	name := newHost.Name
+	// hosts are keyed by the registry in a reference, the configured name may include a scheme or path
+	h := config.HostNewName(newHost.Name)
+	name := h.Name
 	var err error
 	// hostSet should only run on New, which single threaded
 	// rc.mu.Lock()
 	// defer rc.mu.Unlock()
 	if _, ok := rc.hosts[name]; !ok {
 		// merge newHost with default host settings
-		rc.hosts[name] = config.HostNewName(name)
+		rc.hosts[name] = h
 		err = rc.hosts[name].Merge(newHost, nil)
 	} else {
 		// merge newHost with existing settings
@@ -261,3 +313,17 @@ func (rc *RegClient) hostSet(newHost config.Host) error {
 	}
 	return nil
 }
+
+// traceStart creates a span for an operation on a reference
+func (rc *RegClient) traceStart(ctx context.Context, name string, r ref.Ref) (context.Context, trace.Span) {
+	repo := r.Repository
+	if repo == "" {
+		// ocidir references use a path instead of a repository
+		repo = r.Path
+	}
+	return trace.Start(ctx, rc.tracer, "regclient."+name,
+		trace.Attr{Key: "registry.host", Value: r.Registry},
+		trace.Attr{Key: "registry.repository", Value: repo},
+		trace.Attr{Key: "regclient.reference", Value: r.CommonName()},
+	)
+}
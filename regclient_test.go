@@ -1,9 +1,24 @@
 package regclient
 
 import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
 	"testing"
 
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/pkg/trace"
+	"github.com/regclient/regclient/scheme/ocidir"
 	"github.com/regclient/regclient/scheme/reg"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
 	"github.com/sirupsen/logrus"
 )
 
@@ -100,3 +115,281 @@ func TestNew(t *testing.T) {
 	}
 
 }
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+type testSpan struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+type testSpanKey struct{}
+
+func (tt *testTracer) Tracer(name string) trace.Tracer {
+	return tt
+}
+
+func (tt *testTracer) Start(ctx context.Context, spanName string, attrs ...trace.Attr) (context.Context, trace.Span) {
+	span := &testSpan{name: spanName, attrs: map[string]interface{}{}}
+	if parent, ok := ctx.Value(testSpanKey{}).(*testSpan); ok {
+		span.parent = parent.name
+	}
+	span.SetAttributes(attrs...)
+	tt.mu.Lock()
+	tt.spans = append(tt.spans, span)
+	tt.mu.Unlock()
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func (span *testSpan) SetAttributes(attrs ...trace.Attr) {
+	for _, a := range attrs {
+		span.attrs[a.Key] = a.Value
+	}
+}
+
+func (span *testSpan) RecordError(err error) {
+	span.err = err
+}
+
+func (span *testSpan) End() {
+	span.ended = true
+}
+
+func TestTracer(t *testing.T) {
+	ctx := context.Background()
+	t.Run("ocidir", func(t *testing.T) {
+		fsOS := rwfs.OSNew("")
+		fsMem := rwfs.MemNew()
+		err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+		if err != nil {
+			t.Errorf("failed to setup memfs copy: %v", err)
+			return
+		}
+		tt := &testTracer{}
+		rc := New(WithFS(fsMem), WithTracerProvider(tt))
+		r, err := ref.New("ocidir://testrepo:v1")
+		if err != nil {
+			t.Errorf("failed to setup ref: %v", err)
+			return
+		}
+		_, err = rc.ManifestGet(ctx, r)
+		if err != nil {
+			t.Errorf("failed to get manifest: %v", err)
+			return
+		}
+		r.Tag = "missing"
+		_, err = rc.ManifestHead(ctx, r)
+		if err == nil {
+			t.Errorf("head of missing tag did not fail")
+		}
+		if len(tt.spans) != 2 {
+			t.Fatalf("unexpected spans: %v", tt.spans)
+		}
+		if tt.spans[0].name != "regclient.ManifestGet" || !tt.spans[0].ended || tt.spans[0].err != nil || tt.spans[0].attrs["registry.repository"] != "testrepo" {
+			t.Errorf("unexpected get span: %v", tt.spans[0])
+		}
+		if tt.spans[1].name != "regclient.ManifestHead" || !tt.spans[1].ended || tt.spans[1].err == nil {
+			t.Errorf("unexpected head span: %v", tt.spans[1])
+		}
+	})
+	t.Run("blob", func(t *testing.T) {
+		fsOS := rwfs.OSNew("")
+		fsMem := rwfs.MemNew()
+		err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+		if err != nil {
+			t.Fatalf("failed to setup memfs copy: %v", err)
+		}
+		rc := New(WithFS(fsMem))
+		r, err := ref.New("ocidir://testrepo:v1")
+		if err != nil {
+			t.Fatalf("failed to setup ref: %v", err)
+		}
+		m, err := rc.ManifestGet(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		if m.IsList() {
+			dPlat, err := manifest.GetPlatformDesc(m, &platform.Platform{OS: "linux", Architecture: "amd64"})
+			if err != nil {
+				t.Fatalf("failed to get platform: %v", err)
+			}
+			m, err = rc.ManifestGet(ctx, r, WithManifestDesc(*dPlat))
+			if err != nil {
+				t.Fatalf("failed to get platform manifest: %v", err)
+			}
+		}
+		mi, ok := m.(manifest.Imager)
+		if !ok {
+			t.Fatalf("manifest is not an image")
+		}
+		d, err := mi.GetConfig()
+		if err != nil {
+			t.Fatalf("failed to get config: %v", err)
+		}
+		tt := &testTracer{}
+		rc = New(WithFS(fsMem), WithTracerProvider(tt))
+		br, err := rc.BlobGet(ctx, r, d)
+		if err != nil {
+			t.Fatalf("failed to get blob: %v", err)
+		}
+		if len(tt.spans) < 1 || tt.spans[0].name != "regclient.BlobGet" {
+			t.Fatalf("unexpected spans: %v", tt.spans)
+		}
+		if tt.spans[0].ended {
+			t.Errorf("blob span ended before the reader was closed")
+		}
+		err = br.Close()
+		if err != nil {
+			t.Errorf("failed to close blob: %v", err)
+		}
+		if !tt.spans[0].ended || tt.spans[0].err != nil {
+			t.Errorf("unexpected blob span: %v", tt.spans[0])
+		}
+	})
+	t.Run("reg", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+		tsURL, _ := url.Parse(ts.URL)
+		tt := &testTracer{}
+		rc := New(
+			WithConfigHost(config.Host{Name: tsURL.Host, TLS: config.TLSDisabled}),
+			WithTracerProvider(tt),
+		)
+		r, err := ref.New(tsURL.Host + "/project:v1")
+		if err != nil {
+			t.Errorf("failed to setup ref: %v", err)
+			return
+		}
+		_, err = rc.ManifestHead(ctx, r)
+		if err == nil || !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(tt.spans) < 2 {
+			t.Fatalf("unexpected spans: %v", tt.spans)
+		}
+		if tt.spans[0].name != "regclient.ManifestHead" || tt.spans[0].err == nil {
+			t.Errorf("unexpected head span: %v", tt.spans[0])
+		}
+		req := tt.spans[1]
+		if req.name != "regclient.request" || req.parent != "regclient.ManifestHead" || req.attrs["http.request.method"] != "HEAD" ||
+			req.attrs["regclient.attempt"] != 1 || req.attrs["http.response.status_code"] != http.StatusNotFound || req.err == nil || !req.ended {
+			t.Errorf("unexpected request span: %v", req)
+		}
+	})
+}
+
+func TestIPv6Host(t *testing.T) {
+	ctx := context.Background()
+	user, pass := "testuser", "testpass"
+	mDigest := "sha256:15f840677a5e245d9ea199eb9b026b1539208a5183621dced7b469f6aa678115"
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != pass {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodHead || r.URL.Path != "/v2/proj/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", types.MediaTypeOCI1Manifest)
+		w.Header().Set("Content-Length", "100")
+		w.Header().Set("Docker-Content-Digest", mDigest)
+		w.WriteHeader(http.StatusOK)
+	}))
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("ipv6 loopback is unavailable: %v", err)
+	}
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	if tsHost[0] != '[' {
+		t.Fatalf("unexpected host: %s", tsHost)
+	}
+	r, err := ref.New(tsHost + "/proj:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	if r.Registry != tsHost {
+		t.Fatalf("unexpected registry, expected %s, received %s", tsHost, r.Registry)
+	}
+	// credentials are found by the registry when the config includes a scheme
+	for _, name := range []string{tsHost, "http://" + tsHost, "http://" + tsHost + "/v1/"} {
+		t.Run(name, func(t *testing.T) {
+			rc := New(WithConfigHost(config.Host{
+				Name:      name,
+				TLS:       config.TLSDisabled,
+				User:      user,
+				Pass:      pass,
+				ReqPerSec: 100,
+			}))
+			m, err := rc.ManifestHead(ctx, r)
+			if err != nil {
+				t.Fatalf("failed to head manifest: %v", err)
+			}
+			if m.GetDescriptor().Digest.String() != mDigest {
+				t.Errorf("unexpected digest: %s", m.GetDescriptor().Digest.String())
+			}
+		})
+	}
+}
+
+func TestWithScheme(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	fsTest := rwfs.MemNew()
+	rc := New(WithFS(fsMem), WithScheme("schemetest", ocidir.New(ocidir.WithFS(fsTest))))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	// the scheme is only added to this RegClient
+	if _, err := ref.New("schemetest://copy:v1"); err == nil {
+		t.Errorf("scheme from WithScheme was registered globally")
+	}
+	if _, err := New().RefNew("schemetest://copy:v1"); err == nil {
+		t.Errorf("scheme from WithScheme was added to another RegClient")
+	}
+	rTgt, err := rc.RefNew("schemetest://copy:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	err = rc.ImageCopy(ctx, rSrc, rTgt)
+	if err != nil {
+		t.Fatalf("failed to copy image: %v", err)
+	}
+	mSrc, err := rc.ManifestHead(ctx, rSrc)
+	if err != nil {
+		t.Fatalf("failed to head source: %v", err)
+	}
+	mTgt, err := rc.ManifestHead(ctx, rTgt)
+	if err != nil {
+		t.Fatalf("failed to head target: %v", err)
+	}
+	if mSrc.GetDescriptor().Digest != mTgt.GetDescriptor().Digest {
+		t.Errorf("digest mismatch, expected %s, received %s", mSrc.GetDescriptor().Digest, mTgt.GetDescriptor().Digest)
+	}
+	// content is written to the scheme's filesystem
+	if _, err := rwfs.Stat(fsTest, "copy/index.json"); err != nil {
+		t.Errorf("index missing from scheme filesystem: %v", err)
+	}
+	if _, err := rwfs.Stat(fsMem, "copy/index.json"); err == nil {
+		t.Errorf("index written to the ocidir filesystem")
+	}
+}
@@ -2,12 +2,155 @@ package regclient
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
 
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/rematch"
 	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/scheme/reg"
 	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
 	"github.com/regclient/regclient/types/repo"
+	"github.com/sirupsen/logrus"
 )
 
+type repoCopyOpt struct {
+	include   []*regexp.Regexp
+	exclude   []*regexp.Regexp
+	prune     bool
+	imageOpts []ImageOpts
+}
+
+// RepoCopyOpts define options for RepoCopy
+type RepoCopyOpts func(*repoCopyOpt)
+
+// RepoCopyReport lists the tags copied, unchanged, and pruned by RepoCopy
+type RepoCopyReport struct {
+	Source    string   `json:"source"`
+	Target    string   `json:"target"`
+	Copied    []string `json:"copied"`
+	Unchanged []string `json:"unchanged"`
+	Pruned    []string `json:"pruned"`
+}
+
+// RepoCopyWithExclude skips any tag matching the regular expression.
+// This may be specified multiple times, a tag is skipped if any expression matches.
+func RepoCopyWithExclude(re *regexp.Regexp) RepoCopyOpts {
+	return func(opts *repoCopyOpt) {
+		opts.exclude = append(opts.exclude, re)
+	}
+}
+
+// RepoCopyWithImageOpts includes options for each ImageCopy, e.g. ImageWithReferrers.
+func RepoCopyWithImageOpts(imageOpts ...ImageOpts) RepoCopyOpts {
+	return func(opts *repoCopyOpt) {
+		opts.imageOpts = append(opts.imageOpts, imageOpts...)
+	}
+}
+
+// RepoCopyWithInclude only copies tags matching the regular expression.
+// This may be specified multiple times, a tag is copied if any expression matches.
+func RepoCopyWithInclude(re *regexp.Regexp) RepoCopyOpts {
+	return func(opts *repoCopyOpt) {
+		opts.include = append(opts.include, re)
+	}
+}
+
+// RepoCopyWithPrune deletes tags in the target that are not in the source.
+// Tags filtered out by the include and exclude expressions are not pruned.
+func RepoCopyWithPrune() RepoCopyOpts {
+	return func(opts *repoCopyOpt) {
+		opts.prune = true
+	}
+}
+
+// RepoCopy copies every tag from the source repository to the target repository.
+// Images that already exist in the target are skipped, making this safe to repeat for an incremental sync.
+// The tag and digest of each reference are ignored.
+func (rc *RegClient) RepoCopy(ctx context.Context, rSrc, rTgt ref.Ref, opts ...RepoCopyOpts) (RepoCopyReport, error) {
+	var opt repoCopyOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	rSrc.Tag, rSrc.Digest = "", ""
+	rTgt.Tag, rTgt.Digest = "", ""
+	report := RepoCopyReport{
+		Source:    rSrc.CommonName(),
+		Target:    rTgt.CommonName(),
+		Copied:    []string{},
+		Unchanged: []string{},
+		Pruned:    []string{},
+	}
+	tl, err := rc.TagList(ctx, rSrc)
+	if err != nil {
+		return report, fmt.Errorf("failed to list tags for %s: %w", rSrc.CommonName(), err)
+	}
+	tags, err := tl.GetTags()
+	if err != nil {
+		return report, err
+	}
+	srcTags := map[string]bool{}
+	for _, tag := range tags {
+		if !opt.match(tag) {
+			continue
+		}
+		srcTags[tag] = true
+		rSrcTag := rSrc
+		rSrcTag.Tag = tag
+		rTgtTag := rTgt
+		rTgtTag.Tag = tag
+		result := ImageCopyResult{}
+		rc.log.WithFields(logrus.Fields{
+			"source": rSrcTag.CommonName(),
+			"target": rTgtTag.CommonName(),
+		}).Debug("Repo copy image")
+		err = rc.ImageCopy(ctx, rSrcTag, rTgtTag, append(opt.imageOpts, ImageWithCopyResult(&result))...)
+		if err != nil {
+			return report, fmt.Errorf("failed to copy %s: %w", rSrcTag.CommonName(), err)
+		}
+		if result.Unchanged {
+			report.Unchanged = append(report.Unchanged, tag)
+		} else {
+			report.Copied = append(report.Copied, tag)
+		}
+	}
+	if !opt.prune {
+		return report, nil
+	}
+	tlTgt, err := rc.TagList(ctx, rTgt)
+	if err != nil && (errors.Is(err, types.ErrNotFound) || errors.Is(err, fs.ErrNotExist)) {
+		// nothing to prune in a missing repository
+		return report, nil
+	} else if err != nil {
+		return report, fmt.Errorf("failed to list tags for %s: %w", rTgt.CommonName(), err)
+	}
+	tgtTags, err := tlTgt.GetTags()
+	if err != nil {
+		return report, err
+	}
+	for _, tag := range tgtTags {
+		if srcTags[tag] || !opt.match(tag) {
+			continue
+		}
+		rTgtTag := rTgt
+		rTgtTag.Tag = tag
+		err = rc.TagDelete(ctx, rTgtTag)
+		if err != nil {
+			return report, fmt.Errorf("failed to prune %s: %w", rTgtTag.CommonName(), err)
+		}
+		report.Pruned = append(report.Pruned, tag)
+	}
+	return report, nil
+}
+
+// match returns true when the tag is included and not excluded
+func (opt repoCopyOpt) match(tag string) bool {
+	return rematch.Match(tag, opt.include, opt.exclude)
+}
+
 type repoLister interface {
 	RepoList(ctx context.Context, hostname string, opts ...scheme.RepoOpts) (*repo.RepoList, error)
 }
@@ -26,3 +169,52 @@ func (rc *RegClient) RepoList(ctx context.Context, hostname string, opts ...sche
 	return rl.RepoList(ctx, hostname, opts...)
 
 }
+
+type repoSearcher interface {
+	RepoSearch(ctx context.Context, hostname, query string, opts ...scheme.RepoOpts) (reg.SearchList, error)
+}
+
+// RepoSearch returns repositories on a registry matching a query, including the description and star count.
+// This is supported on Docker Hub, and registries configured with the harbor or quay API profile.
+// Use scheme.WithRepoLimit to limit the number of results.
+func (rc *RegClient) RepoSearch(ctx context.Context, hostname, query string, opts ...scheme.RepoOpts) (reg.SearchList, error) {
+	schemeAPI, err := rc.schemeGet("reg")
+	if err != nil {
+		return reg.SearchList{}, err
+	}
+	rs, ok := schemeAPI.(repoSearcher)
+	if !ok {
+		return reg.SearchList{}, types.ErrNotImplemented
+	}
+	return rs.RepoSearch(ctx, hostname, query, opts...)
+}
+
+// RepoGarbageCollect removes blobs in a repository that are not referenced by any manifest.
+// This is supported by OCI Layouts (ocidir), where content remains after tags are deleted or overwritten.
+// Use scheme.WithGCDryRun to list the blobs without deleting them.
+func (rc *RegClient) RepoGarbageCollect(ctx context.Context, r ref.Ref, opts ...scheme.GCOpts) ([]digest.Digest, error) {
+	schemeAPI, err := rc.schemeGet(r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	gc, ok := schemeAPI.(scheme.GarbageCollector)
+	if !ok {
+		return nil, fmt.Errorf("garbage collection is not supported by %s%.0w", r.Scheme, types.ErrUnsupportedAPI)
+	}
+	return gc.GarbageCollect(ctx, r, opts...)
+}
+
+// RepoVerify recomputes the digest of every blob in a repository and checks the index for missing content.
+// This is supported by OCI Layouts (ocidir), for layouts stored on unreliable media.
+// Use scheme.WithVerifyRepair to rename or quarantine corrupt blobs and remove index entries for missing manifests.
+func (rc *RegClient) RepoVerify(ctx context.Context, r ref.Ref, opts ...scheme.VerifyOpts) (scheme.VerifyReport, error) {
+	schemeAPI, err := rc.schemeGet(r.Scheme)
+	if err != nil {
+		return scheme.VerifyReport{}, err
+	}
+	v, ok := schemeAPI.(scheme.Verifier)
+	if !ok {
+		return scheme.VerifyReport{}, fmt.Errorf("verify is not supported by %s%.0w", r.Scheme, types.ErrUnsupportedAPI)
+	}
+	return v.Verify(ctx, r, opts...)
+}
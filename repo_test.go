@@ -0,0 +1,97 @@
+package regclient
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestRepoCopy(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	rTgt, err := ref.New("ocidir://testcopy")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	reRelease := regexp.MustCompile(`^v[0-9]+$`)
+
+	t.Run("Copy", func(t *testing.T) {
+		report, err := rc.RepoCopy(ctx, rSrc, rTgt, RepoCopyWithInclude(reRelease), RepoCopyWithExclude(regexp.MustCompile(`^v3$`)))
+		if err != nil {
+			t.Fatalf("failed to copy repo: %v", err)
+		}
+		if !cmpSliceString(report.Copied, []string{"v1", "v2"}) || len(report.Unchanged) != 0 || len(report.Pruned) != 0 {
+			t.Errorf("unexpected report: %v", report)
+		}
+	})
+	t.Run("Repeat", func(t *testing.T) {
+		report, err := rc.RepoCopy(ctx, rSrc, rTgt, RepoCopyWithInclude(reRelease))
+		if err != nil {
+			t.Fatalf("failed to copy repo: %v", err)
+		}
+		if !cmpSliceString(report.Copied, []string{"v3"}) || !cmpSliceString(report.Unchanged, []string{"v1", "v2"}) {
+			t.Errorf("unexpected report: %v", report)
+		}
+	})
+	t.Run("Prune", func(t *testing.T) {
+		rDel := rSrc
+		rDel.Tag = "v2"
+		err := rc.TagDelete(ctx, rDel)
+		if err != nil {
+			t.Fatalf("failed to delete tag: %v", err)
+		}
+		report, err := rc.RepoCopy(ctx, rSrc, rTgt, RepoCopyWithInclude(reRelease), RepoCopyWithPrune())
+		if err != nil {
+			t.Fatalf("failed to copy repo: %v", err)
+		}
+		if !cmpSliceString(report.Pruned, []string{"v2"}) || len(report.Copied) != 0 {
+			t.Errorf("unexpected report: %v", report)
+		}
+		tl, err := rc.TagList(ctx, rTgt)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		tags, _ := tl.GetTags()
+		if !cmpSliceString(tags, []string{"v1", "v3"}) {
+			t.Errorf("unexpected tags: %v", tags)
+		}
+	})
+	t.Run("Prune missing target", func(t *testing.T) {
+		rMissing, err := ref.New("ocidir://testmissing")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		report, err := rc.RepoCopy(ctx, rSrc, rMissing, RepoCopyWithInclude(regexp.MustCompile(`^none$`)), RepoCopyWithPrune())
+		if err != nil {
+			t.Fatalf("failed to copy repo: %v", err)
+		}
+		if len(report.Copied) != 0 || len(report.Pruned) != 0 {
+			t.Errorf("unexpected report: %v", report)
+		}
+	})
+}
+
+func cmpSliceString(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package regclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// ImageScanner scans an image for vulnerabilities before it is copied, see ImageWithScanner.
+// Scan is called with the source reference, including the digest, and the descriptor of the source manifest.
+// For an index, Scan is called for each copied platform with the descriptor of the platform manifest.
+// Returning an error fails the copy.
+type ImageScanner interface {
+	Scan(ctx context.Context, r ref.Ref, d types.Descriptor) (*ImageScanResult, error)
+}
+
+// ImageScannerFunc adapts a function to the ImageScanner interface
+type ImageScannerFunc func(ctx context.Context, r ref.Ref, d types.Descriptor) (*ImageScanResult, error)
+
+// Scan calls the function
+func (fn ImageScannerFunc) Scan(ctx context.Context, r ref.Ref, d types.Descriptor) (*ImageScanResult, error) {
+	return fn(ctx, r, d)
+}
+
+// ScanSeverity is the severity of a scan finding, ordered from unknown to critical
+type ScanSeverity int
+
+const (
+	// ScanSeverityUnknown is used for findings without a known severity
+	ScanSeverityUnknown ScanSeverity = iota
+	ScanSeverityNegligible
+	ScanSeverityLow
+	ScanSeverityMedium
+	ScanSeverityHigh
+	ScanSeverityCritical
+)
+
+var scanSeverityNames = []string{"unknown", "negligible", "low", "medium", "high", "critical"}
+
+// ParseScanSeverity converts a severity name, ignoring case, to a ScanSeverity
+func ParseScanSeverity(s string) (ScanSeverity, error) {
+	for i, name := range scanSeverityNames {
+		if strings.EqualFold(s, name) {
+			return ScanSeverity(i), nil
+		}
+	}
+	return ScanSeverityUnknown, fmt.Errorf("unknown severity %q, expected one of %s%.0w", s, strings.Join(scanSeverityNames, ", "), types.ErrParsingFailed)
+}
+
+// String returns the name of the severity
+func (sev ScanSeverity) String() string {
+	return scanSeverityNames[sev.index()]
+}
+
+// index limits the severity to the known values
+func (sev ScanSeverity) index() int {
+	if sev < 0 || int(sev) >= len(scanSeverityNames) {
+		return int(ScanSeverityUnknown)
+	}
+	return int(sev)
+}
+
+// MarshalText outputs the name of the severity
+func (sev ScanSeverity) MarshalText() ([]byte, error) {
+	return []byte(sev.String()), nil
+}
+
+// UnmarshalText parses the name of the severity
+func (sev *ScanSeverity) UnmarshalText(b []byte) error {
+	parsed, err := ParseScanSeverity(string(b))
+	if err != nil {
+		return err
+	}
+	*sev = parsed
+	return nil
+}
+
+// ImageScanResult is the report from an ImageScanner
+type ImageScanResult struct {
+	Scanner   string             `json:"scanner"`             // name of the scanner
+	Digest    string             `json:"digest"`              // digest of the source manifest
+	Findings  []ImageScanFinding `json:"findings"`            // vulnerabilities found in the image
+	Threshold ScanSeverity       `json:"threshold,omitempty"` // findings at or above the threshold veto the copy
+	Vetoed    bool               `json:"vetoed,omitempty"`    // the copy was refused
+}
+
+// ImageScanFinding is a single vulnerability found by a scan
+type ImageScanFinding struct {
+	ID       string       `json:"id"`                 // vulnerability identifier, e.g. a CVE
+	Severity ScanSeverity `json:"severity"`           // severity of the vulnerability
+	Package  string       `json:"package,omitempty"`  // name of the affected package
+	Version  string       `json:"version,omitempty"`  // installed version of the package
+	FixedIn  string       `json:"fixedIn,omitempty"`  // version with a fix, when available
+	Platform string       `json:"platform,omitempty"` // platform of the scanned image in an index
+}
+
+// Count returns the number of findings at or above the severity
+func (result ImageScanResult) Count(sev ScanSeverity) int {
+	count := 0
+	for _, f := range result.Findings {
+		if f.Severity >= sev {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary returns the number of findings for each severity, e.g. "critical: 1, high: 3", skipping severities without findings
+func (result ImageScanResult) Summary() string {
+	counts := make([]int, len(scanSeverityNames))
+	for _, f := range result.Findings {
+		counts[f.Severity.index()]++
+	}
+	parts := []string{}
+	for i := len(counts) - 1; i >= 0; i-- {
+		if counts[i] > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", scanSeverityNames[i], counts[i]))
+		}
+	}
+	if len(parts) == 0 {
+		return "no findings"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MarshalPretty outputs a summary of the scan
+func (result ImageScanResult) MarshalPretty() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Scanner:   %s\n", result.Scanner)
+	fmt.Fprintf(buf, "Findings:  %s\n", result.Summary())
+	if result.Vetoed {
+		fmt.Fprintf(buf, "Vetoed:    findings at or above %s\n", result.Threshold.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// ImageWithScanner scans the source image before the copy, refusing the copy when any finding is at or above the threshold.
+// The copy fails with types.ErrScanThreshold, or with the scanner error when the scan fails.
+// A threshold of ScanSeverityUnknown reports the findings without refusing the copy.
+// The result is included in the ImageCopyResult when ImageWithCopyResult is set.
+func ImageWithScanner(scanner ImageScanner, threshold ScanSeverity) ImageOpts {
+	return func(opts *imageOpt) {
+		opts.scanner = scanner
+		opts.scanThreshold = threshold
+	}
+}
+
+// imageCopyScan runs the scanner on the source image, returning an error when the copy is vetoed.
+// Each copied platform of an index is scanned, since the scanners only select a single platform from an index.
+func (rc *RegClient) imageCopyScan(ctx context.Context, refSrc ref.Ref, opt *imageOpt) error {
+	if opt.scanner == nil {
+		return nil
+	}
+	mSrc, err := rc.ManifestGet(ctx, refSrc)
+	if err != nil {
+		return fmt.Errorf("failed to check source %s: %w", refSrc.CommonName(), err)
+	}
+	d := mSrc.GetDescriptor()
+	refScan := refSrc
+	refScan.Tag = ""
+	refScan.Digest = d.Digest.String()
+	scanList := []types.Descriptor{d}
+	mi, isIndex := mSrc.(manifest.Indexer)
+	if isIndex {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return fmt.Errorf("failed to get manifest list %s: %w", refScan.CommonName(), err)
+		}
+		scanList = []types.Descriptor{}
+		for _, dEntry := range dl {
+			// skip nested indexes and attestations that are not runnable images
+			if (dEntry.MediaType != types.MediaTypeOCI1Manifest && dEntry.MediaType != types.MediaTypeDocker2Manifest) ||
+				(dEntry.Platform != nil && dEntry.Platform.OS == "unknown") {
+				continue
+			}
+			if len(opt.platforms) > 0 {
+				match, err := imagePlatformInList(dEntry.Platform, opt.platforms)
+				if err != nil {
+					return err
+				}
+				if !match {
+					continue
+				}
+			}
+			scanList = append(scanList, dEntry)
+		}
+	}
+	result := &ImageScanResult{
+		Digest:   d.Digest.String(),
+		Findings: []ImageScanFinding{},
+	}
+	for _, dScan := range scanList {
+		rScan := refSrc
+		rScan.Tag = ""
+		rScan.Digest = dScan.Digest.String()
+		scanResult, err := opt.scanner.Scan(ctx, rScan, dScan)
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", rScan.CommonName(), err)
+		}
+		if scanResult == nil {
+			continue
+		}
+		if result.Scanner == "" {
+			result.Scanner = scanResult.Scanner
+		}
+		for _, f := range scanResult.Findings {
+			if isIndex && f.Platform == "" && dScan.Platform != nil {
+				f.Platform = dScan.Platform.String()
+			}
+			result.Findings = append(result.Findings, f)
+		}
+	}
+	result.Threshold = opt.scanThreshold
+	if opt.scanThreshold > ScanSeverityUnknown && result.Count(opt.scanThreshold) > 0 {
+		result.Vetoed = true
+	}
+	if opt.result != nil {
+		opt.result.Scan = result
+	}
+	if result.Vetoed {
+		return fmt.Errorf("refusing to copy %s, scan found %s%.0w", refScan.CommonName(), result.Summary(), types.ErrScanThreshold)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package regclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestScanSeverity(t *testing.T) {
+	for _, name := range []string{"unknown", "Negligible", "LOW", "medium", "High", "CRITICAL"} {
+		sev, err := ParseScanSeverity(name)
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", name, err)
+			continue
+		}
+		if sev.String() != strings.ToLower(name) {
+			t.Errorf("unexpected severity for %s: %s", name, sev.String())
+		}
+	}
+	_, err := ParseScanSeverity("severe")
+	if !errors.Is(err, types.ErrParsingFailed) {
+		t.Errorf("expected parsing failed, received %v", err)
+	}
+	var sev ScanSeverity
+	err = sev.UnmarshalText([]byte("high"))
+	if err != nil || sev != ScanSeverityHigh {
+		t.Errorf("failed to unmarshal: %v, %v", sev, err)
+	}
+}
+
+func TestCopyScan(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rSrc, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to parse src ref: %v", err)
+		return
+	}
+	mSrc, err := rc.ManifestHead(ctx, rSrc, WithManifestRequireDigest())
+	if err != nil {
+		t.Fatalf("failed to head src: %v", err)
+	}
+	findings := []ImageScanFinding{
+		{ID: "CVE-2024-0001", Severity: ScanSeverityHigh, Package: "openssl"},
+		{ID: "CVE-2024-0002", Severity: ScanSeverityLow, Package: "zlib"},
+		{ID: "CVE-2024-0003", Severity: ScanSeverityLow, Package: "zlib"},
+	}
+	scanned := []string{}
+	scanner := ImageScannerFunc(func(ctx context.Context, r ref.Ref, d types.Descriptor) (*ImageScanResult, error) {
+		if r.Digest != d.Digest.String() || d.Platform == nil {
+			return nil, fmt.Errorf("unexpected scan of %s", r.CommonName())
+		}
+		scanned = append(scanned, d.Platform.String())
+		return &ImageScanResult{Scanner: "test", Findings: findings}, nil
+	})
+	errScan := errors.New("scanner unavailable")
+	scannerErr := ImageScannerFunc(func(ctx context.Context, r ref.Ref, d types.Descriptor) (*ImageScanResult, error) {
+		return nil, errScan
+	})
+
+	tests := []struct {
+		name      string
+		scanner   ImageScanner
+		threshold ScanSeverity
+		platforms []string
+		expectErr error
+		vetoed    bool
+		scanned   []string
+		summary   string
+	}{
+		{
+			name:      "below threshold",
+			scanner:   scanner,
+			threshold: ScanSeverityCritical,
+			scanned:   []string{"linux/amd64", "linux/arm64"},
+			summary:   "high: 2, low: 4",
+		},
+		{
+			name:      "at threshold",
+			scanner:   scanner,
+			threshold: ScanSeverityHigh,
+			expectErr: types.ErrScanThreshold,
+			vetoed:    true,
+			scanned:   []string{"linux/amd64", "linux/arm64"},
+			summary:   "high: 2, low: 4",
+		},
+		{
+			name:      "report only",
+			scanner:   scanner,
+			threshold: ScanSeverityUnknown,
+			scanned:   []string{"linux/amd64", "linux/arm64"},
+			summary:   "high: 2, low: 4",
+		},
+		{
+			name:      "platform",
+			scanner:   scanner,
+			threshold: ScanSeverityCritical,
+			platforms: []string{"linux/arm64"},
+			scanned:   []string{"linux/arm64"},
+			summary:   "high: 1, low: 2",
+		},
+		{
+			name:      "scan failure",
+			scanner:   scannerErr,
+			threshold: ScanSeverityHigh,
+			expectErr: errScan,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rTgt, err := ref.New("ocidir://testscan:" + strings.ReplaceAll(tt.name, " ", "-"))
+			if err != nil {
+				t.Fatalf("failed to parse tgt ref: %v", err)
+			}
+			scanned = []string{}
+			result := ImageCopyResult{}
+			opts := []ImageOpts{ImageWithScanner(tt.scanner, tt.threshold), ImageWithCopyResult(&result)}
+			if len(tt.platforms) > 0 {
+				opts = append(opts, ImageWithPlatforms(tt.platforms))
+			}
+			err = rc.ImageCopy(ctx, rSrc, rTgt, opts...)
+			if tt.expectErr != nil {
+				if !errors.Is(err, tt.expectErr) {
+					t.Fatalf("unexpected error, expected %v, received %v", tt.expectErr, err)
+				}
+				_, err = rc.ManifestHead(ctx, rTgt)
+				if err == nil {
+					t.Errorf("target was pushed after the copy failed")
+				}
+			} else if err != nil {
+				t.Fatalf("failed to copy: %v", err)
+			}
+			if errors.Is(tt.expectErr, errScan) {
+				return
+			}
+			if result.Scan == nil {
+				t.Fatalf("scan result missing")
+			}
+			if result.Scan.Vetoed != tt.vetoed || result.Scan.Threshold != tt.threshold || result.Scan.Digest != mSrc.GetDescriptor().Digest.String() {
+				t.Errorf("unexpected scan result: %v", result.Scan)
+			}
+			if strings.Join(scanned, ",") != strings.Join(tt.scanned, ",") {
+				t.Errorf("unexpected platforms scanned, expected %v, received %v", tt.scanned, scanned)
+			}
+			if summary := result.Scan.Summary(); summary != tt.summary {
+				t.Errorf("unexpected summary: %s", summary)
+			}
+			if len(result.Scan.Findings) == 0 || result.Scan.Findings[len(result.Scan.Findings)-1].Platform != tt.scanned[len(tt.scanned)-1] {
+				t.Errorf("unexpected finding platform: %v", result.Scan.Findings)
+			}
+			out, err := result.MarshalPretty()
+			if err != nil || !strings.Contains(string(out), "Scanner:   test") {
+				t.Errorf("unexpected pretty output: %s, %v", out, err)
+			}
+		})
+	}
+}
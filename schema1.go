@@ -0,0 +1,241 @@
+package regclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/pkg/archive"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/docker/schema1"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// schema1EmptyLayer is the gzip compressed empty tar used by schema1 for history entries without filesystem changes
+const schema1EmptyLayer = digest.Digest("sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4")
+
+// schema1V1Compat contains the fields from the v1 compatibility history used to generate the OCI history
+type schema1V1Compat struct {
+	Created         *time.Time `json:"created,omitempty"`
+	Author          string     `json:"author,omitempty"`
+	Comment         string     `json:"comment,omitempty"`
+	ThrowAway       bool       `json:"throwaway,omitempty"`
+	ContainerConfig struct {
+		Cmd []string `json:"Cmd,omitempty"`
+	} `json:"container_config,omitempty"`
+}
+
+// isSchema1 returns true for docker schema1 media types
+func isSchema1(mt string) bool {
+	return mt == types.MediaTypeDocker1Manifest || mt == types.MediaTypeDocker1ManifestSigned
+}
+
+// schema1LayerFn returns the descriptor and the digest of the uncompressed content for a schema1 layer
+type schema1LayerFn func(dig digest.Digest) (types.Descriptor, digest.Digest, error)
+
+// imageSchema1ToOCI converts a docker schema1 manifest to an OCI image manifest.
+// The config is generated from the v1 compatibility history and returned to be pushed with the manifest.
+// Each layer is pulled from the source to compute the uncompressed digest.
+func (rc *RegClient) imageSchema1ToOCI(ctx context.Context, r ref.Ref, m manifest.Manifest) (manifest.Manifest, []byte, error) {
+	return schema1Convert(m, func(dig digest.Digest) (types.Descriptor, digest.Digest, error) {
+		return rc.imageSchema1Layer(ctx, r, dig)
+	})
+}
+
+// imageSchema1Match returns true when the target manifest is the OCI conversion of the schema1 manifest.
+// The layer descriptors and uncompressed digests are read from the target instead of pulling each layer.
+func (rc *RegClient) imageSchema1Match(ctx context.Context, r ref.Ref, m manifest.Manifest, mTgt manifest.Manifest) bool {
+	if mTgt == nil || mTgt.GetDescriptor().MediaType != types.MediaTypeOCI1Manifest {
+		return false
+	}
+	if !mTgt.IsSet() {
+		rGet := r
+		rGet.Tag = ""
+		rGet.Digest = mTgt.GetDescriptor().Digest.String()
+		var err error
+		mTgt, err = rc.ManifestGet(ctx, rGet)
+		if err != nil {
+			return false
+		}
+	}
+	mi, ok := mTgt.(manifest.Imager)
+	if !ok {
+		return false
+	}
+	cd, err := mi.GetConfig()
+	if err != nil {
+		return false
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return false
+	}
+	conf, err := rc.BlobGetOCIConfig(ctx, r, cd)
+	if err != nil {
+		return false
+	}
+	diffIDs := conf.GetConfig().RootFS.DiffIDs
+	i := 0
+	mConv, _, err := schema1Convert(m, func(dig digest.Digest) (types.Descriptor, digest.Digest, error) {
+		if i >= len(layers) || i >= len(diffIDs) || layers[i].Digest != dig {
+			return types.Descriptor{}, "", types.ErrMismatch
+		}
+		d := types.Descriptor{MediaType: layers[i].MediaType, Digest: layers[i].Digest, Size: layers[i].Size}
+		diffID := diffIDs[i]
+		i++
+		return d, diffID, nil
+	})
+	return err == nil && mConv.GetDescriptor().Digest == mTgt.GetDescriptor().Digest
+}
+
+// schema1Convert generates the OCI image manifest and config from a schema1 manifest, using layerFn for each non-empty layer.
+func schema1Convert(m manifest.Manifest, layerFn schema1LayerFn) (manifest.Manifest, []byte, error) {
+	var s1 schema1.Manifest
+	switch orig := m.GetOrig().(type) {
+	case schema1.Manifest:
+		s1 = orig
+	case schema1.SignedManifest:
+		s1 = orig.Manifest
+	default:
+		return nil, nil, fmt.Errorf("manifest is not schema1: %s%.0w", m.GetDescriptor().MediaType, types.ErrUnsupportedMediaType)
+	}
+	if len(s1.History) == 0 || len(s1.History) != len(s1.FSLayers) {
+		return nil, nil, fmt.Errorf("schema1 history does not match the layers, history %d, layers %d%.0w", len(s1.History), len(s1.FSLayers), types.ErrUnsupportedMediaType)
+	}
+	// the first history entry contains the config for the image
+	conf := v1.Image{}
+	err := json.Unmarshal([]byte(s1.History[0].V1Compatibility), &conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse v1 compatibility config: %w", err)
+	}
+	conf.RootFS = v1.RootFS{Type: "layers", DiffIDs: []digest.Digest{}}
+	conf.History = []v1.History{}
+	layers := []types.Descriptor{}
+	// schema1 lists the layers from the top down
+	for i := len(s1.History) - 1; i >= 0; i-- {
+		var compat schema1V1Compat
+		err = json.Unmarshal([]byte(s1.History[i].V1Compatibility), &compat)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse v1 compatibility history %d: %w", i, err)
+		}
+		h := v1.History{
+			Created:   compat.Created,
+			CreatedBy: strings.Join(compat.ContainerConfig.Cmd, " "),
+			Author:    compat.Author,
+			Comment:   compat.Comment,
+		}
+		if compat.ThrowAway || s1.FSLayers[i].BlobSum == schema1EmptyLayer {
+			h.EmptyLayer = true
+			conf.History = append(conf.History, h)
+			continue
+		}
+		d, diffID, err := layerFn(s1.FSLayers[i].BlobSum)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, d)
+		conf.RootFS.DiffIDs = append(conf.RootFS.DiffIDs, diffID)
+		conf.History = append(conf.History, h)
+	}
+	confB, err := json.Marshal(conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	mOCI := v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: types.MediaTypeOCI1Manifest,
+		Config: types.Descriptor{
+			MediaType: types.MediaTypeOCI1ImageConfig,
+			Digest:    digest.Canonical.FromBytes(confB),
+			Size:      int64(len(confB)),
+		},
+		Layers: layers,
+	}
+	mNew, err := manifest.New(manifest.WithOrig(mOCI))
+	if err != nil {
+		return nil, nil, err
+	}
+	return mNew, confB, nil
+}
+
+// imageSchema1Layer reads a layer to return the descriptor and the digest of the uncompressed content
+func (rc *RegClient) imageSchema1Layer(ctx context.Context, r ref.Ref, dig digest.Digest) (types.Descriptor, digest.Digest, error) {
+	b, err := rc.BlobGet(ctx, r, types.Descriptor{Digest: dig})
+	if err != nil {
+		return types.Descriptor{}, "", fmt.Errorf("failed to get layer %s: %w", dig.String(), err)
+	}
+	defer b.Close()
+	cr := &schema1Counter{r: b}
+	br := bufio.NewReader(cr)
+	// detect the compression from the content, a short read is checked as uncompressed
+	head, err := br.Peek(10)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return types.Descriptor{}, "", fmt.Errorf("failed to read layer %s: %w", dig.String(), err)
+	}
+	d := types.Descriptor{
+		Digest: dig,
+	}
+	switch comp := archive.DetectCompression(head); comp {
+	case archive.CompressNone:
+		d.MediaType = types.MediaTypeOCI1Layer
+	case archive.CompressGzip:
+		d.MediaType = types.MediaTypeOCI1LayerGzip
+	case archive.CompressZstd:
+		d.MediaType = types.MediaTypeOCI1LayerZstd
+	default:
+		return types.Descriptor{}, "", fmt.Errorf("layer %s uses %s compression which has no OCI media type%.0w", dig.String(), comp.String(), types.ErrUnsupportedMediaType)
+	}
+	dr, err := archive.Decompress(br)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return types.Descriptor{}, "", fmt.Errorf("failed to decompress layer %s: %w", dig.String(), err)
+	}
+	digester := digest.Canonical.Digester()
+	_, err = io.Copy(digester.Hash(), dr)
+	if err != nil {
+		return types.Descriptor{}, "", fmt.Errorf("failed to read layer %s: %w", dig.String(), err)
+	}
+	// read any remaining data so the blob reader verifies the digest
+	_, err = io.Copy(io.Discard, br)
+	if err != nil {
+		return types.Descriptor{}, "", fmt.Errorf("failed to read layer %s: %w", dig.String(), err)
+	}
+	d.Size = cr.n
+	return d, digester.Digest(), nil
+}
+
+// imageSchema1Put pushes the generated config to the target before the converted manifest is copied
+func (rc *RegClient) imageSchema1Put(ctx context.Context, r ref.Ref, m manifest.Manifest, confB []byte) error {
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return fmt.Errorf("converted manifest is not an image%.0w", types.ErrUnsupportedMediaType)
+	}
+	cd, err := mi.GetConfig()
+	if err != nil {
+		return err
+	}
+	_, err = rc.BlobPut(ctx, r, cd, bytes.NewReader(confB))
+	if err != nil {
+		return fmt.Errorf("failed to push config: %w", err)
+	}
+	return nil
+}
+
+type schema1Counter struct {
+	r io.Reader
+	n int64
+}
+
+func (c *schema1Counter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,262 @@
+package regclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/docker/schema1"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestImageSchema1ToOCI(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rV1, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	rSchema1, err := ref.New("ocidir://testrepo:schema1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	rTgt, err := ref.New("ocidir://testconv:v1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	// build a schema1 manifest from the layers of an existing image
+	m, err := rc.ManifestGet(ctx, rV1)
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	dAMD64, err := manifest.GetPlatformDesc(m, &platform.Platform{OS: "linux", Architecture: "amd64"})
+	if err != nil {
+		t.Errorf("failed to get amd64 descriptor: %v", err)
+		return
+	}
+	rAMD64 := rV1
+	rAMD64.Tag = ""
+	rAMD64.Digest = dAMD64.Digest.String()
+	mAMD64, err := rc.ManifestGet(ctx, rAMD64)
+	if err != nil {
+		t.Errorf("failed to get amd64 manifest: %v", err)
+		return
+	}
+	dConf, err := mAMD64.(manifest.Imager).GetConfig()
+	if err != nil {
+		t.Errorf("failed to get config descriptor: %v", err)
+		return
+	}
+	img, err := rc.BlobGetOCIConfig(ctx, rAMD64, dConf)
+	if err != nil {
+		t.Errorf("failed to get config: %v", err)
+		return
+	}
+	layers, err := mAMD64.(manifest.Imager).GetLayers()
+	if err != nil || len(layers) == 0 {
+		t.Errorf("failed to get layers: %v", err)
+		return
+	}
+	s1 := schema1.Manifest{
+		Versioned:    schema1.ManifestSchemaVersion,
+		Name:         "testrepo",
+		Tag:          "schema1",
+		Architecture: "amd64",
+	}
+	// schema1 lists the layers and history from the top down, with an empty layer on top
+	s1.FSLayers = append(s1.FSLayers, schema1.FSLayer{BlobSum: schema1EmptyLayer})
+	s1.History = append(s1.History, schema1.History{V1Compatibility: `{"id":"top","architecture":"amd64","os":"linux","config":{"Cmd":["/app"]},"container_config":{"Cmd":["/bin/sh","-c","#(nop) CMD [\"/app\"]"]},"throwaway":true}`})
+	for i := len(layers) - 1; i >= 0; i-- {
+		s1.FSLayers = append(s1.FSLayers, schema1.FSLayer{BlobSum: layers[i].Digest})
+		s1.History = append(s1.History, schema1.History{V1Compatibility: fmt.Sprintf(`{"id":"layer%d","container_config":{"Cmd":["/bin/sh","-c","#(nop) ADD layer%d"]}}`, i, i)})
+	}
+	// gzip compressed empty tar
+	emptyLayer := []byte{0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x00, 0xff, 0x62, 0x18, 0x05, 0xa3, 0x60, 0x14, 0x8c, 0x58, 0x00, 0x08, 0x00, 0x00, 0xff, 0xff, 0x2e, 0xaf, 0xb5, 0xef, 0x00, 0x04, 0x00, 0x00}
+	_, err = rc.BlobPut(ctx, rSchema1, types.Descriptor{Digest: schema1EmptyLayer, Size: int64(len(emptyLayer))}, bytes.NewReader(emptyLayer))
+	if err != nil {
+		t.Errorf("failed to put empty layer: %v", err)
+		return
+	}
+	mSchema1, err := manifest.New(manifest.WithOrig(s1))
+	if err != nil {
+		t.Errorf("failed to create schema1 manifest: %v", err)
+		return
+	}
+	err = rc.ManifestPut(ctx, rSchema1, mSchema1)
+	if err != nil {
+		t.Errorf("failed to put schema1 manifest: %v", err)
+		return
+	}
+
+	t.Run("Copy", func(t *testing.T) {
+		rCopy := rTgt
+		rCopy.Tag = "schema1"
+		err := rc.ImageCopy(ctx, rSchema1, rCopy)
+		if err != nil {
+			t.Errorf("failed to copy: %v", err)
+			return
+		}
+		mCopy, err := rc.ManifestHead(ctx, rCopy)
+		if err != nil {
+			t.Errorf("failed to head copy: %v", err)
+			return
+		}
+		if mCopy.GetDescriptor().Digest != mSchema1.GetDescriptor().Digest {
+			t.Errorf("schema1 manifest was modified without conversion")
+		}
+	})
+	t.Run("Convert", func(t *testing.T) {
+		result := ImageCopyResult{}
+		err := rc.ImageCopy(ctx, rSchema1, rTgt, ImageWithSchema1ToOCI(), ImageWithCopyResult(&result))
+		if err != nil {
+			t.Errorf("failed to copy: %v", err)
+			return
+		}
+		mConv, err := rc.ManifestGet(ctx, rTgt)
+		if err != nil {
+			t.Errorf("failed to get converted manifest: %v", err)
+			return
+		}
+		if result.Digest != mConv.GetDescriptor().Digest {
+			t.Errorf("unexpected result digest, expected %s, received %s", mConv.GetDescriptor().Digest, result.Digest)
+		}
+		if mConv.GetDescriptor().MediaType != types.MediaTypeOCI1Manifest {
+			t.Errorf("unexpected media type: %s", mConv.GetDescriptor().MediaType)
+		}
+		convLayers, err := mConv.(manifest.Imager).GetLayers()
+		if err != nil {
+			t.Errorf("failed to get layers: %v", err)
+			return
+		}
+		if len(convLayers) != len(layers) {
+			t.Errorf("unexpected layer count, expected %d, received %d", len(layers), len(convLayers))
+			return
+		}
+		for i := range layers {
+			if convLayers[i].Digest != layers[i].Digest || convLayers[i].Size != layers[i].Size {
+				t.Errorf("layer %d mismatch, expected %v, received %v", i, layers[i], convLayers[i])
+			}
+		}
+		cd, err := mConv.(manifest.Imager).GetConfig()
+		if err != nil {
+			t.Errorf("failed to get config descriptor: %v", err)
+			return
+		}
+		br, err := rc.BlobGet(ctx, rTgt, cd)
+		if err != nil {
+			t.Errorf("failed to get config: %v", err)
+			return
+		}
+		confB, err := io.ReadAll(br)
+		_ = br.Close()
+		if err != nil {
+			t.Errorf("failed to read config: %v", err)
+			return
+		}
+		conf := v1.Image{}
+		err = json.Unmarshal(confB, &conf)
+		if err != nil {
+			t.Errorf("failed to parse config: %v", err)
+			return
+		}
+		imgConf := img.GetConfig()
+		if len(conf.RootFS.DiffIDs) != len(imgConf.RootFS.DiffIDs) {
+			t.Errorf("unexpected diff ids, expected %v, received %v", imgConf.RootFS.DiffIDs, conf.RootFS.DiffIDs)
+		} else {
+			for i := range conf.RootFS.DiffIDs {
+				if conf.RootFS.DiffIDs[i] != imgConf.RootFS.DiffIDs[i] {
+					t.Errorf("diff id %d mismatch, expected %s, received %s", i, imgConf.RootFS.DiffIDs[i], conf.RootFS.DiffIDs[i])
+				}
+			}
+		}
+		if conf.OS != "linux" || conf.Architecture != "amd64" || len(conf.Config.Cmd) != 1 || conf.Config.Cmd[0] != "/app" {
+			t.Errorf("config not converted: %s", string(confB))
+		}
+		if len(conf.History) != len(layers)+1 || !conf.History[len(conf.History)-1].EmptyLayer || conf.History[0].CreatedBy != "/bin/sh -c #(nop) ADD layer0" {
+			t.Errorf("history not converted: %v", conf.History)
+		}
+	})
+	t.Run("Rerun", func(t *testing.T) {
+		mConv, err := rc.ManifestHead(ctx, rTgt)
+		if err != nil {
+			t.Errorf("failed to head converted manifest: %v", err)
+			return
+		}
+		result := ImageCopyResult{}
+		err = rc.ImageCopy(ctx, rSchema1, rTgt, ImageWithSchema1ToOCI(), ImageWithCopyResult(&result))
+		if err != nil {
+			t.Errorf("failed to copy: %v", err)
+			return
+		}
+		if !result.Unchanged || result.Digest != mConv.GetDescriptor().Digest || result.BlobsCopied != 0 {
+			t.Errorf("converted image was copied again: %v", result)
+		}
+	})
+	t.Run("Digest Target", func(t *testing.T) {
+		rDig := rTgt
+		rDig.Tag = ""
+		rDig.Digest = mSchema1.GetDescriptor().Digest.String()
+		err := rc.ImageCopy(ctx, rSchema1, rDig, ImageWithSchema1ToOCI())
+		if !errors.Is(err, types.ErrUnsupported) {
+			t.Errorf("unexpected error for a digest target: %v", err)
+		}
+	})
+	t.Run("Layer Compression", func(t *testing.T) {
+		raw := bytes.Repeat([]byte("uncompressed layer content\n"), 100)
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatalf("failed to create zstd writer: %v", err)
+		}
+		zstdB := enc.EncodeAll(raw, nil)
+		_ = enc.Close()
+		tt := []struct {
+			name   string
+			blob   []byte
+			expect string
+		}{
+			{name: "none", blob: raw, expect: types.MediaTypeOCI1Layer},
+			{name: "zstd", blob: zstdB, expect: types.MediaTypeOCI1LayerZstd},
+		}
+		for _, tc := range tt {
+			t.Run(tc.name, func(t *testing.T) {
+				dig := digest.FromBytes(tc.blob)
+				_, err := rc.BlobPut(ctx, rSchema1, types.Descriptor{Digest: dig, Size: int64(len(tc.blob))}, bytes.NewReader(tc.blob))
+				if err != nil {
+					t.Fatalf("failed to put blob: %v", err)
+				}
+				d, diffID, err := rc.imageSchema1Layer(ctx, rSchema1, dig)
+				if err != nil {
+					t.Fatalf("failed to read layer: %v", err)
+				}
+				if d.MediaType != tc.expect || d.Size != int64(len(tc.blob)) {
+					t.Errorf("unexpected descriptor: %v", d)
+				}
+				if diffID != digest.FromBytes(raw) {
+					t.Errorf("unexpected diff id, expected %s, received %s", digest.FromBytes(raw), diffID)
+				}
+			})
+		}
+	})
+}
@@ -17,6 +17,18 @@ func (rc *RegClient) schemeGet(scheme string) (scheme.API, error) {
 	return s, nil
 }
 
+// RefNew parses a reference, including schemes added to this RegClient with WithScheme.
+// Schemes other than "reg" are parsed as a path with an optional tag and digest, e.g. "name://path:tag".
+func (rc *RegClient) RefNew(parse string) (ref.Ref, error) {
+	pathSchemes := []string{}
+	for name := range rc.schemes {
+		if name != "reg" {
+			pathSchemes = append(pathSchemes, name)
+		}
+	}
+	return ref.NewWithSchemes(parse, pathSchemes...)
+}
+
 // Close is used to free resources associated with a reference
 // With ocidir, this may trigger a garbage collection process
 func (rc *RegClient) Close(ctx context.Context, r ref.Ref) error {
@@ -0,0 +1,266 @@
+//go:build containerd
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	contentapi "github.com/containerd/containerd/api/services/content/v1"
+	"github.com/google/uuid"
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/blob"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	// blobChunk is the size of the data in each write message
+	blobChunk = 1024 * 1024
+)
+
+// BlobDelete removes a blob from the content store
+func (c *Containerd) BlobDelete(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	ctx, err := c.apiCtx(ctx, "Content.Delete", "")
+	if err != nil {
+		return err
+	}
+	_, err = c.content.Delete(ctx, &contentapi.DeleteContentRequest{Digest: d.Digest.String()})
+	if err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", d.Digest.String(), apiErr("Content.Delete", err))
+	}
+	return nil
+}
+
+// BlobGet retrieves a blob from the content store
+func (c *Containerd) BlobGet(ctx context.Context, r ref.Ref, d types.Descriptor) (blob.Reader, error) {
+	size, err := c.contentInfo(ctx, d.Digest)
+	if err != nil {
+		return nil, err
+	}
+	d.Size = size
+	ctx, cancel := context.WithCancel(ctx)
+	ctxAPI, err := c.apiCtx(ctx, "Content.Read", "")
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	rc, err := c.content.Read(ctxAPI, &contentapi.ReadContentRequest{Digest: d.Digest.String()})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to read blob %s: %w", d.Digest.String(), apiErr("Content.Read", err))
+	}
+	c.log.WithFields(logrus.Fields{
+		"ref":    r.CommonName(),
+		"digest": d.Digest.String(),
+	}).Debug("retrieved blob")
+	return blob.NewReader(
+		blob.WithRef(r),
+		blob.WithReader(&contentReader{rc: rc, cancel: cancel}),
+		blob.WithDesc(d),
+	), nil
+}
+
+// BlobHead verifies the existence of a blob in the content store
+func (c *Containerd) BlobHead(ctx context.Context, r ref.Ref, d types.Descriptor) (blob.Reader, error) {
+	size, err := c.contentInfo(ctx, d.Digest)
+	if err != nil {
+		return nil, err
+	}
+	d.Size = size
+	return blob.NewReader(
+		blob.WithRef(r),
+		blob.WithDesc(d),
+	), nil
+}
+
+// BlobMount adds existing content to the lease of the target image name, the content store is shared by every image in the namespace
+func (c *Containerd) BlobMount(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, d types.Descriptor) error {
+	_, err := c.contentInfo(ctx, d.Digest)
+	if err != nil {
+		return err
+	}
+	return c.leaseAdd(ctx, refTgt, d.Digest)
+}
+
+// BlobPut writes a blob to the content store, protected by the lease for the image name
+func (c *Containerd) BlobPut(ctx context.Context, r ref.Ref, d types.Descriptor, rdr io.Reader) (types.Descriptor, error) {
+	lease, err := c.leaseGet(ctx, r)
+	if err != nil {
+		return d, err
+	}
+	dOut, err := c.contentWrite(ctx, lease, d, rdr, nil)
+	if errors.Is(err, errAlreadyExists) && dOut.Digest != "" {
+		// existing content is protected by adding it to the lease
+		dOut.Size, err = c.contentInfo(ctx, dOut.Digest)
+		if err != nil {
+			return dOut, err
+		}
+		return dOut, c.leaseAdd(ctx, r, dOut.Digest)
+	}
+	return dOut, err
+}
+
+// contentInfo returns the size of content, verifying it exists
+func (c *Containerd) contentInfo(ctx context.Context, dig digest.Digest) (int64, error) {
+	ctx, err := c.apiCtx(ctx, "Content.Info", "")
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.content.Info(ctx, &contentapi.InfoRequest{Digest: dig.String()})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get info for %s: %w", dig.String(), apiErr("Content.Info", err))
+	}
+	if resp.Info == nil {
+		return 0, fmt.Errorf("info for %s missing from response%.0w", dig.String(), types.ErrNotFound)
+	}
+	return resp.Info.Size, nil
+}
+
+// contentLabel adds labels to existing content
+func (c *Containerd) contentLabel(ctx context.Context, dig digest.Digest, labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	ctx, err := c.apiCtx(ctx, "Content.Update", "")
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(labels))
+	for k := range labels {
+		paths = append(paths, "labels."+k)
+	}
+	sort.Strings(paths)
+	_, err = c.content.Update(ctx, &contentapi.UpdateRequest{
+		Info:       &contentapi.Info{Digest: dig.String(), Labels: labels},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: paths},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to label %s: %w", dig.String(), apiErr("Content.Update", err))
+	}
+	return nil
+}
+
+// contentWrite sends content to the store, committing it with the labels.
+// errAlreadyExists is returned when the store has the digest.
+func (c *Containerd) contentWrite(ctx context.Context, lease string, d types.Descriptor, rdr io.Reader, labels map[string]string) (types.Descriptor, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx, err := c.apiCtx(ctx, "Content.Write", lease)
+	if err != nil {
+		return d, err
+	}
+	wc, err := c.content.Write(ctx)
+	if err != nil {
+		return d, fmt.Errorf("failed to write %s: %w", d.Digest.String(), apiErr("Content.Write", err))
+	}
+	send := func(req *contentapi.WriteContentRequest) error {
+		err := wc.Send(req)
+		if err == nil {
+			_, err = wc.Recv()
+		} else if errors.Is(err, io.EOF) {
+			// the status of a closed stream is returned by Recv
+			_, err = wc.Recv()
+		}
+		return apiErr("Content.Write", err)
+	}
+	wRef := "regclient-" + uuid.New().String()
+	digester := digest.Canonical.Digester()
+	if d.Digest != "" {
+		digester = d.Digest.Algorithm().Digester()
+	}
+	buf := make([]byte, blobChunk)
+	offset := int64(0)
+	for {
+		n, errRead := io.ReadFull(rdr, buf)
+		if n > 0 {
+			_, _ = digester.Hash().Write(buf[:n])
+			err = send(&contentapi.WriteContentRequest{
+				Action:   contentapi.WriteAction_WRITE,
+				Ref:      wRef,
+				Total:    d.Size,
+				Expected: d.Digest.String(),
+				Offset:   offset,
+				Data:     buf[:n],
+			})
+			if err != nil {
+				return d, fmt.Errorf("failed to write %s: %w", d.Digest.String(), err)
+			}
+			offset += int64(n)
+		}
+		if errors.Is(errRead, io.EOF) || errors.Is(errRead, io.ErrUnexpectedEOF) {
+			break
+		} else if errRead != nil {
+			return d, fmt.Errorf("failed to read %s: %w", d.Digest.String(), errRead)
+		}
+	}
+	dig := digester.Digest()
+	if d.Size > 0 && offset != d.Size {
+		return d, fmt.Errorf("size mismatch for %s, expected %d, received %d%.0w", d.Digest.String(), d.Size, offset, types.ErrMismatch)
+	}
+	if d.Digest != "" && dig != d.Digest {
+		return d, fmt.Errorf("%w, expected %s, received %s", types.ErrDigestMismatch, d.Digest.String(), dig.String())
+	}
+	d.Digest = dig
+	d.Size = offset
+	err = send(&contentapi.WriteContentRequest{
+		Action:   contentapi.WriteAction_COMMIT,
+		Ref:      wRef,
+		Total:    offset,
+		Expected: dig.String(),
+		Offset:   offset,
+		Labels:   labels,
+	})
+	if err == nil {
+		err = wc.CloseSend()
+	}
+	if err == nil {
+		_, err = wc.Recv()
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		err = apiErr("Content.Write", err)
+	}
+	if err != nil {
+		return d, fmt.Errorf("failed to commit %s: %w", dig.String(), err)
+	}
+	return d, nil
+}
+
+// contentReader returns the data from a content read stream
+type contentReader struct {
+	rc     contentapi.Content_ReadClient
+	cancel context.CancelFunc
+	buf    []byte
+	err    error
+}
+
+func (cr *contentReader) Read(p []byte) (int, error) {
+	for len(cr.buf) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		resp, err := cr.rc.Recv()
+		if errors.Is(err, io.EOF) {
+			cr.err = io.EOF
+			continue
+		} else if err != nil {
+			cr.err = apiErr("Content.Read", err)
+			continue
+		}
+		cr.buf = resp.Data
+	}
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	return n, nil
+}
+
+func (cr *contentReader) Close() error {
+	cr.cancel()
+	return nil
+}
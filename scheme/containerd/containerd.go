@@ -0,0 +1,300 @@
+//go:build containerd
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	contentapi "github.com/containerd/containerd/api/services/content/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	leasesapi "github.com/containerd/containerd/api/services/leases/v1"
+	apitypes "github.com/containerd/containerd/api/types"
+	"github.com/google/uuid"
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultAddress is used when CONTAINERD_ADDRESS is not set
+	defaultAddress = "/run/containerd/containerd.sock"
+	// defaultNamespace is used when CONTAINERD_NAMESPACE is not set
+	defaultNamespace = "default"
+	// leaseExpire is when containerd removes a lease that was not released after a failed push
+	leaseExpire = time.Hour
+
+	hdrNamespace  = "containerd-namespace"
+	hdrLease      = "containerd-lease"
+	labelGCExpire = "containerd.io/gc.expire"
+	labelGCRef    = "containerd.io/gc.ref.content."
+	resourceType  = "content"
+)
+
+// errAlreadyExists is returned by containerd when content or an image exists
+var errAlreadyExists = errors.New("already exists")
+
+func init() {
+	scheme.Register("containerd", func(conf scheme.Config) scheme.API {
+		return New(WithLog(conf.Log))
+	})
+}
+
+// Containerd is used for accessing images in containerd, e.g. "containerd://docker.io/library/alpine:latest"
+//
+// Blobs and manifests are read from and written to the content store, and tags are the images in the namespace.
+// Content written before the image is created is protected from garbage collection with a lease.
+// The lease is released when the tagged image is created or the reference is closed.
+type Containerd struct {
+	namespace string
+	log       *logrus.Logger
+	connErr   error
+	content   contentapi.ContentClient
+	images    imagesapi.ImagesClient
+	leases    leasesapi.LeasesClient
+	mu        sync.Mutex
+	leaseIDs  map[string]string
+	muRef     sync.Mutex // serializes updates to the referrers list
+}
+
+type containerdConf struct {
+	address   string
+	namespace string
+	log       *logrus.Logger
+}
+
+// Opts are used for passing options to containerd
+type Opts func(*containerdConf)
+
+// New creates a new Containerd with options
+func New(opts ...Opts) *Containerd {
+	conf := containerdConf{
+		address:   os.Getenv("CONTAINERD_ADDRESS"),
+		namespace: os.Getenv("CONTAINERD_NAMESPACE"),
+		log:       &logrus.Logger{Out: io.Discard},
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.address == "" {
+		conf.address = defaultAddress
+	}
+	if conf.namespace == "" {
+		conf.namespace = defaultNamespace
+	}
+	c := &Containerd{
+		namespace: conf.namespace,
+		log:       conf.log,
+		leaseIDs:  map[string]string{},
+	}
+	// the connection is made on the first request
+	conn, err := grpc.Dial("unix://"+conf.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		c.connErr = fmt.Errorf("failed to connect to containerd at %s: %w", conf.address, err)
+		return c
+	}
+	c.content = contentapi.NewContentClient(conn)
+	c.images = imagesapi.NewImagesClient(conn)
+	c.leases = leasesapi.NewLeasesClient(conn)
+	return c
+}
+
+// WithAddress sets the path to the containerd API socket.
+// The default is the CONTAINERD_ADDRESS environment variable, falling back to "/run/containerd/containerd.sock".
+func WithAddress(address string) Opts {
+	return func(c *containerdConf) {
+		c.address = address
+	}
+}
+
+// WithLog provides a logrus logger
+// By default logging is disabled
+func WithLog(log *logrus.Logger) Opts {
+	return func(c *containerdConf) {
+		c.log = log
+	}
+}
+
+// WithNamespace sets the containerd namespace.
+// The default is the CONTAINERD_NAMESPACE environment variable, falling back to "default".
+func WithNamespace(namespace string) Opts {
+	return func(c *containerdConf) {
+		c.namespace = namespace
+	}
+}
+
+// Close releases the lease protecting content written for the image name
+func (c *Containerd) Close(ctx context.Context, r ref.Ref) error {
+	return c.leaseRelease(ctx, r)
+}
+
+// apiCtx returns a context for a request to containerd with the namespace and optional lease
+func (c *Containerd) apiCtx(ctx context.Context, method, lease string) (context.Context, error) {
+	if c.connErr != nil {
+		return ctx, c.connErr
+	}
+	c.log.WithFields(logrus.Fields{
+		"method":    method,
+		"namespace": c.namespace,
+	}).Debug("containerd request")
+	md := metadata.Pairs(hdrNamespace, c.namespace)
+	if lease != "" {
+		md.Set(hdrLease, lease)
+	}
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// apiErr converts the grpc status of a failed request to an error
+func apiErr(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("containerd %s: %w", method, err)
+	}
+	switch s.Code() {
+	case codes.NotFound:
+		return fmt.Errorf("containerd %s: %s%.0w", method, s.Message(), types.ErrNotFound)
+	case codes.AlreadyExists:
+		return fmt.Errorf("containerd %s: %s%.0w", method, s.Message(), errAlreadyExists)
+	case codes.Unimplemented:
+		return fmt.Errorf("containerd %s: %s%.0w", method, s.Message(), types.ErrUnsupported)
+	case codes.Unavailable:
+		return fmt.Errorf("containerd %s: %s%.0w", method, s.Message(), types.ErrUnavailable)
+	default:
+		return fmt.Errorf("containerd %s: %s [grpc status %s]", method, s.Message(), s.Code().String())
+	}
+}
+
+// leaseGet returns the lease for writing content to the image name, creating it on first use
+func (c *Containerd) leaseGet(ctx context.Context, r ref.Ref) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id, ok := c.leaseIDs[r.Path]; ok {
+		return id, nil
+	}
+	ctx, err := c.apiCtx(ctx, "Leases.Create", "")
+	if err != nil {
+		return "", err
+	}
+	id := "regclient-" + uuid.New().String()
+	_, err = c.leases.Create(ctx, &leasesapi.CreateRequest{
+		ID: id,
+		Labels: map[string]string{
+			labelGCExpire: time.Now().Add(leaseExpire).UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create lease: %w", apiErr("Leases.Create", err))
+	}
+	c.leaseIDs[r.Path] = id
+	return id, nil
+}
+
+// leaseRelease deletes the lease for the image name once the content is referenced by an image
+func (c *Containerd) leaseRelease(ctx context.Context, r ref.Ref) error {
+	c.mu.Lock()
+	id, ok := c.leaseIDs[r.Path]
+	delete(c.leaseIDs, r.Path)
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	ctx, err := c.apiCtx(ctx, "Leases.Delete", "")
+	if err != nil {
+		return err
+	}
+	_, err = c.leases.Delete(ctx, &leasesapi.DeleteRequest{ID: id})
+	if err != nil {
+		return fmt.Errorf("failed to delete lease %s: %w", id, apiErr("Leases.Delete", err))
+	}
+	return nil
+}
+
+// leaseAdd protects existing content with the lease for the image name
+func (c *Containerd) leaseAdd(ctx context.Context, r ref.Ref, dig digest.Digest) error {
+	lease, err := c.leaseGet(ctx, r)
+	if err != nil {
+		return err
+	}
+	ctx, err = c.apiCtx(ctx, "Leases.AddResource", "")
+	if err != nil {
+		return err
+	}
+	_, err = c.leases.AddResource(ctx, &leasesapi.AddResourceRequest{
+		ID:       lease,
+		Resource: &leasesapi.Resource{ID: dig.String(), Type: resourceType},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to lease %s: %w", dig.String(), lease, apiErr("Leases.AddResource", err))
+	}
+	return nil
+}
+
+// imageName returns the name of the image in containerd, normalizing names without a registry, e.g. "docker.io/library/alpine:latest"
+func imageName(r ref.Ref) string {
+	name := r.Path
+	if rn, err := ref.New(r.Path); err == nil {
+		name = rn.Registry + "/" + rn.Repository
+	}
+	if r.Tag == "" {
+		return name
+	}
+	return name + ":" + r.Tag
+}
+
+// imageGet returns the target of a tagged image
+func (c *Containerd) imageGet(ctx context.Context, r ref.Ref) (types.Descriptor, error) {
+	if r.Tag == "" {
+		return types.Descriptor{}, types.ErrMissingTag
+	}
+	ctx, err := c.apiCtx(ctx, "Images.Get", "")
+	if err != nil {
+		return types.Descriptor{}, err
+	}
+	resp, err := c.images.Get(ctx, &imagesapi.GetImageRequest{Name: imageName(r)})
+	if err != nil {
+		return types.Descriptor{}, fmt.Errorf("failed to get image %s: %w", r.CommonName(), apiErr("Images.Get", err))
+	}
+	if resp.Image == nil || resp.Image.Target == nil {
+		return types.Descriptor{}, fmt.Errorf("image %s missing from response%.0w", r.CommonName(), types.ErrNotFound)
+	}
+	return descFromAPI(resp.Image.Target)
+}
+
+// descFromAPI converts a containerd descriptor
+func descFromAPI(d *apitypes.Descriptor) (types.Descriptor, error) {
+	dig, err := digest.Parse(d.Digest)
+	if err != nil {
+		return types.Descriptor{}, fmt.Errorf("failed to parse digest %s: %w", d.Digest, err)
+	}
+	return types.Descriptor{
+		MediaType:   d.MediaType,
+		Digest:      dig,
+		Size:        d.Size,
+		Annotations: d.Annotations,
+	}, nil
+}
+
+// descToAPI converts a descriptor to the containerd type
+func descToAPI(d types.Descriptor) *apitypes.Descriptor {
+	return &apitypes.Descriptor{
+		MediaType:   d.MediaType,
+		Digest:      d.Digest.String(),
+		Size:        d.Size,
+		Annotations: d.Annotations,
+	}
+}
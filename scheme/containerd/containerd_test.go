@@ -0,0 +1,549 @@
+//go:build containerd
+
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	contentapi "github.com/containerd/containerd/api/services/content/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	leasesapi "github.com/containerd/containerd/api/services/leases/v1"
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testStore is the state shared by the content, images, and leases services of containerd
+type testStore struct {
+	mu      sync.Mutex
+	content map[digest.Digest][]byte
+	labels  map[digest.Digest]map[string]string
+	images  map[string]*imagesapi.Image
+	leases  map[string]map[string]bool // resources of each lease
+	writes  map[digest.Digest]string   // lease used for each committed digest
+}
+
+// testNamespace rejects requests without the namespace
+func testNamespace(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ns := md.Get(hdrNamespace); len(ns) != 1 || ns[0] != "test" {
+		return status.Error(codes.InvalidArgument, "namespace is required")
+	}
+	return nil
+}
+
+// testLease returns the lease of a request
+func testLease(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if lease := md.Get(hdrLease); len(lease) == 1 {
+		return lease[0]
+	}
+	return ""
+}
+
+type testContent struct {
+	contentapi.UnimplementedContentServer
+	ts *testStore
+}
+
+func (s testContent) Info(ctx context.Context, req *contentapi.InfoRequest) (*contentapi.InfoResponse, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	dig := digest.Digest(req.Digest)
+	b, ok := s.ts.content[dig]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "content "+req.Digest+": not found")
+	}
+	return &contentapi.InfoResponse{Info: &contentapi.Info{Digest: req.Digest, Size: int64(len(b)), Labels: s.ts.labels[dig]}}, nil
+}
+
+func (s testContent) Update(ctx context.Context, req *contentapi.UpdateRequest) (*contentapi.UpdateResponse, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	dig := digest.Digest(req.Info.Digest)
+	if _, ok := s.ts.content[dig]; !ok {
+		return nil, status.Error(codes.NotFound, "content "+req.Info.Digest+": not found")
+	}
+	for _, path := range req.UpdateMask.Paths {
+		k, ok := strings.CutPrefix(path, "labels.")
+		if !ok {
+			return nil, status.Error(codes.InvalidArgument, "unsupported path "+path)
+		}
+		s.ts.labels[dig][k] = req.Info.Labels[k]
+	}
+	return &contentapi.UpdateResponse{Info: req.Info}, nil
+}
+
+func (s testContent) Delete(ctx context.Context, req *contentapi.DeleteContentRequest) (*emptypb.Empty, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	delete(s.ts.content, digest.Digest(req.Digest))
+	return &emptypb.Empty{}, nil
+}
+
+func (s testContent) Read(req *contentapi.ReadContentRequest, srv contentapi.Content_ReadServer) error {
+	s.ts.mu.Lock()
+	b, ok := s.ts.content[digest.Digest(req.Digest)]
+	s.ts.mu.Unlock()
+	if !ok {
+		return status.Error(codes.NotFound, "content "+req.Digest+": not found")
+	}
+	// send the content in small chunks to test multiple messages
+	for i := 0; i < len(b); i += 1000 {
+		err := srv.Send(&contentapi.ReadContentResponse{Offset: int64(i), Data: b[i:min(i+1000, len(b))]})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s testContent) Write(srv contentapi.Content_WriteServer) error {
+	buf := []byte{}
+	for {
+		req, err := srv.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		s.ts.mu.Lock()
+		_, exists := s.ts.content[digest.Digest(req.Expected)]
+		s.ts.mu.Unlock()
+		if exists {
+			return status.Error(codes.AlreadyExists, "content "+req.Expected+": already exists")
+		}
+		if req.Offset != int64(len(buf)) {
+			return status.Error(codes.OutOfRange, "unexpected offset")
+		}
+		buf = append(buf, req.Data...)
+		if req.Action != contentapi.WriteAction_COMMIT {
+			err = srv.Send(&contentapi.WriteContentResponse{Action: req.Action, Offset: int64(len(buf))})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		dig := digest.FromBytes(buf)
+		if req.Expected != dig.String() || req.Total != int64(len(buf)) {
+			return status.Error(codes.FailedPrecondition, "unexpected commit digest or size")
+		}
+		labels := map[string]string{}
+		for k, v := range req.Labels {
+			labels[k] = v
+		}
+		s.ts.mu.Lock()
+		s.ts.content[dig] = buf
+		s.ts.labels[dig] = labels
+		if lease := testLease(srv.Context()); s.ts.leases[lease] != nil {
+			s.ts.writes[dig] = lease
+		}
+		s.ts.mu.Unlock()
+		err = srv.Send(&contentapi.WriteContentResponse{Action: req.Action, Offset: int64(len(buf)), Digest: dig.String()})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type testImages struct {
+	imagesapi.UnimplementedImagesServer
+	ts *testStore
+}
+
+func (s testImages) Get(ctx context.Context, req *imagesapi.GetImageRequest) (*imagesapi.GetImageResponse, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	img, ok := s.ts.images[req.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "image "+req.Name+": not found")
+	}
+	return &imagesapi.GetImageResponse{Image: img}, nil
+}
+
+func (s testImages) List(ctx context.Context, req *imagesapi.ListImagesRequest) (*imagesapi.ListImagesResponse, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	resp := &imagesapi.ListImagesResponse{}
+	for _, img := range s.ts.images {
+		resp.Images = append(resp.Images, img)
+	}
+	return resp, nil
+}
+
+func (s testImages) Create(ctx context.Context, req *imagesapi.CreateImageRequest) (*imagesapi.CreateImageResponse, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	if _, ok := s.ts.images[req.Image.Name]; ok {
+		return nil, status.Error(codes.AlreadyExists, "image "+req.Image.Name+": already exists")
+	}
+	if _, ok := s.ts.content[digest.Digest(req.Image.Target.Digest)]; !ok {
+		return nil, status.Error(codes.FailedPrecondition, "target is missing")
+	}
+	s.ts.images[req.Image.Name] = req.Image
+	return &imagesapi.CreateImageResponse{Image: req.Image}, nil
+}
+
+func (s testImages) Update(ctx context.Context, req *imagesapi.UpdateImageRequest) (*imagesapi.UpdateImageResponse, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	img, ok := s.ts.images[req.Image.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "image "+req.Image.Name+": not found")
+	}
+	if len(req.UpdateMask.GetPaths()) != 1 || req.UpdateMask.Paths[0] != "target" {
+		return nil, status.Error(codes.InvalidArgument, "only the target may be updated")
+	}
+	img.Target = req.Image.Target
+	return &imagesapi.UpdateImageResponse{Image: img}, nil
+}
+
+func (s testImages) Delete(ctx context.Context, req *imagesapi.DeleteImageRequest) (*emptypb.Empty, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	if _, ok := s.ts.images[req.Name]; !ok {
+		return nil, status.Error(codes.NotFound, "image "+req.Name+": not found")
+	}
+	delete(s.ts.images, req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+type testLeases struct {
+	leasesapi.UnimplementedLeasesServer
+	ts *testStore
+}
+
+func (s testLeases) Create(ctx context.Context, req *leasesapi.CreateRequest) (*leasesapi.CreateResponse, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	if req.Labels[labelGCExpire] == "" {
+		return nil, status.Error(codes.InvalidArgument, "lease must expire")
+	}
+	s.ts.leases[req.ID] = map[string]bool{}
+	return &leasesapi.CreateResponse{Lease: &leasesapi.Lease{ID: req.ID, Labels: req.Labels}}, nil
+}
+
+func (s testLeases) Delete(ctx context.Context, req *leasesapi.DeleteRequest) (*emptypb.Empty, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	if _, ok := s.ts.leases[req.ID]; !ok {
+		return nil, status.Error(codes.NotFound, "lease "+req.ID+": not found")
+	}
+	delete(s.ts.leases, req.ID)
+	return &emptypb.Empty{}, nil
+}
+
+func (s testLeases) AddResource(ctx context.Context, req *leasesapi.AddResourceRequest) (*emptypb.Empty, error) {
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	resources, ok := s.ts.leases[req.ID]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "lease "+req.ID+": not found")
+	}
+	if req.Resource.Type != resourceType {
+		return nil, status.Error(codes.InvalidArgument, "unsupported resource type "+req.Resource.Type)
+	}
+	resources[req.Resource.ID] = true
+	return &emptypb.Empty{}, nil
+}
+
+// leased returns true when a lease protects the digest
+func (ts *testStore) leased(dig digest.Digest) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, resources := range ts.leases {
+		if resources[dig.String()] {
+			return true
+		}
+	}
+	return false
+}
+
+func refTag(r ref.Ref, tag string) ref.Ref {
+	r.Tag = tag
+	r.Digest = ""
+	return r
+}
+
+func refDigest(r ref.Ref, dig string) ref.Ref {
+	r.Tag = ""
+	r.Digest = dig
+	return r
+}
+
+func TestContainerd(t *testing.T) {
+	ctx := context.Background()
+	ts := &testStore{
+		content: map[digest.Digest][]byte{},
+		labels:  map[digest.Digest]map[string]string{},
+		images:  map[string]*imagesapi.Image{},
+		leases:  map[string]map[string]bool{},
+		writes:  map[digest.Digest]string{},
+	}
+	sock := filepath.Join(t.TempDir(), "containerd.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := testNamespace(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := testNamespace(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	contentapi.RegisterContentServer(srv, testContent{ts: ts})
+	imagesapi.RegisterImagesServer(srv, testImages{ts: ts})
+	leasesapi.RegisterLeasesServer(srv, testLeases{ts: ts})
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(srv.Stop)
+
+	c := New(WithAddress(sock), WithNamespace("test"))
+	r, err := ref.New("containerd://alpine:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	layer := bytes.Repeat([]byte("layer data "), blobChunk/8)
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	dLayer := types.Descriptor{MediaType: types.MediaTypeOCI1LayerGzip, Digest: digest.FromBytes(layer), Size: int64(len(layer))}
+	dConfig := types.Descriptor{MediaType: types.MediaTypeOCI1ImageConfig, Digest: digest.FromBytes(config), Size: int64(len(config))}
+	raw := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` + dConfig.Digest.String() + `","size":` + strconv.Itoa(len(config)) + `},` +
+		`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":"` + dLayer.Digest.String() + `","size":` + strconv.Itoa(len(layer)) + `}]}`)
+	m, err := manifest.New(manifest.WithRaw(raw))
+	if err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+	name := "docker.io/library/alpine:v1"
+
+	t.Run("Put", func(t *testing.T) {
+		for _, b := range [][]byte{layer, config} {
+			d, err := c.BlobPut(ctx, r, types.Descriptor{}, bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("failed to put blob: %v", err)
+			}
+			if d.Digest != digest.FromBytes(b) || d.Size != int64(len(b)) {
+				t.Errorf("unexpected descriptor: %v", d)
+			}
+		}
+		for dig, lease := range ts.writes {
+			if lease == "" {
+				t.Errorf("content %s was written without a lease", dig)
+			}
+		}
+		// a second put of existing content is not an error, and adds the content to the lease
+		_, err = c.BlobPut(ctx, r, dConfig, bytes.NewReader(config))
+		if err != nil {
+			t.Errorf("failed to put existing blob: %v", err)
+		}
+		if !ts.leased(dConfig.Digest) {
+			t.Errorf("existing blob was not added to the lease: %v", ts.leases)
+		}
+		err = c.ManifestPut(ctx, r, m)
+		if err != nil {
+			t.Fatalf("failed to put manifest: %v", err)
+		}
+		img, ok := ts.images[name]
+		if !ok || img.Target.Digest != m.GetDescriptor().Digest.String() || img.Target.MediaType != types.MediaTypeOCI1Manifest {
+			t.Fatalf("unexpected images: %v", ts.images)
+		}
+		labels := ts.labels[m.GetDescriptor().Digest]
+		if labels[labelGCRef+"config"] != dConfig.Digest.String() || labels[labelGCRef+"l.0"] != dLayer.Digest.String() {
+			t.Errorf("unexpected manifest labels: %v", labels)
+		}
+		if ts.writes[m.GetDescriptor().Digest] == "" {
+			t.Errorf("manifest was written without a lease")
+		}
+		// the image references the content, releasing the lease
+		if len(ts.leases) != 0 {
+			t.Errorf("lease was not released after the tagged put: %v", ts.leases)
+		}
+		// pushing the tag again updates the image
+		rV2 := refTag(r, "v2")
+		err = c.ManifestPut(ctx, rV2, m)
+		if err != nil {
+			t.Fatalf("failed to put existing manifest: %v", err)
+		}
+		err = c.ManifestPut(ctx, rV2, m)
+		if err != nil {
+			t.Fatalf("failed to update image: %v", err)
+		}
+		if len(ts.leases) != 0 {
+			t.Errorf("lease was not released after the tagged put: %v", ts.leases)
+		}
+	})
+	t.Run("Get", func(t *testing.T) {
+		mGet, err := c.ManifestGet(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		if mGet.GetDescriptor().Digest != m.GetDescriptor().Digest {
+			t.Errorf("unexpected manifest digest: %s", mGet.GetDescriptor().Digest)
+		}
+		mHead, err := c.ManifestHead(ctx, refDigest(r, m.GetDescriptor().Digest.String()))
+		if err != nil {
+			t.Fatalf("failed to head manifest: %v", err)
+		}
+		if mHead.GetDescriptor().MediaType != types.MediaTypeOCI1Manifest || mHead.GetDescriptor().Size != int64(len(raw)) {
+			t.Errorf("unexpected head descriptor: %v", mHead.GetDescriptor())
+		}
+		br, err := c.BlobGet(ctx, r, dLayer)
+		if err != nil {
+			t.Fatalf("failed to get blob: %v", err)
+		}
+		b, err := io.ReadAll(br)
+		_ = br.Close()
+		if err != nil || !bytes.Equal(b, layer) {
+			t.Errorf("unexpected blob content, %d bytes, %v", len(b), err)
+		}
+		bh, err := c.BlobHead(ctx, r, types.Descriptor{Digest: dConfig.Digest})
+		if err != nil || bh.GetDescriptor().Size != dConfig.Size {
+			t.Errorf("unexpected blob head: %v, %v", bh, err)
+		}
+	})
+	t.Run("Mount", func(t *testing.T) {
+		rTgt, err := ref.New("containerd://example.com/app:v1")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		err = c.BlobMount(ctx, r, rTgt, dLayer)
+		if err != nil {
+			t.Fatalf("failed to mount blob: %v", err)
+		}
+		if !ts.leased(dLayer.Digest) {
+			t.Errorf("mounted blob was not added to the lease: %v", ts.leases)
+		}
+		err = c.BlobMount(ctx, r, rTgt, types.Descriptor{Digest: digest.FromString("missing")})
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error mounting a missing blob: %v", err)
+		}
+		err = c.Close(ctx, rTgt)
+		if err != nil {
+			t.Fatalf("failed to close: %v", err)
+		}
+		if len(ts.leases) != 0 {
+			t.Errorf("lease was not deleted: %v", ts.leases)
+		}
+	})
+	t.Run("Referrers", func(t *testing.T) {
+		empty := []byte("{}")
+		dEmpty, err := c.BlobPut(ctx, r, types.Descriptor{}, bytes.NewReader(empty))
+		if err != nil {
+			t.Fatalf("failed to put blob: %v", err)
+		}
+		rawArt := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/example.sbom",` +
+			`"config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"` + dEmpty.Digest.String() + `","size":2},` +
+			`"layers":[{"mediaType":"application/vnd.oci.empty.v1+json","digest":"` + dEmpty.Digest.String() + `","size":2}],` +
+			`"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"` + m.GetDescriptor().Digest.String() + `","size":` + strconv.Itoa(len(raw)) + `}}`)
+		mArt, err := manifest.New(manifest.WithRaw(rawArt))
+		if err != nil {
+			t.Fatalf("failed to create manifest: %v", err)
+		}
+		rArt := refDigest(r, mArt.GetDescriptor().Digest.String())
+		err = c.ManifestPut(ctx, rArt, mArt)
+		if err != nil {
+			t.Fatalf("failed to put referrer: %v", err)
+		}
+		// the fallback tag references the artifact, releasing the lease
+		if len(ts.leases) != 0 {
+			t.Errorf("lease was not released after the referrers put: %v", ts.leases)
+		}
+		rl, err := c.ReferrerList(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to list referrers: %v", err)
+		}
+		if len(rl.Descriptors) != 1 || rl.Descriptors[0].Digest != mArt.GetDescriptor().Digest || rl.Descriptors[0].ArtifactType != "application/example.sbom" {
+			t.Errorf("unexpected referrers: %v", rl.Descriptors)
+		}
+		err = c.ManifestDelete(ctx, rArt)
+		if err != nil {
+			t.Fatalf("failed to delete referrer: %v", err)
+		}
+		rl, err = c.ReferrerList(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to list referrers: %v", err)
+		}
+		if len(rl.Descriptors) != 0 {
+			t.Errorf("referrers remain after delete: %v", rl.Descriptors)
+		}
+		if len(ts.images) != 2 {
+			t.Errorf("fallback tag remains after delete: %v", ts.images)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		_, err := c.ManifestGet(ctx, refTag(r, "missing"))
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		_, err = c.BlobHead(ctx, r, types.Descriptor{Digest: digest.FromString("missing")})
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Tags", func(t *testing.T) {
+		tl, err := c.TagList(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		tags, _ := tl.GetTags()
+		if len(tags) != 2 || tags[0] != "v1" || tags[1] != "v2" {
+			t.Errorf("unexpected tags: %v", tags)
+		}
+		err = c.TagDelete(ctx, refTag(r, "v2"))
+		if err != nil {
+			t.Fatalf("failed to delete tag: %v", err)
+		}
+		err = c.ManifestDelete(ctx, refDigest(r, m.GetDescriptor().Digest.String()))
+		if err != nil {
+			t.Fatalf("failed to delete manifest: %v", err)
+		}
+		if len(ts.images) != 0 {
+			t.Errorf("images remain after delete: %v", ts.images)
+		}
+	})
+	t.Run("Close", func(t *testing.T) {
+		_, err := c.BlobPut(ctx, r, types.Descriptor{}, bytes.NewReader([]byte("unreferenced")))
+		if err != nil {
+			t.Fatalf("failed to put blob: %v", err)
+		}
+		if len(ts.leases) != 1 {
+			t.Errorf("unexpected leases: %v", ts.leases)
+		}
+		err = c.Close(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to close: %v", err)
+		}
+		if len(ts.leases) != 0 {
+			t.Errorf("lease was not deleted: %v", ts.leases)
+		}
+	})
+	t.Run("Namespace", func(t *testing.T) {
+		cOther := New(WithAddress(sock), WithNamespace("other"))
+		_, err := cOther.ManifestHead(ctx, r)
+		if err == nil {
+			t.Errorf("request without the namespace did not fail")
+		}
+	})
+}
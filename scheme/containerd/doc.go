@@ -0,0 +1,4 @@
+// Package containerd implements the containerd scheme, accessing the content store and images of containerd with its API socket.
+//
+// The scheme is only included when built with the "containerd" tag, e.g. "go build -tags containerd".
+package containerd
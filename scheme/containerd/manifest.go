@@ -0,0 +1,267 @@
+//go:build containerd
+
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	// manifestLimit is the maximum size of a manifest read from the content store
+	manifestLimit = 1024 * 1024 * 8
+)
+
+// ManifestDelete removes the images in the namespace with the image name that point to the manifest.
+// The content is removed by the containerd garbage collector once it is no longer referenced.
+func (c *Containerd) ManifestDelete(ctx context.Context, r ref.Ref, opts ...scheme.ManifestOpts) error {
+	if r.Digest == "" {
+		return types.ErrMissingDigest
+	}
+	mc := scheme.ManifestConfig{}
+	for _, opt := range opts {
+		opt(&mc)
+	}
+	// always check for refers with containerd
+	if mc.Manifest == nil {
+		m, err := c.ManifestGet(ctx, r)
+		if err != nil {
+			return fmt.Errorf("failed to pull manifest for refers: %w", err)
+		}
+		mc.Manifest = m
+	}
+	if ms, ok := mc.Manifest.(manifest.Subjecter); ok {
+		sDesc, err := ms.GetSubject()
+		if err == nil && sDesc != nil && sDesc.MediaType != "" && sDesc.Size > 0 {
+			// attempt to delete the referrer, but ignore if the referrer entry wasn't found
+			err = c.referrerDelete(ctx, r, mc.Manifest)
+			if err != nil && !errors.Is(err, types.ErrNotFound) {
+				return err
+			}
+		}
+	}
+
+	images, err := c.imageList(ctx, r)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		if img.Target == nil || img.Target.Digest != r.Digest {
+			continue
+		}
+		ctxAPI, err := c.apiCtx(ctx, "Images.Delete", "")
+		if err != nil {
+			return err
+		}
+		_, err = c.images.Delete(ctxAPI, &imagesapi.DeleteImageRequest{Name: img.Name})
+		if err != nil {
+			return fmt.Errorf("failed to delete image %s: %w", img.Name, apiErr("Images.Delete", err))
+		}
+	}
+	return nil
+}
+
+// ManifestGet retrieves a manifest from the content store
+func (c *Containerd) ManifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	d, raw, err := c.manifestGet(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.New(
+		manifest.WithRef(r),
+		manifest.WithDesc(d),
+		manifest.WithRaw(raw),
+	)
+}
+
+// ManifestHead gets metadata about the manifest from the image, a digest reference reads the manifest to get the media type
+func (c *Containerd) ManifestHead(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	if r.Digest == "" {
+		d, err := c.imageGet(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		return manifest.New(
+			manifest.WithRef(r),
+			manifest.WithDesc(d),
+		)
+	}
+	m, err := c.ManifestGet(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	d := m.GetDescriptor()
+	if d.MediaType == "" {
+		return nil, fmt.Errorf("failed to detect the media type of %s%.0w", r.CommonName(), types.ErrUnsupportedMediaType)
+	}
+	return manifest.New(
+		manifest.WithRef(r),
+		manifest.WithDesc(d),
+	)
+}
+
+// ManifestPut writes a manifest to the content store, and creates or updates the image when the reference has a tag.
+// The manifest is labeled with the content it references to protect it from garbage collection,
+// and the lease for the image name is released once the tagged image references the content.
+func (c *Containerd) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest, opts ...scheme.ManifestOpts) error {
+	config := scheme.ManifestConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if !config.Child && r.Digest == "" && r.Tag == "" {
+		r.Tag = "latest"
+	}
+	raw, err := m.RawBody()
+	if err != nil {
+		return err
+	}
+	lease, err := c.leaseGet(ctx, r)
+	if err != nil {
+		return err
+	}
+	d := m.GetDescriptor()
+	labels := manifestGCLabels(m)
+	_, err = c.contentWrite(ctx, lease, d, bytes.NewReader(raw), labels)
+	if errors.Is(err, errAlreadyExists) {
+		err = c.contentLabel(ctx, d.Digest, labels)
+		if err == nil {
+			err = c.leaseAdd(ctx, r, d.Digest)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if r.Tag != "" {
+		err = c.imagePut(ctx, r, types.Descriptor{MediaType: d.MediaType, Digest: d.Digest, Size: d.Size})
+		if err != nil {
+			return err
+		}
+		// the image protects the content from garbage collection
+		err = c.leaseRelease(ctx, r)
+		if err != nil {
+			return err
+		}
+	}
+	c.log.WithFields(logrus.Fields{
+		"ref":    r.CommonName(),
+		"digest": d.Digest.String(),
+	}).Debug("pushed manifest")
+
+	// update referrers if defined on this manifest
+	if ms, ok := m.(manifest.Subjecter); ok {
+		mDesc, err := ms.GetSubject()
+		if err != nil {
+			return err
+		}
+		if mDesc != nil && mDesc.MediaType != "" && mDesc.Size > 0 {
+			err = c.referrerPut(ctx, r, m)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// manifestGet returns the descriptor and content for a digest or the target of the tagged image
+func (c *Containerd) manifestGet(ctx context.Context, r ref.Ref) (types.Descriptor, []byte, error) {
+	var d types.Descriptor
+	if r.Digest == "" {
+		var err error
+		d, err = c.imageGet(ctx, r)
+		if err != nil {
+			return d, nil, err
+		}
+	} else {
+		dig, err := digest.Parse(r.Digest)
+		if err != nil {
+			return d, nil, err
+		}
+		size, err := c.contentInfo(ctx, dig)
+		if err != nil {
+			return d, nil, err
+		}
+		d = types.Descriptor{Digest: dig, Size: size}
+	}
+	if d.Size > manifestLimit {
+		return d, nil, fmt.Errorf("manifest %s exceeds the size limit of %d%.0w", r.CommonName(), manifestLimit, types.ErrSizeLimitExceeded)
+	}
+	br, err := c.BlobGet(ctx, r, d)
+	if err != nil {
+		return d, nil, err
+	}
+	defer br.Close()
+	raw, err := io.ReadAll(br)
+	if err != nil {
+		return d, nil, fmt.Errorf("failed to read manifest %s: %w", r.CommonName(), err)
+	}
+	return d, raw, nil
+}
+
+// imagePut creates the tagged image, updating the target of an existing image
+func (c *Containerd) imagePut(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	img := &imagesapi.Image{
+		Name:   imageName(r),
+		Target: descToAPI(d),
+	}
+	ctxAPI, err := c.apiCtx(ctx, "Images.Create", "")
+	if err != nil {
+		return err
+	}
+	_, err = c.images.Create(ctxAPI, &imagesapi.CreateImageRequest{Image: img})
+	err = apiErr("Images.Create", err)
+	if errors.Is(err, errAlreadyExists) {
+		ctxAPI, err = c.apiCtx(ctx, "Images.Update", "")
+		if err != nil {
+			return err
+		}
+		_, err = c.images.Update(ctxAPI, &imagesapi.UpdateImageRequest{
+			Image:      img,
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"target"}},
+		})
+		err = apiErr("Images.Update", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create image %s: %w", img.Name, err)
+	}
+	return nil
+}
+
+// manifestGCLabels returns the labels referencing the children of a manifest, used by the containerd garbage collector
+func manifestGCLabels(m manifest.Manifest) map[string]string {
+	labels := map[string]string{}
+	if mi, ok := m.(manifest.Indexer); ok {
+		dl, err := mi.GetManifestList()
+		if err == nil {
+			for i, d := range dl {
+				labels[labelGCRef+"m."+strconv.Itoa(i)] = d.Digest.String()
+			}
+		}
+	}
+	if mi, ok := m.(manifest.Imager); ok {
+		cd, err := mi.GetConfig()
+		if err == nil {
+			labels[labelGCRef+"config"] = cd.Digest.String()
+		}
+		dl, err := mi.GetLayers()
+		if err == nil {
+			for i, d := range dl {
+				labels[labelGCRef+"l."+strconv.Itoa(i)] = d.Digest.String()
+			}
+		}
+	}
+	return labels
+}
@@ -0,0 +1,183 @@
+//go:build containerd
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/referrer"
+)
+
+// ReferrerList returns a list of referrers to a given reference, stored in an image with the fallback tag
+func (c *Containerd) ReferrerList(ctx context.Context, r ref.Ref, opts ...scheme.ReferrerOpts) (referrer.ReferrerList, error) {
+	config := scheme.ReferrerConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	rl := referrer.ReferrerList{
+		Subject: r,
+		Tags:    []string{},
+	}
+	// select a platform from a manifest list
+	if config.Platform != "" {
+		m, err := c.ManifestGet(ctx, r)
+		if err != nil {
+			return rl, err
+		}
+		if m.IsList() {
+			plat, err := platform.Parse(config.Platform)
+			if err != nil {
+				return rl, err
+			}
+			d, err := manifest.GetPlatformDesc(m, &plat)
+			if err != nil {
+				return rl, err
+			}
+			r.Digest = d.Digest.String()
+		} else {
+			r.Digest = m.GetDescriptor().Digest.String()
+		}
+	}
+	// if ref is a tag, get the digest from the image
+	if r.Digest == "" {
+		m, err := c.ManifestHead(ctx, r)
+		if err != nil {
+			return rl, err
+		}
+		r.Digest = m.GetDescriptor().Digest.String()
+	}
+
+	// pull referrer list by tag
+	rlTag, err := referrer.FallbackTag(r)
+	if err != nil {
+		return rl, err
+	}
+	m, err := c.ManifestGet(ctx, rlTag)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			// empty list, initialize a new manifest
+			rl.Manifest, err = manifest.New(manifest.WithOrig(v1.Index{
+				Versioned: v1.IndexSchemaVersion,
+				MediaType: types.MediaTypeOCI1ManifestList,
+			}))
+			if err != nil {
+				return rl, err
+			}
+			return rl, nil
+		}
+		return rl, err
+	}
+	ociML, ok := m.GetOrig().(v1.Index)
+	if !ok {
+		return rl, fmt.Errorf("manifest is not an OCI index: %s", rlTag.CommonName())
+	}
+	// update referrer list
+	rl.Manifest = m
+	rl.Descriptors = ociML.Manifests
+	rl.Annotations = ociML.Annotations
+	rl.Tags = append(rl.Tags, rlTag.Tag)
+	rl = scheme.ReferrerFilter(config, rl)
+
+	return rl, nil
+}
+
+// referrerDelete deletes a referrer associated with a manifest
+func (c *Containerd) referrerDelete(ctx context.Context, r ref.Ref, m manifest.Manifest) error {
+	// get refers field
+	mSubject, ok := m.(manifest.Subjecter)
+	if !ok {
+		return fmt.Errorf("manifest does not support subject: %w", types.ErrUnsupportedMediaType)
+	}
+	subject, err := mSubject.GetSubject()
+	if err != nil {
+		return err
+	}
+	// validate/set subject descriptor
+	if subject == nil || subject.MediaType == "" || subject.Digest == "" || subject.Size <= 0 {
+		return fmt.Errorf("subject is not set%.0w", types.ErrNotFound)
+	}
+
+	// get descriptor for subject
+	rSubject := r
+	rSubject.Tag = ""
+	rSubject.Digest = subject.Digest.String()
+
+	// the list is read and written back by tag, serialize concurrent updates
+	c.muRef.Lock()
+	defer c.muRef.Unlock()
+
+	// pull existing referrer list
+	rl, err := c.ReferrerList(ctx, rSubject)
+	if err != nil {
+		return err
+	}
+	err = rl.Delete(m)
+	if err != nil {
+		return err
+	}
+
+	// push updated referrer list by tag
+	rlTag, err := referrer.FallbackTag(rSubject)
+	if err != nil {
+		return err
+	}
+	if rl.IsEmpty() {
+		err = c.TagDelete(ctx, rlTag)
+		if err == nil {
+			return nil
+		}
+		// if delete is not supported, fall back to pushing empty list
+	}
+	return c.ManifestPut(ctx, rlTag, rl.Manifest)
+}
+
+// referrerPut pushes a new referrer associated with a given reference
+func (c *Containerd) referrerPut(ctx context.Context, r ref.Ref, m manifest.Manifest) error {
+	// get subject field
+	mSubject, ok := m.(manifest.Subjecter)
+	if !ok {
+		return fmt.Errorf("manifest does not support subject: %w", types.ErrUnsupportedMediaType)
+	}
+	subject, err := mSubject.GetSubject()
+	if err != nil {
+		return err
+	}
+	// validate/set subject descriptor
+	if subject == nil || subject.MediaType == "" || subject.Digest == "" || subject.Size <= 0 {
+		return fmt.Errorf("subject is not set%.0w", types.ErrNotFound)
+	}
+
+	// get descriptor for subject
+	rSubject := r
+	rSubject.Tag = ""
+	rSubject.Digest = subject.Digest.String()
+
+	// the list is read and written back by tag, serialize concurrent updates
+	c.muRef.Lock()
+	defer c.muRef.Unlock()
+
+	// pull existing referrer list
+	rl, err := c.ReferrerList(ctx, rSubject)
+	if err != nil {
+		return err
+	}
+	err = rl.Add(m)
+	if err != nil {
+		return err
+	}
+
+	// push updated referrer list by tag
+	rlTag, err := referrer.FallbackTag(rSubject)
+	if err != nil {
+		return err
+	}
+	return c.ManifestPut(ctx, rlTag, rl.Manifest)
+}
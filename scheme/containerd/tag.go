@@ -0,0 +1,83 @@
+//go:build containerd
+
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/tag"
+)
+
+// TagDelete removes the image from the namespace, the content is removed by the containerd garbage collector
+func (c *Containerd) TagDelete(ctx context.Context, r ref.Ref) error {
+	if r.Tag == "" {
+		return types.ErrMissingTag
+	}
+	ctx, err := c.apiCtx(ctx, "Images.Delete", "")
+	if err != nil {
+		return err
+	}
+	_, err = c.images.Delete(ctx, &imagesapi.DeleteImageRequest{Name: imageName(r)})
+	if err != nil {
+		return fmt.Errorf("failed to delete tag %s: %w", r.CommonName(), apiErr("Images.Delete", err))
+	}
+	return nil
+}
+
+// TagList returns the tags of the images in the namespace with the image name
+func (c *Containerd) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts) (*tag.List, error) {
+	images, err := c.imageList(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	r.Tag = ""
+	r.Digest = ""
+	prefix := imageName(r) + ":"
+	tl := []string{}
+	for _, img := range images {
+		t := strings.TrimPrefix(img.Name, prefix)
+		if !strings.ContainsAny(t, ":@") {
+			tl = append(tl, t)
+		}
+	}
+	sort.Strings(tl)
+	raw, err := json.Marshal(tag.DockerList{Name: r.Path, Tags: tl})
+	if err != nil {
+		return nil, err
+	}
+	return tag.New(
+		tag.WithRaw(raw),
+		tag.WithRef(r),
+		tag.WithTags(tl),
+	)
+}
+
+// imageList returns the images in the namespace with the image name, including images named with a digest
+func (c *Containerd) imageList(ctx context.Context, r ref.Ref) ([]*imagesapi.Image, error) {
+	r.Tag = ""
+	r.Digest = ""
+	name := imageName(r)
+	ctx, err := c.apiCtx(ctx, "Images.List", "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.images.List(ctx, &imagesapi.ListImagesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images for %s: %w", r.CommonName(), apiErr("Images.List", err))
+	}
+	images := []*imagesapi.Image{}
+	for _, img := range resp.Images {
+		if strings.HasPrefix(img.Name, name+":") || strings.HasPrefix(img.Name, name+"@") {
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
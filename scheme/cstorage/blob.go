@@ -0,0 +1,117 @@
+package cstorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/blob"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+// BlobDelete is not supported, containers-storage is read-only
+func (c *CStorage) BlobDelete(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	return types.ErrUnsupported
+}
+
+// BlobGet retrieves an image config, or a layer reassembled from the storage driver
+func (c *CStorage) BlobGet(ctx context.Context, r ref.Ref, d types.Descriptor) (blob.Reader, error) {
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	img, key, l, err := c.blobFind(driver, d)
+	if err != nil {
+		return nil, err
+	}
+	// image configs are stored as big data on the image, layers are streamed while they are reassembled
+	var rdr io.ReadCloser
+	if key != "" {
+		fh, err := os.Open(c.bigDataFile(driver, img, key))
+		if err != nil {
+			return nil, err
+		}
+		fi, err := fh.Stat()
+		if err != nil {
+			fh.Close()
+			return nil, err
+		}
+		d.Size = fi.Size()
+		rdr = fh
+	} else {
+		d.Size = l.UncompressedSize
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(c.layerAssemble(driver, l, pw))
+		}()
+		rdr = pr
+	}
+	c.log.WithFields(logrus.Fields{
+		"ref":    r.CommonName(),
+		"digest": d.Digest.String(),
+	}).Debug("retrieved blob")
+	return blob.NewReader(
+		blob.WithRef(r),
+		blob.WithReader(rdr),
+		blob.WithDesc(d),
+	), nil
+}
+
+// BlobHead verifies the existence of an image config or layer
+func (c *CStorage) BlobHead(ctx context.Context, r ref.Ref, d types.Descriptor) (blob.Reader, error) {
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	img, key, l, err := c.blobFind(driver, d)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		d.Size = img.BigDataSizes[key]
+	} else {
+		d.Size = l.UncompressedSize
+	}
+	return blob.NewReader(
+		blob.WithRef(r),
+		blob.WithDesc(d),
+	), nil
+}
+
+// BlobMount is not supported, containers-storage is read-only
+func (c *CStorage) BlobMount(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, d types.Descriptor) error {
+	return types.ErrUnsupported
+}
+
+// BlobPut is not supported, containers-storage is read-only
+func (c *CStorage) BlobPut(ctx context.Context, r ref.Ref, d types.Descriptor, rdr io.Reader) (types.Descriptor, error) {
+	return d, types.ErrUnsupported
+}
+
+// blobFind returns the image and big data key for a config, or the layer with the uncompressed digest
+func (c *CStorage) blobFind(driver string, d types.Descriptor) (image, string, layer, error) {
+	il, err := c.images(driver)
+	if err != nil {
+		return image{}, "", layer{}, err
+	}
+	for _, img := range il {
+		for key, dig := range img.BigDataDigests {
+			if dig == d.Digest {
+				return img, key, layer{}, nil
+			}
+		}
+	}
+	lm, err := c.layers(driver)
+	if err != nil {
+		return image{}, "", layer{}, err
+	}
+	for _, l := range lm {
+		if l.UncompressedDigest == d.Digest {
+			return image{}, "", l, nil
+		}
+	}
+	return image{}, "", layer{}, fmt.Errorf("blob %s not found in %s%.0w", d.Digest.String(), c.root, types.ErrNotFound)
+}
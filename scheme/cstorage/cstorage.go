@@ -0,0 +1,325 @@
+// Package cstorage implements the read-only containers-storage scheme, accessing images built locally by podman and buildah
+package cstorage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	rootDefault = "/var/lib/containers/storage"
+	confDefault = "/etc/containers/storage.conf"
+)
+
+// driverNames are the supported storage drivers, in the order they are detected
+var driverNames = []string{"overlay", "vfs"}
+
+// drivers return the path to the files of a layer for each storage driver
+var drivers = map[string]func(root, id string) string{
+	"overlay": func(root, id string) string {
+		return filepath.Join(root, "overlay", id, "diff")
+	},
+	"vfs": func(root, id string) string {
+		return filepath.Join(root, "vfs", "dir", id)
+	},
+}
+
+func init() {
+	scheme.Register("containers-storage", func(conf scheme.Config) scheme.API {
+		return New(WithLog(conf.Log))
+	})
+}
+
+// CStorage is used for reading images from containers-storage, e.g. "containers-storage://localhost/name:tag"
+//
+// Each layer is reassembled from the tar-split metadata and the extracted files in the storage driver.
+// The manifest is generated with uncompressed layers, giving a different digest than the manifest pulled from a registry.
+type CStorage struct {
+	root string
+	log  *logrus.Logger
+}
+
+type cstorageConf struct {
+	root string
+	log  *logrus.Logger
+}
+
+// Opts are used for passing options to cstorage
+type Opts func(*cstorageConf)
+
+// New creates a new CStorage with options
+func New(opts ...Opts) *CStorage {
+	conf := cstorageConf{
+		log: &logrus.Logger{Out: io.Discard},
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.root == "" {
+		conf.root = rootFind()
+	}
+	return &CStorage{
+		root: conf.root,
+		log:  conf.log,
+	}
+}
+
+// WithLog provides a logrus logger
+// By default logging is disabled
+func WithLog(log *logrus.Logger) Opts {
+	return func(c *cstorageConf) {
+		c.log = log
+	}
+}
+
+// WithRoot sets the graph root of the storage.
+// The default is the graphroot from storage.conf, falling back to the rootful or rootless default path.
+func WithRoot(root string) Opts {
+	return func(c *cstorageConf) {
+		c.root = root
+	}
+}
+
+// rootFind returns the graph root for the current user
+func rootFind() string {
+	rootless := os.Geteuid() > 0
+	confFiles := []string{}
+	if conf := os.Getenv("CONTAINERS_STORAGE_CONF"); conf != "" {
+		confFiles = append(confFiles, conf)
+	}
+	if rootless {
+		if confDir, err := os.UserConfigDir(); err == nil {
+			confFiles = append(confFiles, filepath.Join(confDir, "containers", "storage.conf"))
+		}
+	} else {
+		confFiles = append(confFiles, confDefault)
+	}
+	for _, confFile := range confFiles {
+		if root := confGraphRoot(confFile); root != "" {
+			return root
+		}
+	}
+	if !rootless {
+		return rootDefault
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return rootDefault
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "containers", "storage")
+}
+
+// confGraphRoot returns the graphroot setting from a storage.conf file
+func confGraphRoot(file string) string {
+	fh, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok && strings.TrimSpace(key) == "graphroot" {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// image is an entry in the images.json of the storage
+type image struct {
+	ID             string                   `json:"id"`
+	Digest         digest.Digest            `json:"digest,omitempty"`
+	Names          []string                 `json:"names,omitempty"`
+	Layer          string                   `json:"layer,omitempty"`
+	BigDataNames   []string                 `json:"big-data-names,omitempty"`
+	BigDataSizes   map[string]int64         `json:"big-data-sizes,omitempty"`
+	BigDataDigests map[string]digest.Digest `json:"big-data-digests,omitempty"`
+}
+
+// layer is an entry in the layers.json of the storage
+type layer struct {
+	ID                 string        `json:"id"`
+	Parent             string        `json:"parent,omitempty"`
+	UncompressedDigest digest.Digest `json:"diff-digest,omitempty"`
+	UncompressedSize   int64         `json:"diff-size,omitempty"`
+}
+
+// driver returns the storage driver, detected from the image metadata in the graph root
+func (c *CStorage) driver() (string, error) {
+	for _, name := range driverNames {
+		if _, err := os.Stat(filepath.Join(c.root, name+"-images", "images.json")); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no supported storage driver found in %s%.0w", c.root, types.ErrNotFound)
+}
+
+func (c *CStorage) images(driver string) ([]image, error) {
+	il := []image{}
+	err := readJSON(filepath.Join(c.root, driver+"-images", "images.json"), &il)
+	return il, err
+}
+
+func (c *CStorage) layers(driver string) (map[string]layer, error) {
+	ll := []layer{}
+	err := readJSON(filepath.Join(c.root, driver+"-layers", "layers.json"), &ll)
+	if err != nil {
+		return nil, err
+	}
+	lm := map[string]layer{}
+	for _, l := range ll {
+		lm[l.ID] = l
+	}
+	return lm, nil
+}
+
+// imageFind returns the image matching a reference.
+// Names without a registry are matched against localhost and docker.io, the same as podman.
+// A digest matches the generated manifest, the manifest pulled from a registry, or the image ID.
+func (c *CStorage) imageFind(driver string, r ref.Ref) (image, manifest.Manifest, error) {
+	il, err := c.images(driver)
+	if err != nil {
+		return image{}, nil, err
+	}
+	names := nameCandidates(r.Path)
+	for _, img := range il {
+		if r.Tag == "" && r.Digest != "" {
+			if img.Digest.String() != r.Digest && "sha256:"+img.ID != r.Digest {
+				m, err := c.imageManifest(driver, img, r)
+				if err != nil || m.GetDescriptor().Digest.String() != r.Digest {
+					continue
+				}
+				return img, m, nil
+			}
+			m, err := c.imageManifest(driver, img, r)
+			return img, m, err
+		}
+		tag := r.Tag
+		if tag == "" {
+			tag = "latest"
+		}
+		for _, imgName := range img.Names {
+			for _, name := range names {
+				if imgName == name+":"+tag {
+					m, err := c.imageManifest(driver, img, r)
+					return img, m, err
+				}
+			}
+		}
+	}
+	return image{}, nil, fmt.Errorf("image %s not found in %s%.0w", r.CommonName(), c.root, types.ErrNotFound)
+}
+
+// imageManifest generates an OCI manifest for an image with the uncompressed layers
+func (c *CStorage) imageManifest(driver string, img image, r ref.Ref) (manifest.Manifest, error) {
+	key, err := imageConfigKey(img)
+	if err != nil {
+		return nil, err
+	}
+	confDesc := types.Descriptor{
+		MediaType: types.MediaTypeOCI1ImageConfig,
+		Digest:    img.BigDataDigests[key],
+		Size:      img.BigDataSizes[key],
+	}
+	if confDesc.Digest == "" || confDesc.Size <= 0 {
+		b, err := os.ReadFile(c.bigDataFile(driver, img, key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config for image %s: %w", img.ID, err)
+		}
+		confDesc.Digest = digest.FromBytes(b)
+		confDesc.Size = int64(len(b))
+	}
+	lm, err := c.layers(driver)
+	if err != nil {
+		return nil, err
+	}
+	layers := []types.Descriptor{}
+	for id := img.Layer; id != ""; {
+		l, ok := lm[id]
+		if !ok {
+			return nil, fmt.Errorf("layer %s not found for image %s%.0w", id, img.ID, types.ErrNotFound)
+		}
+		if l.UncompressedDigest == "" || l.UncompressedSize <= 0 {
+			return nil, fmt.Errorf("layer %s is missing the uncompressed digest or size%.0w", id, types.ErrUnsupported)
+		}
+		layers = append([]types.Descriptor{{
+			MediaType: types.MediaTypeOCI1Layer,
+			Digest:    l.UncompressedDigest,
+			Size:      l.UncompressedSize,
+		}}, layers...)
+		id = l.Parent
+		if len(layers) > len(lm) {
+			return nil, fmt.Errorf("layer loop detected for image %s%.0w", img.ID, types.ErrLoopDetected)
+		}
+	}
+	return manifest.New(
+		manifest.WithOrig(v1.Manifest{
+			Versioned: v1.ManifestSchemaVersion,
+			MediaType: types.MediaTypeOCI1Manifest,
+			Config:    confDesc,
+			Layers:    layers,
+		}),
+		manifest.WithRef(r),
+	)
+}
+
+// nameCandidates returns the fully qualified names for a repository
+func nameCandidates(repo string) []string {
+	first, _, found := strings.Cut(repo, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return []string{repo}
+	}
+	names := []string{"localhost/" + repo}
+	if !found {
+		return append(names, "docker.io/library/"+repo)
+	}
+	return append(names, "docker.io/"+repo)
+}
+
+// imageConfigKey returns the big data key of the image config
+func imageConfigKey(img image) (string, error) {
+	if _, ok := img.BigDataDigests["sha256:"+img.ID]; ok {
+		return "sha256:" + img.ID, nil
+	}
+	for _, name := range img.BigDataNames {
+		if _, err := digest.Parse(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("config not found for image %s%.0w", img.ID, types.ErrNotFound)
+}
+
+// bigDataFile returns the filename of big data for an image, names with special characters are base64 encoded
+func (c *CStorage) bigDataFile(driver string, img image, key string) string {
+	return filepath.Join(c.root, driver+"-images", img.ID, bigDataName(key))
+}
+
+func readJSON(file string, v interface{}) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return nil
+}
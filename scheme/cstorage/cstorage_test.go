@@ -0,0 +1,240 @@
+package cstorage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// testLayer writes a layer to the storage, splitting the tar into the tar-split metadata and the extracted files
+func testLayer(t *testing.T, root, id string, files map[string]string) (digest.Digest, int64) {
+	t.Helper()
+	diffDir := filepath.Join(root, "overlay", id, "diff")
+	if err := os.MkdirAll(diffDir, 0755); err != nil {
+		t.Fatalf("failed to create diff dir: %v", err)
+	}
+	tsBuf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(tsBuf)
+	enc := json.NewEncoder(gzw)
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	mark := 0
+	segment := func() {
+		if buf.Len() > mark {
+			_ = enc.Encode(tarSplitEntry{Type: tarSplitSegment, Payload: buf.Bytes()[mark:]})
+		}
+		mark = buf.Len()
+	}
+	for _, name := range []string{"dir/", "dir/a.txt", "dir/b.txt"} {
+		th := &tar.Header{Name: name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(files[name]))}
+		if strings.HasSuffix(name, "/") {
+			th = &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(th); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		segment()
+		if th.Typeflag == tar.TypeDir {
+			continue
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		mark = buf.Len()
+		_ = enc.Encode(tarSplitEntry{Type: tarSplitFile, Name: name, Size: th.Size})
+		file := filepath.Join(diffDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(files[name]), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	segment()
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("failed to close gzip: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "overlay-layers", id+".tar-split.gz"), tsBuf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write tar-split: %v", err)
+	}
+	return digest.FromBytes(buf.Bytes()), int64(buf.Len())
+}
+
+func testStorage(t *testing.T) (string, []byte, digest.Digest) {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"overlay-images", "overlay-layers"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+	dig1, size1 := testLayer(t, root, "layer1", map[string]string{"dir/a.txt": "hello", "dir/b.txt": "world"})
+	dig2, size2 := testLayer(t, root, "layer2", map[string]string{"dir/a.txt": "changed", "dir/b.txt": ""})
+	lb, _ := json.Marshal([]layer{
+		{ID: "layer1", UncompressedDigest: dig1, UncompressedSize: size1},
+		{ID: "layer2", Parent: "layer1", UncompressedDigest: dig2, UncompressedSize: size2},
+	})
+	if err := os.WriteFile(filepath.Join(root, "overlay-layers", "layers.json"), lb, 0644); err != nil {
+		t.Fatalf("failed to write layers: %v", err)
+	}
+	conf := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + dig1.String() + `","` + dig2.String() + `"]}}`)
+	confDig := digest.FromBytes(conf)
+	id := confDig.Encoded()
+	key := confDig.String()
+	if err := os.MkdirAll(filepath.Join(root, "overlay-images", id), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "overlay-images", id, bigDataName(key)), conf, 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	ib, _ := json.Marshal([]image{{
+		ID:             id,
+		Names:          []string{"localhost/test/img:v1", "localhost/test/img:v2", "docker.io/library/other:latest"},
+		Layer:          "layer2",
+		BigDataNames:   []string{key},
+		BigDataSizes:   map[string]int64{key: int64(len(conf))},
+		BigDataDigests: map[string]digest.Digest{key: confDig},
+	}})
+	if err := os.WriteFile(filepath.Join(root, "overlay-images", "images.json"), ib, 0644); err != nil {
+		t.Fatalf("failed to write images: %v", err)
+	}
+	return root, conf, dig2
+}
+
+func TestCStorage(t *testing.T) {
+	ctx := context.Background()
+	root, conf, dig2 := testStorage(t)
+	c := New(WithRoot(root))
+	r, err := ref.New("containers-storage://test/img:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	m, err := c.ManifestGet(ctx, r)
+	if err != nil {
+		t.Fatalf("failed to get manifest: %v", err)
+	}
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		t.Fatalf("manifest is not an image")
+	}
+	cd, err := mi.GetConfig()
+	if err != nil || cd.Digest != digest.FromBytes(conf) {
+		t.Errorf("unexpected config: %v, %v", cd, err)
+	}
+	layers, err := mi.GetLayers()
+	if err != nil || len(layers) != 2 || layers[1].Digest != dig2 {
+		t.Fatalf("unexpected layers: %v, %v", layers, err)
+	}
+	t.Run("Blobs", func(t *testing.T) {
+		for _, d := range append(layers, cd) {
+			br, err := c.BlobGet(ctx, r, d)
+			if err != nil {
+				t.Fatalf("failed to get blob %s: %v", d.Digest, err)
+			}
+			b, err := io.ReadAll(br)
+			br.Close()
+			if err != nil {
+				t.Fatalf("failed to read blob %s: %v", d.Digest, err)
+			}
+			if digest.FromBytes(b) != d.Digest || int64(len(b)) != d.Size {
+				t.Errorf("blob mismatch, expected %s, received %s", d.Digest, digest.FromBytes(b))
+			}
+			bh, err := c.BlobHead(ctx, r, d)
+			if err != nil {
+				t.Fatalf("failed to head blob %s: %v", d.Digest, err)
+			}
+			if bh.GetDescriptor().Size != d.Size {
+				t.Errorf("unexpected size for %s: %d", d.Digest, bh.GetDescriptor().Size)
+			}
+		}
+		_, err = c.BlobHead(ctx, r, types.Descriptor{Digest: digest.FromString("missing")})
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error for a missing blob: %v", err)
+		}
+	})
+	t.Run("Names", func(t *testing.T) {
+		for _, name := range []string{
+			"containers-storage://localhost/test/img:v2",
+			"containers-storage://other",
+			"containers-storage://docker.io/library/other:latest",
+			"containers-storage://test/img@" + m.GetDescriptor().Digest.String(),
+			"containers-storage://test/img@" + cd.Digest.String(),
+		} {
+			rName, err := ref.New(name)
+			if err != nil {
+				t.Fatalf("failed to parse ref: %v", err)
+			}
+			mh, err := c.ManifestHead(ctx, rName)
+			if err != nil {
+				t.Errorf("failed to head %s: %v", name, err)
+			} else if mh.GetDescriptor().Digest != m.GetDescriptor().Digest {
+				t.Errorf("digest mismatch for %s", name)
+			}
+		}
+		rMissing, _ := ref.New("containers-storage://test/img:missing")
+		_, err = c.ManifestHead(ctx, rMissing)
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error for a missing tag: %v", err)
+		}
+	})
+	t.Run("Tags", func(t *testing.T) {
+		tl, err := c.TagList(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		tags, _ := tl.GetTags()
+		if strings.Join(tags, ",") != "v1,v2" {
+			t.Errorf("unexpected tags: %v", tags)
+		}
+	})
+	t.Run("ReadOnly", func(t *testing.T) {
+		err := c.ManifestPut(ctx, r, m)
+		if !errors.Is(err, types.ErrUnsupported) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Escape", func(t *testing.T) {
+		// a symlink in the layer content must not be followed outside of the layer
+		diffDir := filepath.Join(root, "overlay", "layer1", "diff")
+		if err := os.Remove(filepath.Join(diffDir, "dir", "a.txt")); err != nil {
+			t.Fatalf("failed to remove file: %v", err)
+		}
+		if err := os.Symlink(filepath.Join(root, "overlay-layers", "layers.json"), filepath.Join(diffDir, "dir", "a.txt")); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+		br, err := c.BlobGet(ctx, r, layers[0])
+		if err != nil {
+			t.Fatalf("failed to get blob: %v", err)
+		}
+		_, err = io.ReadAll(br)
+		br.Close()
+		if err == nil {
+			t.Errorf("layer with an escaping symlink did not fail")
+		}
+	})
+}
+
+func TestBigDataName(t *testing.T) {
+	if bigDataName("manifest") != "manifest" {
+		t.Errorf("unexpected name for manifest: %s", bigDataName("manifest"))
+	}
+	if bigDataName("sha256:abc") != "=c2hhMjU2OmFiYw==" {
+		t.Errorf("unexpected name for digest: %s", bigDataName("sha256:abc"))
+	}
+}
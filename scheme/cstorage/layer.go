@@ -0,0 +1,111 @@
+package cstorage
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/regclient/regclient/types"
+)
+
+// tar-split entry types
+const (
+	tarSplitFile    = 1
+	tarSplitSegment = 2
+)
+
+// tarSplitEntry is a line in the tar-split metadata of a layer.
+// Segments contain the raw tar headers and padding, files are read from the layer content.
+type tarSplitEntry struct {
+	Type    int    `json:"type"`
+	Name    string `json:"name,omitempty"`
+	NameRaw []byte `json:"name_raw,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Payload []byte `json:"payload"`
+}
+
+// layerAssemble writes the original uncompressed tar of a layer
+func (c *CStorage) layerAssemble(driver string, l layer, w io.Writer) error {
+	tsFile := filepath.Join(c.root, driver+"-layers", l.ID+".tar-split.gz")
+	fh, err := os.Open(tsFile)
+	if err != nil {
+		return fmt.Errorf("failed to open tar-split for layer %s: %w", l.ID, err)
+	}
+	defer fh.Close()
+	gzr, err := gzip.NewReader(fh)
+	if err != nil {
+		return fmt.Errorf("failed to read tar-split for layer %s: %w", l.ID, err)
+	}
+	defer gzr.Close()
+	diffDir, err := filepath.EvalSymlinks(drivers[driver](c.root, l.ID))
+	if err != nil {
+		return fmt.Errorf("failed to find content for layer %s: %w", l.ID, err)
+	}
+	dec := json.NewDecoder(gzr)
+	for {
+		e := tarSplitEntry{}
+		err = dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse tar-split for layer %s: %w", l.ID, err)
+		}
+		switch e.Type {
+		case tarSplitSegment:
+			_, err = w.Write(e.Payload)
+			if err != nil {
+				return err
+			}
+		case tarSplitFile:
+			if e.Size == 0 {
+				continue
+			}
+			name := e.Name
+			if len(e.NameRaw) > 0 {
+				name = string(e.NameRaw)
+			}
+			err = layerFileCopy(w, diffDir, name, e.Size)
+			if err != nil {
+				return fmt.Errorf("failed to copy %s for layer %s: %w", name, l.ID, err)
+			}
+		default:
+			return fmt.Errorf("unknown tar-split entry type %d for layer %s%.0w", e.Type, l.ID, types.ErrUnsupported)
+		}
+	}
+}
+
+// layerFileCopy copies a file from the layer content, rejecting symlinks that resolve outside of the layer
+func layerFileCopy(w io.Writer, diffDir, name string, size int64) error {
+	file, err := filepath.EvalSymlinks(filepath.Join(diffDir, filepath.FromSlash(path.Clean("/"+name))))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(file, diffDir+string(filepath.Separator)) {
+		return fmt.Errorf("file resolves outside of the layer: %s", file)
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	_, err = io.CopyN(w, fh, size)
+	return err
+}
+
+// bigDataName returns the filename used by containers-storage for an image big data key
+func bigDataName(key string) string {
+	for _, ch := range key {
+		if ch != '.' && !(ch >= '0' && ch <= '9') && !(ch >= 'a' && ch <= 'z') {
+			return "=" + base64.StdEncoding.EncodeToString([]byte(key))
+		}
+	}
+	return key
+}
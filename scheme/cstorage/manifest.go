@@ -0,0 +1,41 @@
+package cstorage
+
+import (
+	"context"
+
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/referrer"
+)
+
+// ManifestDelete is not supported, containers-storage is read-only
+func (c *CStorage) ManifestDelete(ctx context.Context, r ref.Ref, opts ...scheme.ManifestOpts) error {
+	return types.ErrUnsupported
+}
+
+// ManifestGet returns an OCI manifest generated for the image
+func (c *CStorage) ManifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	_, m, err := c.imageFind(driver, r)
+	return m, err
+}
+
+// ManifestHead returns the OCI manifest generated for the image, the manifest is small enough to always be generated
+func (c *CStorage) ManifestHead(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	return c.ManifestGet(ctx, r)
+}
+
+// ManifestPut is not supported, containers-storage is read-only
+func (c *CStorage) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest, opts ...scheme.ManifestOpts) error {
+	return types.ErrUnsupported
+}
+
+// ReferrerList is not supported by containers-storage
+func (c *CStorage) ReferrerList(ctx context.Context, r ref.Ref, opts ...scheme.ReferrerOpts) (referrer.ReferrerList, error) {
+	return referrer.ReferrerList{}, types.ErrUnsupported
+}
@@ -0,0 +1,54 @@
+package cstorage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/tag"
+)
+
+// TagDelete is not supported, containers-storage is read-only
+func (c *CStorage) TagDelete(ctx context.Context, r ref.Ref) error {
+	return types.ErrUnsupported
+}
+
+// TagList returns the tags of the images with the repository name
+func (c *CStorage) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts) (*tag.List, error) {
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	il, err := c.images(driver)
+	if err != nil {
+		return nil, err
+	}
+	names := nameCandidates(r.Path)
+	tl := []string{}
+	seen := map[string]bool{}
+	for _, img := range il {
+		for _, imgName := range img.Names {
+			for _, name := range names {
+				t, ok := strings.CutPrefix(imgName, name+":")
+				if ok && !seen[t] {
+					seen[t] = true
+					tl = append(tl, t)
+				}
+			}
+		}
+	}
+	sort.Strings(tl)
+	raw, err := json.Marshal(tag.DockerList{Name: r.Path, Tags: tl})
+	if err != nil {
+		return nil, err
+	}
+	return tag.New(
+		tag.WithRaw(raw),
+		tag.WithRef(r),
+		tag.WithTags(tl),
+	)
+}
@@ -0,0 +1,63 @@
+package dockerd
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/blob"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// BlobDelete is not supported by the docker daemon
+func (d *DockerD) BlobDelete(ctx context.Context, r ref.Ref, desc types.Descriptor) error {
+	return types.ErrUnsupported
+}
+
+// BlobGet retrieves a blob from an image saved from the docker daemon
+func (d *DockerD) BlobGet(ctx context.Context, r ref.Ref, desc types.Descriptor) (blob.Reader, error) {
+	s, err := d.blobStore(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	rs := storeRef(r)
+	rs.Tag = ""
+	return s.oci.BlobGet(ctx, rs, desc)
+}
+
+// BlobHead verifies the existence of a blob in an image saved from, or being loaded into, the docker daemon
+func (d *DockerD) BlobHead(ctx context.Context, r ref.Ref, desc types.Descriptor) (blob.Reader, error) {
+	s, err := d.blobStore(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	rs := storeRef(r)
+	rs.Tag = ""
+	return s.oci.BlobHead(ctx, rs, desc)
+}
+
+// BlobMount is not supported by the docker daemon
+func (d *DockerD) BlobMount(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref, desc types.Descriptor) error {
+	return types.ErrUnsupported
+}
+
+// BlobPut adds a blob to the image that is loaded into the docker daemon with the next manifest put
+func (d *DockerD) BlobPut(ctx context.Context, r ref.Ref, desc types.Descriptor, rdr io.Reader) (types.Descriptor, error) {
+	s, err := d.storeGet(r, true)
+	if err != nil {
+		return desc, err
+	}
+	rs := storeRef(r)
+	rs.Tag = ""
+	return s.oci.BlobPut(ctx, rs, desc, rdr)
+}
+
+// blobStore returns the store for a blob request, saving a tagged image that is not found in the daemon is not an error
+func (d *DockerD) blobStore(ctx context.Context, r ref.Ref) (*store, error) {
+	s, err := d.storeSync(ctx, r)
+	if err != nil && errors.Is(err, types.ErrNotFound) {
+		return d.storeGet(r, false)
+	}
+	return s, err
+}
@@ -0,0 +1,294 @@
+// Package dockerd implements the docker-daemon scheme, accessing images in a Docker Engine with the image save and load APIs
+package dockerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/scheme/ocidir"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultHost is used when DOCKER_HOST is not set
+	defaultHost = "unix:///var/run/docker.sock"
+	// storePath is the OCI Layout within each temporary store directory
+	storePath      = "layout"
+	aOCIRefName    = "org.opencontainers.image.ref.name"
+	aCtrdImageName = "io.containerd.image.name"
+)
+
+func init() {
+	scheme.Register("docker-daemon", func(conf scheme.Config) scheme.API {
+		return New(WithLog(conf.Log))
+	})
+}
+
+// DockerD is used for accessing images in a Docker Engine, e.g. "docker-daemon://name:tag"
+//
+// Images are read by saving them from the engine, and written by loading them into the engine.
+// The content is held in a temporary OCI Layout for each image name until the reference is closed.
+type DockerD struct {
+	baseURL *url.URL
+	client  *http.Client
+	hostErr error
+	log     *logrus.Logger
+	tempDir string
+	mu      sync.Mutex
+	stores  map[string]*store
+}
+
+// store is a temporary OCI Layout holding the content saved from, or to be loaded into, the engine for an image name
+type store struct {
+	dir    string
+	oci    *ocidir.OCIDir
+	mu     sync.Mutex      // serializes saves from the engine
+	synced map[string]bool // tags matching the image in the engine
+}
+
+type dockerdConf struct {
+	host    string
+	log     *logrus.Logger
+	tempDir string
+}
+
+// Opts are used for passing options to dockerd
+type Opts func(*dockerdConf)
+
+// New creates a new DockerD with options
+func New(opts ...Opts) *DockerD {
+	conf := dockerdConf{
+		host: os.Getenv("DOCKER_HOST"),
+		log:  &logrus.Logger{Out: io.Discard},
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.host == "" {
+		conf.host = defaultHost
+	}
+	d := &DockerD{
+		log:     conf.log,
+		tempDir: conf.tempDir,
+		stores:  map[string]*store{},
+	}
+	d.baseURL, d.client, d.hostErr = hostClient(conf.host)
+	return d
+}
+
+// WithHost sets the address of the Docker Engine API.
+// Supported values include "unix:///var/run/docker.sock" and "tcp://host:2375".
+// The default is the DOCKER_HOST environment variable, falling back to the local socket.
+func WithHost(host string) Opts {
+	return func(c *dockerdConf) {
+		c.host = host
+	}
+}
+
+// WithLog provides a logrus logger
+// By default logging is disabled
+func WithLog(log *logrus.Logger) Opts {
+	return func(c *dockerdConf) {
+		c.log = log
+	}
+}
+
+// WithTempDir sets the directory used to store images saved from or loaded into the engine
+// The default is the OS temp directory
+func WithTempDir(dir string) Opts {
+	return func(c *dockerdConf) {
+		c.tempDir = dir
+	}
+}
+
+// Close removes the temporary content for the image name
+func (d *DockerD) Close(ctx context.Context, r ref.Ref) error {
+	d.mu.Lock()
+	s, ok := d.stores[r.Path]
+	delete(d.stores, r.Path)
+	d.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// hostClient returns the base URL and http client for a Docker Engine address
+func hostClient(host string) (*url.URL, *http.Client, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse docker host %s: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		dialer := net.Dialer{}
+		t := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &url.URL{Scheme: "http", Host: "docker"}, &http.Client{Transport: t}, nil
+	case "tcp", "http":
+		return &url.URL{Scheme: "http", Host: u.Host}, &http.Client{}, nil
+	case "https":
+		return &url.URL{Scheme: "https", Host: u.Host}, &http.Client{}, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported docker host %s%.0w", host, types.ErrUnsupported)
+	}
+}
+
+// apiError is the body returned by the engine on a failed request
+type apiError struct {
+	Message string `json:"message"`
+}
+
+// do sends a request to the engine, the response body must be closed by the caller
+func (d *DockerD) do(ctx context.Context, method, p string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	if d.hostErr != nil {
+		return nil, d.hostErr
+	}
+	u := *d.baseURL
+	u.Path = p
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	d.log.WithFields(logrus.Fields{
+		"method": method,
+		"url":    u.String(),
+	}).Debug("docker daemon request")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access the docker daemon: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	ae := apiError{}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*64))
+	if json.Unmarshal(b, &ae) != nil || ae.Message == "" {
+		ae.Message = strings.TrimSpace(string(b))
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("docker daemon %s %s: %s%.0w", method, p, ae.Message, types.ErrNotFound)
+	default:
+		return nil, fmt.Errorf("docker daemon %s %s: %s [http %d]%.0w", method, p, ae.Message, resp.StatusCode, types.ErrHTTPStatus)
+	}
+}
+
+// platform returns the platform of the engine, falling back to the local platform
+func (d *DockerD) platform(ctx context.Context) platform.Platform {
+	resp, err := d.do(ctx, http.MethodGet, "/version", nil, nil, "")
+	if err == nil {
+		defer resp.Body.Close()
+		v := struct {
+			Os   string
+			Arch string
+		}{}
+		if json.NewDecoder(resp.Body).Decode(&v) == nil && v.Os != "" && v.Arch != "" {
+			if p, err := platform.Parse(v.Os + "/" + v.Arch); err == nil {
+				return p
+			}
+		}
+	}
+	return platform.Local()
+}
+
+// imageName returns the name used by the engine for a reference
+func imageName(r ref.Ref) string {
+	if r.Tag == "" && r.Digest != "" {
+		return r.Path + "@" + r.Digest
+	}
+	if r.Tag == "" {
+		return r.Path
+	}
+	return r.Path + ":" + r.Tag
+}
+
+// storeRef converts a reference to the temporary OCI Layout
+func storeRef(r ref.Ref) ref.Ref {
+	return ref.Ref{
+		Scheme:    "ocidir",
+		Reference: r.Reference,
+		Path:      storePath,
+		Tag:       r.Tag,
+		Digest:    r.Digest,
+	}
+}
+
+// storeGet returns the temporary store for the image name, creating it when requested
+func (d *DockerD) storeGet(r ref.Ref, create bool) (*store, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.stores[r.Path]; ok {
+		return s, nil
+	}
+	if !create {
+		return nil, fmt.Errorf("image %s has not been loaded from the docker daemon%.0w", r.CommonName(), types.ErrNotFound)
+	}
+	dir, err := os.MkdirTemp(d.tempDir, "regclient-docker-daemon-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	s := &store{
+		dir: dir,
+		oci: ocidir.New(
+			ocidir.WithFS(rwfs.OSNew(dir)),
+			ocidir.WithGC(false),
+			ocidir.WithLog(d.log),
+		),
+		synced: map[string]bool{},
+	}
+	d.stores[r.Path] = s
+	return s, nil
+}
+
+// storeSync saves the image from the engine into the temporary store.
+// A digest reference uses the existing content when the digest was saved with an earlier tag.
+func (d *DockerD) storeSync(ctx context.Context, r ref.Ref) (*store, error) {
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+	s, err := d.storeGet(r, true)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := r.Tag
+	if key == "" {
+		key = r.Digest
+		if _, err := s.oci.ManifestHead(ctx, storeRef(r)); err == nil {
+			return s, nil
+		}
+	}
+	if s.synced[key] {
+		return s, nil
+	}
+	err = d.imageSave(ctx, s, r)
+	if err != nil {
+		return nil, err
+	}
+	s.synced[key] = true
+	return s, nil
+}
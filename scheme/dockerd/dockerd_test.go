@@ -0,0 +1,360 @@
+package dockerd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// testDaemon implements the image APIs of the Docker Engine, storing each image as the tar from a save
+type testDaemon struct {
+	mu     sync.Mutex
+	images map[string][]byte
+	loads  []string
+}
+
+func (td *testDaemon) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	notFound := func(name string) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message":"No such image: ` + name + `"}`))
+	}
+	switch {
+	case req.Method == http.MethodGet && req.URL.Path == "/version":
+		_, _ = w.Write([]byte(`{"Os":"linux","Arch":"amd64"}`))
+	case req.Method == http.MethodGet && req.URL.Path == "/images/json":
+		filters := map[string][]string{}
+		_ = json.Unmarshal([]byte(req.URL.Query().Get("filters")), &filters)
+		list := []map[string][]string{}
+		for name := range td.images {
+			if len(filters["reference"]) == 0 || strings.HasPrefix(name, filters["reference"][0]+":") {
+				list = append(list, map[string][]string{"RepoTags": {name}})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	case req.Method == http.MethodGet && req.URL.Path == "/images/get":
+		name := req.URL.Query().Get("names")
+		b, ok := td.images[name]
+		if !ok {
+			notFound(name)
+			return
+		}
+		_, _ = w.Write(b)
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/images/") && strings.HasSuffix(req.URL.Path, "/json"):
+		name := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/images/"), "/json")
+		if _, ok := td.images[name]; !ok {
+			notFound(name)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	case req.Method == http.MethodDelete && strings.HasPrefix(req.URL.Path, "/images/"):
+		name := strings.TrimPrefix(req.URL.Path, "/images/")
+		if _, ok := td.images[name]; !ok {
+			notFound(name)
+			return
+		}
+		delete(td.images, name)
+		_, _ = w.Write([]byte(`[{"Untagged":"` + name + `"}]`))
+	case req.Method == http.MethodPost && req.URL.Path == "/images/load":
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		files := testTarFiles(b)
+		sml := []saveManifest{}
+		if err := json.Unmarshal(files["manifest.json"], &sml); err != nil || len(sml) != 1 {
+			_, _ = w.Write([]byte(`{"errorDetail":{"message":"invalid manifest.json"},"error":"invalid manifest.json"}`))
+			return
+		}
+		for _, name := range append([]string{sml[0].Config}, sml[0].Layers...) {
+			if _, ok := files[name]; !ok {
+				_, _ = w.Write([]byte(`{"errorDetail":{"message":"missing ` + name + `"},"error":"missing ` + name + `"}`))
+				return
+			}
+		}
+		for _, name := range sml[0].RepoTags {
+			td.images[name] = b
+			td.loads = append(td.loads, name)
+			_, _ = w.Write([]byte(`{"stream":"Loaded image: ` + name + `\n"}`))
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func testTarFiles(b []byte) map[string][]byte {
+	files := map[string][]byte{}
+	tr := tar.NewReader(bytes.NewReader(b))
+	for {
+		th, err := tr.Next()
+		if err != nil {
+			return files
+		}
+		fb, _ := io.ReadAll(tr)
+		files[th.Name] = fb
+	}
+}
+
+// testSave generates the output of a docker save from an engine without an OCI Layout
+func testSave(t *testing.T, name string, conf, layer []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	add := func(th *tar.Header, b []byte) {
+		th.Size = int64(len(b))
+		th.Mode = 0644
+		if err := tw.WriteHeader(th); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	add(&tar.Header{Name: "layer1/layer.tar", Typeflag: tar.TypeReg}, layer)
+	// duplicate layers are saved as a symlink
+	add(&tar.Header{Name: "layer2/layer.tar", Typeflag: tar.TypeSymlink, Linkname: "../layer1/layer.tar"}, nil)
+	add(&tar.Header{Name: "config.json", Typeflag: tar.TypeReg}, conf)
+	smb, _ := json.Marshal([]saveManifest{{
+		Config:   "config.json",
+		RepoTags: []string{name},
+		Layers:   []string{"layer1/layer.tar", "layer2/layer.tar"},
+	}})
+	add(&tar.Header{Name: "manifest.json", Typeflag: tar.TypeReg}, smb)
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDockerD(t *testing.T) {
+	ctx := context.Background()
+	conf := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	layer := []byte("layer content")
+	td := &testDaemon{
+		images: map[string][]byte{},
+	}
+	td.images["test/img:v1"] = testSave(t, "test/img:v1", conf, layer)
+	ts := httptest.NewServer(td)
+	defer ts.Close()
+	tempDir := t.TempDir()
+	d := New(WithHost("tcp://"+ts.Listener.Addr().String()), WithTempDir(tempDir))
+	r1, err := ref.New("docker-daemon://test/img:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	r2, err := ref.New("docker-daemon://test/img:v2")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+
+	var m manifest.Manifest
+	t.Run("Get", func(t *testing.T) {
+		m, err = d.ManifestGet(ctx, r1)
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		if m.GetDescriptor().MediaType != types.MediaTypeOCI1Manifest {
+			t.Errorf("unexpected media type: %s", m.GetDescriptor().MediaType)
+		}
+		mi, ok := m.(manifest.Imager)
+		if !ok {
+			t.Fatalf("manifest is not an image")
+		}
+		cd, err := mi.GetConfig()
+		if err != nil || cd.Digest != digest.FromBytes(conf) || cd.MediaType != types.MediaTypeOCI1ImageConfig {
+			t.Errorf("unexpected config: %v, %v", cd, err)
+		}
+		layers, err := mi.GetLayers()
+		if err != nil || len(layers) != 2 {
+			t.Fatalf("unexpected layers: %v, %v", layers, err)
+		}
+		for _, l := range layers {
+			if l.Digest != digest.FromBytes(layer) || l.MediaType != types.MediaTypeOCI1Layer {
+				t.Errorf("unexpected layer: %v", l)
+			}
+		}
+		br, err := d.BlobGet(ctx, r1, layers[1])
+		if err != nil {
+			t.Fatalf("failed to get blob: %v", err)
+		}
+		b, err := io.ReadAll(br)
+		br.Close()
+		if err != nil || !bytes.Equal(b, layer) {
+			t.Errorf("unexpected blob content: %s, %v", b, err)
+		}
+		mh, err := d.ManifestHead(ctx, r1)
+		if err != nil {
+			t.Fatalf("failed to head manifest: %v", err)
+		}
+		if mh.GetDescriptor().Digest != m.GetDescriptor().Digest {
+			t.Errorf("head digest mismatch, expected %s, received %s", m.GetDescriptor().Digest, mh.GetDescriptor().Digest)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		_, err := d.ManifestHead(ctx, r2)
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Put", func(t *testing.T) {
+		if m == nil {
+			t.Skip("manifest not found")
+		}
+		err := d.ManifestPut(ctx, r2, m)
+		if err != nil {
+			t.Fatalf("failed to put manifest: %v", err)
+		}
+		if len(td.loads) != 1 || td.loads[0] != "test/img:v2" {
+			t.Errorf("unexpected loads: %v", td.loads)
+		}
+		files := testTarFiles(td.images["test/img:v2"])
+		for _, name := range []string{"index.json", "oci-layout", blobName(m.GetDescriptor())} {
+			if _, ok := files[name]; !ok {
+				t.Errorf("load is missing %s", name)
+			}
+		}
+		mh, err := d.ManifestHead(ctx, r2)
+		if err != nil {
+			t.Fatalf("failed to head manifest: %v", err)
+		}
+		if mh.GetDescriptor().Digest != m.GetDescriptor().Digest {
+			t.Errorf("head digest mismatch, expected %s, received %s", m.GetDescriptor().Digest, mh.GetDescriptor().Digest)
+		}
+	})
+	t.Run("Digest", func(t *testing.T) {
+		if m == nil {
+			t.Skip("manifest not found")
+		}
+		rDig := r1
+		rDig.Tag = ""
+		rDig.Digest = m.GetDescriptor().Digest.String()
+		// content saved with a tag is used without another save
+		mh, err := d.ManifestHead(ctx, rDig)
+		if err != nil {
+			t.Fatalf("failed to head manifest: %v", err)
+		}
+		if mh.GetDescriptor().Digest != m.GetDescriptor().Digest {
+			t.Errorf("head digest mismatch, expected %s, received %s", m.GetDescriptor().Digest, mh.GetDescriptor().Digest)
+		}
+		// a new store saves the digest from the engine
+		td.mu.Lock()
+		td.images["test/img@"+rDig.Digest] = td.images["test/img:v1"]
+		td.mu.Unlock()
+		d2 := New(WithHost("tcp://"+ts.Listener.Addr().String()), WithTempDir(t.TempDir()))
+		mg, err := d2.ManifestGet(ctx, rDig)
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		if mg.GetDescriptor().Digest != m.GetDescriptor().Digest {
+			t.Errorf("get digest mismatch, expected %s, received %s", m.GetDescriptor().Digest, mg.GetDescriptor().Digest)
+		}
+		_ = d2.Close(ctx, rDig)
+	})
+	t.Run("Registry port", func(t *testing.T) {
+		td.mu.Lock()
+		td.images["localhost:5000/test/img:v3"] = testSave(t, "localhost:5000/test/img:v3", conf, layer)
+		td.mu.Unlock()
+		rPort, err := ref.New("docker-daemon://localhost:5000/test/img:v3")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		if rPort.Path != "localhost:5000/test/img" || rPort.Tag != "v3" {
+			t.Errorf("unexpected ref: %#v", rPort)
+		}
+		tl, err := d.TagList(ctx, rPort)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		tags, _ := tl.GetTags()
+		if strings.Join(tags, ",") != "v3" {
+			t.Errorf("unexpected tags: %v", tags)
+		}
+		_, err = d.ManifestHead(ctx, rPort)
+		if err != nil {
+			t.Errorf("failed to head manifest: %v", err)
+		}
+		_ = d.Close(ctx, rPort)
+	})
+	t.Run("Tags", func(t *testing.T) {
+		tl, err := d.TagList(ctx, r1)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		tags, _ := tl.GetTags()
+		if strings.Join(tags, ",") != "v1,v2" {
+			t.Errorf("unexpected tags: %v", tags)
+		}
+		err = d.TagDelete(ctx, r2)
+		if err != nil {
+			t.Fatalf("failed to delete tag: %v", err)
+		}
+		tl, err = d.TagList(ctx, r1)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		tags, _ = tl.GetTags()
+		if strings.Join(tags, ",") != "v1" {
+			t.Errorf("unexpected tags after delete: %v", tags)
+		}
+		err = d.TagDelete(ctx, r2)
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("unexpected error deleting a missing tag: %v", err)
+		}
+	})
+	t.Run("Close", func(t *testing.T) {
+		err := d.Close(ctx, r1)
+		if err != nil {
+			t.Fatalf("failed to close: %v", err)
+		}
+		entries, err := os.ReadDir(tempDir)
+		if err != nil || len(entries) != 0 {
+			t.Errorf("temp dir was not cleaned: %v, %v", entries, err)
+		}
+	})
+}
+
+func TestHost(t *testing.T) {
+	tt := []struct {
+		host   string
+		expect string
+		err    error
+	}{
+		{host: "unix:///var/run/docker.sock", expect: "http://docker"},
+		{host: "tcp://127.0.0.1:2375", expect: "http://127.0.0.1:2375"},
+		{host: "https://docker.example.com:2376", expect: "https://docker.example.com:2376"},
+		{host: "npipe:////./pipe/docker_engine", err: types.ErrUnsupported},
+	}
+	for _, tc := range tt {
+		t.Run(tc.host, func(t *testing.T) {
+			u, _, err := hostClient(tc.host)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("unexpected error, expected %v, received %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse host: %v", err)
+			}
+			if u.String() != tc.expect {
+				t.Errorf("unexpected url, expected %s, received %s", tc.expect, u.String())
+			}
+		})
+	}
+}
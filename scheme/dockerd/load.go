@@ -0,0 +1,215 @@
+package dockerd
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+// loadMessage is an entry in the json stream returned by the load API
+type loadMessage struct {
+	Stream      string `json:"stream,omitempty"`
+	Error       string `json:"error,omitempty"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail,omitempty"`
+}
+
+// imageLoad sends an image from the store to the engine.
+// For an index, the image for the platform of the engine is loaded.
+func (d *DockerD) imageLoad(ctx context.Context, s *store, r ref.Ref, m manifest.Manifest) error {
+	if m.IsList() {
+		p := d.platform(ctx)
+		desc, err := manifest.GetPlatformDesc(m, &p)
+		if err != nil {
+			return fmt.Errorf("failed to find platform %s in %s: %w", p.String(), r.CommonName(), err)
+		}
+		rs := storeRef(r)
+		rs.Tag = ""
+		rs.Digest = desc.Digest.String()
+		m, err = s.oci.ManifestGet(ctx, rs)
+		if err != nil {
+			return err
+		}
+	}
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return fmt.Errorf("manifest is not an image: %s%.0w", m.GetDescriptor().MediaType, types.ErrUnsupportedMediaType)
+	}
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		err := d.loadTar(ctx, s, r, m, mi, pw)
+		pw.CloseWithError(err)
+		errCh <- err
+	}()
+	resp, err := d.do(ctx, http.MethodPost, "/images/load", url.Values{"quiet": []string{"1"}}, pr, "application/x-tar")
+	if err != nil {
+		pr.CloseWithError(err)
+		if errTar := <-errCh; errTar != nil && !errors.Is(errTar, io.ErrClosedPipe) {
+			return errTar
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		msg := loadMessage{}
+		err = dec.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse docker daemon load response: %w", err)
+		}
+		if msg.ErrorDetail != nil && msg.ErrorDetail.Message != "" {
+			return fmt.Errorf("docker daemon failed to load %s: %s", r.CommonName(), msg.ErrorDetail.Message)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("docker daemon failed to load %s: %s", r.CommonName(), msg.Error)
+		}
+		if msg.Stream != "" {
+			d.log.WithFields(logrus.Fields{
+				"ref": r.CommonName(),
+				"msg": msg.Stream,
+			}).Debug("docker daemon load")
+		}
+	}
+	return <-errCh
+}
+
+// loadTar writes an image from the store in the format of a docker save.
+// Both the manifest.json and an OCI Layout are included to support engines with either image store.
+func (d *DockerD) loadTar(ctx context.Context, s *store, r ref.Ref, m manifest.Manifest, mi manifest.Imager, w io.Writer) error {
+	cd, err := mi.GetConfig()
+	if err != nil {
+		return err
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return err
+	}
+	mb, err := m.RawBody()
+	if err != nil {
+		return err
+	}
+	md := m.GetDescriptor()
+	tw := tar.NewWriter(w)
+	sm := saveManifest{
+		Config:   blobName(cd),
+		RepoTags: []string{},
+		Layers:   []string{},
+	}
+	mdIndex := types.Descriptor{
+		MediaType: md.MediaType,
+		Digest:    md.Digest,
+		Size:      md.Size,
+	}
+	if r.Tag != "" {
+		sm.RepoTags = append(sm.RepoTags, imageName(r))
+		mdIndex.Annotations = map[string]string{
+			aCtrdImageName: imageName(r),
+			aOCIRefName:    r.Tag,
+		}
+	}
+	err = loadTarFile(tw, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`))
+	if err != nil {
+		return err
+	}
+	err = loadTarFile(tw, blobName(md), mb)
+	if err != nil {
+		return err
+	}
+	blobs := append([]types.Descriptor{cd}, layers...)
+	seen := map[string]bool{}
+	for i, bd := range blobs {
+		if i > 0 {
+			sm.Layers = append(sm.Layers, blobName(bd))
+		}
+		if seen[blobName(bd)] {
+			continue
+		}
+		seen[blobName(bd)] = true
+		err = d.loadTarBlob(ctx, s, r, tw, bd)
+		if err != nil {
+			return err
+		}
+	}
+	ib, err := json.Marshal(v1.Index{
+		Versioned: v1.IndexSchemaVersion,
+		MediaType: types.MediaTypeOCI1ManifestList,
+		Manifests: []types.Descriptor{mdIndex},
+	})
+	if err != nil {
+		return err
+	}
+	err = loadTarFile(tw, "index.json", ib)
+	if err != nil {
+		return err
+	}
+	smb, err := json.Marshal([]saveManifest{sm})
+	if err != nil {
+		return err
+	}
+	err = loadTarFile(tw, "manifest.json", smb)
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// loadTarBlob copies a blob from the store into the tar
+func (d *DockerD) loadTarBlob(ctx context.Context, s *store, r ref.Ref, tw *tar.Writer, desc types.Descriptor) error {
+	rs := storeRef(r)
+	rs.Tag = ""
+	br, err := s.oci.BlobGet(ctx, rs, desc)
+	if err != nil {
+		return fmt.Errorf("failed to get blob %s: %w", desc.Digest.String(), err)
+	}
+	defer br.Close()
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     blobName(desc),
+		Size:     desc.Size,
+		Mode:     0644,
+		ModTime:  time.Unix(0, 0),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, br)
+	return err
+}
+
+func loadTarFile(tw *tar.Writer, name string, b []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(b)),
+		Mode:     0644,
+		ModTime:  time.Unix(0, 0),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
+
+// blobName is the filename of a blob in the tar
+func blobName(desc types.Descriptor) string {
+	return path.Join("blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded())
+}
@@ -0,0 +1,87 @@
+package dockerd
+
+import (
+	"context"
+
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/referrer"
+)
+
+// ManifestDelete is not supported by the docker daemon, see TagDelete
+func (d *DockerD) ManifestDelete(ctx context.Context, r ref.Ref, opts ...scheme.ManifestOpts) error {
+	return types.ErrUnsupported
+}
+
+// ManifestGet retrieves a manifest, saving the image from the docker daemon
+func (d *DockerD) ManifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	s, err := d.storeSync(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.oci.ManifestGet(ctx, storeRef(r))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := m.RawBody()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.New(
+		manifest.WithRef(r),
+		manifest.WithDesc(m.GetDescriptor()),
+		manifest.WithRaw(raw),
+	)
+}
+
+// ManifestHead gets metadata about the manifest, saving the image from the docker daemon
+func (d *DockerD) ManifestHead(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	s, err := d.storeSync(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.oci.ManifestHead(ctx, storeRef(r))
+	if err != nil {
+		return nil, err
+	}
+	return manifest.New(
+		manifest.WithRef(r),
+		manifest.WithDesc(m.GetDescriptor()),
+	)
+}
+
+// ManifestPut stores a manifest, loading the image into the docker daemon when the manifest is not a child of an index
+func (d *DockerD) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest, opts ...scheme.ManifestOpts) error {
+	config := scheme.ManifestConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if !config.Child && r.Digest == "" && r.Tag == "" {
+		r.Tag = "latest"
+	}
+	s, err := d.storeGet(r, true)
+	if err != nil {
+		return err
+	}
+	err = s.oci.ManifestPut(ctx, storeRef(r), m, opts...)
+	if err != nil || config.Child {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = d.imageLoad(ctx, s, r, m)
+	if err != nil {
+		return err
+	}
+	if r.Tag != "" {
+		s.synced[r.Tag] = true
+	}
+	return nil
+}
+
+// ReferrerList is not supported by the docker daemon
+func (d *DockerD) ReferrerList(ctx context.Context, r ref.Ref, opts ...scheme.ReferrerOpts) (referrer.ReferrerList, error) {
+	return referrer.ReferrerList{}, types.ErrUnsupported
+}
@@ -0,0 +1,253 @@
+package dockerd
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+// saveManifest is an entry in the manifest.json of a docker save
+type saveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// saveDir is the extracted output of a docker save
+type saveDir struct {
+	dir   string
+	links map[string]string
+}
+
+// imageSave saves a tagged image from the engine and imports it into the store.
+// The lock on the store must be held.
+func (d *DockerD) imageSave(ctx context.Context, s *store, r ref.Ref) error {
+	name := imageName(r)
+	// inspect the image first since the save API returns a stream
+	resp, err := d.do(ctx, http.MethodGet, "/images/"+name+"/json", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	resp, err = d.do(ctx, http.MethodGet, "/images/get", url.Values{"names": []string{name}}, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dir, err := os.MkdirTemp(s.dir, "save-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	sd, err := saveExtract(resp.Body, dir)
+	if err != nil {
+		return fmt.Errorf("failed to extract image %s from the docker daemon: %w", name, err)
+	}
+	d.log.WithFields(logrus.Fields{
+		"ref": r.CommonName(),
+	}).Debug("saved image from docker daemon")
+	// newer engines include an OCI Layout, preserving the original manifest
+	if _, err := os.Stat(sd.file("index.json")); err == nil {
+		return d.imageSaveOCI(ctx, s, r, sd)
+	}
+	return d.imageSaveDocker(ctx, s, r, sd)
+}
+
+// imageSaveOCI imports the OCI Layout from a save
+func (d *DockerD) imageSaveOCI(ctx context.Context, s *store, r ref.Ref, sd *saveDir) error {
+	index := v1.Index{}
+	err := sd.readJSON("index.json", &index)
+	if err != nil {
+		return err
+	}
+	var desc *types.Descriptor
+	for i, dEntry := range index.Manifests {
+		if len(index.Manifests) == 1 ||
+			(r.Tag == "" && dEntry.Digest.String() == r.Digest) ||
+			(r.Tag != "" && (dEntry.Annotations[aOCIRefName] == r.Tag || strings.HasSuffix(dEntry.Annotations[aCtrdImageName], ":"+r.Tag))) {
+			desc = &index.Manifests[i]
+			break
+		}
+	}
+	if desc == nil {
+		return fmt.Errorf("image %s not found in the docker daemon save%.0w", imageName(r), types.ErrNotFound)
+	}
+	// import every blob, including the manifests
+	blobsDir := filepath.Join(sd.dir, "blobs")
+	err = filepath.WalkDir(blobsDir, func(file string, de fs.DirEntry, err error) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(blobsDir, file)
+		if err != nil {
+			return err
+		}
+		dig, err := digest.Parse(strings.Replace(filepath.ToSlash(rel), "/", ":", 1))
+		if err != nil {
+			return nil
+		}
+		_, err = d.storeBlobPut(ctx, s, r, types.Descriptor{Digest: dig}, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	mb, err := os.ReadFile(sd.file(path.Join("blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded())))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest %s: %w", desc.Digest.String(), err)
+	}
+	m, err := manifest.New(
+		manifest.WithDesc(types.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size}),
+		manifest.WithRaw(mb),
+	)
+	if err != nil {
+		return err
+	}
+	return s.oci.ManifestPut(ctx, storeRef(r), m)
+}
+
+// imageSaveDocker imports the docker manifest.json from a save, generating an OCI manifest
+func (d *DockerD) imageSaveDocker(ctx context.Context, s *store, r ref.Ref, sd *saveDir) error {
+	sml := []saveManifest{}
+	err := sd.readJSON("manifest.json", &sml)
+	if err != nil {
+		return err
+	}
+	var sm *saveManifest
+	for i := range sml {
+		if len(sml) == 1 {
+			sm = &sml[i]
+			break
+		}
+		for _, rt := range sml[i].RepoTags {
+			if rt == imageName(r) {
+				sm = &sml[i]
+			}
+		}
+	}
+	if sm == nil {
+		return fmt.Errorf("image %s not found in the docker daemon save%.0w", imageName(r), types.ErrNotFound)
+	}
+	m := v1.Manifest{
+		Versioned: v1.ManifestSchemaVersion,
+		MediaType: types.MediaTypeOCI1Manifest,
+		Layers:    []types.Descriptor{},
+	}
+	m.Config, err = d.storeBlobPut(ctx, s, r, types.Descriptor{MediaType: types.MediaTypeOCI1ImageConfig}, sd.file(sm.Config))
+	if err != nil {
+		return err
+	}
+	for _, layer := range sm.Layers {
+		// layers are saved uncompressed
+		dl, err := d.storeBlobPut(ctx, s, r, types.Descriptor{MediaType: types.MediaTypeOCI1Layer}, sd.file(layer))
+		if err != nil {
+			return err
+		}
+		m.Layers = append(m.Layers, dl)
+	}
+	mm, err := manifest.New(manifest.WithOrig(m))
+	if err != nil {
+		return err
+	}
+	return s.oci.ManifestPut(ctx, storeRef(r), mm)
+}
+
+// storeBlobPut copies a file from the save into the store
+func (d *DockerD) storeBlobPut(ctx context.Context, s *store, r ref.Ref, desc types.Descriptor, file string) (types.Descriptor, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return desc, err
+	}
+	defer fh.Close()
+	fi, err := fh.Stat()
+	if err != nil {
+		return desc, err
+	}
+	desc.Size = fi.Size()
+	rs := storeRef(r)
+	rs.Tag = ""
+	return s.oci.BlobPut(ctx, rs, desc, fh)
+}
+
+// saveExtract writes the regular files in a tar to a directory, tracking symlinks used for duplicate layers
+func saveExtract(rdr io.Reader, dir string) (*saveDir, error) {
+	sd := &saveDir{
+		dir:   dir,
+		links: map[string]string{},
+	}
+	tr := tar.NewReader(rdr)
+	for {
+		th, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return sd, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(th.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("invalid filename in docker save: %s", th.Name)
+		}
+		switch th.Typeflag {
+		case tar.TypeReg:
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			err = os.MkdirAll(filepath.Dir(file), 0700)
+			if err != nil {
+				return nil, err
+			}
+			fh, err := os.Create(file)
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(fh, tr)
+			fh.Close()
+			if err != nil {
+				return nil, err
+			}
+		case tar.TypeSymlink:
+			sd.links[name] = path.Clean(path.Join(path.Dir(name), th.Linkname))
+		}
+	}
+}
+
+// file returns the path to a file in the save, following symlinks within the save
+func (sd *saveDir) file(name string) string {
+	name = path.Clean(name)
+	for i := 0; i < 16; i++ {
+		link, ok := sd.links[name]
+		if !ok {
+			break
+		}
+		name = link
+	}
+	return filepath.Join(sd.dir, filepath.FromSlash(name))
+}
+
+func (sd *saveDir) readJSON(name string, v interface{}) error {
+	b, err := os.ReadFile(sd.file(name))
+	if err != nil {
+		return fmt.Errorf("failed to read %s from the docker daemon save: %w", name, err)
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s from the docker daemon save: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package dockerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/tag"
+)
+
+// TagDelete removes a tag from the docker daemon, the image is removed with the last tag
+func (d *DockerD) TagDelete(ctx context.Context, r ref.Ref) error {
+	if r.Tag == "" {
+		return types.ErrMissingTag
+	}
+	resp, err := d.do(ctx, http.MethodDelete, "/images/"+imageName(r), nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to delete tag %s: %w", r.CommonName(), err)
+	}
+	resp.Body.Close()
+	d.mu.Lock()
+	s, ok := d.stores[r.Path]
+	d.mu.Unlock()
+	if ok {
+		s.mu.Lock()
+		delete(s.synced, r.Tag)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// TagList returns the tags for the image name in the docker daemon
+func (d *DockerD) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts) (*tag.List, error) {
+	filters, err := json.Marshal(map[string][]string{"reference": {r.Path}})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.do(ctx, http.MethodGet, "/images/json", url.Values{"filters": []string{string(filters)}}, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags for %s: %w", r.CommonName(), err)
+	}
+	defer resp.Body.Close()
+	images := []struct {
+		RepoTags []string
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&images)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image list: %w", err)
+	}
+	tl := []string{}
+	seen := map[string]bool{}
+	for _, image := range images {
+		for _, rt := range image.RepoTags {
+			// parse the name to split the tag from a registry with a port
+			rtRef, err := ref.New(rt)
+			if err != nil || rtRef.Tag == "" || seen[rtRef.Tag] {
+				continue
+			}
+			seen[rtRef.Tag] = true
+			tl = append(tl, rtRef.Tag)
+		}
+	}
+	sort.Strings(tl)
+	raw, err := json.Marshal(tag.DockerList{Name: r.Path, Tags: tl})
+	if err != nil {
+		return nil, err
+	}
+	return tag.New(
+		tag.WithRaw(raw),
+		tag.WithRef(r),
+		tag.WithTags(tl),
+	)
+}
@@ -22,7 +22,19 @@ import (
 
 // BlobDelete removes a blob from the repository
 func (o *OCIDir) BlobDelete(ctx context.Context, r ref.Ref, d types.Descriptor) error {
-	return types.ErrNotImplemented
+	if err := d.Digest.Validate(); err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", d.Digest.String(), err)
+	}
+	file := path.Join(r.Path, "blobs", d.Digest.Algorithm().String(), d.Digest.Encoded())
+	err := o.fs.Remove(file)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", d.Digest.String(), err)
+	}
+	o.log.WithFields(logrus.Fields{
+		"ref":  r.CommonName(),
+		"file": file,
+	}).Debug("deleted blob")
+	return nil
 }
 
 // BlobGet retrieves a blob, returning a reader
@@ -81,11 +93,12 @@ func (o *OCIDir) BlobMount(ctx context.Context, refSrc ref.Ref, refTgt ref.Ref,
 
 // BlobPut sends a blob to the repository, returns the digest and size when successful
 func (o *OCIDir) BlobPut(ctx context.Context, r ref.Ref, d types.Descriptor, rdr io.Reader) (types.Descriptor, error) {
-	err := o.throttleGet(r).Acquire(ctx)
+	t := o.throttleGet(r, false)
+	err := t.Acquire(ctx)
 	if err != nil {
 		return d, err
 	}
-	defer o.throttleGet(r).Release(ctx)
+	defer t.Release(ctx)
 	err = o.initIndex(r, false)
 	if err != nil {
 		return d, err
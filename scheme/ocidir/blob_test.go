@@ -168,5 +168,13 @@ func TestBlob(t *testing.T) {
 	if !bytes.Equal(fBytes, bBytes) {
 		t.Errorf("blob put bytes, expected %s, saw %s", string(bBytes), string(fBytes))
 	}
-
+	// blob delete
+	err = om.BlobDelete(ctx, r, cd)
+	if err != nil {
+		t.Errorf("blob delete: %v", err)
+	}
+	_, err = om.BlobHead(ctx, r, cd)
+	if err == nil {
+		t.Errorf("blob head succeeded after delete")
+	}
 }
@@ -6,6 +6,9 @@ import (
 	"io/fs"
 	"path"
 
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/manifest"
 	"github.com/regclient/regclient/types/ref"
 	"github.com/sirupsen/logrus"
@@ -25,29 +28,53 @@ func (o *OCIDir) Close(ctx context.Context, r ref.Ref) error {
 	}
 
 	// perform GC
+	_, err := o.gcRun(ctx, r, false)
+	return err
+}
+
+// GarbageCollect removes blobs that are not referenced from the index.json.
+// This cleans up content left behind after tags are deleted or overwritten.
+// Use scheme.WithGCDryRun to list the blobs without deleting them.
+func (o *OCIDir) GarbageCollect(ctx context.Context, r ref.Ref, opts ...scheme.GCOpts) ([]digest.Digest, error) {
+	conf := scheme.GCConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if gc, ok := o.modRefs[r.Path]; ok && gc.locks > 0 {
+		return nil, fmt.Errorf("garbage collection of %s is locked by an active write%.0w", r.CommonName(), types.ErrUnavailable)
+	}
+	return o.gcRun(ctx, r, conf.DryRun)
+}
+
+// gcRun removes unreferenced blobs, the lock must be held
+func (o *OCIDir) gcRun(ctx context.Context, r ref.Ref, dryRun bool) ([]digest.Digest, error) {
 	o.log.WithFields(logrus.Fields{
-		"ref": r.CommonName(),
+		"ref":    r.CommonName(),
+		"dryRun": dryRun,
 	}).Debug("running GC")
 	dl := map[string]bool{}
 	// recurse through index, manifests, and blob lists, generating a digest list
 	index, err := o.readIndex(r, true)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	im, err := manifest.New(manifest.WithOrig(index))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	err = o.closeProcManifest(ctx, r, im, &dl)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// go through filesystem digest list, removing entries not seen in recursive pass
+	removed := []digest.Digest{}
 	blobsPath := path.Join(r.Path, "blobs")
 	blobDirs, err := fs.ReadDir(o.fs, blobsPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for _, blobDir := range blobDirs {
 		if !blobDir.IsDir() {
@@ -56,21 +83,28 @@ func (o *OCIDir) Close(ctx context.Context, r ref.Ref) error {
 		}
 		digestFiles, err := fs.ReadDir(o.fs, path.Join(blobsPath, blobDir.Name()))
 		if err != nil {
-			return err
+			return removed, err
 		}
 		for _, digestFile := range digestFiles {
-			digest := fmt.Sprintf("%s:%s", blobDir.Name(), digestFile.Name())
-			if !dl[digest] {
-				o.log.WithFields(logrus.Fields{
-					"digest": digest,
-				}).Debug("ocidir garbage collect")
-				// delete
-				o.fs.Remove(path.Join(blobsPath, blobDir.Name(), digestFile.Name()))
+			dig := fmt.Sprintf("%s:%s", blobDir.Name(), digestFile.Name())
+			if dl[dig] {
+				continue
 			}
+			removed = append(removed, digest.Digest(dig))
+			if dryRun {
+				continue
+			}
+			o.log.WithFields(logrus.Fields{
+				"digest": dig,
+			}).Debug("ocidir garbage collect")
+			// delete
+			o.fs.Remove(path.Join(blobsPath, blobDir.Name(), digestFile.Name()))
 		}
 	}
-	delete(o.modRefs, r.Path)
-	return nil
+	if !dryRun {
+		delete(o.modRefs, r.Path)
+	}
+	return removed, nil
 }
 
 func (o *OCIDir) closeProcManifest(ctx context.Context, r ref.Ref, m manifest.Manifest, dl *map[string]bool) error {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types/ref"
 )
 
@@ -85,3 +86,87 @@ func TestClose(t *testing.T) {
 	}
 
 }
+
+func TestGarbageCollect(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.MkdirAll(fsMem, "testdata/regctl", 0777)
+	if err != nil {
+		t.Errorf("failed to setup memfs dir: %v", err)
+		return
+	}
+	err = rwfs.CopyRecursive(fsOS, "testdata/regctl", fsMem, "testdata/regctl")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	// disable gc on close to leave unreferenced blobs behind
+	oMem := New(WithFS(fsMem), WithGC(false))
+	tRef := "ocidir://testdata/regctl"
+	r, err := ref.New(tRef)
+	if err != nil {
+		t.Errorf("failed to parse ref %s: %v", tRef, err)
+	}
+	rCp := r
+	rCp.Tag = ""
+	rCp.Digest = "sha256:e57d957b974fb4d852aee59b9b2e9dcd7cb0f04622e9356324864a270afd18a0" // armv6
+	err = oMem.ManifestDelete(ctx, rCp)
+	if err != nil {
+		t.Errorf("failed to delete %s: %v", rCp.CommonName(), err)
+	}
+	oMem.Close(ctx, r)
+	dUnref := digest.Digest("sha256:7bb8aa6d91c4638208c4f0824b3482dc443f43fb72cad6b077fda0d1fc50f866") // armv6
+	fileUnref := path.Join("testdata/regctl/blobs", dUnref.Algorithm().String(), dUnref.Encoded())
+	dRef := digest.Digest("sha256:3615d1937a8fe8708e041e94f4abb544196380e12628bacdcde0a7eaf1a693ba") // amd64
+	fileRef := path.Join("testdata/regctl/blobs", dRef.Algorithm().String(), dRef.Encoded())
+	listHas := func(dl []digest.Digest, d digest.Digest) bool {
+		for _, cur := range dl {
+			if cur == d {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("Dry Run", func(t *testing.T) {
+		dl, err := oMem.GarbageCollect(ctx, r, scheme.WithGCDryRun())
+		if err != nil {
+			t.Errorf("failed to gc: %v", err)
+			return
+		}
+		if !listHas(dl, dUnref) || listHas(dl, dRef) {
+			t.Errorf("unexpected gc list: %v", dl)
+		}
+		fh, err := fsMem.Open(fileUnref)
+		if err != nil {
+			t.Errorf("dry run removed blob: %s", fileUnref)
+		} else {
+			fh.Close()
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		dl, err := oMem.GarbageCollect(ctx, r)
+		if err != nil {
+			t.Errorf("failed to gc: %v", err)
+			return
+		}
+		if !listHas(dl, dUnref) || listHas(dl, dRef) {
+			t.Errorf("unexpected gc list: %v", dl)
+		}
+		if _, err := fsMem.Stat(fileUnref); err == nil {
+			t.Errorf("blob was not removed: %s", fileUnref)
+		}
+		if _, err := fsMem.Stat(fileRef); err != nil {
+			t.Errorf("referenced blob was removed: %s", fileRef)
+		}
+		dl, err = oMem.GarbageCollect(ctx, r)
+		if err != nil {
+			t.Errorf("failed to gc: %v", err)
+			return
+		}
+		if len(dl) != 0 {
+			t.Errorf("repeated gc removed blobs: %v", dl)
+		}
+	})
+}
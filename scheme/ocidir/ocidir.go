@@ -13,6 +13,7 @@ import (
 
 	"github.com/regclient/regclient/internal/rwfs"
 	"github.com/regclient/regclient/internal/throttle"
+	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types"
 	v1 "github.com/regclient/regclient/types/oci/v1"
 	"github.com/regclient/regclient/types/ref"
@@ -26,6 +27,12 @@ const (
 	defThrottle     = 3
 )
 
+func init() {
+	scheme.Register("ocidir", func(conf scheme.Config) scheme.API {
+		return New(WithLog(conf.Log), WithFS(conf.FS))
+	})
+}
+
 // OCIDir is used for accessing OCI Image Layouts defined as a directory
 type OCIDir struct {
 	fs          rwfs.RWFS
@@ -131,12 +138,14 @@ func (o *OCIDir) Throttle(r ref.Ref, put bool) []*throttle.Throttle {
 	if !put || o.throttleDef <= 0 {
 		return tList
 	}
-	o.mu.Lock()
-	defer o.mu.Unlock()
-	return []*throttle.Throttle{o.throttleGet(r)}
+	return []*throttle.Throttle{o.throttleGet(r, false)}
 }
 
-func (o *OCIDir) throttleGet(r ref.Ref) *throttle.Throttle {
+func (o *OCIDir) throttleGet(r ref.Ref, locked bool) *throttle.Throttle {
+	if !locked {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+	}
 	if t, ok := o.throttle[r.Path]; ok {
 		return t
 	}
@@ -352,14 +361,30 @@ func indexGet(index v1.Index, r ref.Ref) (types.Descriptor, error) {
 		}
 		// fall back to support full image name in annotation
 		for _, im := range index.Manifests {
-			if name, ok := im.Annotations[aOCIRefName]; ok && strings.HasSuffix(name, ":"+r.Tag) {
-				return im, nil
+			if name, ok := im.Annotations[aOCIRefName]; ok {
+				if repo, tag := refNameParse(name); repo != "" && tag == r.Tag {
+					return im, nil
+				}
 			}
 		}
 	}
 	return types.Descriptor{}, types.ErrNotFound
 }
 
+// refNameParse returns the repository and tag from a ref name annotation.
+// The annotation may be a tag, returned with an empty repository, or a full image name, e.g. "registry.example.com/repo:v1".
+func refNameParse(name string) (string, string) {
+	i := strings.LastIndex(name, ":")
+	if i < 0 || strings.Contains(name[i+1:], "/") {
+		return "", name
+	}
+	rn, err := ref.New(name)
+	if err != nil || rn.Digest != "" {
+		return "", ""
+	}
+	return rn.Registry + "/" + rn.Repository, rn.Tag
+}
+
 func indexSet(index *v1.Index, r ref.Ref, d types.Descriptor) error {
 	if index == nil {
 		return fmt.Errorf("index is nil")
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strings"
 
 	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types"
@@ -29,9 +28,26 @@ func (o *OCIDir) tagDelete(ctx context.Context, r ref.Ref) error {
 	if err != nil {
 		return fmt.Errorf("failed to read index: %w", err)
 	}
+	// entries with the tag are removed, otherwise the full image names for one repository with the tag
+	repo := ""
+	for _, d := range index.Manifests {
+		if t, ok := d.Annotations[aOCIRefName]; ok && t == r.Tag {
+			repo = ""
+			break
+		} else if ok && repo == "" {
+			if tRepo, tTag := refNameParse(t); tRepo != "" && tTag == r.Tag {
+				repo = tRepo
+			}
+		}
+	}
 	changed := false
-	for i, desc := range index.Manifests {
-		if t, ok := desc.Annotations[aOCIRefName]; ok && t == r.Tag {
+	for i := len(index.Manifests) - 1; i >= 0; i-- {
+		t, ok := index.Manifests[i].Annotations[aOCIRefName]
+		if !ok {
+			continue
+		}
+		tRepo, tTag := refNameParse(t)
+		if (repo == "" && t == r.Tag) || (repo != "" && tRepo == repo && tTag == r.Tag) {
 			// remove matching entry from index
 			index.Manifests = append(index.Manifests[:i], index.Manifests[i+1:]...)
 			changed = true
@@ -49,8 +65,14 @@ func (o *OCIDir) tagDelete(ctx context.Context, r ref.Ref) error {
 	return nil
 }
 
-// TagList returns a list of tags from the repository
+// TagList returns a list of tags from the repository.
+// Tags are read from the "org.opencontainers.image.ref.name" annotation of each entry in the index.json,
+// and the limit and last options are applied to the sorted list like a registry.
 func (o *OCIDir) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts) (*tag.List, error) {
+	config := scheme.TagConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
 	// get index
 	index, err := o.readIndex(r, false)
 	if err != nil {
@@ -59,8 +81,9 @@ func (o *OCIDir) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts)
 	tl := []string{}
 	for _, desc := range index.Manifests {
 		if t, ok := desc.Annotations[aOCIRefName]; ok {
-			if i := strings.LastIndex(t, ":"); i >= 0 {
-				t = t[i+1:]
+			_, t = refNameParse(t)
+			if t == "" {
+				continue
 			}
 			found := false
 			for _, cur := range tl {
@@ -75,6 +98,17 @@ func (o *OCIDir) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts)
 		}
 	}
 	sort.Strings(tl)
+	// paginate the tag list
+	if config.Last != "" {
+		i := sort.SearchStrings(tl, config.Last)
+		if i < len(tl) && tl[i] == config.Last {
+			i++
+		}
+		tl = tl[i:]
+	}
+	if config.Limit > 0 && len(tl) > config.Limit {
+		tl = tl[:config.Limit]
+	}
 	ib, err := json.Marshal(index)
 	if err != nil {
 		return nil, err
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/ref"
 )
@@ -48,6 +49,36 @@ func TestTag(t *testing.T) {
 		}
 	})
 
+	t.Run("TagList Pagination", func(t *testing.T) {
+		tl, err := oMem.TagList(ctx, r, scheme.WithTagLimit(2))
+		if err != nil {
+			t.Errorf("failed to retrieve tag list: %v", err)
+			return
+		}
+		tlTags, _ := tl.GetTags()
+		if !cmpSliceString([]string{"broken", "latest"}, tlTags) {
+			t.Errorf("unexpected tag list with limit: %v", tlTags)
+		}
+		tl, err = oMem.TagList(ctx, r, scheme.WithTagLast("latest"), scheme.WithTagLimit(1))
+		if err != nil {
+			t.Errorf("failed to retrieve tag list: %v", err)
+			return
+		}
+		tlTags, _ = tl.GetTags()
+		if !cmpSliceString([]string{"v0.3"}, tlTags) {
+			t.Errorf("unexpected tag list with last: %v", tlTags)
+		}
+		tl, err = oMem.TagList(ctx, r, scheme.WithTagLast("v0.3.10"))
+		if err != nil {
+			t.Errorf("failed to retrieve tag list: %v", err)
+			return
+		}
+		tlTags, _ = tl.GetTags()
+		if len(tlTags) != 0 {
+			t.Errorf("unexpected tag list after the last tag: %v", tlTags)
+		}
+	})
+
 	t.Run("TagDelete", func(t *testing.T) {
 		exTags := []string{"broken", "v0.3"}
 		rCp.Tag = "missing"
@@ -80,3 +111,73 @@ func TestTag(t *testing.T) {
 		}
 	})
 }
+
+func TestTagFullName(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.MkdirAll(fsMem, "testdata/regctl", 0777)
+	if err != nil {
+		t.Errorf("failed to setup memfs dir: %v", err)
+		return
+	}
+	err = rwfs.CopyRecursive(fsOS, "testdata/regctl", fsMem, "testdata/regctl")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	oMem := New(WithFS(fsMem))
+	r, err := ref.New("ocidir://testdata/regctl")
+	if err != nil {
+		t.Errorf("failed to parse ref: %v", err)
+		return
+	}
+	// rewrite the tags using full image names, as some tools do
+	index, err := oMem.readIndex(r, false)
+	if err != nil {
+		t.Errorf("failed to read index: %v", err)
+		return
+	}
+	for i := range index.Manifests {
+		if name, ok := index.Manifests[i].Annotations[aOCIRefName]; ok {
+			index.Manifests[i].Annotations[aOCIRefName] = "example.com/regctl:" + name
+			// a tag with the same name in another repository is not deleted
+			if name == "v0.3" {
+				dOther := index.Manifests[i]
+				dOther.Annotations = map[string]string{aOCIRefName: "example.com/other:v0.3"}
+				index.Manifests = append(index.Manifests, dOther)
+			}
+		}
+	}
+	err = oMem.writeIndex(r, index, false)
+	if err != nil {
+		t.Errorf("failed to write index: %v", err)
+		return
+	}
+	rTag := r
+	rTag.Tag = "v0.3"
+	err = oMem.TagDelete(ctx, rTag)
+	if err != nil {
+		t.Errorf("failed to delete tag %s: %v", rTag.CommonName(), err)
+	}
+	tl, err := oMem.TagList(ctx, r)
+	if err != nil {
+		t.Errorf("failed to retrieve tag list: %v", err)
+		return
+	}
+	tlTags, _ := tl.GetTags()
+	exTags := []string{"broken", "latest", "v0.3", "v0.3.10"}
+	if !cmpSliceString(exTags, tlTags) {
+		t.Errorf("unexpected tag list, expected %v, received %v", exTags, tlTags)
+	}
+	index, err = oMem.readIndex(r, false)
+	if err != nil {
+		t.Errorf("failed to read index: %v", err)
+		return
+	}
+	for _, d := range index.Manifests {
+		if d.Annotations[aOCIRefName] == "example.com/regctl:v0.3" {
+			t.Errorf("tag was not deleted")
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package ocidir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+// quarantineDir holds corrupt blobs moved out of the blob store by a repair
+const quarantineDir = "quarantine"
+
+// Verify recomputes the digest of every blob in the layout and checks the index.json for missing content.
+// This is used to detect corruption of layouts stored on unreliable media.
+// Use scheme.WithVerifyRepair to rename blobs stored under the wrong digest, quarantine other corrupt blobs,
+// and remove index entries for missing manifests.
+func (o *OCIDir) Verify(ctx context.Context, r ref.Ref, opts ...scheme.VerifyOpts) (scheme.VerifyReport, error) {
+	conf := scheme.VerifyConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	report := scheme.VerifyReport{}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if gc, ok := o.modRefs[r.Path]; ok && gc.locks > 0 {
+		return report, fmt.Errorf("verify of %s is locked by an active write%.0w", r.CommonName(), types.ErrUnavailable)
+	}
+	o.log.WithFields(logrus.Fields{
+		"ref":    r.CommonName(),
+		"repair": conf.Repair,
+	}).Debug("running verify")
+
+	// content referenced by the index, used to find blobs stored under the wrong digest
+	referenced, err := o.verifyReferenced(ctx, r)
+	if err != nil {
+		return report, err
+	}
+
+	// hash each blob, tracking the size of the valid content
+	stored := map[digest.Digest]int64{}
+	blobsPath := path.Join(r.Path, "blobs")
+	blobDirs, err := fs.ReadDir(o.fs, blobsPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return report, err
+	}
+	for _, blobDir := range blobDirs {
+		if !blobDir.IsDir() {
+			continue
+		}
+		digestFiles, err := fs.ReadDir(o.fs, path.Join(blobsPath, blobDir.Name()))
+		if err != nil {
+			return report, err
+		}
+		for _, digestFile := range digestFiles {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			dig, err := digest.Parse(blobDir.Name() + ":" + digestFile.Name())
+			if err != nil || !dig.Algorithm().Available() {
+				// skip temp files and unknown algorithms
+				o.log.WithFields(logrus.Fields{
+					"file": path.Join(blobsPath, blobDir.Name(), digestFile.Name()),
+				}).Debug("skipping unknown file in blobs")
+				continue
+			}
+			report.Blobs++
+			file := path.Join(blobsPath, blobDir.Name(), digestFile.Name())
+			computed, size, err := o.verifyHash(file, dig.Algorithm())
+			if err != nil {
+				return report, err
+			}
+			if computed == dig {
+				stored[dig] = size
+				continue
+			}
+			o.log.WithFields(logrus.Fields{
+				"digest":   dig.String(),
+				"computed": computed.String(),
+			}).Warn("blob digest mismatch")
+			report.Corrupt = append(report.Corrupt, dig)
+			if !conf.Repair {
+				continue
+			}
+			err = o.verifyRepairBlob(r, dig, computed, size, referenced, stored, &report)
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+
+	// check each index entry against the stored manifests
+	index, err := o.readIndex(r, true)
+	if err != nil {
+		return report, err
+	}
+	indexChanged := false
+	manifests := index.Manifests[:0]
+	for _, d := range index.Manifests {
+		size, ok := stored[d.Digest]
+		if !ok && conf.Repair {
+			report.IndexRemoved = append(report.IndexRemoved, d)
+			indexChanged = true
+			continue
+		}
+		if ok && d.Size != size {
+			report.IndexFixed = append(report.IndexFixed, d)
+			if conf.Repair {
+				d.Size = size
+				indexChanged = true
+			}
+		}
+		manifests = append(manifests, d)
+	}
+	index.Manifests = manifests
+	if indexChanged {
+		err = o.writeIndex(r, index, true)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// report referenced content that is not stored
+	referenced, err = o.verifyReferenced(ctx, r)
+	if err != nil {
+		return report, err
+	}
+	for dig := range referenced {
+		if _, ok := stored[digest.Digest(dig)]; !ok {
+			report.Missing = append(report.Missing, digest.Digest(dig))
+		}
+	}
+	sort.Slice(report.Missing, func(i, j int) bool {
+		return report.Missing[i] < report.Missing[j]
+	})
+	return report, nil
+}
+
+// verifyReferenced returns the digests referenced by the index and the manifests, the lock must be held
+func (o *OCIDir) verifyReferenced(ctx context.Context, r ref.Ref) (map[string]bool, error) {
+	dl := map[string]bool{}
+	index, err := o.readIndex(r, true)
+	if err != nil {
+		return dl, err
+	}
+	im, err := manifest.New(manifest.WithOrig(index))
+	if err != nil {
+		return dl, err
+	}
+	err = o.closeProcManifest(ctx, r, im, &dl)
+	return dl, err
+}
+
+// verifyHash returns the digest and size of a file
+func (o *OCIDir) verifyHash(file string, alg digest.Algorithm) (digest.Digest, int64, error) {
+	fd, err := o.fs.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer fd.Close()
+	digester := alg.Digester()
+	size, err := io.Copy(digester.Hash(), fd)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return digester.Digest(), size, nil
+}
+
+// verifyRepairBlob renames a blob to the digest of the content when that digest is referenced and not already stored.
+// Other corrupt blobs are moved to the quarantine directory.
+func (o *OCIDir) verifyRepairBlob(r ref.Ref, dig, computed digest.Digest, size int64, referenced map[string]bool, stored map[digest.Digest]int64, report *scheme.VerifyReport) error {
+	file := path.Join(r.Path, "blobs", dig.Algorithm().String(), dig.Encoded())
+	computedFile := path.Join(r.Path, "blobs", computed.Algorithm().String(), computed.Encoded())
+	if _, err := rwfs.Stat(o.fs, computedFile); referenced[computed.String()] && errors.Is(err, fs.ErrNotExist) {
+		err = o.fs.Rename(file, computedFile)
+		if err != nil {
+			return fmt.Errorf("failed to rename %s to %s: %w", file, computedFile, err)
+		}
+		if report.Renamed == nil {
+			report.Renamed = map[digest.Digest]digest.Digest{}
+		}
+		report.Renamed[dig] = computed
+		stored[computed] = size
+		return nil
+	}
+	qDir := path.Join(r.Path, quarantineDir, dig.Algorithm().String())
+	err := rwfs.MkdirAll(o.fs, qDir, 0777)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("failed creating %s: %w", qDir, err)
+	}
+	qFile := path.Join(qDir, dig.Encoded())
+	err = o.fs.Rename(file, qFile)
+	if err != nil {
+		return fmt.Errorf("failed to quarantine %s: %w", file, err)
+	}
+	report.Quarantined = append(report.Quarantined, dig)
+	return nil
+}
@@ -0,0 +1,119 @@
+package ocidir
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.MkdirAll(fsMem, "testdata/regctl", 0777)
+	if err != nil {
+		t.Fatalf("failed to setup memfs dir: %v", err)
+	}
+	err = rwfs.CopyRecursive(fsOS, "testdata/regctl", fsMem, "testdata/regctl")
+	if err != nil {
+		t.Fatalf("failed to setup memfs copy: %v", err)
+	}
+	o := New(WithFS(fsMem), WithGC(false))
+	r, err := ref.New("ocidir://testdata/regctl")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	blobDir := "testdata/regctl/blobs/sha256"
+	dConf := digest.Digest("sha256:f6e2d7fa40092cf3d9817bf6ff54183d68d108a47fdf5a5e476c612626c80e14")
+	dWrong := digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	dIndex := digest.Digest("sha256:3f5754829e9747db418bd1a5a40f418b073ed863cba4d57aaeaefa08118c4743")
+	t.Run("Valid", func(t *testing.T) {
+		report, err := o.Verify(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to verify: %v", err)
+		}
+		if report.Blobs == 0 || len(report.Corrupt) > 0 || len(report.IndexRemoved) > 0 {
+			t.Errorf("unexpected report: %v", report)
+		}
+	})
+	t.Run("Wrong name", func(t *testing.T) {
+		err := fsMem.Rename(path.Join(blobDir, dConf.Encoded()), path.Join(blobDir, dWrong.Encoded()))
+		if err != nil {
+			t.Fatalf("failed to rename blob: %v", err)
+		}
+		report, err := o.Verify(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to verify: %v", err)
+		}
+		if len(report.Corrupt) != 1 || report.Corrupt[0] != dWrong || len(report.Renamed) > 0 {
+			t.Errorf("unexpected corrupt list: %v", report)
+		}
+		if !digestIn(report.Missing, dConf) {
+			t.Errorf("config not reported missing: %v", report.Missing)
+		}
+		report, err = o.Verify(ctx, r, scheme.WithVerifyRepair())
+		if err != nil {
+			t.Fatalf("failed to repair: %v", err)
+		}
+		if report.Renamed[dWrong] != dConf || len(report.Quarantined) > 0 || digestIn(report.Missing, dConf) {
+			t.Errorf("blob was not renamed: %v", report)
+		}
+		if _, err := rwfs.Stat(fsMem, path.Join(blobDir, dConf.Encoded())); err != nil {
+			t.Errorf("renamed blob not found: %v", err)
+		}
+	})
+	t.Run("Quarantine", func(t *testing.T) {
+		err := rwfs.WriteFile(fsMem, path.Join(blobDir, dIndex.Encoded()), []byte("corrupt"), 0644)
+		if err != nil {
+			t.Fatalf("failed to corrupt blob: %v", err)
+		}
+		report, err := o.Verify(ctx, r, scheme.WithVerifyRepair())
+		if err != nil {
+			t.Fatalf("failed to repair: %v", err)
+		}
+		if !digestIn(report.Quarantined, dIndex) || len(report.Renamed) > 0 {
+			t.Errorf("blob was not quarantined: %v", report)
+		}
+		if len(report.IndexRemoved) == 0 {
+			t.Errorf("index entries not removed: %v", report)
+		}
+		for _, d := range report.IndexRemoved {
+			if d.Digest != dIndex {
+				t.Errorf("unexpected index entry removed: %v", d)
+			}
+		}
+		if _, err := rwfs.Stat(fsMem, path.Join("testdata/regctl", quarantineDir, "sha256", dIndex.Encoded())); err != nil {
+			t.Errorf("quarantined blob not found: %v", err)
+		}
+		index, err := o.readIndex(r, false)
+		if err != nil {
+			t.Fatalf("failed to read index: %v", err)
+		}
+		for _, d := range index.Manifests {
+			if d.Digest == dIndex {
+				t.Errorf("index still references %s", dIndex)
+			}
+		}
+		report, err = o.Verify(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to verify: %v", err)
+		}
+		if len(report.Corrupt) > 0 {
+			t.Errorf("corrupt blobs after repair: %v", report.Corrupt)
+		}
+	})
+}
+
+func digestIn(dl []digest.Digest, d digest.Digest) bool {
+	for _, cur := range dl {
+		if cur == d {
+			return true
+		}
+	}
+	return false
+}
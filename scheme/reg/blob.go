@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 
@@ -16,6 +17,7 @@ import (
 	_ "crypto/sha512"
 
 	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/redact"
 	"github.com/regclient/regclient/internal/reghttp"
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/blob"
@@ -25,6 +27,9 @@ import (
 
 // BlobDelete removes a blob from the repository
 func (reg *Reg) BlobDelete(ctx context.Context, r ref.Ref, d types.Descriptor) error {
+	if err := refValidate(r, d.Digest); err != nil {
+		return err
+	}
 	req := &reghttp.Req{
 		Host: r.Registry,
 		APIs: map[string]reghttp.ReqAPI{
@@ -47,6 +52,9 @@ func (reg *Reg) BlobDelete(ctx context.Context, r ref.Ref, d types.Descriptor) e
 
 // BlobGet retrieves a blob from the repository, returning a blob reader
 func (reg *Reg) BlobGet(ctx context.Context, r ref.Ref, d types.Descriptor) (blob.Reader, error) {
+	if err := refValidate(r, d.Digest); err != nil {
+		return nil, err
+	}
 	// build/send request
 	req := &reghttp.Req{
 		Host: r.Registry,
@@ -91,12 +99,21 @@ func (reg *Reg) BlobGet(ctx context.Context, r ref.Ref, d types.Descriptor) (blo
 		return nil, fmt.Errorf("failed to get blob, digest %s, ref %s: %w", d.Digest.String(), r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
 	}
 
+	bd := types.Descriptor{
+		Digest: d.Digest,
+	}
+	if reg.blobSizeCheck && d.Size > 0 {
+		cl, err := strconv.ParseInt(resp.HTTPResponse().Header.Get("Content-Length"), 10, 64)
+		if err == nil && cl >= 0 && cl != d.Size {
+			_ = resp.Close()
+			return nil, fmt.Errorf("blob size mismatch, digest %s, ref %s, expected %d, received %d%.0w", d.Digest.String(), r.CommonName(), d.Size, cl, types.ErrMismatch)
+		}
+		bd.Size = d.Size
+	}
 	b := blob.NewReader(
 		blob.WithRef(r),
 		blob.WithReader(resp),
-		blob.WithDesc(types.Descriptor{
-			Digest: d.Digest,
-		}),
+		blob.WithDesc(bd),
 		blob.WithResp(resp.HTTPResponse()),
 	)
 	return b, nil
@@ -104,6 +121,9 @@ func (reg *Reg) BlobGet(ctx context.Context, r ref.Ref, d types.Descriptor) (blo
 
 // BlobHead is used to verify if a blob exists and is accessible
 func (reg *Reg) BlobHead(ctx context.Context, r ref.Ref, d types.Descriptor) (blob.Reader, error) {
+	if err := refValidate(r, d.Digest); err != nil {
+		return nil, err
+	}
 	// build/send request
 	req := &reghttp.Req{
 		Host: r.Registry,
@@ -161,10 +181,16 @@ func (reg *Reg) BlobHead(ctx context.Context, r ref.Ref, d types.Descriptor) (bl
 
 // BlobMount attempts to perform a server side copy/mount of the blob between repositories
 func (reg *Reg) BlobMount(ctx context.Context, rSrc ref.Ref, rTgt ref.Ref, d types.Descriptor) error {
-	_, uuid, err := reg.blobMount(ctx, rTgt, d, rSrc)
+	if err := refValidate(rTgt, d.Digest); err != nil {
+		return err
+	}
+	if err := refValidate(rSrc); err != nil {
+		return err
+	}
+	putURL, _, err := reg.blobMount(ctx, rTgt, d, rSrc)
 	// if mount fails and returns an upload location, cancel that upload
-	if err != nil {
-		reg.blobUploadCancel(ctx, rTgt, uuid)
+	if err != nil && putURL != nil {
+		_ = reg.blobUploadCancel(ctx, rTgt, putURL)
 	}
 	return err
 }
@@ -173,22 +199,49 @@ func (reg *Reg) BlobMount(ctx context.Context, rSrc ref.Ref, rTgt ref.Ref, d typ
 // This will attempt an anonymous blob mount first which some registries may support.
 // It will then try doing a full put of the blob without chunking (most widely supported).
 // If the full put fails, it will fall back to a chunked upload (useful for flaky networks).
-func (reg *Reg) BlobPut(ctx context.Context, r ref.Ref, d types.Descriptor, rdr io.Reader) (types.Descriptor, error) {
+func (reg *Reg) BlobPut(ctx context.Context, r ref.Ref, d types.Descriptor, rdr io.Reader) (_ types.Descriptor, err error) {
+	if err := refValidate(r, d.Digest); err != nil {
+		return d, err
+	}
 	var putURL *url.URL
-	var err error
 	// defaults for content-type and length
 	if d.Size == 0 {
 		d.Size = -1
 	}
+	// spool streams with an unknown digest or size to a temp file
+	if (d.Digest == "" || d.Size <= 0) && reg.blobSpoolMax > 0 {
+		var cleanup func()
+		d, rdr, cleanup, err = reg.blobSpool(d, rdr)
+		if cleanup != nil {
+			defer cleanup()
+		}
+		if err != nil {
+			return d, err
+		}
+	}
+
+	// track the start of a seekable source to restart the upload
+	rdrStart := int64(-1)
+	if rdrSeek, ok := rdr.(io.Seeker); ok {
+		if pos, err := rdrSeek.Seek(0, io.SeekCurrent); err == nil {
+			rdrStart = pos
+		}
+	}
 
-	// attempt an anonymous blob mount
-	if d.Digest != "" && d.Size > 0 {
+	// attempt an anonymous blob mount, unless the registry has rejected previous attempts
+	if enabled, ok := reg.featureGet(featureBlobMountAnon, r.Registry, ""); d.Digest != "" && d.Size > 0 && (!ok || enabled) {
 		putURL, _, err = reg.blobMount(ctx, r, d, ref.Ref{})
 		if err == nil {
 			return d, nil
 		}
 		if err != types.ErrMountReturnedLocation {
 			putURL = nil
+			// only cache responses showing the registry does not mount, other errors may be temporary
+			var se *types.HTTPStatusError
+			if ctx.Err() == nil && errors.As(err, &se) &&
+				(se.StatusCode == http.StatusAccepted || se.StatusCode == http.StatusNotFound || se.StatusCode == http.StatusMethodNotAllowed) {
+				reg.featureSet(featureBlobMountAnon, r.Registry, "", false)
+			}
 		}
 	}
 	// fallback to requesting upload URL
@@ -198,6 +251,14 @@ func (reg *Reg) BlobPut(ctx context.Context, r ref.Ref, d types.Descriptor, rdr
 			return d, err
 		}
 	}
+	// cancel the upload session on failure to avoid leaving orphaned uploads on the registry,
+	// err is the named return so every failed return below triggers the cancel
+	cancelURL := *putURL
+	defer func() {
+		if err != nil {
+			reg.blobUploadCancelCleanup(ctx, r, &cancelURL)
+		}
+	}()
 
 	// send upload as one-chunk
 	tryPut := bool(d.Digest != "" && d.Size > 0)
@@ -228,10 +289,90 @@ func (reg *Reg) BlobPut(ctx context.Context, r ref.Ref, d types.Descriptor, rdr
 	}
 
 	// send a chunked upload if full upload not possible or too large
-	return reg.blobPutUploadChunked(ctx, r, putURL, rdr)
+	for i := 0; ; i++ {
+		var dPut types.Descriptor
+		dPut, err = reg.blobPutUploadChunked(ctx, r, putURL, rdr)
+		if err == nil || !errors.Is(err, types.ErrRetryNeeded) || i >= blobUploadRestartLimit {
+			return dPut, err
+		}
+		// the state of the session is unknown, cancel it and restart the upload when the source can be rewound
+		rdrSeek, ok := rdr.(io.Seeker)
+		if !ok || rdrStart < 0 {
+			return dPut, err
+		}
+		if _, errS := rdrSeek.Seek(rdrStart, io.SeekStart); errS != nil {
+			return dPut, err
+		}
+		reg.blobUploadCancelCleanup(ctx, r, &cancelURL)
+		reg.log.WithFields(logrus.Fields{
+			"ref": r.CommonName(),
+			"err": err,
+		}).Debug("Restarting blob upload")
+		putURL, err = reg.blobGetUploadURL(ctx, r)
+		if err != nil {
+			return d, err
+		}
+		cancelURL = *putURL
+	}
+}
+
+// blobSpool copies up to blobSpoolMax bytes of the reader to a temp file, computing the digest and size.
+// When the blob exceeds the limit, the returned reader streams the spooled content followed by the remaining reader,
+// and the descriptor is left unchanged.
+func (reg *Reg) blobSpool(d types.Descriptor, rdr io.Reader) (types.Descriptor, io.Reader, func(), error) {
+	fh, err := os.CreateTemp(reg.blobSpoolDir, "regclient-blob-*")
+	if err != nil {
+		return d, rdr, nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	cleanup := func() {
+		_ = fh.Close()
+		_ = os.Remove(fh.Name())
+	}
+	digester := digest.Canonical.Digester()
+	if d.Digest != "" && d.Digest.Algorithm().Available() {
+		digester = d.Digest.Algorithm().Digester()
+	}
+	size, err := io.Copy(io.MultiWriter(fh, digester.Hash()), io.LimitReader(rdr, reg.blobSpoolMax+1))
+	if err != nil {
+		return d, rdr, cleanup, fmt.Errorf("failed to spool blob: %w", err)
+	}
+	_, err = fh.Seek(0, io.SeekStart)
+	if err != nil {
+		return d, rdr, cleanup, fmt.Errorf("failed to seek spool file: %w", err)
+	}
+	if size > reg.blobSpoolMax {
+		reg.log.WithFields(logrus.Fields{
+			"limit": reg.blobSpoolMax,
+		}).Debug("Blob exceeds spool limit, sending stream")
+		return d, io.MultiReader(fh, rdr), cleanup, nil
+	}
+	if d.Digest != "" && d.Digest != digester.Digest() {
+		return d, fh, cleanup, fmt.Errorf("blob digest mismatch, expected %s, received %s%.0w", d.Digest.String(), digester.Digest().String(), types.ErrDigestMismatch)
+	}
+	if d.Size > 0 && d.Size != size {
+		return d, fh, cleanup, fmt.Errorf("blob size mismatch, expected %d, received %d%.0w", d.Size, size, types.ErrMismatch)
+	}
+	d.Digest = digester.Digest()
+	d.Size = size
+	return d, fh, cleanup, nil
 }
 
 func (reg *Reg) blobGetUploadURL(ctx context.Context, r ref.Ref) (*url.URL, error) {
+	// a failed POST is not replayed by reghttp since the registry may have created a session,
+	// a session returned with the failure is canceled before the request is restarted
+	for i := 0; ; i++ {
+		u, err := reg.blobGetUploadURLReq(ctx, r)
+		if err == nil || !errors.Is(err, types.ErrRetryNeeded) || i >= blobUploadRestartLimit {
+			return u, err
+		}
+		reg.log.WithFields(logrus.Fields{
+			"ref": r.CommonName(),
+			"err": err,
+		}).Debug("Restarting blob upload session")
+	}
+}
+
+func (reg *Reg) blobGetUploadURLReq(ctx context.Context, r ref.Ref) (*url.URL, error) {
 	// request an upload location
 	req := &reghttp.Req{
 		Host:      r.Registry,
@@ -246,6 +387,13 @@ func (reg *Reg) blobGetUploadURL(ctx context.Context, r ref.Ref) (*url.URL, erro
 	}
 	resp, err := reg.reghttp.Do(ctx, req)
 	if err != nil {
+		// cancel a session the registry created before the request failed
+		if resp != nil && resp.HTTPResponse() != nil && resp.HTTPResponse().Header.Get("Location") != "" {
+			cancelURL, errP := resp.HTTPResponse().Request.URL.Parse(resp.HTTPResponse().Header.Get("Location"))
+			if errP == nil {
+				reg.blobUploadCancelCleanup(ctx, r, cancelURL)
+			}
+		}
 		return nil, fmt.Errorf("failed to send blob post, ref %s: %w", r.CommonName(), err)
 	}
 	defer resp.Close()
@@ -253,44 +401,21 @@ func (reg *Reg) blobGetUploadURL(ctx context.Context, r ref.Ref) (*url.URL, erro
 		return nil, fmt.Errorf("failed to send blob post, ref %s: %w", r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
 	}
 
-	// if min size header received, check/adjust host settings
-	minSizeStr := resp.HTTPResponse().Header.Get(blobChunkMinHeader)
-	if minSizeStr != "" {
-		minSize, err := strconv.ParseInt(minSizeStr, 10, 64)
-		if err != nil {
-			reg.log.WithFields(logrus.Fields{
-				"size": minSizeStr,
-				"err":  err,
-			}).Warn("Failed to parse chunk size header")
-		} else {
-			host := reg.hostGet(r.Registry)
-			if (host.BlobChunk > 0 && minSize > host.BlobChunk) || (host.BlobChunk <= 0 && minSize > reg.blobChunkSize) {
-				if minSize > reg.blobChunkLimit {
-					host.BlobChunk = reg.blobChunkLimit
-				} else {
-					host.BlobChunk = minSize
-				}
-				reg.log.WithFields(logrus.Fields{
-					"size": host.BlobChunk,
-					"host": host.Name,
-				}).Debug("Registry requested min chunk size")
-			}
-		}
-	}
+	reg.blobChunkMinSet(r, resp.HTTPResponse())
 	// Extract the location into a new putURL based on whether it's relative, fqdn with a scheme, or without a scheme.
 	location := resp.HTTPResponse().Header.Get("Location")
 	if location == "" {
 		return nil, fmt.Errorf("failed to send blob post, ref %s: %w", r.CommonName(), types.ErrMissingLocation)
 	}
 	reg.log.WithFields(logrus.Fields{
-		"location": location,
+		"location": redact.URLString(location),
 	}).Debug("Upload location received")
 	// put url may be relative to the above post URL, so parse in that context
 	postURL := resp.HTTPResponse().Request.URL
 	putURL, err := postURL.Parse(location)
 	if err != nil {
 		reg.log.WithFields(logrus.Fields{
-			"location": location,
+			"location": redact.URLString(location),
 			"err":      err,
 		}).Warn("Location url failed to parse")
 		return nil, fmt.Errorf("blob upload url invalid, ref %s: %w", r.CommonName(), err)
@@ -298,6 +423,32 @@ func (reg *Reg) blobGetUploadURL(ctx context.Context, r ref.Ref) (*url.URL, erro
 	return putURL, nil
 }
 
+// blobChunkMinSet caches the minimum chunk size when the registry includes the OCI-Chunk-Min-Length header
+func (reg *Reg) blobChunkMinSet(r ref.Ref, resp *http.Response) {
+	minSizeStr := resp.Header.Get(blobChunkMinHeader)
+	if minSizeStr == "" {
+		return
+	}
+	minSize, err := strconv.ParseInt(minSizeStr, 10, 64)
+	if err != nil || minSize <= 0 {
+		reg.log.WithFields(logrus.Fields{
+			"size": minSizeStr,
+			"err":  err,
+		}).Warn("Failed to parse chunk size header")
+		return
+	}
+	if minSize > reg.blobChunkLimit {
+		minSize = reg.blobChunkLimit
+	}
+	if cur, ok := reg.featureSizeGet(featureChunkMin, r.Registry); !ok || cur != minSize {
+		reg.log.WithFields(logrus.Fields{
+			"size": minSize,
+			"host": r.Registry,
+		}).Debug("Registry requested min chunk size")
+	}
+	reg.featureSizeSet(featureChunkMin, r.Registry, minSize)
+}
+
 func (reg *Reg) blobMount(ctx context.Context, rTgt ref.Ref, d types.Descriptor, rSrc ref.Ref) (*url.URL, string, error) {
 	// build/send request
 	query := url.Values{}
@@ -327,30 +478,7 @@ func (reg *Reg) blobMount(ctx context.Context, rTgt ref.Ref, d types.Descriptor,
 	}
 	defer resp.Close()
 
-	// if min size header received, check/adjust host settings
-	minSizeStr := resp.HTTPResponse().Header.Get(blobChunkMinHeader)
-	if minSizeStr != "" {
-		minSize, err := strconv.ParseInt(minSizeStr, 10, 64)
-		if err != nil {
-			reg.log.WithFields(logrus.Fields{
-				"size": minSizeStr,
-				"err":  err,
-			}).Warn("Failed to parse chunk size header")
-		} else {
-			host := reg.hostGet(rTgt.Registry)
-			if (host.BlobChunk > 0 && minSize > host.BlobChunk) || (host.BlobChunk <= 0 && minSize > reg.blobChunkSize) {
-				if minSize > reg.blobChunkLimit {
-					host.BlobChunk = reg.blobChunkLimit
-				} else {
-					host.BlobChunk = minSize
-				}
-				reg.log.WithFields(logrus.Fields{
-					"size": host.BlobChunk,
-					"host": host.Name,
-				}).Debug("Registry requested min chunk size")
-			}
-		}
-	}
+	reg.blobChunkMinSet(rTgt, resp.HTTPResponse())
 	// 201 indicates the blob mount succeeded
 	if resp.HTTPResponse().StatusCode == 201 {
 		return nil, "", nil
@@ -365,7 +493,7 @@ func (reg *Reg) blobMount(ctx context.Context, rTgt ref.Ref, d types.Descriptor,
 			reg.log.WithFields(logrus.Fields{
 				"digest":   d,
 				"target":   rTgt.CommonName(),
-				"location": location,
+				"location": redact.URLString(location),
 				"err":      err,
 			}).Warn("Mount location header failed to parse")
 		} else {
@@ -438,14 +566,22 @@ func (reg *Reg) blobPutUploadChunked(ctx context.Context, r ref.Ref, putURL *url
 	if bufSize <= 0 {
 		bufSize = reg.blobChunkSize
 	}
+	if minSize := host.APIProfile().BlobChunkMin; bufSize < minSize {
+		bufSize = minSize
+	}
+	if minSize, ok := reg.featureSizeGet(featureChunkMin, r.Registry); ok && bufSize < minSize {
+		bufSize = minSize
+	}
+	if maxSize, ok := reg.featureSizeGet(featureChunkMax, r.Registry); ok && bufSize > maxSize {
+		bufSize = maxSize
+	}
 	bufBytes := make([]byte, 0, bufSize)
 	bufRdr := bytes.NewReader(bufBytes)
 	bufStart := int64(0)
-	bufChange := false
 
 	// setup buffer and digest pipe
 	digester := digest.Canonical.Digester()
-	digestRdr := io.TeeReader(rdr, digester.Hash())
+	digestRdr := newBlobResumeReader(rdr, digester)
 	finalChunk := false
 	chunkStart := int64(0)
 	chunkSize := 0
@@ -457,19 +593,55 @@ func (reg *Reg) blobPutUploadChunked(ctx context.Context, r ref.Ref, putURL *url
 		}
 		return io.NopCloser(bufRdr), nil
 	}
+	// chunkResize changes the size of the remaining chunks, keeping any buffered content that has not been sent
+	chunkResize := func(size int64) error {
+		if chunkStart >= bufStart && chunkStart <= bufStart+int64(len(bufBytes)) {
+			bufBytes = bufBytes[chunkStart-bufStart:]
+			bufStart = chunkStart
+		}
+		bufSize = size
+		if int64(len(bufBytes)) < bufSize && !finalChunk && chunkStart == bufStart {
+			grown := make([]byte, len(bufBytes), bufSize)
+			copy(grown, bufBytes)
+			n, err := io.ReadFull(digestRdr, grown[len(bufBytes):bufSize])
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				finalChunk = true
+			} else if err != nil {
+				return err
+			}
+			bufBytes = grown[:len(bufBytes)+n]
+		}
+		chunkSize = len(bufBytes)
+		return nil
+	}
 	chunkURL := *putURL
 	retryLimit := 10 // TODO: pull limit from reghttp
 	retryCur := 0
 	var err error
 
 	for !finalChunk || chunkStart < bufStart+int64(len(bufBytes)) {
-		bufChange = false
+		if chunkStart < bufStart {
+			// the registry is missing content that is no longer buffered, seek the source back to resume
+			err = digestRdr.seek(chunkStart)
+			if err != nil {
+				// the upload is restarted from the beginning, by BlobPut when the source can be rewound, otherwise by the caller
+				return types.Descriptor{}, fmt.Errorf("failed to resume blob upload from offset %d, ref %s: %w%.0w", chunkStart, r.CommonName(), err, types.ErrRetryNeeded)
+			}
+			reg.log.WithFields(logrus.Fields{
+				"ref":    r.CommonName(),
+				"offset": chunkStart,
+			}).Debug("Resuming chunked upload from registry offset")
+			bufBytes = make([]byte, 0, bufSize)
+			bufStart = chunkStart
+			finalChunk = false
+		}
 		for chunkStart >= bufStart+int64(len(bufBytes)) && !finalChunk {
 			bufStart += int64(len(bufBytes))
-			// reset length if previous read was short
-			if cap(bufBytes) != len(bufBytes) {
-				bufBytes = bufBytes[:cap(bufBytes)]
-				bufChange = true
+			// reset length if previous read was short or the chunk size changed
+			if int64(cap(bufBytes)) < bufSize {
+				bufBytes = make([]byte, bufSize)
+			} else {
+				bufBytes = bufBytes[:bufSize]
 			}
 			// read a chunk into an input buffer, computing the digest
 			chunkSize, err = io.ReadFull(digestRdr, bufBytes)
@@ -481,7 +653,6 @@ func (reg *Reg) blobPutUploadChunked(ctx context.Context, r ref.Ref, putURL *url
 			// update length on partial read
 			if chunkSize != len(bufBytes) {
 				bufBytes = bufBytes[:chunkSize]
-				bufChange = true
 			}
 		}
 		if chunkStart > bufStart && chunkStart < bufStart+int64(len(bufBytes)) {
@@ -489,16 +660,16 @@ func (reg *Reg) blobPutUploadChunked(ctx context.Context, r ref.Ref, putURL *url
 			bufBytes = bufBytes[chunkStart-bufStart:]
 			bufStart = chunkStart
 			chunkSize = len(bufBytes)
-			bufChange = true
 		}
 		if chunkSize > 0 && chunkStart != bufStart {
 			return types.Descriptor{}, fmt.Errorf("chunkStart (%d) != bufStart (%d)", chunkStart, bufStart)
 		}
-		if bufChange {
-			// need to recreate the reader on a change to the slice length,
-			// old reader is looking at the old slice metadata
-			bufRdr = bytes.NewReader(bufBytes)
+		// a reduced chunk size sends the buffer over multiple requests
+		if int64(chunkSize) > bufSize {
+			chunkSize = int(bufSize)
 		}
+		// recreate the reader for the slice being sent, an old reader is looking at the old slice metadata
+		bufRdr = bytes.NewReader(bufBytes[:chunkSize])
 
 		if chunkSize > 0 {
 			// write chunk
@@ -521,18 +692,49 @@ func (reg *Reg) blobPutUploadChunked(ctx context.Context, r ref.Ref, putURL *url
 				NoMirrors: true,
 			}
 			resp, err := reg.reghttp.Do(ctx, req)
-			if err != nil && !errors.Is(err, types.ErrHTTPStatus) && !errors.Is(err, types.ErrNotFound) {
-				return types.Descriptor{}, fmt.Errorf("failed to send blob (chunk), ref %s: http do: %w", r.CommonName(), err)
+			reqFailed := err != nil && !errors.Is(err, types.ErrHTTPStatus) && !errors.Is(err, types.ErrNotFound)
+			var httpResp *http.Response
+			if !reqFailed {
+				resp.Close()
+				httpResp = resp.HTTPResponse()
 			}
-			resp.Close()
-			httpResp := resp.HTTPResponse()
-			// distribution-spec is 202, AWS ECR returns a 201 and rejects the put
-			if resp.HTTPResponse().StatusCode == 201 {
+			if reqFailed {
+				// the request failed mid-stream, query the registry for the received offset to resume
+				retryCur++
+				statusResp, statusErr := reg.blobUploadStatus(ctx, r, &chunkURL)
+				if retryCur > retryLimit || statusErr != nil {
+					return types.Descriptor{}, fmt.Errorf("failed to send blob (chunk), ref %s: http do: %w", r.CommonName(), err)
+				}
+				reg.log.WithFields(logrus.Fields{
+					"ref":        r.CommonName(),
+					"chunkStart": chunkStart,
+					"chunkSize":  chunkSize,
+					"range":      statusResp.Header.Get("Range"),
+					"err":        err,
+				}).Debug("Chunk upload failed, resuming from registry offset")
+				httpResp = statusResp
+			} else if resp.HTTPResponse().StatusCode == 201 {
+				// distribution-spec is 202, AWS ECR returns a 201 and rejects the put
 				reg.log.WithFields(logrus.Fields{
 					"ref":        r.CommonName(),
 					"chunkStart": chunkStart,
 					"chunkSize":  chunkSize,
 				}).Debug("Early accept of chunk in PATCH before PUT request")
+			} else if size := reg.blobChunkAdjust(r, resp.HTTPResponse(), bufSize, chunkStart, chunkSize); size > 0 {
+				// registry rejected the chunk size, continue with the new size
+				retryCur++
+				if retryCur > retryLimit {
+					return types.Descriptor{}, fmt.Errorf("failed to send blob (chunk), ref %s: http status: %w", r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
+				}
+				err = chunkResize(size)
+				if err != nil {
+					return types.Descriptor{}, fmt.Errorf("failed to resize blob chunk, ref %s: %w", r.CommonName(), err)
+				}
+				if _, errRange := blobUploadCurBytes(httpResp); errRange != nil {
+					// without a range, resend from the same offset
+					continue
+				}
+				// the registry accepted part of the chunk, the next chunk starts at the registry offset
 			} else if resp.HTTPResponse().StatusCode >= 400 && resp.HTTPResponse().StatusCode < 500 &&
 				resp.HTTPResponse().Header.Get("Location") != "" &&
 				resp.HTTPResponse().Header.Get("Range") != "" {
@@ -559,13 +761,13 @@ func (reg *Reg) blobPutUploadChunked(ctx context.Context, r ref.Ref, putURL *url
 			rangeEnd, err := blobUploadCurBytes(httpResp)
 			if err == nil {
 				chunkStart = rangeEnd + 1
-			} else {
+			} else if !reqFailed {
 				chunkStart += int64(chunkSize)
 			}
 			location := httpResp.Header.Get("Location")
 			if location != "" {
 				reg.log.WithFields(logrus.Fields{
-					"location": location,
+					"location": redact.URLString(location),
 				}).Debug("Next chunk upload location received")
 				prevURL := httpResp.Request.URL
 				parseURL, err := prevURL.Parse(location)
@@ -617,20 +819,92 @@ func (reg *Reg) blobPutUploadChunked(ctx context.Context, r ref.Ref, putURL *url
 	return types.Descriptor{Digest: d, Size: chunkStart}, nil
 }
 
-// TODO: just take a putURL rather than the uuid and call a delete on that url
-func (reg *Reg) blobUploadCancel(ctx context.Context, r ref.Ref, uuid string) error {
-	if uuid == "" {
-		return fmt.Errorf("failed to cancel upload %s: uuid undefined", r.CommonName())
+// blobChunkAdjust returns a new chunk size when the registry rejects the size of a chunk, or 0 for other responses.
+// A minimum in the OCI-Chunk-Min-Length header raises the size, and a Range showing the registry accepted part of the chunk lowers it.
+// Other rejections do not change the size.
+// The size is cached for the registry, leaving the configured chunk size unchanged.
+func (reg *Reg) blobChunkAdjust(r ref.Ref, resp *http.Response, cur, chunkStart int64, sent int) int64 {
+	if resp == nil || (resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusRequestedRangeNotSatisfiable) {
+		return 0
+	}
+	size := int64(0)
+	if minSize, err := strconv.ParseInt(resp.Header.Get(blobChunkMinHeader), 10, 64); err == nil && minSize > cur {
+		size = minSize
+		if size > reg.blobChunkLimit {
+			size = reg.blobChunkLimit
+		}
+		if size <= cur {
+			return 0
+		}
+		reg.featureSizeSet(featureChunkMin, r.Registry, size)
+	} else if rangeEnd, err := blobUploadCurBytes(resp); err == nil && rangeEnd+1-chunkStart >= blobChunkMin && rangeEnd+1-chunkStart < int64(sent) {
+		size = rangeEnd + 1 - chunkStart
+		reg.featureSizeSet(featureChunkMax, r.Registry, size)
+	} else {
+		return 0
+	}
+	reg.log.WithFields(logrus.Fields{
+		"ref":    r.CommonName(),
+		"status": resp.StatusCode,
+		"orig":   cur,
+		"size":   size,
+	}).Info("Registry rejected chunk size, adjusting")
+	return size
+}
+
+// BlobUploadCancel cancels an upload session, deleting any content uploaded in that session.
+// The location is either the upload URL returned by the registry, or the upload session UUID.
+func (reg *Reg) BlobUploadCancel(ctx context.Context, r ref.Ref, location string) error {
+	if location == "" {
+		return fmt.Errorf("failed to cancel upload %s: location undefined%.0w", r.CommonName(), types.ErrMissingLocation)
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("failed to cancel upload %s: %w", r.CommonName(), err)
+	}
+	if u.IsAbs() {
+		return reg.blobUploadCancel(ctx, r, u)
+	}
+	// relative locations and a uuid are resolved against the repository
+	api := reghttp.ReqAPI{
+		Method:     "DELETE",
+		Repository: r.Repository,
+		Path:       "blobs/uploads/" + u.Path,
+		Query:      u.Query(),
+	}
+	if prefix := "/v2/" + r.Repository + "/"; strings.HasPrefix(u.Path, prefix) {
+		api.Path = strings.TrimPrefix(u.Path, prefix)
+	}
+	return reg.blobUploadCancelReq(ctx, r, api)
+}
+
+func (reg *Reg) blobUploadCancel(ctx context.Context, r ref.Ref, putURL *url.URL) error {
+	return reg.blobUploadCancelReq(ctx, r, reghttp.ReqAPI{
+		Method:     "DELETE",
+		Repository: r.Repository,
+		DirectURL:  putURL,
+	})
+}
+
+// blobUploadCancelCleanup cancels an upload after a failure, even when the context was canceled
+func (reg *Reg) blobUploadCancelCleanup(ctx context.Context, r ref.Ref, putURL *url.URL) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), blobUploadCancelTimeout)
+	defer cancel()
+	err := reg.blobUploadCancel(ctx, r, putURL)
+	if err != nil {
+		reg.log.WithFields(logrus.Fields{
+			"ref": r.CommonName(),
+			"err": err,
+		}).Debug("Failed to cancel upload")
 	}
+}
+
+func (reg *Reg) blobUploadCancelReq(ctx context.Context, r ref.Ref, api reghttp.ReqAPI) error {
 	req := &reghttp.Req{
 		Host:      r.Registry,
 		NoMirrors: true,
 		APIs: map[string]reghttp.ReqAPI{
-			"": {
-				Method:     "DELETE",
-				Repository: r.Repository,
-				Path:       "blobs/uploads/" + uuid,
-			},
+			"": api,
 		},
 	}
 	resp, err := reg.reghttp.Do(ctx, req)
@@ -638,7 +912,8 @@ func (reg *Reg) blobUploadCancel(ctx context.Context, r ref.Ref, uuid string) er
 		return fmt.Errorf("failed to cancel upload %s: %w", r.CommonName(), err)
 	}
 	defer resp.Close()
-	if resp.HTTPResponse().StatusCode != 202 {
+	// 204 follows distribution-spec, 202 is returned by some registries
+	if resp.HTTPResponse().StatusCode != 202 && resp.HTTPResponse().StatusCode != 204 {
 		return fmt.Errorf("failed to cancel upload %s: %w", r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
 	}
 	return nil
@@ -668,6 +943,60 @@ func (reg *Reg) blobUploadStatus(ctx context.Context, r ref.Ref, putURL *url.URL
 	return resp.HTTPResponse(), nil
 }
 
+// blobResumeReader computes the digest of a blob while allowing a seekable source to be rewound.
+// Content that is read again after a seek is not added to the digest a second time.
+type blobResumeReader struct {
+	rdr      io.Reader
+	digester digest.Digester
+	base     int64 // position of the source when the upload started
+	offset   int64 // offset of the next read
+	hashed   int64 // bytes included in the digest
+}
+
+func newBlobResumeReader(rdr io.Reader, digester digest.Digester) *blobResumeReader {
+	brr := &blobResumeReader{rdr: rdr, digester: digester, base: -1}
+	if rs, ok := rdr.(io.Seeker); ok {
+		if base, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			brr.base = base
+		}
+	}
+	return brr
+}
+
+func (brr *blobResumeReader) Read(p []byte) (int, error) {
+	n, err := brr.rdr.Read(p)
+	if n > 0 {
+		end := brr.offset + int64(n)
+		if end > brr.hashed {
+			start := int64(0)
+			if brr.hashed > brr.offset {
+				start = brr.hashed - brr.offset
+			}
+			_, _ = brr.digester.Hash().Write(p[start:n])
+			brr.hashed = end
+		}
+		brr.offset = end
+	}
+	return n, err
+}
+
+// seek moves the source to an offset relative to the start of the upload
+func (brr *blobResumeReader) seek(offset int64) error {
+	rs, ok := brr.rdr.(io.Seeker)
+	if !ok || brr.base < 0 {
+		return fmt.Errorf("source is not seekable%.0w", types.ErrUnsupported)
+	}
+	if offset > brr.hashed {
+		return fmt.Errorf("offset %d is past the content read, %d%.0w", offset, brr.hashed, types.ErrUnsupported)
+	}
+	_, err := rs.Seek(brr.base+offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	brr.offset = offset
+	return nil
+}
+
 func blobUploadCurBytes(resp *http.Response) (int64, error) {
 	if resp == nil {
 		return 0, fmt.Errorf("missing response")
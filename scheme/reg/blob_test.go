@@ -11,6 +11,8 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -252,6 +254,33 @@ func TestBlobGet(t *testing.T) {
 		}
 	})
 
+	t.Run("Size Check", func(t *testing.T) {
+		regCheck := New(
+			WithConfigHosts(rcHosts),
+			WithLog(log),
+			WithDelay(delayInit, delayMax),
+			WithBlobSizeCheck(),
+		)
+		r, err := ref.New(tsURL.Host + blobRepo)
+		if err != nil {
+			t.Errorf("Failed creating ref: %v", err)
+		}
+		br, err := regCheck.BlobGet(ctx, r, types.Descriptor{Digest: d1, Size: int64(blobLen)})
+		if err != nil {
+			t.Errorf("Failed running BlobGet: %v", err)
+			return
+		}
+		_, err = io.ReadAll(br)
+		br.Close()
+		if err != nil {
+			t.Errorf("Failed reading blob: %v", err)
+		}
+		_, err = regCheck.BlobGet(ctx, r, types.Descriptor{Digest: d1, Size: int64(blobLen - 1)})
+		if err == nil || !errors.Is(err, types.ErrMismatch) {
+			t.Errorf("BlobGet did not fail on size mismatch: %v", err)
+		}
+	})
+
 	t.Run("Head", func(t *testing.T) {
 		r, err := ref.New(tsURL.Host + blobRepo)
 		if err != nil {
@@ -993,6 +1022,9 @@ func TestBlobPut(t *testing.T) {
 			t.Errorf("Failed running BlobPut: %v", err)
 			return
 		}
+		if minSize, ok := reg.featureSizeGet(featureChunkMin, r.Registry); !ok || minSize != int64(blobLen4/2) {
+			t.Errorf("unexpected cached chunk min, expected %d, received %d", blobLen4/2, minSize)
+		}
 		if dp.Digest.String() != d4.String() {
 			t.Errorf("Digest mismatch, expected %s, received %s", d4.String(), dp.Digest.String())
 		}
@@ -1001,5 +1033,682 @@ func TestBlobPut(t *testing.T) {
 		}
 	})
 
+	t.Run("Spool", func(t *testing.T) {
+		regSpool := New(
+			WithConfigHosts(rcHosts),
+			WithLog(log),
+			WithDelay(delayInit, delayMax),
+			WithBlobSpool(t.TempDir(), int64(blobLen*2)),
+		)
+		r, err := ref.New(tsURL.Host + blobRepo)
+		if err != nil {
+			t.Errorf("Failed creating ref: %v", err)
+		}
+		// hide the seeker and descriptor to simulate a stream
+		br := io.MultiReader(bytes.NewReader(blob1))
+		dp, err := regSpool.BlobPut(ctx, r, types.Descriptor{}, br)
+		if err != nil {
+			t.Errorf("Failed running BlobPut: %v", err)
+			return
+		}
+		if dp.Digest.String() != d1.String() {
+			t.Errorf("Digest mismatch, expected %s, received %s", d1.String(), dp.Digest.String())
+		}
+		if dp.Size != int64(len(blob1)) {
+			t.Errorf("Content length mismatch, expected %d, received %d", len(blob1), dp.Size)
+		}
+	})
+
 	// TODO: test failed mount (blobGetUploadURL)
 }
+
+func TestBlobPutResume(t *testing.T) {
+	ctx := context.Background()
+	blobRepo := "/proj/repo"
+	blobChunk := 512
+	d1, blob1 := reqresp.NewRandomBlob(blobChunk*4, time.Now().UTC().Unix())
+	uploadPath := "/v2" + blobRepo + "/blobs/uploads/session"
+	// the registry drops content from the end of the second chunk when the third chunk is received
+	dropBytes := 100
+	var mu sync.Mutex
+	received := []byte{}
+	dropped := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v2"+blobRepo+"/blobs/uploads/":
+			received = []byte{}
+			w.Header().Set("Location", uploadPath)
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPatch && r.URL.Path == uploadPath:
+			body, _ := io.ReadAll(r.Body)
+			var start, end int
+			_, err := fmt.Sscanf(r.Header.Get("Content-Range"), "%d-%d", &start, &end)
+			if err == nil && start == blobChunk*2 && !dropped {
+				dropped = true
+				received = received[:len(received)-dropBytes]
+			}
+			if err != nil || start != len(received) {
+				w.Header().Set("Location", uploadPath)
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			received = append(received, body...)
+			w.Header().Set("Location", uploadPath)
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && r.URL.Path == uploadPath:
+			if r.URL.Query().Get("digest") != digest.FromBytes(received).String() || !bytes.Equal(received, blob1) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", d1.String())
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete && r.URL.Path == uploadPath:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	delayInit, _ := time.ParseDuration("0.05s")
+	delayMax, _ := time.ParseDuration("0.10s")
+	reg := New(
+		WithConfigHosts([]*config.Host{
+			{
+				Name:      tsHost,
+				Hostname:  tsHost,
+				TLS:       config.TLSDisabled,
+				BlobChunk: int64(blobChunk),
+				BlobMax:   int64(blobChunk),
+			},
+		}),
+		WithLog(&logrus.Logger{Out: io.Discard}),
+		WithDelay(delayInit, delayMax),
+	)
+	r, err := ref.New(tsHost + blobRepo)
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+
+	t.Run("Seekable", func(t *testing.T) {
+		// start the source at an offset to verify the resume is relative to the initial position
+		prefix := []byte("prefix")
+		br := bytes.NewReader(append(append([]byte{}, prefix...), blob1...))
+		_, err := br.Seek(int64(len(prefix)), io.SeekStart)
+		if err != nil {
+			t.Fatalf("failed to seek: %v", err)
+		}
+		dp, err := reg.BlobPut(ctx, r, types.Descriptor{Digest: d1, Size: int64(len(blob1))}, br)
+		if err != nil {
+			t.Errorf("failed running BlobPut: %v", err)
+			return
+		}
+		if dp.Digest != d1 || dp.Size != int64(len(blob1)) {
+			t.Errorf("unexpected descriptor, expected %s/%d, received %s/%d", d1, len(blob1), dp.Digest, dp.Size)
+		}
+		if !dropped {
+			t.Errorf("registry did not drop content")
+		}
+	})
+	t.Run("Rewind", func(t *testing.T) {
+		// a reader that only seeks to the start restarts the upload from the beginning
+		mu.Lock()
+		dropped = false
+		mu.Unlock()
+		br := &rewindReader{Reader: bytes.NewReader(blob1)}
+		dp, err := reg.BlobPut(ctx, r, types.Descriptor{Digest: d1, Size: int64(len(blob1))}, br)
+		if err != nil {
+			t.Errorf("failed running BlobPut: %v", err)
+			return
+		}
+		if dp.Digest != d1 || dp.Size != int64(len(blob1)) {
+			t.Errorf("unexpected descriptor, expected %s/%d, received %s/%d", d1, len(blob1), dp.Digest, dp.Size)
+		}
+		if !dropped || br.rewinds != 1 {
+			t.Errorf("upload was not restarted, dropped %t, rewinds %d", dropped, br.rewinds)
+		}
+	})
+	t.Run("Stream", func(t *testing.T) {
+		// a reader without a seek cannot resume from before the buffered chunk, the caller must restart the upload
+		mu.Lock()
+		dropped = false
+		mu.Unlock()
+		br := io.MultiReader(bytes.NewReader(blob1))
+		_, err := reg.BlobPut(ctx, r, types.Descriptor{Digest: d1, Size: int64(len(blob1))}, br)
+		if err == nil || !errors.Is(err, types.ErrUnsupported) || !errors.Is(err, types.ErrRetryNeeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+// rewindReader only seeks to the start, similar to a blob reader
+type rewindReader struct {
+	*bytes.Reader
+	rewinds int
+}
+
+func (rr *rewindReader) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekCurrent {
+		return rr.Reader.Seek(offset, whence)
+	}
+	if offset != 0 || whence != io.SeekStart {
+		return 0, fmt.Errorf("unable to seek to arbitrary position")
+	}
+	rr.rewinds++
+	return rr.Reader.Seek(offset, whence)
+}
+
+func TestBlobPutChunkAdjust(t *testing.T) {
+	ctx := context.Background()
+	blobRepo := "/proj/repo"
+	uploadPath := "/v2" + blobRepo + "/blobs/uploads/session"
+	chunkMin := 1024
+	chunkMax := 100 * 1024
+	var mu sync.Mutex
+	received := []byte{}
+	rejected := 0
+	var expect []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v2"+blobRepo+"/blobs/uploads/":
+			received = []byte{}
+			w.Header().Set("Location", uploadPath)
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPatch && r.URL.Path == uploadPath:
+			body, _ := io.ReadAll(r.Body)
+			final := len(received)+len(body) == len(expect)
+			if len(body) > chunkMax {
+				// accept part of a large chunk, returning the range received
+				rejected++
+				received = append(received, body[:chunkMax]...)
+				w.Header().Set("Location", uploadPath)
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			if len(body) < chunkMin && !final {
+				// reject small chunks with the minimum size
+				rejected++
+				w.Header().Set(blobChunkMinHeader, fmt.Sprintf("%d", chunkMin))
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			received = append(received, body...)
+			w.Header().Set("Location", uploadPath)
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && r.URL.Path == uploadPath:
+			if r.URL.Query().Get("digest") != digest.FromBytes(received).String() || !bytes.Equal(received, expect) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(received).String())
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete && r.URL.Path == uploadPath:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	delayInit, _ := time.ParseDuration("0.05s")
+	delayMax, _ := time.ParseDuration("0.10s")
+	reg := New(
+		WithConfigHosts([]*config.Host{
+			{
+				Name:      "small." + tsHost,
+				Hostname:  tsHost,
+				TLS:       config.TLSDisabled,
+				BlobChunk: 512,
+				BlobMax:   512,
+			},
+			{
+				Name:      "large." + tsHost,
+				Hostname:  tsHost,
+				TLS:       config.TLSDisabled,
+				BlobChunk: 256 * 1024,
+				BlobMax:   512,
+			},
+		}),
+		WithLog(&logrus.Logger{Out: io.Discard}),
+		WithDelay(delayInit, delayMax),
+	)
+	tests := []struct {
+		name      string
+		host      string
+		blobLen   int
+		feature   string
+		chunkSize int64
+		chunkConf int64
+	}{
+		{
+			name:      "Min header",
+			host:      "small." + tsHost,
+			blobLen:   chunkMin*2 + 512,
+			feature:   featureChunkMin,
+			chunkSize: int64(chunkMin),
+			chunkConf: 512,
+		},
+		{
+			name:      "Partial range",
+			host:      "large." + tsHost,
+			blobLen:   300 * 1024,
+			feature:   featureChunkMax,
+			chunkSize: 100 * 1024,
+			chunkConf: 256 * 1024,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, blob := reqresp.NewRandomBlob(tt.blobLen, int64(i))
+			mu.Lock()
+			expect = blob
+			rejected = 0
+			mu.Unlock()
+			r, err := ref.New(tt.host + blobRepo)
+			if err != nil {
+				t.Fatalf("failed creating ref: %v", err)
+			}
+			// hide the seeker, the resize must not depend on rereading the source
+			br := io.MultiReader(bytes.NewReader(blob))
+			dp, err := reg.BlobPut(ctx, r, types.Descriptor{Digest: d, Size: int64(len(blob))}, br)
+			if err != nil {
+				t.Fatalf("failed running BlobPut: %v", err)
+			}
+			if dp.Digest != d || dp.Size != int64(len(blob)) {
+				t.Errorf("unexpected descriptor, expected %s/%d, received %s/%d", d, len(blob), dp.Digest, dp.Size)
+			}
+			mu.Lock()
+			if rejected == 0 {
+				t.Errorf("registry did not reject a chunk")
+			}
+			mu.Unlock()
+			if size, ok := reg.featureSizeGet(tt.feature, r.Registry); !ok || size != tt.chunkSize {
+				t.Errorf("unexpected chunk size, expected %d, received %d", tt.chunkSize, size)
+			}
+			// the configured size is not modified
+			if host := reg.hostGet(r.Registry); host.BlobChunk != tt.chunkConf {
+				t.Errorf("host chunk size changed, expected %d, received %d", tt.chunkConf, host.BlobChunk)
+			}
+			// the next upload uses the cached size without a rejection
+			mu.Lock()
+			rejected = 0
+			mu.Unlock()
+			_, err = reg.BlobPut(ctx, r, types.Descriptor{Digest: d, Size: int64(len(blob))}, io.MultiReader(bytes.NewReader(blob)))
+			if err != nil {
+				t.Fatalf("failed running second BlobPut: %v", err)
+			}
+			mu.Lock()
+			if rejected != 0 {
+				t.Errorf("registry rejected a chunk with the cached size")
+			}
+			mu.Unlock()
+		})
+	}
+}
+
+func TestBlobPutRestart(t *testing.T) {
+	ctx := context.Background()
+	blobRepo := "/proj/repo"
+	d1, blob1 := reqresp.NewRandomBlob(1024, time.Now().UTC().Unix())
+	var mu sync.Mutex
+	posts := 0
+	canceled := []string{}
+	received := map[string][]byte{}
+	// drop the connection without a response, the request may have been processed
+	drop := func(w http.ResponseWriter) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		conn, _, err := hj.Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		session := strings.TrimPrefix(r.URL.Path, "/v2"+blobRepo+"/blobs/uploads/")
+		switch {
+		case r.Method == http.MethodPost && session == "":
+			posts++
+			if posts == 1 {
+				drop(w)
+				return
+			}
+			session = fmt.Sprintf("session-%d", posts)
+			received[session] = []byte{}
+			w.Header().Set("Location", "/v2"+blobRepo+"/blobs/uploads/"+session)
+			if posts == 2 {
+				// the session is created before the request fails
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPatch && received[session] != nil:
+			if session == "session-3" {
+				// lose the session along with the response
+				delete(received, session)
+				drop(w)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			received[session] = append(received[session], body...)
+			w.Header().Set("Location", r.URL.Path)
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received[session])-1))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && received[session] != nil:
+			if r.URL.Query().Get("digest") != d1.String() || !bytes.Equal(received[session], blob1) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", d1.String())
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete:
+			canceled = append(canceled, session)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	delayInit, _ := time.ParseDuration("0.01s")
+	delayMax, _ := time.ParseDuration("0.05s")
+	reg := New(
+		WithConfigHosts([]*config.Host{
+			{
+				Name:      tsHost,
+				Hostname:  tsHost,
+				TLS:       config.TLSDisabled,
+				BlobChunk: 2048,
+				BlobMax:   512,
+			},
+		}),
+		WithLog(&logrus.Logger{Out: io.Discard}),
+		WithDelay(delayInit, delayMax),
+	)
+	r, err := ref.New(tsHost + blobRepo)
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+	dp, err := reg.BlobPut(ctx, r, types.Descriptor{Digest: d1, Size: int64(len(blob1))}, bytes.NewReader(blob1))
+	if err != nil {
+		t.Fatalf("failed running BlobPut: %v", err)
+	}
+	if dp.Digest != d1 || dp.Size != int64(len(blob1)) {
+		t.Errorf("unexpected descriptor, expected %s/%d, received %s/%d", d1, len(blob1), dp.Digest, dp.Size)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// the first post is restarted, the second post fails and is canceled,
+	// the third session is lost and canceled, the fourth completes
+	if posts != 4 {
+		t.Errorf("unexpected number of sessions, expected 4, received %d", posts)
+	}
+	if len(canceled) != 2 || canceled[0] != "session-2" || canceled[1] != "session-3" {
+		t.Errorf("unexpected canceled sessions: %v", canceled)
+	}
+}
+
+func TestBlobUploadCancel(t *testing.T) {
+	blobRepo := "/proj/cancel"
+	ctx := context.Background()
+	d1, blob1 := reqresp.NewRandomBlob(1024, 42)
+	uuid1 := uuid.New()
+	uuid2 := uuid.New()
+	deleteCount := map[string]int{}
+	var deleteMu sync.Mutex
+	rrs := []reqresp.ReqResp{
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "POST for d1",
+				Method: "POST",
+				Path:   "/v2" + blobRepo + "/blobs/uploads/",
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusAccepted,
+				Headers: http.Header{
+					"Content-Length": {"0"},
+					"Location":       {"/v2" + blobRepo + "/blobs/uploads/" + uuid1.String()},
+				},
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "PUT for d1",
+				Method: "PUT",
+				Path:   "/v2" + blobRepo + "/blobs/uploads/" + uuid1.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusBadRequest,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "PATCH for d1",
+				Method: "PATCH",
+				Path:   "/v2" + blobRepo + "/blobs/uploads/" + uuid1.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusBadRequest,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "GET status for d1",
+				Method: "GET",
+				Path:   "/v2" + blobRepo + "/blobs/uploads/" + uuid1.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusNotFound,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "DELETE upload 1",
+				Method: "DELETE",
+				Path:   "/v2" + blobRepo + "/blobs/uploads/" + uuid1.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusNoContent,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "DELETE upload 2",
+				Method: "DELETE",
+				Path:   "/v2" + blobRepo + "/blobs/uploads/" + uuid2.String(),
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusAccepted,
+			},
+		},
+	}
+	rrs = append(rrs, reqresp.BaseEntries...)
+	rrHandler := reqresp.NewHandler(t, rrs)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodDelete {
+			deleteMu.Lock()
+			deleteCount[req.URL.Path]++
+			deleteMu.Unlock()
+		}
+		rrHandler.ServeHTTP(w, req)
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	rcHosts := []*config.Host{
+		{
+			Name:     tsHost,
+			Hostname: tsHost,
+			TLS:      config.TLSDisabled,
+			BlobMax:  int64(-1),
+		},
+	}
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	delayInit, _ := time.ParseDuration("0.05s")
+	delayMax, _ := time.ParseDuration("0.10s")
+	reg := New(
+		WithConfigHosts(rcHosts),
+		WithLog(log),
+		WithDelay(delayInit, delayMax),
+		WithRetryLimit(1),
+	)
+	r, err := ref.New(tsHost + blobRepo)
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+	path1 := "/v2" + blobRepo + "/blobs/uploads/" + uuid1.String()
+	path2 := "/v2" + blobRepo + "/blobs/uploads/" + uuid2.String()
+
+	t.Run("Failed Put", func(t *testing.T) {
+		_, err := reg.BlobPut(ctx, r, types.Descriptor{Digest: d1, Size: int64(len(blob1))}, bytes.NewReader(blob1))
+		if err == nil {
+			t.Errorf("put did not fail")
+		}
+		deleteMu.Lock()
+		defer deleteMu.Unlock()
+		if deleteCount[path1] != 1 {
+			t.Errorf("upload was not canceled, delete count %d", deleteCount[path1])
+		}
+	})
+	t.Run("Cancel UUID", func(t *testing.T) {
+		err := reg.BlobUploadCancel(ctx, r, uuid2.String())
+		if err != nil {
+			t.Errorf("failed to cancel: %v", err)
+		}
+		deleteMu.Lock()
+		defer deleteMu.Unlock()
+		if deleteCount[path2] != 1 {
+			t.Errorf("upload was not canceled, delete count %d", deleteCount[path2])
+		}
+	})
+	t.Run("Cancel Path", func(t *testing.T) {
+		err := reg.BlobUploadCancel(ctx, r, path2)
+		if err != nil {
+			t.Errorf("failed to cancel: %v", err)
+		}
+		deleteMu.Lock()
+		defer deleteMu.Unlock()
+		if deleteCount[path2] != 2 {
+			t.Errorf("upload was not canceled, delete count %d", deleteCount[path2])
+		}
+	})
+	t.Run("Cancel URL", func(t *testing.T) {
+		err := reg.BlobUploadCancel(ctx, r, ts.URL+path2)
+		if err != nil {
+			t.Errorf("failed to cancel: %v", err)
+		}
+		deleteMu.Lock()
+		defer deleteMu.Unlock()
+		if deleteCount[path2] != 3 {
+			t.Errorf("upload was not canceled, delete count %d", deleteCount[path2])
+		}
+	})
+	t.Run("Missing Location", func(t *testing.T) {
+		err := reg.BlobUploadCancel(ctx, r, "")
+		if err == nil || !errors.Is(err, types.ErrMissingLocation) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestBlobPutMountAnon(t *testing.T) {
+	blobRepo := "/proj/mount"
+	ctx := context.Background()
+	d1, blob1 := reqresp.NewRandomBlob(1024, 43)
+	var mu sync.Mutex
+	mountStatus := http.StatusInternalServerError
+	mountCount := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Query().Get("mount") != "":
+			mu.Lock()
+			mountCount++
+			status := mountStatus
+			mu.Unlock()
+			w.WriteHeader(status)
+		case req.Method == http.MethodPost:
+			w.Header().Set("Location", "/v2"+blobRepo+"/blobs/uploads/"+uuid.New().String())
+			w.WriteHeader(http.StatusAccepted)
+		case req.Method == http.MethodPut:
+			w.Header().Set("Docker-Content-Digest", d1.String())
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	rcHosts := []*config.Host{
+		{
+			Name:     tsHost,
+			Hostname: tsHost,
+			TLS:      config.TLSDisabled,
+		},
+	}
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	delayInit, _ := time.ParseDuration("0.05s")
+	delayMax, _ := time.ParseDuration("0.10s")
+	reg := New(
+		WithConfigHosts(rcHosts),
+		WithLog(log),
+		WithDelay(delayInit, delayMax),
+		WithRetryLimit(1),
+	)
+	r, err := ref.New(tsHost + blobRepo)
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+	tt := []struct {
+		name      string
+		status    int
+		wantCount int
+	}{
+		{name: "Server Error", status: http.StatusInternalServerError, wantCount: 1},
+		{name: "Unauthorized", status: http.StatusUnauthorized, wantCount: 1},
+		{name: "Method Not Allowed", status: http.StatusMethodNotAllowed, wantCount: 1},
+		{name: "Cached Unsupported", status: http.StatusMethodNotAllowed, wantCount: 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			mountStatus = tc.status
+			mountCount = 0
+			mu.Unlock()
+			_, err := reg.BlobPut(ctx, r, types.Descriptor{Digest: d1, Size: int64(len(blob1))}, bytes.NewReader(blob1))
+			if err != nil {
+				t.Fatalf("failed to put blob: %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if mountCount < tc.wantCount || (tc.wantCount == 0 && mountCount > 0) {
+				t.Errorf("unexpected mount attempts, expected %d, received %d", tc.wantCount, mountCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package reg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/regclient/regclient/internal/reghttp"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+// extensionDiscoverPath is the distribution-spec extensions discovery endpoint
+const extensionDiscoverPath = "_oci/ext/discover"
+
+// Extension is a distribution-spec extension advertised by a registry
+type Extension struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Endpoints   []string `json:"endpoints,omitempty"`
+}
+
+// ExtensionList is the response from the extensions discovery endpoint
+type ExtensionList struct {
+	Extensions []Extension `json:"extensions"`
+}
+
+// Supported returns true if the list includes the named extension
+func (el ExtensionList) Supported(name string) bool {
+	for _, e := range el.Extensions {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalPretty outputs the extensions in a human readable format
+func (el ExtensionList) MarshalPretty() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Name\tEndpoints\tDescription\n")
+	for _, e := range el.Extensions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Name, strings.Join(e.Endpoints, ", "), e.Description)
+	}
+	err := tw.Flush()
+	return buf.Bytes(), err
+}
+
+// ExtensionList returns the extensions advertised by a registry using the distribution-spec discovery endpoint.
+// When the reference does not include a repository, the extensions of the registry are returned.
+// Registries without the discovery endpoint return an empty list.
+func (reg *Reg) ExtensionList(ctx context.Context, r ref.Ref) (ExtensionList, error) {
+	el := ExtensionList{Extensions: []Extension{}}
+	if r.Repository != "" {
+		if err := refValidate(r); err != nil {
+			return el, err
+		}
+	}
+	req := &reghttp.Req{
+		Host:      r.Registry,
+		NoMirrors: true,
+		APIs: map[string]reghttp.ReqAPI{
+			"": {
+				Method:     "GET",
+				Repository: r.Repository,
+				Path:       extensionDiscoverPath,
+				NoPrefix:   r.Repository == "",
+				IgnoreErr:  true,
+				Headers: http.Header{
+					"Accept": []string{"application/json"},
+				},
+			},
+		},
+	}
+	resp, err := reg.reghttp.Do(ctx, req)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			// discovery endpoint is not implemented
+			return el, nil
+		}
+		return el, fmt.Errorf("failed to discover extensions for %s: %w", r.CommonName(), err)
+	}
+	defer resp.Close()
+	if resp.HTTPResponse().StatusCode != 200 {
+		return el, fmt.Errorf("failed to discover extensions for %s: %w", r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
+	}
+	body, err := io.ReadAll(resp)
+	if err != nil {
+		return el, fmt.Errorf("failed to read extensions for %s: %w", r.CommonName(), err)
+	}
+	err = json.Unmarshal(body, &el)
+	if err != nil {
+		reg.log.WithFields(logrus.Fields{
+			"err":  err,
+			"body": string(body),
+		}).Debug("Failed to parse extensions")
+		return el, fmt.Errorf("failed to parse extensions for %s: %w", r.CommonName(), err)
+	}
+	if el.Extensions == nil {
+		el.Extensions = []Extension{}
+	}
+	return el, nil
+}
@@ -0,0 +1,90 @@
+package reg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+func TestExtensionList(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/_oci/ext/discover":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"extensions":[{"name":"_oci","description":"discovery","url":"https://example.com","endpoints":["_oci/ext/discover"]}]}`)
+		case "/v2/proj/_oci/ext/discover":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"extensions":[{"name":"_oci"},{"name":"_example","endpoints":["_example/search"]}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	reg := New(
+		WithConfigHosts([]*config.Host{
+			{Name: tsHost, Hostname: tsHost, TLS: config.TLSDisabled},
+			{Name: "missing." + tsHost, Hostname: tsHost, TLS: config.TLSDisabled, PathPrefix: "missing"},
+		}),
+		WithLog(log),
+	)
+	t.Run("Registry", func(t *testing.T) {
+		el, err := reg.ExtensionList(ctx, ref.Ref{Scheme: "reg", Registry: tsHost})
+		if err != nil {
+			t.Fatalf("failed to list extensions: %v", err)
+		}
+		if len(el.Extensions) != 1 || el.Extensions[0].Name != "_oci" || el.Extensions[0].URL != "https://example.com" {
+			t.Errorf("unexpected extensions: %v", el)
+		}
+		if el.Supported("_example") {
+			t.Errorf("registry extension list includes a repository extension")
+		}
+	})
+	t.Run("Repository", func(t *testing.T) {
+		r, err := ref.New(tsHost + "/proj")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		el, err := reg.ExtensionList(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to list extensions: %v", err)
+		}
+		if !el.Supported("_oci") || !el.Supported("_example") {
+			t.Errorf("unexpected extensions: %v", el)
+		}
+		if el.Supported("_missing") {
+			t.Errorf("missing extension supported")
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		r, err := ref.New("missing." + tsHost + "/proj")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		el, err := reg.ExtensionList(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to list extensions: %v", err)
+		}
+		if len(el.Extensions) != 0 {
+			t.Errorf("unexpected extensions: %v", el)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package reg
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/reghttp"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+// hubURL is the Docker Hub API, a variable to allow tests to override the server
+var hubURL = url.URL{Scheme: "https", Host: "hub.docker.com"}
+
+// HubInfo is the repository metadata from the Docker Hub API
+type HubInfo struct {
+	Namespace       string    `json:"namespace"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	FullDescription string    `json:"full_description"`
+	StarCount       int64     `json:"star_count"`
+	PullCount       int64     `json:"pull_count"`
+	LastUpdated     time.Time `json:"last_updated"`
+	IsPrivate       bool      `json:"is_private"`
+}
+
+// MarshalPretty outputs the repository metadata in a human readable format
+func (h HubInfo) MarshalPretty() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Repository:\t%s/%s\n", h.Namespace, h.Name)
+	fmt.Fprintf(tw, "Description:\t%s\n", h.Description)
+	fmt.Fprintf(tw, "Stars:\t%d\n", h.StarCount)
+	fmt.Fprintf(tw, "Pulls:\t%d\n", h.PullCount)
+	if !h.LastUpdated.IsZero() {
+		fmt.Fprintf(tw, "Last Updated:\t%s\n", h.LastUpdated.Format(time.RFC3339))
+	}
+	fmt.Fprintf(tw, "Private:\t%t\n", h.IsPrivate)
+	err := tw.Flush()
+	return buf.Bytes(), err
+}
+
+// HubInfo returns the star count, pull count, last updated time, and description of a Docker Hub repository.
+// When the Hub API requests a login, the credentials configured for docker.io are used.
+func (reg *Reg) HubInfo(ctx context.Context, r ref.Ref) (HubInfo, error) {
+	hi := HubInfo{}
+	if r.Registry != config.DockerRegistry {
+		return hi, fmt.Errorf("hub info is only available for %s, registry is %s%.0w", config.DockerRegistry, r.Registry, types.ErrUnsupportedAPI)
+	}
+	u := hubURL
+	u.Path = "/v2/repositories/" + r.Repository + "/"
+	req := &reghttp.Req{
+		Host:      r.Registry,
+		NoMirrors: true,
+		APIs: map[string]reghttp.ReqAPI{
+			"": {
+				Method:    "GET",
+				DirectURL: &u,
+				Headers: http.Header{
+					"Accept": []string{"application/json"},
+				},
+			},
+		},
+	}
+	resp, err := reg.reghttp.Do(ctx, req)
+	if err != nil {
+		return hi, fmt.Errorf("failed to get hub info for %s: %w", r.CommonName(), err)
+	}
+	defer resp.Close()
+	if resp.HTTPResponse().StatusCode != 200 {
+		return hi, fmt.Errorf("failed to get hub info for %s: %w", r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
+	}
+	body, err := io.ReadAll(resp)
+	if err != nil {
+		return hi, fmt.Errorf("failed to read hub info for %s: %w", r.CommonName(), err)
+	}
+	err = json.Unmarshal(body, &hi)
+	if err != nil {
+		reg.log.WithFields(logrus.Fields{
+			"err":  err,
+			"body": string(body),
+		}).Debug("Failed to parse hub info")
+		return hi, fmt.Errorf("failed to parse hub info for %s: %w", r.CommonName(), err)
+	}
+	return hi, nil
+}
+
+// hubToken is a JWT from the Hub API login
+type hubToken struct {
+	user   string
+	token  string
+	expire time.Time
+}
+
+// hubTokenExpire is used when the JWT does not include an expiration
+var hubTokenExpire = time.Minute * 5
+
+// hubTokenEarly refreshes the JWT before it expires
+const hubTokenEarly = time.Second * 30
+
+// hubTagDelete deletes a tag with the Docker Hub repository API.
+// The Hub API requires a JWT from a login with the credentials configured for the registry.
+func (reg *Reg) hubTagDelete(ctx context.Context, r ref.Ref) error {
+	token, cached, err := reg.hubLogin(ctx, r, false)
+	if err != nil {
+		return err
+	}
+	resp, err := reg.hubTagDeleteReq(ctx, r, token)
+	if err != nil && cached && (errors.Is(err, types.ErrHTTPUnauthorized) || errors.Is(err, types.ErrEmptyChallenge)) {
+		// the cached JWT may have been revoked, login again
+		token, _, err = reg.hubLogin(ctx, r, true)
+		if err != nil {
+			return err
+		}
+		resp, err = reg.hubTagDeleteReq(ctx, r, token)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete tag %s: %w", r.CommonName(), err)
+	}
+	defer resp.Close()
+	switch resp.HTTPResponse().StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+	default:
+		return fmt.Errorf("failed to delete tag %s: %w", r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
+	}
+	return nil
+}
+
+func (reg *Reg) hubTagDeleteReq(ctx context.Context, r ref.Ref, token string) (reghttp.Resp, error) {
+	u := hubURL
+	u.Path = "/v2/repositories/" + r.Repository + "/tags/" + r.Tag + "/"
+	req := &reghttp.Req{
+		Host:      r.Registry,
+		NoMirrors: true,
+		APIs: map[string]reghttp.ReqAPI{
+			"": {
+				Method:    "DELETE",
+				DirectURL: &u,
+				Headers: http.Header{
+					"Authorization": []string{"Bearer " + token},
+				},
+			},
+		},
+	}
+	return reg.reghttp.Do(ctx, req)
+}
+
+// hubLogin returns the Hub API JWT for the registry credentials, reusing a cached JWT until it expires.
+// The returned bool is true when the JWT was cached.
+func (reg *Reg) hubLogin(ctx context.Context, r ref.Ref, force bool) (string, bool, error) {
+	cred := reg.hostGet(r.Registry).GetCred()
+	if cred.User == "" || cred.Password == "" {
+		return "", false, fmt.Errorf("deleting a tag with the hub API requires a login for %s%.0w", r.Registry, types.ErrHTTPUnauthorized)
+	}
+	reg.muHub.Lock()
+	defer reg.muHub.Unlock()
+	if ht, ok := reg.hubTokens[r.Registry]; ok && !force && ht.user == cred.User && time.Now().Before(ht.expire) {
+		return ht.token, true, nil
+	}
+	delete(reg.hubTokens, r.Registry)
+	loginBody, err := json.Marshal(struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{
+		Username: cred.User,
+		Password: cred.Password,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	u := hubURL
+	u.Path = "/v2/users/login"
+	req := &reghttp.Req{
+		Host:      r.Registry,
+		NoMirrors: true,
+		APIs: map[string]reghttp.ReqAPI{
+			"": {
+				Method:    "POST",
+				DirectURL: &u,
+				BodyBytes: loginBody,
+				BodyLen:   int64(len(loginBody)),
+				Headers: http.Header{
+					"Accept":       []string{"application/json"},
+					"Content-Type": []string{"application/json"},
+				},
+			},
+		},
+	}
+	resp, err := reg.reghttp.Do(ctx, req)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to login to the hub API: %w", err)
+	}
+	loginResp := struct {
+		Token string `json:"token"`
+	}{}
+	err = json.NewDecoder(resp).Decode(&loginResp)
+	resp.Close()
+	if err != nil || loginResp.Token == "" {
+		return "", false, fmt.Errorf("failed to parse the hub API login: %v%.0w", err, types.ErrHTTPUnauthorized)
+	}
+	reg.hubTokens[r.Registry] = hubToken{
+		user:   cred.User,
+		token:  loginResp.Token,
+		expire: hubTokenExpiration(loginResp.Token, time.Now()),
+	}
+	return loginResp.Token, false, nil
+}
+
+// hubTokenExpiration returns when to refresh the JWT, from the exp claim when available
+func hubTokenExpiration(token string, now time.Time) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) == 3 {
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		claims := struct {
+			Exp int64 `json:"exp"`
+		}{}
+		if err == nil && json.Unmarshal(payload, &claims) == nil && claims.Exp > 0 {
+			return time.Unix(claims.Exp, 0).Add(-1 * hubTokenEarly)
+		}
+	}
+	return now.Add(hubTokenExpire)
+}
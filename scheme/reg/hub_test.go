@@ -0,0 +1,177 @@
+package reg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestHubInfo(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v2/repositories/library/alpine/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"namespace":"library","name":"alpine","description":"A minimal Docker image","star_count":10,"pull_count":1000,"last_updated":"2024-01-27T00:43:42.426353Z","is_private":false}`)
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	hubURLOrig := hubURL
+	hubURL = url.URL{Scheme: "http", Host: tsURL.Host}
+	defer func() { hubURL = hubURLOrig }()
+	reg := New()
+	t.Run("Info", func(t *testing.T) {
+		r, err := ref.New("alpine")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		hi, err := reg.HubInfo(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to get hub info: %v", err)
+		}
+		if hi.Name != "alpine" || hi.StarCount != 10 || hi.PullCount != 1000 || hi.LastUpdated.Year() != 2024 || hi.Description != "A minimal Docker image" {
+			t.Errorf("unexpected hub info: %v", hi)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		r, err := ref.New("regclient/missing")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		_, err = reg.HubInfo(ctx, r)
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected not found, received %v", err)
+		}
+	})
+	t.Run("Other registry", func(t *testing.T) {
+		r, err := ref.New("ghcr.io/regclient/regctl")
+		if err != nil {
+			t.Fatalf("failed to parse ref: %v", err)
+		}
+		_, err = reg.HubInfo(ctx, r)
+		if !errors.Is(err, types.ErrUnsupportedAPI) {
+			t.Errorf("expected unsupported API, received %v", err)
+		}
+	})
+}
+
+func TestHubTokenExpiration(t *testing.T) {
+	now := time.Now()
+	exp := now.Add(time.Hour).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"user","exp":%d}`, exp)))
+	if e := hubTokenExpiration("header."+payload+".sig", now); !e.Equal(time.Unix(exp, 0).Add(-1 * hubTokenEarly)) {
+		t.Errorf("unexpected expiration from claim: %v", e)
+	}
+	if e := hubTokenExpiration("opaque", now); !e.Equal(now.Add(hubTokenExpire)) {
+		t.Errorf("unexpected default expiration: %v", e)
+	}
+}
+
+func TestHubTagDelete(t *testing.T) {
+	ctx := context.Background()
+	deleted := []string{}
+	logins := 0
+	token := "hubtoken"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/users/login":
+			logins++
+			login := struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+			}{}
+			err := json.NewDecoder(r.Body).Decode(&login)
+			if err != nil || login.Username != "user" || login.Password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"token":"%s"}`, token)
+		case r.Method == http.MethodDelete && (r.URL.Path == "/v2/repositories/regclient/test/tags/v1/" || r.URL.Path == "/v2/repositories/regclient/test/tags/v2/"):
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	hubURLOrig := hubURL
+	hubURL = url.URL{Scheme: "http", Host: tsURL.Host}
+	defer func() { hubURL = hubURLOrig }()
+	r, err := ref.New("regclient/test:v1")
+	if err != nil {
+		t.Fatalf("failed to parse ref: %v", err)
+	}
+	t.Run("Delete", func(t *testing.T) {
+		reg := New(WithConfigHosts([]*config.Host{{Name: config.DockerRegistry, API: config.APIHub, User: "user", Pass: "pass"}}))
+		err := reg.TagDelete(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to delete tag: %v", err)
+		}
+		if len(deleted) != 1 {
+			t.Errorf("unexpected deletes: %v", deleted)
+		}
+	})
+	t.Run("Cached login", func(t *testing.T) {
+		deleted = []string{}
+		logins = 0
+		reg := New(WithConfigHosts([]*config.Host{{Name: config.DockerRegistry, API: config.APIHub, User: "user", Pass: "pass"}}))
+		r2 := r
+		r2.Tag = "v2"
+		for _, rDel := range []ref.Ref{r, r2} {
+			err := reg.TagDelete(ctx, rDel)
+			if err != nil {
+				t.Fatalf("failed to delete tag: %v", err)
+			}
+		}
+		if len(deleted) != 2 || logins != 1 {
+			t.Errorf("unexpected deletes: %v, logins: %d", deleted, logins)
+		}
+		// a revoked token is replaced with a new login
+		token = "hubtoken2"
+		defer func() { token = "hubtoken" }()
+		err := reg.TagDelete(ctx, r)
+		if err != nil {
+			t.Fatalf("failed to delete tag: %v", err)
+		}
+		if len(deleted) != 3 || logins != 2 {
+			t.Errorf("unexpected deletes: %v, logins: %d", deleted, logins)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		reg := New(WithConfigHosts([]*config.Host{{Name: config.DockerRegistry, API: config.APIHub, User: "user", Pass: "pass"}}))
+		rMissing := r
+		rMissing.Tag = "missing"
+		err := reg.TagDelete(ctx, rMissing)
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected not found, received %v", err)
+		}
+	})
+	t.Run("No login", func(t *testing.T) {
+		reg := New(WithConfigHosts([]*config.Host{{Name: config.DockerRegistry, API: config.APIHub}}))
+		err := reg.TagDelete(ctx, r)
+		if !errors.Is(err, types.ErrHTTPUnauthorized) {
+			t.Errorf("expected unauthorized, received %v", err)
+		}
+	})
+}
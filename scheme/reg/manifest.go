@@ -1,12 +1,14 @@
 package reg
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/regclient/regclient/internal/limitread"
 	"github.com/regclient/regclient/internal/reghttp"
@@ -18,9 +20,23 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// manifestAcceptDefault is the Accept header for manifest requests when not configured
+var manifestAcceptDefault = []string{
+	types.MediaTypeOCI1ManifestList,
+	types.MediaTypeOCI1Manifest,
+	types.MediaTypeDocker2ManifestList,
+	types.MediaTypeDocker2Manifest,
+	types.MediaTypeDocker1ManifestSigned,
+	types.MediaTypeDocker1Manifest,
+	types.MediaTypeOCI1Artifact,
+}
+
 // ManifestDelete removes a manifest by reference (digest) from a registry.
 // This will implicitly delete all tags pointing to that manifest.
 func (reg *Reg) ManifestDelete(ctx context.Context, r ref.Ref, opts ...scheme.ManifestOpts) error {
+	if err := refValidate(r); err != nil {
+		return err
+	}
 	if r.Digest == "" {
 		return wraperr.New(fmt.Errorf("digest required to delete manifest, reference %s", r.CommonName()), types.ErrMissingDigest)
 	}
@@ -80,12 +96,15 @@ func (reg *Reg) ManifestDelete(ctx context.Context, r ref.Ref, opts ...scheme.Ma
 
 // ManifestGet retrieves a manifest from the registry
 func (reg *Reg) ManifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	if err := refValidate(r); err != nil {
+		return nil, err
+	}
 	var tagOrDigest string
 	if r.Digest != "" {
 		rCache := r
 		rCache.Tag = ""
 		rCache.Reference = rCache.CommonName()
-		if m, err := reg.cacheMan.Get(rCache); err == nil {
+		if m, err := reg.manifestCacheGet(rCache); err == nil {
 			return m, nil
 		}
 		tagOrDigest = r.Digest
@@ -97,15 +116,7 @@ func (reg *Reg) ManifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest,
 
 	// build/send request
 	headers := http.Header{
-		"Accept": []string{
-			types.MediaTypeOCI1ManifestList,
-			types.MediaTypeOCI1Manifest,
-			types.MediaTypeDocker2ManifestList,
-			types.MediaTypeDocker2Manifest,
-			types.MediaTypeDocker1ManifestSigned,
-			types.MediaTypeDocker1Manifest,
-			types.MediaTypeOCI1Artifact,
-		},
+		"Accept": reg.manifestAcceptList(r.Registry),
 	}
 	req := &reghttp.Req{
 		Host: r.Registry,
@@ -151,43 +162,47 @@ func (reg *Reg) ManifestGet(ctx context.Context, r ref.Ref) (manifest.Manifest,
 	if err != nil {
 		return nil, err
 	}
+	err = reg.manifestDescCheck(r, m)
+	if err != nil {
+		return nil, err
+	}
 	rCache := r
 	rCache.Tag = ""
 	rCache.Digest = m.GetDescriptor().Digest.String()
 	rCache.Reference = rCache.CommonName()
-	reg.cacheMan.Set(rCache, m)
+	reg.manifestCacheSet(rCache, m)
 	return m, nil
 }
 
-// ManifestHead returns metadata on the manifest from the registry
+// ManifestHead returns metadata on the manifest from the registry.
+// When the registry does not return a digest for the HEAD of a tag, the manifest is pulled to compute the digest,
+// and later requests to that registry skip the HEAD.
 func (reg *Reg) ManifestHead(ctx context.Context, r ref.Ref) (manifest.Manifest, error) {
+	if err := refValidate(r); err != nil {
+		return nil, err
+	}
 	// build the request
 	var tagOrDigest string
 	if r.Digest != "" {
 		rCache := r
 		rCache.Tag = ""
 		rCache.Reference = rCache.CommonName()
-		if m, err := reg.cacheMan.Get(rCache); err == nil {
+		if m, err := reg.manifestCacheGet(rCache); err == nil {
 			return m, nil
 		}
 		tagOrDigest = r.Digest
 	} else if r.Tag != "" {
 		tagOrDigest = r.Tag
+		if enabled, ok := reg.featureGet(featureHeadDigest, r.Registry, ""); ok && !enabled {
+			return reg.ManifestGet(ctx, r)
+		}
 	} else {
 		return nil, wraperr.New(fmt.Errorf("reference missing tag and digest: %s", r.CommonName()), types.ErrMissingTagOrDigest)
 	}
 
 	// build/send request
 	headers := http.Header{
-		"Accept": []string{
-			types.MediaTypeOCI1ManifestList,
-			types.MediaTypeOCI1Manifest,
-			types.MediaTypeDocker2ManifestList,
-			types.MediaTypeDocker2Manifest,
-			types.MediaTypeDocker1ManifestSigned,
-			types.MediaTypeDocker1Manifest,
-			types.MediaTypeOCI1Artifact,
-		},
+		"Accept": reg.manifestAcceptList(r.Registry),
 	}
 	req := &reghttp.Req{
 		Host: r.Registry,
@@ -208,6 +223,16 @@ func (reg *Reg) ManifestHead(ctx context.Context, r ref.Ref) (manifest.Manifest,
 	if resp.HTTPResponse().StatusCode != 200 {
 		return nil, fmt.Errorf("failed to request manifest head %s: %w", r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
 	}
+	// some registries do not include the digest header on a HEAD request, fall back to a GET to compute the digest
+	if r.Digest == "" && resp.HTTPResponse().Header.Get("Docker-Content-Digest") == "" {
+		reg.log.WithFields(logrus.Fields{
+			"ref": r.CommonName(),
+		}).Debug("Manifest head missing digest, falling back to get")
+		reg.featureSet(featureHeadDigest, r.Registry, "", false)
+		// release the throttle before sending the next request
+		resp.Close()
+		return reg.ManifestGet(ctx, r)
+	}
 
 	return manifest.New(
 		manifest.WithRef(r),
@@ -217,6 +242,9 @@ func (reg *Reg) ManifestHead(ctx context.Context, r ref.Ref) (manifest.Manifest,
 
 // ManifestPut uploads a manifest to a registry
 func (reg *Reg) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest, opts ...scheme.ManifestOpts) error {
+	if err := refValidate(r); err != nil {
+		return err
+	}
 	var tagOrDigest string
 	if r.Digest != "" {
 		tagOrDigest = r.Digest
@@ -244,6 +272,14 @@ func (reg *Reg) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest,
 		return fmt.Errorf("manifest too large, calculated %d, limit %d: %s%.0w", len(mj), reg.manifestMaxPush, r.CommonName(), types.ErrSizeLimitExceeded)
 	}
 
+	// the push is still attempted when the registry previously rejected zstd, the registry may have been upgraded
+	zstd := manifestHasZstd(m)
+	if enabled, ok := reg.featureGet(featureZstd, r.Registry, ""); zstd && ok && !enabled {
+		reg.log.WithFields(logrus.Fields{
+			"ref": r.CommonName(),
+		}).Debug("Registry previously rejected zstd compressed layers")
+	}
+
 	// build/send request
 	headers := http.Header{
 		"Content-Type": []string{manifest.GetMediaType(m)},
@@ -264,18 +300,25 @@ func (reg *Reg) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest,
 	}
 	resp, err := reg.reghttp.Do(ctx, req)
 	if err != nil {
+		if zstd && manifestErrZstd(err) {
+			reg.featureSet(featureZstd, r.Registry, "", false)
+			return fmt.Errorf("failed to put manifest %s, registry does not accept zstd compressed layers: %w%.0w", r.CommonName(), err, types.ErrUnsupportedMediaType)
+		}
 		return fmt.Errorf("failed to put manifest %s: %w", r.CommonName(), err)
 	}
 	resp.Close()
 	if resp.HTTPResponse().StatusCode != 201 {
 		return fmt.Errorf("failed to put manifest %s: %w", r.CommonName(), reghttp.HTTPError(resp.HTTPResponse().StatusCode))
 	}
+	if zstd {
+		reg.featureSet(featureZstd, r.Registry, "", true)
+	}
 
 	rCache := r
 	rCache.Tag = ""
 	rCache.Digest = m.GetDescriptor().Digest.String()
 	rCache.Reference = rCache.CommonName()
-	reg.cacheMan.Set(rCache, m)
+	reg.manifestCacheSet(rCache, m)
 
 	// update referrers if defined on this manifest
 	if mr, ok := m.(manifest.Subjecter); ok {
@@ -289,7 +332,20 @@ func (reg *Reg) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest,
 			rSubj.Digest = mDesc.Digest.String()
 			rSubj.Reference = rSubj.CommonName()
 			reg.cacheRL.Delete(rSubj)
-			if mDesc.Digest.String() != resp.HTTPResponse().Header.Get(OCISubjectHeader) {
+			// registries supporting the referrers API return the subject digest in the OCI-Subject header,
+			// otherwise the client manages the fallback tag
+			subjHeader := resp.HTTPResponse().Header.Get(OCISubjectHeader)
+			if subjHeader == mDesc.Digest.String() {
+				reg.featureSet(featureReferrer, r.Registry, r.Repository, true)
+			} else {
+				if subjHeader != "" {
+					reg.log.WithFields(logrus.Fields{
+						"ref":     r.CommonName(),
+						"subject": mDesc.Digest.String(),
+						"header":  subjHeader,
+					}).Warn("Registry returned an unexpected OCI-Subject header, updating the referrers fallback tag")
+				}
+				reg.featureSet(featureReferrer, r.Registry, r.Repository, false)
 				err = reg.referrerPut(ctx, r, m)
 				if err != nil {
 					return err
@@ -300,3 +356,103 @@ func (reg *Reg) ManifestPut(ctx context.Context, r ref.Ref, m manifest.Manifest,
 
 	return nil
 }
+
+// manifestAcceptList returns the media types to accept for a manifest request, preferring the host setting
+func (reg *Reg) manifestAcceptList(registry string) []string {
+	accept := manifestAcceptDefault
+	if host := reg.hostGet(registry); len(host.ManifestAccept) > 0 {
+		accept = host.ManifestAccept
+	} else if len(reg.manifestAccept) > 0 {
+		accept = reg.manifestAccept
+	}
+	return append([]string{}, accept...)
+}
+
+// manifestDescCheck verifies the number of descriptors in a manifest is within the limit
+func (reg *Reg) manifestDescCheck(r ref.Ref, m manifest.Manifest) error {
+	if reg.manifestMaxDesc <= 0 {
+		return nil
+	}
+	count := 0
+	if mi, ok := m.(manifest.Indexer); ok {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return err
+		}
+		count = len(dl)
+	} else if mi, ok := m.(manifest.Imager); ok {
+		dl, err := mi.GetLayers()
+		if err != nil {
+			return err
+		}
+		// include the config descriptor
+		count = len(dl) + 1
+	}
+	if count > reg.manifestMaxDesc {
+		return fmt.Errorf("manifest has too many descriptors, received %d, limit %d: %s%.0w", count, reg.manifestMaxDesc, r.CommonName(), types.ErrSizeLimitExceeded)
+	}
+	return nil
+}
+
+// manifestCacheGet returns a copy of the cached manifest so changes made by the caller do not modify the cache
+func (reg *Reg) manifestCacheGet(r ref.Ref) (manifest.Manifest, error) {
+	m, err := reg.cacheMan.Get(r)
+	if err != nil {
+		return nil, err
+	}
+	return manifestCopy(m)
+}
+
+// manifestCacheSet saves a copy of the manifest, leaving the raw body untouched by later changes to m
+func (reg *Reg) manifestCacheSet(r ref.Ref, m manifest.Manifest) {
+	mc, err := manifestCopy(m)
+	if err != nil {
+		return
+	}
+	reg.cacheMan.Set(r, mc)
+}
+
+// manifestCopy creates a new manifest from the raw body and headers of m
+func manifestCopy(m manifest.Manifest) (manifest.Manifest, error) {
+	raw, err := m.RawBody()
+	if err != nil {
+		return nil, err
+	}
+	header, _ := m.RawHeaders()
+	return manifest.New(
+		manifest.WithRef(m.GetRef()),
+		manifest.WithDesc(m.GetDescriptor()),
+		manifest.WithHeader(header.Clone()),
+		manifest.WithRaw(bytes.Clone(raw)),
+	)
+}
+
+// manifestErrZstd returns true when the registry error response indicates the zstd layer media type is unsupported
+func manifestErrZstd(err error) bool {
+	for _, re := range types.RegistryErrors(err) {
+		msg := strings.ToLower(re.Message + " " + string(re.Detail))
+		if strings.Contains(msg, "zstd") ||
+			((strings.Contains(msg, "media type") || strings.Contains(msg, "mediatype")) && (strings.Contains(msg, "unsupported") || strings.Contains(msg, "not supported"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestHasZstd returns true when an image manifest includes a zstd compressed layer
+func manifestHasZstd(m manifest.Manifest) bool {
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return false
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return false
+	}
+	for _, l := range layers {
+		if l.MediaType == types.MediaTypeOCI1LayerZstd || l.MediaType == types.MediaTypeOCI1ForeignLayerZstd {
+			return true
+		}
+	}
+	return false
+}
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/docker/schema2"
 	"github.com/regclient/regclient/types/manifest"
+	v1 "github.com/regclient/regclient/types/oci/v1"
 	"github.com/regclient/regclient/types/ref"
 	"github.com/sirupsen/logrus"
 )
@@ -32,6 +34,8 @@ func TestManifest(t *testing.T) {
 	noheadTag := "nohead"
 	missingTag := "missing"
 	putTag := "put"
+	rawTag := "raw"
+	rawPutTag := "raw-put"
 	digest1 := digest.FromString("example1")
 	digest2 := digest.FromString("example2")
 	m := schema2.Manifest{
@@ -54,6 +58,12 @@ func TestManifest(t *testing.T) {
 	}
 	mDigest := digest.FromBytes(mBody)
 	mLen := len(mBody)
+	// indented json is not canonical, the bytes must be returned and pushed without changes
+	mRawBody, err := json.MarshalIndent(m, "", "   ")
+	if err != nil {
+		t.Errorf("Failed to marshal manifest: %v", err)
+	}
+	mRawDigest := digest.FromBytes(mRawBody)
 	ctx := context.Background()
 	rrs := []reqresp.ReqResp{
 		{
@@ -166,6 +176,36 @@ func TestManifest(t *testing.T) {
 				Body: mBody,
 			},
 		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "Get raw",
+				Method: "GET",
+				Path:   "/v2" + repoPath + "/manifests/" + rawTag,
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusOK,
+				Headers: http.Header{
+					"Content-Length":        {fmt.Sprintf("%d", len(mRawBody))},
+					"Content-Type":          []string{types.MediaTypeDocker2Manifest},
+					"Docker-Content-Digest": []string{mRawDigest.String()},
+				},
+				Body: mRawBody,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "Put raw",
+				Method: "PUT",
+				Path:   "/v2" + repoPath + "/manifests/" + rawPutTag,
+				Body:   mRawBody,
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusCreated,
+				Headers: http.Header{
+					"Docker-Content-Digest": []string{mRawDigest.String()},
+				},
+			},
+		},
 		{
 			ReqEntry: reqresp.ReqEntry{
 				Name:   "Missing",
@@ -259,6 +299,9 @@ func TestManifest(t *testing.T) {
 		if mGet.GetDescriptor().Digest != mDigest {
 			t.Errorf("Unexpected digest: %s", mGet.GetDescriptor().Digest.String())
 		}
+		if rh := manifest.GetResponseHeaders(mGet); rh.Digest != mDigest {
+			t.Errorf("Unexpected response headers: %v", rh)
+		}
 	})
 	t.Run("Head", func(t *testing.T) {
 		headRef, err := ref.New(tsURL.Host + repoPath + ":" + headTag)
@@ -378,6 +421,55 @@ func TestManifest(t *testing.T) {
 			return
 		}
 	})
+	t.Run("Raw Passthrough", func(t *testing.T) {
+		rawRef, err := ref.New(tsURL.Host + repoPath + ":" + rawTag)
+		if err != nil {
+			t.Errorf("Failed creating ref: %v", err)
+			return
+		}
+		mGet, err := regCache.ManifestGet(ctx, rawRef)
+		if err != nil {
+			t.Errorf("Failed running ManifestGet: %v", err)
+			return
+		}
+		raw, err := mGet.RawBody()
+		if err != nil {
+			t.Errorf("Failed to get raw body: %v", err)
+			return
+		}
+		if string(raw) != string(mRawBody) || mGet.GetDescriptor().Digest != mRawDigest {
+			t.Errorf("Raw body was modified, expected %s, received %s", string(mRawBody), string(raw))
+		}
+		// changes to the returned manifest must not modify the cached copy
+		err = mGet.(manifest.Annotator).SetAnnotation("test", "modified")
+		if err != nil {
+			t.Errorf("Failed to set annotation: %v", err)
+			return
+		}
+		digRef := rawRef
+		digRef.Tag = ""
+		digRef.Digest = mRawDigest.String()
+		mCache, err := regCache.ManifestGet(ctx, digRef)
+		if err != nil {
+			t.Errorf("Failed running ManifestGet (cache): %v", err)
+			return
+		}
+		raw, err = mCache.RawBody()
+		if err != nil {
+			t.Errorf("Failed to get raw body: %v", err)
+			return
+		}
+		if string(raw) != string(mRawBody) || mCache.GetDescriptor().Digest != mRawDigest {
+			t.Errorf("Cached manifest was modified: %s", string(raw))
+		}
+		// the pushed body is identical to the pulled body
+		putRef := rawRef
+		putRef.Tag = rawPutTag
+		err = regCache.ManifestPut(ctx, putRef, mCache)
+		if err != nil {
+			t.Errorf("Failed to put manifest: %v", err)
+		}
+	})
 	// TODO: get manifest that is larger than Content-Length header
 	t.Run("Size Limit", func(t *testing.T) {
 		bigRef, err := ref.New(tsURL.Host + repoPath + ":" + bigTag)
@@ -394,6 +486,27 @@ func TestManifest(t *testing.T) {
 			return
 		}
 	})
+	t.Run("Descriptor Limit", func(t *testing.T) {
+		regLimit := New(
+			WithConfigHosts(rcHosts),
+			WithLog(log),
+			WithDelay(delayInit, delayMax),
+			WithManifestMaxDesc(1),
+		)
+		getRef, err := ref.New(tsURL.Host + repoPath + ":" + getTag)
+		if err != nil {
+			t.Errorf("Failed creating ref: %v", err)
+		}
+		_, err = regLimit.ManifestGet(ctx, getRef)
+		if err == nil {
+			t.Errorf("ManifestGet did not fail")
+			return
+		}
+		if !errors.Is(err, types.ErrSizeLimitExceeded) {
+			t.Errorf("unexpected error, expected %v, received %v", types.ErrSizeLimitExceeded, err)
+			return
+		}
+	})
 	t.Run("Read beyond size", func(t *testing.T) {
 		shortRef, err := ref.New(tsURL.Host + repoPath + ":" + shortReadTag)
 		if err != nil {
@@ -450,3 +563,298 @@ func TestManifest(t *testing.T) {
 		}
 	})
 }
+
+func TestManifestAccept(t *testing.T) {
+	ctx := context.Background()
+	m := schema2.Manifest{
+		Config: types.Descriptor{
+			MediaType: types.MediaTypeDocker2ImageConfig,
+			Size:      8,
+			Digest:    digest.FromString("config"),
+		},
+	}
+	m.SchemaVersion = 2
+	m.MediaType = types.MediaTypeDocker2Manifest
+	mBody, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("failed to marshal manifest: %v", err)
+	}
+	reqAccept := [][]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqAccept = append(reqAccept, r.Header.Values("Accept"))
+		w.Header().Set("Content-Type", types.MediaTypeDocker2Manifest)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(mBody).String())
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(mBody)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(mBody)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	hostAccept := []string{types.MediaTypeDocker2ManifestList, types.MediaTypeDocker2Manifest}
+	regAccept := []string{types.MediaTypeOCI1Manifest, "application/vnd.example.custom+json"}
+	tests := []struct {
+		name   string
+		host   string
+		reg    *Reg
+		expect []string
+	}{
+		{
+			name:   "default",
+			host:   tsHost,
+			reg:    New(WithConfigHosts([]*config.Host{{Name: tsHost, Hostname: tsHost, TLS: config.TLSDisabled}}), WithLog(log)),
+			expect: manifestAcceptDefault,
+		},
+		{
+			name:   "option",
+			host:   tsHost,
+			reg:    New(WithConfigHosts([]*config.Host{{Name: tsHost, Hostname: tsHost, TLS: config.TLSDisabled}}), WithManifestAccept(regAccept...), WithLog(log)),
+			expect: regAccept,
+		},
+		{
+			name: "host",
+			host: "proxy." + tsHost,
+			reg: New(WithConfigHosts([]*config.Host{{Name: "proxy." + tsHost, Hostname: tsHost, TLS: config.TLSDisabled, ManifestAccept: hostAccept}}),
+				WithManifestAccept(regAccept...), WithLog(log)),
+			expect: hostAccept,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ref.New(tc.host + "/proj:" + tc.name)
+			if err != nil {
+				t.Fatalf("failed creating ref: %v", err)
+			}
+			reqAccept = [][]string{}
+			_, err = tc.reg.ManifestHead(ctx, r)
+			if err != nil {
+				t.Fatalf("failed running ManifestHead: %v", err)
+			}
+			_, err = tc.reg.ManifestGet(ctx, r)
+			if err != nil {
+				t.Fatalf("failed running ManifestGet: %v", err)
+			}
+			if len(reqAccept) != 2 {
+				t.Fatalf("unexpected number of requests: %d", len(reqAccept))
+			}
+			for _, accept := range reqAccept {
+				if !stringSliceCmp(tc.expect, accept) {
+					t.Errorf("unexpected accept header, expected %v, received %v", tc.expect, accept)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestHeadNoDigest(t *testing.T) {
+	ctx := context.Background()
+	m := schema2.Manifest{
+		Config: types.Descriptor{
+			MediaType: types.MediaTypeDocker2ImageConfig,
+			Size:      8,
+			Digest:    digest.FromString("config"),
+		},
+	}
+	m.SchemaVersion = 2
+	m.MediaType = types.MediaTypeDocker2Manifest
+	mBody, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("failed to marshal manifest: %v", err)
+	}
+	mDigest := digest.FromBytes(mBody)
+	reqMethods := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqMethods = append(reqMethods, r.Method)
+		// the digest header is only returned on a GET
+		w.Header().Set("Content-Type", types.MediaTypeDocker2Manifest)
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(mBody)))
+		if r.Method == http.MethodGet {
+			w.Header().Set("Docker-Content-Digest", mDigest.String())
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(mBody)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	reg := New(
+		WithConfigHosts([]*config.Host{{Name: tsHost, Hostname: tsHost, TLS: config.TLSDisabled}}),
+		WithLog(log),
+	)
+	r, err := ref.New(tsHost + "/proj:latest")
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+	mh, err := reg.ManifestHead(ctx, r)
+	if err != nil {
+		t.Fatalf("failed running ManifestHead: %v", err)
+	}
+	if mh.GetDescriptor().Digest != mDigest {
+		t.Errorf("unexpected digest, expected %s, received %s", mDigest, mh.GetDescriptor().Digest)
+	}
+	if !stringSliceCmp([]string{http.MethodHead, http.MethodGet}, reqMethods) {
+		t.Errorf("unexpected requests: %v", reqMethods)
+	}
+	// the registry is known to omit the digest, later requests skip the HEAD
+	reqMethods = []string{}
+	mh, err = reg.ManifestHead(ctx, r)
+	if err != nil {
+		t.Fatalf("failed running ManifestHead: %v", err)
+	}
+	if mh.GetDescriptor().Digest != mDigest {
+		t.Errorf("unexpected digest, expected %s, received %s", mDigest, mh.GetDescriptor().Digest)
+	}
+	if !stringSliceCmp([]string{http.MethodGet}, reqMethods) {
+		t.Errorf("unexpected requests: %v", reqMethods)
+	}
+}
+
+func TestManifestPutZstd(t *testing.T) {
+	ctx := context.Background()
+	puts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		puts++
+		body, _ := io.ReadAll(r.Body)
+		// reject the invalid digest without indicating a media type issue
+		if strings.Contains(string(body), digest.FromString("invalid").String()) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"manifest invalid"}]}`))
+			return
+		}
+		// reject zstd compressed layers
+		if strings.Contains(string(body), types.MediaTypeOCI1LayerZstd) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"manifest invalid","detail":"unsupported layer media type: ` + types.MediaTypeOCI1LayerZstd + `"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	reg := New(
+		WithConfigHosts([]*config.Host{{Name: tsHost, Hostname: tsHost, TLS: config.TLSDisabled}}),
+		WithLog(log),
+		WithDelay(time.Millisecond*10, time.Millisecond*100),
+		WithRetryLimit(1),
+	)
+	r, err := ref.New(tsHost + "/proj:latest")
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+	newManifest := func(mt string, layerDig digest.Digest) manifest.Manifest {
+		m, err := manifest.New(manifest.WithOrig(v1.Manifest{
+			Versioned: v1.ManifestSchemaVersion,
+			MediaType: types.MediaTypeOCI1Manifest,
+			Config:    types.Descriptor{MediaType: types.MediaTypeOCI1ImageConfig, Size: 8, Digest: digest.FromString("config")},
+			Layers:    []types.Descriptor{{MediaType: mt, Size: 5, Digest: layerDig}},
+		}))
+		if err != nil {
+			t.Fatalf("failed to create manifest: %v", err)
+		}
+		return m
+	}
+	// other rejections are not recorded as a lack of zstd support
+	err = reg.ManifestPut(ctx, r, newManifest(types.MediaTypeOCI1LayerZstd, digest.FromString("invalid")))
+	if err == nil || errors.Is(err, types.ErrUnsupportedMediaType) {
+		t.Errorf("unexpected error for invalid manifest: %v", err)
+	}
+	if _, ok := reg.featureGet(featureZstd, tsHost, ""); ok {
+		t.Errorf("zstd support recorded for an unrelated error")
+	}
+	mZstd := newManifest(types.MediaTypeOCI1LayerZstd, digest.FromString("layer"))
+	err = reg.ManifestPut(ctx, r, mZstd)
+	if !errors.Is(err, types.ErrUnsupportedMediaType) {
+		t.Errorf("expected unsupported media type, received %v", err)
+	}
+	if enabled, ok := reg.featureGet(featureZstd, tsHost, ""); !ok || enabled {
+		t.Errorf("zstd rejection was not recorded")
+	}
+	// later zstd pushes are still sent to the registry
+	putsFirst := puts
+	err = reg.ManifestPut(ctx, r, mZstd)
+	if !errors.Is(err, types.ErrUnsupportedMediaType) {
+		t.Errorf("expected unsupported media type, received %v", err)
+	}
+	if puts == putsFirst {
+		t.Errorf("zstd manifest was not pushed after the rejection")
+	}
+	err = reg.ManifestPut(ctx, r, newManifest(types.MediaTypeOCI1LayerGzip, digest.FromString("layer")))
+	if err != nil {
+		t.Errorf("failed to put gzip manifest: %v", err)
+	}
+}
+
+func TestManifestInvalidRef(t *testing.T) {
+	ctx := context.Background()
+	reqCount := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCount++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	reg := New(
+		WithConfigHosts([]*config.Host{{Name: tsHost, Hostname: tsHost, TLS: config.TLSDisabled}}),
+		WithLog(log),
+	)
+	r, err := ref.New(tsHost + "/proj:v1")
+	if err != nil {
+		t.Fatalf("failed creating ref: %v", err)
+	}
+	// references modified after parsing are rejected locally
+	rTag := r
+	rTag.Tag = "v1/bad"
+	_, err = reg.ManifestGet(ctx, rTag)
+	if !errors.Is(err, types.ErrInvalidReference) {
+		t.Errorf("unexpected error for invalid tag: %v", err)
+	}
+	rRepo := r
+	rRepo.Repository = "Proj"
+	_, err = reg.ManifestHead(ctx, rRepo)
+	if !errors.Is(err, types.ErrInvalidReference) {
+		t.Errorf("unexpected error for invalid repository: %v", err)
+	}
+	_, err = reg.BlobGet(ctx, r, types.Descriptor{Digest: "sha256:1234"})
+	if !errors.Is(err, types.ErrInvalidReference) {
+		t.Errorf("unexpected error for invalid digest: %v", err)
+	}
+	if reqCount > 0 {
+		t.Errorf("requests sent for invalid references: %d", reqCount)
+	}
+}
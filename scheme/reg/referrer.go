@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 
 	"github.com/regclient/regclient/internal/httplink"
@@ -22,6 +23,9 @@ const OCISubjectHeader = "OCI-Subject"
 
 // ReferrerList returns a list of referrers to a given reference
 func (reg *Reg) ReferrerList(ctx context.Context, r ref.Ref, opts ...scheme.ReferrerOpts) (referrer.ReferrerList, error) {
+	if err := refValidate(r); err != nil {
+		return referrer.ReferrerList{}, err
+	}
 	config := scheme.ReferrerConfig{}
 	for _, opt := range opts {
 		opt(&config)
@@ -74,13 +78,13 @@ func (reg *Reg) ReferrerList(ctx context.Context, r ref.Ref, opts ...scheme.Refe
 	}
 	// try referrers API
 	if !found {
-		referrerEnabled, ok := reg.featureGet("referrer", r.Registry, r.Repository)
+		referrerEnabled, ok := reg.featureGet(featureReferrer, r.Registry, r.Repository)
 		if !ok || referrerEnabled {
 			// attempt to call the referrer API
 			rl, err = reg.referrerListByAPI(ctx, r, config)
 			if !ok {
 				// save the referrer API state
-				reg.featureSet("referrer", r.Registry, r.Repository, err == nil)
+				reg.featureSet(featureReferrer, r.Registry, r.Repository, err == nil)
 			}
 			if err == nil {
 				if config.FilterArtifactType == "" {
@@ -168,6 +172,7 @@ func (reg *Reg) referrerListByAPIPage(ctx context.Context, r ref.Ref, config sch
 				Repository: r.Repository,
 				Path:       "referrers/" + r.Digest,
 				Query:      query,
+				Headers:    http.Header{"Accept": []string{types.MediaTypeOCI1ManifestList}},
 				IgnoreErr:  true,
 			},
 		},
@@ -178,6 +183,7 @@ func (reg *Reg) referrerListByAPIPage(ctx context.Context, r ref.Ref, config sch
 			Method:     "GET",
 			DirectURL:  link,
 			Repository: r.Repository,
+			Headers:    http.Header{"Accept": []string{types.MediaTypeOCI1ManifestList}},
 		}
 	}
 	resp, err := reg.reghttp.Do(ctx, req)
@@ -363,7 +369,7 @@ func (reg *Reg) referrerPut(ctx context.Context, r ref.Ref, m manifest.Manifest)
 
 // referrerPing verifies the registry supports the referrers API
 func (reg *Reg) referrerPing(ctx context.Context, r ref.Ref) bool {
-	referrerEnabled, ok := reg.featureGet("referrer", r.Registry, r.Repository)
+	referrerEnabled, ok := reg.featureGet(featureReferrer, r.Registry, r.Repository)
 	if ok {
 		return referrerEnabled
 	}
@@ -379,11 +385,11 @@ func (reg *Reg) referrerPing(ctx context.Context, r ref.Ref) bool {
 	}
 	resp, err := reg.reghttp.Do(ctx, req)
 	if err != nil {
-		reg.featureSet("referrer", r.Registry, r.Repository, false)
+		reg.featureSet(featureReferrer, r.Registry, r.Repository, false)
 		return false
 	}
 	resp.Close()
 	result := resp.HTTPResponse().StatusCode == 200
-	reg.featureSet("referrer", r.Registry, r.Repository, result)
+	reg.featureSet(featureReferrer, r.Registry, r.Repository, result)
 	return result
 }
@@ -656,6 +656,9 @@ func TestReferrer(t *testing.T) {
 				Method:  "GET",
 				Path:    "/v2" + repoPath + "/referrers/" + mDigest.String(),
 				IfState: []string{"putA", "deleteB"},
+				Headers: http.Header{
+					"Accept": []string{types.MediaTypeOCI1ManifestList},
+				},
 			},
 			RespEntry: reqresp.RespEntry{
 				Status: http.StatusOK,
@@ -676,6 +679,9 @@ func TestReferrer(t *testing.T) {
 					"next": {"1"},
 				},
 				IfState: []string{"putBoth"},
+				Headers: http.Header{
+					"Accept": []string{types.MediaTypeOCI1ManifestList},
+				},
 			},
 			RespEntry: reqresp.RespEntry{
 				Status: http.StatusOK,
@@ -844,6 +850,28 @@ func TestReferrer(t *testing.T) {
 		}
 	})
 
+	t.Run("Put A API detects referrers", func(t *testing.T) {
+		// a new client learns the referrers API is supported from the OCI-Subject header
+		regHeader := New(
+			WithConfigHosts(rcHosts),
+			WithLog(log),
+			WithDelay(delayInit, delayMax),
+		)
+		r, err := ref.New(tsURLAPI.Host + repoPath + "@" + artifactM.GetDescriptor().Digest.String())
+		if err != nil {
+			t.Errorf("Failed creating ref: %v", err)
+		}
+		err = regHeader.ManifestPut(ctx, r, artifactM)
+		if err != nil {
+			t.Errorf("Failed running ManifestPut: %v", err)
+			return
+		}
+		enabled, ok := regHeader.featureGet(featureReferrer, r.Registry, r.Repository)
+		if !ok || !enabled {
+			t.Errorf("referrers API not detected, enabled %t, found %t", enabled, ok)
+		}
+	})
+
 	// list referrers to v1
 	t.Run("List A NoAPI", func(t *testing.T) {
 		r, err := ref.New(tsURLNoAPI.Host + repoPath + ":" + tagV1)
@@ -6,10 +6,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/cache"
 	"github.com/regclient/regclient/internal/reghttp"
 	"github.com/regclient/regclient/internal/throttle"
+	"github.com/regclient/regclient/pkg/trace"
 	"github.com/regclient/regclient/types/manifest"
 	"github.com/regclient/regclient/types/ref"
 	"github.com/regclient/regclient/types/referrer"
@@ -21,6 +23,10 @@ const (
 	blobChunkMinHeader = "OCI-Chunk-Min-Length"
 	// defaultBlobChunk 1M chunks, this is allocated in a memory buffer
 	defaultBlobChunk = 1024 * 1024
+	// blobUploadRestartLimit is the number of times a failed request for an upload session is restarted
+	blobUploadRestartLimit = 3
+	// blobChunkMin is the smallest chunk size used when reducing the size after a registry rejects a chunk
+	blobChunkMin = 64 * 1024
 	// defaultBlobChunkLimit 1G chunks, prevents a memory exhaustion attack
 	defaultBlobChunkLimit = 1024 * 1024 * 1024
 	// defaultBlobMax is disabled to support registries without chunked upload support
@@ -41,11 +47,19 @@ type Reg struct {
 	blobChunkSize   int64
 	blobChunkLimit  int64
 	blobMaxPut      int64
+	blobSpoolDir    string
+	blobSpoolMax    int64
+	blobSizeCheck   bool
+	catalogCacheDir string
+	manifestAccept  []string
 	manifestMaxPull int64
+	manifestMaxDesc int
 	manifestMaxPush int64
 	cacheMan        *cache.Cache[ref.Ref, manifest.Manifest]
 	cacheRL         *cache.Cache[ref.Ref, referrer.ReferrerList]
+	hubTokens       map[string]hubToken
 	muHost          sync.Mutex
+	muHub           sync.Mutex
 	muRefTag        sync.Mutex
 }
 
@@ -56,11 +70,27 @@ type featureKey struct {
 }
 type featureVal struct {
 	enabled bool
+	size    int64
 	expire  time.Time
 }
 
 var featureExpire = time.Minute * time.Duration(5)
 
+// blobUploadCancelTimeout limits the cleanup of a failed upload, which runs even after the context is canceled
+var blobUploadCancelTimeout = time.Second * 30
+
+// features cached per registry or repository to avoid probing on every request
+const (
+	featureBlobMountAnon = "blobMountAnon" // anonymous blob mount requests are accepted
+	featureCatalog       = "catalog"       // catalog API to list repositories
+	featureChunkMax      = "chunkMax"      // maximum size of a chunked upload from the Range of a partially accepted chunk
+	featureChunkMin      = "chunkMin"      // minimum size of a chunked upload from the OCI-Chunk-Min-Length header
+	featureHeadDigest    = "headDigest"    // manifest HEAD requests return the Docker-Content-Digest header
+	featureReferrer      = "referrer"      // OCI referrers API
+	featureTagDelete     = "tagDelete"     // delete by tag API
+	featureZstd          = "zstd"          // manifests with zstd compressed layers are accepted
+)
+
 // Opts provides options to access registries
 type Opts func(*Reg)
 
@@ -75,6 +105,7 @@ func New(opts ...Opts) *Reg {
 		manifestMaxPush: defaultManifestMaxPush,
 		hosts:           map[string]*config.Host{},
 		features:        map[featureKey]*featureVal{},
+		hubTokens:       map[string]hubToken{},
 	}
 	r.reghttpOpts = append(r.reghttpOpts, reghttp.WithConfigHost(r.hostGet))
 	for _, opt := range opts {
@@ -112,8 +143,31 @@ func (reg *Reg) hostGet(hostname string) *config.Host {
 	return reg.hosts[hostname]
 }
 
+// refValidate rejects invalid names before sending a request, some registries respond to these with a confusing 500 error.
+// Digests of blob descriptors are checked with the reference.
+func refValidate(r ref.Ref, digests ...digest.Digest) error {
+	err := r.Validate()
+	if err != nil {
+		return err
+	}
+	for _, dig := range digests {
+		if dig == "" {
+			continue
+		}
+		r.Digest = dig.String()
+		err = r.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // featureGet returns enabled and ok
 func (reg *Reg) featureGet(kind, registry, repo string) (bool, bool) {
+	if enabled, ok := reg.featureProfile(kind, registry); ok {
+		return enabled, true
+	}
 	reg.muHost.Lock()
 	defer reg.muHost.Unlock()
 	if v, ok := reg.features[featureKey{kind: kind, reg: registry, repo: repo}]; ok {
@@ -124,12 +178,42 @@ func (reg *Reg) featureGet(kind, registry, repo string) (bool, bool) {
 	return false, false
 }
 
+// featureProfile returns features disabled by the API profile of the registry
+func (reg *Reg) featureProfile(kind, registry string) (bool, bool) {
+	profile := reg.hostGet(registry).APIProfile()
+	switch {
+	case kind == featureCatalog && profile.NoCatalog,
+		kind == featureReferrer && profile.NoReferrers,
+		kind == featureTagDelete && profile.NoTagDelete:
+		return false, true
+	}
+	return false, false
+}
+
 func (reg *Reg) featureSet(kind, registry, repo string, enabled bool) {
 	reg.muHost.Lock()
 	reg.features[featureKey{kind: kind, reg: registry, repo: repo}] = &featureVal{enabled: enabled, expire: time.Now().Add(featureExpire)}
 	reg.muHost.Unlock()
 }
 
+// featureSizeGet returns a size and ok for features with a size, like the minimum chunk size
+func (reg *Reg) featureSizeGet(kind, registry string) (int64, bool) {
+	reg.muHost.Lock()
+	defer reg.muHost.Unlock()
+	if v, ok := reg.features[featureKey{kind: kind, reg: registry}]; ok && v.enabled {
+		if time.Now().Before(v.expire) {
+			return v.size, true
+		}
+	}
+	return 0, false
+}
+
+func (reg *Reg) featureSizeSet(kind, registry string, size int64) {
+	reg.muHost.Lock()
+	reg.features[featureKey{kind: kind, reg: registry}] = &featureVal{enabled: true, size: size, expire: time.Now().Add(featureExpire)}
+	reg.muHost.Unlock()
+}
+
 // WithBlobSize overrides default blob sizes
 func WithBlobSize(size, max int64) Opts {
 	return func(r *Reg) {
@@ -154,6 +238,33 @@ func WithBlobLimit(limit int64) Opts {
 	}
 }
 
+// WithBlobSpool writes blobs with an unknown digest or size to a temporary file before pushing.
+// This allows a single put request, with the digest and length, when the source is a stream.
+// Blobs larger than max are sent with a chunked upload instead.
+// An empty dir uses the default temporary directory, and a max of 0 disables spooling.
+func WithBlobSpool(dir string, max int64) Opts {
+	return func(r *Reg) {
+		r.blobSpoolDir = dir
+		r.blobSpoolMax = max
+	}
+}
+
+// WithBlobSizeCheck rejects blobs when the received length does not match the descriptor size.
+// The Content-Length header is checked before reading, and reads beyond the descriptor size return an error.
+func WithBlobSizeCheck() Opts {
+	return func(r *Reg) {
+		r.blobSizeCheck = true
+	}
+}
+
+// WithCatalogCache stores repository listings in a directory, revalidating them with the ETag and Last-Modified headers.
+// Registries that return a 304 Not Modified response do not resend the catalog, which is useful for registries with many repositories.
+func WithCatalogCache(dir string) Opts {
+	return func(r *Reg) {
+		r.catalogCacheDir = dir
+	}
+}
+
 // WithCache defines a cache used for various requests
 func WithCache(timeout time.Duration, count int) Opts {
 	return func(r *Reg) {
@@ -219,6 +330,15 @@ func WithLog(log *logrus.Logger) Opts {
 	}
 }
 
+// WithManifestAccept replaces the media types sent in the Accept header of manifest requests.
+// This can exclude OCI types for a misbehaving proxy, or include artifact media types a registry requires for content negotiation.
+// The manifestAccept setting of a host takes precedence.
+func WithManifestAccept(mediaTypes ...string) Opts {
+	return func(r *Reg) {
+		r.manifestAccept = mediaTypes
+	}
+}
+
 // WithManifestMax sets the push and pull limits for manifests
 func WithManifestMax(push, pull int64) Opts {
 	return func(r *Reg) {
@@ -227,6 +347,24 @@ func WithManifestMax(push, pull int64) Opts {
 	}
 }
 
+// WithManifestMaxDesc rejects pulled manifests with more than max descriptors, e.g. an index with an excessive number of children.
+// A max of 0 disables the limit.
+func WithManifestMaxDesc(max int) Opts {
+	return func(r *Reg) {
+		r.manifestMaxDesc = max
+	}
+}
+
+// WithRequestHook adds a function to modify each request before it is sent.
+// This may be used to sign requests (e.g. AWS SigV4 or an HMAC gateway signature) without replacing the transport.
+// Hooks run after the auth headers are set, and again on every retry.
+// Token requests to an auth server are not passed to the hook.
+func WithRequestHook(hook func(*http.Request) error) Opts {
+	return func(r *Reg) {
+		r.reghttpOpts = append(r.reghttpOpts, reghttp.WithRequestHook(hook))
+	}
+}
+
 // WithRetryLimit restricts the number of retries (defaults to 5)
 func WithRetryLimit(l int) Opts {
 	return func(r *Reg) {
@@ -241,6 +379,21 @@ func WithTransport(t *http.Transport) Opts {
 	}
 }
 
+// WithTracerProvider creates a span for each request attempt, including retries
+func WithTracerProvider(tp trace.TracerProvider) Opts {
+	return func(r *Reg) {
+		r.reghttpOpts = append(r.reghttpOpts, reghttp.WithTracer(tp.Tracer(trace.Name)))
+	}
+}
+
+// WithTransportWrap wraps the round tripper used for each registry host.
+// This may be used to record or replay requests, see the cassette package.
+func WithTransportWrap(wrap func(http.RoundTripper) http.RoundTripper) Opts {
+	return func(r *Reg) {
+		r.reghttpOpts = append(r.reghttpOpts, reghttp.WithTransportWrap(wrap))
+	}
+}
+
 // WithUserAgent sets a user agent header
 func WithUserAgent(ua string) Opts {
 	return func(r *Reg) {
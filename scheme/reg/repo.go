@@ -2,12 +2,18 @@ package reg
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
+	"github.com/opencontainers/go-digest"
+	configPkg "github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/reghttp"
 	"github.com/regclient/regclient/scheme"
 	"github.com/regclient/regclient/types"
@@ -22,7 +28,36 @@ func (reg *Reg) RepoList(ctx context.Context, hostname string, opts ...scheme.Re
 	for _, opt := range opts {
 		opt(&config)
 	}
+	if enabled, ok := reg.featureGet(featureCatalog, hostname, ""); ok && !enabled {
+		return nil, fmt.Errorf("catalog is not available on %s%.0w", hostname, types.ErrUnsupportedAPI)
+	}
+	if config.Prefix == "" {
+		return reg.repoListPage(ctx, hostname, config)
+	}
+
+	if config.Last < config.Prefix {
+		// the catalog is sorted, start before the first repository that could match the prefix
+		config.Last = config.Prefix[:len(config.Prefix)-1]
+	}
+	for {
+		rl, err := reg.repoListPage(ctx, hostname, config)
+		if err != nil {
+			return nil, err
+		}
+		names, err := rl.GetRepos()
+		if err != nil {
+			return nil, err
+		}
+		// keep paging over names sorted before the prefix, e.g. "team-a-db" when listing "team-a/"
+		if len(names) == 0 || names[len(names)-1] >= config.Prefix || names[len(names)-1] <= config.Last {
+			return repoListPrefix(rl, config.Prefix, hostname)
+		}
+		config.Last = names[len(names)-1]
+	}
+}
 
+// repoListPage returns a single page of the repository list from the registry.
+func (reg *Reg) repoListPage(ctx context.Context, hostname string, config scheme.RepoConfig) (*repo.RepoList, error) {
 	query := url.Values{}
 	if config.Last != "" {
 		query.Set("last", config.Last)
@@ -34,6 +69,16 @@ func (reg *Reg) RepoList(ctx context.Context, hostname string, opts ...scheme.Re
 	headers := http.Header{
 		"Accept": []string{"application/json"},
 	}
+	cacheKey := hostname + "/_catalog?" + query.Encode()
+	cached := reg.catalogCacheGet(cacheKey)
+	if cached != nil {
+		if cached.ETag != "" {
+			headers.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			headers.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
 	req := &reghttp.Req{
 		Host:      hostname,
 		NoMirrors: true,
@@ -47,24 +92,45 @@ func (reg *Reg) RepoList(ctx context.Context, hostname string, opts ...scheme.Re
 			},
 		},
 	}
+	if u := repoListArtifactoryURL(reg.hostGet(hostname), query); u != nil {
+		req.APIs[configPkg.APIArtifactory] = reghttp.ReqAPI{
+			Method:    "GET",
+			DirectURL: u,
+			Headers:   headers,
+		}
+	}
 	resp, err := reg.reghttp.Do(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list repositories for %s: %w", hostname, err)
 	}
 	defer resp.Close()
-	if resp.HTTPResponse().StatusCode != 200 {
-		return nil, fmt.Errorf("failed to list repositories for %s: %w", hostname, reghttp.HTTPError(resp.HTTPResponse().StatusCode))
-	}
-
-	respBody, err := io.ReadAll(resp)
-	if err != nil {
+	var respBody []byte
+	mt := types.MediaTypeBase(resp.HTTPResponse().Header.Get("Content-Type"))
+	if resp.HTTPResponse().StatusCode == http.StatusNotModified && cached != nil {
 		reg.log.WithFields(logrus.Fields{
-			"err":  err,
 			"host": hostname,
-		}).Warn("Failed to read repo list")
-		return nil, fmt.Errorf("failed to read repo list for %s: %w", hostname, err)
+			"last": config.Last,
+		}).Debug("Repo list not modified, using cache")
+		respBody = cached.Body
+		mt = cached.MediaType
+	} else if resp.HTTPResponse().StatusCode != 200 {
+		return nil, fmt.Errorf("failed to list repositories for %s: %w", hostname, reghttp.HTTPError(resp.HTTPResponse().StatusCode))
+	} else {
+		respBody, err = io.ReadAll(resp)
+		if err != nil {
+			reg.log.WithFields(logrus.Fields{
+				"err":  err,
+				"host": hostname,
+			}).Warn("Failed to read repo list")
+			return nil, fmt.Errorf("failed to read repo list for %s: %w", hostname, err)
+		}
+		reg.catalogCacheSet(cacheKey, catalogCacheEntry{
+			ETag:         resp.HTTPResponse().Header.Get("ETag"),
+			LastModified: resp.HTTPResponse().Header.Get("Last-Modified"),
+			MediaType:    mt,
+			Body:         respBody,
+		})
 	}
-	mt := types.MediaTypeBase(resp.HTTPResponse().Header.Get("Content-Type"))
 	rl, err := repo.New(
 		repo.WithMT(mt),
 		repo.WithRaw(respBody),
@@ -81,3 +147,114 @@ func (reg *Reg) RepoList(ctx context.Context, hostname string, opts ...scheme.Re
 	}
 	return rl, nil
 }
+
+// repoListArtifactoryURL returns the catalog url for an artifactory repository key, or nil for other registries.
+// Artifactory with the repository path method lists the catalog for the repository key with its own docker API.
+func repoListArtifactoryURL(host *configPkg.Host, query url.Values) *url.URL {
+	if host.API != configPkg.APIArtifactory || host.PathPrefix == "" {
+		return nil
+	}
+	u := url.URL{
+		Scheme:   "https",
+		Host:     host.Hostname,
+		Path:     "/artifactory/api/docker/" + host.PathPrefix + "/v2/_catalog",
+		RawQuery: query.Encode(),
+	}
+	if host.TLS == configPkg.TLSDisabled {
+		u.Scheme = "http"
+	}
+	return &u
+}
+
+// repoListPrefix removes repositories that do not start with the prefix
+func repoListPrefix(rl *repo.RepoList, prefix, hostname string) (*repo.RepoList, error) {
+	repos := []string{}
+	for _, name := range rl.Repositories {
+		if strings.HasPrefix(name, prefix) {
+			repos = append(repos, name)
+		}
+	}
+	if len(repos) == len(rl.Repositories) {
+		return rl, nil
+	}
+	raw, err := json.Marshal(repo.RepoRegistryList{Repositories: repos})
+	if err != nil {
+		return nil, err
+	}
+	headers, _ := rl.RawHeaders()
+	return repo.New(
+		repo.WithMT("application/json"),
+		repo.WithRaw(raw),
+		repo.WithHost(hostname),
+		repo.WithHeaders(headers),
+	)
+}
+
+// catalogCacheEntry is a repository list saved for revalidation
+type catalogCacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	MediaType    string `json:"mediaType"`
+	Body         []byte `json:"body"`
+}
+
+func (reg *Reg) catalogCacheFile(key string) string {
+	return filepath.Join(reg.catalogCacheDir, "catalog-"+digest.FromString(key).Encoded()+".json")
+}
+
+// catalogCacheGet returns a saved repository list, or nil if the cache is disabled or missing the entry
+func (reg *Reg) catalogCacheGet(key string) *catalogCacheEntry {
+	if reg.catalogCacheDir == "" {
+		return nil
+	}
+	b, err := os.ReadFile(reg.catalogCacheFile(key))
+	if err != nil {
+		return nil
+	}
+	entry := catalogCacheEntry{}
+	err = json.Unmarshal(b, &entry)
+	if err != nil || (entry.ETag == "" && entry.LastModified == "") {
+		return nil
+	}
+	return &entry
+}
+
+// catalogCacheSet saves a repository list when the registry returned a validator, errors are logged and ignored
+func (reg *Reg) catalogCacheSet(key string, entry catalogCacheEntry) {
+	if reg.catalogCacheDir == "" || (entry.ETag == "" && entry.LastModified == "") {
+		return
+	}
+	err := func() error {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(reg.catalogCacheDir, 0700)
+		if err != nil {
+			return err
+		}
+		// write to a temp file and rename to avoid partial reads from concurrent processes
+		fh, err := os.CreateTemp(reg.catalogCacheDir, "catalog-*.tmp")
+		if err != nil {
+			return err
+		}
+		_, err = fh.Write(b)
+		errC := fh.Close()
+		if err == nil {
+			err = errC
+		}
+		if err == nil {
+			err = os.Rename(fh.Name(), reg.catalogCacheFile(key))
+		}
+		if err != nil {
+			_ = os.Remove(fh.Name())
+		}
+		return err
+	}()
+	if err != nil {
+		reg.log.WithFields(logrus.Fields{
+			"dir": reg.catalogCacheDir,
+			"err": err,
+		}).Debug("Failed to save repo list cache")
+	}
+}
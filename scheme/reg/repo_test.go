@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -255,3 +256,178 @@ func TestRepo(t *testing.T) {
 		// error is a json error, no custom error type was made for this yet
 	})
 }
+
+func TestRepoCatalogCache(t *testing.T) {
+	ctx := context.Background()
+	listRegistry := []string{
+		"library/alpine",
+		"library/busybox",
+		"team-a-cache",
+		"team-a-web",
+		"team-a/app",
+		"team-a/db",
+		"team-b/app",
+	}
+	etag := `"catalog-1"`
+	full, notModified := 0, 0
+	lastReq := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method != http.MethodGet || r.URL.Path != "/v2/_catalog" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		lastReq = r.URL.Query().Get("last")
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		repos := []string{}
+		for _, name := range listRegistry {
+			if name > lastReq {
+				repos = append(repos, name)
+			}
+		}
+		if n, err := strconv.Atoi(r.URL.Query().Get("n")); err == nil && n < len(repos) {
+			repos = repos[:n]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"repositories":["%s"]}`, strings.Join(repos, `","`))
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	reg := New(
+		WithConfigHosts([]*config.Host{{Name: tsHost, Hostname: tsHost, TLS: config.TLSDisabled}}),
+		WithLog(log),
+		WithCatalogCache(t.TempDir()),
+	)
+	t.Run("Revalidate", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			rl, err := reg.RepoList(ctx, tsHost)
+			if err != nil {
+				t.Fatalf("error listing repos: %v", err)
+			}
+			rlRepos, err := rl.GetRepos()
+			if err != nil {
+				t.Fatalf("error retrieving repos: %v", err)
+			}
+			if !stringSliceCmp(listRegistry, rlRepos) {
+				t.Errorf("repositories do not match: expected %v, received %v", listRegistry, rlRepos)
+			}
+		}
+		if full != 1 || notModified != 1 {
+			t.Errorf("unexpected requests, full %d, not modified %d", full, notModified)
+		}
+	})
+	t.Run("Prefix", func(t *testing.T) {
+		rl, err := reg.RepoList(ctx, tsHost, scheme.WithRepoPrefix("team-a/"))
+		if err != nil {
+			t.Fatalf("error listing repos: %v", err)
+		}
+		rlRepos, err := rl.GetRepos()
+		if err != nil {
+			t.Fatalf("error retrieving repos: %v", err)
+		}
+		expect := []string{"team-a/app", "team-a/db"}
+		if !stringSliceCmp(expect, rlRepos) {
+			t.Errorf("repositories do not match: expected %v, received %v", expect, rlRepos)
+		}
+		if lastReq != "team-a" {
+			t.Errorf("listing did not start at the prefix, last %s", lastReq)
+		}
+	})
+	t.Run("Prefix Paging", func(t *testing.T) {
+		rl, err := reg.RepoList(ctx, tsHost, scheme.WithRepoPrefix("team-a/"), scheme.WithRepoLimit(2))
+		if err != nil {
+			t.Fatalf("error listing repos: %v", err)
+		}
+		rlRepos, err := rl.GetRepos()
+		if err != nil {
+			t.Fatalf("error retrieving repos: %v", err)
+		}
+		expect := []string{"team-a/app", "team-a/db"}
+		if !stringSliceCmp(expect, rlRepos) {
+			t.Errorf("repositories do not match: expected %v, received %v", expect, rlRepos)
+		}
+		rl, err = reg.RepoList(ctx, tsHost, scheme.WithRepoPrefix("team-a/"), scheme.WithRepoLimit(2), scheme.WithRepoLast("team-a/db"))
+		if err != nil {
+			t.Fatalf("error listing repos: %v", err)
+		}
+		rlRepos, err = rl.GetRepos()
+		if err != nil {
+			t.Fatalf("error retrieving repos: %v", err)
+		}
+		if len(rlRepos) != 0 {
+			t.Errorf("unexpected repositories past the prefix: %v", rlRepos)
+		}
+	})
+}
+
+func TestRepoAPIProfile(t *testing.T) {
+	ctx := context.Background()
+	reqPaths := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqPaths = append(reqPaths, r.URL.Path)
+		if r.URL.Path != "/artifactory/api/docker/docker-local/v2/_catalog" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"repositories":["app","db"]}`)
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	reg := New(
+		WithConfigHosts([]*config.Host{
+			{Name: "ghcr." + tsHost, Hostname: tsHost, TLS: config.TLSDisabled, API: config.APIGHCR},
+			{Name: "artifactory." + tsHost, Hostname: tsHost, TLS: config.TLSDisabled, API: config.APIArtifactory, PathPrefix: "docker-local"},
+		}),
+		WithLog(log),
+	)
+	t.Run("No catalog", func(t *testing.T) {
+		reqPaths = []string{}
+		_, err := reg.RepoList(ctx, "ghcr."+tsHost)
+		if !errors.Is(err, types.ErrUnsupportedAPI) {
+			t.Errorf("expected unsupported API, received %v", err)
+		}
+		if len(reqPaths) > 0 {
+			t.Errorf("requests sent to registry without a catalog: %v", reqPaths)
+		}
+	})
+	t.Run("Artifactory", func(t *testing.T) {
+		rl, err := reg.RepoList(ctx, "artifactory."+tsHost)
+		if err != nil {
+			t.Fatalf("error listing repos: %v", err)
+		}
+		rlRepos, err := rl.GetRepos()
+		if err != nil {
+			t.Fatalf("error retrieving repos: %v", err)
+		}
+		expect := []string{"app", "db"}
+		if !stringSliceCmp(expect, rlRepos) {
+			t.Errorf("repositories do not match: expected %v, received %v", expect, rlRepos)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package reg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"text/tabwriter"
+
+	configPkg "github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/reghttp"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/sirupsen/logrus"
+)
+
+// SearchResult is a repository matching a search
+type SearchResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Stars       int64  `json:"stars"`
+	Pulls       int64  `json:"pulls"` // not reported by quay
+	Official    bool   `json:"official,omitempty"`
+}
+
+// SearchList is the result of a repository search on a registry
+type SearchList struct {
+	Registry string         `json:"registry"`
+	Query    string         `json:"query"`
+	Results  []SearchResult `json:"results"`
+}
+
+// MarshalPretty outputs the search results in a human readable format
+func (sl SearchList) MarshalPretty() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name\tStars\tPulls\tOfficial\tDescription\n")
+	for _, sr := range sl.Results {
+		official := ""
+		if sr.Official {
+			official = "[OK]"
+		}
+		desc := sr.Description
+		if len(desc) > 60 {
+			desc = desc[:57] + "..."
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", sr.Name, sr.Stars, sr.Pulls, official, desc)
+	}
+	err := tw.Flush()
+	return buf.Bytes(), err
+}
+
+// hubSearch is the response from the Docker Hub search API
+type hubSearch struct {
+	Results []struct {
+		RepoName         string `json:"repo_name"`
+		ShortDescription string `json:"short_description"`
+		StarCount        int64  `json:"star_count"`
+		PullCount        int64  `json:"pull_count"`
+		IsOfficial       bool   `json:"is_official"`
+	} `json:"results"`
+}
+
+// harborSearch is the response from the Harbor search API
+type harborSearch struct {
+	Repository []struct {
+		RepositoryName string `json:"repository_name"`
+		PullCount      int64  `json:"pull_count"`
+	} `json:"repository"`
+}
+
+// quaySearch is the response from the Quay repository search API
+type quaySearch struct {
+	Results []struct {
+		Name      string `json:"name"`
+		Namespace struct {
+			Name string `json:"name"`
+		} `json:"namespace"`
+		Description string `json:"description"`
+		Stars       int64  `json:"stars"`
+	} `json:"results"`
+}
+
+// RepoSearch returns repositories on a registry matching a query.
+// The search is supported on Docker Hub, and registries configured with the harbor or quay API profile.
+// WithRepoLimit sets the maximum number of results.
+func (reg *Reg) RepoSearch(ctx context.Context, hostname, query string, opts ...scheme.RepoOpts) (SearchList, error) {
+	config := scheme.RepoConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	sl := SearchList{Registry: hostname, Query: query, Results: []SearchResult{}}
+	host := reg.hostGet(hostname)
+	api := host.API
+	if hostname == configPkg.DockerRegistry {
+		api = configPkg.APIHub
+	}
+	var u url.URL
+	switch api {
+	case configPkg.APIHub:
+		u = hubURL
+		u.Path = "/v2/search/repositories/"
+		q := url.Values{"query": []string{query}}
+		if config.Limit > 0 {
+			q.Set("page_size", strconv.Itoa(config.Limit))
+		}
+		u.RawQuery = q.Encode()
+	case configPkg.APIHarbor, configPkg.APIQuay:
+		u = url.URL{Scheme: "https", Host: host.Hostname}
+		if host.TLS == configPkg.TLSDisabled {
+			u.Scheme = "http"
+		}
+		if api == configPkg.APIHarbor {
+			u.Path = "/api/v2.0/search"
+			u.RawQuery = url.Values{"q": []string{query}}.Encode()
+		} else {
+			u.Path = "/api/v1/find/repositories"
+			u.RawQuery = url.Values{"query": []string{query}}.Encode()
+		}
+	default:
+		return sl, fmt.Errorf("repository search is not available on %s%.0w", hostname, types.ErrUnsupportedAPI)
+	}
+	req := &reghttp.Req{
+		Host:      hostname,
+		NoMirrors: true,
+		APIs: map[string]reghttp.ReqAPI{
+			"": {
+				Method:    "GET",
+				DirectURL: &u,
+				Headers: http.Header{
+					"Accept": []string{"application/json"},
+				},
+			},
+		},
+	}
+	resp, err := reg.reghttp.Do(ctx, req)
+	if err != nil {
+		return sl, fmt.Errorf("failed to search repositories on %s: %w", hostname, err)
+	}
+	defer resp.Close()
+	if resp.HTTPResponse().StatusCode != 200 {
+		return sl, fmt.Errorf("failed to search repositories on %s: %w", hostname, reghttp.HTTPError(resp.HTTPResponse().StatusCode))
+	}
+	body, err := io.ReadAll(resp)
+	if err != nil {
+		return sl, fmt.Errorf("failed to read search results from %s: %w", hostname, err)
+	}
+	switch api {
+	case configPkg.APIHub:
+		hs := hubSearch{}
+		err = json.Unmarshal(body, &hs)
+		for _, r := range hs.Results {
+			sl.Results = append(sl.Results, SearchResult{
+				Name:        r.RepoName,
+				Description: r.ShortDescription,
+				Stars:       r.StarCount,
+				Pulls:       r.PullCount,
+				Official:    r.IsOfficial,
+			})
+		}
+	case configPkg.APIHarbor:
+		hs := harborSearch{}
+		err = json.Unmarshal(body, &hs)
+		for _, r := range hs.Repository {
+			sl.Results = append(sl.Results, SearchResult{
+				Name:  r.RepositoryName,
+				Pulls: r.PullCount,
+			})
+		}
+	case configPkg.APIQuay:
+		qs := quaySearch{}
+		err = json.Unmarshal(body, &qs)
+		for _, r := range qs.Results {
+			sl.Results = append(sl.Results, SearchResult{
+				Name:        r.Namespace.Name + "/" + r.Name,
+				Description: r.Description,
+				Stars:       r.Stars,
+			})
+		}
+	}
+	if err != nil {
+		reg.log.WithFields(logrus.Fields{
+			"err":  err,
+			"body": string(body),
+			"host": hostname,
+		}).Debug("Failed to parse search results")
+		return sl, fmt.Errorf("failed to parse search results from %s: %w", hostname, err)
+	}
+	if config.Limit > 0 && len(sl.Results) > config.Limit {
+		sl.Results = sl.Results[:config.Limit]
+	}
+	return sl, nil
+}
@@ -0,0 +1,87 @@
+package reg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+)
+
+func TestRepoSearch(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v2/search/repositories/" && r.URL.Query().Get("query") == "alpine":
+			if r.URL.Query().Get("page_size") != "2" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"count":3,"results":[{"repo_name":"alpine","short_description":"A minimal Docker image","star_count":10,"pull_count":1000,"is_official":true},{"repo_name":"example/alpine-tools","short_description":"Tools","star_count":2,"pull_count":50,"is_official":false}]}`)
+		case r.URL.Path == "/api/v2.0/search" && r.URL.Query().Get("q") == "nginx":
+			fmt.Fprint(w, `{"project":[],"repository":[{"repository_name":"library/nginx","project_name":"library","pull_count":5,"artifact_count":2}]}`)
+		case r.URL.Path == "/api/v1/find/repositories" && r.URL.Query().Get("query") == "redis":
+			fmt.Fprint(w, `{"results":[{"kind":"repository","name":"redis","namespace":{"name":"example"},"description":"Redis server","stars":3,"popularity":7},{"kind":"repository","name":"redis-exporter","namespace":{"name":"example"},"stars":1}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	hubURLOrig := hubURL
+	hubURL = url.URL{Scheme: "http", Host: tsURL.Host}
+	defer func() { hubURL = hubURLOrig }()
+	reg := New(WithConfigHosts([]*config.Host{
+		{Name: "harbor.example.org", Hostname: tsURL.Host, TLS: config.TLSDisabled, API: config.APIHarbor},
+		{Name: "quay.example.org", Hostname: tsURL.Host, TLS: config.TLSDisabled, API: config.APIQuay},
+		{Name: "registry.example.org", Hostname: tsURL.Host, TLS: config.TLSDisabled},
+	}))
+	t.Run("Hub", func(t *testing.T) {
+		sl, err := reg.RepoSearch(ctx, config.DockerRegistry, "alpine", scheme.WithRepoLimit(2))
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		if len(sl.Results) != 2 {
+			t.Fatalf("unexpected results: %v", sl.Results)
+		}
+		if sl.Results[0].Name != "alpine" || sl.Results[0].Stars != 10 || sl.Results[0].Pulls != 1000 || !sl.Results[0].Official || sl.Results[0].Description != "A minimal Docker image" {
+			t.Errorf("unexpected result: %v", sl.Results[0])
+		}
+		out, err := sl.MarshalPretty()
+		if err != nil || !strings.Contains(string(out), "example/alpine-tools") {
+			t.Errorf("unexpected pretty output: %s, %v", out, err)
+		}
+	})
+	t.Run("Harbor", func(t *testing.T) {
+		sl, err := reg.RepoSearch(ctx, "harbor.example.org", "nginx")
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		if len(sl.Results) != 1 || sl.Results[0].Name != "library/nginx" || sl.Results[0].Pulls != 5 {
+			t.Errorf("unexpected results: %v", sl.Results)
+		}
+	})
+	t.Run("Quay", func(t *testing.T) {
+		sl, err := reg.RepoSearch(ctx, "quay.example.org", "redis", scheme.WithRepoLimit(1))
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		if len(sl.Results) != 1 || sl.Results[0].Name != "example/redis" || sl.Results[0].Stars != 3 || sl.Results[0].Pulls != 0 || sl.Results[0].Description != "Redis server" {
+			t.Errorf("unexpected results: %v", sl.Results)
+		}
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := reg.RepoSearch(ctx, "registry.example.org", "alpine")
+		if !errors.Is(err, types.ErrUnsupportedAPI) {
+			t.Errorf("expected unsupported API, received %v", err)
+		}
+	})
+}
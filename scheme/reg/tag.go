@@ -17,6 +17,7 @@ import (
 	_ "crypto/sha512"
 
 	"github.com/opencontainers/go-digest"
+	configPkg "github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/httplink"
 	"github.com/regclient/regclient/internal/reghttp"
 	"github.com/regclient/regclient/scheme"
@@ -34,12 +35,20 @@ import (
 // It first attempts the newer OCI API to delete by tag name (not widely supported).
 // If the OCI API fails, it falls back to pushing a unique empty manifest and deleting that.
 func (reg *Reg) TagDelete(ctx context.Context, r ref.Ref) error {
+	if err := refValidate(r); err != nil {
+		return err
+	}
 	var tempManifest manifest.Manifest
 	if r.Tag == "" {
 		return types.ErrMissingTag
 	}
 
-	// attempt to delete the tag directly, available in OCI distribution-spec, and Hub API
+	// Docker Hub deletes tags with the repository API
+	if reg.hostGet(r.Registry).API == configPkg.APIHub {
+		return reg.hubTagDelete(ctx, r)
+	}
+
+	// attempt to delete the tag directly, available in OCI distribution-spec
 	req := &reghttp.Req{
 		Host:      r.Registry,
 		NoMirrors: true,
@@ -50,20 +59,25 @@ func (reg *Reg) TagDelete(ctx context.Context, r ref.Ref) error {
 				Path:       "manifests/" + r.Tag,
 				IgnoreErr:  true, // do not trigger backoffs if this fails
 			},
-			"hub": {
-				Method: "DELETE",
-				Path:   "repositories/" + r.Repository + "/tags/" + r.Tag + "/",
-			},
 		},
 	}
 
-	resp, err := reg.reghttp.Do(ctx, req)
-	if resp != nil {
-		defer resp.Close()
-	}
-	// TODO: Hub may return a different status
-	if err == nil && resp != nil && resp.HTTPResponse().StatusCode == 202 {
-		return nil
+	// skip the delete request when the registry is known to not support deleting tags
+	if enabled, ok := reg.featureGet(featureTagDelete, r.Registry, ""); !ok || enabled {
+		resp, err := reg.reghttp.Do(ctx, req)
+		if resp != nil {
+			defer resp.Close()
+		}
+		if err == nil && resp != nil && resp.HTTPResponse().StatusCode == 202 {
+			reg.featureSet(featureTagDelete, r.Registry, "", true)
+			return nil
+		}
+		if resp != nil && resp.HTTPResponse() != nil {
+			switch resp.HTTPResponse().StatusCode {
+			case http.StatusBadRequest, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+				reg.featureSet(featureTagDelete, r.Registry, "", false)
+			}
+		}
 	}
 	// ignore errors, fallback to creating a temporary manifest to replace the tag and deleting that manifest
 
@@ -198,6 +212,9 @@ func (reg *Reg) TagDelete(ctx context.Context, r ref.Ref) error {
 
 // TagList returns a listing to tags from the repository
 func (reg *Reg) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts) (*tag.List, error) {
+	if err := refValidate(r); err != nil {
+		return nil, err
+	}
 	var config scheme.TagConfig
 	for _, opt := range opts {
 		opt(&config)
@@ -154,9 +154,10 @@ func TestTag(t *testing.T) {
 		},
 		{
 			ReqEntry: reqresp.ReqEntry{
-				Name:   "delete fallback tag",
-				Method: "DELETE",
-				Path:   "/v2" + repoPath + "/manifests/" + delFallbackTag,
+				Name:     "delete fallback tag",
+				DelOnUse: true,
+				Method:   "DELETE",
+				Path:     "/v2" + repoPath + "/manifests/" + delFallbackTag,
 			},
 			RespEntry: reqresp.RespEntry{
 				Status: http.StatusBadRequest,
@@ -384,4 +385,17 @@ func TestTag(t *testing.T) {
 			return
 		}
 	})
+
+	// delete tag again, unsupported tag delete API should be cached and skipped
+	t.Run("Delete Fallback Cached", func(t *testing.T) {
+		delRef, err := ref.New(tsURL.Host + repoPath + ":" + delFallbackTag)
+		if err != nil {
+			t.Errorf("failed creating delRef: %v", err)
+		}
+		err = reg.TagDelete(ctx, delRef)
+		if err != nil {
+			t.Errorf("failed to delete tag: %v", err)
+			return
+		}
+	})
 }
@@ -0,0 +1,59 @@
+package scheme
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+// Config contains the settings of the RegClient creating a scheme
+type Config struct {
+	Hosts     []*config.Host
+	FS        rwfs.RWFS
+	Log       *logrus.Logger
+	UserAgent string
+}
+
+// Factory creates the API for a scheme, called once for each RegClient
+type Factory func(conf Config) API
+
+var (
+	factoriesMu sync.Mutex
+	factories   = map[string]Factory{}
+)
+
+// Register adds a scheme to every RegClient created after the call.
+// This is typically called from the init function of the package implementing the scheme,
+// allowing packages outside of regclient to add a backend, e.g. "s3://bucket/layout:tag".
+// References with the scheme are parsed as a path with an optional tag and digest.
+// The "reg" scheme is reserved, and Register panics if the name is already registered or the factory is nil.
+func Register(name string, f Factory) {
+	if f == nil {
+		panic("scheme: Register factory is nil for " + name)
+	}
+	if name == "" || name == "reg" {
+		panic(fmt.Sprintf("scheme: Register called with a reserved name \"%s\"", name))
+	}
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic("scheme: Register called twice for " + name)
+	}
+	factories[name] = f
+	ref.RegisterPathScheme(name)
+}
+
+// Registered returns the factories for each registered scheme
+func Registered() map[string]Factory {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	ret := make(map[string]Factory, len(factories))
+	for name, f := range factories {
+		ret[name] = f
+	}
+	return ret
+}
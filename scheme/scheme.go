@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient/internal/throttle"
 	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/blob"
@@ -52,6 +53,24 @@ type Closer interface {
 	Close(ctx context.Context, r ref.Ref) error
 }
 
+// BlobUploadCanceler is used by schemes with upload sessions that may be canceled
+type BlobUploadCanceler interface {
+	// BlobUploadCancel deletes an in-progress upload session, the location is the upload URL or session UUID.
+	BlobUploadCancel(ctx context.Context, r ref.Ref, location string) error
+}
+
+// GarbageCollector is used by schemes that can remove unreferenced content
+type GarbageCollector interface {
+	// GarbageCollect removes blobs that are not referenced by any manifest in the repository, returning the removed digests.
+	GarbageCollect(ctx context.Context, r ref.Ref, opts ...GCOpts) ([]digest.Digest, error)
+}
+
+// Verifier is used by schemes that can verify and repair the stored content
+type Verifier interface {
+	// Verify recomputes the digest of each stored blob and checks the index, optionally repairing any problems found.
+	Verify(ctx context.Context, r ref.Ref, opts ...VerifyOpts) (VerifyReport, error)
+}
+
 // GCLocker is used to indicate locking is available for GC management
 type GCLocker interface {
 	// GCLock a reference to prevent GC from triggering during a put, locks are not exclusive.
@@ -202,10 +221,54 @@ func WithReferrerSort(annotation string, desc bool) ReferrerOpts {
 	}
 }
 
+// GCConfig is used by schemes to import GCOpts
+type GCConfig struct {
+	DryRun bool
+}
+
+// GCOpts is used to set options on garbage collection APIs
+type GCOpts func(*GCConfig)
+
+// WithGCDryRun lists the content that would be removed without deleting it
+func WithGCDryRun() GCOpts {
+	return func(config *GCConfig) {
+		config.DryRun = true
+	}
+}
+
+// VerifyConfig is used by schemes to import VerifyOpts
+type VerifyConfig struct {
+	Repair bool
+}
+
+// VerifyOpts is used to set options on verify APIs
+type VerifyOpts func(*VerifyConfig)
+
+// WithVerifyRepair fixes the problems found when verifying content.
+// Blobs stored under the wrong digest are renamed, other corrupt blobs are quarantined,
+// and index entries referencing missing content are removed.
+func WithVerifyRepair() VerifyOpts {
+	return func(config *VerifyConfig) {
+		config.Repair = true
+	}
+}
+
+// VerifyReport lists the problems found, and the repairs made, when verifying content
+type VerifyReport struct {
+	Blobs        int                             `json:"blobs"`                  // number of blobs hashed
+	Corrupt      []digest.Digest                 `json:"corrupt,omitempty"`      // blobs with content that does not match the digest
+	Renamed      map[digest.Digest]digest.Digest `json:"renamed,omitempty"`      // corrupt blobs renamed to the digest of their content
+	Quarantined  []digest.Digest                 `json:"quarantined,omitempty"`  // corrupt blobs moved out of the blob store
+	Missing      []digest.Digest                 `json:"missing,omitempty"`      // content referenced by the index or a manifest that is not stored
+	IndexRemoved []types.Descriptor              `json:"indexRemoved,omitempty"` // index entries removed because the manifest is missing
+	IndexFixed   []types.Descriptor              `json:"indexFixed,omitempty"`   // index entries updated with the size of the stored manifest
+}
+
 // RepoConfig is used by schemes to import RepoOpts
 type RepoConfig struct {
-	Limit int
-	Last  string
+	Limit  int
+	Last   string
+	Prefix string
 }
 
 // RepoOpts is used to set options on repo APIs
@@ -227,6 +290,15 @@ func WithRepoLast(l string) RepoOpts {
 	}
 }
 
+// WithRepoPrefix limits the repository list to repositories starting with the prefix.
+// The listing starts at the prefix in the sorted catalog, and repositories outside of the prefix are removed.
+// An empty list is returned once the catalog is past the prefix.
+func WithRepoPrefix(p string) RepoOpts {
+	return func(config *RepoConfig) {
+		config.Prefix = p
+	}
+}
+
 // TagConfig is used by schemes to import TagOpts
 type TagConfig struct {
 	Limit int
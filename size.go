@@ -0,0 +1,127 @@
+package regclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/units"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+)
+
+// ImageSizePlatform is the size of a single image within an index
+type ImageSizePlatform struct {
+	Platform *platform.Platform `json:"platform,omitempty"`
+	Digest   digest.Digest      `json:"digest"`
+	Manifest int64              `json:"manifest"` // size of the image manifest
+	Config   int64              `json:"config"`   // size of the config blob
+	Layers   int64              `json:"layers"`   // sum of the layer sizes, the compressed size pulled by a runtime
+	Total    int64              `json:"total"`    // sum of the manifest, config, and layers
+}
+
+// ImageSizeReport contains the size of each platform and the deduplicated total for an image
+type ImageSizeReport struct {
+	Digest    digest.Digest       `json:"digest"`
+	Platforms []ImageSizePlatform `json:"platforms"`
+	Total     int64               `json:"total"` // size of each manifest and unique blob, blobs shared between platforms are counted once
+}
+
+// ImageSize returns the size of an image, summing the layers of each platform.
+// The total counts each manifest and blob once, reporting the storage used by the image in the registry.
+func (rc *RegClient) ImageSize(ctx context.Context, r ref.Ref) (ImageSizeReport, error) {
+	report := ImageSizeReport{
+		Platforms: []ImageSizePlatform{},
+	}
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		return report, err
+	}
+	report.Digest = m.GetDescriptor().Digest
+	seen := map[digest.Digest]bool{}
+	err = rc.imageSizeAdd(ctx, r, m, nil, seen, &report)
+	return report, err
+}
+
+func (rc *RegClient) imageSizeAdd(ctx context.Context, r ref.Ref, m manifest.Manifest, p *platform.Platform, seen map[digest.Digest]bool, report *ImageSizeReport) error {
+	mDesc := m.GetDescriptor()
+	if !seen[mDesc.Digest] {
+		seen[mDesc.Digest] = true
+		report.Total += mDesc.Size
+	}
+	if mi, ok := m.(manifest.Indexer); ok {
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return fmt.Errorf("failed to get manifest list %s: %w", r.CommonName(), err)
+		}
+		for _, d := range dl {
+			rChild := r
+			rChild.Tag = ""
+			rChild.Digest = d.Digest.String()
+			mChild, err := rc.ManifestGet(ctx, rChild, WithManifestDesc(d))
+			if err != nil {
+				return fmt.Errorf("failed to get manifest %s: %w", rChild.CommonName(), err)
+			}
+			err = rc.imageSizeAdd(ctx, rChild, mChild, d.Platform, seen, report)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	mi, ok := m.(manifest.Imager)
+	if !ok {
+		return fmt.Errorf("manifest is not an image or index: %s%.0w", mDesc.MediaType, types.ErrUnsupportedMediaType)
+	}
+	ps := ImageSizePlatform{
+		Platform: p,
+		Digest:   mDesc.Digest,
+		Manifest: mDesc.Size,
+	}
+	// schema1 manifests do not have a config
+	if cd, err := mi.GetConfig(); err == nil {
+		ps.Config = cd.Size
+		if !seen[cd.Digest] {
+			seen[cd.Digest] = true
+			report.Total += cd.Size
+		}
+	}
+	layers, err := mi.GetLayers()
+	if err != nil {
+		return fmt.Errorf("failed to get layers %s: %w", r.CommonName(), err)
+	}
+	for _, layer := range layers {
+		ps.Layers += layer.Size
+		if !seen[layer.Digest] {
+			seen[layer.Digest] = true
+			report.Total += layer.Size
+		}
+	}
+	ps.Total = ps.Manifest + ps.Config + ps.Layers
+	report.Platforms = append(report.Platforms, ps)
+	return nil
+}
+
+// MarshalPretty outputs a table of the size of each platform and the total.
+func (report ImageSizeReport) MarshalPretty() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Platform\tDigest\tLayers\tTotal\n")
+	for _, ps := range report.Platforms {
+		pStr := ""
+		if ps.Platform != nil {
+			pStr = ps.Platform.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", pStr, ps.Digest.String(), units.HumanSize(float64(ps.Layers)), units.HumanSize(float64(ps.Total)))
+	}
+	fmt.Fprintf(tw, "Total\t%s\t\t%s\n", report.Digest.String(), units.HumanSize(float64(report.Total)))
+	err := tw.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,106 @@
+package regclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestImageSize(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	r, err := ref.New("ocidir://testrepo:v1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		t.Errorf("failed to get manifest: %v", err)
+		return
+	}
+	dl, err := m.(manifest.Indexer).GetManifestList()
+	if err != nil {
+		t.Errorf("failed to get manifest list: %v", err)
+		return
+	}
+	// compute the expected sizes from the descriptors
+	expectTotal := m.GetDescriptor().Size
+	seen := map[digest.Digest]bool{}
+	for _, d := range dl {
+		if !seen[d.Digest] {
+			seen[d.Digest] = true
+			expectTotal += d.Size
+		}
+		rChild := r
+		rChild.Tag = ""
+		rChild.Digest = d.Digest.String()
+		mChild, err := rc.ManifestGet(ctx, rChild)
+		if err != nil {
+			t.Errorf("failed to get manifest %s: %v", d.Digest, err)
+			return
+		}
+		mi := mChild.(manifest.Imager)
+		cd, err := mi.GetConfig()
+		if err != nil {
+			t.Errorf("failed to get config: %v", err)
+			return
+		}
+		layers, err := mi.GetLayers()
+		if err != nil {
+			t.Errorf("failed to get layers: %v", err)
+			return
+		}
+		for _, d := range append(layers, cd) {
+			if !seen[d.Digest] {
+				seen[d.Digest] = true
+				expectTotal += d.Size
+			}
+		}
+	}
+
+	report, err := rc.ImageSize(ctx, r)
+	if err != nil {
+		t.Errorf("failed to get image size: %v", err)
+		return
+	}
+	if report.Digest != m.GetDescriptor().Digest {
+		t.Errorf("unexpected digest, expected %s, received %s", m.GetDescriptor().Digest, report.Digest)
+	}
+	if len(report.Platforms) != len(dl) {
+		t.Errorf("unexpected platform count, expected %d, received %d", len(dl), len(report.Platforms))
+		return
+	}
+	sum := m.GetDescriptor().Size
+	for i, ps := range report.Platforms {
+		if ps.Digest != dl[i].Digest || ps.Manifest != dl[i].Size {
+			t.Errorf("platform %d mismatch, expected %s, received %s", i, dl[i].Digest, ps.Digest)
+		}
+		if ps.Layers == 0 || ps.Total != ps.Manifest+ps.Config+ps.Layers {
+			t.Errorf("platform %d sizes are invalid: %v", i, ps)
+		}
+		sum += ps.Total
+	}
+	if report.Total != expectTotal {
+		t.Errorf("unexpected total, expected %d, received %d", expectTotal, report.Total)
+	}
+	// the index lists the same image more than once
+	if report.Total >= sum {
+		t.Errorf("total was not deduplicated, total %d, sum of platforms %d", report.Total, sum)
+	}
+	out, err := report.MarshalPretty()
+	if err != nil || len(out) == 0 {
+		t.Errorf("failed to marshal report: %v", err)
+	}
+}
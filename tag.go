@@ -2,28 +2,96 @@ package regclient
 
 import (
 	"context"
+	"fmt"
+	"regexp"
 
+	"github.com/regclient/regclient/pkg/trace"
 	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
 	"github.com/regclient/regclient/types/ref"
 	"github.com/regclient/regclient/types/tag"
 )
 
+var tagRE = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+
+// TagCopy pushes the existing manifest of r to a new tag without pulling or pushing any layers.
+// The newTag may be a tag in the same repository, or a reference to another repository on the same registry.
+// When copying to another repository, any missing child manifests and blobs are mounted from the source repository.
+func (rc *RegClient) TagCopy(ctx context.Context, r ref.Ref, newTag string) (err error) {
+	ctx, span := rc.traceStart(ctx, "TagCopy", r)
+	defer func() { trace.End(span, err) }()
+	rTgt := r
+	rTgt.Digest = ""
+	if tagRE.MatchString(newTag) {
+		rTgt.Tag = newTag
+	} else {
+		rTgt, err = ref.New(newTag)
+		if err != nil {
+			return err
+		}
+		if rTgt.Tag == "" || rTgt.Digest != "" {
+			return fmt.Errorf("target must be a tag: %s%.0w", newTag, types.ErrMissingTag)
+		}
+		if !ref.EqualRegistry(r, rTgt) {
+			return fmt.Errorf("target must be on the same registry, use ImageCopy between registries: %s%.0w", rTgt.CommonName(), types.ErrInvalidReference)
+		}
+	}
+	rTgt.Reference = rTgt.CommonName()
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		return err
+	}
+	if ref.EqualRepository(r, rTgt) {
+		return rc.ManifestPut(ctx, rTgt, m)
+	}
+	return rc.ManifestPut(ctx, rTgt, m, WithManifestChildSource(r))
+}
+
 // TagDelete deletes a tag from the registry. Since there's no API for this,
 // you'd want to normally just delete the manifest. However multiple tags may
 // point to the same manifest, so instead you must:
 // 1. Make a manifest, for this we put a few labels and timestamps to be unique.
 // 2. Push that manifest to the tag.
 // 3. Delete the digest for that new manifest that is only used by that tag.
-func (rc *RegClient) TagDelete(ctx context.Context, r ref.Ref) error {
+func (rc *RegClient) TagDelete(ctx context.Context, r ref.Ref, opts ...TagDeleteOpts) (err error) {
+	ctx, span := rc.traceStart(ctx, "TagDelete", r)
+	defer func() { trace.End(span, err) }()
+	var opt tagDeleteOpt
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
 	schemeAPI, err := rc.schemeGet(r.Scheme)
 	if err != nil {
 		return err
 	}
-	return schemeAPI.TagDelete(ctx, r)
+	err = schemeAPI.TagDelete(ctx, r)
+	if opt.ignoreMissing {
+		err = ignoreMissing(err, func() error {
+			_, err := schemeAPI.ManifestHead(ctx, r)
+			return err
+		})
+	}
+	return err
+}
+
+type tagDeleteOpt struct {
+	ignoreMissing bool
+}
+
+// TagDeleteOpts define options for TagDelete
+type TagDeleteOpts func(*tagDeleteOpt)
+
+// TagDeleteWithIgnoreMissing treats a missing tag as a successful TagDelete
+func TagDeleteWithIgnoreMissing() TagDeleteOpts {
+	return func(opts *tagDeleteOpt) {
+		opts.ignoreMissing = true
+	}
 }
 
 // TagList returns a tag list from a repository
-func (rc *RegClient) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts) (*tag.List, error) {
+func (rc *RegClient) TagList(ctx context.Context, r ref.Ref, opts ...scheme.TagOpts) (tl *tag.List, err error) {
+	ctx, span := rc.traceStart(ctx, "TagList", r)
+	defer func() { trace.End(span, err) }()
 	schemeAPI, err := rc.schemeGet(r.Scheme)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,179 @@
+package regclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/internal/reqresp"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/docker/schema2"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTagCopy(t *testing.T) {
+	ctx := context.Background()
+	srcRepo := "/proj"
+	tgtRepo := "/copy"
+	digest1 := digest.FromString("example1")
+	digest2 := digest.FromString("example2")
+	m := schema2.Manifest{
+		Versioned: schema2.ManifestSchemaVersion,
+		Config: types.Descriptor{
+			MediaType: types.MediaTypeDocker2ImageConfig,
+			Size:      8,
+			Digest:    digest1,
+		},
+		Layers: []types.Descriptor{
+			{
+				MediaType: types.MediaTypeDocker2LayerGzip,
+				Size:      8,
+				Digest:    digest2,
+			},
+		},
+	}
+	mBody, err := json.Marshal(m)
+	if err != nil {
+		t.Errorf("failed to marshal manifest: %v", err)
+		return
+	}
+	mDigest := digest.FromBytes(mBody)
+	mHeaders := http.Header{
+		"Content-Length":        {fmt.Sprintf("%d", len(mBody))},
+		"Content-Type":          {types.MediaTypeDocker2Manifest},
+		"Docker-Content-Digest": {mDigest.String()},
+	}
+	// any request for a blob other than a HEAD or mount fails the test
+	rrs := []reqresp.ReqResp{
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "get manifest",
+				Method: "GET",
+				Path:   "/v2" + srcRepo + "/manifests/v1",
+			},
+			RespEntry: reqresp.RespEntry{
+				Status:  http.StatusOK,
+				Headers: mHeaders,
+				Body:    mBody,
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "put same repo",
+				Method: "PUT",
+				Path:   "/v2" + srcRepo + "/manifests/v2",
+				Body:   mBody,
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusCreated,
+				Headers: http.Header{
+					"Docker-Content-Digest": {mDigest.String()},
+				},
+			},
+		},
+		{
+			ReqEntry: reqresp.ReqEntry{
+				Name:   "put other repo",
+				Method: "PUT",
+				Path:   "/v2" + tgtRepo + "/manifests/v1",
+				Body:   mBody,
+			},
+			RespEntry: reqresp.RespEntry{
+				Status: http.StatusCreated,
+				Headers: http.Header{
+					"Docker-Content-Digest": {mDigest.String()},
+				},
+			},
+		},
+	}
+	for _, d := range []digest.Digest{digest1, digest2} {
+		rrs = append(rrs,
+			reqresp.ReqResp{
+				ReqEntry: reqresp.ReqEntry{
+					Name:   "head blob " + d.String(),
+					Method: "HEAD",
+					Path:   "/v2" + tgtRepo + "/blobs/" + d.String(),
+				},
+				RespEntry: reqresp.RespEntry{
+					Status: http.StatusNotFound,
+				},
+			},
+			reqresp.ReqResp{
+				ReqEntry: reqresp.ReqEntry{
+					Name:   "mount blob " + d.String(),
+					Method: "POST",
+					Path:   "/v2" + tgtRepo + "/blobs/uploads/",
+					Query: map[string][]string{
+						"mount": {d.String()},
+						"from":  {srcRepo[1:]},
+					},
+				},
+				RespEntry: reqresp.RespEntry{
+					Status: http.StatusCreated,
+					Headers: http.Header{
+						"Docker-Content-Digest": {d.String()},
+					},
+				},
+			},
+		)
+	}
+	rrs = append(rrs, reqresp.BaseEntries...)
+	ts := httptest.NewServer(reqresp.NewHandler(t, rrs))
+	defer ts.Close()
+	tsURL, _ := url.Parse(ts.URL)
+	tsHost := tsURL.Host
+	log := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: new(logrus.TextFormatter),
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.WarnLevel,
+	}
+	rc := New(
+		WithConfigHost(config.Host{
+			Name:      tsHost,
+			Hostname:  tsHost,
+			TLS:       config.TLSDisabled,
+			ReqPerSec: 100,
+		}),
+		WithLog(log),
+	)
+	r, err := ref.New(tsHost + srcRepo + ":v1")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+
+	t.Run("Same repo", func(t *testing.T) {
+		err := rc.TagCopy(ctx, r, "v2")
+		if err != nil {
+			t.Errorf("failed to copy tag: %v", err)
+		}
+	})
+	t.Run("Other repo", func(t *testing.T) {
+		err := rc.TagCopy(ctx, r, tsHost+tgtRepo+":v1")
+		if err != nil {
+			t.Errorf("failed to copy tag: %v", err)
+		}
+	})
+	t.Run("Other registry", func(t *testing.T) {
+		err := rc.TagCopy(ctx, r, "registry.example.com"+tgtRepo+":v1")
+		if err == nil || !errors.Is(err, types.ErrInvalidReference) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Missing tag", func(t *testing.T) {
+		err := rc.TagCopy(ctx, r, tsHost+tgtRepo+"@"+mDigest.String())
+		if err == nil || !errors.Is(err, types.ErrMissingTag) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
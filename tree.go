@@ -0,0 +1,262 @@
+package regclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/regclient/regclient/types/referrer"
+)
+
+type manifestTreeOpt struct {
+	digestTags   bool
+	referrerOpts []scheme.ReferrerOpts
+}
+
+// ManifestTreeOpts define options for ManifestTree
+type ManifestTreeOpts func(*manifestTreeOpt)
+
+// ManifestTreeWithDigestTags includes digest tags (sha256-<digest>.*) as referrers of the matching manifest.
+func ManifestTreeWithDigestTags() ManifestTreeOpts {
+	return func(opts *manifestTreeOpt) {
+		opts.digestTags = true
+	}
+}
+
+// ManifestTreeWithReferrerOpts filters the referrers included in the tree.
+func ManifestTreeWithReferrerOpts(rOpts ...scheme.ReferrerOpts) ManifestTreeOpts {
+	return func(opts *manifestTreeOpt) {
+		opts.referrerOpts = append(opts.referrerOpts, rOpts...)
+	}
+}
+
+// ManifestTreeNode is a manifest with the child manifests and referrers attached to it
+type ManifestTreeNode struct {
+	Ref          ref.Ref             `json:"reference"`
+	Manifest     manifest.Manifest   `json:"manifest"`
+	Platform     *platform.Platform  `json:"platform,omitempty"`
+	ArtifactType string              `json:"artifactType,omitempty"`
+	Annotations  map[string]string   `json:"annotations,omitempty"`
+	Child        []*ManifestTreeNode `json:"child,omitempty"`
+	Referrer     []*ManifestTreeNode `json:"referrer,omitempty"`
+}
+
+// ManifestTree recursively walks the children of an index and the referrers of each manifest.
+// A partial tree is returned with any error.
+// A loop in the graph returns ErrLoopDetected.
+func (rc *RegClient) ManifestTree(ctx context.Context, r ref.Ref, opts ...ManifestTreeOpts) (*ManifestTreeNode, error) {
+	opt := manifestTreeOpt{}
+	for _, fn := range opts {
+		fn(&opt)
+	}
+	tags := []string{}
+	if opt.digestTags {
+		tl, err := rc.TagList(ctx, r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags: %w", err)
+		}
+		tags, err = tl.GetTags()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags: %w", err)
+		}
+	}
+	return rc.manifestTreeAdd(ctx, r, []string{}, tags, opt)
+}
+
+func (rc *RegClient) manifestTreeAdd(ctx context.Context, r ref.Ref, seen []string, tags []string, opt manifestTreeOpt) (*ManifestTreeNode, error) {
+	tn := ManifestTreeNode{
+		Ref: r,
+	}
+
+	// get manifest
+	m, err := rc.ManifestGet(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	tn.Manifest = m
+	if r.Digest == "" {
+		r.Digest = m.GetDescriptor().Digest.String()
+	}
+	if ma, ok := m.(manifest.Annotator); ok {
+		annotations, err := ma.GetAnnotations()
+		if err == nil && len(annotations) > 0 {
+			tn.Annotations = annotations
+		}
+	}
+
+	// track already seen manifests
+	dig := m.GetDescriptor().Digest.String()
+	for _, s := range seen {
+		if s == dig {
+			return &tn, fmt.Errorf("%w, already processed %s", types.ErrLoopDetected, dig)
+		}
+	}
+	seen = append(seen, dig)
+
+	// get child nodes
+	if m.IsList() {
+		tn.Child = []*ManifestTreeNode{}
+		mi, ok := m.(manifest.Indexer)
+		if !ok {
+			return &tn, fmt.Errorf("failed to convert a manifest list to indexer for %s", r.CommonName())
+		}
+		dl, err := mi.GetManifestList()
+		if err != nil {
+			return &tn, fmt.Errorf("failed to get platforms for %s: %w", r.CommonName(), err)
+		}
+		for _, d := range dl {
+			rChild := r
+			rChild.Tag = ""
+			rChild.Digest = d.Digest.String()
+			tChild, err := rc.manifestTreeAdd(ctx, rChild, seen, tags, opt)
+			if tChild != nil {
+				tChild.setDesc(d)
+				tn.Child = append(tn.Child, tChild)
+			}
+			if err != nil {
+				return &tn, err
+			}
+		}
+	}
+
+	// get referrers
+	rl, err := rc.ReferrerList(ctx, r, opt.referrerOpts...)
+	if err != nil {
+		return &tn, fmt.Errorf("failed to check referrers for %s: %w", r.CommonName(), err)
+	}
+	if len(rl.Descriptors) > 0 {
+		tn.Referrer = []*ManifestTreeNode{}
+		for _, d := range rl.Descriptors {
+			rReferrer := r
+			rReferrer.Tag = ""
+			rReferrer.Digest = d.Digest.String()
+			tReferrer, err := rc.manifestTreeAdd(ctx, rReferrer, seen, tags, opt)
+			if tReferrer != nil {
+				tReferrer.setDesc(d)
+				tn.Referrer = append(tn.Referrer, tReferrer)
+			}
+			if err != nil {
+				return &tn, err
+			}
+		}
+	}
+
+	// include digest tags if requested
+	if opt.digestTags {
+		prefix, err := referrer.FallbackTag(r)
+		if err != nil {
+			return &tn, fmt.Errorf("failed to compute fallback tag: %v", err)
+		}
+		for _, t := range tags {
+			if !strings.HasPrefix(t, prefix.Tag) || manifestTreeHasStr(rl.Tags, t) {
+				continue
+			}
+			rTag := r
+			rTag.Tag = t
+			rTag.Digest = ""
+			tReferrer, err := rc.manifestTreeAdd(ctx, rTag, seen, tags, opt)
+			if tReferrer != nil {
+				tReferrer.Ref.Tag = t
+				tReferrer.Ref.Digest = ""
+				tn.Referrer = append(tn.Referrer, tReferrer)
+			}
+			if err != nil {
+				return &tn, err
+			}
+		}
+	}
+
+	return &tn, nil
+}
+
+// setDesc copies the fields from the descriptor that points to the node.
+// Annotations are merged with those of the manifest, with the descriptor value used for duplicate keys.
+func (tn *ManifestTreeNode) setDesc(d types.Descriptor) {
+	tn.ArtifactType = d.ArtifactType
+	if d.Platform != nil {
+		pCopy := *d.Platform
+		tn.Platform = &pCopy
+	}
+	if len(d.Annotations) > 0 {
+		annotations := make(map[string]string, len(tn.Annotations)+len(d.Annotations))
+		for k, v := range tn.Annotations {
+			annotations[k] = v
+		}
+		for k, v := range d.Annotations {
+			annotations[k] = v
+		}
+		tn.Annotations = annotations
+	}
+}
+
+// MarshalPretty renders the tree of digests with their platforms, artifact types, and annotations.
+func (tn *ManifestTreeNode) MarshalPretty() ([]byte, error) {
+	mp, err := tn.marshalPretty("")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("Ref: %s\nDigest: %s", tn.Ref.CommonName(), mp)), nil
+}
+
+func (tn *ManifestTreeNode) marshalPretty(indent string) ([]byte, error) {
+	result := &bytes.Buffer{}
+	result.WriteString(tn.Manifest.GetDescriptor().Digest.String())
+	if tn.Platform != nil {
+		result.WriteString(" [" + tn.Platform.String() + "]")
+	}
+	if tn.ArtifactType != "" {
+		result.WriteString(": " + tn.ArtifactType)
+	}
+	if tn.ArtifactType == "" && strings.HasPrefix(tn.Ref.Tag, "sha256-") {
+		result.WriteString(": " + tn.Ref.Tag)
+	}
+	result.WriteString("\n")
+	if len(tn.Annotations) > 0 {
+		keys := make([]string, 0, len(tn.Annotations))
+		for k := range tn.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		result.WriteString(indent + "Annotations:\n")
+		for _, k := range keys {
+			result.WriteString(indent + "  " + k + ": " + tn.Annotations[k] + "\n")
+		}
+	}
+	for _, section := range []struct {
+		name  string
+		nodes []*ManifestTreeNode
+	}{
+		{"Children", tn.Child},
+		{"Referrers", tn.Referrer},
+	} {
+		if len(section.nodes) == 0 {
+			continue
+		}
+		result.WriteString(indent + section.name + ":\n")
+		for _, tnSub := range section.nodes {
+			subBytes, err := tnSub.marshalPretty(indent + "    ")
+			if err != nil {
+				return nil, err
+			}
+			result.WriteString(indent + "  - ")
+			result.Write(subBytes)
+		}
+	}
+	return result.Bytes(), nil
+}
+
+func manifestTreeHasStr(list []string, search string) bool {
+	for _, el := range list {
+		if el == search {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package regclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/regclient/regclient/internal/rwfs"
+	"github.com/regclient/regclient/scheme"
+	"github.com/regclient/regclient/types"
+	"github.com/regclient/regclient/types/ref"
+)
+
+func TestManifestTree(t *testing.T) {
+	ctx := context.Background()
+	fsOS := rwfs.OSNew("")
+	fsMem := rwfs.MemNew()
+	err := rwfs.CopyRecursive(fsOS, "testdata", fsMem, ".")
+	if err != nil {
+		t.Errorf("failed to setup memfs copy: %v", err)
+		return
+	}
+	rc := New(WithFS(fsMem))
+	rV2, err := ref.New("ocidir://testrepo:v2")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+	rLoop, err := ref.New("ocidir://testrepo:loop")
+	if err != nil {
+		t.Errorf("failed to setup ref: %v", err)
+		return
+	}
+
+	t.Run("Tree", func(t *testing.T) {
+		tn, err := rc.ManifestTree(ctx, rV2)
+		if err != nil {
+			t.Errorf("failed to get tree: %v", err)
+			return
+		}
+		if len(tn.Child) != 3 {
+			t.Errorf("unexpected number of children: %d", len(tn.Child))
+		}
+		for _, child := range tn.Child {
+			if child.Platform == nil {
+				t.Errorf("child %s is missing the platform", child.Ref.CommonName())
+			}
+			if len(child.Referrer) == 0 {
+				t.Errorf("child %s is missing referrers", child.Ref.CommonName())
+			}
+		}
+		if len(tn.Referrer) != 2 {
+			t.Errorf("unexpected number of referrers: %d", len(tn.Referrer))
+		}
+		if tn.Annotations["org.example.version"] != "v2" {
+			t.Errorf("annotations missing from root: %v", tn.Annotations)
+		}
+		out, err := tn.MarshalPretty()
+		if err != nil {
+			t.Errorf("failed to marshal: %v", err)
+			return
+		}
+		for _, s := range []string{"Children:", "Referrers:", "Annotations:", "[linux/amd64]", "application/example.sbom"} {
+			if !strings.Contains(string(out), s) {
+				t.Errorf("output is missing %s:\n%s", s, out)
+			}
+		}
+	})
+	t.Run("Merge Annotations", func(t *testing.T) {
+		tn := ManifestTreeNode{Annotations: map[string]string{"manifest": "m", "shared": "manifest"}}
+		tn.setDesc(types.Descriptor{Annotations: map[string]string{"desc": "d", "shared": "desc"}})
+		if len(tn.Annotations) != 3 || tn.Annotations["manifest"] != "m" || tn.Annotations["desc"] != "d" || tn.Annotations["shared"] != "desc" {
+			t.Errorf("unexpected annotations: %v", tn.Annotations)
+		}
+	})
+	t.Run("Filter", func(t *testing.T) {
+		tn, err := rc.ManifestTree(ctx, rV2, ManifestTreeWithReferrerOpts(scheme.WithReferrerAT("application/example.sbom")))
+		if err != nil {
+			t.Errorf("failed to get tree: %v", err)
+			return
+		}
+		if len(tn.Referrer) != 1 || tn.Referrer[0].ArtifactType != "application/example.sbom" {
+			t.Errorf("unexpected referrers: %v", tn.Referrer)
+		}
+	})
+	t.Run("Digest Tags", func(t *testing.T) {
+		mV2, err := rc.ManifestHead(ctx, rV2, WithManifestRequireDigest())
+		if err != nil {
+			t.Errorf("failed to head v2: %v", err)
+			return
+		}
+		rSrc := rV2
+		rSrc.Tag = "v1"
+		rSig := rV2
+		rSig.Tag = "sha256-" + mV2.GetDescriptor().Digest.Hex() + ".sig"
+		err = rc.ImageCopy(ctx, rSrc, rSig)
+		if err != nil {
+			t.Errorf("failed to create digest tag: %v", err)
+			return
+		}
+		tn, err := rc.ManifestTree(ctx, rV2, ManifestTreeWithDigestTags())
+		if err != nil {
+			t.Errorf("failed to get tree: %v", err)
+			return
+		}
+		found := false
+		for _, tr := range tn.Referrer {
+			if tr.Ref.Tag == rSig.Tag {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("digest tag not included in referrers")
+		}
+	})
+	t.Run("Loop", func(t *testing.T) {
+		tn, err := rc.ManifestTree(ctx, rLoop)
+		if err == nil || !errors.Is(err, types.ErrLoopDetected) {
+			t.Errorf("loop not detected: %v", err)
+		}
+		if tn == nil {
+			t.Errorf("partial tree not returned")
+		}
+	})
+}
@@ -70,3 +70,22 @@ const (
 	// AnnotationReferrersFiltersApplied is the annotation key for the comma separated list of filters applied by the registry in the referrers listing.
 	AnnotationReferrersFiltersApplied = "org.opencontainers.referrers.filtersApplied"
 )
+
+const (
+	// AnnotationDockerReferenceType is the annotation key buildkit uses on index entries for content attached to another manifest, e.g. "attestation-manifest".
+	AnnotationDockerReferenceType = "vnd.docker.reference.type"
+
+	// AnnotationDockerReferenceDigest is the annotation key buildkit uses on index entries for the digest of the manifest the content is attached to.
+	AnnotationDockerReferenceDigest = "vnd.docker.reference.digest"
+
+	// AnnotationInTotoPredicateType is the annotation key on attestation layers for the predicate type of the in-toto statement.
+	AnnotationInTotoPredicateType = "in-toto.io/predicate-type"
+
+	// DockerReferenceTypeAttestation is the value of AnnotationDockerReferenceType for buildkit attestation manifests.
+	DockerReferenceTypeAttestation = "attestation-manifest"
+)
+
+const (
+	// AnnotationNotaryThumbprint is the annotation key notation uses on signatures for the list of certificate thumbprints in the signing chain.
+	AnnotationNotaryThumbprint = "io.cncf.notary.x509chain.thumbprint#S256"
+)
@@ -665,6 +665,58 @@ func TestReadFile(t *testing.T) {
 	})
 }
 
+func TestListFiles(t *testing.T) {
+	fileBytes, err := os.ReadFile(fileLayerWH)
+	if err != nil {
+		t.Errorf("failed to open test data: %v", err)
+		return
+	}
+	blobDigest := digest.FromBytes(fileBytes)
+	t.Run("list", func(t *testing.T) {
+		fh, err := os.Open(fileLayerWH)
+		if err != nil {
+			t.Errorf("failed to open test data: %v", err)
+			return
+		}
+		btr := NewTarReader(WithReader(fh), WithDesc(types.Descriptor{Size: int64(len(fileBytes)), Digest: blobDigest, MediaType: types.MediaTypeOCI1Layer}))
+		btl, ok := btr.(TarFileLister)
+		if !ok {
+			t.Fatalf("tar reader does not implement TarFileLister")
+		}
+		headers, err := btl.ListFiles()
+		if err != nil {
+			t.Errorf("ListFiles failed: %v", err)
+			return
+		}
+		expect := []string{"layer1.txt", ".wh.layer2.txt", "layer3.txt", "exdir/", "exdir/.wh..wh..opq"}
+		names := []string{}
+		for _, th := range headers {
+			names = append(names, th.Name)
+		}
+		if !cmpSliceString(expect, names) {
+			t.Errorf("unexpected file list, expected %v, received %v", expect, names)
+		}
+		err = btr.Close()
+		if err != nil {
+			t.Errorf("failed to close tar reader: %v", err)
+		}
+	})
+	t.Run("bad digest", func(t *testing.T) {
+		fh, err := os.Open(fileLayerWH)
+		if err != nil {
+			t.Errorf("failed to open test data: %v", err)
+			return
+		}
+		btr := NewTarReader(WithReader(fh), WithDesc(types.Descriptor{Size: int64(len(fileBytes)), Digest: digest.FromString("bad digest"), MediaType: types.MediaTypeOCI1Layer}))
+		_, err = btr.(TarFileLister).ListFiles()
+		if err == nil {
+			t.Errorf("ListFiles did not fail")
+		} else if !errors.Is(err, types.ErrDigestMismatch) {
+			t.Errorf("unexpected error, expected %v, received %v", types.ErrDigestMismatch, err)
+		}
+	})
+}
+
 func cmpSliceString(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
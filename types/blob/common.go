@@ -63,3 +63,8 @@ func (b *common) RawHeaders() http.Header {
 func (b *common) Response() *http.Response {
 	return b.resp
 }
+
+// GetResponseHeaders returns the selected headers received when the blob was pulled from a registry.
+func GetResponseHeaders(b Common) types.ResponseHeaders {
+	return types.ParseResponseHeaders(b.RawHeaders())
+}
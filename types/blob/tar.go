@@ -22,6 +22,11 @@ type TarReader interface {
 	ReadFile(filename string) (*tar.Header, io.Reader, error)
 }
 
+// TarFileLister lists the entries of a tar blob, implemented by the TarReader from NewTarReader
+type TarFileLister interface {
+	ListFiles() ([]*tar.Header, error)
+}
+
 type tarReader struct {
 	common
 	origRdr  io.Reader
@@ -155,18 +160,49 @@ func (tr *tarReader) ReadFile(filename string) (*tar.Header, io.Reader, error) {
 	if whiteout {
 		return nil, nil, types.ErrFileDeleted
 	}
-	if tr.digester != nil {
-		io.Copy(io.Discard, tr.reader) // process/digest any trailing bytes from reader
-		dig := tr.digester.Digest()
-		tr.digester = nil
-		if tr.desc.Digest.String() != "" && dig != tr.desc.Digest {
-			return nil, nil, fmt.Errorf("%w, expected %s, received %s", types.ErrDigestMismatch, tr.desc.Digest.String(), dig.String())
-		}
-		tr.desc.Digest = dig
+	if err := tr.digestVerify(); err != nil {
+		return nil, nil, err
 	}
 	return nil, nil, types.ErrFileNotFound
 }
 
+// ListFiles returns the header of every entry in the tar, including whiteout files.
+// This reads the entire blob and verifies the digest.
+func (tr *tarReader) ListFiles() ([]*tar.Header, error) {
+	rdr, err := tr.GetTarReader()
+	if err != nil {
+		return nil, err
+	}
+	headers := []*tar.Header{}
+	for {
+		th, err := rdr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return headers, err
+		}
+		headers = append(headers, th)
+	}
+	err = tr.digestVerify()
+	return headers, err
+}
+
+// digestVerify reads any trailing bytes and compares the digest to the descriptor
+func (tr *tarReader) digestVerify() error {
+	if tr.digester == nil {
+		return nil
+	}
+	_, _ = io.Copy(io.Discard, tr.reader) // process/digest any trailing bytes from reader
+	dig := tr.digester.Digest()
+	tr.digester = nil
+	if tr.desc.Digest.String() != "" && dig != tr.desc.Digest {
+		return fmt.Errorf("%w, expected %s, received %s", types.ErrDigestMismatch, tr.desc.Digest.String(), dig.String())
+	}
+	tr.desc.Digest = dig
+	return nil
+}
+
 func tarCmpWhiteout(whFile, tgtFile string) bool {
 	whSplit := strings.Split(whFile, "/")
 	tgtSplit := strings.Split(tgtFile, "/")
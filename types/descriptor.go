@@ -49,6 +49,16 @@ var EmptyDigest = digest.FromBytes(EmptyData)
 var emptyDigest = digest.FromBytes([]byte{})
 var mtToOCI map[string]string
 
+// EmptyDescriptor returns the descriptor of the empty JSON blob, EmptyData.
+// OCI artifacts use this as the config when there is no config, and as the only layer when there is no content.
+func EmptyDescriptor() Descriptor {
+	return Descriptor{
+		MediaType: MediaTypeOCI1Empty,
+		Digest:    EmptyDigest,
+		Size:      int64(len(EmptyData)),
+	}
+}
+
 func init() {
 	mtToOCI = map[string]string{
 		MediaTypeDocker2ManifestList: MediaTypeOCI1ManifestList,
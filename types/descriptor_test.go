@@ -78,6 +78,21 @@ func TestDescriptorData(t *testing.T) {
 	}
 }
 
+func TestEmptyDescriptor(t *testing.T) {
+	d := EmptyDescriptor()
+	if d.MediaType != MediaTypeOCI1Empty {
+		t.Errorf("unexpected media type: %s", d.MediaType)
+	}
+	if d.Digest != digest.FromBytes([]byte("{}")) || d.Size != 2 {
+		t.Errorf("unexpected digest or size: %s, %d", d.Digest, d.Size)
+	}
+	// verify the descriptor matches the data
+	d.Data = EmptyData
+	if _, err := d.GetData(); err != nil {
+		t.Errorf("descriptor does not match the empty data: %v", err)
+	}
+}
+
 func TestDescriptorEq(t *testing.T) {
 	digA := digest.FromString("test A")
 	digB := digest.FromString("test B")
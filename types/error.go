@@ -1,9 +1,11 @@
 package types
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"strings"
 )
 
 var (
@@ -57,12 +59,18 @@ var (
 	ErrNotImplemented = errors.New("not implemented")
 	// ErrParsingFailed when a string cannot be parsed
 	ErrParsingFailed = errors.New("parsing failed")
-	// ErrRetryNeeded indicates a request needs to be retried
+	// ErrRetryNeeded indicates a request needs to be retried by the caller, e.g. a failed POST that is not safe to replay
 	ErrRetryNeeded = errors.New("retry needed")
+	// ErrScanThreshold when an image scan has findings at or above the severity threshold
+	ErrScanThreshold = errors.New("scan findings exceed the severity threshold")
+	// ErrSchemaInvalid when content does not match the schema for the media type
+	ErrSchemaInvalid = errors.New("schema validation failed")
 	// ErrShortRead if contents are less than expected the size
 	ErrShortRead = errors.New("short read")
 	// ErrSizeLimitExceeded if contents exceed the size limit
 	ErrSizeLimitExceeded = errors.New("size limit exceeded")
+	// ErrTagImmutable when a copy would overwrite a protected tag
+	ErrTagImmutable = errors.New("tag is immutable")
 	// ErrUnavailable when a requested value is not available
 	ErrUnavailable = errors.New("unavailable")
 	// ErrUnsupported indicates the request was unsupported
@@ -81,4 +89,73 @@ var (
 	ErrHTTPRateLimit = fmt.Errorf("rate limit exceeded%.0w", ErrHTTPStatus)
 	// ErrHTTPUnauthorized when authentication fails
 	ErrHTTPUnauthorized = fmt.Errorf("unauthorized%.0w", ErrHTTPStatus)
+	// ErrHTTPForbidden when the credentials do not have access, this extends ErrHTTPUnauthorized.
+	// Some registries return a 401 or 403 for a missing repository to avoid leaking which repositories exist.
+	ErrHTTPForbidden = fmt.Errorf("forbidden%.0w", ErrHTTPUnauthorized)
 )
+
+// HTTPStatusError preserves the status code of a failed http request.
+// The wrapped error is one of the typed errors, e.g. ErrNotFound or ErrHTTPForbidden.
+// Errors contains the error list from the response body when returned by the registry.
+type HTTPStatusError struct {
+	StatusCode int
+	Err        error
+	Errors     []RegistryError
+}
+
+// Error includes the status code and any registry errors with the wrapped error message
+func (e *HTTPStatusError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("%s [http %d]", e.Err.Error(), e.StatusCode)
+	}
+	regErrs := make([]string, len(e.Errors))
+	for i, re := range e.Errors {
+		regErrs[i] = re.Error()
+	}
+	return fmt.Sprintf("%s: %s [http %d]", e.Err.Error(), strings.Join(regErrs, "; "), e.StatusCode)
+}
+
+// Unwrap returns the typed error
+func (e *HTTPStatusError) Unwrap() error {
+	return e.Err
+}
+
+// RegistryError is an entry in the errors list of a registry response, defined by the OCI distribution-spec
+type RegistryError struct {
+	Code    string          `json:"code"`              // e.g. MANIFEST_UNKNOWN or DENIED
+	Message string          `json:"message,omitempty"` // human readable description
+	Detail  json.RawMessage `json:"detail,omitempty"`  // unstructured data specific to the error code
+}
+
+// Error returns the code, message, and detail, e.g. "DENIED: requested access to the resource is denied"
+func (re RegistryError) Error() string {
+	msg := re.Code
+	if re.Message != "" {
+		if msg != "" {
+			msg += ": "
+		}
+		msg += re.Message
+	}
+	if detail := strings.TrimSpace(string(re.Detail)); detail != "" && detail != "null" && detail != "{}" && detail != `""` {
+		msg += " (detail: " + detail + ")"
+	}
+	return msg
+}
+
+// RegistryErrors returns the errors from the registry response body of an HTTPStatusError in the error chain
+func RegistryErrors(err error) []RegistryError {
+	var se *HTTPStatusError
+	if errors.As(err, &se) {
+		return se.Errors
+	}
+	return nil
+}
+
+// HTTPStatus returns the status code of an HTTPStatusError in the error chain, or 0 if none is found
+func HTTPStatus(err error) int {
+	var se *HTTPStatusError
+	if errors.As(err, &se) {
+		return se.StatusCode
+	}
+	return 0
+}
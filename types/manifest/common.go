@@ -2,8 +2,6 @@ package manifest
 
 import (
 	"net/http"
-	"strconv"
-	"strings"
 
 	// crypto libraries included for go-digest
 	_ "crypto/sha256"
@@ -71,6 +69,7 @@ func (m *common) IsSet() bool {
 }
 
 // RawBody returns the raw body from the manifest if available.
+// This is the unmodified content received from the registry, used to compute the digest.
 func (m *common) RawBody() ([]byte, error) {
 	if len(m.rawBody) == 0 {
 		return m.rawBody, types.ErrManifestNotSet
@@ -84,41 +83,5 @@ func (m *common) RawHeaders() (http.Header, error) {
 }
 
 func (m *common) setRateLimit(header http.Header) {
-	// check for rate limit headers
-	rlLimit := header.Get("RateLimit-Limit")
-	rlRemain := header.Get("RateLimit-Remaining")
-	rlReset := header.Get("RateLimit-Reset")
-	if rlLimit != "" {
-		lpSplit := strings.Split(rlLimit, ",")
-		lSplit := strings.Split(lpSplit[0], ";")
-		rlLimitI, err := strconv.Atoi(lSplit[0])
-		if err != nil {
-			m.ratelimit.Limit = 0
-		} else {
-			m.ratelimit.Limit = rlLimitI
-		}
-		if len(lSplit) > 1 {
-			m.ratelimit.Policies = lpSplit
-		} else if len(lpSplit) > 1 {
-			m.ratelimit.Policies = lpSplit[1:]
-		}
-	}
-	if rlRemain != "" {
-		rSplit := strings.Split(rlRemain, ";")
-		rlRemainI, err := strconv.Atoi(rSplit[0])
-		if err != nil {
-			m.ratelimit.Remain = 0
-		} else {
-			m.ratelimit.Remain = rlRemainI
-			m.ratelimit.Set = true
-		}
-	}
-	if rlReset != "" {
-		rlResetI, err := strconv.Atoi(rlReset)
-		if err != nil {
-			m.ratelimit.Reset = 0
-		} else {
-			m.ratelimit.Reset = rlResetI
-		}
-	}
+	m.ratelimit = types.ParseRateLimit(header)
 }
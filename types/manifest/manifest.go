@@ -158,6 +158,24 @@ func WithRef(r ref.Ref) Opts {
 	}
 }
 
+// GetArtifactType returns the artifact type of an OCI manifest, index, or artifact manifest.
+// An OCI image manifest without the artifactType field returns the config media type, following the referrers API.
+// Other manifests return an empty string.
+func GetArtifactType(m Manifest) string {
+	switch mOrig := m.GetOrig().(type) {
+	case v1.ArtifactManifest:
+		return mOrig.ArtifactType
+	case v1.Manifest:
+		if mOrig.ArtifactType != "" {
+			return mOrig.ArtifactType
+		}
+		return mOrig.Config.MediaType
+	case v1.Index:
+		return mOrig.ArtifactType
+	}
+	return ""
+}
+
 // GetDigest returns the digest from the manifest descriptor
 func GetDigest(m Manifest) digest.Digest {
 	d := m.GetDescriptor()
@@ -196,49 +214,20 @@ func GetPlatformList(m Manifest) ([]*platform.Platform, error) {
 
 // GetRateLimit returns the current rate limit seen in headers
 func GetRateLimit(m Manifest) types.RateLimit {
-	rl := types.RateLimit{}
 	header, err := m.RawHeaders()
 	if err != nil {
-		return rl
-	}
-	// check for rate limit headers
-	rlLimit := header.Get("RateLimit-Limit")
-	rlRemain := header.Get("RateLimit-Remaining")
-	rlReset := header.Get("RateLimit-Reset")
-	if rlLimit != "" {
-		lpSplit := strings.Split(rlLimit, ",")
-		lSplit := strings.Split(lpSplit[0], ";")
-		rlLimitI, err := strconv.Atoi(lSplit[0])
-		if err != nil {
-			rl.Limit = 0
-		} else {
-			rl.Limit = rlLimitI
-		}
-		if len(lSplit) > 1 {
-			rl.Policies = lpSplit
-		} else if len(lpSplit) > 1 {
-			rl.Policies = lpSplit[1:]
-		}
+		return types.RateLimit{}
 	}
-	if rlRemain != "" {
-		rSplit := strings.Split(rlRemain, ";")
-		rlRemainI, err := strconv.Atoi(rSplit[0])
-		if err != nil {
-			rl.Remain = 0
-		} else {
-			rl.Remain = rlRemainI
-			rl.Set = true
-		}
-	}
-	if rlReset != "" {
-		rlResetI, err := strconv.Atoi(rlReset)
-		if err != nil {
-			rl.Reset = 0
-		} else {
-			rl.Reset = rlResetI
-		}
+	return types.ParseRateLimit(header)
+}
+
+// GetResponseHeaders returns the selected headers received when the manifest was pulled from a registry.
+func GetResponseHeaders(m Manifest) types.ResponseHeaders {
+	header, err := m.RawHeaders()
+	if err != nil {
+		return types.ResponseHeaders{}
 	}
-	return rl
+	return types.ParseResponseHeaders(header)
 }
 
 // HasRateLimit indicates whether the rate limit is set and available
@@ -555,16 +544,17 @@ func getPlatformDesc(p *platform.Platform, dl []types.Descriptor) (*types.Descri
 	if p == nil {
 		return nil, fmt.Errorf("invalid input, platform is nil%.0w", types.ErrNotFound)
 	}
+	// select the best platform, falling back to a compatible platform (Mac runs Linux images, arm/v7 runs arm/v6)
+	pl := []platform.Platform{}
+	pdl := []types.Descriptor{}
 	for _, d := range dl {
-		if d.Platform != nil && platform.Match(*p, *d.Platform) {
-			return &d, nil
+		if d.Platform != nil {
+			pl = append(pl, *d.Platform)
+			pdl = append(pdl, d)
 		}
 	}
-	// if no platforms match, fall back to searching for a compatible platform (Mac runs Linux images)
-	for _, d := range dl {
-		if d.Platform != nil && platform.Compatible(*p, *d.Platform) {
-			return &d, nil
-		}
+	if i := platform.Best(*p, pl); i >= 0 {
+		return &pdl[i], nil
 	}
 	return nil, fmt.Errorf("platform not found: %s%.0w", *p, types.ErrNotFound)
 }
@@ -1461,3 +1461,78 @@ func TestSet(t *testing.T) {
 		})
 	}
 }
+
+func TestGetArtifactType(t *testing.T) {
+	at := "application/vnd.example.artifact"
+	tests := []struct {
+		name   string
+		orig   interface{}
+		expect string
+	}{
+		{
+			name: "OCI manifest with artifactType",
+			orig: v1.Manifest{
+				Versioned:    v1.ManifestSchemaVersion,
+				MediaType:    types.MediaTypeOCI1Manifest,
+				ArtifactType: at,
+				Config:       types.EmptyDescriptor(),
+				Layers:       []types.Descriptor{types.EmptyDescriptor()},
+			},
+			expect: at,
+		},
+		{
+			name: "OCI manifest with config media type",
+			orig: v1.Manifest{
+				Versioned: v1.ManifestSchemaVersion,
+				MediaType: types.MediaTypeOCI1Manifest,
+				Config: types.Descriptor{
+					MediaType: at,
+					Digest:    types.EmptyDigest,
+					Size:      int64(len(types.EmptyData)),
+				},
+			},
+			expect: at,
+		},
+		{
+			name: "OCI index",
+			orig: v1.Index{
+				Versioned:    v1.IndexSchemaVersion,
+				MediaType:    types.MediaTypeOCI1ManifestList,
+				ArtifactType: at,
+			},
+			expect: at,
+		},
+		{
+			name: "OCI artifact",
+			orig: v1.ArtifactManifest{
+				MediaType:    types.MediaTypeOCI1Artifact,
+				ArtifactType: at,
+			},
+			expect: at,
+		},
+		{
+			name: "Docker manifest",
+			orig: schema2.Manifest{
+				Versioned: schema2.ManifestSchemaVersion,
+				Config: types.Descriptor{
+					MediaType: types.MediaTypeDocker2ImageConfig,
+					Digest:    types.EmptyDigest,
+					Size:      int64(len(types.EmptyData)),
+				},
+			},
+			expect: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(WithOrig(tt.orig))
+			if err != nil {
+				t.Fatalf("failed to create manifest: %v", err)
+			}
+			result := GetArtifactType(m)
+			if result != tt.expect {
+				t.Errorf("unexpected artifact type, expected %s, received %s", tt.expect, result)
+			}
+		})
+	}
+}
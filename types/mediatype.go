@@ -41,6 +41,14 @@ const (
 	MediaTypeOCI1Empty = "application/vnd.oci.empty.v1+json"
 	// MediaTypeBuildkitCacheConfig is used by buildkit cache images
 	MediaTypeBuildkitCacheConfig = "application/vnd.buildkit.cacheconfig.v0"
+	// MediaTypeInToto is used for in-toto statements, e.g. the layers of buildkit attestation manifests
+	MediaTypeInToto = "application/vnd.in-toto+json"
+	// MediaTypeNotarySignature is the artifact type of notation signatures attached as referrers
+	MediaTypeNotarySignature = "application/vnd.cncf.notary.signature"
+	// MediaTypeJWS is the notation signature envelope using JSON web signatures
+	MediaTypeJWS = "application/jose+json"
+	// MediaTypeCOSE is the notation signature envelope using CBOR object signing
+	MediaTypeCOSE = "application/cose"
 )
 
 // MediaTypeBase cleans the Content-Type header to return only the lower case base media type
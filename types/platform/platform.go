@@ -21,6 +21,7 @@ import (
 	"path"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 )
 
@@ -64,22 +65,25 @@ func (p Platform) String() string {
 
 // Compatible indicates if a host can run a specified target platform image.
 // This accounts for Docker Desktop for Mac and Windows using a Linux VM.
+// Hosts can run older CPU variants (e.g. linux/arm/v7 runs linux/arm/v6 images),
+// and Windows hosts must include every OS feature required by the target.
 func Compatible(host, target Platform) bool {
 	(&host).normalize()
 	(&target).normalize()
 	if host.OS == "linux" {
-		return host.OS == target.OS && host.Architecture == target.Architecture && host.Variant == target.Variant
+		return host.OS == target.OS && host.Architecture == target.Architecture && variantCompatible(host, target)
 	} else if host.OS == "windows" {
 		if target.OS == "windows" {
-			return host.Architecture == target.Architecture && host.Variant == target.Variant &&
-				prefix(host.OSVersion) == prefix(target.OSVersion)
+			return host.Architecture == target.Architecture && variantCompatible(host, target) &&
+				(host.OSVersion == "" || target.OSVersion == "" || prefix(host.OSVersion) == prefix(target.OSVersion)) &&
+				(len(host.OSFeatures) == 0 || strSliceSubset(target.OSFeatures, host.OSFeatures))
 		} else if target.OS == "linux" {
-			return host.Architecture == target.Architecture && host.Variant == target.Variant
+			return host.Architecture == target.Architecture && variantCompatible(host, target)
 		}
 		return false
 	} else if host.OS == "darwin" {
 		if target.OS == "darwin" || target.OS == "linux" {
-			return host.Architecture == target.Architecture && host.Variant == target.Variant
+			return host.Architecture == target.Architecture && variantCompatible(host, target)
 		}
 		return false
 	} else {
@@ -91,6 +95,116 @@ func Compatible(host, target Platform) bool {
 	}
 }
 
+// Best returns the index of the platform in the list that is the best choice for the host.
+// A platform that matches the host is preferred, followed by the compatible platform
+// with the same OS and the newest variant and OS version that the host can run.
+// If no platform is compatible, -1 is returned.
+func Best(host Platform, list []Platform) int {
+	for i, p := range list {
+		if Match(host, p) {
+			return i
+		}
+	}
+	best := -1
+	for i, p := range list {
+		if !Compatible(host, p) {
+			continue
+		}
+		if best < 0 || better(host, p, list[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// better returns true when compatible platform a is preferred over b for the host
+func better(host, a, b Platform) bool {
+	(&host).normalize()
+	(&a).normalize()
+	(&b).normalize()
+	if (a.OS == host.OS) != (b.OS == host.OS) {
+		return a.OS == host.OS
+	}
+	if a.OSVersion != b.OSVersion {
+		if a.OSVersion == host.OSVersion || b.OSVersion == host.OSVersion {
+			return a.OSVersion == host.OSVersion
+		}
+		if cmp := versionCmp(a.OSVersion, b.OSVersion); cmp != 0 {
+			return cmp > 0
+		}
+	}
+	if a.Variant != b.Variant {
+		aVer, aOK := variantVersion(a)
+		bVer, bOK := variantVersion(b)
+		if aOK && bOK {
+			return versionCmp(aVer, bVer) > 0
+		}
+	}
+	return false
+}
+
+// variantCompatible returns true when the host CPU variant can run the target variant
+func variantCompatible(host, target Platform) bool {
+	if host.Variant == target.Variant {
+		return true
+	}
+	hostVer, hostOK := variantVersion(host)
+	targetVer, targetOK := variantVersion(target)
+	if !hostOK || !targetOK {
+		return false
+	}
+	return versionCmp(hostVer, targetVer) >= 0
+}
+
+// variantVersion returns the numeric version of a variant for architectures where variants are backwards compatible
+func variantVersion(p Platform) (string, bool) {
+	v := p.Variant
+	switch p.Architecture {
+	case "amd64":
+		if v == "" {
+			v = "v1"
+		}
+	case "arm64":
+		if v == "" {
+			v = "v8"
+		}
+	case "arm":
+	default:
+		return "", false
+	}
+	if !strings.HasPrefix(v, "v") {
+		return "", false
+	}
+	v = strings.TrimPrefix(v, "v")
+	for _, part := range strings.Split(v, ".") {
+		if _, err := strconv.Atoi(part); err != nil {
+			return "", false
+		}
+	}
+	return v, true
+}
+
+// versionCmp compares dot separated numeric versions, returning -1, 0, or 1
+func versionCmp(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aNum, bNum := 0, 0
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum < bNum {
+			return -1
+		} else if aNum > bNum {
+			return 1
+		}
+	}
+	return 0
+}
+
 // Match indicates if two platforms are the same
 func Match(a, b Platform) bool {
 	(&a).normalize()
@@ -217,3 +331,20 @@ func strSliceEq(a, b []string) bool {
 	}
 	return true
 }
+
+// strSliceSubset returns true when every entry in a is found in b
+func strSliceSubset(a, b []string) bool {
+	for _, aEntry := range a {
+		found := false
+		for _, bEntry := range b {
+			if aEntry == bEntry {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
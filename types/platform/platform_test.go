@@ -40,6 +40,34 @@ func TestCompare(t *testing.T) {
 			expectMatch:  false,
 			expectCompat: false,
 		},
+		{
+			name:         "linux older variant",
+			a:            Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			b:            Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			expectMatch:  false,
+			expectCompat: true,
+		},
+		{
+			name:         "linux amd64 variant",
+			a:            Platform{OS: "linux", Architecture: "amd64", Variant: "v3"},
+			b:            Platform{OS: "linux", Architecture: "amd64"},
+			expectMatch:  false,
+			expectCompat: true,
+		},
+		{
+			name:         "linux amd64 newer variant",
+			a:            Platform{OS: "linux", Architecture: "amd64"},
+			b:            Platform{OS: "linux", Architecture: "amd64", Variant: "v2"},
+			expectMatch:  false,
+			expectCompat: false,
+		},
+		{
+			name:         "linux arm64 minor variant",
+			a:            Platform{OS: "linux", Architecture: "arm64", Variant: "v8.2"},
+			b:            Platform{OS: "linux", Architecture: "arm64"},
+			expectMatch:  false,
+			expectCompat: true,
+		},
 		{
 			name:         "windows match",
 			a:            Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2114"},
@@ -61,6 +89,27 @@ func TestCompare(t *testing.T) {
 			expectMatch:  false,
 			expectCompat: false,
 		},
+		{
+			name:         "windows any version",
+			a:            Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2114"},
+			b:            Platform{OS: "windows", Architecture: "amd64"},
+			expectMatch:  false,
+			expectCompat: true,
+		},
+		{
+			name:         "windows features",
+			a:            Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2114", OSFeatures: []string{"win32k"}},
+			b:            Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2114", OSFeatures: []string{"win32k"}},
+			expectMatch:  true,
+			expectCompat: true,
+		},
+		{
+			name:         "windows missing features",
+			a:            Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2114", OSFeatures: []string{"other"}},
+			b:            Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2114", OSFeatures: []string{"win32k"}},
+			expectMatch:  true,
+			expectCompat: false,
+		},
 		{
 			name:         "darwin compatible",
 			a:            Platform{OS: "darwin", Architecture: "amd64"},
@@ -111,6 +160,83 @@ func TestCompare(t *testing.T) {
 	}
 }
 
+func TestBest(t *testing.T) {
+	tests := []struct {
+		name   string
+		host   Platform
+		list   []Platform
+		expect int
+	}{
+		{
+			name: "match",
+			host: Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			list: []Platform{
+				{OS: "linux", Architecture: "amd64"},
+				{OS: "linux", Architecture: "arm", Variant: "v6"},
+				{OS: "linux", Architecture: "arm", Variant: "v7"},
+			},
+			expect: 2,
+		},
+		{
+			name: "closest variant",
+			host: Platform{OS: "linux", Architecture: "arm", Variant: "v8"},
+			list: []Platform{
+				{OS: "linux", Architecture: "arm", Variant: "v5"},
+				{OS: "linux", Architecture: "arm", Variant: "v7"},
+				{OS: "linux", Architecture: "arm", Variant: "v6"},
+			},
+			expect: 1,
+		},
+		{
+			name: "amd64 variant",
+			host: Platform{OS: "linux", Architecture: "amd64", Variant: "v3"},
+			list: []Platform{
+				{OS: "linux", Architecture: "amd64"},
+				{OS: "linux", Architecture: "amd64", Variant: "v2"},
+				{OS: "linux", Architecture: "amd64", Variant: "v4"},
+			},
+			expect: 1,
+		},
+		{
+			name: "windows version",
+			host: Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2114"},
+			list: []Platform{
+				{OS: "linux", Architecture: "amd64"},
+				{OS: "windows", Architecture: "amd64"},
+				{OS: "windows", Architecture: "amd64", OSVersion: "10.0.14393.4583"},
+			},
+			expect: 1,
+		},
+		{
+			name: "windows newest version",
+			host: Platform{OS: "windows", Architecture: "amd64"},
+			list: []Platform{
+				{OS: "linux", Architecture: "amd64"},
+				{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2114"},
+				{OS: "windows", Architecture: "amd64", OSVersion: "10.0.14393.4583"},
+			},
+			expect: 1,
+		},
+		{
+			name: "not found",
+			host: Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			list: []Platform{
+				{OS: "linux", Architecture: "amd64"},
+				{OS: "linux", Architecture: "arm", Variant: "v7"},
+			},
+			expect: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Best(tt.host, tt.list)
+			if result != tt.expect {
+				t.Errorf("unexpected result, expected %d, received %d", tt.expect, result)
+			}
+		})
+	}
+}
+
 func TestPlatformParse(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -8,7 +8,13 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 
+	// crypto libraries included for go-digest
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/opencontainers/go-digest"
 	"github.com/regclient/regclient/types"
 )
 
@@ -20,16 +26,19 @@ const (
 	dockerRegistryLegacy = "index.docker.io"
 	// DockerRegistryDNS is the host to connect to for Hub
 	dockerRegistryDNS = "registry-1.docker.io"
+	// nameMaxLen limits the registry and repository name, matching the docker reference limit
+	nameMaxLen = 255
 )
 
 var (
 	hostPartS = `(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)`
 	// host with port allows a short name in addition to hostDomainS
 	hostPortS = `(?:` + hostPartS + `(?:` + regexp.QuoteMeta(`.`) + hostPartS + `)*` + regexp.QuoteMeta(`.`) + `?` + regexp.QuoteMeta(`:`) + `[0-9]+)`
-	// hostname may be ip, fqdn (example.com), or trailing dot (example.)
+	// hostname may be ip, fqdn (example.com), or trailing dot (example.), an ipv6 address must be in brackets ([::1])
 	hostDomainS = `(?:` + hostPartS + `(?:(?:` + regexp.QuoteMeta(`.`) + hostPartS + `)+` + regexp.QuoteMeta(`.`) + `?|` + regexp.QuoteMeta(`.`) + `))`
 	hostUpperS  = `(?:[a-zA-Z0-9]*[A-Z][a-zA-Z0-9-]*[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[A-Z][a-zA-Z0-9]*)`
-	registryS   = `(?:` + hostDomainS + `|` + hostPortS + `|` + hostUpperS + `|localhost(?:` + regexp.QuoteMeta(`:`) + `[0-9]+))`
+	hostIPv6S   = `(?:` + regexp.QuoteMeta(`[`) + `[a-fA-F0-9:]+` + regexp.QuoteMeta(`]`) + `(?:` + regexp.QuoteMeta(`:`) + `[0-9]+)?)`
+	registryS   = `(?:` + hostDomainS + `|` + hostPortS + `|` + hostUpperS + `|` + hostIPv6S + `|localhost(?:` + regexp.QuoteMeta(`:`) + `[0-9]+))`
 	repoPartS   = `[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*`
 	pathS       = `[/a-zA-Z0-9_\-. ]+`
 	tagS        = `[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}`
@@ -38,12 +47,44 @@ var (
 		`(` + repoPartS + `(?:` + regexp.QuoteMeta(`/`) + repoPartS + `)*)` +
 		`(?:` + regexp.QuoteMeta(`:`) + `(` + tagS + `))?` +
 		`(?:` + regexp.QuoteMeta(`@`) + `(` + digestS + `))?$`)
-	schemeRE = regexp.MustCompile(`^([a-z]+)://(.+)$`)
+	schemeRE = regexp.MustCompile(`^([a-z][a-z0-9+.-]*)://(.+)$`)
+	repoRE   = regexp.MustCompile(`^` + repoPartS + `(?:` + regexp.QuoteMeta(`/`) + repoPartS + `)*$`)
+	tagRE    = regexp.MustCompile(`^` + tagS + `$`)
+	digestRE = regexp.MustCompile(`^` + digestS + `$`)
 	pathRE   = regexp.MustCompile(`^(` + pathS + `)` +
 		`(?:` + regexp.QuoteMeta(`:`) + `(` + tagS + `))?` +
 		`(?:` + regexp.QuoteMeta(`@`) + `(` + digestS + `))?$`)
 )
 
+var (
+	pathSchemesMu sync.RWMutex
+	// pathSchemes are parsed as a path with an optional tag and digest
+	pathSchemes = map[string]bool{"ocidir": true, "ocifile": true}
+)
+
+// RegisterPathScheme adds a scheme that is parsed as a path with an optional tag and digest, e.g. "s3://bucket/layout:v1".
+// This is called by packages implementing a scheme outside of regclient, see scheme.Register.
+func RegisterPathScheme(scheme string) {
+	pathSchemesMu.Lock()
+	defer pathSchemesMu.Unlock()
+	pathSchemes[scheme] = true
+}
+
+func inList(s string, list []string) bool {
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isPathScheme(scheme string) bool {
+	pathSchemesMu.RLock()
+	defer pathSchemesMu.RUnlock()
+	return pathSchemes[scheme]
+}
+
 // Ref reference to a registry/repository
 // If the tag or digest is available, it's also included in the reference.
 // Reference itself is the unparsed string.
@@ -61,6 +102,12 @@ type Ref struct {
 
 // New returns a reference based on the scheme, defaulting to a
 func New(parse string) (Ref, error) {
+	return NewWithSchemes(parse)
+}
+
+// NewWithSchemes is New with additional schemes parsed as a path with an optional tag and digest.
+// This is used for schemes added to a single RegClient rather than registered with RegisterPathScheme.
+func NewWithSchemes(parse string, pathSchemes ...string) (Ref, error) {
 	scheme := ""
 	path := parse
 	matchScheme := schemeRE.FindStringSubmatch(parse)
@@ -107,8 +154,17 @@ func New(parse string) (Ref, error) {
 			return Ref{}, fmt.Errorf("%w \"%s\"", types.ErrInvalidReference, path)
 		}
 
-	case "ocidir", "ocifile":
+	default:
+		if !isPathScheme(scheme) && !inList(scheme, pathSchemes) {
+			return Ref{}, fmt.Errorf("%w, unknown scheme \"%s\" in \"%s\"", types.ErrInvalidReference, scheme, parse)
+		}
 		matchPath := pathRE.FindStringSubmatch(path)
+		if matchPath == nil {
+			// image names with a registry port, e.g. docker-daemon://localhost:5000/repo:tag
+			if matchRef := refRE.FindStringSubmatch(path); matchRef != nil && matchRef[1] != "" {
+				matchPath = []string{matchRef[0], matchRef[1] + "/" + matchRef[2], matchRef[3], matchRef[4]}
+			}
+		}
 		if matchPath == nil || len(matchPath) < 2 || matchPath[1] == "" {
 			return Ref{}, fmt.Errorf("%w, invalid path for scheme \"%s\": %s", types.ErrInvalidReference, scheme, path)
 		}
@@ -119,9 +175,6 @@ func New(parse string) (Ref, error) {
 		if len(matchPath) > 3 && matchPath[3] != "" {
 			ret.Digest = matchPath[3]
 		}
-
-	default:
-		return Ref{}, fmt.Errorf("%w, unknown scheme \"%s\" in \"%s\"", types.ErrInvalidReference, scheme, parse)
 	}
 	return ret, nil
 }
@@ -144,8 +197,11 @@ func (r Ref) CommonName() string {
 		if r.Digest != "" {
 			cn = cn + "@" + r.Digest
 		}
-	case "ocidir":
-		cn = fmt.Sprintf("ocidir://%s", r.Path)
+	default:
+		if r.Scheme == "" || r.Path == "" {
+			return ""
+		}
+		cn = fmt.Sprintf("%s://%s", r.Scheme, r.Path)
 		if r.Tag != "" {
 			cn = cn + ":" + r.Tag
 		}
@@ -156,6 +212,35 @@ func (r Ref) CommonName() string {
 	return cn
 }
 
+// Validate checks the repository, tag, and digest for values a registry would reject.
+// This is used when a reference is modified after parsing, before a request is sent,
+// since some registries respond to invalid names with a confusing error.
+func (r Ref) Validate() error {
+	if r.Scheme == "reg" {
+		if !repoRE.MatchString(r.Repository) {
+			return fmt.Errorf("%w \"%s\", repository \"%s\" must be lowercase letters and digits, with components separated by \"/\" and words separated by \".\", \"_\", \"__\", or \"-\"", types.ErrInvalidReference, r.CommonName(), r.Repository)
+		}
+		if name := r.Registry + "/" + r.Repository; len(name) > nameMaxLen {
+			return fmt.Errorf("%w \"%s\", name is %d characters, limit %d", types.ErrInvalidReference, r.CommonName(), len(name), nameMaxLen)
+		}
+	}
+	if r.Tag != "" && !tagRE.MatchString(r.Tag) {
+		return fmt.Errorf("%w \"%s\", tag \"%s\" must be 1 to 128 letters, digits, \"_\", \".\", or \"-\", and cannot start with \".\" or \"-\"", types.ErrInvalidReference, r.CommonName(), r.Tag)
+	}
+	if r.Digest != "" {
+		if !digestRE.MatchString(r.Digest) {
+			return fmt.Errorf("%w \"%s\", digest \"%s\" must be an algorithm and hex encoded hash, e.g. sha256:<hash>", types.ErrInvalidReference, r.CommonName(), r.Digest)
+		}
+		// check the length of the hash for known algorithms
+		if d := digest.Digest(r.Digest); d.Algorithm().Available() {
+			if err := d.Validate(); err != nil {
+				return fmt.Errorf("%w \"%s\", digest \"%s\": %v", types.ErrInvalidReference, r.CommonName(), r.Digest, err)
+			}
+		}
+	}
+	return nil
+}
+
 // IsZero returns true if ref is unset
 func (r Ref) IsZero() bool {
 	if r.Scheme == "" && r.Registry == "" && r.Repository == "" && r.Path == "" && r.Tag == "" && r.Digest == "" {
@@ -188,13 +273,11 @@ func EqualRegistry(a, b Ref) bool {
 	switch a.Scheme {
 	case "reg":
 		return a.Registry == b.Registry
-	case "ocidir":
-		return a.Path == b.Path
 	case "":
 		// both undefined
 		return true
 	default:
-		return false
+		return a.Path != "" && a.Path == b.Path
 	}
 }
 
@@ -206,12 +289,10 @@ func EqualRepository(a, b Ref) bool {
 	switch a.Scheme {
 	case "reg":
 		return a.Registry == b.Registry && a.Repository == b.Repository
-	case "ocidir":
-		return a.Path == b.Path
 	case "":
 		// both undefined
 		return true
 	default:
-		return false
+		return a.Path != "" && a.Path == b.Path
 	}
 }
@@ -229,6 +229,38 @@ func TestRef(t *testing.T) {
 			path:       "",
 			wantE:      nil,
 		},
+		{
+			name:       "ipv6 registry with port",
+			ref:        "[::1]:5000/group/image:v42",
+			scheme:     "reg",
+			registry:   "[::1]:5000",
+			repository: "group/image",
+			tag:        "v42",
+			digest:     "",
+			path:       "",
+			wantE:      nil,
+		},
+		{
+			name:       "ipv6 registry without port",
+			ref:        "[2001:db8::1]/image@sha256:15f840677a5e245d9ea199eb9b026b1539208a5183621dced7b469f6aa678115",
+			scheme:     "reg",
+			registry:   "[2001:db8::1]",
+			repository: "image",
+			tag:        "",
+			digest:     "sha256:15f840677a5e245d9ea199eb9b026b1539208a5183621dced7b469f6aa678115",
+			path:       "",
+			wantE:      nil,
+		},
+		{
+			name:  "invalid ipv6 without brackets",
+			ref:   "::1:5000/image:v42",
+			wantE: types.ErrInvalidReference,
+		},
+		{
+			name:  "invalid ipv6 missing bracket",
+			ref:   "[::1:5000/image:v42",
+			wantE: types.ErrInvalidReference,
+		},
 		{
 			name:       "OCI file",
 			ref:        "ocifile://path/to/file.tgz",
@@ -300,6 +332,17 @@ func TestRef(t *testing.T) {
 			path:       "path/2/dir",
 			wantE:      nil,
 		},
+		{
+			name:       "OCI dir with registry port",
+			ref:        "ocidir://localhost:5000/repo:v1",
+			scheme:     "ocidir",
+			registry:   "",
+			repository: "",
+			tag:        "v1",
+			digest:     "",
+			path:       "localhost:5000/repo",
+			wantE:      nil,
+		},
 		{
 			name:  "invalid scheme",
 			ref:   "unknown://repo:tag",
@@ -637,3 +680,127 @@ func TestToReg(t *testing.T) {
 	}
 
 }
+
+func TestValidate(t *testing.T) {
+	validDigest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		name      string
+		r         Ref
+		expectErr bool
+	}{
+		{
+			name: "valid",
+			r:    Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "proj/app", Tag: "v1.0", Digest: validDigest},
+		},
+		{
+			name: "valid separators",
+			r:    Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "proj__a/app-b.c", Tag: "_v1"},
+		},
+		{
+			name:      "upper case repo",
+			r:         Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "Proj/app", Tag: "v1"},
+			expectErr: true,
+		},
+		{
+			name:      "empty path component",
+			r:         Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "proj//app", Tag: "v1"},
+			expectErr: true,
+		},
+		{
+			name:      "name too long",
+			r:         Ref{Scheme: "reg", Registry: "registry.example.com", Repository: strings.Repeat("a", 250), Tag: "v1"},
+			expectErr: true,
+		},
+		{
+			name:      "tag too long",
+			r:         Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "proj/app", Tag: strings.Repeat("a", 129)},
+			expectErr: true,
+		},
+		{
+			name:      "tag leading dash",
+			r:         Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "proj/app", Tag: "-v1"},
+			expectErr: true,
+		},
+		{
+			name:      "tag invalid character",
+			r:         Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "proj/app", Tag: "v1+build"},
+			expectErr: true,
+		},
+		{
+			name:      "digest format",
+			r:         Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "proj/app", Digest: "sha256-" + strings.Repeat("a", 64)},
+			expectErr: true,
+		},
+		{
+			name:      "digest length",
+			r:         Ref{Scheme: "reg", Registry: "registry.example.com", Repository: "proj/app", Digest: "sha256:" + strings.Repeat("a", 40)},
+			expectErr: true,
+		},
+		{
+			name: "ocidir path",
+			r:    Ref{Scheme: "ocidir", Path: "Test Dir", Tag: "v1"},
+		},
+		{
+			name:      "ocidir tag",
+			r:         Ref{Scheme: "ocidir", Path: "testdir", Tag: "v1/bad"},
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.r.Validate()
+			if tt.expectErr && err == nil {
+				t.Errorf("validate did not fail")
+			} else if tt.expectErr && !errors.Is(err, types.ErrInvalidReference) {
+				t.Errorf("unexpected error: %v", err)
+			} else if !tt.expectErr && err != nil {
+				t.Errorf("validate failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRegisterPathScheme(t *testing.T) {
+	_, err := New("unittest://bucket/layout:v1")
+	if err == nil {
+		t.Fatalf("unregistered scheme did not fail")
+	}
+	RegisterPathScheme("unittest")
+	r, err := New("unittest://bucket/layout:v1")
+	if err != nil {
+		t.Fatalf("failed to parse registered scheme: %v", err)
+	}
+	if r.Scheme != "unittest" || r.Path != "bucket/layout" || r.Tag != "v1" {
+		t.Errorf("unexpected ref: %#v", r)
+	}
+	if r.CommonName() != "unittest://bucket/layout:v1" {
+		t.Errorf("unexpected common name: %s", r.CommonName())
+	}
+	r2, err := New("unittest://bucket/layout:v2")
+	if err != nil {
+		t.Fatalf("failed to parse registered scheme: %v", err)
+	}
+	if !EqualRepository(r, r2) {
+		t.Errorf("repository mismatch for %s and %s", r.CommonName(), r2.CommonName())
+	}
+	// scheme names may include digits and dashes
+	RegisterPathScheme("unit-test3")
+	r, err = New("unit-test3://layout@" + "sha256:" + strings.Repeat("0", 64))
+	if err != nil {
+		t.Fatalf("failed to parse registered scheme: %v", err)
+	}
+	if r.Scheme != "unit-test3" || r.Path != "layout" || r.Digest == "" {
+		t.Errorf("unexpected ref: %#v", r)
+	}
+	// schemes passed to NewWithSchemes are not registered
+	r, err = NewWithSchemes("clienttest://layout:v1", "clienttest")
+	if err != nil {
+		t.Fatalf("failed to parse scheme: %v", err)
+	}
+	if r.Path != "layout" || r.CommonName() != "clienttest://layout:v1" {
+		t.Errorf("unexpected ref: %#v", r)
+	}
+	if _, err := New("clienttest://layout:v1"); err == nil {
+		t.Errorf("scheme from NewWithSchemes was registered")
+	}
+}
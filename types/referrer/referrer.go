@@ -40,21 +40,15 @@ func (rl *ReferrerList) Add(m manifest.Manifest) error {
 	switch mOrig := m.GetOrig().(type) {
 	case v1.ArtifactManifest:
 		mDesc.Annotations = mOrig.Annotations
-		mDesc.ArtifactType = mOrig.ArtifactType
 	case v1.Manifest:
 		mDesc.Annotations = mOrig.Annotations
-		if mOrig.ArtifactType != "" {
-			mDesc.ArtifactType = mOrig.ArtifactType
-		} else {
-			mDesc.ArtifactType = mOrig.Config.MediaType
-		}
 	case v1.Index:
 		mDesc.Annotations = mOrig.Annotations
-		mDesc.ArtifactType = mOrig.ArtifactType
 	default:
 		// other types are not supported
 		return fmt.Errorf("invalid manifest for referrer \"%t\": %w", m.GetOrig(), types.ErrUnsupportedMediaType)
 	}
+	mDesc.ArtifactType = manifest.GetArtifactType(m)
 	// append descriptor to index
 	rlM.Manifests = append(rlM.Manifests, mDesc)
 	rl.Descriptors = rlM.Manifests
@@ -104,6 +98,26 @@ func (rl ReferrerList) IsEmpty() bool {
 	return false
 }
 
+// Signatures returns the descriptors of referrers that are signatures
+func (rl ReferrerList) Signatures() []types.Descriptor {
+	dl := []types.Descriptor{}
+	for _, d := range rl.Descriptors {
+		if SignatureType(d) != "" {
+			dl = append(dl, d)
+		}
+	}
+	return dl
+}
+
+// SignatureType returns the tool that created a signature referrer, e.g. "notation", or an empty string for other referrers
+func SignatureType(d types.Descriptor) string {
+	switch d.ArtifactType {
+	case types.MediaTypeNotarySignature:
+		return "notation"
+	}
+	return ""
+}
+
 // MarshalPretty is used for printPretty template formatting
 func (rl ReferrerList) MarshalPretty() ([]byte, error) {
 	buf := &bytes.Buffer{}
@@ -125,6 +139,9 @@ func (rl ReferrerList) MarshalPretty() ([]byte, error) {
 		if err != nil {
 			return []byte{}, err
 		}
+		if st := SignatureType(d); st != "" {
+			fmt.Fprintf(tw, "  Signature:\t%s\n", st)
+		}
 	}
 	if rl.Annotations != nil && len(rl.Annotations) > 0 {
 		fmt.Fprintf(tw, "Annotations:\t\n")
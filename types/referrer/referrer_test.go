@@ -2,6 +2,7 @@ package referrer
 
 import (
 	"errors"
+	"regexp"
 	"testing"
 
 	"github.com/opencontainers/go-digest"
@@ -282,6 +283,29 @@ func TestAdd(t *testing.T) {
 	}
 }
 
+func TestSignatures(t *testing.T) {
+	rl := ReferrerList{
+		Descriptors: []types.Descriptor{
+			{MediaType: types.MediaTypeOCI1Manifest, ArtifactType: "application/vnd.example.sbom", Digest: digest.FromString("sbom")},
+			{MediaType: types.MediaTypeOCI1Manifest, ArtifactType: types.MediaTypeNotarySignature, Digest: digest.FromString("sig")},
+		},
+	}
+	sigs := rl.Signatures()
+	if len(sigs) != 1 || sigs[0].Digest != digest.FromString("sig") {
+		t.Errorf("unexpected signatures: %v", sigs)
+	}
+	if st := SignatureType(sigs[0]); st != "notation" {
+		t.Errorf("unexpected signature type: %s", st)
+	}
+	out, err := rl.MarshalPretty()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if !regexp.MustCompile(`Signature:\s+notation`).Match(out) {
+		t.Errorf("signature type missing from output: %s", out)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	rl := &ReferrerList{
 		Descriptors: []types.Descriptor{
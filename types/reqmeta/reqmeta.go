@@ -0,0 +1,41 @@
+// Package reqmeta attaches request scoped metadata to a context.
+// The metadata is added as headers to every registry request made with the context, e.g. to propagate a trace ID.
+package reqmeta
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+var key contextKey = "key"
+
+// NewContext returns a context with the headers added to any headers already in the parent context.
+// Setting "User-Agent" overrides the user agent of the client for requests made with the context.
+func NewContext(ctx context.Context, headers http.Header) context.Context {
+	merged := FromContext(ctx)
+	if merged == nil {
+		merged = http.Header{}
+	}
+	for k, vl := range headers {
+		merged.Del(k)
+		for _, v := range vl {
+			merged.Add(k, v)
+		}
+	}
+	return context.WithValue(ctx, key, merged)
+}
+
+// FromContext returns a copy of the headers in the context, or nil when none are set.
+func FromContext(ctx context.Context) http.Header {
+	hAny := ctx.Value(key)
+	if hAny == nil {
+		return nil
+	}
+	h, ok := hAny.(http.Header)
+	if !ok {
+		return nil
+	}
+	return h.Clone()
+}
@@ -0,0 +1,29 @@
+package reqmeta
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if h := FromContext(ctx); h != nil {
+		t.Errorf("unexpected headers in empty context: %v", h)
+	}
+	ctx1 := NewContext(ctx, http.Header{"X-Trace-Id": []string{"trace1"}, "X-Request-Id": []string{"req1"}})
+	ctx2 := NewContext(ctx1, http.Header{"X-Trace-Id": []string{"trace2"}})
+	h1 := FromContext(ctx1)
+	if h1.Get("X-Trace-Id") != "trace1" || h1.Get("X-Request-Id") != "req1" {
+		t.Errorf("unexpected headers in parent: %v", h1)
+	}
+	h2 := FromContext(ctx2)
+	if len(h2.Values("X-Trace-Id")) != 1 || h2.Get("X-Trace-Id") != "trace2" || h2.Get("X-Request-Id") != "req1" {
+		t.Errorf("unexpected headers in child: %v", h2)
+	}
+	// modifying the returned headers does not change the context
+	h2.Set("X-Request-Id", "changed")
+	if FromContext(ctx2).Get("X-Request-Id") != "req1" {
+		t.Errorf("context headers were modified")
+	}
+}
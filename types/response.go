@@ -0,0 +1,49 @@
+package types
+
+import (
+	"net/http"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// ResponseHeaders contains selected headers returned by a registry.
+// The request ID is useful when opening a support request with a registry provider.
+type ResponseHeaders struct {
+	Digest    digest.Digest `json:"digest,omitempty"`    // Docker-Content-Digest header
+	Subject   digest.Digest `json:"subject,omitempty"`   // OCI-Subject header, returned when a registry processed the subject of a pushed manifest
+	RequestID string        `json:"requestID,omitempty"` // request ID from the first matching header in RequestIDHeaders
+	RateLimit RateLimit     `json:"rateLimit"`
+}
+
+// RequestIDHeaders are the headers used by registries to identify a request, in order of precedence.
+var RequestIDHeaders = []string{
+	"X-Request-Id",
+	"Docker-Request-Id",
+	"X-Amzn-Requestid",
+	"X-Ms-Request-Id",
+	"X-Github-Request-Id",
+	"X-Cloud-Trace-Context",
+}
+
+// ParseResponseHeaders extracts the selected headers from a registry response.
+// Invalid digests are ignored.
+func ParseResponseHeaders(header http.Header) ResponseHeaders {
+	rh := ResponseHeaders{}
+	if header == nil {
+		return rh
+	}
+	if dig, err := digest.Parse(header.Get("Docker-Content-Digest")); err == nil {
+		rh.Digest = dig
+	}
+	if dig, err := digest.Parse(header.Get("OCI-Subject")); err == nil {
+		rh.Subject = dig
+	}
+	for _, k := range RequestIDHeaders {
+		if v := header.Get(k); v != "" {
+			rh.RequestID = v
+			break
+		}
+	}
+	rh.RateLimit = ParseRateLimit(header)
+	return rh
+}
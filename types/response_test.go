@@ -0,0 +1,77 @@
+package types
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func TestParseResponseHeaders(t *testing.T) {
+	dig := digest.FromString("manifest")
+	digSubject := digest.FromString("subject")
+	tt := []struct {
+		name   string
+		header http.Header
+		expect ResponseHeaders
+	}{
+		{
+			name:   "nil",
+			header: nil,
+			expect: ResponseHeaders{},
+		},
+		{
+			name: "distribution",
+			header: http.Header{
+				"Docker-Content-Digest": {dig.String()},
+				"Oci-Subject":           {digSubject.String()},
+				"X-Request-Id":          {"request-1"},
+			},
+			expect: ResponseHeaders{
+				Digest:    dig,
+				Subject:   digSubject,
+				RequestID: "request-1",
+			},
+		},
+		{
+			name: "hub rate limit",
+			header: http.Header{
+				"Docker-Content-Digest": {dig.String()},
+				"Ratelimit-Limit":       {"100;w=21600"},
+				"Ratelimit-Remaining":   {"42;w=21600"},
+			},
+			expect: ResponseHeaders{
+				Digest: dig,
+				RateLimit: RateLimit{
+					Limit:    100,
+					Remain:   42,
+					Set:      true,
+					Policies: []string{"100;w=21600"},
+				},
+			},
+		},
+		{
+			name: "precedence and invalid digest",
+			header: http.Header{
+				"Docker-Content-Digest": {"invalid"},
+				"X-Amzn-Requestid":      {"request-aws"},
+				"Docker-Request-Id":     {"request-docker"},
+			},
+			expect: ResponseHeaders{
+				RequestID: "request-docker",
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ParseResponseHeaders(tc.header)
+			if result.Digest != tc.expect.Digest || result.Subject != tc.expect.Subject || result.RequestID != tc.expect.RequestID {
+				t.Errorf("unexpected result, expected %v, received %v", tc.expect, result)
+			}
+			rl, expectRL := result.RateLimit, tc.expect.RateLimit
+			if rl.Limit != expectRL.Limit || rl.Remain != expectRL.Remain || rl.Set != expectRL.Set || len(rl.Policies) != len(expectRL.Policies) {
+				t.Errorf("unexpected rate limit, expected %v, received %v", expectRL, rl)
+			}
+		})
+	}
+}
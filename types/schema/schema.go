@@ -0,0 +1,404 @@
+// Package schema validates manifests and configs against the OCI image-spec JSON schemas.
+//
+// Docker schema2 manifests, manifest lists, and configs are validated against the equivalent OCI schema.
+// Fields not defined by the schema are permitted, matching the image-spec.
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/regclient/regclient/types"
+)
+
+var (
+	// patterns from the image-spec defs-descriptor.json
+	digestRE    = regexp.MustCompile(`^[a-z0-9]+(?:[+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+	mediaTypeRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]{0,126}/[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]{0,126}$`)
+	// exposed ports are a port number with an optional protocol
+	portRE = regexp.MustCompile(`^[0-9]+(?:/(?:tcp|udp|sctp))?$`)
+)
+
+// Violation is a single field that does not match the schema.
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// String formats the violation with the field and message.
+func (v Violation) String() string {
+	if v.Field == "" {
+		return v.Message
+	}
+	return v.Field + ": " + v.Message
+}
+
+// Error is returned when content does not match the schema, it wraps types.ErrSchemaInvalid.
+type Error struct {
+	MediaType  string      `json:"mediaType"`
+	Violations []Violation `json:"violations"`
+}
+
+// Error lists each of the violations.
+func (e *Error) Error() string {
+	vl := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		vl[i] = v.String()
+	}
+	return fmt.Sprintf("%s does not match the schema: %s", e.MediaType, strings.Join(vl, "; "))
+}
+
+// Unwrap returns types.ErrSchemaInvalid.
+func (e *Error) Unwrap() error {
+	return types.ErrSchemaInvalid
+}
+
+// Supported returns true when the media type has a schema to validate.
+func Supported(mediaType string) bool {
+	switch mediaType {
+	case types.MediaTypeOCI1Manifest, types.MediaTypeDocker2Manifest,
+		types.MediaTypeOCI1ManifestList, types.MediaTypeDocker2ManifestList,
+		types.MediaTypeOCI1ImageConfig, types.MediaTypeDocker2ImageConfig:
+		return true
+	}
+	return false
+}
+
+// Validate checks the raw content against the schema for the media type.
+// An *Error listing every violation is returned when the content does not match.
+// Media types without a schema, see Supported, return an error wrapping types.ErrUnsupportedMediaType.
+func Validate(mediaType string, raw []byte) error {
+	if !Supported(mediaType) {
+		return fmt.Errorf("no schema for media type %s%.0w", mediaType, types.ErrUnsupportedMediaType)
+	}
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	err := dec.Decode(&doc)
+	if err != nil {
+		return &Error{MediaType: mediaType, Violations: []Violation{{Message: fmt.Sprintf("invalid json: %v", err)}}}
+	}
+	v := &validator{}
+	switch mediaType {
+	case types.MediaTypeOCI1Manifest, types.MediaTypeDocker2Manifest:
+		v.manifest(doc, mediaType)
+	case types.MediaTypeOCI1ManifestList, types.MediaTypeDocker2ManifestList:
+		v.index(doc, mediaType)
+	case types.MediaTypeOCI1ImageConfig, types.MediaTypeDocker2ImageConfig:
+		v.config(doc)
+	}
+	if len(v.violations) > 0 {
+		return &Error{MediaType: mediaType, Violations: v.violations}
+	}
+	return nil
+}
+
+// validator collects violations while walking the decoded json
+type validator struct {
+	violations []Violation
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func join(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// object returns the value as a map, or nil with a violation when it is another type
+func (v *validator) object(field string, val interface{}) map[string]interface{} {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		v.add(field, "expected an object, received %s", jsonType(val))
+		return nil
+	}
+	return obj
+}
+
+// field returns a value from an object, adding a violation when a required field is missing
+func (v *validator) field(obj map[string]interface{}, parent, key string, required bool) (interface{}, bool) {
+	val, ok := obj[key]
+	if !ok {
+		if required {
+			v.add(join(parent, key), "required field is missing")
+		}
+		return nil, false
+	}
+	return val, true
+}
+
+func (v *validator) str(obj map[string]interface{}, parent, key string, required bool) (string, bool) {
+	val, ok := v.field(obj, parent, key, required)
+	if !ok {
+		return "", false
+	}
+	s, ok := val.(string)
+	if !ok {
+		v.add(join(parent, key), "expected a string, received %s", jsonType(val))
+		return "", false
+	}
+	return s, true
+}
+
+func (v *validator) pattern(obj map[string]interface{}, parent, key string, required bool, re *regexp.Regexp) {
+	if s, ok := v.str(obj, parent, key, required); ok && !re.MatchString(s) {
+		v.add(join(parent, key), "value %q does not match %s", s, re.String())
+	}
+}
+
+func (v *validator) boolean(obj map[string]interface{}, parent, key string) {
+	if val, ok := v.field(obj, parent, key, false); ok {
+		if _, ok := val.(bool); !ok {
+			v.add(join(parent, key), "expected a boolean, received %s", jsonType(val))
+		}
+	}
+}
+
+func (v *validator) dateTime(obj map[string]interface{}, parent, key string) {
+	if s, ok := v.str(obj, parent, key, false); ok {
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			v.add(join(parent, key), "value %q is not an RFC 3339 date-time", s)
+		}
+	}
+}
+
+func (v *validator) int64(obj map[string]interface{}, parent, key string, required bool) (int64, bool) {
+	val, ok := v.field(obj, parent, key, required)
+	if !ok {
+		return 0, false
+	}
+	num, ok := val.(json.Number)
+	if !ok {
+		v.add(join(parent, key), "expected an integer, received %s", jsonType(val))
+		return 0, false
+	}
+	i, err := num.Int64()
+	if err != nil {
+		v.add(join(parent, key), "expected an integer, received %s", num.String())
+		return 0, false
+	}
+	return i, true
+}
+
+// array returns the entries of an array, null is accepted when nullable is set
+func (v *validator) array(obj map[string]interface{}, parent, key string, required, nullable bool) ([]interface{}, bool) {
+	val, ok := v.field(obj, parent, key, required)
+	if !ok {
+		return nil, false
+	}
+	if val == nil && nullable {
+		return nil, false
+	}
+	list, ok := val.([]interface{})
+	if !ok {
+		v.add(join(parent, key), "expected an array, received %s", jsonType(val))
+		return nil, false
+	}
+	return list, true
+}
+
+func (v *validator) strArray(obj map[string]interface{}, parent, key string, required, nullable bool) {
+	list, _ := v.array(obj, parent, key, required, nullable)
+	for i, entry := range list {
+		if _, ok := entry.(string); !ok {
+			v.add(fmt.Sprintf("%s[%d]", join(parent, key), i), "expected a string, received %s", jsonType(entry))
+		}
+	}
+}
+
+// strMap validates an object with string values, e.g. annotations and labels
+func (v *validator) strMap(obj map[string]interface{}, parent, key string) {
+	val, ok := v.field(obj, parent, key, false)
+	if !ok {
+		return
+	}
+	m := v.object(join(parent, key), val)
+	for k, entry := range m {
+		if _, ok := entry.(string); !ok {
+			v.add(join(join(parent, key), k), "expected a string, received %s", jsonType(entry))
+		}
+	}
+}
+
+func (v *validator) schemaVersion(obj map[string]interface{}) {
+	if i, ok := v.int64(obj, "", "schemaVersion", true); ok && i != 2 {
+		v.add("schemaVersion", "expected 2, received %d", i)
+	}
+}
+
+// mediaType validates the optional mediaType field matches the expected value
+func (v *validator) mediaType(obj map[string]interface{}, expect string, required bool) {
+	if s, ok := v.str(obj, "", "mediaType", required); ok && s != expect {
+		v.add("mediaType", "expected %s, received %s", expect, s)
+	}
+}
+
+func (v *validator) descriptor(field string, val interface{}, withPlatform bool) {
+	obj := v.object(field, val)
+	if obj == nil {
+		return
+	}
+	v.pattern(obj, field, "mediaType", true, mediaTypeRE)
+	if i, ok := v.int64(obj, field, "size", true); ok && i < 0 {
+		v.add(join(field, "size"), "size must not be negative, received %d", i)
+	}
+	v.pattern(obj, field, "digest", true, digestRE)
+	v.strArray(obj, field, "urls", false, false)
+	v.strMap(obj, field, "annotations")
+	if s, ok := v.str(obj, field, "data", false); ok {
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			v.add(join(field, "data"), "invalid base64 encoding: %v", err)
+		}
+	}
+	v.pattern(obj, field, "artifactType", false, mediaTypeRE)
+	if pVal, ok := v.field(obj, field, "platform", false); ok {
+		if !withPlatform {
+			return
+		}
+		pField := join(field, "platform")
+		pObj := v.object(pField, pVal)
+		if pObj == nil {
+			return
+		}
+		v.str(pObj, pField, "architecture", true)
+		v.str(pObj, pField, "os", true)
+		v.str(pObj, pField, "os.version", false)
+		v.strArray(pObj, pField, "os.features", false, false)
+		v.str(pObj, pField, "variant", false)
+	}
+}
+
+func (v *validator) manifest(doc interface{}, mediaType string) {
+	obj := v.object("", doc)
+	if obj == nil {
+		return
+	}
+	v.schemaVersion(obj)
+	// the mediaType field is required for docker manifests
+	v.mediaType(obj, mediaType, mediaType == types.MediaTypeDocker2Manifest)
+	v.pattern(obj, "", "artifactType", false, mediaTypeRE)
+	if val, ok := v.field(obj, "", "config", true); ok {
+		v.descriptor("config", val, false)
+	}
+	layers, _ := v.array(obj, "", "layers", true, false)
+	for i, l := range layers {
+		v.descriptor(fmt.Sprintf("layers[%d]", i), l, false)
+	}
+	if val, ok := v.field(obj, "", "subject", false); ok {
+		v.descriptor("subject", val, false)
+	}
+	v.strMap(obj, "", "annotations")
+}
+
+func (v *validator) index(doc interface{}, mediaType string) {
+	obj := v.object("", doc)
+	if obj == nil {
+		return
+	}
+	v.schemaVersion(obj)
+	v.mediaType(obj, mediaType, mediaType == types.MediaTypeDocker2ManifestList)
+	v.pattern(obj, "", "artifactType", false, mediaTypeRE)
+	manifests, _ := v.array(obj, "", "manifests", true, false)
+	for i, d := range manifests {
+		v.descriptor(fmt.Sprintf("manifests[%d]", i), d, true)
+	}
+	if val, ok := v.field(obj, "", "subject", false); ok {
+		v.descriptor("subject", val, false)
+	}
+	v.strMap(obj, "", "annotations")
+}
+
+func (v *validator) config(doc interface{}) {
+	obj := v.object("", doc)
+	if obj == nil {
+		return
+	}
+	v.dateTime(obj, "", "created")
+	v.str(obj, "", "author", false)
+	v.str(obj, "", "architecture", true)
+	v.str(obj, "", "os", true)
+	v.str(obj, "", "os.version", false)
+	v.strArray(obj, "", "os.features", false, false)
+	v.str(obj, "", "variant", false)
+	if val, ok := v.field(obj, "", "config", false); ok && val != nil {
+		if cObj := v.object("config", val); cObj != nil {
+			v.str(cObj, "config", "User", false)
+			if pVal, ok := v.field(cObj, "config", "ExposedPorts", false); ok && pVal != nil {
+				for port := range v.object("config.ExposedPorts", pVal) {
+					if !portRE.MatchString(port) {
+						v.add("config.ExposedPorts", "port %q does not match %s", port, portRE.String())
+					}
+				}
+			}
+			v.strArray(cObj, "config", "Env", false, true)
+			v.strArray(cObj, "config", "Entrypoint", false, true)
+			v.strArray(cObj, "config", "Cmd", false, true)
+			if vVal, ok := v.field(cObj, "config", "Volumes", false); ok && vVal != nil {
+				v.object("config.Volumes", vVal)
+			}
+			v.str(cObj, "config", "WorkingDir", false)
+			if lVal, ok := v.field(cObj, "config", "Labels", false); ok && lVal != nil {
+				v.strMap(cObj, "config", "Labels")
+			}
+			v.str(cObj, "config", "StopSignal", false)
+			v.boolean(cObj, "config", "ArgsEscaped")
+		}
+	}
+	if val, ok := v.field(obj, "", "rootfs", true); ok {
+		if rObj := v.object("rootfs", val); rObj != nil {
+			if s, ok := v.str(rObj, "rootfs", "type", true); ok && s != "layers" {
+				v.add("rootfs.type", "expected layers, received %s", s)
+			}
+			diffIDs, _ := v.array(rObj, "rootfs", "diff_ids", true, false)
+			for i, d := range diffIDs {
+				s, ok := d.(string)
+				if !ok {
+					v.add(fmt.Sprintf("rootfs.diff_ids[%d]", i), "expected a string, received %s", jsonType(d))
+				} else if !digestRE.MatchString(s) {
+					v.add(fmt.Sprintf("rootfs.diff_ids[%d]", i), "value %q does not match %s", s, digestRE.String())
+				}
+			}
+		}
+	}
+	history, _ := v.array(obj, "", "history", false, false)
+	for i, h := range history {
+		field := fmt.Sprintf("history[%d]", i)
+		hObj := v.object(field, h)
+		if hObj == nil {
+			continue
+		}
+		v.dateTime(hObj, field, "created")
+		v.str(hObj, field, "author", false)
+		v.str(hObj, field, "created_by", false)
+		v.str(hObj, field, "comment", false)
+		v.boolean(hObj, field, "empty_layer")
+	}
+}
+
+// jsonType describes the type of a decoded json value for error messages
+func jsonType(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", val)
+}
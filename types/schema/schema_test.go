@@ -0,0 +1,111 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/regclient/regclient/types"
+)
+
+func TestValidate(t *testing.T) {
+	dig := "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	tt := []struct {
+		name       string
+		mediaType  string
+		raw        string
+		violations []string
+		errIs      error
+	}{
+		{
+			name:      "OCI manifest",
+			mediaType: types.MediaTypeOCI1Manifest,
+			raw:       `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","size":2,"digest":"` + dig + `"},"layers":[],"annotations":{"a":"b"}}`,
+		},
+		{
+			name:       "OCI manifest invalid",
+			mediaType:  types.MediaTypeOCI1Manifest,
+			raw:        `{"schemaVersion":"2","mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"mediaType":"config","size":-1,"digest":"sha256"},"layers":[{"mediaType":"application/octet-stream","size":1.5,"digest":"` + dig + `","data":"!"}],"annotations":{"a":1}}`,
+			violations: []string{"schemaVersion", "mediaType", "config.mediaType", "config.size", "config.digest", "layers[0].size", "layers[0].data", "annotations.a"},
+			errIs:      types.ErrSchemaInvalid,
+		},
+		{
+			name:       "docker manifest missing fields",
+			mediaType:  types.MediaTypeDocker2Manifest,
+			raw:        `{"schemaVersion":2}`,
+			violations: []string{"mediaType", "config", "layers"},
+			errIs:      types.ErrSchemaInvalid,
+		},
+		{
+			name:      "OCI index",
+			mediaType: types.MediaTypeOCI1ManifestList,
+			raw:       `{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":2,"digest":"` + dig + `","platform":{"architecture":"amd64","os":"linux"}}]}`,
+		},
+		{
+			name:       "OCI index invalid platform",
+			mediaType:  types.MediaTypeOCI1ManifestList,
+			raw:        `{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":2,"digest":"` + dig + `","platform":{"os":"linux","os.features":"sse4"}}]}`,
+			violations: []string{"manifests[0].platform.architecture", "manifests[0].platform.os.features"},
+			errIs:      types.ErrSchemaInvalid,
+		},
+		{
+			name:      "OCI config",
+			mediaType: types.MediaTypeOCI1ImageConfig,
+			raw:       `{"created":"2023-01-02T03:04:05.123Z","architecture":"amd64","os":"linux","config":{"Env":["A=b"],"Cmd":null,"ExposedPorts":{"80/tcp":{}},"Labels":{"a":"b"}},"rootfs":{"type":"layers","diff_ids":["` + dig + `"]},"history":[{"created_by":"test","empty_layer":true}]}`,
+		},
+		{
+			name:       "OCI config invalid",
+			mediaType:  types.MediaTypeOCI1ImageConfig,
+			raw:        `{"created":"today","os":"linux","config":{"Env":"A=b","ExposedPorts":{"http":{}}},"rootfs":{"type":"layer","diff_ids":["abc"]},"history":[{"empty_layer":"true"}]}`,
+			violations: []string{"created", "architecture", "config.Env", "config.ExposedPorts", "rootfs.type", "rootfs.diff_ids[0]", "history[0].empty_layer"},
+			errIs:      types.ErrSchemaInvalid,
+		},
+		{
+			name:       "invalid json",
+			mediaType:  types.MediaTypeOCI1ImageConfig,
+			raw:        `{"os":`,
+			violations: []string{""},
+			errIs:      types.ErrSchemaInvalid,
+		},
+		{
+			name:      "unsupported",
+			mediaType: "application/example",
+			raw:       `{}`,
+			errIs:     types.ErrUnsupportedMediaType,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.mediaType, []byte(tc.raw))
+			if tc.errIs == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.errIs) {
+				t.Errorf("unexpected error, expected %v, received %v", tc.errIs, err)
+				return
+			}
+			if len(tc.violations) == 0 {
+				return
+			}
+			var errSchema *Error
+			if !errors.As(err, &errSchema) {
+				t.Errorf("error is not a schema error: %v", err)
+				return
+			}
+			fields := map[string]bool{}
+			for _, v := range errSchema.Violations {
+				fields[v.Field] = true
+			}
+			for _, field := range tc.violations {
+				if !fields[field] {
+					t.Errorf("missing violation for %q: %v", field, err)
+				}
+			}
+			if len(errSchema.Violations) != len(tc.violations) {
+				t.Errorf("unexpected violations, expected %d, received %v", len(tc.violations), errSchema.Violations)
+			}
+		})
+	}
+}